@@ -11,6 +11,7 @@ import (
 	"k8s.io/component-base/logs"
 
 	"open-cluster-management.io/ocm/pkg/cmd/hub"
+	"open-cluster-management.io/ocm/pkg/cmd/operator"
 	"open-cluster-management.io/ocm/pkg/cmd/spoke"
 	"open-cluster-management.io/ocm/pkg/version"
 )
@@ -49,6 +50,7 @@ func newNucleusCommand() *cobra.Command {
 	cmd.AddCommand(hub.NewHubOperatorCmd())
 	cmd.AddCommand(spoke.NewKlusterletOperatorCmd())
 	cmd.AddCommand(spoke.NewKlusterletAgentCmd())
+	cmd.AddCommand(operator.NewRenderCmd())
 
 	return cmd
 }
@@ -11,8 +11,6 @@ import (
 	utilflag "k8s.io/component-base/cli/flag"
 	"k8s.io/component-base/logs"
 
-	ocmfeature "open-cluster-management.io/api/feature"
-
 	"open-cluster-management.io/ocm/pkg/cmd/hub"
 	"open-cluster-management.io/ocm/pkg/cmd/spoke"
 	"open-cluster-management.io/ocm/pkg/cmd/webhook"
@@ -31,7 +29,7 @@ func main() {
 	logs.InitLogs()
 	defer logs.FlushLogs()
 
-	utilruntime.Must(features.HubMutableFeatureGate.Add(ocmfeature.DefaultHubRegistrationFeatureGates))
+	utilruntime.Must(features.HubMutableFeatureGate.Add(features.DefaultHubRegistrationFeatureGates))
 	features.HubMutableFeatureGate.AddFlag(pflag.CommandLine)
 
 	command := newRegistrationCommand()
@@ -62,6 +60,9 @@ func newRegistrationCommand() *cobra.Command {
 	cmd.AddCommand(hub.NewRegistrationController())
 	cmd.AddCommand(spoke.NewRegistrationAgent())
 	cmd.AddCommand(webhook.NewRegistrationWebhook())
+	cmd.AddCommand(hub.NewHubBackup())
+	cmd.AddCommand(hub.NewHubRestore())
+	cmd.AddCommand(hub.NewHubDiagnostics())
 
 	return cmd
 }
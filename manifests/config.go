@@ -28,10 +28,12 @@ type HubConfig struct {
 	AgentImage                     string
 	CloudEventsDriverEnabled       bool
 	ClusterImporterEnabled         bool
-	ImporterRenderers              string
-	WorkDriver                     string
-	AutoApproveUsers               string
-	ImagePullSecret                string
+	// ClientCertificateRevocationEnabled is true if the revoked managed cluster credentials are rejected on the hub.
+	ClientCertificateRevocationEnabled bool
+	ImporterRenderers                  string
+	WorkDriver                         string
+	AutoApproveUsers                   string
+	ImagePullSecret                    string
 	// ResourceRequirementResourceType is the resource requirement resource type for the cluster manager managed containers.
 	ResourceRequirementResourceType operatorapiv1.ResourceQosClass
 	// ResourceRequirements is the resource requirements for the cluster manager managed containers.
@@ -49,6 +51,9 @@ type HubConfig struct {
 	GRPCServerImage                   string
 	GRPCAutoApprovedUsers             string
 	GRPCEndpointType                  string
+	// EnabledHubFeatures is the comma separated features enabled on the hub, which are checked against the agent
+	// version skew policy.
+	EnabledHubFeatures string
 }
 
 type Webhook struct {
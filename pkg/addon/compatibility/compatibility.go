@@ -0,0 +1,155 @@
+package compatibility
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/version"
+
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+const (
+	// RequirementsAnnotationKey is the annotation key of the ClusterManagementAddOn declaring the requirements of
+	// the addon on the managed clusters. The value is a json encoded Requirements.
+	RequirementsAnnotationKey = "addon.open-cluster-management.io/compatibility"
+
+	// ManagedClusterAddOnConditionInstalled represents whether the addon can be installed on the cluster. It is set
+	// to False with the reason Incompatible if the cluster does not meet the requirements of the addon.
+	ManagedClusterAddOnConditionInstalled = "Installed"
+	// IncompatibleReason is the reason of the Installed condition if the cluster is incompatible.
+	IncompatibleReason = "Incompatible"
+)
+
+// Requirements are the requirements of the addon on the managed clusters.
+type Requirements struct {
+	// KubernetesVersion is the range of the kubernetes version of the cluster, read from the status.version of
+	// the ManagedCluster.
+	KubernetesVersion *VersionRange `json:"kubernetesVersion,omitempty"`
+	// Claims are the cluster claims required on the cluster.
+	Claims []ClaimRequirement `json:"claims,omitempty"`
+	// APIs are the names of the claims reported by the APIPresence claim provider of the registration agent, the
+	// value of the claims must be true.
+	APIs []string `json:"apis,omitempty"`
+}
+
+// VersionRange is a range of versions, the min version is inclusive and the max version is exclusive.
+type VersionRange struct {
+	Min string `json:"min,omitempty"`
+	Max string `json:"max,omitempty"`
+}
+
+// ClaimRequirement requires the claim on the cluster, and if Values is set, the value of the claim to be one of
+// the values.
+type ClaimRequirement struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values,omitempty"`
+}
+
+// GetRequirements returns the requirements declared by the ClusterManagementAddOn, nil if it is not declared.
+func GetRequirements(cma *addonv1alpha1.ClusterManagementAddOn) (*Requirements, error) {
+	value, ok := cma.Annotations[RequirementsAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	requirements := &Requirements{}
+	if err := json.Unmarshal([]byte(value), requirements); err != nil {
+		return nil, fmt.Errorf("invalid annotation %s of addon %s: %v", RequirementsAnnotationKey, cma.Name, err)
+	}
+	if err := requirements.validate(); err != nil {
+		return nil, fmt.Errorf("invalid annotation %s of addon %s: %v", RequirementsAnnotationKey, cma.Name, err)
+	}
+	return requirements, nil
+}
+
+func (r *Requirements) validate() error {
+	if r.KubernetesVersion != nil {
+		var minVersion, maxVersion *version.Version
+		var err error
+		if len(r.KubernetesVersion.Min) > 0 {
+			if minVersion, err = version.ParseGeneric(r.KubernetesVersion.Min); err != nil {
+				return err
+			}
+		}
+		if len(r.KubernetesVersion.Max) > 0 {
+			if maxVersion, err = version.ParseGeneric(r.KubernetesVersion.Max); err != nil {
+				return err
+			}
+		}
+		if minVersion != nil && maxVersion != nil && !minVersion.LessThan(maxVersion) {
+			return fmt.Errorf("min kubernetes version %s must be less than max version %s",
+				r.KubernetesVersion.Min, r.KubernetesVersion.Max)
+		}
+	}
+	for _, claim := range r.Claims {
+		if len(claim.Name) == 0 {
+			return fmt.Errorf("name of the claim is required")
+		}
+	}
+	return nil
+}
+
+// Check checks whether the cluster meets the requirements, and returns the message of the unmet requirements.
+func Check(cluster *clusterv1.ManagedCluster, requirements *Requirements) (bool, string) {
+	if requirements == nil {
+		return true, ""
+	}
+
+	var messages []string
+	if r := requirements.KubernetesVersion; r != nil {
+		if message := checkKubernetesVersion(cluster.Status.Version.Kubernetes, r); len(message) > 0 {
+			messages = append(messages, message)
+		}
+	}
+
+	claims := map[string]string{}
+	for _, claim := range cluster.Status.ClusterClaims {
+		claims[claim.Name] = claim.Value
+	}
+	for _, r := range requirements.Claims {
+		value, ok := claims[r.Name]
+		switch {
+		case !ok:
+			messages = append(messages, fmt.Sprintf("claim %s is not found", r.Name))
+		case len(r.Values) > 0 && !slices.Contains(r.Values, value):
+			messages = append(messages, fmt.Sprintf("claim %s is %q, expected one of %s", r.Name, value,
+				strings.Join(r.Values, ", ")))
+		}
+	}
+	for _, api := range requirements.APIs {
+		if claims[api] != "true" {
+			messages = append(messages, fmt.Sprintf("api %s is not available", api))
+		}
+	}
+
+	if len(messages) > 0 {
+		return false, strings.Join(messages, "; ")
+	}
+	return true, ""
+}
+
+func checkKubernetesVersion(kubeVersion string, r *VersionRange) string {
+	if len(kubeVersion) == 0 {
+		return "kubernetes version of the cluster is unknown"
+	}
+	current, err := version.ParseGeneric(kubeVersion)
+	if err != nil {
+		return fmt.Sprintf("invalid kubernetes version %s of the cluster", kubeVersion)
+	}
+
+	if len(r.Min) > 0 {
+		if minVersion, err := version.ParseGeneric(r.Min); err == nil && current.LessThan(minVersion) {
+			return fmt.Sprintf("kubernetes version %s is lower than %s", kubeVersion, r.Min)
+		}
+	}
+	if len(r.Max) > 0 {
+		if maxVersion, err := version.ParseGeneric(r.Max); err == nil && !current.LessThan(maxVersion) {
+			return fmt.Sprintf("kubernetes version %s is not lower than %s", kubeVersion, r.Max)
+		}
+	}
+	return ""
+}
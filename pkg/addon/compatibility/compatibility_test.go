@@ -0,0 +1,147 @@
+package compatibility
+
+import (
+	"testing"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+func TestGetRequirements(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotation  string
+		expectedNil bool
+		expectedErr bool
+	}{
+		{
+			name:        "no requirements",
+			expectedNil: true,
+		},
+		{
+			name:        "invalid json",
+			annotation:  "invalid",
+			expectedErr: true,
+		},
+		{
+			name:        "invalid version",
+			annotation:  `{"kubernetesVersion": {"min": "latest"}}`,
+			expectedErr: true,
+		},
+		{
+			name:        "invalid version range",
+			annotation:  `{"kubernetesVersion": {"min": "1.30", "max": "1.29"}}`,
+			expectedErr: true,
+		},
+		{
+			name:        "claim without name",
+			annotation:  `{"claims": [{"values": ["AWS"]}]}`,
+			expectedErr: true,
+		},
+		{
+			name:       "valid",
+			annotation: `{"kubernetesVersion": {"min": "1.29", "max": "1.33.0"}, "claims": [{"name": "platform"}], "apis": ["api.certmanager"]}`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cma := addontesting.NewClusterManagementAddon("test", "", "").Build()
+			if len(c.annotation) > 0 {
+				cma.Annotations = map[string]string{RequirementsAnnotationKey: c.annotation}
+			}
+			requirements, err := GetRequirements(cma)
+			if c.expectedErr != (err != nil) {
+				t.Fatalf("expected error %v, but got %v", c.expectedErr, err)
+			}
+			if !c.expectedErr && c.expectedNil != (requirements == nil) {
+				t.Errorf("expected nil requirements %v, but got %v", c.expectedNil, requirements)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	newCluster := func(kubeVersion string, claims ...clusterv1.ManagedClusterClaim) *clusterv1.ManagedCluster {
+		cluster := addontesting.NewManagedCluster("cluster1")
+		cluster.Status.Version.Kubernetes = kubeVersion
+		cluster.Status.ClusterClaims = claims
+		return cluster
+	}
+
+	cases := []struct {
+		name               string
+		cluster            *clusterv1.ManagedCluster
+		requirements       *Requirements
+		expectedCompatible bool
+		expectedMessage    string
+	}{
+		{
+			name:               "no requirements",
+			cluster:            newCluster(""),
+			expectedCompatible: true,
+		},
+		{
+			name:               "version in range",
+			cluster:            newCluster("v1.30.2+k3s1"),
+			requirements:       &Requirements{KubernetesVersion: &VersionRange{Min: "1.29", Max: "1.33"}},
+			expectedCompatible: true,
+		},
+		{
+			name:            "version too low",
+			cluster:         newCluster("v1.28.0"),
+			requirements:    &Requirements{KubernetesVersion: &VersionRange{Min: "1.29"}},
+			expectedMessage: "kubernetes version v1.28.0 is lower than 1.29",
+		},
+		{
+			name:            "max version is exclusive",
+			cluster:         newCluster("v1.33.0"),
+			requirements:    &Requirements{KubernetesVersion: &VersionRange{Max: "1.33.0"}},
+			expectedMessage: "kubernetes version v1.33.0 is not lower than 1.33.0",
+		},
+		{
+			name:            "unknown version",
+			cluster:         newCluster(""),
+			requirements:    &Requirements{KubernetesVersion: &VersionRange{Min: "1.29"}},
+			expectedMessage: "kubernetes version of the cluster is unknown",
+		},
+		{
+			name: "claims and apis",
+			cluster: newCluster("v1.30.0",
+				clusterv1.ManagedClusterClaim{Name: "platform.open-cluster-management.io", Value: "AWS"},
+				clusterv1.ManagedClusterClaim{Name: "api.certmanager", Value: "true"}),
+			requirements: &Requirements{
+				Claims: []ClaimRequirement{{Name: "platform.open-cluster-management.io", Values: []string{"AWS", "GCP"}}},
+				APIs:   []string{"api.certmanager"},
+			},
+			expectedCompatible: true,
+		},
+		{
+			name: "claims and apis not met",
+			cluster: newCluster("v1.30.0",
+				clusterv1.ManagedClusterClaim{Name: "platform.open-cluster-management.io", Value: "Azure"},
+				clusterv1.ManagedClusterClaim{Name: "api.certmanager", Value: "false"}),
+			requirements: &Requirements{
+				Claims: []ClaimRequirement{
+					{Name: "platform.open-cluster-management.io", Values: []string{"AWS", "GCP"}},
+					{Name: "region"},
+				},
+				APIs: []string{"api.certmanager"},
+			},
+			expectedMessage: `claim platform.open-cluster-management.io is "Azure", expected one of AWS, GCP; ` +
+				"claim region is not found; api api.certmanager is not available",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			compatible, message := Check(c.cluster, c.requirements)
+			if compatible != c.expectedCompatible {
+				t.Errorf("expected compatible %v, but got %v", c.expectedCompatible, compatible)
+			}
+			if message != c.expectedMessage {
+				t.Errorf("expected message %q, but got %q", c.expectedMessage, message)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package addonconfiguration
 
 import (
 	"context"
+	"fmt"
 
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -23,6 +24,7 @@ func (d *managedClusterAddonConfigurationReconciler) reconcile(
 	ctx context.Context, cma *addonv1alpha1.ClusterManagementAddOn, graph *configurationGraph) (*addonv1alpha1.ClusterManagementAddOn, reconcileState, error) {
 	var errs []error
 	configured := sets.Set[string]{}
+	patched := sets.Set[string]{}
 
 	// Update the config references and set the "configured" condition to true for addons that are ready for rollout.
 	// These addons are part of the current rollout batch according to the strategy.
@@ -31,6 +33,7 @@ func (d *managedClusterAddonConfigurationReconciler) reconcile(
 		newAddon := d.mergeAddonConfig(addon.mca, addon.desiredConfigs)
 		// update mca configured condition to true
 		d.setCondition(newAddon, metav1.ConditionTrue, "ConfigurationsConfigured", "Configurations configured")
+		setConfigOverriddenCondition(newAddon, addon.overrideRule)
 
 		err := d.patchAddonStatus(ctx, newAddon, addon.mca)
 		if err != nil {
@@ -38,6 +41,7 @@ func (d *managedClusterAddonConfigurationReconciler) reconcile(
 		}
 
 		configured.Insert(addon.mca.Namespace)
+		patched.Insert(addon.mca.Namespace)
 	}
 
 	// Set the "configured" condition to false for addons whose configurations have not been synced yet
@@ -49,11 +53,13 @@ func (d *managedClusterAddonConfigurationReconciler) reconcile(
 		}
 		newAddon := addon.mca.DeepCopy()
 		d.setCondition(newAddon, metav1.ConditionFalse, "ConfigurationsNotConfigured", "Configurations updated and not configured yet")
+		setConfigOverriddenCondition(newAddon, addon.overrideRule)
 
 		err := d.patchAddonStatus(ctx, newAddon, addon.mca)
 		if err != nil {
 			errs = append(errs, err)
 		}
+		patched.Insert(addon.mca.Namespace)
 	}
 
 	// Set the "configured" condition to true for addons that have successfully completed rollout.
@@ -64,6 +70,22 @@ func (d *managedClusterAddonConfigurationReconciler) reconcile(
 	for _, addon := range graph.getAddonsSucceeded() {
 		newAddon := addon.mca.DeepCopy()
 		d.setCondition(newAddon, metav1.ConditionTrue, "ConfigurationsConfigured", "Configurations configured")
+		setConfigOverriddenCondition(newAddon, addon.overrideRule)
+
+		err := d.patchAddonStatus(ctx, newAddon, addon.mca)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		patched.Insert(addon.mca.Namespace)
+	}
+
+	// Report the override rule for the rest of addons, e.g. the addons which are progressing or failed.
+	for _, addon := range graph.getAddons() {
+		if patched.Has(addon.mca.Namespace) {
+			continue
+		}
+		newAddon := addon.mca.DeepCopy()
+		setConfigOverriddenCondition(newAddon, addon.overrideRule)
 
 		err := d.patchAddonStatus(ctx, newAddon, addon.mca)
 		if err != nil {
@@ -140,6 +162,21 @@ func (d *managedClusterAddonConfigurationReconciler) setCondition(
 	})
 }
 
+// setConfigOverriddenCondition reports the override rule of the cma which the configurations of the addon are
+// from, and removes the condition if no rule selects the cluster.
+func setConfigOverriddenCondition(addon *addonv1alpha1.ManagedClusterAddOn, rule *ConfigOverrideRule) {
+	if rule == nil {
+		meta.RemoveStatusCondition(&addon.Status.Conditions, ManagedClusterAddOnConditionConfigOverridden)
+		return
+	}
+	meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+		Type:    ManagedClusterAddOnConditionConfigOverridden,
+		Status:  metav1.ConditionTrue,
+		Reason:  OverrideRuleMatchedReason,
+		Message: fmt.Sprintf("Configurations are overridden by the rule %q of the ClusterManagementAddOn", rule.Name),
+	})
+}
+
 // patchAddonStatus patches the status of the addon
 func (d *managedClusterAddonConfigurationReconciler) patchAddonStatus(
 	ctx context.Context, newaddon *addonv1alpha1.ManagedClusterAddOn, oldaddon *addonv1alpha1.ManagedClusterAddOn) error {
@@ -3,15 +3,38 @@ package addonconfiguration
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	"open-cluster-management.io/sdk-go/pkg/patcher"
 )
 
+const (
+	// RollbackOnFailureAnnotationKey is the annotation key of the ClusterManagementAddOn to opt in the rollback of
+	// the install strategies. The value is a comma separated list of the placements of the install strategies in
+	// the format of <namespace>/<name>. Once the rollout of the desired configs of a listed install strategy breaches
+	// the MaxFailures of its rollout strategy, the failed and already upgraded clusters are rolled back to the last
+	// known good configs, until the desired configs are changed.
+	RollbackOnFailureAnnotationKey = "addon.open-cluster-management.io/rollback-on-failure"
+
+	// InstallProgressionConditionRolledBack is the condition type of the install progression indicating the desired
+	// configs are rolled back to the last known good configs.
+	InstallProgressionConditionRolledBack = "RolledBack"
+
+	// RolledBackReasonMaxFailuresBreached is the reason of the RolledBack condition when the rollout of the desired
+	// configs breaches the max failures.
+	RolledBackReasonMaxFailuresBreached = "MaxFailuresBreached"
+
+	// RolledBackReasonRollbackEnded is the reason of the RolledBack condition when the desired configs are changed
+	// or the rollback is disabled.
+	RolledBackReasonRollbackEnded = "RollbackEnded"
+)
+
 type cmaProgressingReconciler struct {
 	patcher patcher.Patcher[
 		*addonv1alpha1.ClusterManagementAddOn, addonv1alpha1.ClusterManagementAddOnSpec, addonv1alpha1.ClusterManagementAddOnStatus]
@@ -30,6 +53,15 @@ func (d *cmaProgressingReconciler) reconcile(
 			continue
 		}
 
+		if placementNode.rolledBack {
+			setAddOnInstallProgressionsRolledBack(&cmaCopy.Status.InstallProgressions[i],
+				placementNode.countAddonUpgrading(),
+				placementNode.countAddonUpgradeSucceed(),
+				len(placementNode.clusters),
+			)
+			continue
+		}
+
 		setAddOnInstallProgressionsAndLastApplied(&cmaCopy.Status.InstallProgressions[i],
 			placementNode.countAddonUpgrading(),
 			placementNode.countAddonUpgradeSucceed(),
@@ -67,4 +99,100 @@ func setAddOnInstallProgressionsAndLastApplied(
 		condition.Message = fmt.Sprintf("%d/%d completed with no errors, %d failed %d timeout.", done, total, failed, timeout)
 	}
 	meta.SetStatusCondition(&installProgression.Conditions, condition)
+
+	if meta.IsStatusConditionTrue(installProgression.Conditions, InstallProgressionConditionRolledBack) {
+		meta.SetStatusCondition(&installProgression.Conditions, metav1.Condition{
+			Type:    InstallProgressionConditionRolledBack,
+			Status:  metav1.ConditionFalse,
+			Reason:  RolledBackReasonRollbackEnded,
+			Message: "Rollback ended, the desired configs are rolled out.",
+		})
+	}
+}
+
+// setAddOnInstallProgressionsRolledBack sets the conditions of an install progression rolled back to the last known
+// good configs. The last applied and last known good configs are kept as the desired configs are never applied
+// successfully, and the desired configs rolled back from are recorded in the RolledBack condition.
+func setAddOnInstallProgressionsRolledBack(
+	installProgression *addonv1alpha1.InstallProgression,
+	progressing, done, total int) {
+
+	condition := metav1.Condition{
+		Type: addonv1alpha1.ManagedClusterAddOnConditionProgressing,
+	}
+	if done != total {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = addonv1alpha1.ProgressingReasonProgressing
+		condition.Message = fmt.Sprintf("%d/%d rolling back to the last known good configs...", progressing+done, total)
+	} else {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = addonv1alpha1.ProgressingReasonFailed
+		condition.Message = fmt.Sprintf("%d/%d rolled back to the last known good configs.", done, total)
+	}
+	meta.SetStatusCondition(&installProgression.Conditions, condition)
+
+	meta.SetStatusCondition(&installProgression.Conditions, metav1.Condition{
+		Type:    InstallProgressionConditionRolledBack,
+		Status:  metav1.ConditionTrue,
+		Reason:  RolledBackReasonMaxFailuresBreached,
+		Message: rolledBackMessage(installProgression.ConfigReferences),
+	})
+}
+
+// isRolledBack returns true if the install progression is rolled back from its current desired configs, which are
+// recorded in the message of the RolledBack condition by the rollback. The rollback ends once any of the desired
+// configs is changed.
+func isRolledBack(installProgression addonv1alpha1.InstallProgression) bool {
+	cond := meta.FindStatusCondition(installProgression.Conditions, InstallProgressionConditionRolledBack)
+	if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != RolledBackReasonMaxFailuresBreached {
+		return false
+	}
+	if cond.Message != rolledBackMessage(installProgression.ConfigReferences) {
+		return false
+	}
+
+	for _, configRef := range installProgression.ConfigReferences {
+		if configRef.DesiredConfig != nil && configRef.LastKnownGoodConfig != nil &&
+			*configRef.LastKnownGoodConfig != *configRef.DesiredConfig {
+			return true
+		}
+	}
+	return false
+}
+
+// rolledBackMessage returns the message of the RolledBack condition, which records the desired configs rolled
+// back from.
+func rolledBackMessage(configRefs []addonv1alpha1.InstallConfigReference) string {
+	var configs []string
+	for _, configRef := range configRefs {
+		if configRef.DesiredConfig == nil {
+			continue
+		}
+		name := configRef.DesiredConfig.Name
+		if len(configRef.DesiredConfig.Namespace) > 0 {
+			name = configRef.DesiredConfig.Namespace + "/" + name
+		}
+		configs = append(configs, fmt.Sprintf("%s.%s %s(%s)",
+			configRef.Resource, configRef.Group, name, configRef.DesiredConfig.SpecHash))
+	}
+	return fmt.Sprintf("Rolled back to the last known good configs, the max failures are breached by the desired configs %s.",
+		strings.Join(configs, ", "))
+}
+
+// rollbackOnFailurePlacements returns the placements of the install strategies opted in the rollback by the
+// RollbackOnFailureAnnotationKey annotation of the ClusterManagementAddOn.
+func rollbackOnFailurePlacements(cma *addonv1alpha1.ClusterManagementAddOn) sets.Set[addonv1alpha1.PlacementRef] {
+	placements := sets.New[addonv1alpha1.PlacementRef]()
+	value, ok := cma.Annotations[RollbackOnFailureAnnotationKey]
+	if !ok {
+		return placements
+	}
+	for _, item := range strings.Split(value, ",") {
+		namespace, name, found := strings.Cut(strings.TrimSpace(item), "/")
+		if !found || len(namespace) == 0 || len(name) == 0 {
+			continue
+		}
+		placements.Insert(addonv1alpha1.PlacementRef{Namespace: namespace, Name: name})
+	}
+	return placements
 }
@@ -7,8 +7,11 @@ import (
 	"time"
 
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 	clienttesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2/ktesting"
@@ -672,6 +675,179 @@ func TestMgmtAddonProgressingReconcile(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "rollback clustermanagementaddon after max failures breached",
+			managedClusteraddon: []runtime.Object{
+				newManagedClusterAddon("test", "cluster1", nil, []addonv1alpha1.ConfigReference{
+					{
+						ConfigGroupResource: addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"},
+						DesiredConfig: &addonv1alpha1.ConfigSpecHash{
+							ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test1"},
+							SpecHash:       "hash2",
+						},
+					},
+				}, []metav1.Condition{
+					{
+						Type:               addonv1alpha1.ManagedClusterAddOnConditionProgressing,
+						Reason:             addonv1alpha1.ProgressingReasonFailed,
+						LastTransitionTime: fakeTime,
+					},
+				}),
+				newManagedClusterAddon("test", "cluster2", nil, []addonv1alpha1.ConfigReference{
+					{
+						ConfigGroupResource: addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"},
+						DesiredConfig: &addonv1alpha1.ConfigSpecHash{
+							ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test1"},
+							SpecHash:       "hash1",
+						},
+						LastAppliedConfig: &addonv1alpha1.ConfigSpecHash{
+							ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test1"},
+							SpecHash:       "hash1",
+						},
+					},
+				}, []metav1.Condition{
+					{
+						Type:               addonv1alpha1.ManagedClusterAddOnConditionProgressing,
+						Reason:             addonv1alpha1.ProgressingReasonCompleted,
+						LastTransitionTime: fakeTime,
+					},
+				}),
+			},
+			clusterManagementAddon: []runtime.Object{func() *addonv1alpha1.ClusterManagementAddOn {
+				cma := addontesting.NewClusterManagementAddon("test", "", "").
+					WithPlacementStrategy(addonv1alpha1.PlacementStrategy{
+						PlacementRef: addonv1alpha1.PlacementRef{Name: "placement1", Namespace: "test"},
+						RolloutStrategy: clusterv1alpha1.RolloutStrategy{
+							Type: clusterv1alpha1.Progressive,
+							Progressive: &clusterv1alpha1.RolloutProgressive{
+								RolloutConfig:  clusterv1alpha1.RolloutConfig{ProgressDeadline: "1m"},
+								MaxConcurrency: intstr.FromInt(1),
+							},
+						},
+					}).WithInstallProgression(addonv1alpha1.InstallProgression{
+					PlacementRef: addonv1alpha1.PlacementRef{Name: "placement1", Namespace: "test"},
+					ConfigReferences: []addonv1alpha1.InstallConfigReference{
+						{
+							ConfigGroupResource: addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"},
+							DesiredConfig: &addonv1alpha1.ConfigSpecHash{
+								ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test1"},
+								SpecHash:       "hash2",
+							},
+							LastAppliedConfig: &addonv1alpha1.ConfigSpecHash{
+								ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test1"},
+								SpecHash:       "hash1",
+							},
+							LastKnownGoodConfig: &addonv1alpha1.ConfigSpecHash{
+								ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test1"},
+								SpecHash:       "hash1",
+							},
+						},
+					},
+				}).Build()
+				cma.Annotations = map[string]string{RollbackOnFailureAnnotationKey: "test/placement1"}
+				return cma
+			}()},
+			placements: []runtime.Object{
+				&clusterv1beta1.Placement{ObjectMeta: metav1.ObjectMeta{Name: "placement1", Namespace: "test"}},
+			},
+			placementDecisions: []runtime.Object{
+				&clusterv1beta1.PlacementDecision{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "placement1",
+						Namespace: "test",
+						Labels: map[string]string{
+							clusterv1beta1.PlacementLabel:          "placement1",
+							clusterv1beta1.DecisionGroupIndexLabel: "0",
+						},
+					},
+					Status: clusterv1beta1.PlacementDecisionStatus{
+						Decisions: []clusterv1beta1.ClusterDecision{{ClusterName: "cluster1"}, {ClusterName: "cluster2"}},
+					},
+				},
+			},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+				actual := actions[0].(clienttesting.PatchActionImpl).Patch
+				cma := &addonv1alpha1.ClusterManagementAddOn{}
+				err := json.Unmarshal(actual, cma)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				installProgression := cma.Status.InstallProgressions[0]
+				if installProgression.ConfigReferences[0].LastKnownGoodConfig.SpecHash != "hash1" {
+					t.Errorf("InstallProgressions LastKnownGoodConfig is not correct: %v", installProgression.ConfigReferences[0])
+				}
+				if installProgression.ConfigReferences[0].LastAppliedConfig.SpecHash != "hash1" {
+					t.Errorf("InstallProgressions LastAppliedConfig is not correct: %v", installProgression.ConfigReferences[0])
+				}
+				progressing := meta.FindStatusCondition(installProgression.Conditions, addonv1alpha1.ManagedClusterAddOnConditionProgressing)
+				if progressing == nil || progressing.Message != "2/2 rolling back to the last known good configs..." {
+					t.Errorf("InstallProgressions condition is not correct: %v", installProgression.Conditions)
+				}
+				rolledBack := meta.FindStatusCondition(installProgression.Conditions, InstallProgressionConditionRolledBack)
+				if rolledBack == nil || rolledBack.Status != metav1.ConditionTrue || rolledBack.Reason != RolledBackReasonMaxFailuresBreached {
+					t.Errorf("InstallProgressions condition is not correct: %v", installProgression.Conditions)
+				}
+				if rolledBack != nil && rolledBack.Message != "Rolled back to the last known good configs, "+
+					"the max failures are breached by the desired configs Foo.core test1(hash2)." {
+					t.Errorf("InstallProgressions condition is not correct: %v", rolledBack.Message)
+				}
+			},
+		},
+		{
+			name:                "end the rollback once the desired configs are changed",
+			managedClusteraddon: []runtime.Object{},
+			clusterManagementAddon: []runtime.Object{func() *addonv1alpha1.ClusterManagementAddOn {
+				cma := addontesting.NewClusterManagementAddon("test", "", "").
+					WithPlacementStrategy(addonv1alpha1.PlacementStrategy{
+						PlacementRef:    addonv1alpha1.PlacementRef{Name: "placement1", Namespace: "test"},
+						RolloutStrategy: clusterv1alpha1.RolloutStrategy{Type: clusterv1alpha1.All},
+					}).WithInstallProgression(addonv1alpha1.InstallProgression{
+					PlacementRef: addonv1alpha1.PlacementRef{Name: "placement1", Namespace: "test"},
+					ConfigReferences: []addonv1alpha1.InstallConfigReference{
+						{
+							ConfigGroupResource: addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"},
+							DesiredConfig: &addonv1alpha1.ConfigSpecHash{
+								ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test1"},
+								SpecHash:       "hash3",
+							},
+							LastKnownGoodConfig: &addonv1alpha1.ConfigSpecHash{
+								ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test1"},
+								SpecHash:       "hash1",
+							},
+						},
+					},
+					Conditions: []metav1.Condition{
+						{
+							Type:    InstallProgressionConditionRolledBack,
+							Status:  metav1.ConditionTrue,
+							Reason:  RolledBackReasonMaxFailuresBreached,
+							Message: "Rolled back to the last known good configs, the max failures are breached by the desired configs Foo.core test1(hash2).",
+						},
+					},
+				}).Build()
+				cma.Annotations = map[string]string{RollbackOnFailureAnnotationKey: "test/placement1"}
+				return cma
+			}()},
+			placements: []runtime.Object{
+				&clusterv1beta1.Placement{ObjectMeta: metav1.ObjectMeta{Name: "placement1", Namespace: "test"}},
+			},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+				actual := actions[0].(clienttesting.PatchActionImpl).Patch
+				cma := &addonv1alpha1.ClusterManagementAddOn{}
+				err := json.Unmarshal(actual, cma)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				rolledBack := meta.FindStatusCondition(cma.Status.InstallProgressions[0].Conditions, InstallProgressionConditionRolledBack)
+				if rolledBack == nil || rolledBack.Status != metav1.ConditionFalse || rolledBack.Reason != RolledBackReasonRollbackEnded {
+					t.Errorf("InstallProgressions condition is not correct: %v", cma.Status.InstallProgressions[0].Conditions)
+				}
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -754,3 +930,86 @@ func TestMgmtAddonProgressingReconcile(t *testing.T) {
 		})
 	}
 }
+
+func TestIsRolledBack(t *testing.T) {
+	newConfigSpecHash := func(hash string) *addonv1alpha1.ConfigSpecHash {
+		return &addonv1alpha1.ConfigSpecHash{ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test1"}, SpecHash: hash}
+	}
+	rolledBack := metav1.Condition{
+		Type:   InstallProgressionConditionRolledBack,
+		Status: metav1.ConditionTrue,
+		Reason: RolledBackReasonMaxFailuresBreached,
+	}
+	rollbackEnded := metav1.Condition{
+		Type:   InstallProgressionConditionRolledBack,
+		Status: metav1.ConditionFalse,
+		Reason: RolledBackReasonRollbackEnded,
+	}
+
+	cases := []struct {
+		name           string
+		condition      metav1.Condition
+		desired        string
+		rolledBackFrom string
+		expected       bool
+	}{
+		{
+			name:           "rolled back from the desired configs",
+			condition:      rolledBack,
+			desired:        "hash2",
+			rolledBackFrom: "hash2",
+			expected:       true,
+		},
+		{
+			name:           "desired configs changed",
+			condition:      rolledBack,
+			desired:        "hash3",
+			rolledBackFrom: "hash2",
+		},
+		{
+			name:           "desired configs changed back to the last known good configs",
+			condition:      rolledBack,
+			desired:        "hash1",
+			rolledBackFrom: "hash1",
+		},
+		{
+			name:           "rollback ended",
+			condition:      rollbackEnded,
+			desired:        "hash2",
+			rolledBackFrom: "hash2",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			configRef := addonv1alpha1.InstallConfigReference{
+				ConfigGroupResource: addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"},
+				DesiredConfig:       newConfigSpecHash(c.rolledBackFrom),
+				LastAppliedConfig:   newConfigSpecHash("hash1"),
+				LastKnownGoodConfig: newConfigSpecHash("hash1"),
+			}
+			c.condition.Message = rolledBackMessage([]addonv1alpha1.InstallConfigReference{configRef})
+			configRef.DesiredConfig = newConfigSpecHash(c.desired)
+			installProgression := addonv1alpha1.InstallProgression{
+				ConfigReferences: []addonv1alpha1.InstallConfigReference{configRef},
+				Conditions:       []metav1.Condition{c.condition},
+			}
+			if actual := isRolledBack(installProgression); actual != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRollbackOnFailurePlacements(t *testing.T) {
+	cma := addontesting.NewClusterManagementAddon("test", "", "").Build()
+	cma.Annotations = map[string]string{RollbackOnFailureAnnotationKey: "test/placement1, invalid,test/placement2"}
+
+	expected := sets.New(
+		addonv1alpha1.PlacementRef{Namespace: "test", Name: "placement1"},
+		addonv1alpha1.PlacementRef{Namespace: "test", Name: "placement2"},
+	)
+	if actual := rollbackOnFailurePlacements(cma); !actual.Equal(expected) {
+		t.Errorf("expected %v, but got %v", expected.UnsortedList(), actual.UnsortedList())
+	}
+}
@@ -0,0 +1,118 @@
+package addonconfiguration
+
+import (
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+	clusterlisterv1 "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
+)
+
+const (
+	// ConfigOverridesAnnotationKey is the annotation key of the ClusterManagementAddOn defining the ordered rules
+	// overriding the configs of the addons on the clusters selected by the label selector. The value is a json
+	// encoded list of ConfigOverrideRule, the first rule matching the cluster takes effect.
+	// The configs of the rule override the configs of the install strategy and the default configs with the same
+	// group and resource, and are overridden by the configs in the spec of the ManagedClusterAddOn.
+	ConfigOverridesAnnotationKey = "addon.open-cluster-management.io/config-overrides"
+
+	// ManagedClusterAddOnConditionConfigOverridden is set on the ManagedClusterAddOn whose configs are overridden
+	// by a rule of the ClusterManagementAddOn, the message shows the name of the rule.
+	ManagedClusterAddOnConditionConfigOverridden = "ConfigOverridden"
+	// OverrideRuleMatchedReason is the reason of the ConfigOverridden condition.
+	OverrideRuleMatchedReason = "OverrideRuleMatched"
+)
+
+// ConfigOverrideRule overrides the configs of the addons on the clusters selected by the ClusterSelector.
+type ConfigOverrideRule struct {
+	// Name is the unique name of the rule, which is reported in the ConfigOverridden condition of the addon.
+	Name            string                      `json:"name"`
+	ClusterSelector metav1.LabelSelector        `json:"clusterSelector"`
+	Configs         []addonv1alpha1.AddOnConfig `json:"configs"`
+}
+
+// configOverrides matches the clusters with the override rules of a ClusterManagementAddOn.
+type configOverrides struct {
+	rules         []ConfigOverrideRule
+	selectors     []labels.Selector
+	clusterLister clusterlisterv1.ManagedClusterLister
+}
+
+// newConfigOverrides returns the override rules defined by the ClusterManagementAddOn, nil if it is not defined.
+func newConfigOverrides(
+	cma *addonv1alpha1.ClusterManagementAddOn,
+	clusterLister clusterlisterv1.ManagedClusterLister,
+) (*configOverrides, error) {
+	value, ok := cma.Annotations[ConfigOverridesAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	overrides := &configOverrides{clusterLister: clusterLister}
+	if err := json.Unmarshal([]byte(value), &overrides.rules); err != nil {
+		return nil, fmt.Errorf("invalid annotation %s of addon %s: %v", ConfigOverridesAnnotationKey, cma.Name, err)
+	}
+
+	names := sets.New[string]()
+	for _, rule := range overrides.rules {
+		if len(rule.Name) == 0 || names.Has(rule.Name) {
+			return nil, fmt.Errorf("invalid annotation %s of addon %s: the rule name %q is empty or duplicated",
+				ConfigOverridesAnnotationKey, cma.Name, rule.Name)
+		}
+		names.Insert(rule.Name)
+		if len(rule.Configs) == 0 {
+			return nil, fmt.Errorf("invalid annotation %s of addon %s: no configs in rule %s",
+				ConfigOverridesAnnotationKey, cma.Name, rule.Name)
+		}
+		selector, err := metav1.LabelSelectorAsSelector(&rule.ClusterSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation %s of addon %s: invalid cluster selector of rule %s: %v",
+				ConfigOverridesAnnotationKey, cma.Name, rule.Name, err)
+		}
+		overrides.selectors = append(overrides.selectors, selector)
+	}
+	return overrides, nil
+}
+
+// match returns the first rule selecting the cluster, nil if no rule selects the cluster.
+func (o *configOverrides) match(clusterName string) *ConfigOverrideRule {
+	if o == nil || len(o.rules) == 0 {
+		return nil
+	}
+	cluster, err := o.clusterLister.Get(clusterName)
+	if err != nil {
+		// the cluster is deleted or not synced yet, the addon is re-evaluated when the cluster is added
+		return nil
+	}
+	for i := range o.rules {
+		if o.selectors[i].Matches(labels.Set(cluster.Labels)) {
+			return &o.rules[i]
+		}
+	}
+	return nil
+}
+
+// clusterManagementAddonByConfigOverridesQueueKey returns the ClusterManagementAddOns with the override rules, so
+// the rules are re-evaluated when the labels of the cluster are changed.
+func clusterManagementAddonByConfigOverridesQueueKey(
+	cmaLister addonlisterv1alpha1.ClusterManagementAddOnLister) func() []string {
+	return func() []string {
+		cmas, err := cmaLister.List(labels.Everything())
+		if err != nil {
+			return nil
+		}
+
+		var keys []string
+		for _, cma := range cmas {
+			if _, ok := cma.Annotations[ConfigOverridesAnnotationKey]; ok {
+				keys = append(keys, cma.Name)
+			}
+		}
+		return keys
+	}
+}
@@ -0,0 +1,283 @@
+package addonconfiguration
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clienttesting "k8s.io/client-go/testing"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	fakecluster "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterv1informers "open-cluster-management.io/api/client/cluster/informers/externalversions"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	clusterv1alpha1 "open-cluster-management.io/api/cluster/v1alpha1"
+	clusterv1beta1 "open-cluster-management.io/api/cluster/v1beta1"
+
+	"open-cluster-management.io/ocm/pkg/common/helpers"
+)
+
+const testConfigOverrides = `[
+{"name":"prod","clusterSelector":{"matchLabels":{"env":"prod"}},
+ "configs":[{"group":"core","resource":"Foo","name":"prod-config"}]},
+{"name":"non-dev","clusterSelector":{"matchExpressions":[{"key":"env","operator":"In","values":["prod","staging"]}]},
+ "configs":[{"group":"core","resource":"Foo","name":"staging-config"}]}
+]`
+
+func TestNewConfigOverrides(t *testing.T) {
+	cases := []struct {
+		name          string
+		overrides     string
+		expectedRules []string
+		expectedErr   bool
+	}{
+		{
+			name:          "valid rules",
+			overrides:     testConfigOverrides,
+			expectedRules: []string{"prod", "non-dev"},
+		},
+		{
+			name:        "invalid json",
+			overrides:   `{`,
+			expectedErr: true,
+		},
+		{
+			name: "duplicated rule name",
+			overrides: `[{"name":"a","configs":[{"group":"core","resource":"Foo","name":"a"}]},
+{"name":"a","configs":[{"group":"core","resource":"Foo","name":"b"}]}]`,
+			expectedErr: true,
+		},
+		{
+			name: "invalid selector",
+			overrides: `[{"name":"a","clusterSelector":{"matchExpressions":[{"key":"env","operator":"Unknown"}]},
+"configs":[{"group":"core","resource":"Foo","name":"a"}]}]`,
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cma := newClusterManagementAddonWithConfigOverrides("test", c.overrides)
+			overrides, err := newConfigOverrides(cma, nil)
+			if c.expectedErr != (err != nil) {
+				t.Fatalf("expected error %v, but got %v", c.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+			var rules []string
+			for _, rule := range overrides.rules {
+				rules = append(rules, rule.Name)
+			}
+			if len(rules) != len(c.expectedRules) || len(overrides.selectors) != len(c.expectedRules) {
+				t.Errorf("expected rules %v, but got %v", c.expectedRules, rules)
+			}
+		})
+	}
+}
+
+func TestConfigurationGraphOverrides(t *testing.T) {
+	fooGR := addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Foo"}
+	newCluster := func(name, env string) *clusterv1.ManagedCluster {
+		cluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if len(env) > 0 {
+			cluster.Labels = map[string]string{"env": env}
+		}
+		return cluster
+	}
+
+	fakeClusterClient := fakecluster.NewSimpleClientset()
+	clusterInformers := clusterv1informers.NewSharedInformerFactory(fakeClusterClient, 10*time.Minute)
+	for _, cluster := range []*clusterv1.ManagedCluster{
+		newCluster("cluster1", "prod"),
+		newCluster("cluster2", "staging"),
+		newCluster("cluster3", ""),
+		newCluster("cluster4", "prod"),
+		newCluster("cluster5", "prod"),
+	} {
+		if err := clusterInformers.Cluster().V1().ManagedClusters().Informer().GetStore().Add(cluster); err != nil {
+			t.Fatal(err)
+		}
+	}
+	placementRef := addonv1alpha1.PlacementRef{Name: "placement1", Namespace: "test"}
+	if err := clusterInformers.Cluster().V1beta1().Placements().Informer().GetStore().Add(&clusterv1beta1.Placement{
+		ObjectMeta: metav1.ObjectMeta{Name: placementRef.Name, Namespace: placementRef.Namespace}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := clusterInformers.Cluster().V1beta1().PlacementDecisions().Informer().GetStore().Add(&clusterv1beta1.PlacementDecision{
+		ObjectMeta: metav1.ObjectMeta{Name: placementRef.Name, Namespace: placementRef.Namespace,
+			Labels: map[string]string{
+				clusterv1beta1.PlacementLabel:          placementRef.Name,
+				clusterv1beta1.DecisionGroupIndexLabel: "0",
+			}},
+		Status: clusterv1beta1.PlacementDecisionStatus{Decisions: []clusterv1beta1.ClusterDecision{
+			{ClusterName: "cluster1"}, {ClusterName: "cluster2"}, {ClusterName: "cluster3"}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	overrides, err := newConfigOverrides(newClusterManagementAddonWithConfigOverrides("test", testConfigOverrides),
+		clusterInformers.Cluster().V1().ManagedClusters().Lister())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graph := newGraph([]addonv1alpha1.ConfigMeta{
+		{ConfigGroupResource: fooGR, DefaultConfig: &addonv1alpha1.ConfigReferent{Name: "default-config"}},
+	}, nil)
+	graph.setConfigOverrides(overrides)
+	for _, addon := range []*addonv1alpha1.ManagedClusterAddOn{
+		newManagedClusterAddon("test", "cluster1", nil, []addonv1alpha1.ConfigReference{{
+			ConfigGroupResource: fooGR,
+			DesiredConfig: &addonv1alpha1.ConfigSpecHash{
+				ConfigReferent: addonv1alpha1.ConfigReferent{Name: "prod-config"},
+				SpecHash:       "<prod-hash>",
+			},
+		}}, nil),
+		newManagedClusterAddon("test", "cluster2", nil, nil, nil),
+		newManagedClusterAddon("test", "cluster3", nil, nil, nil),
+		newManagedClusterAddon("test", "cluster4", nil, nil, nil),
+		newManagedClusterAddon("test", "cluster5", []addonv1alpha1.AddOnConfig{{
+			ConfigGroupResource: fooGR,
+			ConfigReferent:      addonv1alpha1.ConfigReferent{Name: "cluster5-config"},
+		}}, nil, nil),
+	} {
+		graph.addAddonNode(addon)
+	}
+
+	installProgression := addonv1alpha1.InstallProgression{
+		PlacementRef: placementRef,
+		ConfigReferences: []addonv1alpha1.InstallConfigReference{
+			newInstallConfigReference("core", "Foo", "placement-config", "<placement-hash>"),
+		},
+	}
+	if err := graph.addPlacementNode(addonv1alpha1.PlacementStrategy{
+		PlacementRef:    placementRef,
+		RolloutStrategy: clusterv1alpha1.RolloutStrategy{Type: clusterv1alpha1.All},
+	}, installProgression,
+		clusterInformers.Cluster().V1beta1().Placements().Lister(),
+		helpers.PlacementDecisionGetter{Client: clusterInformers.Cluster().V1beta1().PlacementDecisions().Lister()},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := graph.generateRolloutResult(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]struct {
+		config string
+		hash   string
+		rule   string
+	}{
+		"cluster1": {config: "prod-config", hash: "<prod-hash>", rule: "prod"},
+		"cluster2": {config: "staging-config", rule: "non-dev"},
+		"cluster3": {config: "placement-config", hash: "<placement-hash>"},
+		"cluster4": {config: "prod-config", rule: "prod"},
+		"cluster5": {config: "cluster5-config", rule: "prod"},
+	}
+	addons := graph.getAddons()
+	if len(addons) != len(expected) {
+		t.Fatalf("expected %d addons, but got %d", len(expected), len(addons))
+	}
+	for _, addon := range addons {
+		e := expected[addon.mca.Namespace]
+		configs := addon.desiredConfigs[fooGR]
+		if len(configs) != 1 || configs[0].DesiredConfig.Name != e.config || configs[0].DesiredConfig.SpecHash != e.hash {
+			t.Errorf("expected config %s with hash %q on %s, but got %v", e.config, e.hash, addon.mca.Namespace, configs)
+		}
+		var rule string
+		if addon.overrideRule != nil {
+			rule = addon.overrideRule.Name
+		}
+		if rule != e.rule {
+			t.Errorf("expected rule %q on %s, but got %q", e.rule, addon.mca.Namespace, rule)
+		}
+	}
+}
+
+func TestSetConfigOverriddenCondition(t *testing.T) {
+	overridden := metav1.Condition{
+		Type:    ManagedClusterAddOnConditionConfigOverridden,
+		Status:  metav1.ConditionTrue,
+		Reason:  OverrideRuleMatchedReason,
+		Message: `Configurations are overridden by the rule "old" of the ClusterManagementAddOn`,
+	}
+	cases := []struct {
+		name            string
+		conditions      []metav1.Condition
+		rule            *ConfigOverrideRule
+		expectedMessage string
+	}{
+		{
+			name:            "rule matched",
+			rule:            &ConfigOverrideRule{Name: "prod"},
+			expectedMessage: `Configurations are overridden by the rule "prod" of the ClusterManagementAddOn`,
+		},
+		{
+			name:       "rule no longer matched",
+			conditions: []metav1.Condition{overridden},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addon := newManagedClusterAddon("test", "cluster1", nil, nil, c.conditions)
+			fakeAddonClient := fakeaddon.NewSimpleClientset(addon)
+			graph := newGraph(nil, nil)
+			graph.addAddonNode(addon)
+			graph.defaults.children["cluster1"].overrideRule = c.rule
+			if err := graph.generateRolloutResult(); err != nil {
+				t.Fatal(err)
+			}
+
+			reconciler := &managedClusterAddonConfigurationReconciler{addonClient: fakeAddonClient}
+			if _, _, err := reconciler.reconcile(context.TODO(), nil, graph); err != nil {
+				t.Fatal(err)
+			}
+
+			var patched *addonv1alpha1.ManagedClusterAddOn
+			for _, action := range fakeAddonClient.Actions() {
+				if action.GetVerb() != "patch" {
+					continue
+				}
+				patched = &addonv1alpha1.ManagedClusterAddOn{}
+				if err := json.Unmarshal(action.(clienttesting.PatchActionImpl).Patch, patched); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if patched == nil {
+				t.Fatal("expected the addon status to be patched")
+			}
+			cond := meta.FindStatusCondition(patched.Status.Conditions, ManagedClusterAddOnConditionConfigOverridden)
+			switch {
+			case len(c.expectedMessage) == 0 && cond != nil:
+				t.Errorf("expected no condition, but got %v", cond)
+			case len(c.expectedMessage) > 0 && (cond == nil || cond.Message != c.expectedMessage):
+				t.Errorf("expected condition message %q, but got %v", c.expectedMessage, cond)
+			}
+		})
+	}
+}
+
+func TestClusterManagementAddonByConfigOverridesQueueKey(t *testing.T) {
+	addonInformers := addoninformers.NewSharedInformerFactory(fakeaddon.NewSimpleClientset(), 10*time.Minute)
+	store := addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().GetStore()
+	if err := store.Add(newClusterManagementAddonWithConfigOverrides("with-overrides", testConfigOverrides)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add(addontesting.NewClusterManagementAddon("without-overrides", "", "").Build()); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := clusterManagementAddonByConfigOverridesQueueKey(
+		addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Lister())()
+	if len(keys) != 1 || keys[0] != "with-overrides" {
+		t.Errorf("expected keys [with-overrides], but got %v", keys)
+	}
+}
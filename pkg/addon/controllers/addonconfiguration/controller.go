@@ -3,10 +3,13 @@ package addonconfiguration
 import (
 	"context"
 	"errors"
+	"fmt"
+	"reflect"
 	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 
@@ -14,8 +17,11 @@ import (
 	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
 	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
 	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+	clusterinformersv1 "open-cluster-management.io/api/client/cluster/informers/externalversions/cluster/v1"
 	clusterinformersv1beta1 "open-cluster-management.io/api/client/cluster/informers/externalversions/cluster/v1beta1"
+	clusterlisterv1 "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
 	clusterlisterv1beta1 "open-cluster-management.io/api/client/cluster/listers/cluster/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
 	"open-cluster-management.io/sdk-go/pkg/patcher"
 
@@ -31,8 +37,9 @@ const (
 
 // addonConfigurationController is a controller to update configuration of mca with the following order
 // 1. use configuration in mca spec if it is set
-// 2. use configuration in install strategy
-// 3. use configuration in the default configuration in cma
+// 2. use configuration in the first override rule of cma selecting the cluster
+// 3. use configuration in install strategy
+// 4. use configuration in the default configuration in cma
 type addonConfigurationController struct {
 	addonClient                  addonv1alpha1client.Interface
 	clusterManagementAddonLister addonlisterv1alpha1.ClusterManagementAddOnLister
@@ -40,6 +47,7 @@ type addonConfigurationController struct {
 	addonFilterFunc              factory.EventFilterFunc
 	placementLister              clusterlisterv1beta1.PlacementLister
 	placementDecisionGetter      helpers.PlacementDecisionGetter
+	clusterLister                clusterlisterv1.ManagedClusterLister
 
 	reconcilers []addonConfigurationReconcile
 }
@@ -62,14 +70,19 @@ func NewAddonConfigurationController(
 	clusterManagementAddonInformers addoninformerv1alpha1.ClusterManagementAddOnInformer,
 	placementInformer clusterinformersv1beta1.PlacementInformer,
 	placementDecisionInformer clusterinformersv1beta1.PlacementDecisionInformer,
+	clusterInformer clusterinformersv1.ManagedClusterInformer,
 	addonFilterFunc factory.EventFilterFunc,
 ) factory.Controller {
+	controllerName := "addon-configuration-controller"
+	syncCtx := factory.NewSyncContext(controllerName)
+
 	c := &addonConfigurationController{
 		addonClient:                  addonClient,
 		clusterManagementAddonLister: clusterManagementAddonInformers.Lister(),
 		managedClusterAddonIndexer:   addonInformers.Informer().GetIndexer(),
 		placementLister:              placementInformer.Lister(),
 		placementDecisionGetter:      helpers.PlacementDecisionGetter{Client: placementDecisionInformer.Lister()},
+		clusterLister:                clusterInformer.Lister(),
 		addonFilterFunc:              addonFilterFunc,
 	}
 
@@ -84,7 +97,39 @@ func NewAddonConfigurationController(
 		},
 	}
 
-	controllerFactory := factory.New().WithFilteredEventsInformersQueueKeysFunc(
+	// re-evaluate the override rules only when a cluster is added or its labels are changed, instead of on every
+	// status update of the clusters.
+	queueKeys := clusterManagementAddonByConfigOverridesQueueKey(clusterManagementAddonInformers.Lister())
+	_, err := clusterInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			for _, key := range queueKeys() {
+				syncCtx.Queue().Add(key)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldCluster, ok := oldObj.(*clusterv1.ManagedCluster)
+			if !ok {
+				utilruntime.HandleError(fmt.Errorf("error to get old ManagedCluster object: %v", oldObj))
+				return
+			}
+			newCluster, ok := newObj.(*clusterv1.ManagedCluster)
+			if !ok {
+				utilruntime.HandleError(fmt.Errorf("error to get new ManagedCluster object: %v", newObj))
+				return
+			}
+			if reflect.DeepEqual(oldCluster.Labels, newCluster.Labels) {
+				return
+			}
+			for _, key := range queueKeys() {
+				syncCtx.Queue().Add(key)
+			}
+		},
+	})
+	if err != nil {
+		utilruntime.HandleError(err)
+	}
+
+	controllerFactory := factory.New().WithSyncContext(syncCtx).WithFilteredEventsInformersQueueKeysFunc(
 		queue.QueueKeyByMetaNamespaceName,
 		c.addonFilterFunc,
 		clusterManagementAddonInformers.Informer()).
@@ -92,9 +137,10 @@ func NewAddonConfigurationController(
 		WithInformersQueueKeysFunc(
 			addonindex.ClusterManagementAddonByPlacementDecisionQueueKey(clusterManagementAddonInformers), placementDecisionInformer.Informer()).
 		WithInformersQueueKeysFunc(
-			addonindex.ClusterManagementAddonByPlacementQueueKey(clusterManagementAddonInformers), placementInformer.Informer())
+			addonindex.ClusterManagementAddonByPlacementQueueKey(clusterManagementAddonInformers), placementInformer.Informer()).
+		WithBareInformers(clusterInformer.Informer())
 
-	return controllerFactory.WithSync(c.sync).ToController("addon-configuration-controller")
+	return controllerFactory.WithSync(c.sync).ToController(controllerName)
 }
 
 func (c *addonConfigurationController) sync(ctx context.Context, syncCtx factory.SyncContext, addonName string) error {
@@ -157,6 +203,12 @@ func (c *addonConfigurationController) sync(ctx context.Context, syncCtx factory
 
 func (c *addonConfigurationController) buildConfigurationGraph(logger klog.Logger, cma *addonv1alpha1.ClusterManagementAddOn) (*configurationGraph, error) {
 	graph := newGraph(cma.Spec.SupportedConfigs, cma.Status.DefaultConfigReferences)
+	graph.rollbackPlacements = rollbackOnFailurePlacements(cma)
+	overrides, err := newConfigOverrides(cma, c.clusterLister)
+	if err != nil {
+		return graph, err
+	}
+	graph.setConfigOverrides(overrides)
 	addons, err := c.managedClusterAddonIndexer.ByIndex(addonindex.ManagedClusterAddonByName, cma.Name)
 	if err != nil {
 		return graph, err
@@ -51,6 +51,7 @@ func TestNewAddonConfigurationController(t *testing.T) {
 		addonInformers.Addon().V1alpha1().ClusterManagementAddOns(),
 		clusterInformers.Cluster().V1beta1().Placements(),
 		clusterInformers.Cluster().V1beta1().PlacementDecisions(),
+		clusterInformers.Cluster().V1().ManagedClusters(),
 		addonFilterFunc,
 	)
 
@@ -165,6 +166,20 @@ func TestAddonConfigurationControllerSync(t *testing.T) {
 			addonFilterFuncResult: true,
 			expectError:           false,
 		},
+		{
+			name:     "invalid config overrides",
+			queueKey: "test-addon",
+			managedClusterAddons: []runtime.Object{
+				addontesting.NewAddon("test-addon", "cluster1"),
+			},
+			clusterManagementAddons: []runtime.Object{
+				newClusterManagementAddonWithConfigOverrides("test-addon", `[{"name":"rule1"}]`),
+			},
+			addonFilterFuncResult: true,
+			expectError:           true,
+			expectedErrorMessage: "invalid annotation addon.open-cluster-management.io/config-overrides of addon test-addon: " +
+				"no configs in rule rule1",
+		},
 	}
 
 	for _, c := range cases {
@@ -225,6 +240,7 @@ func TestAddonConfigurationControllerSync(t *testing.T) {
 				placementDecisionGetter: helpers.PlacementDecisionGetter{
 					Client: clusterInformers.Cluster().V1beta1().PlacementDecisions().Lister(),
 				},
+				clusterLister:   clusterInformers.Cluster().V1().ManagedClusters().Lister(),
 				addonFilterFunc: addonFilterFunc,
 				reconcilers: []addonConfigurationReconcile{
 					&managedClusterAddonConfigurationReconciler{
@@ -252,3 +268,9 @@ func TestAddonConfigurationControllerSync(t *testing.T) {
 		})
 	}
 }
+
+func newClusterManagementAddonWithConfigOverrides(name, overrides string) *addonv1alpha1.ClusterManagementAddOn {
+	cma := addontesting.NewClusterManagementAddon(name, "", "").Build()
+	cma.Annotations = map[string]string{ConfigOverridesAnnotationKey: overrides}
+	return cma
+}
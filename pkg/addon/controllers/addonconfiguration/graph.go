@@ -26,6 +26,14 @@ type configurationGraph struct {
 	nodes []*installStrategyNode
 	// defaults is the nodes with no install strategy
 	defaults *installStrategyNode
+	// rollbackPlacements is the placements of the install strategies rolling back to the last known good
+	// configs once the rollout breaches the max failures
+	rollbackPlacements sets.Set[addonv1alpha1.PlacementRef]
+}
+
+// setConfigOverrides sets the override rules applied to the addons added to the graph afterwards.
+func (g *configurationGraph) setConfigOverrides(overrides *configOverrides) {
+	g.defaults.overrides = overrides
 }
 
 // installStrategyNode is a node in configurationGraph defined by a install strategy
@@ -38,6 +46,12 @@ type installStrategyNode struct {
 	// children keeps a map of addons node as the children of this node
 	children map[string]*addonNode
 	clusters sets.Set[string]
+	// lastKnownGoodConfigs is the configuration to roll back to, nil if rollback is not possible
+	lastKnownGoodConfigs addonConfigMap
+	// rolledBack indicates the desiredConfigs is re-targeted to the lastKnownGoodConfigs
+	rolledBack bool
+	// overrides are the rules overriding the desiredConfigs of the addons on the selected clusters
+	overrides *configOverrides
 }
 
 // addonNode is node as a child of installStrategy node represting a mca
@@ -46,6 +60,8 @@ type addonNode struct {
 	desiredConfigs addonConfigMap
 	mca            *addonv1alpha1.ManagedClusterAddOn
 	status         *clustersdkv1alpha1.ClusterRolloutStatus
+	// overrideRule is the rule overriding the desiredConfigs of the addon, nil if no rule selects the cluster
+	overrideRule *ConfigOverrideRule
 }
 
 type addonConfigMap map[addonv1alpha1.ConfigGroupResource][]addonv1alpha1.ConfigReference
@@ -240,6 +256,7 @@ func (g *configurationGraph) addPlacementNode(
 		desiredConfigs:  g.defaults.desiredConfigs,
 		children:        map[string]*addonNode{},
 		clusters:        clusters,
+		overrides:       g.defaults.overrides,
 	}
 
 	// Set MaxConcurrency
@@ -263,6 +280,15 @@ func (g *configurationGraph) addPlacementNode(
 		overrideConfigMapByInstallConfigRef(node.desiredConfigs, installConfigReference)
 	}
 
+	// keep the last known good configs if rollback is enabled, and continue the rollback if the
+	// desired configs are the ones rolled back from
+	if g.rollbackPlacements.Has(placementRef) {
+		node.lastKnownGoodConfigs = lastKnownGoodConfigMap(g.defaults.desiredConfigs, installConfigReference)
+		if node.lastKnownGoodConfigs != nil && isRolledBack(installProgression) {
+			node.retargetToLastKnownGood()
+		}
+	}
+
 	// remove addon in defaults and other placements.
 	for _, cluster := range node.clusters.UnsortedList() {
 		if _, ok := g.defaults.children[cluster]; ok {
@@ -344,6 +370,20 @@ func (g *configurationGraph) getAddonsSucceeded() []*addonNode {
 	return addons
 }
 
+// getAddons returns all the addons in the graph.
+func (g *configurationGraph) getAddons() []*addonNode {
+	var addons []*addonNode
+	for _, node := range g.nodes {
+		for _, addon := range node.children {
+			addons = append(addons, addon)
+		}
+	}
+	for _, addon := range g.defaults.children {
+		addons = append(addons, addon)
+	}
+	return addons
+}
+
 func (g *configurationGraph) getRequeueTime() time.Duration {
 	minRequeue := maxRequeueTime
 
@@ -361,16 +401,30 @@ func (n *installStrategyNode) addNode(addon *addonv1alpha1.ManagedClusterAddOn)
 	n.children[addon.Namespace] = &addonNode{
 		mca:            addon,
 		desiredConfigs: n.desiredConfigs,
+		overrideRule:   n.overrides.match(addon.Namespace),
+	}
+
+	var overrideConfigs []addonv1alpha1.AddOnConfig
+	// override configuration by the override rule of cma
+	if rule := n.children[addon.Namespace].overrideRule; rule != nil {
+		n.children[addon.Namespace].desiredConfigs = n.children[addon.Namespace].desiredConfigs.copy()
+		overrideConfigMapByAddOnConfigs(n.children[addon.Namespace].desiredConfigs, rule.Configs)
+		overrideConfigs = append(overrideConfigs, rule.Configs...)
 	}
 
 	// override configuration by mca spec
 	if len(addon.Spec.Configs) > 0 {
-		n.children[addon.Namespace].desiredConfigs = n.children[addon.Namespace].desiredConfigs.copy()
+		if len(overrideConfigs) == 0 {
+			n.children[addon.Namespace].desiredConfigs = n.children[addon.Namespace].desiredConfigs.copy()
+		}
 		// TODO we should also filter out the configs which are not supported configs.
 		overrideConfigMapByAddOnConfigs(n.children[addon.Namespace].desiredConfigs, addon.Spec.Configs)
+		overrideConfigs = append(overrideConfigs, addon.Spec.Configs...)
+	}
 
-		//	go through mca spec configs and copy the specHash from status if they match
-		for _, config := range addon.Spec.Configs {
+	if len(overrideConfigs) > 0 {
+		//	go through the override rule and mca spec configs and copy the specHash from status if they match
+		for _, config := range overrideConfigs {
 			for _, configRef := range addon.Status.ConfigReferences {
 				if configRef.DesiredConfig == nil {
 					continue
@@ -438,11 +492,35 @@ func (n *installStrategyNode) generateRolloutResult() error {
 			return err
 		}
 		n.rolloutResult = rolloutResult
+
+		// the rollout is cut short by failed clusters, roll back to the last known good configs
+		if rolloutResult.MaxFailureBreach && n.canRollback() {
+			n.retargetToLastKnownGood()
+			for _, addon := range n.children {
+				n.addNode(addon.mca)
+			}
+			return n.generateRolloutResult()
+		}
 	}
 
 	return nil
 }
 
+// canRollback returns true if the node is not rolled back yet and has last known good configs
+// different from the desired configs.
+func (n *installStrategyNode) canRollback() bool {
+	return !n.rolledBack && n.lastKnownGoodConfigs != nil &&
+		!equality.Semantic.DeepEqual(n.lastKnownGoodConfigs, n.desiredConfigs)
+}
+
+// retargetToLastKnownGood sets the desired configs of the node to the last known good configs. The failed
+// and already upgraded clusters are rolled back at once regardless of the original rollout strategy.
+func (n *installStrategyNode) retargetToLastKnownGood() {
+	n.rolledBack = true
+	n.desiredConfigs = n.lastKnownGoodConfigs
+	n.rolloutStrategy = clusterv1alpha1.RolloutStrategy{Type: clusterv1alpha1.All}
+}
+
 // addonToUpdate finds the addons to be updated by placement
 func (n *installStrategyNode) getAddonsToUpdate() []*addonNode {
 	var addons []*addonNode
@@ -568,6 +646,32 @@ func rolloutStatusHasCluster(clusterRolloutStatus []clustersdkv1alpha1.ClusterRo
 	return false
 }
 
+// lastKnownGoodConfigMap returns the addonConfigMap built from the last known good configs of a slice of
+// InstallConfigReference (from cma status), nil if any of the configs has no last known good config.
+func lastKnownGoodConfigMap(
+	defaultConfigs addonConfigMap,
+	installConfigRefs []addonv1alpha1.InstallConfigReference,
+) addonConfigMap {
+	if len(installConfigRefs) == 0 {
+		return nil
+	}
+
+	lastKnownGoodConfigRefs := []addonv1alpha1.InstallConfigReference{}
+	for _, configRef := range installConfigRefs {
+		if configRef.LastKnownGoodConfig == nil {
+			return nil
+		}
+		lastKnownGoodConfigRefs = append(lastKnownGoodConfigRefs, addonv1alpha1.InstallConfigReference{
+			ConfigGroupResource: configRef.ConfigGroupResource,
+			DesiredConfig:       configRef.LastKnownGoodConfig,
+		})
+	}
+
+	configs := defaultConfigs.copy()
+	overrideConfigMapByInstallConfigRef(configs, lastKnownGoodConfigRefs)
+	return configs
+}
+
 // Override the desired addonConfigMap by a slice of InstallConfigReference (from cma status),
 func overrideConfigMapByInstallConfigRef(
 	desiredConfigs addonConfigMap,
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
 	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
@@ -844,6 +846,182 @@ func TestConfigurationGraph(t *testing.T) {
 	}
 }
 
+func TestConfigurationGraphRollback(t *testing.T) {
+	barGR := addonv1alpha1.ConfigGroupResource{Group: "core", Resource: "Bar"}
+	newConfigReference := func(hash string, applied bool) []addonv1alpha1.ConfigReference {
+		configRef := addonv1alpha1.ConfigReference{
+			ConfigGroupResource: barGR,
+			ConfigReferent:      addonv1alpha1.ConfigReferent{Name: "test1"},
+			DesiredConfig: &addonv1alpha1.ConfigSpecHash{
+				ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test1"},
+				SpecHash:       hash,
+			},
+		}
+		if applied {
+			configRef.LastAppliedConfig = configRef.DesiredConfig.DeepCopy()
+		}
+		return []addonv1alpha1.ConfigReference{configRef}
+	}
+	newProgressingCondition := func(reason string) []metav1.Condition {
+		return []metav1.Condition{{
+			Type:               addonv1alpha1.ManagedClusterAddOnConditionProgressing,
+			Reason:             reason,
+			LastTransitionTime: fakeTime,
+		}}
+	}
+	addons := []*addonv1alpha1.ManagedClusterAddOn{
+		// failed on the new config
+		newManagedClusterAddon("test", "cluster1", nil, newConfigReference("<new-hash>", false),
+			newProgressingCondition(addonv1alpha1.ProgressingReasonFailed)),
+		// upgraded to the new config
+		newManagedClusterAddon("test", "cluster2", nil, newConfigReference("<new-hash>", true),
+			newProgressingCondition(addonv1alpha1.ProgressingReasonCompleted)),
+		// not upgraded yet
+		newManagedClusterAddon("test", "cluster3", nil, newConfigReference("<good-hash>", true),
+			newProgressingCondition(addonv1alpha1.ProgressingReasonCompleted)),
+	}
+	placementRef := addonv1alpha1.PlacementRef{Name: "placement1", Namespace: "test"}
+	placementStrategy := addonv1alpha1.PlacementStrategy{
+		PlacementRef: placementRef,
+		RolloutStrategy: clusterv1alpha1.RolloutStrategy{
+			Type: clusterv1alpha1.Progressive,
+			Progressive: &clusterv1alpha1.RolloutProgressive{
+				RolloutConfig:  clusterv1alpha1.RolloutConfig{ProgressDeadline: "1m"},
+				MaxConcurrency: intstr.FromInt(1),
+			},
+		},
+	}
+	installConfigReference := newInstallConfigReference("core", "Bar", "test1", "<new-hash>")
+	installConfigReference.LastKnownGoodConfig = &addonv1alpha1.ConfigSpecHash{
+		ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test1"},
+		SpecHash:       "<good-hash>",
+	}
+	rolledBackCondition := metav1.Condition{
+		Type:    InstallProgressionConditionRolledBack,
+		Status:  metav1.ConditionTrue,
+		Reason:  RolledBackReasonMaxFailuresBreached,
+		Message: rolledBackMessage([]addonv1alpha1.InstallConfigReference{installConfigReference}),
+	}
+
+	cases := []struct {
+		name               string
+		rollbackPlacements sets.Set[addonv1alpha1.PlacementRef]
+		conditions         []metav1.Condition
+		lastApplied        string
+		lastKnownGood      bool
+		expectedRolledBack bool
+		expectedToUpdate   []string
+		expectedHash       string
+	}{
+		{
+			name:          "rollback is not enabled",
+			lastKnownGood: true,
+			expectedHash:  "<new-hash>",
+		},
+		{
+			name:               "rollback is enabled for other placements",
+			rollbackPlacements: sets.New(addonv1alpha1.PlacementRef{Name: "placement2", Namespace: "test"}),
+			lastKnownGood:      true,
+			expectedHash:       "<new-hash>",
+		},
+		{
+			name:               "no last known good config",
+			rollbackPlacements: sets.New(placementRef),
+			expectedHash:       "<new-hash>",
+		},
+		{
+			name:               "roll back after max failures breached",
+			rollbackPlacements: sets.New(placementRef),
+			lastKnownGood:      true,
+			expectedRolledBack: true,
+			expectedToUpdate:   []string{"cluster1", "cluster2"},
+			expectedHash:       "<good-hash>",
+		},
+		{
+			name:               "continue the rollback",
+			rollbackPlacements: sets.New(placementRef),
+			lastKnownGood:      true,
+			conditions:         []metav1.Condition{rolledBackCondition},
+			lastApplied:        "<good-hash>",
+			expectedRolledBack: true,
+			expectedToUpdate:   []string{"cluster1", "cluster2"},
+			expectedHash:       "<good-hash>",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fakeClusterClient := fakecluster.NewSimpleClientset()
+			clusterInformers := clusterv1informers.NewSharedInformerFactory(fakeClusterClient, 10*time.Minute)
+			placementDecisionGetter := helpers.PlacementDecisionGetter{Client: clusterInformers.Cluster().V1beta1().PlacementDecisions().Lister()}
+			placementLister := clusterInformers.Cluster().V1beta1().Placements().Lister()
+
+			if err := clusterInformers.Cluster().V1beta1().Placements().Informer().GetStore().Add(&clusterv1beta1.Placement{
+				ObjectMeta: metav1.ObjectMeta{Name: placementRef.Name, Namespace: placementRef.Namespace}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := clusterInformers.Cluster().V1beta1().PlacementDecisions().Informer().GetStore().Add(&clusterv1beta1.PlacementDecision{
+				ObjectMeta: metav1.ObjectMeta{Name: placementRef.Name, Namespace: placementRef.Namespace,
+					Labels: map[string]string{
+						clusterv1beta1.PlacementLabel:          placementRef.Name,
+						clusterv1beta1.DecisionGroupIndexLabel: "0",
+					}},
+				Status: clusterv1beta1.PlacementDecisionStatus{Decisions: []clusterv1beta1.ClusterDecision{
+					{ClusterName: "cluster1"}, {ClusterName: "cluster2"}, {ClusterName: "cluster3"}}},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			graph := newGraph(nil, nil)
+			graph.rollbackPlacements = c.rollbackPlacements
+			for _, addon := range addons {
+				graph.addAddonNode(addon)
+			}
+
+			configRef := *installConfigReference.DeepCopy()
+			if !c.lastKnownGood {
+				configRef.LastKnownGoodConfig = nil
+			}
+			if len(c.lastApplied) > 0 {
+				configRef.LastAppliedConfig = &addonv1alpha1.ConfigSpecHash{
+					ConfigReferent: addonv1alpha1.ConfigReferent{Name: "test1"},
+					SpecHash:       c.lastApplied,
+				}
+			}
+			installProgression := addonv1alpha1.InstallProgression{
+				PlacementRef:     placementRef,
+				ConfigReferences: []addonv1alpha1.InstallConfigReference{configRef},
+				Conditions:       c.conditions,
+			}
+			if err := graph.addPlacementNode(placementStrategy, installProgression, placementLister, placementDecisionGetter); err != nil {
+				t.Fatal(err)
+			}
+			if err := graph.generateRolloutResult(); err != nil {
+				t.Fatal(err)
+			}
+
+			node := graph.getPlacementNodes()[placementRef]
+			if node.rolledBack != c.expectedRolledBack {
+				t.Errorf("expected rolled back %v, but got %v", c.expectedRolledBack, node.rolledBack)
+			}
+			if hash := node.desiredConfigs[barGR][0].DesiredConfig.SpecHash; hash != c.expectedHash {
+				t.Errorf("expected desired spec hash %s, but got %s", c.expectedHash, hash)
+			}
+
+			var actual []string
+			for _, addon := range graph.getAddonsToUpdate() {
+				actual = append(actual, addon.mca.Namespace)
+				if hash := addon.desiredConfigs[barGR][0].DesiredConfig.SpecHash; hash != c.expectedHash {
+					t.Errorf("expected desired spec hash %s on cluster %s, but got %s", c.expectedHash, addon.mca.Namespace, hash)
+				}
+			}
+			if c.expectedRolledBack && !reflect.DeepEqual(actual, c.expectedToUpdate) {
+				t.Errorf("expected addons to update %v, but got %v", c.expectedToUpdate, actual)
+			}
+		})
+	}
+}
+
 func newInstallConfigReference(group, resource, name, hash string) addonv1alpha1.InstallConfigReference {
 	return addonv1alpha1.InstallConfigReference{
 		ConfigGroupResource: addonv1alpha1.ConfigGroupResource{
@@ -0,0 +1,270 @@
+package addonhubcleanup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	rbacinformers "k8s.io/client-go/informers/rbac/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	rbaclisters "k8s.io/client-go/listers/rbac/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
+	"open-cluster-management.io/sdk-go/pkg/patcher"
+
+	"open-cluster-management.io/ocm/pkg/addon/controllers/addontokeninfra"
+	"open-cluster-management.io/ocm/pkg/addon/templateagent"
+	"open-cluster-management.io/ocm/pkg/common/queue"
+)
+
+const (
+	// HubResourceCleanupFinalizer is added to the ManagedClusterAddOn which has resources created on the hub on
+	// behalf of it, so the resources are removed before the addon is deleted.
+	HubResourceCleanupFinalizer = "addon.open-cluster-management.io/hub-resource-cleanup"
+
+	// ManagedClusterAddOnConditionHubResourcesCleanedUp is set to False on the deleting addon when the resources
+	// created on the hub on behalf of the addon fail to be removed, the message lists the leftover resources.
+	ManagedClusterAddOnConditionHubResourcesCleanedUp = "HubResourcesCleanedUp"
+	// LeftoverResourcesReason is the reason of the HubResourcesCleanedUp condition if there are leftover resources.
+	LeftoverResourcesReason = "LeftoverResources"
+
+	tokenInfrastructureLabelKey      = "addon.open-cluster-management.io/token-infrastructure"
+	tokenInfrastructureAddonLabelKey = "addon.open-cluster-management.io/name"
+)
+
+// hubResource is a resource created on the hub on behalf of an addon.
+type hubResource struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+func (r hubResource) String() string {
+	return fmt.Sprintf("%s %s/%s", r.kind, r.namespace, r.name)
+}
+
+// addonHubCleanupController removes the resources created on the hub on behalf of the addon when the
+// ManagedClusterAddOn is deleted, that is the rolebindings granting the hub permissions to the agent of the
+// template addon, which are labeled with the addon and cluster name. The ones out of the cluster namespace have
+// no owner reference to the addon.
+// The token infrastructure of the addon agent using the token driver is removed by the token infrastructure
+// controller, it is only reported here if it is left over.
+// A finalizer is added to the addon so the resources are removed before the addon is gone.
+type addonHubCleanupController struct {
+	kubeClient                     kubernetes.Interface
+	addonClient                    addonv1alpha1client.Interface
+	addonLister                    addonlisterv1alpha1.ManagedClusterAddOnLister
+	hubPermissionBindingLister     rbaclisters.RoleBindingLister
+	tokenInfraServiceAccountLister corelisters.ServiceAccountLister
+	tokenInfraRoleLister           rbaclisters.RoleLister
+	tokenInfraRoleBindingLister    rbaclisters.RoleBindingLister
+}
+
+// NewAddonHubCleanupController creates the controller, the hubPermissionBindingInformer is expected to only watch
+// the rolebindings labeled with the addon template, and the token infrastructure informers are expected to only
+// watch the token infrastructure resources.
+func NewAddonHubCleanupController(
+	kubeClient kubernetes.Interface,
+	addonClient addonv1alpha1client.Interface,
+	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	hubPermissionBindingInformer rbacinformers.RoleBindingInformer,
+	tokenInfraServiceAccountInformer coreinformers.ServiceAccountInformer,
+	tokenInfraRoleInformer rbacinformers.RoleInformer,
+	tokenInfraRoleBindingInformer rbacinformers.RoleBindingInformer,
+) factory.Controller {
+	c := &addonHubCleanupController{
+		kubeClient:                     kubeClient,
+		addonClient:                    addonClient,
+		addonLister:                    addonInformers.Lister(),
+		hubPermissionBindingLister:     hubPermissionBindingInformer.Lister(),
+		tokenInfraServiceAccountLister: tokenInfraServiceAccountInformer.Lister(),
+		tokenInfraRoleLister:           tokenInfraRoleInformer.Lister(),
+		tokenInfraRoleBindingLister:    tokenInfraRoleBindingInformer.Lister(),
+	}
+
+	return factory.New().
+		WithInformersQueueKeysFunc(queue.QueueKeyByMetaNamespaceName, addonInformers.Informer()).
+		WithInformersQueueKeysFunc(hubPermissionBindingQueueKey, hubPermissionBindingInformer.Informer()).
+		WithInformersQueueKeysFunc(tokenInfraQueueKey,
+			tokenInfraServiceAccountInformer.Informer(),
+			tokenInfraRoleInformer.Informer(),
+			tokenInfraRoleBindingInformer.Informer()).
+		WithSync(c.sync).
+		ToController("addon-hub-cleanup-controller")
+}
+
+// hubPermissionBindingQueueKey maps the hub permission rolebinding to the key of the addon it is created for.
+func hubPermissionBindingQueueKey(obj runtime.Object) []string {
+	accessor, _ := meta.Accessor(obj)
+	addonName := accessor.GetLabels()[addonapiv1alpha1.AddonLabelKey]
+	clusterName := accessor.GetLabels()[clusterv1.ClusterNameLabelKey]
+	if len(addonName) == 0 || len(clusterName) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s/%s", clusterName, addonName)}
+}
+
+// tokenInfraQueueKey maps the token infrastructure resource in the cluster namespace to the key of the addon.
+func tokenInfraQueueKey(obj runtime.Object) []string {
+	accessor, _ := meta.Accessor(obj)
+	addonName := accessor.GetLabels()[tokenInfrastructureAddonLabelKey]
+	if len(addonName) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s/%s", accessor.GetNamespace(), addonName)}
+}
+
+func (c *addonHubCleanupController) sync(ctx context.Context, syncCtx factory.SyncContext, key string) error {
+	logger := klog.FromContext(ctx).WithValues("addon", key)
+	logger.V(4).Info("Reconciling addon hub resources")
+
+	clusterName, addonName, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		// ignore addon whose key is invalid
+		return nil
+	}
+
+	addonPatcher := patcher.NewPatcher[
+		*addonapiv1alpha1.ManagedClusterAddOn,
+		addonapiv1alpha1.ManagedClusterAddOnSpec,
+		addonapiv1alpha1.ManagedClusterAddOnStatus](
+		c.addonClient.AddonV1alpha1().ManagedClusterAddOns(clusterName))
+
+	addon, err := c.addonLister.ManagedClusterAddOns(clusterName).Get(addonName)
+	switch {
+	case errors.IsNotFound(err):
+		// the addon is deleted without the finalizer, e.g. it is created before the finalizer is introduced,
+		// remove the leftover resources.
+		_, err := c.cleanupHubPermissionBindings(ctx, clusterName, addonName)
+		return err
+	case err != nil:
+		return err
+	}
+
+	if addon.DeletionTimestamp.IsZero() {
+		if !hasHubResources(addon) {
+			return nil
+		}
+		_, err := addonPatcher.AddFinalizer(ctx, addon, HubResourceCleanupFinalizer)
+		return err
+	}
+
+	if !hasFinalizer(addon, HubResourceCleanupFinalizer) {
+		return nil
+	}
+
+	leftovers, cleanupErr := c.cleanupHubPermissionBindings(ctx, clusterName, addonName)
+	tokenInfraLeftovers, err := c.tokenInfraLeftovers(clusterName, addonName)
+	if err != nil {
+		return err
+	}
+	leftovers = append(leftovers, tokenInfraLeftovers...)
+	sort.Strings(leftovers)
+	if len(leftovers) > 0 {
+		addonCopy := addon.DeepCopy()
+		meta.SetStatusCondition(&addonCopy.Status.Conditions, metav1.Condition{
+			Type:    ManagedClusterAddOnConditionHubResourcesCleanedUp,
+			Status:  metav1.ConditionFalse,
+			Reason:  LeftoverResourcesReason,
+			Message: fmt.Sprintf("Failed to remove the resources on the hub: %s", strings.Join(leftovers, ", ")),
+		})
+		_, err := addonPatcher.PatchStatus(ctx, addonCopy, addonCopy.Status, addon.Status)
+		// the addon is requeued once the leftover token infrastructure is removed by the token
+		// infrastructure controller.
+		return utilerrors.NewAggregate([]error{cleanupErr, err})
+	}
+	if cleanupErr != nil {
+		return cleanupErr
+	}
+
+	logger.Info("Resources of addon on the hub are removed")
+	return addonPatcher.RemoveFinalizer(ctx, addon, HubResourceCleanupFinalizer)
+}
+
+// cleanupHubPermissionBindings deletes the rolebindings created on the hub on behalf of the template addon, and
+// returns the rolebindings failed to be deleted.
+func (c *addonHubCleanupController) cleanupHubPermissionBindings(
+	ctx context.Context, clusterName, addonName string) ([]string, error) {
+	bindings, err := c.hubPermissionBindingLister.List(templateagent.HubPermissionBindingSelector(clusterName, addonName))
+	if err != nil {
+		return nil, err
+	}
+
+	var leftovers []string
+	var errs []error
+	for _, binding := range bindings {
+		err := c.kubeClient.RbacV1().RoleBindings(binding.Namespace).Delete(ctx, binding.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			leftovers = append(leftovers, hubResource{kind: "RoleBinding", namespace: binding.Namespace, name: binding.Name}.String())
+			errs = append(errs, err)
+		}
+	}
+	return leftovers, utilerrors.NewAggregate(errs)
+}
+
+// tokenInfraLeftovers returns the token infrastructure of the addon which is not removed by the token
+// infrastructure controller yet.
+func (c *addonHubCleanupController) tokenInfraLeftovers(clusterName, addonName string) ([]string, error) {
+	selector := labels.SelectorFromSet(labels.Set{
+		tokenInfrastructureAddonLabelKey: addonName,
+		tokenInfrastructureLabelKey:      "true",
+	})
+
+	var leftovers []string
+	bindings, err := c.tokenInfraRoleBindingLister.RoleBindings(clusterName).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, binding := range bindings {
+		leftovers = append(leftovers, hubResource{kind: "RoleBinding", namespace: binding.Namespace, name: binding.Name}.String())
+	}
+	roles, err := c.tokenInfraRoleLister.Roles(clusterName).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range roles {
+		leftovers = append(leftovers, hubResource{kind: "Role", namespace: role.Namespace, name: role.Name}.String())
+	}
+	serviceAccounts, err := c.tokenInfraServiceAccountLister.ServiceAccounts(clusterName).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, sa := range serviceAccounts {
+		leftovers = append(leftovers, hubResource{kind: "ServiceAccount", namespace: sa.Namespace, name: sa.Name}.String())
+	}
+	return leftovers, nil
+}
+
+// hasHubResources returns true if resources are created on the hub on behalf of the addon, that is the addon is
+// deployed by an addon template or its agent uses the token driver.
+func hasHubResources(addon *addonapiv1alpha1.ManagedClusterAddOn) bool {
+	if found, _ := templateagent.AddonTemplateConfigRef(addon.Status.ConfigReferences); found {
+		return true
+	}
+	return meta.FindStatusCondition(addon.Status.Conditions, addontokeninfra.TokenInfrastructureReadyCondition) != nil
+}
+
+func hasFinalizer(addon *addonapiv1alpha1.ManagedClusterAddOn, finalizer string) bool {
+	for _, f := range addon.Finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
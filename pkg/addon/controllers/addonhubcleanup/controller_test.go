@@ -0,0 +1,268 @@
+package addonhubcleanup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeinformers "k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/ocm/pkg/addon/templateagent"
+	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
+)
+
+func newTemplateAddon(finalizers []string, deleting bool) *addonapiv1alpha1.ManagedClusterAddOn {
+	addon := addontesting.NewAddon("test", "cluster1")
+	addon.Finalizers = finalizers
+	addon.Status.ConfigReferences = []addonapiv1alpha1.ConfigReference{
+		{
+			ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{
+				Group:    "addon.open-cluster-management.io",
+				Resource: "addontemplates",
+			},
+			DesiredConfig: &addonapiv1alpha1.ConfigSpecHash{
+				ConfigReferent: addonapiv1alpha1.ConfigReferent{Name: "template1"},
+			},
+		},
+	}
+	if deleting {
+		now := metav1.Now()
+		addon.DeletionTimestamp = &now
+	}
+	return addon
+}
+
+func newPermissionBinding(namespace, clusterName string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("open-cluster-management:test:%s:clusterrole:agent", clusterName),
+			Namespace: namespace,
+			Labels: map[string]string{
+				addonapiv1alpha1.AddonLabelKey:      "test",
+				clusterv1.ClusterNameLabelKey:       clusterName,
+				templateagent.AddonTemplateLabelKey: "",
+			},
+		},
+	}
+}
+
+func newTokenServiceAccount(namespace string) *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-agent",
+			Namespace: namespace,
+			Labels: map[string]string{
+				tokenInfrastructureAddonLabelKey: "test",
+				tokenInfrastructureLabelKey:      "true",
+			},
+		},
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	cases := []struct {
+		name                 string
+		syncKey              string
+		addons               []runtime.Object
+		kubeObjects          []runtime.Object
+		deleteErr            error
+		expectedErr          bool
+		validateAddonActions func(t *testing.T, actions []clienttesting.Action)
+		validateKubeActions  func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name:    "addon without hub resources",
+			syncKey: "cluster1/test",
+			addons:  []runtime.Object{addontesting.NewAddon("test", "cluster1")},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertNoActions(t, actions)
+			},
+			validateKubeActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertNoActions(t, actions)
+			},
+		},
+		{
+			name:    "add finalizer to template addon",
+			syncKey: "cluster1/test",
+			addons:  []runtime.Object{newTemplateAddon(nil, false)},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				patch := actions[0].(clienttesting.PatchActionImpl).Patch
+				addon := &addonapiv1alpha1.ManagedClusterAddOn{}
+				if err := json.Unmarshal(patch, addon); err != nil {
+					t.Fatal(err)
+				}
+				if len(addon.Finalizers) != 1 || addon.Finalizers[0] != HubResourceCleanupFinalizer {
+					t.Errorf("expected finalizer %s, but got %v", HubResourceCleanupFinalizer, addon.Finalizers)
+				}
+			},
+			validateKubeActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertNoActions(t, actions)
+			},
+		},
+		{
+			name:    "cleanup deleting addon",
+			syncKey: "cluster1/test",
+			addons:  []runtime.Object{newTemplateAddon([]string{HubResourceCleanupFinalizer}, true)},
+			kubeObjects: []runtime.Object{
+				newPermissionBinding("ns1", "cluster1"),
+				newPermissionBinding("ns1", "cluster2"),
+			},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				patch := actions[0].(clienttesting.PatchActionImpl).Patch
+				addon := &addonapiv1alpha1.ManagedClusterAddOn{}
+				if err := json.Unmarshal(patch, addon); err != nil {
+					t.Fatal(err)
+				}
+				if len(addon.Finalizers) != 0 {
+					t.Errorf("expected finalizer removed, but got %v", addon.Finalizers)
+				}
+			},
+			validateKubeActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "delete")
+				testingcommon.AssertDelete(t, actions[0], "rolebindings", "ns1",
+					"open-cluster-management:test:cluster1:clusterrole:agent")
+			},
+		},
+		{
+			name:    "token infrastructure of deleting addon is not removed yet",
+			syncKey: "cluster1/test",
+			addons:  []runtime.Object{newTemplateAddon([]string{HubResourceCleanupFinalizer}, true)},
+			kubeObjects: []runtime.Object{
+				newPermissionBinding("ns1", "cluster1"),
+				newTokenServiceAccount("cluster1"),
+			},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				patch := actions[0].(clienttesting.PatchActionImpl).Patch
+				addon := &addonapiv1alpha1.ManagedClusterAddOn{}
+				if err := json.Unmarshal(patch, addon); err != nil {
+					t.Fatal(err)
+				}
+				cond := meta.FindStatusCondition(addon.Status.Conditions, ManagedClusterAddOnConditionHubResourcesCleanedUp)
+				if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != LeftoverResourcesReason {
+					t.Fatalf("expected leftover resources condition, but got %v", cond)
+				}
+				expected := "Failed to remove the resources on the hub: ServiceAccount cluster1/test-agent"
+				if cond.Message != expected {
+					t.Errorf("expected message %q, but got %q", expected, cond.Message)
+				}
+			},
+			validateKubeActions: func(t *testing.T, actions []clienttesting.Action) {
+				// the token infrastructure is removed by the token infrastructure controller
+				testingcommon.AssertActions(t, actions, "delete")
+				testingcommon.AssertDelete(t, actions[0], "rolebindings", "ns1",
+					"open-cluster-management:test:cluster1:clusterrole:agent")
+			},
+		},
+		{
+			name:        "leftover resources of deleting addon",
+			syncKey:     "cluster1/test",
+			addons:      []runtime.Object{newTemplateAddon([]string{HubResourceCleanupFinalizer}, true)},
+			kubeObjects: []runtime.Object{newPermissionBinding("ns1", "cluster1")},
+			deleteErr:   fmt.Errorf("forbidden"),
+			expectedErr: true,
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				patch := actions[0].(clienttesting.PatchActionImpl).Patch
+				addon := &addonapiv1alpha1.ManagedClusterAddOn{}
+				if err := json.Unmarshal(patch, addon); err != nil {
+					t.Fatal(err)
+				}
+				cond := meta.FindStatusCondition(addon.Status.Conditions, ManagedClusterAddOnConditionHubResourcesCleanedUp)
+				if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != LeftoverResourcesReason {
+					t.Fatalf("expected leftover resources condition, but got %v", cond)
+				}
+				expected := "Failed to remove the resources on the hub: " +
+					"RoleBinding ns1/open-cluster-management:test:cluster1:clusterrole:agent"
+				if cond.Message != expected {
+					t.Errorf("expected message %q, but got %q", expected, cond.Message)
+				}
+			},
+			validateKubeActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "delete")
+			},
+		},
+		{
+			name:        "cleanup deleted addon",
+			syncKey:     "cluster1/test",
+			kubeObjects: []runtime.Object{newPermissionBinding("ns1", "cluster1")},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertNoActions(t, actions)
+			},
+			validateKubeActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "delete")
+				testingcommon.AssertDelete(t, actions[0], "rolebindings", "ns1",
+					"open-cluster-management:test:cluster1:clusterrole:agent")
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			fakeAddonClient := fakeaddon.NewSimpleClientset(c.addons...)
+			fakeKubeClient := kubefake.NewSimpleClientset(c.kubeObjects...)
+			if c.deleteErr != nil {
+				fakeKubeClient.PrependReactor("delete", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+					return true, nil, c.deleteErr
+				})
+			}
+
+			addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 10*time.Minute)
+			for _, obj := range c.addons {
+				if err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			hubPermissionBindingInformers := kubeinformers.NewSharedInformerFactory(fakeKubeClient, 10*time.Minute)
+			tokenInfraInformers := kubeinformers.NewSharedInformerFactory(fakeKubeClient, 10*time.Minute)
+			for _, obj := range c.kubeObjects {
+				var err error
+				switch obj.(type) {
+				case *rbacv1.RoleBinding:
+					err = hubPermissionBindingInformers.Rbac().V1().RoleBindings().Informer().GetStore().Add(obj)
+				case *corev1.ServiceAccount:
+					err = tokenInfraInformers.Core().V1().ServiceAccounts().Informer().GetStore().Add(obj)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			controller := NewAddonHubCleanupController(
+				fakeKubeClient,
+				fakeAddonClient,
+				addonInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+				hubPermissionBindingInformers.Rbac().V1().RoleBindings(),
+				tokenInfraInformers.Core().V1().ServiceAccounts(),
+				tokenInfraInformers.Rbac().V1().Roles(),
+				tokenInfraInformers.Rbac().V1().RoleBindings(),
+			)
+
+			err := controller.Sync(context.TODO(), testingcommon.NewFakeSyncContext(t, c.syncKey), c.syncKey)
+			if c.expectedErr != (err != nil) {
+				t.Errorf("expected error %v, but got %v", c.expectedErr, err)
+			}
+
+			c.validateAddonActions(t, fakeAddonClient.Actions())
+			c.validateKubeActions(t, fakeKubeClient.Actions())
+		})
+	}
+}
@@ -2,8 +2,11 @@ package addonmanagement
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -14,19 +17,24 @@ import (
 
 	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
 	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	clusterlisterv1 "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
 	clusterlisterv1beta1 "open-cluster-management.io/api/client/cluster/listers/cluster/v1beta1"
 	clusterv1beta1 "open-cluster-management.io/api/cluster/v1beta1"
 	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
+	"open-cluster-management.io/sdk-go/pkg/patcher"
 
+	"open-cluster-management.io/ocm/pkg/addon/compatibility"
 	addonindex "open-cluster-management.io/ocm/pkg/addon/index"
 )
 
 type managedClusterAddonInstallReconciler struct {
-	addonClient                addonv1alpha1client.Interface
-	managedClusterAddonIndexer cache.Indexer
-	placementLister            clusterlisterv1beta1.PlacementLister
-	placementDecisionLister    clusterlisterv1beta1.PlacementDecisionLister
-	addonFilterFunc            factory.EventFilterFunc
+	addonClient                   addonv1alpha1client.Interface
+	managedClusterAddonIndexer    cache.Indexer
+	clusterManagementAddonIndexer cache.Indexer
+	clusterLister                 clusterlisterv1.ManagedClusterLister
+	placementLister               clusterlisterv1beta1.PlacementLister
+	placementDecisionLister       clusterlisterv1beta1.PlacementDecisionLister
+	addonFilterFunc               factory.EventFilterFunc
 }
 
 func (d *managedClusterAddonInstallReconciler) reconcile(
@@ -48,10 +56,28 @@ func (d *managedClusterAddonInstallReconciler) reconcile(
 		return cma, reconcileContinue, err
 	}
 
+	dependencies, err := GetDependencies(cma)
+	if err != nil {
+		return cma, reconcileContinue, err
+	}
+	var cmas []*addonv1alpha1.ClusterManagementAddOn
+	for _, obj := range d.clusterManagementAddonIndexer.List() {
+		cmas = append(cmas, obj.(*addonv1alpha1.ClusterManagementAddOn))
+	}
+	if err := checkDependencyCycle(cmas, cma.Name); err != nil {
+		return cma, reconcileContinue, err
+	}
+	requirements, err := compatibility.GetRequirements(cma)
+	if err != nil {
+		return cma, reconcileContinue, err
+	}
+
 	existingDeployed := sets.Set[string]{}
+	existingAddons := map[string]*addonv1alpha1.ManagedClusterAddOn{}
 	for _, addonObject := range addons {
 		addon := addonObject.(*addonv1alpha1.ManagedClusterAddOn)
 		existingDeployed.Insert(addon.Namespace)
+		existingAddons[addon.Namespace] = addon
 	}
 
 	requiredDeployed, err := d.getAllDecisions(logger, cma.Name, cma.Spec.InstallStrategy.Placements)
@@ -69,7 +95,25 @@ func (d *managedClusterAddonInstallReconciler) reconcile(
 
 	var errs []error
 	for cluster := range toAdd {
-		_, err := d.addonClient.AddonV1alpha1().ManagedClusterAddOns(cluster).Create(ctx, &addonv1alpha1.ManagedClusterAddOn{
+		// create the addon only after its dependencies are available on the cluster
+		if satisfied, message := checkDependencies(d.managedClusterAddonIndexer, cluster, dependencies); !satisfied {
+			logger.V(2).Info("Dependencies of addon are not satisfied", "addonName", cma.Name,
+				"clusterName", cluster, "message", message)
+			continue
+		}
+		// do not install the addon on the cluster not meeting its requirements
+		incompatibleMessage, err := d.checkCompatibility(cluster, requirements)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(incompatibleMessage) > 0 {
+			logger.V(2).Info("Cluster does not meet the requirements of addon", "addonName", cma.Name,
+				"clusterName", cluster, "message", incompatibleMessage)
+			continue
+		}
+
+		_, err = d.addonClient.AddonV1alpha1().ManagedClusterAddOns(cluster).Create(ctx, &addonv1alpha1.ManagedClusterAddOn{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:            cma.Name,
 				Namespace:       cluster,
@@ -83,8 +127,35 @@ func (d *managedClusterAddonInstallReconciler) reconcile(
 		}
 	}
 
+	for cluster := range requiredDeployed.Intersection(existingDeployed) {
+		incompatibleMessage, err := d.checkCompatibility(cluster, requirements)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := d.updateAddonConditions(ctx, existingAddons[cluster], dependencies, nil, incompatibleMessage); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
 	for cluster := range toRemove {
-		err := d.addonClient.AddonV1alpha1().ManagedClusterAddOns(cluster).Delete(ctx, cma.Name, metav1.DeleteOptions{})
+		// keep the addon while the addons depending on it exist on the cluster
+		dependents, err := GetDependents(cmas, func(addonName string) (bool, error) {
+			_, exists, err := d.managedClusterAddonIndexer.GetByKey(fmt.Sprintf("%s/%s", cluster, addonName))
+			return exists, err
+		}, cma.Name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(dependents) > 0 {
+			if err := d.updateAddonConditions(ctx, existingAddons[cluster], dependencies, dependents, ""); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		err = d.addonClient.AddonV1alpha1().ManagedClusterAddOns(cluster).Delete(ctx, cma.Name, metav1.DeleteOptions{})
 		if err != nil && !errors.IsNotFound(err) {
 			errs = append(errs, err)
 		}
@@ -93,6 +164,81 @@ func (d *managedClusterAddonInstallReconciler) reconcile(
 	return cma, reconcileContinue, utilerrors.NewAggregate(errs)
 }
 
+// checkCompatibility returns the message of the requirements of the addon not met by the cluster.
+func (d *managedClusterAddonInstallReconciler) checkCompatibility(
+	clusterName string, requirements *compatibility.Requirements) (string, error) {
+	if requirements == nil {
+		return "", nil
+	}
+	cluster, err := d.clusterLister.Get(clusterName)
+	if errors.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if compatible, message := compatibility.Check(cluster, requirements); !compatible {
+		return message, nil
+	}
+	return "", nil
+}
+
+// updateAddonConditions reports the state of the dependencies, the dependents and the compatibility of the addon
+// in its conditions.
+func (d *managedClusterAddonInstallReconciler) updateAddonConditions(
+	ctx context.Context,
+	addon *addonv1alpha1.ManagedClusterAddOn,
+	dependencies []AddOnDependency,
+	dependents []string,
+	incompatibleMessage string) error {
+	newAddon := addon.DeepCopy()
+
+	if len(incompatibleMessage) > 0 {
+		meta.SetStatusCondition(&newAddon.Status.Conditions, metav1.Condition{
+			Type:    compatibility.ManagedClusterAddOnConditionInstalled,
+			Status:  metav1.ConditionFalse,
+			Reason:  compatibility.IncompatibleReason,
+			Message: fmt.Sprintf("The cluster does not meet the requirements of the addon: %s", incompatibleMessage),
+		})
+	} else {
+		meta.RemoveStatusCondition(&newAddon.Status.Conditions, compatibility.ManagedClusterAddOnConditionInstalled)
+	}
+
+	if len(dependencies) > 0 {
+		condition := metav1.Condition{
+			Type:    ManagedClusterAddOnConditionDependenciesSatisfied,
+			Status:  metav1.ConditionTrue,
+			Reason:  DependenciesSatisfiedReason,
+			Message: "All the dependencies are available",
+		}
+		if satisfied, message := checkDependencies(d.managedClusterAddonIndexer, addon.Namespace, dependencies); !satisfied {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = DependenciesNotSatisfiedReason
+			condition.Message = message
+		}
+		meta.SetStatusCondition(&newAddon.Status.Conditions, condition)
+	} else {
+		meta.RemoveStatusCondition(&newAddon.Status.Conditions, ManagedClusterAddOnConditionDependenciesSatisfied)
+	}
+
+	if len(dependents) > 0 {
+		meta.SetStatusCondition(&newAddon.Status.Conditions, metav1.Condition{
+			Type:    ManagedClusterAddOnConditionUninstallBlocked,
+			Status:  metav1.ConditionTrue,
+			Reason:  DependentsExistReason,
+			Message: fmt.Sprintf("Addons %s depending on the addon exist on the cluster", strings.Join(dependents, ", ")),
+		})
+	} else {
+		meta.RemoveStatusCondition(&newAddon.Status.Conditions, ManagedClusterAddOnConditionUninstallBlocked)
+	}
+
+	addonPatcher := patcher.NewPatcher[
+		*addonv1alpha1.ManagedClusterAddOn, addonv1alpha1.ManagedClusterAddOnSpec, addonv1alpha1.ManagedClusterAddOnStatus](
+		d.addonClient.AddonV1alpha1().ManagedClusterAddOns(addon.Namespace))
+	_, err := addonPatcher.PatchStatus(ctx, newAddon, newAddon.Status, addon.Status)
+	return err
+}
+
 func (d *managedClusterAddonInstallReconciler) getAllDecisions(
 	logger klog.Logger,
 	addonName string,
@@ -2,9 +2,13 @@ package addonmanagement
 
 import (
 	"context"
+	"fmt"
 
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 
@@ -12,9 +16,12 @@ import (
 	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
 	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
 	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+	clusterinformersv1 "open-cluster-management.io/api/client/cluster/informers/externalversions/cluster/v1"
 	clusterinformersv1beta1 "open-cluster-management.io/api/client/cluster/informers/externalversions/cluster/v1beta1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
 
+	"open-cluster-management.io/ocm/pkg/addon/compatibility"
 	addonindex "open-cluster-management.io/ocm/pkg/addon/index"
 	"open-cluster-management.io/ocm/pkg/common/queue"
 )
@@ -45,8 +52,12 @@ func NewAddonManagementController(
 	clusterManagementAddonInformers addoninformerv1alpha1.ClusterManagementAddOnInformer,
 	placementInformer clusterinformersv1beta1.PlacementInformer,
 	placementDecisionInformer clusterinformersv1beta1.PlacementDecisionInformer,
+	clusterInformer clusterinformersv1.ManagedClusterInformer,
 	addonFilterFunc factory.EventFilterFunc,
 ) factory.Controller {
+	controllerName := "addon-management-controller"
+	syncCtx := factory.NewSyncContext(controllerName)
+
 	c := &addonManagementController{
 		addonClient:                   addonClient,
 		clusterManagementAddonLister:  clusterManagementAddonInformers.Lister(),
@@ -54,18 +65,55 @@ func NewAddonManagementController(
 
 		reconcilers: []addonManagementReconcile{
 			&managedClusterAddonInstallReconciler{
-				addonClient:                addonClient,
-				placementDecisionLister:    placementDecisionInformer.Lister(),
-				placementLister:            placementInformer.Lister(),
-				managedClusterAddonIndexer: addonInformers.Informer().GetIndexer(),
-				addonFilterFunc:            addonFilterFunc,
+				addonClient:                   addonClient,
+				placementDecisionLister:       placementDecisionInformer.Lister(),
+				placementLister:               placementInformer.Lister(),
+				managedClusterAddonIndexer:    addonInformers.Informer().GetIndexer(),
+				clusterManagementAddonIndexer: clusterManagementAddonInformers.Informer().GetIndexer(),
+				clusterLister:                 clusterInformer.Lister(),
+				addonFilterFunc:               addonFilterFunc,
 			},
 		},
 	}
 
-	return factory.New().WithInformersQueueKeysFunc(
+	// recheck the compatibility only when a cluster is added or the fields checked by the requirements change,
+	// instead of on every status update of the clusters.
+	queueKeys := clusterManagementAddonWithRequirementsQueueKey(clusterManagementAddonInformers.Lister())
+	_, err := clusterInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			for _, key := range queueKeys() {
+				syncCtx.Queue().Add(key)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldCluster, ok := oldObj.(*clusterv1.ManagedCluster)
+			if !ok {
+				utilruntime.HandleError(fmt.Errorf("error to get old ManagedCluster object: %v", oldObj))
+				return
+			}
+			newCluster, ok := newObj.(*clusterv1.ManagedCluster)
+			if !ok {
+				utilruntime.HandleError(fmt.Errorf("error to get new ManagedCluster object: %v", newObj))
+				return
+			}
+			if !clusterCompatibilityChanged(oldCluster, newCluster) {
+				return
+			}
+			for _, key := range queueKeys() {
+				syncCtx.Queue().Add(key)
+			}
+		},
+	})
+	if err != nil {
+		utilruntime.HandleError(err)
+	}
+
+	return factory.New().WithSyncContext(syncCtx).WithInformersQueueKeysFunc(
 		queue.QueueKeyByMetaName,
 		addonInformers.Informer(), clusterManagementAddonInformers.Informer()).
+		WithInformersQueueKeysFunc(
+			ClusterManagementAddonByDependencyQueueKey(clusterManagementAddonInformers),
+			addonInformers.Informer()).
 		WithInformersQueueKeysFunc(
 			addonindex.ClusterManagementAddonByPlacementDecisionQueueKey(
 				clusterManagementAddonInformers),
@@ -74,7 +122,37 @@ func NewAddonManagementController(
 			addonindex.ClusterManagementAddonByPlacementQueueKey(
 				clusterManagementAddonInformers),
 			placementInformer.Informer()).
-		WithSync(c.sync).ToController("addon-management-controller")
+		WithBareInformers(clusterInformer.Informer()).
+		WithSync(c.sync).ToController(controllerName)
+}
+
+// clusterCompatibilityChanged returns true if the labels, the claims or the kubernetes version of the cluster
+// checked by the requirements of the addons change.
+func clusterCompatibilityChanged(oldCluster, newCluster *clusterv1.ManagedCluster) bool {
+	return !equality.Semantic.DeepEqual(oldCluster.Labels, newCluster.Labels) ||
+		!equality.Semantic.DeepEqual(oldCluster.Status.ClusterClaims, newCluster.Status.ClusterClaims) ||
+		oldCluster.Status.Version.Kubernetes != newCluster.Status.Version.Kubernetes
+}
+
+// clusterManagementAddonWithRequirementsQueueKey returns the keys of the ClusterManagementAddOns declaring the
+// requirements on the clusters, so the compatibility is rechecked once a cluster changes.
+func clusterManagementAddonWithRequirementsQueueKey(
+	cmaLister addonlisterv1alpha1.ClusterManagementAddOnLister) func() []string {
+	return func() []string {
+		cmas, err := cmaLister.List(labels.Everything())
+		if err != nil {
+			utilruntime.HandleError(err)
+			return []string{}
+		}
+
+		var keys []string
+		for _, cma := range cmas {
+			if _, ok := cma.Annotations[compatibility.RequirementsAnnotationKey]; ok {
+				keys = append(keys, cma.Name)
+			}
+		}
+		return keys
+	}
 }
 
 func (c *addonManagementController) sync(ctx context.Context, syncCtx factory.SyncContext, addonName string) error {
@@ -2,11 +2,15 @@ package addonmanagement
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	clienttesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2/ktesting"
@@ -18,22 +22,68 @@ import (
 	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
 	fakecluster "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
 	clusterv1informers "open-cluster-management.io/api/client/cluster/informers/externalversions"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	clusterv1beta1 "open-cluster-management.io/api/cluster/v1beta1"
 	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
 
+	"open-cluster-management.io/ocm/pkg/addon/compatibility"
 	addonindex "open-cluster-management.io/ocm/pkg/addon/index"
 	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
 )
 
+func newPlacementClusterManagementAddon(name, dependencies string) *addonv1alpha1.ClusterManagementAddOn {
+	addon := addontesting.NewClusterManagementAddon(name, "", "").Build()
+	if len(dependencies) > 0 {
+		addon.Annotations = map[string]string{DependenciesAnnotationKey: dependencies}
+	}
+	addon.Spec.InstallStrategy = addonv1alpha1.InstallStrategy{
+		Type: addonv1alpha1.AddonInstallStrategyPlacements,
+		Placements: []addonv1alpha1.PlacementStrategy{
+			{
+				PlacementRef: addonv1alpha1.PlacementRef{Name: "test-placement", Namespace: "default"},
+			},
+		},
+	}
+	return addon
+}
+
+func newAvailableAddon(name, cluster string) *addonv1alpha1.ManagedClusterAddOn {
+	addon := addontesting.NewAddon(name, cluster)
+	meta.SetStatusCondition(&addon.Status.Conditions, metav1.Condition{
+		Type:   addonv1alpha1.ManagedClusterAddOnConditionAvailable,
+		Status: metav1.ConditionTrue,
+		Reason: "Available",
+	})
+	return addon
+}
+
 func TestAddonInstallReconcile(t *testing.T) {
+	testPlacement := &clusterv1beta1.Placement{ObjectMeta: metav1.ObjectMeta{Name: "test-placement", Namespace: "default"}}
+	newTestPlacementDecision := func(clusters ...string) *clusterv1beta1.PlacementDecision {
+		decision := &clusterv1beta1.PlacementDecision{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-placement",
+				Namespace: "default",
+				Labels:    map[string]string{clusterv1beta1.PlacementLabel: "test-placement"},
+			},
+		}
+		for _, cluster := range clusters {
+			decision.Status.Decisions = append(decision.Status.Decisions, clusterv1beta1.ClusterDecision{ClusterName: cluster})
+		}
+		return decision
+	}
+
 	cases := []struct {
 		name                   string
 		managedClusteraddon    []runtime.Object
 		clusterManagementAddon *addonv1alpha1.ClusterManagementAddOn
-		placements             []runtime.Object
-		placementDecisions     []runtime.Object
-		validateAddonActions   func(t *testing.T, actions []clienttesting.Action)
-		expectErr              bool
+		// clusterManagementAddons are the other ClusterManagementAddOns in the informer
+		clusterManagementAddons []runtime.Object
+		clusters                []runtime.Object
+		placements              []runtime.Object
+		placementDecisions      []runtime.Object
+		validateAddonActions    func(t *testing.T, actions []clienttesting.Action)
+		expectErr               bool
 	}{
 		{
 			name:                   "no installStrategy",
@@ -219,6 +269,185 @@ func TestAddonInstallReconcile(t *testing.T) {
 				addontesting.AssertActions(t, actions, "create", "create", "delete")
 			},
 		},
+		{
+			name:                   "invalid dependencies",
+			clusterManagementAddon: newPlacementClusterManagementAddon("test", "invalid"),
+			placements:             []runtime.Object{testPlacement},
+			placementDecisions:     []runtime.Object{newTestPlacementDecision("cluster1")},
+			validateAddonActions:   addontesting.AssertNoActions,
+			expectErr:              true,
+		},
+		{
+			name: "install addon after dependencies are available",
+			managedClusteraddon: []runtime.Object{
+				newAvailableAddon("dep", "cluster1"),
+				addontesting.NewAddon("dep", "cluster2"),
+			},
+			clusterManagementAddon: newPlacementClusterManagementAddon("test", `[{"name": "dep"}]`),
+			placements:             []runtime.Object{testPlacement},
+			placementDecisions:     []runtime.Object{newTestPlacementDecision("cluster1", "cluster2", "cluster3")},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "create")
+				addon := actions[0].(clienttesting.CreateActionImpl).Object.(*addonv1alpha1.ManagedClusterAddOn)
+				if addon.Namespace != "cluster1" {
+					t.Errorf("expected addon created on cluster1, but got %s", addon.Namespace)
+				}
+			},
+		},
+		{
+			name: "required config of dependency is not applied",
+			managedClusteraddon: []runtime.Object{
+				func() *addonv1alpha1.ManagedClusterAddOn {
+					addon := newAvailableAddon("dep", "cluster1")
+					addon.Status.ConfigReferences = []addonv1alpha1.ConfigReference{
+						{
+							ConfigGroupResource: addonv1alpha1.ConfigGroupResource{
+								Group: "addon.open-cluster-management.io", Resource: "addontemplates"},
+							DesiredConfig: &addonv1alpha1.ConfigSpecHash{
+								ConfigReferent: addonv1alpha1.ConfigReferent{Name: "dep-v2"}, SpecHash: "hash2"},
+							LastAppliedConfig: &addonv1alpha1.ConfigSpecHash{
+								ConfigReferent: addonv1alpha1.ConfigReferent{Name: "dep-v1"}, SpecHash: "hash1"},
+						},
+					}
+					return addon
+				}(),
+			},
+			clusterManagementAddon: newPlacementClusterManagementAddon("test",
+				`[{"name": "dep", "config": {"group": "addon.open-cluster-management.io", "resource": "addontemplates", "name": "dep-v2"}}]`),
+			placements:           []runtime.Object{testPlacement},
+			placementDecisions:   []runtime.Object{newTestPlacementDecision("cluster1")},
+			validateAddonActions: addontesting.AssertNoActions,
+		},
+		{
+			name: "update dependencies condition",
+			managedClusteraddon: []runtime.Object{
+				addontesting.NewAddon("dep", "cluster1"),
+				addontesting.NewAddon("test", "cluster1"),
+			},
+			clusterManagementAddon: newPlacementClusterManagementAddon("test", `[{"name": "dep"}]`),
+			placements:             []runtime.Object{testPlacement},
+			placementDecisions:     []runtime.Object{newTestPlacementDecision("cluster1")},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+				addon := &addonv1alpha1.ManagedClusterAddOn{}
+				if err := json.Unmarshal(actions[0].(clienttesting.PatchActionImpl).Patch, addon); err != nil {
+					t.Fatal(err)
+				}
+				cond := meta.FindStatusCondition(addon.Status.Conditions, ManagedClusterAddOnConditionDependenciesSatisfied)
+				if cond == nil || cond.Status != metav1.ConditionFalse || cond.Message != "addon dep is not available" {
+					t.Errorf("unexpected condition %v", cond)
+				}
+			},
+		},
+		{
+			name: "uninstall blocked by dependents",
+			managedClusteraddon: []runtime.Object{
+				addontesting.NewAddon("dep", "cluster1"),
+				addontesting.NewAddon("test", "cluster1"),
+			},
+			clusterManagementAddon: newPlacementClusterManagementAddon("dep", ""),
+			clusterManagementAddons: []runtime.Object{
+				newPlacementClusterManagementAddon("test", `[{"name": "dep"}]`),
+			},
+			placements:         []runtime.Object{testPlacement},
+			placementDecisions: []runtime.Object{newTestPlacementDecision("cluster2")},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "create", "patch")
+				addon := &addonv1alpha1.ManagedClusterAddOn{}
+				if err := json.Unmarshal(actions[1].(clienttesting.PatchActionImpl).Patch, addon); err != nil {
+					t.Fatal(err)
+				}
+				cond := meta.FindStatusCondition(addon.Status.Conditions, ManagedClusterAddOnConditionUninstallBlocked)
+				if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason != DependentsExistReason {
+					t.Errorf("unexpected condition %v", cond)
+				}
+			},
+		},
+		{
+			name:                   "dependency cycle",
+			clusterManagementAddon: newPlacementClusterManagementAddon("test", `[{"name": "dep"}]`),
+			clusterManagementAddons: []runtime.Object{
+				newPlacementClusterManagementAddon("dep", `[{"name": "test"}]`),
+			},
+			placements:           []runtime.Object{testPlacement},
+			placementDecisions:   []runtime.Object{newTestPlacementDecision("cluster1")},
+			validateAddonActions: addontesting.AssertNoActions,
+			expectErr:            true,
+		},
+		{
+			name: "incompatible cluster",
+			managedClusteraddon: []runtime.Object{
+				addontesting.NewAddon("test", "cluster1"),
+				addontesting.NewAddon("test", "cluster2"),
+			},
+			clusterManagementAddon: func() *addonv1alpha1.ClusterManagementAddOn {
+				addon := newPlacementClusterManagementAddon("test", "")
+				addon.Annotations = map[string]string{
+					compatibility.RequirementsAnnotationKey: `{"kubernetesVersion": {"min": "1.29.0"}}`,
+				}
+				return addon
+			}(),
+			clusters: []runtime.Object{
+				func() *clusterv1.ManagedCluster {
+					cluster := addontesting.NewManagedCluster("cluster1")
+					cluster.Status.Version.Kubernetes = "v1.28.3"
+					return cluster
+				}(),
+				func() *clusterv1.ManagedCluster {
+					cluster := addontesting.NewManagedCluster("cluster2")
+					cluster.Status.Version.Kubernetes = "v1.30.1"
+					return cluster
+				}(),
+			},
+			placements:         []runtime.Object{testPlacement},
+			placementDecisions: []runtime.Object{newTestPlacementDecision("cluster1", "cluster2")},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "patch")
+				patch := actions[0].(clienttesting.PatchActionImpl)
+				if patch.Namespace != "cluster1" {
+					t.Errorf("expected addon on cluster1 patched, but got %s", patch.Namespace)
+				}
+				addon := &addonv1alpha1.ManagedClusterAddOn{}
+				if err := json.Unmarshal(patch.Patch, addon); err != nil {
+					t.Fatal(err)
+				}
+				cond := meta.FindStatusCondition(addon.Status.Conditions, compatibility.ManagedClusterAddOnConditionInstalled)
+				if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != compatibility.IncompatibleReason {
+					t.Errorf("unexpected condition %v", cond)
+				}
+			},
+		},
+		{
+			name:                "skip install on incompatible cluster",
+			managedClusteraddon: []runtime.Object{},
+			clusterManagementAddon: func() *addonv1alpha1.ClusterManagementAddOn {
+				addon := newPlacementClusterManagementAddon("test", "")
+				addon.Annotations = map[string]string{
+					compatibility.RequirementsAnnotationKey: `{"kubernetesVersion": {"min": "1.29.0"}}`,
+				}
+				return addon
+			}(),
+			clusters: []runtime.Object{
+				func() *clusterv1.ManagedCluster {
+					cluster := addontesting.NewManagedCluster("cluster1")
+					cluster.Status.Version.Kubernetes = "v1.28.3"
+					return cluster
+				}(),
+				func() *clusterv1.ManagedCluster {
+					cluster := addontesting.NewManagedCluster("cluster2")
+					cluster.Status.Version.Kubernetes = "v1.30.1"
+					return cluster
+				}(),
+			},
+			placements:         []runtime.Object{testPlacement},
+			placementDecisions: []runtime.Object{newTestPlacementDecision("cluster1", "cluster2")},
+			validateAddonActions: func(t *testing.T, actions []clienttesting.Action) {
+				addontesting.AssertActions(t, actions, "create")
+				if ns := actions[0].GetNamespace(); ns != "cluster2" {
+					t.Errorf("expected addon created on cluster2, but got %s", ns)
+				}
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -237,6 +466,25 @@ func TestAddonInstallReconcile(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
+			err = addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().AddIndexers(
+				cache.Indexers{
+					ClusterManagementAddonByDependency: IndexClusterManagementAddonByDependency,
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, obj := range append(c.clusterManagementAddons, c.clusterManagementAddon) {
+				if err := addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, obj := range c.clusters {
+				if err := clusterInformers.Cluster().V1().ManagedClusters().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
 
 			for _, obj := range c.placements {
 				if err := clusterInformers.Cluster().V1beta1().Placements().Informer().GetStore().Add(obj); err != nil {
@@ -257,11 +505,13 @@ func TestAddonInstallReconcile(t *testing.T) {
 			}
 
 			reconcile := &managedClusterAddonInstallReconciler{
-				addonClient:                fakeAddonClient,
-				placementLister:            clusterInformers.Cluster().V1beta1().Placements().Lister(),
-				placementDecisionLister:    clusterInformers.Cluster().V1beta1().PlacementDecisions().Lister(),
-				managedClusterAddonIndexer: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetIndexer(),
-				addonFilterFunc:            utils.ManagedByAddonManager,
+				addonClient:                   fakeAddonClient,
+				placementLister:               clusterInformers.Cluster().V1beta1().Placements().Lister(),
+				placementDecisionLister:       clusterInformers.Cluster().V1beta1().PlacementDecisions().Lister(),
+				managedClusterAddonIndexer:    addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetIndexer(),
+				clusterManagementAddonIndexer: addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().GetIndexer(),
+				clusterLister:                 clusterInformers.Cluster().V1().ManagedClusters().Lister(),
+				addonFilterFunc:               utils.ManagedByAddonManager,
 			}
 
 			_, _, err = reconcile.reconcile(context.TODO(), c.clusterManagementAddon)
@@ -302,6 +552,7 @@ func TestNewAddonManagementController(t *testing.T) {
 		addonInformers.Addon().V1alpha1().ClusterManagementAddOns(),
 		clusterInformers.Cluster().V1beta1().Placements(),
 		clusterInformers.Cluster().V1beta1().PlacementDecisions(),
+		clusterInformers.Cluster().V1().ManagedClusters(),
 		addonFilterFunc,
 	)
 
@@ -416,6 +667,13 @@ func TestAddonManagementControllerSync(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
+			err = addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().AddIndexers(
+				cache.Indexers{
+					ClusterManagementAddonByDependency: IndexClusterManagementAddonByDependency,
+				})
+			if err != nil {
+				t.Fatal(err)
+			}
 
 			// Populate informer stores
 			for _, obj := range c.managedClusterAddons {
@@ -453,11 +711,13 @@ func TestAddonManagementControllerSync(t *testing.T) {
 				clusterManagementAddonIndexer: addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().GetIndexer(),
 				reconcilers: []addonManagementReconcile{
 					&managedClusterAddonInstallReconciler{
-						addonClient:                fakeAddonClient,
-						placementDecisionLister:    clusterInformers.Cluster().V1beta1().PlacementDecisions().Lister(),
-						placementLister:            clusterInformers.Cluster().V1beta1().Placements().Lister(),
-						managedClusterAddonIndexer: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetIndexer(),
-						addonFilterFunc:            addonFilterFunc,
+						addonClient:                   fakeAddonClient,
+						placementDecisionLister:       clusterInformers.Cluster().V1beta1().PlacementDecisions().Lister(),
+						placementLister:               clusterInformers.Cluster().V1beta1().Placements().Lister(),
+						managedClusterAddonIndexer:    addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetIndexer(),
+						clusterManagementAddonIndexer: addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().GetIndexer(),
+						clusterLister:                 clusterInformers.Cluster().V1().ManagedClusters().Lister(),
+						addonFilterFunc:               addonFilterFunc,
 					},
 				},
 			}
@@ -481,3 +741,93 @@ func TestAddonManagementControllerSync(t *testing.T) {
 		})
 	}
 }
+
+func TestGetDependents(t *testing.T) {
+	cmas := []*addonv1alpha1.ClusterManagementAddOn{
+		newPlacementClusterManagementAddon("dep", ""),
+		newPlacementClusterManagementAddon("test1", `[{"name": "dep"}]`),
+		newPlacementClusterManagementAddon("test2", `[{"name": "dep"}]`),
+		newPlacementClusterManagementAddon("test3", `[{"name": "test1"}]`),
+		// cycle-a and cycle-b depend on each other
+		newPlacementClusterManagementAddon("cycle-a", `[{"name": "cycle-b"}]`),
+		newPlacementClusterManagementAddon("cycle-b", `[{"name": "cycle-a"}]`),
+	}
+	installed := sets.New("dep", "test1", "test3", "cycle-a", "cycle-b")
+	addonInstalled := func(addonName string) (bool, error) {
+		return installed.Has(addonName), nil
+	}
+
+	cases := []struct {
+		addonName string
+		expected  []string
+	}{
+		{addonName: "dep", expected: []string{"test1"}},
+		{addonName: "test1", expected: []string{"test3"}},
+		{addonName: "test3"},
+		{addonName: "cycle-a"},
+	}
+	for _, c := range cases {
+		t.Run(c.addonName, func(t *testing.T) {
+			dependents, err := GetDependents(cmas, addonInstalled, c.addonName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equality.Semantic.DeepEqual(dependents, c.expected) {
+				t.Errorf("expected dependents %v, but got %v", c.expected, dependents)
+			}
+		})
+	}
+}
+
+func TestClusterCompatibilityChanged(t *testing.T) {
+	newCluster := func(label, claim, version string) *clusterv1.ManagedCluster {
+		cluster := addontesting.NewManagedCluster("cluster1")
+		cluster.Labels = map[string]string{"env": label}
+		cluster.Status.ClusterClaims = []clusterv1.ManagedClusterClaim{{Name: "platform", Value: claim}}
+		cluster.Status.Version.Kubernetes = version
+		return cluster
+	}
+
+	cases := []struct {
+		name       string
+		oldCluster *clusterv1.ManagedCluster
+		newCluster *clusterv1.ManagedCluster
+		expected   bool
+	}{
+		{
+			name:       "no change",
+			oldCluster: newCluster("dev", "aws", "v1.30.1"),
+			newCluster: func() *clusterv1.ManagedCluster {
+				cluster := newCluster("dev", "aws", "v1.30.1")
+				cluster.Status.Conditions = []metav1.Condition{{Type: "ManagedClusterConditionAvailable"}}
+				return cluster
+			}(),
+		},
+		{
+			name:       "label changed",
+			oldCluster: newCluster("dev", "aws", "v1.30.1"),
+			newCluster: newCluster("prod", "aws", "v1.30.1"),
+			expected:   true,
+		},
+		{
+			name:       "claim changed",
+			oldCluster: newCluster("dev", "aws", "v1.30.1"),
+			newCluster: newCluster("dev", "gcp", "v1.30.1"),
+			expected:   true,
+		},
+		{
+			name:       "version changed",
+			oldCluster: newCluster("dev", "aws", "v1.30.1"),
+			newCluster: newCluster("dev", "aws", "v1.31.0"),
+			expected:   true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if actual := clusterCompatibilityChanged(c.oldCluster, c.newCluster); actual != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package addonmanagement
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
+)
+
+const (
+	// DependenciesAnnotationKey is the annotation key of the ClusterManagementAddOn declaring the addons it depends
+	// on. The value is a json encoded list of AddOnDependency. The ManagedClusterAddOn is created on a cluster only
+	// after all the dependencies are available on the cluster, and a dependency is not uninstalled from a cluster
+	// while its dependents exist on the cluster, the deletion of the dependency is rejected by the addon webhook on
+	// the other removal paths except the deletion of the cluster. The webhook is not registered in hosted mode. The
+	// dependency cycles are not allowed.
+	DependenciesAnnotationKey = "addon.open-cluster-management.io/dependencies"
+
+	// ManagedClusterAddOnConditionDependenciesSatisfied represents whether the dependencies of the addon are
+	// available on the cluster.
+	ManagedClusterAddOnConditionDependenciesSatisfied = "DependenciesSatisfied"
+	// ManagedClusterAddOnConditionUninstallBlocked represents the addon is not uninstalled from the cluster because
+	// other addons depending on it exist on the cluster.
+	ManagedClusterAddOnConditionUninstallBlocked = "UninstallBlocked"
+
+	DependenciesSatisfiedReason    = "DependenciesSatisfied"
+	DependenciesNotSatisfiedReason = "DependenciesNotSatisfied"
+	DependentsExistReason          = "DependentsExist"
+
+	// ClusterManagementAddonByDependency is the index of the ClusterManagementAddOns by the names of their dependencies.
+	ClusterManagementAddonByDependency = "clusterManagementAddonByDependency"
+)
+
+// AddOnDependency is an addon the ClusterManagementAddOn depends on.
+type AddOnDependency struct {
+	// Name is the name of the addon depended on.
+	Name string `json:"name"`
+	// Config optionally requires the config to be applied by the addon depended on, for example an AddOnTemplate
+	// of a minimum version.
+	Config *AddOnDependencyConfig `json:"config,omitempty"`
+}
+
+// AddOnDependencyConfig is a config required to be applied by the addon depended on.
+type AddOnDependencyConfig struct {
+	addonv1alpha1.ConfigGroupResource `json:",inline"`
+	addonv1alpha1.ConfigReferent      `json:",inline"`
+	// SpecHash optionally requires the spec hash of the applied config.
+	SpecHash string `json:"specHash,omitempty"`
+}
+
+// GetDependencies returns the dependencies declared by the ClusterManagementAddOn.
+func GetDependencies(cma *addonv1alpha1.ClusterManagementAddOn) ([]AddOnDependency, error) {
+	value, ok := cma.Annotations[DependenciesAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	var dependencies []AddOnDependency
+	if err := json.Unmarshal([]byte(value), &dependencies); err != nil {
+		return nil, fmt.Errorf("invalid annotation %s of addon %s: %v", DependenciesAnnotationKey, cma.Name, err)
+	}
+	for _, dependency := range dependencies {
+		if len(dependency.Name) == 0 {
+			return nil, fmt.Errorf("invalid annotation %s of addon %s: dependency name is required", DependenciesAnnotationKey, cma.Name)
+		}
+		if dependency.Name == cma.Name {
+			return nil, fmt.Errorf("invalid annotation %s of addon %s: addon cannot depend on itself", DependenciesAnnotationKey, cma.Name)
+		}
+	}
+	return dependencies, nil
+}
+
+func IndexClusterManagementAddonByDependency(obj interface{}) ([]string, error) {
+	cma, ok := obj.(*addonv1alpha1.ClusterManagementAddOn)
+	if !ok {
+		return []string{}, fmt.Errorf("obj %T is not a ClusterManagementAddon", obj)
+	}
+
+	dependencies, err := GetDependencies(cma)
+	if err != nil {
+		// do not fail the informer with the invalid annotation, the error is reported when the addon is reconciled
+		return []string{}, nil
+	}
+
+	var keys []string
+	for _, dependency := range dependencies {
+		keys = append(keys, dependency.Name)
+	}
+	return keys, nil
+}
+
+// ClusterManagementAddonByDependencyQueueKey returns the keys of the ClusterManagementAddOns depending on the addon
+// and the ClusterManagementAddOns the addon depends on, so the installation of the dependents and the uninstallation
+// of the dependencies are reconciled once the addon changes.
+func ClusterManagementAddonByDependencyQueueKey(
+	cmai addoninformerv1alpha1.ClusterManagementAddOnInformer) func(obj runtime.Object) []string {
+	return func(obj runtime.Object) []string {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			utilruntime.HandleError(err)
+			return []string{}
+		}
+
+		keys := sets.New[string]()
+		dependents, err := cmai.Informer().GetIndexer().ByIndex(ClusterManagementAddonByDependency, accessor.GetName())
+		if err != nil {
+			utilruntime.HandleError(err)
+			return []string{}
+		}
+		for _, o := range dependents {
+			keys.Insert(o.(*addonv1alpha1.ClusterManagementAddOn).Name)
+		}
+
+		cma, err := cmai.Lister().Get(accessor.GetName())
+		if err == nil {
+			dependencies, _ := GetDependencies(cma)
+			for _, dependency := range dependencies {
+				keys.Insert(dependency.Name)
+			}
+		}
+
+		klog.V(4).Infof("enqueue ClusterManagementAddons %v, because of the dependency addon %s/%s",
+			sets.List(keys), accessor.GetNamespace(), accessor.GetName())
+		return sets.List(keys)
+	}
+}
+
+// checkDependencies checks whether the dependencies are available on the cluster, and returns the message of the
+// unsatisfied dependencies.
+func checkDependencies(addonIndexer cache.Indexer, cluster string, dependencies []AddOnDependency) (bool, string) {
+	var messages []string
+	for _, dependency := range dependencies {
+		if message := checkDependency(addonIndexer, cluster, dependency); len(message) > 0 {
+			messages = append(messages, message)
+		}
+	}
+	if len(messages) > 0 {
+		return false, strings.Join(messages, "; ")
+	}
+	return true, ""
+}
+
+func checkDependency(addonIndexer cache.Indexer, cluster string, dependency AddOnDependency) string {
+	obj, exists, err := addonIndexer.GetByKey(fmt.Sprintf("%s/%s", cluster, dependency.Name))
+	if err != nil {
+		return fmt.Sprintf("failed to get addon %s: %v", dependency.Name, err)
+	}
+	if !exists {
+		return fmt.Sprintf("addon %s is not installed", dependency.Name)
+	}
+
+	addon := obj.(*addonv1alpha1.ManagedClusterAddOn)
+	if !addon.DeletionTimestamp.IsZero() {
+		return fmt.Sprintf("addon %s is deleting", dependency.Name)
+	}
+	if !meta.IsStatusConditionTrue(addon.Status.Conditions, addonv1alpha1.ManagedClusterAddOnConditionAvailable) {
+		return fmt.Sprintf("addon %s is not available", dependency.Name)
+	}
+
+	config := dependency.Config
+	if config == nil {
+		return ""
+	}
+	for _, configRef := range addon.Status.ConfigReferences {
+		if configRef.ConfigGroupResource != config.ConfigGroupResource || configRef.DesiredConfig == nil ||
+			configRef.DesiredConfig.ConfigReferent != config.ConfigReferent {
+			continue
+		}
+		// the desired config is applied
+		if configRef.LastAppliedConfig == nil || *configRef.LastAppliedConfig != *configRef.DesiredConfig {
+			break
+		}
+		if len(config.SpecHash) > 0 && configRef.LastAppliedConfig.SpecHash != config.SpecHash {
+			break
+		}
+		return ""
+	}
+	name := config.Name
+	if len(config.Namespace) > 0 {
+		name = config.Namespace + "/" + name
+	}
+	return fmt.Sprintf("config %s.%s %s of addon %s is not applied", config.Resource, config.Group, name, dependency.Name)
+}
+
+// GetDependents returns the names of the addons installed on the cluster depending on the addon. The addons in a
+// dependency cycle with the addon are not counted, so a cycle does not block the uninstallation forever.
+func GetDependents(
+	cmas []*addonv1alpha1.ClusterManagementAddOn,
+	addonInstalled func(addonName string) (bool, error),
+	addonName string) ([]string, error) {
+	dependencies := dependencyMap(cmas)
+
+	var dependents []string
+	for name, names := range dependencies {
+		if !sets.New(names...).Has(addonName) || dependsOn(dependencies, addonName, name) {
+			continue
+		}
+		installed, err := addonInstalled(name)
+		if err != nil {
+			return nil, err
+		}
+		if installed {
+			dependents = append(dependents, name)
+		}
+	}
+	sort.Strings(dependents)
+	return dependents, nil
+}
+
+// checkDependencyCycle returns an error if the addon depends on itself through the other addons.
+func checkDependencyCycle(cmas []*addonv1alpha1.ClusterManagementAddOn, addonName string) error {
+	if dependsOn(dependencyMap(cmas), addonName, addonName) {
+		return fmt.Errorf("invalid annotation %s of addon %s: dependency cycle is not allowed", DependenciesAnnotationKey, addonName)
+	}
+	return nil
+}
+
+// dependencyMap returns the names of the dependencies by the name of the ClusterManagementAddOns, the
+// ClusterManagementAddOns with an invalid annotation are ignored.
+func dependencyMap(cmas []*addonv1alpha1.ClusterManagementAddOn) map[string][]string {
+	dependencies := map[string][]string{}
+	for _, cma := range cmas {
+		addonDependencies, err := GetDependencies(cma)
+		if err != nil {
+			continue
+		}
+		for _, dependency := range addonDependencies {
+			dependencies[cma.Name] = append(dependencies[cma.Name], dependency.Name)
+		}
+	}
+	return dependencies
+}
+
+// dependsOn returns true if the addon depends on the target addon directly or transitively.
+func dependsOn(dependencies map[string][]string, addonName, target string) bool {
+	visited := sets.New[string]()
+	queue := append([]string{}, dependencies[addonName]...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if name == target {
+			return true
+		}
+		if visited.Has(name) {
+			continue
+		}
+		visited.Insert(name)
+		queue = append(queue, dependencies[name]...)
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package addontemplate
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -38,6 +39,12 @@ type addonTemplateController struct {
 	// addonManagers holds all addon managers that will be deployed with template type addons.
 	// The key is the name of the template type addon.
 	addonManagers map[string]context.CancelFunc
+	// addonManagerConfigGVRs holds the config resources declared by the template type addons when their addon
+	// managers are started. The key is the name of the template type addon.
+	addonManagerConfigGVRs map[string]string
+	// addonManagerExited holds the channels closed once the addon managers exit, a new addon manager is not started
+	// until the stopped one exits. The key is the name of the template type addon.
+	addonManagerExited map[string]<-chan struct{}
 
 	kubeConfig                 *rest.Config
 	addonClient                addonv1alpha1client.Interface
@@ -74,6 +81,8 @@ func NewAddonTemplateController(
 		cmaLister:                  addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Lister(),
 		managedClusterAddonIndexer: addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetIndexer(),
 		addonManagers:              make(map[string]context.CancelFunc),
+		addonManagerConfigGVRs:     make(map[string]string),
+		addonManagerExited:         make(map[string]<-chan struct{}),
 		addonInformers:             addonInformers,
 		clusterInformers:           clusterInformers,
 		dynamicInformers:           dynamicInformers,
@@ -141,6 +150,7 @@ func (c *addonTemplateController) stopUnusedManagers(
 		logger.Info("Start to stop the manager for addon", "addonName", addOnName)
 		stopFunc()
 		delete(c.addonManagers, addOnName)
+		delete(c.addonManagerConfigGVRs, addOnName)
 		logger.Info("The manager for addon stopped", "addonName", addOnName)
 	}
 	return nil
@@ -162,25 +172,53 @@ func (c *addonTemplateController) sync(ctx context.Context, syncCtx factory.Sync
 		return c.stopUnusedManagers(ctx, syncCtx, cma.Name)
 	}
 
-	_, exist := c.addonManagers[addonName]
+	// the supported configs of the addon manager are determined when it starts, so the manager is restarted
+	// once the config resources declared by the addon change.
+	configGVRs := fmt.Sprint(templateagent.TemplateConfigGVRs(cma))
+	stopFunc, exist := c.addonManagers[addonName]
 	if exist {
-		logger.V(4).Info("There already is a manager started for addon, skipping")
-		return nil
+		if c.addonManagerConfigGVRs[addonName] == configGVRs {
+			logger.V(4).Info("There already is a manager started for addon, skipping")
+			return nil
+		}
+		logger.Info("Restarting the addon manager for addon since the config resources changed",
+			"configResources", configGVRs)
+		stopFunc()
+		delete(c.addonManagers, addonName)
+		delete(c.addonManagerConfigGVRs, addonName)
+	}
+
+	// both managers reconcile the addon if the new one is started before the stopped one exits, so wait for it.
+	if exited, ok := c.addonManagerExited[addonName]; ok {
+		select {
+		case <-exited:
+			delete(c.addonManagerExited, addonName)
+		default:
+			logger.Info("Waiting for the stopped addon manager for addon to exit")
+			syncCtx.Queue().AddAfter(addonName, 1*time.Second)
+			return nil
+		}
 	}
 
 	logger.Info("Starting an addon manager for addon")
 
-	stopFunc := c.startManager(ctx, addonName)
+	stopFunc, exited := c.startManager(ctx, addonName)
 	c.addonManagers[addonName] = stopFunc
+	c.addonManagerConfigGVRs[addonName] = configGVRs
+	c.addonManagerExited[addonName] = exited
 	return nil
 }
 
+// startManager starts the addon manager for the addon, and returns the function to stop it and the channel closed
+// once it exits.
 func (c *addonTemplateController) startManager(
 	pctx context.Context,
-	addonName string) context.CancelFunc {
+	addonName string) (context.CancelFunc, <-chan struct{}) {
 	ctx, stopFunc := context.WithCancel(pctx)
 	logger := klog.FromContext(ctx)
+	exited := make(chan struct{})
 	go func() {
+		defer close(exited)
 		err := c.runControllerFunc(ctx, addonName)
 		if err != nil {
 			logger.Error(err, "Error running controller for addon")
@@ -197,7 +235,7 @@ func (c *addonTemplateController) startManager(
 		<-ctx.Done()
 		logger.Info("Addon Manager stopped")
 	}()
-	return stopFunc
+	return stopFunc, exited
 }
 
 func (c *addonTemplateController) runController(ctx context.Context, addonName string) error {
@@ -242,6 +280,8 @@ func (c *addonTemplateController) runController(ctx context.Context, addonName s
 			templateagent.ToAddOnProxyPrivateValues,
 			templateagent.ToAddOnResourceRequirementsPrivateValues,
 		),
+		// the variables from the config resources declared by the addon override the customized variables
+		templateagent.GetTemplateConfigValues(c.cmaLister, c.dynamicInformers),
 	)
 	err = mgr.AddAgent(agentAddon)
 	if err != nil {
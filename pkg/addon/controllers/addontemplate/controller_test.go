@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	dynamicfake "k8s.io/client-go/dynamic/fake"
@@ -25,6 +26,7 @@ import (
 	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
 
 	addonindex "open-cluster-management.io/ocm/pkg/addon/index"
+	"open-cluster-management.io/ocm/pkg/addon/templateagent"
 	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
 	testinghelpers "open-cluster-management.io/ocm/pkg/registration/helpers/testing"
 )
@@ -414,3 +416,106 @@ func TestStopUnusedManagers(t *testing.T) {
 		})
 	}
 }
+
+func TestRestartManagerOnConfigResourcesChange(t *testing.T) {
+	var lock sync.Mutex
+	started := 0
+	// the stopped manager is held until released
+	release := make(chan struct{})
+	runController := func(ctx context.Context, addonName string) error {
+		lock.Lock()
+		started++
+		lock.Unlock()
+		<-ctx.Done()
+		<-release
+		return nil
+	}
+
+	cma := addontesting.NewClusterManagementAddon("test", "", "").WithSupportedConfigs(
+		addonv1alpha1.ConfigMeta{
+			ConfigGroupResource: addonv1alpha1.ConfigGroupResource{
+				Group:    utils.AddOnTemplateGVR.Group,
+				Resource: utils.AddOnTemplateGVR.Resource,
+			},
+			DefaultConfig: &addonv1alpha1.ConfigReferent{Name: "test"},
+		}).Build()
+	// the shared informers are started along with the manager, so the cma is in the client as well
+	fakeAddonClient := fakeaddon.NewSimpleClientset(cma)
+	addonInformers := addoninformers.NewSharedInformerFactory(fakeAddonClient, 10*time.Minute)
+	err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().AddIndexers(
+		cache.Indexers{
+			addonindex.ManagedClusterAddonByName: addonindex.IndexManagedClusterAddonByName,
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmaStore := addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().GetStore()
+	if err := cmaStore.Add(cma); err != nil {
+		t.Fatal(err)
+	}
+
+	fakeClusterClient := fakecluster.NewSimpleClientset()
+	fakeWorkClient := fakework.NewSimpleClientset()
+	controller := NewAddonTemplateController(
+		nil,
+		fakekube.NewSimpleClientset(),
+		fakeAddonClient,
+		fakeWorkClient,
+		addonInformers,
+		clusterv1informers.NewSharedInformerFactory(fakeClusterClient, 10*time.Minute),
+		dynamicinformer.NewDynamicSharedInformerFactory(dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), 0),
+		workinformers.NewSharedInformerFactory(fakeWorkClient, 10*time.Minute),
+		runController,
+	)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	syncAddon := func() {
+		if err := controller.Sync(ctx, testingcommon.NewFakeSyncContext(t, "test"), "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	startedTimes := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return started
+	}
+	assertStarted := func(expected int) {
+		assert.Eventually(t, func() bool {
+			return startedTimes() == expected
+		}, time.Second, 10*time.Millisecond, "expected the manager started %d times", expected)
+	}
+
+	syncAddon()
+	assertStarted(1)
+
+	// the manager is not restarted if the config resources are not changed
+	syncAddon()
+	assertStarted(1)
+
+	cma = cma.DeepCopy()
+	cma.Annotations = map[string]string{
+		templateagent.TemplateConfigsAnnotationKey: `[{"group": "example.com", "version": "v1", "resource": "loggings"}]`,
+	}
+	if _, err := fakeAddonClient.AddonV1alpha1().ClusterManagementAddOns().Update(
+		ctx, cma, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmaStore.Update(cma); err != nil {
+		t.Fatal(err)
+	}
+
+	// the new manager is not started until the stopped one exits
+	syncAddon()
+	syncAddon()
+	assert.Never(t, func() bool {
+		return startedTimes() != 1
+	}, 100*time.Millisecond, 10*time.Millisecond, "expected the manager not restarted before the stopped one exits")
+
+	close(release)
+	for i := 0; i < 100 && startedTimes() < 2; i++ {
+		syncAddon()
+		time.Sleep(10 * time.Millisecond)
+	}
+	assertStarted(2)
+}
@@ -25,6 +25,7 @@ import (
 	workv1informers "open-cluster-management.io/api/client/work/informers/externalversions"
 
 	"open-cluster-management.io/ocm/pkg/addon/controllers/addonconfiguration"
+	"open-cluster-management.io/ocm/pkg/addon/controllers/addonhubcleanup"
 	"open-cluster-management.io/ocm/pkg/addon/controllers/addonmanagement"
 	"open-cluster-management.io/ocm/pkg/addon/controllers/addonowner"
 	"open-cluster-management.io/ocm/pkg/addon/controllers/addonprogressing"
@@ -32,6 +33,7 @@ import (
 	"open-cluster-management.io/ocm/pkg/addon/controllers/addontokeninfra"
 	"open-cluster-management.io/ocm/pkg/addon/controllers/cmainstallprogression"
 	addonindex "open-cluster-management.io/ocm/pkg/addon/index"
+	"open-cluster-management.io/ocm/pkg/addon/templateagent"
 )
 
 func RunManager(ctx context.Context, controllerContext *controllercmd.ControllerContext) error {
@@ -150,8 +152,9 @@ func RunControllerManagerWithInformers(
 	// managementAddonConfigController
 	err = addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().AddIndexers(
 		cache.Indexers{
-			addonindex.ClusterManagementAddonByPlacement: addonindex.IndexClusterManagementAddonByPlacement, // addonConfigurationController, addonManagementController
-			index.ClusterManagementAddonByConfig:         index.IndexClusterManagementAddonByConfig,         // cmaConfigController
+			addonindex.ClusterManagementAddonByPlacement:       addonindex.IndexClusterManagementAddonByPlacement,       // addonConfigurationController, addonManagementController
+			index.ClusterManagementAddonByConfig:               index.IndexClusterManagementAddonByConfig,               // cmaConfigController
+			addonmanagement.ClusterManagementAddonByDependency: addonmanagement.IndexClusterManagementAddonByDependency, // addonManagementController
 		})
 	if err != nil {
 		return err
@@ -163,6 +166,7 @@ func RunControllerManagerWithInformers(
 		addonInformers.Addon().V1alpha1().ClusterManagementAddOns(),
 		clusterInformers.Cluster().V1beta1().Placements(),
 		clusterInformers.Cluster().V1beta1().PlacementDecisions(),
+		clusterInformers.Cluster().V1().ManagedClusters(),
 		utils.ManagedByAddonManager,
 	)
 
@@ -172,6 +176,7 @@ func RunControllerManagerWithInformers(
 		addonInformers.Addon().V1alpha1().ClusterManagementAddOns(),
 		clusterInformers.Cluster().V1beta1().Placements(),
 		clusterInformers.Cluster().V1beta1().PlacementDecisions(),
+		clusterInformers.Cluster().V1().ManagedClusters(),
 		utils.ManagedByAddonManager,
 	)
 
@@ -205,7 +210,7 @@ func RunControllerManagerWithInformers(
 		addonInformers,
 		clusterInformers,
 		// can share the same dynamic informers for different template type addons since
-		// these addons only support addontemplate and addondeploymentconfig
+		// the informers are created once per config resource
 		dynamicInformers,
 		workinformers,
 	)
@@ -219,6 +224,31 @@ func RunControllerManagerWithInformers(
 		tokenInfraInformers.Rbac().V1().RoleBindings(),
 	)
 
+	// the rolebindings granting the hub permissions to the agents of the template addons
+	hubPermissionBindingInformers := informers.NewSharedInformerFactoryWithOptions(hubKubeClient, 10*time.Minute,
+		informers.WithTweakListOptions(func(listOptions *metav1.ListOptions) {
+			selector := &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{
+						Key:      templateagent.AddonTemplateLabelKey,
+						Operator: metav1.LabelSelectorOpExists,
+					},
+				},
+			}
+			listOptions.LabelSelector = metav1.FormatLabelSelector(selector)
+		}),
+	)
+
+	addonHubCleanupController := addonhubcleanup.NewAddonHubCleanupController(
+		hubKubeClient,
+		hubAddOnClient,
+		addonInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+		hubPermissionBindingInformers.Rbac().V1().RoleBindings(),
+		tokenInfraInformers.Core().V1().ServiceAccounts(),
+		tokenInfraInformers.Rbac().V1().Roles(),
+		tokenInfraInformers.Rbac().V1().RoleBindings(),
+	)
+
 	go addonManagementController.Run(ctx, 2)
 	go addonConfigurationController.Run(ctx, 2)
 	go addonOwnerController.Run(ctx, 2)
@@ -228,12 +258,14 @@ func RunControllerManagerWithInformers(
 	// start a goroutine for each template-type addon it watches.
 	go addonTemplateController.Run(ctx, 1)
 	go tokenInfrastructureController.Run(ctx, 1)
+	go addonHubCleanupController.Run(ctx, 2)
 
 	clusterInformers.Start(ctx.Done())
 	addonInformers.Start(ctx.Done())
 	workinformers.Start(ctx.Done())
 	dynamicInformers.Start(ctx.Done())
 	tokenInfraInformers.Start(ctx.Done())
+	hubPermissionBindingInformers.Start(ctx.Done())
 
 	<-ctx.Done()
 	return nil
@@ -0,0 +1,41 @@
+package templateagent
+
+import (
+	"github.com/google/cel-go/cel"
+	"k8s.io/utils/lru"
+)
+
+// celProgramCacheSize is the max number of the compiled programs kept by a celProgramCache, the least recently used
+// programs are evicted once it is exceeded, so the programs of the expressions no longer used by any template or
+// config are dropped eventually.
+const celProgramCacheSize = 1024
+
+// celProgramCache caches the compiled CEL programs by the expression, so an expression of the templates and the
+// configs is compiled once instead of on every validation and evaluation. The programs are safe for concurrent use.
+type celProgramCache struct {
+	compile func(expression string) (cel.Program, error)
+
+	programs *lru.Cache
+}
+
+func newCELProgramCache(compile func(expression string) (cel.Program, error)) *celProgramCache {
+	return &celProgramCache{
+		compile:  compile,
+		programs: lru.New(celProgramCacheSize),
+	}
+}
+
+// get returns the compiled program of the expression, the expression failing to compile is not cached.
+func (c *celProgramCache) get(expression string) (cel.Program, error) {
+	if program, ok := c.programs.Get(expression); ok {
+		return program.(cel.Program), nil
+	}
+
+	program, err := c.compile(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	c.programs.Add(expression, program)
+	return program, nil
+}
@@ -30,19 +30,24 @@ type namespaceDecorator struct {
 }
 
 func newNamespaceDecorator(privateValues addonfactory.Values) *namespaceDecorator {
-	decorator := &namespaceDecorator{
+	var installNamespace string
+	namespace, ok := privateValues[InstallNamespacePrivateValueKey]
+	if ok {
+		installNamespace = namespace.(string)
+	}
+
+	return newNamespaceDecoratorWithNamespace(installNamespace)
+}
+
+func newNamespaceDecoratorWithNamespace(installNamespace string) *namespaceDecorator {
+	return &namespaceDecorator{
+		installNamespace: installNamespace,
 		paths: map[string][]string{
 			"ClusterRoleBinding": {"subjects", "namespace"},
 			"RoleBinding":        {"subjects", "namespace"},
 			"Namespace":          {"metadata", "name"},
 		},
 	}
-	namespace, ok := privateValues[InstallNamespacePrivateValueKey]
-	if ok {
-		decorator.installNamespace = namespace.(string)
-	}
-
-	return decorator
 }
 
 func (d *namespaceDecorator) decorate(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
@@ -98,17 +103,18 @@ func newDeploymentDecorator(
 	template *addonapiv1alpha1.AddOnTemplate,
 	orderedValues orderedValues,
 	privateValues addonfactory.Values,
+	additionalDecorators ...podTemplateSpecDecorator,
 ) decorator {
 	return &deploymentDecorator{
 		logger: logger,
-		decorators: []podTemplateSpecDecorator{
+		decorators: append([]podTemplateSpecDecorator{
 			newEnvironmentDecorator(orderedValues),
 			newVolumeDecorator(addonName, template),
 			newNodePlacementDecorator(privateValues),
 			newImageDecorator(privateValues),
 			newProxyHandler(logger, addonName, privateValues),
 			newResourceRequirementsDecorator(logger, supportResourceDeployment, privateValues),
-		},
+		}, additionalDecorators...),
 	}
 }
 
@@ -145,17 +151,18 @@ func newDaemonSetDecorator(
 	template *addonapiv1alpha1.AddOnTemplate,
 	orderedValues orderedValues,
 	privateValues addonfactory.Values,
+	additionalDecorators ...podTemplateSpecDecorator,
 ) decorator {
 	return &daemonSetDecorator{
 		logger: logger,
-		decorators: []podTemplateSpecDecorator{
+		decorators: append([]podTemplateSpecDecorator{
 			newEnvironmentDecorator(orderedValues),
 			newVolumeDecorator(addonName, template),
 			newNodePlacementDecorator(privateValues),
 			newImageDecorator(privateValues),
 			newProxyHandler(logger, addonName, privateValues),
 			newResourceRequirementsDecorator(logger, supportResourceDaemonset, privateValues),
-		},
+		}, additionalDecorators...),
 	}
 }
 
@@ -227,6 +234,11 @@ func newVolumeDecorator(addonName string, template *addonapiv1alpha1.AddOnTempla
 }
 
 func (d *volumeDecorator) decorate(_ string, pod *corev1.PodTemplateSpec) error {
+	// the template is not set for the workloads which do not run the addon agent, e.g. the workloads on the managed
+	// cluster in the Hosted mode, since the secrets of the registrations are not on the managed cluster.
+	if d.template == nil {
+		return nil
+	}
 
 	volumeMounts := []corev1.VolumeMount{}
 	volumes := []corev1.Volume{}
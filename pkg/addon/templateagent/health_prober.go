@@ -0,0 +1,278 @@
+package templateagent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	celconfig "k8s.io/apiserver/pkg/apis/cel"
+
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+	ocmcelcommon "open-cluster-management.io/sdk-go/pkg/cel/common"
+	ocmcellibrary "open-cluster-management.io/sdk-go/pkg/cel/library"
+)
+
+// HealthProberAnnotationKey is the annotation key of the AddOnTemplate defining how the health of the addon is
+// probed. The value is a json encoded HealthProber. The WorkloadAvailability prober, which checks the availability
+// of the deployments and daemonsets in the template, is used if the annotation is not set.
+const HealthProberAnnotationKey = "addon.open-cluster-management.io/health-prober"
+
+// HealthProber defines how the health of the template addon is probed.
+type HealthProber struct {
+	// Type is one of Lease, Work, WorkloadAvailability and DeploymentAvailability.
+	// - Lease: the addon agent is healthy if it keeps updating its lease on the managed cluster.
+	// - Work: the addon is healthy if the manifestworks are available, and if probeFields are set, the values
+	//   probed from the resources pass the health expression.
+	// - WorkloadAvailability and DeploymentAvailability: the deployments (and daemonsets) are available.
+	Type agent.HealthProberType `json:"type"`
+	// ProbeFields are the status fields of the resources in the template probed by the work agent with the
+	// feedback rules of the ManifestWork.
+	ProbeFields []HealthProbeField `json:"probeFields,omitempty"`
+	// HealthExpression is a CEL expression evaluating to a bool reporting whether the addon is healthy. The probed
+	// values are the variable results, a list of objects with the fields group, resource, namespace, name and
+	// values, which is a map of the name of the probed value to the value, e.g.
+	// results.all(r, r.values.succeeded >= 1). All the probe fields need to return values if it is not set.
+	HealthExpression string `json:"healthExpression,omitempty"`
+	// MessageExpression is a CEL expression evaluating to a string used as the message of the Available condition
+	// when the addon is unhealthy, with the same variable as the HealthExpression, e.g.
+	// 'job ' + results[0].name + ' failed ' + string(results[0].values.failed) + ' times'.
+	// The probed values are listed in the message if it is not set.
+	MessageExpression string `json:"messageExpression,omitempty"`
+}
+
+// HealthProbeField is the status fields of a resource probed by the work agent.
+type HealthProbeField struct {
+	ResourceIdentifier workapiv1.ResourceIdentifier `json:"resourceIdentifier"`
+	ProbeRules         []workapiv1.FeedbackRule     `json:"probeRules"`
+}
+
+// GetHealthProber returns the health prober defined by the template, nil if it is not defined.
+func GetHealthProber(template *addonapiv1alpha1.AddOnTemplate) (*HealthProber, error) {
+	value, ok := template.Annotations[HealthProberAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	prober := &HealthProber{}
+	if err := json.Unmarshal([]byte(value), prober); err != nil {
+		return nil, fmt.Errorf("invalid annotation %s of template %s: %v", HealthProberAnnotationKey, template.Name, err)
+	}
+	if err := prober.validate(); err != nil {
+		return nil, fmt.Errorf("invalid annotation %s of template %s: %v", HealthProberAnnotationKey, template.Name, err)
+	}
+	return prober, nil
+}
+
+func (p *HealthProber) validate() error {
+	switch p.Type {
+	case agent.HealthProberTypeLease, agent.HealthProberTypeWorkloadAvailability,
+		agent.HealthProberTypeDeploymentAvailability:
+		if len(p.ProbeFields) > 0 || len(p.HealthExpression) > 0 || len(p.MessageExpression) > 0 {
+			return fmt.Errorf("probeFields and expressions are only supported by the %s prober", agent.HealthProberTypeWork)
+		}
+		return nil
+	case agent.HealthProberTypeWork:
+	default:
+		return fmt.Errorf("unsupported health prober type %q", p.Type)
+	}
+
+	if len(p.ProbeFields) == 0 && (len(p.HealthExpression) > 0 || len(p.MessageExpression) > 0) {
+		return fmt.Errorf("probeFields are required by the expressions")
+	}
+	for _, field := range p.ProbeFields {
+		if len(field.ResourceIdentifier.Resource) == 0 || len(field.ResourceIdentifier.Name) == 0 {
+			return fmt.Errorf("resource and name of the probe field are required")
+		}
+		if len(field.ProbeRules) == 0 {
+			return fmt.Errorf("probe rules of %s %s are required", field.ResourceIdentifier.Resource, field.ResourceIdentifier.Name)
+		}
+	}
+	if len(p.HealthExpression) > 0 {
+		if _, err := healthExpressionProgram(p.HealthExpression, cel.BoolType); err != nil {
+			return fmt.Errorf("invalid health expression: %v", err)
+		}
+	}
+	if len(p.MessageExpression) > 0 {
+		if _, err := healthExpressionProgram(p.MessageExpression, cel.StringType); err != nil {
+			return fmt.Errorf("invalid message expression: %v", err)
+		}
+	}
+	return nil
+}
+
+// toAgentHealthProber converts the prober to the health prober of the addon framework.
+func (p *HealthProber) toAgentHealthProber() *agent.HealthProber {
+	if p.Type != agent.HealthProberTypeWork || len(p.ProbeFields) == 0 {
+		return &agent.HealthProber{Type: p.Type}
+	}
+
+	workProber := &agent.WorkHealthProber{HealthChecker: p.healthChecker}
+	for _, field := range p.ProbeFields {
+		workProber.ProbeFields = append(workProber.ProbeFields, agent.ProbeField{
+			ResourceIdentifier: field.ResourceIdentifier,
+			ProbeRules:         field.ProbeRules,
+		})
+	}
+	return &agent.HealthProber{Type: agent.HealthProberTypeWork, WorkProber: workProber}
+}
+
+// healthChecker evaluates the health of the addon with the values probed from the resources. The returned error is
+// the message of the Available condition of the addon.
+func (p *HealthProber) healthChecker(results []agent.FieldResult,
+	_ *clusterv1.ManagedCluster, _ *addonapiv1alpha1.ManagedClusterAddOn) error {
+	probed, err := probedResults(results)
+	if err != nil {
+		return err
+	}
+
+	if len(p.HealthExpression) == 0 {
+		if len(results) < len(p.ProbeFields) {
+			return fmt.Errorf("values are not probed from all the resources: %s", formatProbedResults(probed))
+		}
+		return nil
+	}
+
+	healthy, err := evaluateHealthExpression(p.HealthExpression, cel.BoolType, probed)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate the health expression: %v", err)
+	}
+	if healthy.(bool) {
+		return nil
+	}
+
+	if len(p.MessageExpression) > 0 {
+		message, err := evaluateHealthExpression(p.MessageExpression, cel.StringType, probed)
+		if err == nil {
+			return fmt.Errorf("%s", message)
+		}
+	}
+	return fmt.Errorf("health expression is not satisfied with the probed values: %s", formatProbedResults(probed))
+}
+
+// probedResults converts the feedback results to the variable of the expressions.
+func probedResults(results []agent.FieldResult) ([]interface{}, error) {
+	probed := []interface{}{}
+	for _, result := range results {
+		values := map[string]interface{}{}
+		for _, value := range result.FeedbackResult.Values {
+			switch {
+			case value.Value.Integer != nil:
+				values[value.Name] = *value.Value.Integer
+			case value.Value.String != nil:
+				values[value.Name] = *value.Value.String
+			case value.Value.Boolean != nil:
+				values[value.Name] = *value.Value.Boolean
+			case value.Value.JsonRaw != nil:
+				var raw interface{}
+				if err := json.Unmarshal([]byte(*value.Value.JsonRaw), &raw); err != nil {
+					return nil, fmt.Errorf("invalid json value %s of %s %s: %v",
+						value.Name, result.ResourceIdentifier.Resource, result.ResourceIdentifier.Name, err)
+				}
+				values[value.Name] = raw
+			}
+		}
+		probed = append(probed, map[string]interface{}{
+			"group":     result.ResourceIdentifier.Group,
+			"resource":  result.ResourceIdentifier.Resource,
+			"namespace": result.ResourceIdentifier.Namespace,
+			"name":      result.ResourceIdentifier.Name,
+			"values":    values,
+		})
+	}
+	return probed, nil
+}
+
+// formatProbedResults formats the probed values as resource namespace/name: name=value, ...
+func formatProbedResults(probed []interface{}) string {
+	var messages []string
+	for _, p := range probed {
+		result := p.(map[string]interface{})
+		values := result["values"].(map[string]interface{})
+		var pairs []string
+		for name, value := range values {
+			formatted, _ := formatValue(value)
+			pairs = append(pairs, fmt.Sprintf("%s=%s", name, formatted))
+		}
+		sort.Strings(pairs)
+
+		name := result["name"].(string)
+		if namespace := result["namespace"].(string); len(namespace) > 0 {
+			name = namespace + "/" + name
+		}
+		messages = append(messages, fmt.Sprintf("%s %s: %s", result["resource"], name, strings.Join(pairs, ", ")))
+	}
+	if len(messages) == 0 {
+		return "no values are probed"
+	}
+	return strings.Join(messages, "; ")
+}
+
+var (
+	// healthExpressionPrograms and messageExpressionPrograms cache the compiled programs of the health expressions
+	// and the message expressions, so they are compiled once and not on every health check.
+	healthExpressionPrograms = newCELProgramCache(func(expression string) (cel.Program, error) {
+		return compileHealthExpression(expression, cel.BoolType)
+	})
+	messageExpressionPrograms = newCELProgramCache(func(expression string) (cel.Program, error) {
+		return compileHealthExpression(expression, cel.StringType)
+	})
+)
+
+func healthExpressionProgram(expression string, outputType *cel.Type) (cel.Program, error) {
+	if outputType == cel.BoolType {
+		return healthExpressionPrograms.get(expression)
+	}
+	return messageExpressionPrograms.get(expression)
+}
+
+func compileHealthExpression(expression string, outputType *cel.Type) (cel.Program, error) {
+	env, err := cel.NewEnv(slices.Concat(
+		[]cel.EnvOption{cel.Variable("results", cel.ListType(cel.DynType)), ocmcellibrary.JsonLib()},
+		ocmcelcommon.BaseEnvOpts,
+	)...)
+	if err != nil {
+		return nil, err
+	}
+	ast, iss := env.Compile(expression)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+	if ast.OutputType() != outputType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("expected %v result of expression, got %v", outputType, ast.OutputType())
+	}
+	return env.Program(ast,
+		cel.CostLimit(celconfig.PerCallLimit),
+		cel.InterruptCheckFrequency(celconfig.CheckFrequency),
+	)
+}
+
+func evaluateHealthExpression(expression string, outputType *cel.Type, probed []interface{}) (interface{}, error) {
+	program, err := healthExpressionProgram(expression, outputType)
+	if err != nil {
+		return nil, err
+	}
+	result, _, err := program.ContextEval(context.Background(), map[string]interface{}{"results": probed})
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := result.(type) {
+	case types.Bool:
+		if outputType == cel.BoolType {
+			return bool(v), nil
+		}
+	case types.String:
+		if outputType == cel.StringType {
+			return string(v), nil
+		}
+	}
+	return nil, fmt.Errorf("expected %v result of expression, got %v", outputType, result.Type())
+}
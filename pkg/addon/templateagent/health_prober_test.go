@@ -0,0 +1,173 @@
+package templateagent
+
+import (
+	"testing"
+
+	"k8s.io/utils/ptr"
+
+	"open-cluster-management.io/addon-framework/pkg/agent"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+func TestGetHealthProber(t *testing.T) {
+	cases := []struct {
+		name         string
+		annotation   string
+		expectedErr  bool
+		expectedType agent.HealthProberType
+		expectedWork bool
+	}{
+		{
+			name: "no prober",
+		},
+		{
+			name:        "invalid json",
+			annotation:  "invalid",
+			expectedErr: true,
+		},
+		{
+			name:        "unsupported type",
+			annotation:  `{"type": "Unknown"}`,
+			expectedErr: true,
+		},
+		{
+			name:        "probe fields of lease prober",
+			annotation:  `{"type": "Lease", "probeFields": [{"resourceIdentifier": {"resource": "jobs", "name": "a"}}]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "expression without probe fields",
+			annotation:  `{"type": "Work", "healthExpression": "true"}`,
+			expectedErr: true,
+		},
+		{
+			name: "invalid health expression",
+			annotation: `{"type": "Work", "probeFields": [{"resourceIdentifier": {"group": "batch", "resource": "jobs", "name": "a"}, ` +
+				`"probeRules": [{"type": "WellKnownStatus"}]}], "healthExpression": "'a'"}`,
+			expectedErr: true,
+		},
+		{
+			name:         "lease",
+			annotation:   `{"type": "Lease"}`,
+			expectedType: agent.HealthProberTypeLease,
+		},
+		{
+			name:         "work",
+			annotation:   `{"type": "Work"}`,
+			expectedType: agent.HealthProberTypeWork,
+		},
+		{
+			name: "work with probe fields",
+			annotation: `{"type": "Work", "probeFields": [{"resourceIdentifier": {"group": "batch", "resource": "jobs", "name": "a"}, ` +
+				`"probeRules": [{"type": "WellKnownStatus"}]}], "healthExpression": "results.all(r, r.values.succeeded >= 1)"}`,
+			expectedType: agent.HealthProberTypeWork,
+			expectedWork: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			template := &addonapiv1alpha1.AddOnTemplate{}
+			if len(c.annotation) > 0 {
+				template.Annotations = map[string]string{HealthProberAnnotationKey: c.annotation}
+			}
+			prober, err := GetHealthProber(template)
+			if c.expectedErr != (err != nil) {
+				t.Fatalf("expected error %v, but got %v", c.expectedErr, err)
+			}
+			if prober == nil {
+				if len(c.expectedType) > 0 {
+					t.Fatalf("expected prober %s, but got nil", c.expectedType)
+				}
+				return
+			}
+
+			agentProber := prober.toAgentHealthProber()
+			if agentProber.Type != c.expectedType {
+				t.Errorf("expected type %s, but got %s", c.expectedType, agentProber.Type)
+			}
+			if c.expectedWork != (agentProber.WorkProber != nil) {
+				t.Errorf("expected work prober %v, but got %v", c.expectedWork, agentProber.WorkProber)
+			}
+		})
+	}
+}
+
+func TestHealthChecker(t *testing.T) {
+	jobIdentifier := workapiv1.ResourceIdentifier{Group: "batch", Resource: "jobs", Namespace: "ns", Name: "init"}
+	probeFields := []HealthProbeField{
+		{
+			ResourceIdentifier: jobIdentifier,
+			ProbeRules:         []workapiv1.FeedbackRule{{Type: workapiv1.WellKnownStatusType}},
+		},
+	}
+	newResult := func(succeeded, failed int64) agent.FieldResult {
+		return agent.FieldResult{
+			ResourceIdentifier: jobIdentifier,
+			FeedbackResult: workapiv1.StatusFeedbackResult{
+				Values: []workapiv1.FeedbackValue{
+					{Name: "succeeded", Value: workapiv1.FieldValue{Type: workapiv1.Integer, Integer: ptr.To(succeeded)}},
+					{Name: "failed", Value: workapiv1.FieldValue{Type: workapiv1.Integer, Integer: ptr.To(failed)}},
+					{Name: "conditions", Value: workapiv1.FieldValue{Type: workapiv1.JsonRaw, JsonRaw: ptr.To(`[{"type":"Complete"}]`)}},
+				},
+			},
+		}
+	}
+
+	cases := []struct {
+		name            string
+		prober          HealthProber
+		results         []agent.FieldResult
+		expectedMessage string
+	}{
+		{
+			name:    "no expression",
+			prober:  HealthProber{Type: agent.HealthProberTypeWork, ProbeFields: probeFields},
+			results: []agent.FieldResult{newResult(0, 0)},
+		},
+		{
+			name: "healthy",
+			prober: HealthProber{Type: agent.HealthProberTypeWork, ProbeFields: probeFields,
+				HealthExpression: "results.all(r, r.values.succeeded >= 1 && r.values.conditions[0].type == 'Complete')"},
+			results: []agent.FieldResult{newResult(1, 0)},
+		},
+		{
+			name: "unhealthy with probed values",
+			prober: HealthProber{Type: agent.HealthProberTypeWork, ProbeFields: probeFields,
+				HealthExpression: "results.all(r, r.values.succeeded >= 1)"},
+			results: []agent.FieldResult{newResult(0, 2)},
+			expectedMessage: "health expression is not satisfied with the probed values: " +
+				`jobs ns/init: conditions=[{"type":"Complete"}], failed=2, succeeded=0`,
+		},
+		{
+			name: "unhealthy with message expression",
+			prober: HealthProber{Type: agent.HealthProberTypeWork, ProbeFields: probeFields,
+				HealthExpression:  "results.all(r, r.values.succeeded >= 1)",
+				MessageExpression: "'job ' + results[0].name + ' failed ' + string(results[0].values.failed) + ' times'"},
+			results:         []agent.FieldResult{newResult(0, 2)},
+			expectedMessage: "job init failed 2 times",
+		},
+		{
+			name: "expression error",
+			prober: HealthProber{Type: agent.HealthProberTypeWork, ProbeFields: probeFields,
+				HealthExpression: "results.all(r, r.values.active >= 1)"},
+			results:         []agent.FieldResult{newResult(0, 2)},
+			expectedMessage: "failed to evaluate the health expression: no such key: active",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			prober := c.prober.toAgentHealthProber()
+			err := prober.WorkProber.HealthChecker(c.results, nil, nil)
+			var message string
+			if err != nil {
+				message = err.Error()
+			}
+			if message != c.expectedMessage {
+				t.Errorf("expected message %q, but got %q", c.expectedMessage, message)
+			}
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package templateagent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasttemplate"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+// HelmChartAnnotationKey is the annotation key of the AddOnTemplate referencing a helm chart as the source of the
+// agent manifests. The value is a json encoded HelmChartSource. The objects rendered from the chart are deployed
+// together with the manifests in the spec.agentSpec.workload of the template.
+const HelmChartAnnotationKey = "addon.open-cluster-management.io/helm-chart"
+
+const (
+	// defaultAgentInstallNamespace is the namespace the agent is installed in if it is not configured.
+	defaultAgentInstallNamespace = "open-cluster-management-agent-addon"
+	// defaultChartArchiveKey is the default key of the chart archive in the ConfigMap or Secret.
+	defaultChartArchiveKey = "chart.tgz"
+	// chartLoadTimeout is the timeout to load a chart, including pulling it from the OCI registry.
+	chartLoadTimeout = time.Minute
+)
+
+// HelmChartSource references a helm chart and defines how the values of the chart are built for each cluster.
+type HelmChartSource struct {
+	// OCI is the reference of the chart in an OCI registry, e.g. oci://quay.io/open-cluster-management/charts/foo:1.0.0
+	OCI string `json:"oci,omitempty"`
+	// ConfigMap references the chart archive stored in a ConfigMap on the hub. The ConfigMap must be in the chart
+	// namespace of the addon manager, which is used if the namespace is not set.
+	ConfigMap *ChartArchiveReference `json:"configMap,omitempty"`
+	// Secret references the chart archive stored in a Secret on the hub. The Secret must be in the chart namespace
+	// of the addon manager, which is used if the namespace is not set.
+	Secret *ChartArchiveReference `json:"secret,omitempty"`
+	// ReleaseName is the release name used to render the chart, the addon name is used if it is not set.
+	ReleaseName string `json:"releaseName,omitempty"`
+	// Values is the preset values of the chart. The {{VARIABLE}} in string values is substituted with the variables
+	// of the template, including the built-in variables and the customized variables in AddOnDeploymentConfig.
+	Values map[string]interface{} `json:"values,omitempty"`
+	// ValuesMapping sets the values of the chart from the variables and the labels/claims of the cluster.
+	ValuesMapping []HelmValueMapping `json:"valuesMapping,omitempty"`
+}
+
+// ChartArchiveReference references the chart archive (.tgz) stored in a key of a ConfigMap or Secret.
+type ChartArchiveReference struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Key is the key of the chart archive, chart.tgz is used if it is not set.
+	Key string `json:"key,omitempty"`
+}
+
+// HelmValueMapping sets the value at Path with exactly one of Variable, ClusterLabel or ClusterClaim. The mapping is
+// skipped if the source has no value for the cluster, so the value of the chart is used.
+type HelmValueMapping struct {
+	// Path is the dot separated path of the value, e.g. image.tag
+	Path         string `json:"path"`
+	Variable     string `json:"variable,omitempty"`
+	ClusterLabel string `json:"clusterLabel,omitempty"`
+	ClusterClaim string `json:"clusterClaim,omitempty"`
+}
+
+// GetHelmChartSource returns the helm chart source of the template, nil if the template does not reference a chart.
+func GetHelmChartSource(template *addonapiv1alpha1.AddOnTemplate) (*HelmChartSource, error) {
+	value, ok := template.Annotations[HelmChartAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	source := &HelmChartSource{}
+	if err := json.Unmarshal([]byte(value), source); err != nil {
+		return nil, fmt.Errorf("invalid annotation %s: %v", HelmChartAnnotationKey, err)
+	}
+	if err := source.validate(); err != nil {
+		return nil, fmt.Errorf("invalid annotation %s: %v", HelmChartAnnotationKey, err)
+	}
+	return source, nil
+}
+
+func (s *HelmChartSource) validate() error {
+	count := 0
+	if len(s.OCI) > 0 {
+		if _, _, _, err := parseOCIReference(s.OCI); err != nil {
+			return err
+		}
+		count++
+	}
+	for _, ref := range []*ChartArchiveReference{s.ConfigMap, s.Secret} {
+		if ref == nil {
+			continue
+		}
+		if len(ref.Name) == 0 {
+			return fmt.Errorf("name of the chart archive is required")
+		}
+		// the addon manager is only allowed to read the chart archives in the chart namespace, so the templates
+		// cannot expose the configmaps and secrets in the other namespaces to the managed clusters
+		if len(ref.Namespace) > 0 && ref.Namespace != AddonChartNamespace() {
+			return fmt.Errorf("the chart archive must be in namespace %s", AddonChartNamespace())
+		}
+		count++
+	}
+	if count != 1 {
+		return fmt.Errorf("exactly one of oci, configMap and secret is required")
+	}
+
+	for _, mapping := range s.ValuesMapping {
+		if len(mapping.Path) == 0 {
+			return fmt.Errorf("path of the values mapping is required")
+		}
+		count = 0
+		for _, from := range []string{mapping.Variable, mapping.ClusterLabel, mapping.ClusterClaim} {
+			if len(from) > 0 {
+				count++
+			}
+		}
+		if count != 1 {
+			return fmt.Errorf("exactly one of variable, clusterLabel and clusterClaim is required for path %s", mapping.Path)
+		}
+	}
+	return nil
+}
+
+type cachedChart struct {
+	// lock serializes the loading of the chart, so a chart is pulled once by the concurrent renderings without
+	// blocking the loading of the other charts.
+	lock    sync.Mutex
+	version string
+	chart   *chart.Chart
+}
+
+// helmChartLoader loads the charts of the templates. The loaded charts are cached by the version of the source,
+// the resource version for the ConfigMap/Secret and the reference for OCI, so an OCI reference should be pinned
+// to an immutable tag or a digest.
+type helmChartLoader struct {
+	kubeClient kubernetes.Interface
+	httpClient *http.Client
+
+	lock  sync.Mutex
+	cache map[string]*cachedChart
+}
+
+func newHelmChartLoader(kubeClient kubernetes.Interface) *helmChartLoader {
+	return &helmChartLoader{
+		kubeClient: kubeClient,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+			// the registries redirect the blob requests to the storage, only https is followed
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if req.URL.Scheme != "https" {
+					return fmt.Errorf("redirect to %s is not allowed", req.URL.Redacted())
+				}
+				if len(via) >= 10 {
+					return fmt.Errorf("stopped after 10 redirects")
+				}
+				return nil
+			},
+		},
+		cache: map[string]*cachedChart{},
+	}
+}
+
+func (l *helmChartLoader) load(ctx context.Context, source *HelmChartSource) (*chart.Chart, error) {
+	var key, version string
+	var archive func() ([]byte, error)
+	switch {
+	case len(source.OCI) > 0:
+		key, version = "oci", source.OCI
+		archive = func() ([]byte, error) {
+			return l.pullOCIChart(ctx, source.OCI)
+		}
+	case source.ConfigMap != nil:
+		ref := source.ConfigMap
+		cm, err := l.kubeClient.CoreV1().ConfigMaps(AddonChartNamespace()).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		key = fmt.Sprintf("configmap/%s/%s/%s", cm.Namespace, ref.Name, ref.key())
+		version = cm.ResourceVersion
+		archive = func() ([]byte, error) {
+			if data, ok := cm.BinaryData[ref.key()]; ok {
+				return data, nil
+			}
+			return nil, fmt.Errorf("chart archive %s is not found in configmap %s/%s", ref.key(), cm.Namespace, ref.Name)
+		}
+	case source.Secret != nil:
+		ref := source.Secret
+		secret, err := l.kubeClient.CoreV1().Secrets(AddonChartNamespace()).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		key = fmt.Sprintf("secret/%s/%s/%s", secret.Namespace, ref.Name, ref.key())
+		version = secret.ResourceVersion
+		archive = func() ([]byte, error) {
+			if data, ok := secret.Data[ref.key()]; ok {
+				return data, nil
+			}
+			return nil, fmt.Errorf("chart archive %s is not found in secret %s/%s", ref.key(), secret.Namespace, ref.Name)
+		}
+	default:
+		return nil, fmt.Errorf("no chart source is specified")
+	}
+
+	l.lock.Lock()
+	cached, ok := l.cache[key]
+	if !ok {
+		cached = &cachedChart{}
+		l.cache[key] = cached
+	}
+	l.lock.Unlock()
+
+	cached.lock.Lock()
+	defer cached.lock.Unlock()
+	if cached.chart != nil && cached.version == version {
+		return cached.chart, nil
+	}
+
+	data, err := archive()
+	if err != nil {
+		return nil, err
+	}
+	helmChart, err := loader.LoadArchive(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart %s: %v", key, err)
+	}
+	cached.version, cached.chart = version, helmChart
+	return helmChart, nil
+}
+
+func (r *ChartArchiveReference) key() string {
+	if len(r.Key) == 0 {
+		return defaultChartArchiveKey
+	}
+	return r.Key
+}
+
+// renderHelmChart renders the chart of the template for the cluster, the CRDs of the chart are at the top of the
+// returned objects. The hooks, including the tests, of the chart are not rendered since they are run by the helm
+// release lifecycle which does not exist for the addons.
+func (a *CRDTemplateAgentAddon) renderHelmChart(
+	cluster *clusterv1.ManagedCluster,
+	source *HelmChartSource,
+	configValues map[string]interface{}) ([]*unstructured.Unstructured, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), chartLoadTimeout)
+	defer cancel()
+	helmChart, err := a.chartLoader.load(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := helmChartValues(cluster, source, configValues)
+	if err != nil {
+		return nil, err
+	}
+
+	releaseOptions := chartutil.ReleaseOptions{
+		Name:      source.ReleaseName,
+		Namespace: defaultAgentInstallNamespace,
+		IsInstall: true,
+	}
+	if len(releaseOptions.Name) == 0 {
+		releaseOptions.Name = a.addonName
+	}
+	if namespace, ok := configValues["INSTALL_NAMESPACE"].(string); ok && len(namespace) > 0 {
+		releaseOptions.Namespace = namespace
+	}
+
+	renderValues, err := chartutil.ToRenderValues(helmChart, values, releaseOptions, helmCapabilities(cluster))
+	if err != nil {
+		return nil, err
+	}
+	templates, err := engine.Render(helmChart, renderValues)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render chart %s: %v", helmChart.Name(), err)
+	}
+
+	var manifests []string
+	for _, crd := range helmChart.CRDObjects() {
+		manifests = append(manifests, string(crd.File.Data))
+	}
+	for name := range templates {
+		if strings.HasSuffix(name, "NOTES.txt") {
+			delete(templates, name)
+		}
+	}
+	// the hooks are dropped and the manifests are sorted in the install order of helm
+	_, sortedManifests, err := releaseutil.SortManifests(templates, nil, releaseutil.InstallOrder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sort the manifests rendered by chart %s: %v", helmChart.Name(), err)
+	}
+	for _, manifest := range sortedManifests {
+		manifests = append(manifests, manifest.Content)
+	}
+
+	var objects []*unstructured.Unstructured
+	for _, manifest := range manifests {
+		object := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(manifest), &object.Object); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal the manifest rendered by chart %s: %v", helmChart.Name(), err)
+		}
+		// skip the empty documents
+		if len(object.Object) == 0 || len(object.GetKind()) == 0 {
+			continue
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// helmCapabilities returns the capabilities to render the charts with the kubernetes version of the cluster, the
+// default capabilities are used if the version of the cluster is unknown.
+func helmCapabilities(cluster *clusterv1.ManagedCluster) *chartutil.Capabilities {
+	capabilities := chartutil.DefaultCapabilities.Copy()
+	kubeVersion, err := chartutil.ParseKubeVersion(cluster.Status.Version.Kubernetes)
+	if err != nil {
+		return capabilities
+	}
+	capabilities.KubeVersion = *kubeVersion
+	return capabilities
+}
+
+// helmChartValues builds the values of the chart for the cluster. The preset values are substituted with the
+// variables and then overridden by the values mapping.
+func helmChartValues(
+	cluster *clusterv1.ManagedCluster,
+	source *HelmChartSource,
+	configValues map[string]interface{}) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if source.Values != nil {
+		values = substituteVariables(runtime.DeepCopyJSON(source.Values), configValues).(map[string]interface{})
+	}
+
+	for _, mapping := range source.ValuesMapping {
+		value, ok := "", false
+		switch {
+		case len(mapping.Variable) > 0:
+			value, ok = configValues[mapping.Variable].(string)
+		case len(mapping.ClusterLabel) > 0:
+			value, ok = cluster.Labels[mapping.ClusterLabel]
+		case len(mapping.ClusterClaim) > 0:
+			for _, claim := range cluster.Status.ClusterClaims {
+				if claim.Name == mapping.ClusterClaim {
+					value, ok = claim.Value, true
+					break
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		if err := unstructured.SetNestedField(values, value, strings.Split(mapping.Path, ".")...); err != nil {
+			return nil, fmt.Errorf("failed to set value %s: %v", mapping.Path, err)
+		}
+	}
+	return values, nil
+}
+
+// substituteVariables replaces the {{VARIABLE}} in the string values with the variables.
+func substituteVariables(value interface{}, variables map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return fasttemplate.New(v, "{{", "}}").ExecuteString(variables)
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = substituteVariables(item, variables)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = substituteVariables(item, variables)
+		}
+	}
+	return value
+}
@@ -0,0 +1,216 @@
+package templateagent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	ociManifestMediaType            = "application/vnd.oci.image.manifest.v1+json"
+	helmChartContentMediaType       = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	maxOCIResponseSize        int64 = 32 * 1024 * 1024
+)
+
+// parseOCIReference parses an oci://<host>/<repository>:<tag> or oci://<host>/<repository>@<digest> reference.
+func parseOCIReference(ref string) (host, repository, reference string, err error) {
+	if !strings.HasPrefix(ref, "oci://") {
+		return "", "", "", fmt.Errorf("invalid oci reference %q: oci:// scheme is required", ref)
+	}
+	name := strings.TrimPrefix(ref, "oci://")
+
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, reference = name[:i], name[i+1:]
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, reference = name[:i], name[i+1:]
+	}
+	host, repository, _ = strings.Cut(name, "/")
+	if len(host) == 0 || len(repository) == 0 || len(reference) == 0 {
+		return "", "", "", fmt.Errorf("invalid oci reference %q: host, repository and tag or digest are required", ref)
+	}
+	return host, repository, reference, nil
+}
+
+// pullOCIChart pulls the chart archive from an OCI registry with the OCI distribution API. Only the anonymous
+// access, including the anonymous bearer token of the public registries, is supported.
+func (l *helmChartLoader) pullOCIChart(ctx context.Context, ref string) ([]byte, error) {
+	host, repository, reference, err := parseOCIReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := l.ociGet(ctx, host, fmt.Sprintf("https://%s/v2/%s/manifests/%s", host, repository, reference), ociManifestMediaType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the manifest of %s: %v", ref, err)
+	}
+	manifest := struct {
+		Layers []struct {
+			MediaType string `json:"mediaType"`
+			Digest    string `json:"digest"`
+		} `json:"layers"`
+	}{}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest of %s: %v", ref, err)
+	}
+
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != helmChartContentMediaType {
+			continue
+		}
+		data, err := l.ociGet(ctx, host, fmt.Sprintf("https://%s/v2/%s/blobs/%s", host, repository, layer.Digest), "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the chart of %s: %v", ref, err)
+		}
+		sum := sha256.Sum256(data)
+		if digest := "sha256:" + hex.EncodeToString(sum[:]); digest != layer.Digest {
+			return nil, fmt.Errorf("digest of the chart %s mismatch, expected %s, got %s", ref, layer.Digest, digest)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("no chart layer is found in %s", ref)
+}
+
+// ociGet gets the content of the url from the registry host, it requests an anonymous bearer token and retries if
+// it is challenged.
+func (l *helmChartLoader) ociGet(ctx context.Context, host, rawURL, accept string) ([]byte, error) {
+	resp, err := l.httpGet(ctx, rawURL, accept, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		token, err := l.ociToken(ctx, host, challenge)
+		if err != nil {
+			return nil, err
+		}
+		if resp, err = l.httpGet(ctx, rawURL, accept, token); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxOCIResponseSize))
+}
+
+// ociToken requests an anonymous token with the bearer challenge of the registry host. The realm of the challenge
+// must be an https url on the registry host or a host in the same domain, so the registry cannot make the addon
+// manager request an arbitrary url.
+func (l *helmChartLoader) ociToken(ctx context.Context, host, challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+
+	query := url.Values{}
+	var realm string
+	for _, param := range strings.Split(params, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"`)
+		if key == "realm" {
+			realm = value
+			continue
+		}
+		query.Set(key, value)
+	}
+	if len(realm) == 0 {
+		return "", fmt.Errorf("no realm in the authentication challenge %q", challenge)
+	}
+	realmURL, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("invalid realm %q: %v", realm, err)
+	}
+	if realmURL.Scheme != "https" || !isAllowedRealmHost(host, realmURL.Host) {
+		return "", fmt.Errorf("realm %q is not allowed for registry %s", realm, host)
+	}
+	realmURL.RawQuery = query.Encode()
+
+	resp, err := l.httpGet(ctx, realmURL.String(), "", "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get token: unexpected status %s", resp.Status)
+	}
+
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxOCIResponseSize)).Decode(&token); err != nil {
+		return "", err
+	}
+	if len(token.Token) > 0 {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+func (l *helmChartLoader) httpGet(ctx context.Context, rawURL, accept, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(accept) > 0 {
+		req.Header.Set("Accept", accept)
+	}
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return l.httpClient.Do(req)
+}
+
+// isAllowedRealmHost returns true if the realm host is the registry host or in the same parent domain of the
+// registry host, e.g. auth.docker.io for registry-1.docker.io. The IP hosts must be the same.
+func isAllowedRealmHost(registryHost, realmHost string) bool {
+	if registryHost == realmHost {
+		return true
+	}
+
+	registryHostname, realmHostname := hostname(registryHost), hostname(realmHost)
+	if registryHostname == realmHostname {
+		return true
+	}
+	if net.ParseIP(registryHostname) != nil || net.ParseIP(realmHostname) != nil {
+		return false
+	}
+	registryDomain, ok := parentDomain(registryHostname)
+	if !ok {
+		return false
+	}
+	realmDomain, ok := parentDomain(realmHostname)
+	return ok && registryDomain == realmDomain
+}
+
+// hostname returns the host without the port.
+func hostname(host string) string {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		return name
+	}
+	return host
+}
+
+// parentDomain returns the last two labels of the hostname.
+func parentDomain(hostname string) (string, bool) {
+	labels := strings.Split(strings.TrimSuffix(hostname, "."), ".")
+	if len(labels) < 2 {
+		return "", false
+	}
+	return strings.Join(labels[len(labels)-2:], "."), true
+}
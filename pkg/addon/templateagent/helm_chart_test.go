@@ -0,0 +1,328 @@
+package templateagent
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/klog/v2/ktesting"
+
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+const testChartDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Release.Name }}-agent
+  namespace: {{ .Release.Namespace }}
+  labels:
+    region: {{ .Values.region | quote }}
+    kube-version: {{ .Capabilities.KubeVersion.Version | quote }}
+spec:
+  selector:
+    matchLabels:
+      app: hello
+  template:
+    metadata:
+      labels:
+        app: hello
+    spec:
+      containers:
+      - name: hello
+        image: "{{ .Values.image.repository }}:{{ .Values.image.tag }}"
+        args: ["--cluster={{ .Values.clusterName }}"]
+---
+# empty document
+`
+
+const testChartTestPod = `apiVersion: v1
+kind: Pod
+metadata:
+  name: {{ .Release.Name }}-test
+  annotations:
+    helm.sh/hook: test
+spec:
+  containers:
+  - name: test
+    image: busybox
+`
+
+func newTestChartArchive(t *testing.T) []byte {
+	files := map[string]string{
+		"hello/Chart.yaml":                   "apiVersion: v2\nname: hello\nversion: 0.1.0\n",
+		"hello/values.yaml":                  "image:\n  repository: quay.io/ocm/hello\n  tag: latest\nregion: unknown\nclusterName: \"\"\n",
+		"hello/templates/deployment.yaml":    testChartDeployment,
+		"hello/templates/NOTES.txt":          "hello",
+		"hello/templates/tests/test.yaml":    testChartTestPod,
+		"hello/crds/hellos.example.com.yaml": "apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: hellos.example.com\n",
+	}
+
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGetHelmChartSource(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotation  string
+		expectedErr bool
+		expected    *HelmChartSource
+	}{
+		{
+			name: "no chart",
+		},
+		{
+			name:        "invalid json",
+			annotation:  "invalid",
+			expectedErr: true,
+		},
+		{
+			name:        "no source",
+			annotation:  `{"releaseName": "hello"}`,
+			expectedErr: true,
+		},
+		{
+			name:        "multiple sources",
+			annotation:  `{"oci": "oci://quay.io/ocm/hello:0.1.0", "configMap": {"namespace": "ns", "name": "hello"}}`,
+			expectedErr: true,
+		},
+		{
+			name:        "invalid oci reference",
+			annotation:  `{"oci": "quay.io/ocm/hello"}`,
+			expectedErr: true,
+		},
+		{
+			name:        "invalid values mapping",
+			annotation:  `{"oci": "oci://quay.io/ocm/hello:0.1.0", "valuesMapping": [{"path": "a", "variable": "A", "clusterLabel": "a"}]}`,
+			expectedErr: true,
+		},
+		{
+			name:        "secret not in the chart namespace",
+			annotation:  `{"secret": {"namespace": "open-cluster-management-hub", "name": "hello"}}`,
+			expectedErr: true,
+		},
+		{
+			name:        "configmap not in the chart namespace",
+			annotation:  `{"configMap": {"namespace": "ns", "name": "hello"}}`,
+			expectedErr: true,
+		},
+		{
+			name:       "valid",
+			annotation: `{"secret": {"name": "hello"}, "valuesMapping": [{"path": "a.b", "clusterClaim": "id.k8s.io"}]}`,
+			expected: &HelmChartSource{
+				Secret:        &ChartArchiveReference{Name: "hello"},
+				ValuesMapping: []HelmValueMapping{{Path: "a.b", ClusterClaim: "id.k8s.io"}},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			template := &addonapiv1alpha1.AddOnTemplate{}
+			if len(c.annotation) > 0 {
+				template.Annotations = map[string]string{HelmChartAnnotationKey: c.annotation}
+			}
+			source, err := GetHelmChartSource(template)
+			if c.expectedErr != (err != nil) {
+				t.Fatalf("expected error %v, but got %v", c.expectedErr, err)
+			}
+			if !equality.Semantic.DeepEqual(source, c.expected) {
+				t.Errorf("expected %v, but got %v", c.expected, source)
+			}
+		})
+	}
+}
+
+func TestRenderHelmChart(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	chartConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello-chart", Namespace: "open-cluster-management-hub-addon-charts", ResourceVersion: "1"},
+		BinaryData: map[string][]byte{"chart.tgz": newTestChartArchive(t)},
+	}
+	source, err := json.Marshal(HelmChartSource{
+		ConfigMap: &ChartArchiveReference{Namespace: chartConfigMap.Namespace, Name: chartConfigMap.Name},
+		Values: map[string]interface{}{
+			"clusterName": "{{CLUSTER_NAME}}",
+		},
+		ValuesMapping: []HelmValueMapping{
+			{Path: "image.tag", Variable: "IMAGE_TAG"},
+			{Path: "region", ClusterLabel: "region"},
+			{Path: "zone", ClusterClaim: "zone.open-cluster-management.io"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &addonapiv1alpha1.AddOnTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "hello-template",
+			Annotations: map[string]string{HelmChartAnnotationKey: string(source)},
+		},
+		Spec: addonapiv1alpha1.AddOnTemplateSpec{AddonName: "hello"},
+	}
+
+	cluster := addontesting.NewManagedCluster("cluster1")
+	cluster.Labels = map[string]string{"region": "us-east-1"}
+	cluster.Status.Version.Kubernetes = "v1.30.2"
+	addon := addontesting.NewAddon("hello", "cluster1")
+
+	getValues := func(_ *clusterv1.ManagedCluster, _ *addonapiv1alpha1.ManagedClusterAddOn) (addonfactory.Values, error) {
+		return addonfactory.Values{
+			"IMAGE_TAG": "v1",
+			NodePlacementPrivateValueKey: &addonapiv1alpha1.NodePlacement{
+				NodeSelector: map[string]string{"host": "ssd"},
+			},
+			InstallNamespacePrivateValueKey: "hello-agent",
+		}, nil
+	}
+	addonClient := fakeaddon.NewSimpleClientset()
+	kubeClient := fakekube.NewSimpleClientset(chartConfigMap)
+	agentAddon := NewCRDTemplateAgentAddon(ctx, "hello", kubeClient, addonClient,
+		addoninformers.NewSharedInformerFactory(addonClient, 0), nil, getValues)
+
+	objects, err := agentAddon.renderObjects(cluster, addon, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the test hook is not rendered
+	if len(objects) != 2 {
+		t.Fatalf("expected the crd and deployment are rendered, but got %v", objects)
+	}
+	if crd := objects[0].(*unstructured.Unstructured); crd.GetName() != "hellos.example.com" {
+		t.Errorf("expected the crd at the top, but got %v", crd)
+	}
+
+	deploy := objects[1].(*unstructured.Unstructured)
+	if deploy.GetName() != "hello-agent" || deploy.GetNamespace() != "hello-agent" {
+		t.Errorf("unexpected deployment %s/%s", deploy.GetNamespace(), deploy.GetName())
+	}
+	if region := deploy.GetLabels()["region"]; region != "us-east-1" {
+		t.Errorf("expected the region from the cluster label, but got %s", region)
+	}
+	if kubeVersion := deploy.GetLabels()["kube-version"]; kubeVersion != "v1.30.2" {
+		t.Errorf("expected the kube version of the cluster, but got %s", kubeVersion)
+	}
+	containers, _, _ := unstructured.NestedSlice(deploy.Object, "spec", "template", "spec", "containers")
+	container := containers[0].(map[string]interface{})
+	if container["image"] != "quay.io/ocm/hello:v1" {
+		t.Errorf("expected the image tag from the variable, but got %v", container["image"])
+	}
+	if args := fmt.Sprint(container["args"]); args != "[--cluster=cluster1]" {
+		t.Errorf("expected the cluster name substituted, but got %s", args)
+	}
+	// the decorators are applied to the objects rendered from the chart
+	nodeSelector, _, _ := unstructured.NestedStringMap(deploy.Object, "spec", "template", "spec", "nodeSelector")
+	if !equality.Semantic.DeepEqual(nodeSelector, map[string]string{"host": "ssd"}) {
+		t.Errorf("expected the node selector decorated, but got %v", nodeSelector)
+	}
+
+	// the chart is loaded from the cache if the configmap is not changed
+	if _, err := agentAddon.renderObjects(cluster, addon, template); err != nil {
+		t.Fatal(err)
+	}
+	if len(agentAddon.chartLoader.cache) != 1 {
+		t.Errorf("expected the chart is cached, but got %v", agentAddon.chartLoader.cache)
+	}
+}
+
+func TestPullOCIChart(t *testing.T) {
+	archive := newTestChartArchive(t)
+	sum := sha256.Sum256(archive)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			if r.URL.Query().Get("scope") != "repository:ocm/hello:pull" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"token": "test-token"}`))
+		case r.Header.Get("Authorization") != "Bearer test-token":
+			w.Header().Set("WWW-Authenticate",
+				fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:ocm/hello:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/v2/ocm/hello/manifests/0.1.0":
+			_, _ = fmt.Fprintf(w, `{"layers": [{"mediaType": "%s", "digest": "%s"}]}`, helmChartContentMediaType, digest)
+		case r.URL.Path == "/v2/ocm/hello/blobs/"+digest:
+			_, _ = w.Write(archive)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	loader := newHelmChartLoader(fakekube.NewSimpleClientset())
+	loader.httpClient = server.Client()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	helmChart, err := loader.load(context.TODO(), &HelmChartSource{OCI: fmt.Sprintf("oci://%s/ocm/hello:0.1.0", host)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if helmChart.Name() != "hello" {
+		t.Errorf("expected chart hello, but got %s", helmChart.Name())
+	}
+
+	if _, err := loader.load(context.TODO(), &HelmChartSource{OCI: fmt.Sprintf("oci://%s/ocm/hello:0.2.0", host)}); err == nil {
+		t.Errorf("expected error when the chart is not found")
+	}
+}
+
+func TestIsAllowedRealmHost(t *testing.T) {
+	cases := []struct {
+		registryHost string
+		realmHost    string
+		expected     bool
+	}{
+		{registryHost: "quay.io", realmHost: "quay.io", expected: true},
+		{registryHost: "registry-1.docker.io", realmHost: "auth.docker.io", expected: true},
+		{registryHost: "127.0.0.1:5000", realmHost: "127.0.0.1:5001", expected: true},
+		{registryHost: "quay.io", realmHost: "attacker.example.com"},
+		{registryHost: "quay.io", realmHost: "169.254.169.254"},
+		{registryHost: "127.0.0.1:5000", realmHost: "127.0.0.2"},
+		{registryHost: "localhost:5000", realmHost: "kubernetes.default.svc"},
+	}
+
+	for _, c := range cases {
+		if actual := isAllowedRealmHost(c.registryHost, c.realmHost); actual != c.expected {
+			t.Errorf("expected %v for realm %s of registry %s, but got %v", c.expected, c.realmHost, c.registryHost, actual)
+		}
+	}
+}
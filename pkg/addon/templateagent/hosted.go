@@ -0,0 +1,117 @@
+package templateagent
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	addonconstants "open-cluster-management.io/addon-framework/pkg/addonmanager/constants"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+)
+
+const (
+	// ExternalManagedKubeconfigSecretName is the secret in the agent namespace of the hosted klusterlet on the
+	// hosting cluster, which contains the kubeconfig of the managed cluster. It is mounted to the deployments and
+	// daemonsets deployed on the hosting cluster when the addon is installed in the Hosted mode.
+	ExternalManagedKubeconfigSecretName = "external-managed-kubeconfig"
+)
+
+// hostedInfo is the information of the addon installed in the Hosted mode, the addon agent runs on the hosting
+// cluster and connects to the managed cluster with the external managed kubeconfig.
+type hostedInfo struct {
+	hostingClusterName string
+	// hostingNamespace is the namespace on the hosting cluster where the manifests with the hosted manifest location
+	// annotation "hosting" are deployed.
+	hostingNamespace string
+}
+
+// getHostedInfo returns the hosted information of the addon, nil if the addon is not installed in the Hosted mode.
+func getHostedInfo(addon *addonapiv1alpha1.ManagedClusterAddOn, privateValues addonfactory.Values) *hostedInfo {
+	installMode, hostingClusterName := addonconstants.GetHostedModeInfo(addon, nil)
+	if installMode != addonconstants.InstallModeHosted {
+		return nil
+	}
+
+	return &hostedInfo{
+		hostingClusterName: hostingClusterName,
+		hostingNamespace:   hostingNamespace(addon.Namespace, privateValues),
+	}
+}
+
+// hostingNamespace returns the agent install namespace configured by the AddOnDeploymentConfig, or the agent
+// namespace of the hosted klusterlet on the hosting cluster by default.
+func hostingNamespace(clusterName string, privateValues addonfactory.Values) string {
+	if namespace, ok := privateValues[InstallNamespacePrivateValueKey]; ok && namespace != nil {
+		if ns := namespace.(string); len(ns) > 0 {
+			return ns
+		}
+	}
+	return defaultHostingNamespace(clusterName)
+}
+
+// defaultHostingNamespace returns the agent namespace of the hosted klusterlet, which is named klusterlet-<cluster>.
+func defaultHostingNamespace(clusterName string) string {
+	return fmt.Sprintf("klusterlet-%s", clusterName)
+}
+
+// isHostingManifest returns true if the manifest is deployed on the hosting cluster in the Hosted mode.
+func isHostingManifest(obj *unstructured.Unstructured) bool {
+	location, _, err := addonconstants.GetHostedManifestLocation(obj.GetLabels(), obj.GetAnnotations())
+	return err == nil && location == addonapiv1alpha1.HostedManifestLocationHostingValue
+}
+
+// setHostingManifest marks the manifest to be deployed on the hosting cluster in the Hosted mode.
+func setHostingManifest(obj *unstructured.Unstructured) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[addonapiv1alpha1.HostedManifestLocationAnnotationKey] = addonapiv1alpha1.HostedManifestLocationHostingValue
+	obj.SetAnnotations(annotations)
+}
+
+type managedKubeconfigDecorator struct {
+	envDecorator podTemplateSpecDecorator
+}
+
+// newManagedKubeconfigDecorator mounts the external managed kubeconfig to the workloads on the hosting cluster, and
+// sets the path of the kubeconfig to the env MANAGED_KUBECONFIG.
+func newManagedKubeconfigDecorator() podTemplateSpecDecorator {
+	return &managedKubeconfigDecorator{
+		envDecorator: newEnvironmentDecorator([]keyValuePair{
+			{name: "MANAGED_KUBECONFIG", value: managedKubeconfigPath()},
+		}),
+	}
+}
+
+func (d *managedKubeconfigDecorator) decorate(name string, pod *corev1.PodTemplateSpec) error {
+	if err := d.envDecorator.decorate(name, pod); err != nil {
+		return err
+	}
+
+	for j := range pod.Spec.Containers {
+		pod.Spec.Containers[j].VolumeMounts = append(pod.Spec.Containers[j].VolumeMounts, corev1.VolumeMount{
+			Name:      "managed-kubeconfig",
+			MountPath: managedKubeconfigSecretMountPath(),
+		})
+	}
+	pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+		Name: "managed-kubeconfig",
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: ExternalManagedKubeconfigSecretName,
+			},
+		},
+	})
+	return nil
+}
+
+func managedKubeconfigSecretMountPath() string {
+	return "/managed/managed-kubeconfig"
+}
+
+func managedKubeconfigPath() string {
+	return "/managed/managed-kubeconfig/kubeconfig"
+}
@@ -0,0 +1,154 @@
+package templateagent
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/klog/v2/ktesting"
+
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	"open-cluster-management.io/addon-framework/pkg/utils"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+const (
+	testHostingDeployment = `{"apiVersion": "apps/v1", "kind": "Deployment",
+"metadata": {"name": "hello-agent", "namespace": "default",
+  "annotations": {"addon.open-cluster-management.io/hosted-manifest-location": "hosting"}},
+"spec": {"selector": {"matchLabels": {"app": "hello"}},
+  "template": {"metadata": {"labels": {"app": "hello"}},
+    "spec": {"containers": [{"name": "agent", "image": "quay.io/ocm/hello:v1"}]}}}}`
+	testManagedDeployment = `{"apiVersion": "apps/v1", "kind": "Deployment",
+"metadata": {"name": "hello-helper", "namespace": "default"},
+"spec": {"selector": {"matchLabels": {"app": "helper"}},
+  "template": {"metadata": {"labels": {"app": "helper"}},
+    "spec": {"containers": [{"name": "helper", "image": "quay.io/ocm/helper:v1"}]}}}}`
+)
+
+func TestRenderHostedObjects(t *testing.T) {
+	_, ctx := ktesting.NewTestContext(t)
+	template := &addonapiv1alpha1.AddOnTemplate{
+		Spec: addonapiv1alpha1.AddOnTemplateSpec{
+			AddonName: "hello",
+			AgentSpec: workapiv1.ManifestWorkSpec{
+				Workload: workapiv1.ManifestsTemplate{
+					Manifests: []workapiv1.Manifest{
+						{RawExtension: runtime.RawExtension{Raw: []byte(testHostingDeployment)}},
+						{RawExtension: runtime.RawExtension{Raw: []byte(testManagedDeployment)}},
+					},
+				},
+			},
+			Registration: []addonapiv1alpha1.RegistrationSpec{
+				{Type: addonapiv1alpha1.RegistrationTypeKubeClient},
+			},
+		},
+	}
+
+	cases := []struct {
+		name                      string
+		annotations               map[string]string
+		expectedHostingNamespace  string
+		expectedManagedNamespace  string
+		expectedHostingVolumes    []string
+		expectedManagedVolumes    []string
+		expectedHostingClusterEnv string
+	}{
+		{
+			name:                     "default mode",
+			expectedHostingNamespace: "default",
+			expectedManagedNamespace: "default",
+			expectedHostingVolumes:   []string{"hub-kubeconfig"},
+			expectedManagedVolumes:   []string{"hub-kubeconfig"},
+		},
+		{
+			name: "hosted mode",
+			annotations: map[string]string{
+				addonapiv1alpha1.HostingClusterNameAnnotationKey: "hosting",
+			},
+			expectedHostingNamespace:  "klusterlet-cluster1",
+			expectedManagedNamespace:  "default",
+			expectedHostingVolumes:    []string{"hub-kubeconfig", "managed-kubeconfig"},
+			expectedHostingClusterEnv: "hosting",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addon := addontesting.NewAddon("hello", "cluster1")
+			addon.Annotations = c.annotations
+
+			addonClient := fakeaddon.NewSimpleClientset()
+			agentAddon := NewCRDTemplateAgentAddon(ctx, "hello", fakekube.NewSimpleClientset(), addonClient,
+				addoninformers.NewSharedInformerFactory(addonClient, 0), nil)
+			objects, err := agentAddon.renderObjects(addontesting.NewManagedCluster("cluster1"), addon, template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(objects) != 2 {
+				t.Fatalf("expected 2 objects, but got %d", len(objects))
+			}
+
+			hosting, err := utils.ConvertToDeployment(objects[0].(*unstructured.Unstructured))
+			if err != nil {
+				t.Fatal(err)
+			}
+			managed, err := utils.ConvertToDeployment(objects[1].(*unstructured.Unstructured))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if hosting.Namespace != c.expectedHostingNamespace {
+				t.Errorf("expected hosting namespace %s, but got %s", c.expectedHostingNamespace, hosting.Namespace)
+			}
+			if managed.Namespace != c.expectedManagedNamespace {
+				t.Errorf("expected managed namespace %s, but got %s", c.expectedManagedNamespace, managed.Namespace)
+			}
+			assertVolumes(t, hosting.Spec.Template.Spec.Volumes, c.expectedHostingVolumes)
+			assertVolumes(t, managed.Spec.Template.Spec.Volumes, c.expectedManagedVolumes)
+
+			env := envMap(hosting.Spec.Template.Spec.Containers[0].Env)
+			if env["HOSTING_CLUSTER_NAME"] != c.expectedHostingClusterEnv {
+				t.Errorf("expected hosting cluster env %q, but got %q", c.expectedHostingClusterEnv, env["HOSTING_CLUSTER_NAME"])
+			}
+			_, hasManagedKubeconfig := env["MANAGED_KUBECONFIG"]
+			if hasManagedKubeconfig != (len(c.annotations) > 0) {
+				t.Errorf("unexpected MANAGED_KUBECONFIG env %v", env)
+			}
+			if _, ok := envMap(managed.Spec.Template.Spec.Containers[0].Env)["MANAGED_KUBECONFIG"]; ok {
+				t.Errorf("expected no MANAGED_KUBECONFIG env on the managed cluster")
+			}
+		})
+	}
+}
+
+func assertVolumes(t *testing.T, volumes []corev1.Volume, expected []string) {
+	t.Helper()
+	var names []string
+	for _, v := range volumes {
+		names = append(names, v.Name)
+	}
+	if len(names) != len(expected) {
+		t.Errorf("expected volumes %v, but got %v", expected, names)
+		return
+	}
+	for i := range names {
+		if names[i] != expected[i] {
+			t.Errorf("expected volumes %v, but got %v", expected, names)
+			return
+		}
+	}
+}
+
+func envMap(envs []corev1.EnvVar) map[string]string {
+	m := map[string]string{}
+	for _, env := range envs {
+		m[env.Name] = env.Value
+	}
+	return m
+}
@@ -16,7 +16,12 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
 
@@ -35,6 +40,17 @@ const (
 	AddonTemplateLabelKey = "open-cluster-management.io/addon-template-name"
 )
 
+// HubPermissionBindingSelector returns the selector of the rolebindings created on the hub to grant permissions to
+// the agent of the template addon on the cluster.
+func HubPermissionBindingSelector(clusterName, addonName string) labels.Selector {
+	selector := labels.SelectorFromSet(labels.Set{
+		addonapiv1alpha1.AddonLabelKey: addonName,
+		clusterv1.ClusterNameLabelKey:  clusterName,
+	})
+	requirement, _ := labels.NewRequirement(AddonTemplateLabelKey, selection.Exists, nil)
+	return selector.Add(*requirement)
+}
+
 var (
 	podNamespace = ""
 )
@@ -53,6 +69,12 @@ func AddonManagerNamespace() string {
 	return podNamespace
 }
 
+// AddonChartNamespace returns the namespace of the helm chart archives of the addon templates on the hub, the addon
+// manager is only allowed to read the secrets in this namespace.
+func AddonChartNamespace() string {
+	return AddonManagerNamespace() + "-addon-charts"
+}
+
 // GetDesiredAddOnTemplate returns the desired AddOnTemplate for the given ManagedClusterAddOn.
 // If the desired AddOnTemplate is not found in the ManagedClusterAddOn Status ConfigReferences,
 // it will return a nil AddOnTemplate with no error. the caller should handle the nil
@@ -369,6 +391,13 @@ func (a *CRDTemplateAgentAddon) TemplatePermissionConfigFunc() agent.PermissionC
 				cluster.Name, a.addonName)
 		}
 
+		// the permissions are removed by the addon hub cleanup controller when the addon is deleting, do not
+		// create them again.
+		if !addon.DeletionTimestamp.IsZero() {
+			return nil
+		}
+
+		desiredBindings := sets.New[string]()
 		for _, registration := range template.Spec.Registration {
 			switch registration.Type {
 			case addonapiv1alpha1.RegistrationTypeKubeClient:
@@ -377,10 +406,11 @@ func (a *CRDTemplateAgentAddon) TemplatePermissionConfigFunc() agent.PermissionC
 					continue
 				}
 
-				err := a.createKubeClientPermissions(kcrc, cluster, addon)
+				bindings, err := a.createKubeClientPermissions(kcrc, cluster, addon)
 				if err != nil {
 					return err
 				}
+				desiredBindings.Insert(bindings...)
 
 			case addonapiv1alpha1.RegistrationTypeCustomSigner:
 				continue
@@ -392,7 +422,9 @@ func (a *CRDTemplateAgentAddon) TemplatePermissionConfigFunc() agent.PermissionC
 
 		}
 
-		return nil
+		// remove the permissions which are no longer required by the template, e.g. the template of the addon is
+		// changed.
+		return a.removeStalePermissionBindings(cluster.Name, desiredBindings)
 	}
 }
 
@@ -400,13 +432,14 @@ func (a *CRDTemplateAgentAddon) createKubeClientPermissions(
 	kcrc *addonapiv1alpha1.KubeClientRegistrationConfig,
 	cluster *clusterv1.ManagedCluster,
 	addon *addonapiv1alpha1.ManagedClusterAddOn,
-) error {
+) ([]string, error) {
 
+	var bindings []string
 	for _, pc := range kcrc.HubPermissions {
 		switch pc.Type {
 		case addonapiv1alpha1.HubPermissionsBindingCurrentCluster:
 			if pc.CurrentCluster == nil {
-				return fmt.Errorf("current cluster is required when the HubPermission type is CurrentCluster")
+				return nil, fmt.Errorf("current cluster is required when the HubPermission type is CurrentCluster")
 			}
 
 			a.logger.V(5).Info("Set hub permission for addon",
@@ -429,34 +462,88 @@ func (a *CRDTemplateAgentAddon) createKubeClientPermissions(
 				APIGroup: rbacv1.GroupName,
 				Name:     pc.CurrentCluster.ClusterRoleName,
 			}
-			err := a.createPermissionBinding(cluster.Name, addon.Name, cluster.Name, roleRef, &owner)
+			binding, err := a.createPermissionBinding(cluster.Name, addon.Name, cluster.Name, roleRef, &owner)
 			if err != nil {
-				return err
+				return nil, err
 			}
+			bindings = append(bindings, binding)
 		case addonapiv1alpha1.HubPermissionsBindingSingleNamespace:
 			if pc.SingleNamespace == nil {
-				return fmt.Errorf("single namespace is required when the HubPermission type is SingleNamespace")
+				return nil, fmt.Errorf("single namespace is required when the HubPermission type is SingleNamespace")
 			}
 
-			// set owner reference nil since the rolebinding has different namespace with the ManagedClusterAddon
-			// TODO: cleanup the rolebinding when the addon is deleted
-			err := a.createPermissionBinding(cluster.Name, addon.Name,
+			// set owner reference nil since the rolebinding has different namespace with the ManagedClusterAddon,
+			// it is removed by the addon hub cleanup controller when the addon is deleted.
+			binding, err := a.createPermissionBinding(cluster.Name, addon.Name,
 				pc.SingleNamespace.Namespace, pc.SingleNamespace.RoleRef, nil)
 			if err != nil {
-				return err
+				return nil, err
 			}
+			bindings = append(bindings, binding)
 		}
 	}
-	return nil
+	return bindings, nil
 }
 
+// removeStalePermissionBindings deletes the rolebindings created on the hub for the addon agent on the cluster
+// which are not in the desired bindings, the bindings are in the format of namespace/name. The legacy rolebindings
+// without the cluster name label in the namespaces of the desired bindings are removed as well, they were shared by
+// the agents on all the clusters and are replaced by the per cluster ones.
+func (a *CRDTemplateAgentAddon) removeStalePermissionBindings(clusterName string, desired sets.Set[string]) error {
+	bindings, err := a.rolebindingLister.List(HubPermissionBindingSelector(clusterName, a.addonName))
+	if err != nil {
+		return err
+	}
+
+	desiredNamespaces := sets.New[string](clusterName)
+	for key := range desired {
+		namespace, _, _ := strings.Cut(key, "/")
+		desiredNamespaces.Insert(namespace)
+	}
+	legacyBindings, err := a.rolebindingLister.List(legacyHubPermissionBindingSelector(a.addonName))
+	if err != nil {
+		return err
+	}
+	for _, binding := range legacyBindings {
+		if desiredNamespaces.Has(binding.Namespace) {
+			bindings = append(bindings, binding)
+		}
+	}
+
+	var errs []error
+	for _, binding := range bindings {
+		if desired.Has(binding.Namespace + "/" + binding.Name) {
+			continue
+		}
+		err := a.hubKubeClient.RbacV1().RoleBindings(binding.Namespace).Delete(
+			context.TODO(), binding.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, err)
+			continue
+		}
+		a.logger.Info("Stale rolebinding for addon removed", "namespace", binding.Namespace, "name", binding.Name,
+			"clusterName", clusterName, "addonName", a.addonName)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// legacyHubPermissionBindingSelector returns the selector of the rolebindings created for the addon agents before
+// the rolebindings are labeled with the cluster name.
+func legacyHubPermissionBindingSelector(addonName string) labels.Selector {
+	selector := labels.SelectorFromSet(labels.Set{addonapiv1alpha1.AddonLabelKey: addonName})
+	templateRequirement, _ := labels.NewRequirement(AddonTemplateLabelKey, selection.Exists, nil)
+	clusterRequirement, _ := labels.NewRequirement(clusterv1.ClusterNameLabelKey, selection.DoesNotExist, nil)
+	return selector.Add(*templateRequirement, *clusterRequirement)
+}
+
+// createPermissionBinding applies the rolebinding and returns its key in the format of namespace/name.
 func (a *CRDTemplateAgentAddon) createPermissionBinding(clusterName, addonName, namespace string,
-	roleRef rbacv1.RoleRef, owner *metav1.OwnerReference) error {
+	roleRef rbacv1.RoleRef, owner *metav1.OwnerReference) (string, error) {
 
 	// Get the ManagedClusterAddOn to extract dynamic subjects from Status.Registrations
 	addon, err := a.addonLister.ManagedClusterAddOns(clusterName).Get(addonName)
 	if err != nil {
-		return fmt.Errorf("failed to get ManagedClusterAddOn %s/%s: %w", clusterName, addonName, err)
+		return "", fmt.Errorf("failed to get ManagedClusterAddOn %s/%s: %w", clusterName, addonName, err)
 	}
 
 	// Build subjects dynamically from addon.Status.Registrations for KubeClient signer
@@ -466,16 +553,24 @@ func (a *CRDTemplateAgentAddon) createPermissionBinding(clusterName, addonName,
 	// If no subjects found, return pending error to retry later
 	// This can happen when the addon is first created and registrations are not yet populated
 	if len(subjects) == 0 {
-		return &agent.SubjectNotReadyError{}
+		return "", &agent.SubjectNotReadyError{}
+	}
+
+	name := fmt.Sprintf("open-cluster-management:%s:%s:agent", addonName, strings.ToLower(roleRef.Kind))
+	if namespace != clusterName {
+		// the rolebinding out of the cluster namespace is shared by the agents on all the clusters, add the cluster
+		// name so it can be removed per cluster.
+		name = fmt.Sprintf("open-cluster-management:%s:%s:%s:agent", addonName, clusterName,
+			strings.ToLower(roleRef.Kind))
 	}
 
 	binding := &rbacv1.RoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: fmt.Sprintf("open-cluster-management:%s:%s:agent",
-				addonName, strings.ToLower(roleRef.Kind)),
+			Name:      name,
 			Namespace: namespace,
 			Labels: map[string]string{
 				addonapiv1alpha1.AddonLabelKey: addonName,
+				clusterv1.ClusterNameLabelKey:  clusterName,
 				AddonTemplateLabelKey:          "",
 			},
 		},
@@ -498,7 +593,10 @@ func (a *CRDTemplateAgentAddon) createPermissionBinding(clusterName, addonName,
 		a.logger.Info("Rolebinding for addon updated", "namespace", binding.Namespace, "name", binding.Name,
 			"clusterName", clusterName, "addonName", addonName, "subjects", subjects)
 	}
-	return err
+	if err != nil {
+		return "", err
+	}
+	return binding.Namespace + "/" + binding.Name, nil
 }
 
 // buildSubjectsFromRegistration extracts and builds RBAC subjects from addon registration status.
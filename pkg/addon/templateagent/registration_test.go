@@ -16,6 +16,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
@@ -679,7 +680,7 @@ func TestTemplatePermissionConfigFunc(t *testing.T) {
 			expectedErr: nil,
 			validatePermissionFunc: func(t *testing.T, kubeClient kubernetes.Interface) {
 				rb, err := kubeClient.RbacV1().RoleBindings("test").Get(context.TODO(),
-					fmt.Sprintf("open-cluster-management:%s:%s:agent", "addon1", strings.ToLower("ClusterRole")),
+					fmt.Sprintf("open-cluster-management:%s:%s:%s:agent", "addon1", "cluster1", strings.ToLower("ClusterRole")),
 					metav1.GetOptions{},
 				)
 				if err != nil {
@@ -713,6 +714,172 @@ func TestTemplatePermissionConfigFunc(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:    "kubeclient stale single namespace binding",
+			cluster: NewFakeManagedCluster("cluster1"),
+			template: NewFakeAddonTemplate("template1", []addonapiv1alpha1.RegistrationSpec{
+				{
+					Type: addonapiv1alpha1.RegistrationTypeKubeClient,
+					KubeClient: &addonapiv1alpha1.KubeClientRegistrationConfig{
+						HubPermissions: []addonapiv1alpha1.HubPermissionConfig{
+							{
+								Type: addonapiv1alpha1.HubPermissionsBindingCurrentCluster,
+								CurrentCluster: &addonapiv1alpha1.CurrentClusterBindingConfig{
+									ClusterRoleName: "test",
+								},
+							},
+						},
+					},
+				},
+			}),
+			addon: NewFakeTemplateManagedClusterAddon("addon1", "cluster1", "template1", "fakehash"),
+			rolebinding: &rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "open-cluster-management:addon1:cluster1:clusterrole:agent",
+					Namespace: "test",
+					Labels: map[string]string{
+						addonapiv1alpha1.AddonLabelKey: "addon1",
+						clusterv1.ClusterNameLabelKey:  "cluster1",
+						AddonTemplateLabelKey:          "",
+					},
+				},
+			},
+			expectedErr: nil,
+			validatePermissionFunc: func(t *testing.T, kubeClient kubernetes.Interface) {
+				_, err := kubeClient.RbacV1().RoleBindings("test").Get(context.TODO(),
+					"open-cluster-management:addon1:cluster1:clusterrole:agent", metav1.GetOptions{})
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("expected stale rolebinding to be removed, got %v", err)
+				}
+				_, err = kubeClient.RbacV1().RoleBindings("cluster1").Get(context.TODO(),
+					"open-cluster-management:addon1:clusterrole:agent", metav1.GetOptions{})
+				if err != nil {
+					t.Errorf("failed to get rolebinding: %v", err)
+				}
+			},
+		},
+		{
+			name:    "kubeclient legacy single namespace binding",
+			cluster: NewFakeManagedCluster("cluster1"),
+			template: NewFakeAddonTemplate("template1", []addonapiv1alpha1.RegistrationSpec{
+				{
+					Type: addonapiv1alpha1.RegistrationTypeKubeClient,
+					KubeClient: &addonapiv1alpha1.KubeClientRegistrationConfig{
+						HubPermissions: []addonapiv1alpha1.HubPermissionConfig{
+							{
+								Type: addonapiv1alpha1.HubPermissionsBindingSingleNamespace,
+								SingleNamespace: &addonapiv1alpha1.SingleNamespaceBindingConfig{
+									Namespace: "test",
+									RoleRef: rbacv1.RoleRef{
+										APIGroup: rbacv1.GroupName,
+										Kind:     "ClusterRole",
+										Name:     "test",
+									},
+								},
+							},
+						},
+					},
+				},
+			}),
+			addon: NewFakeTemplateManagedClusterAddon("addon1", "cluster1", "template1", "fakehash"),
+			rolebinding: &rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "open-cluster-management:addon1:clusterrole:agent",
+					Namespace: "test",
+					Labels: map[string]string{
+						addonapiv1alpha1.AddonLabelKey: "addon1",
+						AddonTemplateLabelKey:          "",
+					},
+				},
+			},
+			expectedErr: nil,
+			validatePermissionFunc: func(t *testing.T, kubeClient kubernetes.Interface) {
+				_, err := kubeClient.RbacV1().RoleBindings("test").Get(context.TODO(),
+					"open-cluster-management:addon1:clusterrole:agent", metav1.GetOptions{})
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("expected legacy rolebinding to be removed, got %v", err)
+				}
+				_, err = kubeClient.RbacV1().RoleBindings("test").Get(context.TODO(),
+					"open-cluster-management:addon1:cluster1:clusterrole:agent", metav1.GetOptions{})
+				if err != nil {
+					t.Errorf("failed to get rolebinding: %v", err)
+				}
+			},
+		},
+		{
+			name:    "kubeclient legacy binding in other cluster namespace",
+			cluster: NewFakeManagedCluster("cluster1"),
+			template: NewFakeAddonTemplate("template1", []addonapiv1alpha1.RegistrationSpec{
+				{
+					Type: addonapiv1alpha1.RegistrationTypeKubeClient,
+					KubeClient: &addonapiv1alpha1.KubeClientRegistrationConfig{
+						HubPermissions: []addonapiv1alpha1.HubPermissionConfig{
+							{
+								Type: addonapiv1alpha1.HubPermissionsBindingCurrentCluster,
+								CurrentCluster: &addonapiv1alpha1.CurrentClusterBindingConfig{
+									ClusterRoleName: "test",
+								},
+							},
+						},
+					},
+				},
+			}),
+			addon: NewFakeTemplateManagedClusterAddon("addon1", "cluster1", "template1", "fakehash"),
+			rolebinding: &rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "open-cluster-management:addon1:clusterrole:agent",
+					Namespace: "cluster2",
+					Labels: map[string]string{
+						addonapiv1alpha1.AddonLabelKey: "addon1",
+						AddonTemplateLabelKey:          "",
+					},
+				},
+			},
+			expectedErr: nil,
+			validatePermissionFunc: func(t *testing.T, kubeClient kubernetes.Interface) {
+				_, err := kubeClient.RbacV1().RoleBindings("cluster2").Get(context.TODO(),
+					"open-cluster-management:addon1:clusterrole:agent", metav1.GetOptions{})
+				if err != nil {
+					t.Errorf("expected rolebinding of other cluster to be kept, got %v", err)
+				}
+			},
+		},
+		{
+			name:    "kubeclient binding of deleting addon",
+			cluster: NewFakeManagedCluster("cluster1"),
+			template: NewFakeAddonTemplate("template1", []addonapiv1alpha1.RegistrationSpec{
+				{
+					Type: addonapiv1alpha1.RegistrationTypeKubeClient,
+					KubeClient: &addonapiv1alpha1.KubeClientRegistrationConfig{
+						HubPermissions: []addonapiv1alpha1.HubPermissionConfig{
+							{
+								Type: addonapiv1alpha1.HubPermissionsBindingCurrentCluster,
+								CurrentCluster: &addonapiv1alpha1.CurrentClusterBindingConfig{
+									ClusterRoleName: "test",
+								},
+							},
+						},
+					},
+				},
+			}),
+			addon: func() *addonapiv1alpha1.ManagedClusterAddOn {
+				addon := NewFakeTemplateManagedClusterAddon("addon1", "cluster1", "template1", "fakehash")
+				now := metav1.Now()
+				addon.DeletionTimestamp = &now
+				return addon
+			}(),
+			expectedErr: nil,
+			validatePermissionFunc: func(t *testing.T, kubeClient kubernetes.Interface) {
+				bindings, err := kubeClient.RbacV1().RoleBindings(metav1.NamespaceAll).List(context.TODO(),
+					metav1.ListOptions{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(bindings.Items) != 0 {
+					t.Errorf("expected no rolebinding created for deleting addon, got %d", len(bindings.Items))
+				}
+			},
+		},
 		{
 			name:    "customsigner",
 			cluster: NewFakeManagedCluster("cluster1"),
@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/valyala/fasttemplate"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -22,6 +22,8 @@ import (
 	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
 	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/ocm/pkg/addon/compatibility"
 )
 
 const (
@@ -49,6 +51,8 @@ var PrivateValuesKeys = map[string]struct{}{
 type templateCRDBuiltinValues struct {
 	ClusterName      string `json:"CLUSTER_NAME,omitempty"`
 	InstallNamespace string `json:"INSTALL_NAMESPACE,omitempty"`
+	// HostingClusterName is set only when the addon is installed in the Hosted mode
+	HostingClusterName string `json:"HOSTING_CLUSTER_NAME,omitempty"`
 }
 
 // templateDefaultValues includes the default values for crd template agentAddon.
@@ -70,6 +74,7 @@ type CRDTemplateAgentAddon struct {
 	addonTemplateLister addonlisterv1alpha1.AddOnTemplateLister
 	cmaLister           addonlisterv1alpha1.ClusterManagementAddOnLister
 	rolebindingLister   rbacv1lister.RoleBindingLister
+	chartLoader         *helmChartLoader
 	addonName           string
 	agentName           string
 }
@@ -96,6 +101,7 @@ func NewCRDTemplateAgentAddon(
 		addonTemplateLister: addonInformers.Addon().V1alpha1().AddOnTemplates().Lister(),
 		cmaLister:           addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Lister(),
 		rolebindingLister:   rolebindingLister,
+		chartLoader:         newHelmChartLoader(hubKubeClient),
 		addonName:           addonName,
 		agentName:           fmt.Sprintf("%s-agent", addonName),
 	}
@@ -114,22 +120,47 @@ func (a *CRDTemplateAgentAddon) Manifests(
 	if template == nil {
 		return nil, fmt.Errorf("addon %s/%s template not found in status", addon.Namespace, addon.Name)
 	}
+
+	// nothing is deployed on the clusters not meeting the requirements of the addon
+	compatible, err := a.isCompatible(cluster)
+	if err != nil {
+		return nil, err
+	}
+	if !compatible {
+		a.logger.V(4).Info("Cluster is incompatible with the addon, skip rendering manifests",
+			"addonName", addon.Name, "clusterName", cluster.Name)
+		return []runtime.Object{}, nil
+	}
+
 	return a.renderObjects(cluster, addon, template)
 }
 
 func (a *CRDTemplateAgentAddon) GetAgentAddonOptions() agent.AgentAddonOptions {
-	// TODO: consider a new way for developers to define their supported config GVRs
 	supportedConfigGVRs := []schema.GroupVersionResource{}
 	for gvr := range utils.BuiltInAddOnConfigGVRs {
 		supportedConfigGVRs = append(supportedConfigGVRs, gvr)
 	}
+	// the config resources declared by the ClusterManagementAddOn in addition to the built-in ones
+	cma, err := a.cmaLister.Get(a.addonName)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("GetAgentAddonOptions failed to get addon %s: %v", a.addonName, err))
+	} else {
+		for _, gvr := range TemplateConfigGVRs(cma) {
+			if !utils.BuiltInAddOnConfigGVRs[gvr] {
+				supportedConfigGVRs = append(supportedConfigGVRs, gvr)
+			}
+		}
+	}
 
 	agentAddonOptions := agent.AgentAddonOptions{
 		AddonName: a.addonName,
 		HealthProber: &agent.HealthProber{
 			Type: agent.HealthProberTypeWorkloadAvailability,
 		},
-		HostedModeInfoFunc:  addonconstants.GetHostedModeInfo,
+		HostedModeInfoFunc: addonconstants.GetHostedModeInfo,
+		// the manifests annotated with the hosted manifest location "hosting" are deployed on the hosting cluster
+		// when the addon is installed in the Hosted mode
+		HostedModeEnabled:   true,
 		SupportedConfigGVRs: supportedConfigGVRs,
 		Registration: &agent.RegistrationOption{
 			CSRConfigurations:     a.TemplateCSRConfigurationsFunc(),
@@ -141,7 +172,9 @@ func (a *CRDTemplateAgentAddon) GetAgentAddonOptions() agent.AgentAddonOptions {
 		AgentDeployTriggerClusterFilter: func(old, new *clusterv1.ManagedCluster) bool {
 			return utils.ClusterImageRegistriesAnnotationChanged(old, new) ||
 				// if the cluster changes from unknow to true, recheck the health of the addon immediately
-				utils.ClusterAvailableConditionChanged(old, new)
+				utils.ClusterAvailableConditionChanged(old, new) ||
+				// recheck the compatibility of the cluster
+				clusterVersionOrClaimsChanged(old, new)
 		},
 		// enable the ConfigCheckEnabled flag to check the configured condition before rendering manifests
 		ConfigCheckEnabled: true,
@@ -158,9 +191,40 @@ func (a *CRDTemplateAgentAddon) GetAgentAddonOptions() agent.AgentAddonOptions {
 	}
 	agentAddonOptions.ManifestConfigs = template.Spec.AgentSpec.ManifestConfigs
 
+	healthProber, err := GetHealthProber(template)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("GetAgentAddonOptions failed to get addon %s health prober: %v", a.addonName, err))
+		return agentAddonOptions
+	}
+	if healthProber != nil {
+		agentAddonOptions.HealthProber = healthProber.toAgentHealthProber()
+	}
+
 	return agentAddonOptions
 }
 
+// isCompatible checks whether the cluster meets the requirements declared by the ClusterManagementAddOn.
+func (a *CRDTemplateAgentAddon) isCompatible(cluster *clusterv1.ManagedCluster) (bool, error) {
+	cma, err := a.cmaLister.Get(a.addonName)
+	if err != nil {
+		return false, err
+	}
+	requirements, err := compatibility.GetRequirements(cma)
+	if err != nil {
+		return false, err
+	}
+	compatible, _ := compatibility.Check(cluster, requirements)
+	return compatible, nil
+}
+
+func clusterVersionOrClaimsChanged(old, new *clusterv1.ManagedCluster) bool {
+	if old == nil || new == nil {
+		return false
+	}
+	return old.Status.Version != new.Status.Version ||
+		!equality.Semantic.DeepEqual(old.Status.ClusterClaims, new.Status.ClusterClaims)
+}
+
 func (a *CRDTemplateAgentAddon) renderObjects(
 	cluster *clusterv1.ManagedCluster,
 	addon *addonapiv1alpha1.ManagedClusterAddOn,
@@ -174,27 +238,48 @@ func (a *CRDTemplateAgentAddon) renderObjects(
 		"presetValues", presetValues,
 		"configValues", configValues,
 		"privateValues", privateValues)
+	hosted := getHostedInfo(addon, privateValues)
+	engine, err := GetTemplateEngine(template)
+	if err != nil {
+		return objects, err
+	}
 
 	for _, manifest := range template.Spec.AgentSpec.Workload.Manifests {
-		t := fasttemplate.New(string(manifest.Raw), "{{", "}}")
-		manifestStr := t.ExecuteString(configValues)
+		object, err := renderManifest(engine, manifest, configValues, cluster)
+		if err != nil {
+			return objects, err
+		}
 		a.logger.V(4).Info("Addon render result",
 			"addonNamespace", addon.Namespace,
 			"addonName", addon.Name,
-			"renderResult", manifestStr)
-		object := &unstructured.Unstructured{}
-		if err := object.UnmarshalJSON([]byte(manifestStr)); err != nil {
+			"renderResult", object)
+
+		object, err = a.decorateObject(template, object, presetValues, privateValues, hosted)
+		if err != nil {
 			return objects, err
 		}
+		objects = append(objects, object)
+	}
 
-		object, err = a.decorateObject(template, object, presetValues, privateValues)
+	chartSource, err := GetHelmChartSource(template)
+	if err != nil {
+		return objects, err
+	}
+	if chartSource != nil {
+		chartObjects, err := a.renderHelmChart(cluster, chartSource, configValues)
 		if err != nil {
 			return objects, err
 		}
-		objects = append(objects, object)
+		for _, object := range chartObjects {
+			object, err = a.decorateObject(template, object, presetValues, privateValues, hosted)
+			if err != nil {
+				return objects, err
+			}
+			objects = append(objects, object)
+		}
 	}
 
-	additionalObjects, err := a.injectAdditionalObjects(template, presetValues, privateValues)
+	additionalObjects, err := a.injectAdditionalObjects(template, presetValues, privateValues, hosted)
 	if err != nil {
 		return objects, err
 	}
@@ -207,11 +292,25 @@ func (a *CRDTemplateAgentAddon) decorateObject(
 	template *addonapiv1alpha1.AddOnTemplate,
 	obj *unstructured.Unstructured,
 	orderedValues orderedValues,
-	privateValues addonfactory.Values) (*unstructured.Unstructured, error) {
+	privateValues addonfactory.Values,
+	hosted *hostedInfo) (*unstructured.Unstructured, error) {
+	registrationTemplate := template
+	namespaceDecorator := newNamespaceDecorator(privateValues)
+	var podDecorators []podTemplateSpecDecorator
+	if hosted != nil {
+		if isHostingManifest(obj) {
+			namespaceDecorator = newNamespaceDecoratorWithNamespace(hosted.hostingNamespace)
+			podDecorators = append(podDecorators, newManagedKubeconfigDecorator())
+		} else {
+			// the secrets of the registrations are on the hosting cluster in the Hosted mode
+			registrationTemplate = nil
+		}
+	}
+
 	decorators := []decorator{
-		newDeploymentDecorator(a.logger, a.addonName, template, orderedValues, privateValues),
-		newDaemonSetDecorator(a.logger, a.addonName, template, orderedValues, privateValues),
-		newNamespaceDecorator(privateValues),
+		newDeploymentDecorator(a.logger, a.addonName, registrationTemplate, orderedValues, privateValues, podDecorators...),
+		newDaemonSetDecorator(a.logger, a.addonName, registrationTemplate, orderedValues, privateValues, podDecorators...),
+		namespaceDecorator,
 	}
 
 	var err error
@@ -228,7 +327,8 @@ func (a *CRDTemplateAgentAddon) decorateObject(
 func (a *CRDTemplateAgentAddon) injectAdditionalObjects(
 	template *addonapiv1alpha1.AddOnTemplate,
 	orderedValues orderedValues,
-	privateValues addonfactory.Values) ([]runtime.Object, error) {
+	privateValues addonfactory.Values,
+	hosted *hostedInfo) ([]runtime.Object, error) {
 	injectors := []objectsInjector{
 		newProxyHandler(a.logger, a.addonName, privateValues),
 	}
@@ -261,6 +361,17 @@ func (a *CRDTemplateAgentAddon) injectAdditionalObjects(
 			}
 
 			objs = append(objs, unstructuredObject)
+
+			// the workloads on both the managed and hosting cluster may refer to the objects in the Hosted mode
+			if hosted != nil {
+				hostingObject, err := newNamespaceDecoratorWithNamespace(hosted.hostingNamespace).decorate(
+					unstructuredObject.DeepCopy())
+				if err != nil {
+					return nil, err
+				}
+				setHostingManifest(hostingObject)
+				objs = append(objs, hostingObject)
+			}
 		}
 
 	}
@@ -306,8 +417,14 @@ func (a *CRDTemplateAgentAddon) TemplateAgentRegistrationNamespaceFunc(
 		return "", fmt.Errorf("addon %s template not found in status", addon.Name)
 	}
 
+	// the agent runs on the hosting cluster in the Hosted mode, the registration secrets are in the agent namespace
+	// of the hosted klusterlet by default.
+	if installMode, _ := addonconstants.GetHostedModeInfo(addon, nil); installMode == addonconstants.InstallModeHosted {
+		return a.hostedAgentInstallNamespace(addon)
+	}
+
 	// pick the namespace of the first deployment, if there is no deployment, pick the namespace of the first daemonset
-	var desiredNS = "open-cluster-management-agent-addon"
+	var desiredNS = defaultAgentInstallNamespace
 	var firstDeploymentNamespace, firstDaemonSetNamespace string
 	for _, manifest := range template.Spec.AgentSpec.Workload.Manifests {
 		object := &unstructured.Unstructured{}
@@ -345,3 +462,15 @@ func (a *CRDTemplateAgentAddon) TemplateAgentRegistrationNamespaceFunc(
 	}
 	return desiredNS, nil
 }
+
+func (a *CRDTemplateAgentAddon) hostedAgentInstallNamespace(addon *addonapiv1alpha1.ManagedClusterAddOn) (string, error) {
+	overrideNs, err := utils.AgentInstallNamespaceFromDeploymentConfigFunc(
+		utils.NewAddOnDeploymentConfigGetter(a.addonClient))(addon)
+	if err != nil {
+		return "", err
+	}
+	if len(overrideNs) > 0 {
+		return overrideNs, nil
+	}
+	return defaultHostingNamespace(addon.Namespace), nil
+}
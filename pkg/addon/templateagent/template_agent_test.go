@@ -866,6 +866,35 @@ func TestAgentInstallNamespace(t *testing.T) {
 			),
 			expected: "test-install-namespace",
 		},
+		{
+			name: "hosted mode",
+			addonTemplate: &addonapiv1alpha1.AddOnTemplate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "hello-template",
+				},
+				Spec: addonapiv1alpha1.AddOnTemplateSpec{
+					AgentSpec: workapiv1.ManifestWorkSpec{
+						Workload: workapiv1.ManifestsTemplate{
+							Manifests: []workapiv1.Manifest{
+								{RawExtension: runtime.RawExtension{Raw: deploymentRaw}},
+							},
+						},
+					},
+				},
+			},
+			managedClusterAddonBuilder: newManagedClusterAddonBuilder(
+				&addonapiv1alpha1.ManagedClusterAddOn{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      addonName,
+						Namespace: clusterName,
+						Annotations: map[string]string{
+							addonapiv1alpha1.HostingClusterNameAnnotationKey: "hosting",
+						},
+					},
+				},
+			),
+			expected: "klusterlet-cluster1",
+		},
 		{
 			name: "empty string agentInstallNamespace should use template namespace",
 			addonTemplate: &addonapiv1alpha1.AddOnTemplate{
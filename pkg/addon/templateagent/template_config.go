@@ -0,0 +1,358 @@
+package templateagent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"google.golang.org/protobuf/types/known/structpb"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	celconfig "k8s.io/apiserver/pkg/apis/cel"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/util/jsonpath"
+
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	ocmcelcommon "open-cluster-management.io/sdk-go/pkg/cel/common"
+	ocmcellibrary "open-cluster-management.io/sdk-go/pkg/cel/library"
+)
+
+// TemplateConfigsAnnotationKey is the annotation key of the ClusterManagementAddOn declaring the config resources,
+// in addition to the AddOnTemplate and AddOnDeploymentConfig, that the template addon consumes. The value is a json
+// encoded list of TemplateConfig. The spec hash of the configs is tracked in the status of the addons, so the
+// changes of the configs are rolled out with the install strategy of the ClusterManagementAddOn. The addon-manager
+// on the hub must be granted to list and watch the config resources.
+const TemplateConfigsAnnotationKey = "addon.open-cluster-management.io/template-configs"
+
+// TemplateConfig is a config resource consumed by the template addon. The config object of the resource referenced
+// by the addon is converted to the variables of the template.
+type TemplateConfig struct {
+	Group    string `json:"group,omitempty"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+	// Variables are the template variables read from the config object. If no variable is defined, the fields in
+	// the spec of the config object are flattened into the variables, e.g. spec.logging.logLevel is converted to
+	// the variable LOGGING_LOG_LEVEL.
+	Variables []ConfigVariable `json:"variables,omitempty"`
+}
+
+// ConfigVariable is a template variable read from the config object with exactly one of JSONPath or Expression.
+type ConfigVariable struct {
+	Name string `json:"name"`
+	// JSONPath is the json path of the value in the config object, e.g. {.spec.logLevel}
+	JSONPath string `json:"jsonPath,omitempty"`
+	// Expression is a CEL expression evaluating the value with the config object as the variable object,
+	// e.g. string(object.spec.replicas). The variable is not set if the expression evaluates to null or an empty
+	// optional, so the optional field selection, e.g. object.spec.?logLevel, skips the fields not set in the config.
+	Expression string `json:"expression,omitempty"`
+}
+
+// configExpressionPrograms caches the compiled programs of the expressions of the config variables.
+var configExpressionPrograms = newCELProgramCache(compileConfigExpression)
+
+// GetTemplateConfigs returns the config resources declared by the ClusterManagementAddOn.
+func GetTemplateConfigs(cma *addonapiv1alpha1.ClusterManagementAddOn) ([]TemplateConfig, error) {
+	value, ok := cma.Annotations[TemplateConfigsAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+
+	var configs []TemplateConfig
+	if err := json.Unmarshal([]byte(value), &configs); err != nil {
+		return nil, fmt.Errorf("invalid annotation %s of addon %s: %v", TemplateConfigsAnnotationKey, cma.Name, err)
+	}
+	for _, config := range configs {
+		if err := config.validate(); err != nil {
+			return nil, fmt.Errorf("invalid annotation %s of addon %s: %v", TemplateConfigsAnnotationKey, cma.Name, err)
+		}
+	}
+	return configs, nil
+}
+
+// TemplateConfigGVRs returns the resources of the configs declared by the ClusterManagementAddOn. The invalid
+// declarations are ignored.
+func TemplateConfigGVRs(cma *addonapiv1alpha1.ClusterManagementAddOn) []schema.GroupVersionResource {
+	configs, err := GetTemplateConfigs(cma)
+	if err != nil {
+		return nil
+	}
+
+	var gvrs []schema.GroupVersionResource
+	for _, config := range configs {
+		gvrs = append(gvrs, config.gvr())
+	}
+	sort.Slice(gvrs, func(i, j int) bool {
+		return gvrs[i].String() < gvrs[j].String()
+	})
+	return gvrs
+}
+
+func (c TemplateConfig) gvr() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: c.Group, Version: c.Version, Resource: c.Resource}
+}
+
+func (c TemplateConfig) validate() error {
+	if len(c.Version) == 0 || len(c.Resource) == 0 {
+		return fmt.Errorf("version and resource of config are required")
+	}
+	for _, variable := range c.Variables {
+		if len(variable.Name) == 0 {
+			return fmt.Errorf("name of the variable of config %s is required", c.gvr())
+		}
+		if _, ok := PrivateValuesKeys[variable.Name]; ok {
+			return fmt.Errorf("variable %s of config %s is reserved", variable.Name, c.gvr())
+		}
+		if (len(variable.JSONPath) == 0) == (len(variable.Expression) == 0) {
+			return fmt.Errorf("exactly one of jsonPath and expression of variable %s is required", variable.Name)
+		}
+		if len(variable.JSONPath) > 0 {
+			if _, err := parseJSONPath(variable.JSONPath); err != nil {
+				return fmt.Errorf("variable %s: %v", variable.Name, err)
+			}
+			continue
+		}
+		if _, err := configExpressionPrograms.get(variable.Expression); err != nil {
+			return fmt.Errorf("variable %s: %v", variable.Name, err)
+		}
+	}
+	return nil
+}
+
+// GetTemplateConfigValues returns a GetValuesFunc converting the configs declared by the ClusterManagementAddOn
+// and referenced by the addon to the template variables. The configs are read from the dynamic informers, which
+// are started by the addon manager for the supported config resources.
+func GetTemplateConfigValues(
+	cmaLister addonlisterv1alpha1.ClusterManagementAddOnLister,
+	dynamicInformers dynamicinformer.DynamicSharedInformerFactory) addonfactory.GetValuesFunc {
+	return func(_ *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) (addonfactory.Values, error) {
+		cma, err := cmaLister.Get(addon.Name)
+		if err != nil {
+			return nil, err
+		}
+		configs, err := GetTemplateConfigs(cma)
+		if err != nil {
+			return nil, err
+		}
+
+		values := addonfactory.Values{}
+		for _, config := range configs {
+			for _, configRef := range addon.Status.ConfigReferences {
+				if configRef.Group != config.Group || configRef.Resource != config.Resource || configRef.DesiredConfig == nil {
+					continue
+				}
+
+				lister := dynamicInformers.ForResource(config.gvr()).Lister()
+				var obj runtime.Object
+				if len(configRef.DesiredConfig.Namespace) > 0 {
+					obj, err = lister.ByNamespace(configRef.DesiredConfig.Namespace).Get(configRef.DesiredConfig.Name)
+				} else {
+					obj, err = lister.Get(configRef.DesiredConfig.Name)
+				}
+				if errors.IsNotFound(err) {
+					continue
+				}
+				if err != nil {
+					return nil, err
+				}
+				object, ok := obj.(*unstructured.Unstructured)
+				if !ok {
+					return nil, fmt.Errorf("unexpected config object %T", obj)
+				}
+
+				configValues, err := config.toValues(object)
+				if err != nil {
+					return nil, fmt.Errorf("failed to get the variables of config %s %s: %v",
+						config.gvr(), configRef.DesiredConfig.Name, err)
+				}
+				values = addonfactory.MergeValues(values, configValues)
+			}
+		}
+		return values, nil
+	}
+}
+
+// toValues converts the config object to the template variables.
+func (c TemplateConfig) toValues(object *unstructured.Unstructured) (addonfactory.Values, error) {
+	values := addonfactory.Values{}
+	if len(c.Variables) == 0 {
+		spec, ok := object.Object["spec"].(map[string]interface{})
+		if !ok {
+			return values, nil
+		}
+		if err := flattenValues("", spec, values); err != nil {
+			return nil, err
+		}
+		return values, nil
+	}
+
+	for _, variable := range c.Variables {
+		var value string
+		var found bool
+		var err error
+		if len(variable.JSONPath) > 0 {
+			value, found, err = evaluateJSONPath(variable.JSONPath, object)
+		} else {
+			value, found, err = evaluateExpression(variable.Expression, object)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("variable %s: %v", variable.Name, err)
+		}
+		if found {
+			values[variable.Name] = value
+		}
+	}
+	return values, nil
+}
+
+// flattenValues flattens the fields into values, the name of the value is the upper snake case of the field path.
+func flattenValues(prefix string, fields map[string]interface{}, values addonfactory.Values) error {
+	for key, field := range fields {
+		name := toUpperSnakeCase(key)
+		if len(prefix) > 0 {
+			name = prefix + "_" + name
+		}
+
+		switch v := field.(type) {
+		case nil:
+			continue
+		case map[string]interface{}:
+			if err := flattenValues(name, v, values); err != nil {
+				return err
+			}
+		default:
+			value, err := formatValue(v)
+			if err != nil {
+				return err
+			}
+			values[name] = value
+		}
+	}
+	return nil
+}
+
+// toUpperSnakeCase converts a camel case field name to upper snake case, e.g. logLevel to LOG_LEVEL.
+func toUpperSnakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 &&
+			(unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			b.WriteRune('_')
+		}
+		if r == '-' || r == '.' {
+			r = '_'
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// formatValue formats a value as the template variable, the lists and objects are json encoded.
+func formatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool, int64, float64, int, int32:
+		return fmt.Sprint(v), nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
+
+// parseJSONPath parses the json path, the missing keys are allowed so the fields not set in the config are skipped.
+func parseJSONPath(path string) (*jsonpath.JSONPath, error) {
+	j := jsonpath.New("variable").AllowMissingKeys(true)
+	if err := j.Parse(path); err != nil {
+		return nil, fmt.Errorf("failed to parse json path %s: %v", path, err)
+	}
+	return j, nil
+}
+
+func evaluateJSONPath(path string, object *unstructured.Unstructured) (string, bool, error) {
+	j, err := parseJSONPath(path)
+	if err != nil {
+		return "", false, err
+	}
+	results, err := j.FindResults(object.UnstructuredContent())
+	if err != nil {
+		return "", false, err
+	}
+	if len(results) == 0 || len(results[0]) == 0 {
+		return "", false, nil
+	}
+
+	value, err := formatValue(results[0][0].Interface())
+	return value, err == nil, err
+}
+
+func compileConfigExpression(expression string) (cel.Program, error) {
+	env, err := cel.NewEnv(slices.Concat(
+		[]cel.EnvOption{cel.Variable("object", cel.DynType), ocmcellibrary.JsonLib()},
+		ocmcelcommon.BaseEnvOpts,
+	)...)
+	if err != nil {
+		return nil, err
+	}
+	ast, iss := env.Compile(expression)
+	if iss.Err() != nil {
+		return nil, fmt.Errorf("failed to compile expression %s: %v", expression, iss.Err())
+	}
+	return env.Program(ast,
+		cel.CostLimit(celconfig.PerCallLimit),
+		cel.InterruptCheckFrequency(celconfig.CheckFrequency),
+	)
+}
+
+func evaluateExpression(expression string, object *unstructured.Unstructured) (string, bool, error) {
+	program, err := configExpressionPrograms.get(expression)
+	if err != nil {
+		return "", false, err
+	}
+
+	result, _, err := program.ContextEval(context.Background(), map[string]interface{}{"object": object.Object})
+	if err != nil {
+		return "", false, err
+	}
+	// the optional field selection evaluates to an empty optional if the field is not set in the config
+	if optional, ok := result.(*types.Optional); ok {
+		if !optional.HasValue() {
+			return "", false, nil
+		}
+		result = optional.GetValue()
+	}
+	if result == types.NullValue {
+		return "", false, nil
+	}
+	if str, ok := result.(types.String); ok {
+		return string(str), true, nil
+	}
+
+	switch result.(type) {
+	case types.Int, types.Uint, types.Double, types.Bool:
+		return fmt.Sprint(result.Value()), true, nil
+	}
+
+	// the lists and maps are json encoded
+	native, err := result.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+	if err != nil {
+		return "", false, fmt.Errorf("unsupported result type %v", result.Type())
+	}
+	value, err := formatValue(native.(*structpb.Value).AsInterface())
+	return value, err == nil, err
+}
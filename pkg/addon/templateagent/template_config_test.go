@@ -0,0 +1,277 @@
+package templateagent
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/cel-go/cel"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"open-cluster-management.io/addon-framework/pkg/addonfactory"
+	"open-cluster-management.io/addon-framework/pkg/addonmanager/addontesting"
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+)
+
+var testLoggingGVR = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "loggings"}
+
+func TestGetTemplateConfigs(t *testing.T) {
+	cases := []struct {
+		name         string
+		annotation   string
+		expectedErr  bool
+		expectedGVRs []schema.GroupVersionResource
+	}{
+		{
+			name: "no configs",
+		},
+		{
+			name:        "invalid json",
+			annotation:  "invalid",
+			expectedErr: true,
+		},
+		{
+			name:        "no version",
+			annotation:  `[{"group": "example.com", "resource": "loggings"}]`,
+			expectedErr: true,
+		},
+		{
+			name:        "reserved variable",
+			annotation:  `[{"version": "v1", "resource": "loggings", "variables": [{"name": "__PROXY", "jsonPath": "{.spec}"}]}]`,
+			expectedErr: true,
+		},
+		{
+			name: "both json path and expression",
+			annotation: `[{"version": "v1", "resource": "loggings", ` +
+				`"variables": [{"name": "LEVEL", "jsonPath": "{.spec.level}", "expression": "object.spec.level"}]}]`,
+			expectedErr: true,
+		},
+		{
+			name: "invalid json path",
+			annotation: `[{"version": "v1", "resource": "loggings", ` +
+				`"variables": [{"name": "LEVEL", "jsonPath": "{.spec.level"}]}]`,
+			expectedErr: true,
+		},
+		{
+			name: "invalid expression",
+			annotation: `[{"version": "v1", "resource": "loggings", ` +
+				`"variables": [{"name": "LEVEL", "expression": "object.spec.level +"}]}]`,
+			expectedErr: true,
+		},
+		{
+			name: "valid",
+			annotation: `[{"group": "example.com", "version": "v1", "resource": "loggings"}, ` +
+				`{"group": "example.com", "version": "v1", "resource": "exporters"}]`,
+			expectedGVRs: []schema.GroupVersionResource{
+				{Group: "example.com", Version: "v1", Resource: "exporters"},
+				testLoggingGVR,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cma := addontesting.NewClusterManagementAddon("test", "", "").Build()
+			if len(c.annotation) > 0 {
+				cma.Annotations = map[string]string{TemplateConfigsAnnotationKey: c.annotation}
+			}
+			_, err := GetTemplateConfigs(cma)
+			if c.expectedErr != (err != nil) {
+				t.Fatalf("expected error %v, but got %v", c.expectedErr, err)
+			}
+			if gvrs := TemplateConfigGVRs(cma); !equality.Semantic.DeepEqual(gvrs, c.expectedGVRs) {
+				t.Errorf("expected gvrs %v, but got %v", c.expectedGVRs, gvrs)
+			}
+		})
+	}
+}
+
+func TestGetTemplateConfigValues(t *testing.T) {
+	config := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Logging",
+		"metadata":   map[string]interface{}{"name": "logging", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"logLevel":  "debug",
+			"verbosity": int64(4),
+			"output": map[string]interface{}{
+				"format":  "json",
+				"targets": []interface{}{"stdout", "file"},
+			},
+		},
+	}}
+
+	cases := []struct {
+		name           string
+		annotation     string
+		configRefs     []addonapiv1alpha1.ConfigReference
+		expectedErr    bool
+		expectedValues addonfactory.Values
+	}{
+		{
+			name:           "no configs",
+			expectedValues: addonfactory.Values{},
+		},
+		{
+			name:       "config is not referenced",
+			annotation: `[{"group": "example.com", "version": "v1", "resource": "loggings"}]`,
+			configRefs: []addonapiv1alpha1.ConfigReference{
+				newConfigReference("addon.open-cluster-management.io", "addondeploymentconfigs", "default", "config"),
+			},
+			expectedValues: addonfactory.Values{},
+		},
+		{
+			name:       "config is not found",
+			annotation: `[{"group": "example.com", "version": "v1", "resource": "loggings"}]`,
+			configRefs: []addonapiv1alpha1.ConfigReference{
+				newConfigReference("example.com", "loggings", "default", "missing"),
+			},
+			expectedValues: addonfactory.Values{},
+		},
+		{
+			name:       "flatten spec",
+			annotation: `[{"group": "example.com", "version": "v1", "resource": "loggings"}]`,
+			configRefs: []addonapiv1alpha1.ConfigReference{
+				newConfigReference("example.com", "loggings", "default", "logging"),
+			},
+			expectedValues: addonfactory.Values{
+				"LOG_LEVEL":      "debug",
+				"VERBOSITY":      "4",
+				"OUTPUT_FORMAT":  "json",
+				"OUTPUT_TARGETS": `["stdout","file"]`,
+			},
+		},
+		{
+			name: "variables",
+			annotation: `[{"group": "example.com", "version": "v1", "resource": "loggings", "variables": [` +
+				`{"name": "LEVEL", "jsonPath": "{.spec.logLevel}"}, ` +
+				`{"name": "VERBOSE", "expression": "object.spec.verbosity > 2"}, ` +
+				`{"name": "TARGETS", "expression": "object.spec.output.targets"}, ` +
+				`{"name": "FORMAT", "expression": "object.spec.output.format + '-v' + string(object.spec.verbosity)"}, ` +
+				`{"name": "MISSING", "jsonPath": "{.spec.missing}"}, ` +
+				`{"name": "MISSING_EXPRESSION", "expression": "object.spec.?missing"}]}]`,
+			configRefs: []addonapiv1alpha1.ConfigReference{
+				newConfigReference("example.com", "loggings", "default", "logging"),
+			},
+			expectedValues: addonfactory.Values{
+				"LEVEL":   "debug",
+				"VERBOSE": "true",
+				"TARGETS": `["stdout","file"]`,
+				"FORMAT":  "json-v4",
+			},
+		},
+		{
+			name: "missing field without optional selection",
+			annotation: `[{"group": "example.com", "version": "v1", "resource": "loggings", "variables": [` +
+				`{"name": "MISSING", "expression": "object.spec.missing"}]}]`,
+			configRefs: []addonapiv1alpha1.ConfigReference{
+				newConfigReference("example.com", "loggings", "default", "logging"),
+			},
+			expectedErr: true,
+		},
+		{
+			name: "invalid expression",
+			annotation: `[{"group": "example.com", "version": "v1", "resource": "loggings", "variables": [` +
+				`{"name": "LEVEL", "expression": "object.spec.logLevel +"}]}]`,
+			configRefs: []addonapiv1alpha1.ConfigReference{
+				newConfigReference("example.com", "loggings", "default", "logging"),
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cma := addontesting.NewClusterManagementAddon("test", "", "").Build()
+			if len(c.annotation) > 0 {
+				cma.Annotations = map[string]string{TemplateConfigsAnnotationKey: c.annotation}
+			}
+			addonInformers := addoninformers.NewSharedInformerFactory(fakeaddon.NewSimpleClientset(), 0)
+			if err := addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().GetStore().Add(cma); err != nil {
+				t.Fatal(err)
+			}
+			dynamicInformers := dynamicinformer.NewDynamicSharedInformerFactory(
+				dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), 0)
+			if err := dynamicInformers.ForResource(testLoggingGVR).Informer().GetStore().Add(config); err != nil {
+				t.Fatal(err)
+			}
+
+			addon := addontesting.NewAddon("test", "cluster1")
+			addon.Status.ConfigReferences = c.configRefs
+
+			getValues := GetTemplateConfigValues(addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Lister(),
+				dynamicInformers)
+			values, err := getValues(addontesting.NewManagedCluster("cluster1"), addon)
+			if c.expectedErr != (err != nil) {
+				t.Fatalf("expected error %v, but got %v", c.expectedErr, err)
+			}
+			if !equality.Semantic.DeepEqual(values, c.expectedValues) {
+				t.Errorf("expected values %v, but got %v", c.expectedValues, values)
+			}
+		})
+	}
+}
+
+func TestToUpperSnakeCase(t *testing.T) {
+	cases := map[string]string{
+		"logLevel":       "LOG_LEVEL",
+		"level":          "LEVEL",
+		"httpProxy":      "HTTP_PROXY",
+		"caBundleURL":    "CA_BUNDLE_URL",
+		"TLSMinVersion":  "TLS_MIN_VERSION",
+		"max-connection": "MAX_CONNECTION",
+	}
+	for name, expected := range cases {
+		if actual := toUpperSnakeCase(name); actual != expected {
+			t.Errorf("expected %s of %s, but got %s", expected, name, actual)
+		}
+	}
+}
+
+func newConfigReference(group, resource, namespace, name string) addonapiv1alpha1.ConfigReference {
+	return addonapiv1alpha1.ConfigReference{
+		ConfigGroupResource: addonapiv1alpha1.ConfigGroupResource{Group: group, Resource: resource},
+		DesiredConfig: &addonapiv1alpha1.ConfigSpecHash{
+			ConfigReferent: addonapiv1alpha1.ConfigReferent{Namespace: namespace, Name: name},
+			SpecHash:       "hash",
+		},
+	}
+}
+
+func TestCELProgramCacheBounded(t *testing.T) {
+	compiled := 0
+	cache := newCELProgramCache(func(expression string) (cel.Program, error) {
+		compiled++
+		return compileConfigExpression(expression)
+	})
+
+	for i := 0; i < celProgramCacheSize+1; i++ {
+		if _, err := cache.get(fmt.Sprintf("object.spec.level == %d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cache.programs.Len() != celProgramCacheSize {
+		t.Errorf("expected %d programs cached, but got %d", celProgramCacheSize, cache.programs.Len())
+	}
+
+	// the most recently used program is cached, while the least recently used one is evicted
+	if _, err := cache.get(fmt.Sprintf("object.spec.level == %d", celProgramCacheSize)); err != nil {
+		t.Fatal(err)
+	}
+	if compiled != celProgramCacheSize+1 {
+		t.Errorf("expected the cached program to be reused, but compiled %d times", compiled)
+	}
+	if _, err := cache.get("object.spec.level == 0"); err != nil {
+		t.Fatal(err)
+	}
+	if compiled != celProgramCacheSize+2 {
+		t.Errorf("expected the evicted program to be compiled again, but compiled %d times", compiled)
+	}
+}
@@ -0,0 +1,334 @@
+package templateagent
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/valyala/fasttemplate"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/json"
+
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+// TemplateEngineAnnotationKey is the annotation key of the AddOnTemplate choosing the engine used to render the
+// manifests. The value is one of:
+//   - Simple: the default, "{{KEY}}" in the manifests is replaced by the value of the variable KEY.
+//   - GoTemplate: each string value in the manifests is a go template with the sprig functions, except the ones
+//     accessing the environment, network and randomness and the ones with unbounded cost, and the fromJson and
+//     fromJsonArray functions decoding the json encoded variables. The template is executed with the
+//     TemplateContext, and the rendered string values of a manifest are limited to 500KiB in total.
+//     If a string value consists of a single action ending with one of the typed functions, e.g.
+//     "{{ .Values.REPLICAS | atoi }}" or "{{ .Values.TOLERATIONS | fromJsonArray | toJson }}", the rendered
+//     result is decoded as json, so integers, booleans, lists and objects can be rendered. A field or a
+//     list item is removed if the result is null.
+const TemplateEngineAnnotationKey = "addon.open-cluster-management.io/template-engine"
+
+const (
+	TemplateEngineSimple     = "Simple"
+	TemplateEngineGoTemplate = "GoTemplate"
+)
+
+// TemplateContext is the data the manifests are rendered with by the GoTemplate engine.
+type TemplateContext struct {
+	// Values are the variables of the addon, including the builtin, default and the customized variables of the
+	// AddOnDeploymentConfig, e.g. .Values.CLUSTER_NAME. The variables not set are rendered as empty strings.
+	Values  map[string]string
+	Cluster TemplateClusterContext
+}
+
+// TemplateClusterContext is the information of the managed cluster in the TemplateContext.
+type TemplateClusterContext struct {
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+	// Claims are the cluster claims reported by the managed cluster, keyed by the claim name.
+	Claims map[string]string
+}
+
+// typedFuncs are the functions returning non-string results. The result of a string value consisting of a single
+// action ending with these functions is decoded as json.
+var typedFuncs = map[string]bool{
+	"toJson":    true,
+	"toRawJson": true,
+	"atoi":      true,
+	"int":       true,
+	"int64":     true,
+	"float64":   true,
+	"eq":        true,
+	"ne":        true,
+	"lt":        true,
+	"le":        true,
+	"gt":        true,
+	"ge":        true,
+	"and":       true,
+	"or":        true,
+	"not":       true,
+	"empty":     true,
+	"hasKey":    true,
+	"contains":  true,
+	"hasPrefix": true,
+	"hasSuffix": true,
+}
+
+// unsafeFuncs are the sprig functions which are not allowed in the templates, since the result depends on the
+// environment of the addon manager rather than the addon and the cluster, or changes on every rendering which
+// causes the manifestworks to be updated endlessly, or the cost of the function is controlled by its arguments
+// which allows a template to exhaust the cpu and memory of the addon manager.
+var unsafeFuncs = []string{
+	"env", "expandenv", "getHostByName",
+	"now", "date", "dateInZone", "date_in_zone", "ago", "unixEpoch",
+	"randAlpha", "randAlphaNum", "randAscii", "randNumeric", "randBytes", "randInt", "uuidv4", "shuffle",
+	"genPrivateKey", "genCA", "genCAWithKey", "genSelfSignedCert", "genSelfSignedCertWithKey",
+	"genSignedCert", "genSignedCertWithKey", "derivePassword",
+	"until", "untilStep", "seq", "repeat", "bcrypt", "htpasswd",
+}
+
+// maxRenderedManifestSize is the max size of the rendered string values of a manifest, which is the default limit of
+// the manifests in a ManifestWork.
+const maxRenderedManifestSize = 500 * 1024
+
+// renderBuffer is the output of the templates of a manifest, the rendering fails once the total size of the output
+// exceeds the limit.
+type renderBuffer struct {
+	bytes.Buffer
+	remaining int
+}
+
+func (b *renderBuffer) Write(p []byte) (int, error) {
+	if len(p) > b.remaining {
+		return 0, fmt.Errorf("the rendered manifest exceeds the size limit of %d bytes", maxRenderedManifestSize)
+	}
+	b.remaining -= len(p)
+	return b.Buffer.Write(p)
+}
+
+var goTemplateFuncs = func() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	for _, name := range unsafeFuncs {
+		delete(funcs, name)
+	}
+	// the variables are strings, the json encoded lists and objects are decoded with the functions
+	funcs["fromJson"] = func(str string) (map[string]interface{}, error) {
+		m := map[string]interface{}{}
+		err := json.Unmarshal([]byte(str), &m)
+		return m, err
+	}
+	funcs["fromJsonArray"] = func(str string) ([]interface{}, error) {
+		a := []interface{}{}
+		err := json.Unmarshal([]byte(str), &a)
+		return a, err
+	}
+	return funcs
+}()
+
+// GetTemplateEngine returns the engine used to render the manifests of the template.
+func GetTemplateEngine(template *addonapiv1alpha1.AddOnTemplate) (string, error) {
+	engine, ok := template.Annotations[TemplateEngineAnnotationKey]
+	if !ok || len(engine) == 0 {
+		return TemplateEngineSimple, nil
+	}
+	switch engine {
+	case TemplateEngineSimple, TemplateEngineGoTemplate:
+		return engine, nil
+	}
+	return "", fmt.Errorf("invalid annotation %s: unsupported template engine %q", TemplateEngineAnnotationKey, engine)
+}
+
+// ValidateTemplateManifests validates the manifests of the template can be rendered by the template engine.
+func ValidateTemplateManifests(template *addonapiv1alpha1.AddOnTemplate) error {
+	engine, err := GetTemplateEngine(template)
+	if err != nil {
+		return err
+	}
+	if engine != TemplateEngineGoTemplate {
+		return nil
+	}
+
+	for i, manifest := range template.Spec.AgentSpec.Workload.Manifests {
+		obj := map[string]interface{}{}
+		if err := json.Unmarshal(manifest.Raw, &obj); err != nil {
+			return fmt.Errorf("manifest %d is invalid: %v", i, err)
+		}
+		if err := walkStrings(obj, "", func(path, value string) error {
+			if _, err := parseGoTemplate(path, value); err != nil {
+				return fmt.Errorf("manifest %d: %v", i, err)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderManifest renders the manifest with the engine of the template.
+func renderManifest(
+	engine string,
+	manifest workapiv1.Manifest,
+	configValues map[string]interface{},
+	cluster *clusterv1.ManagedCluster,
+) (*unstructured.Unstructured, error) {
+	object := &unstructured.Unstructured{}
+	if engine != TemplateEngineGoTemplate {
+		t := fasttemplate.New(string(manifest.Raw), "{{", "}}")
+		if err := object.UnmarshalJSON([]byte(t.ExecuteString(configValues))); err != nil {
+			return nil, err
+		}
+		return object, nil
+	}
+
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(manifest.Raw, &obj); err != nil {
+		return nil, err
+	}
+	buf := &renderBuffer{remaining: maxRenderedManifestSize}
+	rendered, _, err := renderGoTemplateValue(obj, "", newTemplateContext(configValues, cluster), buf)
+	if err != nil {
+		return nil, err
+	}
+	renderedObj, ok := rendered.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("the rendered manifest is not an object")
+	}
+	object.Object = renderedObj
+	return object, nil
+}
+
+func newTemplateContext(configValues map[string]interface{}, cluster *clusterv1.ManagedCluster) TemplateContext {
+	claims := map[string]string{}
+	for _, claim := range cluster.Status.ClusterClaims {
+		claims[claim.Name] = claim.Value
+	}
+	values := make(map[string]string, len(configValues))
+	for key, value := range configValues {
+		if str, ok := value.(string); ok {
+			values[key] = str
+			continue
+		}
+		values[key] = fmt.Sprint(value)
+	}
+	return TemplateContext{
+		Values: values,
+		Cluster: TemplateClusterContext{
+			Name:        cluster.Name,
+			Labels:      cluster.Labels,
+			Annotations: cluster.Annotations,
+			Claims:      claims,
+		},
+	}
+}
+
+// renderGoTemplateValue renders the string values in the value recursively, and returns false if the value is
+// rendered to null and should be removed.
+func renderGoTemplateValue(value interface{}, path string, ctx TemplateContext, buf *renderBuffer) (interface{}, bool, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			renderedItem, keep, err := renderGoTemplateValue(item, path+"."+key, ctx, buf)
+			if err != nil {
+				return nil, false, err
+			}
+			if keep {
+				rendered[key] = renderedItem
+			}
+		}
+		return rendered, true, nil
+	case []interface{}:
+		rendered := make([]interface{}, 0, len(v))
+		for i, item := range v {
+			renderedItem, keep, err := renderGoTemplateValue(item, fmt.Sprintf("%s[%d]", path, i), ctx, buf)
+			if err != nil {
+				return nil, false, err
+			}
+			if keep {
+				rendered = append(rendered, renderedItem)
+			}
+		}
+		return rendered, true, nil
+	case string:
+		return renderGoTemplateString(path, v, ctx, buf)
+	default:
+		return value, true, nil
+	}
+}
+
+func renderGoTemplateString(path, value string, ctx TemplateContext, buf *renderBuffer) (interface{}, bool, error) {
+	if !strings.Contains(value, "{{") {
+		return value, true, nil
+	}
+
+	t, err := parseGoTemplate(path, value)
+	if err != nil {
+		return nil, false, err
+	}
+	buf.Reset()
+	if err := t.Execute(buf, ctx); err != nil {
+		return nil, false, fmt.Errorf("failed to render %s: %v", path, err)
+	}
+	result := buf.String()
+	if !isTypedTemplate(t) {
+		return result, true, nil
+	}
+
+	var typed interface{}
+	if err := json.Unmarshal([]byte(result), &typed); err != nil {
+		return nil, false, fmt.Errorf("failed to render %s: the result %q is not valid json: %v", path, result, err)
+	}
+	return typed, typed != nil, nil
+}
+
+func parseGoTemplate(path, value string) (*template.Template, error) {
+	t, err := template.New(path).Funcs(goTemplateFuncs).Option("missingkey=zero").Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template of %s: %v", path, err)
+	}
+	return t, nil
+}
+
+// isTypedTemplate returns true if the template consists of a single action ending with a typed function.
+func isTypedTemplate(t *template.Template) bool {
+	if t.Tree == nil || t.Root == nil || len(t.Root.Nodes) != 1 {
+		return false
+	}
+	action, ok := t.Root.Nodes[0].(*parse.ActionNode)
+	if !ok || action.Pipe == nil || len(action.Pipe.Decl) > 0 || len(action.Pipe.Cmds) == 0 {
+		return false
+	}
+	last := action.Pipe.Cmds[len(action.Pipe.Cmds)-1]
+	if len(last.Args) == 0 {
+		return false
+	}
+	identifier, ok := last.Args[0].(*parse.IdentifierNode)
+	return ok && typedFuncs[identifier.Ident]
+}
+
+// walkStrings calls the fn on each string value in the value recursively.
+func walkStrings(value interface{}, path string, fn func(path, value string) error) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if err := walkStrings(item, path+"."+key, fn); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			if err := walkStrings(item, fmt.Sprintf("%s[%d]", path, i), fn); err != nil {
+				return err
+			}
+		}
+	case string:
+		if strings.Contains(v, "{{") {
+			return fn(path, v)
+		}
+	}
+	return nil
+}
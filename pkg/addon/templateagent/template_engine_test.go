@@ -0,0 +1,218 @@
+package templateagent
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	addonapiv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workapiv1 "open-cluster-management.io/api/work/v1"
+)
+
+func newEngineTemplate(engine string, manifests ...string) *addonapiv1alpha1.AddOnTemplate {
+	template := &addonapiv1alpha1.AddOnTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello-template"},
+	}
+	if len(engine) > 0 {
+		template.Annotations = map[string]string{TemplateEngineAnnotationKey: engine}
+	}
+	for _, manifest := range manifests {
+		template.Spec.AgentSpec.Workload.Manifests = append(template.Spec.AgentSpec.Workload.Manifests,
+			workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: []byte(manifest)}})
+	}
+	return template
+}
+
+func TestRenderManifest(t *testing.T) {
+	cluster := &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "cluster1",
+			Labels: map[string]string{"env": "prod"},
+		},
+		Status: clusterv1.ManagedClusterStatus{
+			ClusterClaims: []clusterv1.ManagedClusterClaim{{Name: "platform.open-cluster-management.io", Value: "AWS"}},
+		},
+	}
+	values := map[string]interface{}{
+		"CLUSTER_NAME": "cluster1",
+		"REPLICAS":     "3",
+		"TOLERATIONS":  `[{"key":"node-role","operator":"Exists"}]`,
+		"MESSAGE":      "<no value> is kept",
+		"LARGE":        strings.Repeat("x", 200*1024),
+	}
+
+	cases := []struct {
+		name           string
+		engine         string
+		manifest       string
+		expectedObject map[string]interface{}
+		expectedErr    string
+	}{
+		{
+			name:     "simple",
+			engine:   TemplateEngineSimple,
+			manifest: `{"kind":"ConfigMap","metadata":{"name":"{{CLUSTER_NAME}}"},"data":{"replicas":"{{REPLICAS}}"}}`,
+			expectedObject: map[string]interface{}{
+				"kind":     "ConfigMap",
+				"metadata": map[string]interface{}{"name": "cluster1"},
+				"data":     map[string]interface{}{"replicas": "3"},
+			},
+		},
+		{
+			name:   "strings",
+			engine: TemplateEngineGoTemplate,
+			manifest: `{"kind":"ConfigMap","metadata":{"name":"{{ .Values.CLUSTER_NAME }}-config"},
+"data":{"platform":"{{ index .Cluster.Claims \"platform.open-cluster-management.io\" | lower }}",
+"env":"{{ if eq .Cluster.Labels.env \"prod\" }}production{{ else }}dev{{ end }}",
+"region":"{{ .Cluster.Labels.region }}"}}`,
+			expectedObject: map[string]interface{}{
+				"kind":     "ConfigMap",
+				"metadata": map[string]interface{}{"name": "cluster1-config"},
+				"data": map[string]interface{}{
+					"platform": "aws",
+					"env":      "production",
+					"region":   "",
+				},
+			},
+		},
+		{
+			name:     "missing values",
+			engine:   TemplateEngineGoTemplate,
+			manifest: `{"kind":"ConfigMap","data":{"missing":"{{ .Values.MISSING }}","message":"{{ .Values.MESSAGE }}"}}`,
+			expectedObject: map[string]interface{}{
+				"kind": "ConfigMap",
+				"data": map[string]interface{}{
+					"missing": "",
+					"message": "<no value> is kept",
+				},
+			},
+		},
+		{
+			name:   "typed values",
+			engine: TemplateEngineGoTemplate,
+			manifest: `{"kind":"Deployment","spec":{"replicas":"{{ .Values.REPLICAS | atoi }}",
+"paused":"{{ ne .Cluster.Labels.env \"prod\" }}",
+"template":{"spec":{"tolerations":"{{ .Values.TOLERATIONS | fromJsonArray | toJson }}"}}}}`,
+			expectedObject: map[string]interface{}{
+				"kind": "Deployment",
+				"spec": map[string]interface{}{
+					"replicas": int64(3),
+					"paused":   false,
+					"template": map[string]interface{}{
+						"spec": map[string]interface{}{
+							"tolerations": []interface{}{
+								map[string]interface{}{"key": "node-role", "operator": "Exists"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:   "optional fields",
+			engine: TemplateEngineGoTemplate,
+			manifest: `{"kind":"ConfigMap","data":{
+"prod":"{{ if eq .Cluster.Labels.env \"prod\" }}{{ toJson \"yes\" }}{{ end }}",
+"dev":"{{ ternary \"yes\" nil (eq .Cluster.Labels.env \"dev\") | toJson }}"},
+"items":["a","{{ toJson nil }}"]}`,
+			expectedObject: map[string]interface{}{
+				"kind":  "ConfigMap",
+				"data":  map[string]interface{}{"prod": "\"yes\""},
+				"items": []interface{}{"a"},
+			},
+		},
+		{
+			name:        "invalid json variable",
+			engine:      TemplateEngineGoTemplate,
+			manifest:    `{"kind":"ConfigMap","data":{"name":"{{ .Values.CLUSTER_NAME | fromJsonArray | toJson }}"}}`,
+			expectedErr: "failed to render .data.name",
+		},
+		{
+			name:        "unsafe function",
+			engine:      TemplateEngineGoTemplate,
+			manifest:    `{"kind":"ConfigMap","data":{"home":"{{ env \"HOME\" }}"}}`,
+			expectedErr: "invalid template of .data.home",
+		},
+		{
+			name:        "unbounded function",
+			engine:      TemplateEngineGoTemplate,
+			manifest:    `{"kind":"ConfigMap","data":{"a":"{{ range until 100000000 }}a{{ end }}"}}`,
+			expectedErr: "invalid template of .data.a",
+		},
+		{
+			name:   "rendered manifest exceeds the size limit",
+			engine: TemplateEngineGoTemplate,
+			manifest: `{"kind":"ConfigMap","data":{"a":"{{ .Values.LARGE }}","b":"{{ .Values.LARGE }}",` +
+				`"c":"{{ .Values.LARGE }}"}}`,
+			expectedErr: "exceeds the size limit",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			manifest := workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: []byte(c.manifest)}}
+			object, err := renderManifest(c.engine, manifest, values, cluster)
+			if len(c.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), c.expectedErr) {
+					t.Fatalf("expected error %q, but got %v", c.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equality.Semantic.DeepEqual(object.Object, c.expectedObject) {
+				t.Errorf("expected object %v, but got %v", c.expectedObject, object.Object)
+			}
+		})
+	}
+}
+
+func TestValidateTemplateManifests(t *testing.T) {
+	cases := []struct {
+		name        string
+		template    *addonapiv1alpha1.AddOnTemplate
+		expectedErr string
+	}{
+		{
+			name:     "simple engine by default",
+			template: newEngineTemplate("", `{"kind":"ConfigMap","data":{"name":"{{ if }}"}}`),
+		},
+		{
+			name:        "unsupported engine",
+			template:    newEngineTemplate("Jinja", `{"kind":"ConfigMap"}`),
+			expectedErr: `unsupported template engine "Jinja"`,
+		},
+		{
+			name: "valid go template",
+			template: newEngineTemplate(TemplateEngineGoTemplate,
+				`{"kind":"ConfigMap","data":{"name":"{{ .Values.CLUSTER_NAME | upper }}"}}`),
+		},
+		{
+			name: "invalid go template",
+			template: newEngineTemplate(TemplateEngineGoTemplate,
+				`{"kind":"ConfigMap"}`,
+				`{"kind":"ConfigMap","data":{"list":["{{ if .Values.A }}"]}}`),
+			expectedErr: "manifest 1: invalid template of .data.list[0]",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ValidateTemplateManifests(c.template)
+			if len(c.expectedErr) == 0 {
+				if err != nil {
+					t.Errorf("expected no error, but got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.expectedErr) {
+				t.Errorf("expected error %q, but got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
@@ -144,10 +144,13 @@ func (a *CRDTemplateAgentAddon) getValues(
 
 func (a *CRDTemplateAgentAddon) getBuiltinValues(
 	cluster *clusterv1.ManagedCluster,
-	_ *addonapiv1alpha1.ManagedClusterAddOn,
+	addon *addonapiv1alpha1.ManagedClusterAddOn,
 	privateValues map[string]interface{}) ([]string, addonfactory.Values, error) {
 	builtinValues := templateCRDBuiltinValues{}
 	builtinValues.ClusterName = cluster.GetName()
+	if hosted := getHostedInfo(addon, privateValues); hosted != nil {
+		builtinValues.HostingClusterName = hosted.hostingClusterName
+	}
 
 	namespace, ok := privateValues[InstallNamespacePrivateValueKey]
 	if ok && namespace != nil {
@@ -33,5 +33,11 @@ func SetupWebhookServer(opts *commonoptions.WebhookOptions) error {
 		&addonv1beta1.ClusterManagementAddOnWebhook{},
 	)
 
+	// Register AddOnTemplate validating webhook
+	opts.InstallWebhook(&addonv1alpha1.AddOnTemplateWebhook{})
+
+	// Register ManagedClusterAddOn deletion validating webhook
+	opts.InstallWebhook(&addonv1alpha1.ManagedClusterAddOnDeletionWebhook{})
+
 	return nil
 }
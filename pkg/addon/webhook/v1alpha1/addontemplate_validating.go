@@ -0,0 +1,52 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package v1alpha1
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+
+	"open-cluster-management.io/ocm/pkg/addon/templateagent"
+)
+
+var _ admission.Validator[*addonv1alpha1.AddOnTemplate] = &AddOnTemplateWebhook{}
+
+// AddOnTemplateWebhook implements the validating webhook for AddOnTemplate
+type AddOnTemplateWebhook struct{}
+
+func (w *AddOnTemplateWebhook) Init(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr, &addonv1alpha1.AddOnTemplate{}).
+		WithValidator(w).
+		Complete()
+}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (w *AddOnTemplateWebhook) ValidateCreate(
+	_ context.Context, template *addonv1alpha1.AddOnTemplate) (admission.Warnings, error) {
+	return nil, validateAddOnTemplate(template)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (w *AddOnTemplateWebhook) ValidateUpdate(
+	_ context.Context, _, template *addonv1alpha1.AddOnTemplate) (admission.Warnings, error) {
+	return nil, validateAddOnTemplate(template)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (w *AddOnTemplateWebhook) ValidateDelete(
+	_ context.Context, _ *addonv1alpha1.AddOnTemplate) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateAddOnTemplate validates the manifests of the template can be rendered by its template engine.
+func validateAddOnTemplate(template *addonv1alpha1.AddOnTemplate) error {
+	if err := templateagent.ValidateTemplateManifests(template); err != nil {
+		return apierrors.NewBadRequest(err.Error())
+	}
+	return nil
+}
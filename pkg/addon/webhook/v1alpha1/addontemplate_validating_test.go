@@ -0,0 +1,79 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/ocm/pkg/addon/templateagent"
+)
+
+func newAddOnTemplate(engine, manifest string) *addonv1alpha1.AddOnTemplate {
+	return &addonv1alpha1.AddOnTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "hello-template",
+			Annotations: map[string]string{templateagent.TemplateEngineAnnotationKey: engine},
+		},
+		Spec: addonv1alpha1.AddOnTemplateSpec{
+			AddonName: "hello",
+			AgentSpec: workv1.ManifestWorkSpec{
+				Workload: workv1.ManifestsTemplate{
+					Manifests: []workv1.Manifest{{RawExtension: runtime.RawExtension{Raw: []byte(manifest)}}},
+				},
+			},
+		},
+	}
+}
+
+func TestAddOnTemplateValidate(t *testing.T) {
+	cases := []struct {
+		name        string
+		template    *addonv1alpha1.AddOnTemplate
+		expectedErr bool
+	}{
+		{
+			name:     "simple template",
+			template: newAddOnTemplate(templateagent.TemplateEngineSimple, `{"kind":"ConfigMap","data":{"a":"{{A}}"}}`),
+		},
+		{
+			name: "valid go template",
+			template: newAddOnTemplate(templateagent.TemplateEngineGoTemplate,
+				`{"kind":"ConfigMap","data":{"a":"{{ .Values.A | default \"a\" }}"}}`),
+		},
+		{
+			name: "invalid go template",
+			template: newAddOnTemplate(templateagent.TemplateEngineGoTemplate,
+				`{"kind":"ConfigMap","data":{"a":"{{ range .Values.A }}"}}`),
+			expectedErr: true,
+		},
+		{
+			name:        "unsupported engine",
+			template:    newAddOnTemplate("Unknown", `{"kind":"ConfigMap"}`),
+			expectedErr: true,
+		},
+	}
+
+	w := &AddOnTemplateWebhook{}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, createErr := w.ValidateCreate(context.TODO(), c.template)
+			_, updateErr := w.ValidateUpdate(context.TODO(), c.template, c.template)
+			for _, err := range []error{createErr, updateErr} {
+				if c.expectedErr != (err != nil) {
+					t.Errorf("expected error %v, but got %v", c.expectedErr, err)
+				}
+				if err != nil && !apierrors.IsBadRequest(err) {
+					t.Errorf("expected bad request error, but got %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	addonv1alpha1client "open-cluster-management.io/api/client/addon/clientset/versioned"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+	clusterv1client "open-cluster-management.io/api/client/cluster/clientset/versioned"
+	clusterinformers "open-cluster-management.io/api/client/cluster/informers/externalversions"
+	clusterlisterv1 "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
+
+	"open-cluster-management.io/ocm/pkg/addon/controllers/addonmanagement"
+)
+
+// ManagedClusterAddOnDeletionWebhookPath is the path of the webhook validating the deletion of ManagedClusterAddOn.
+const ManagedClusterAddOnDeletionWebhookPath = "/validate-addon-open-cluster-management-io-v1alpha1-managedclusteraddon-deletion"
+
+// ManagedClusterAddOnDeletionWebhook rejects the deletion of a ManagedClusterAddOn while the addons depending on it
+// exist on the cluster, so the dependency is kept on every removal path, including the direct deletion and the
+// garbage collection after the ClusterManagementAddOn is deleted. The deletion is always allowed once the cluster
+// namespace or the cluster is deleting, so the cleanup of a cluster is never blocked.
+type ManagedClusterAddOnDeletionWebhook struct {
+	cmaLister       addonlisterv1alpha1.ClusterManagementAddOnLister
+	addonLister     addonlisterv1alpha1.ManagedClusterAddOnLister
+	clusterLister   clusterlisterv1.ManagedClusterLister
+	namespaceLister corev1listers.NamespaceLister
+	hasSynced       []cache.InformerSynced
+}
+
+var _ admission.Handler = &ManagedClusterAddOnDeletionWebhook{}
+
+func (w *ManagedClusterAddOnDeletionWebhook) Init(mgr ctrl.Manager) error {
+	kubeClient, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+	addonClient, err := addonv1alpha1client.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+	clusterClient, err := clusterv1client.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+
+	kubeInformers := kubeinformers.NewSharedInformerFactory(kubeClient, 30*time.Minute)
+	addonInformers := addoninformers.NewSharedInformerFactory(addonClient, 30*time.Minute)
+	clusterInformers := clusterinformers.NewSharedInformerFactory(clusterClient, 30*time.Minute)
+	w.cmaLister = addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Lister()
+	w.addonLister = addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister()
+	w.clusterLister = clusterInformers.Cluster().V1().ManagedClusters().Lister()
+	w.namespaceLister = kubeInformers.Core().V1().Namespaces().Lister()
+	w.hasSynced = []cache.InformerSynced{
+		addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().HasSynced,
+		addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().HasSynced,
+		clusterInformers.Cluster().V1().ManagedClusters().Informer().HasSynced,
+		kubeInformers.Core().V1().Namespaces().Informer().HasSynced,
+	}
+	if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		kubeInformers.Start(ctx.Done())
+		addonInformers.Start(ctx.Done())
+		clusterInformers.Start(ctx.Done())
+		<-ctx.Done()
+		return nil
+	})); err != nil {
+		return err
+	}
+
+	mgr.GetWebhookServer().Register(ManagedClusterAddOnDeletionWebhookPath, &admission.Webhook{Handler: w})
+	return nil
+}
+
+// Handle rejects the deletion of the addon if the addons depending on it are installed on the cluster.
+func (w *ManagedClusterAddOnDeletionWebhook) Handle(_ context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Delete {
+		return admission.Allowed("")
+	}
+
+	for _, hasSynced := range w.hasSynced {
+		if !hasSynced() {
+			return admission.Errored(http.StatusServiceUnavailable, fmt.Errorf("the addons are not synced"))
+		}
+	}
+
+	deleting, err := w.isClusterDeleting(req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if deleting {
+		return admission.Allowed("")
+	}
+
+	cmas, err := w.cmaLister.List(labels.Everything())
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	dependents, err := addonmanagement.GetDependents(cmas, func(addonName string) (bool, error) {
+		_, err := w.addonLister.ManagedClusterAddOns(req.Namespace).Get(addonName)
+		switch {
+		case apierrors.IsNotFound(err):
+			return false, nil
+		case err != nil:
+			return false, err
+		}
+		return true, nil
+	}, req.Name)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if len(dependents) > 0 {
+		return admission.Denied(fmt.Sprintf("addons %s depending on addon %s exist on cluster %s",
+			strings.Join(dependents, ", "), req.Name, req.Namespace))
+	}
+	return admission.Allowed("")
+}
+
+// isClusterDeleting returns true if the cluster namespace or the cluster is deleting or does not exist.
+func (w *ManagedClusterAddOnDeletionWebhook) isClusterDeleting(clusterName string) (bool, error) {
+	namespace, err := w.namespaceLister.Get(clusterName)
+	switch {
+	case apierrors.IsNotFound(err):
+		return true, nil
+	case err != nil:
+		return false, err
+	case !namespace.DeletionTimestamp.IsZero():
+		return true, nil
+	}
+
+	cluster, err := w.clusterLister.Get(clusterName)
+	switch {
+	case apierrors.IsNotFound(err):
+		return true, nil
+	case err != nil:
+		return false, err
+	}
+	return !cluster.DeletionTimestamp.IsZero(), nil
+}
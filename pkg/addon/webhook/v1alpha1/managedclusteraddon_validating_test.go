@@ -0,0 +1,137 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	fakeaddon "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	fakecluster "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterinformers "open-cluster-management.io/api/client/cluster/informers/externalversions"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	"open-cluster-management.io/ocm/pkg/addon/controllers/addonmanagement"
+)
+
+func TestManagedClusterAddOnDeletionWebhook(t *testing.T) {
+	newCMA := func(name, dependencies string) *addonv1alpha1.ClusterManagementAddOn {
+		cma := &addonv1alpha1.ClusterManagementAddOn{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if len(dependencies) > 0 {
+			cma.Annotations = map[string]string{addonmanagement.DependenciesAnnotationKey: dependencies}
+		}
+		return cma
+	}
+	newAddon := func(name, cluster string) *addonv1alpha1.ManagedClusterAddOn {
+		return &addonv1alpha1.ManagedClusterAddOn{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: cluster}}
+	}
+	now := metav1.Now()
+	cmas := []*addonv1alpha1.ClusterManagementAddOn{newCMA("dep", ""), newCMA("test", `[{"name": "dep"}]`)}
+
+	cases := []struct {
+		name      string
+		operation admissionv1.Operation
+		addons    []*addonv1alpha1.ManagedClusterAddOn
+		namespace *corev1.Namespace
+		cluster   *clusterv1.ManagedCluster
+		allowed   bool
+	}{
+		{
+			name:      "not a deletion",
+			operation: admissionv1.Update,
+			addons:    []*addonv1alpha1.ManagedClusterAddOn{newAddon("dep", "cluster1"), newAddon("test", "cluster1")},
+			namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}},
+			cluster:   &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}},
+			allowed:   true,
+		},
+		{
+			name:      "no dependents",
+			operation: admissionv1.Delete,
+			addons:    []*addonv1alpha1.ManagedClusterAddOn{newAddon("dep", "cluster1"), newAddon("test", "cluster2")},
+			namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}},
+			cluster:   &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}},
+			allowed:   true,
+		},
+		{
+			name:      "dependents exist",
+			operation: admissionv1.Delete,
+			addons:    []*addonv1alpha1.ManagedClusterAddOn{newAddon("dep", "cluster1"), newAddon("test", "cluster1")},
+			namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}},
+			cluster:   &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}},
+		},
+		{
+			name:      "cluster namespace is deleting",
+			operation: admissionv1.Delete,
+			addons:    []*addonv1alpha1.ManagedClusterAddOn{newAddon("dep", "cluster1"), newAddon("test", "cluster1")},
+			namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "cluster1", DeletionTimestamp: &now}},
+			cluster:   &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}},
+			allowed:   true,
+		},
+		{
+			name:      "cluster is deleting",
+			operation: admissionv1.Delete,
+			addons:    []*addonv1alpha1.ManagedClusterAddOn{newAddon("dep", "cluster1"), newAddon("test", "cluster1")},
+			namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}},
+			cluster:   &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: "cluster1", DeletionTimestamp: &now}},
+			allowed:   true,
+		},
+		{
+			name:      "cluster is deleted",
+			operation: admissionv1.Delete,
+			addons:    []*addonv1alpha1.ManagedClusterAddOn{newAddon("dep", "cluster1"), newAddon("test", "cluster1")},
+			namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "cluster1"}},
+			allowed:   true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kubeInformers := kubeinformers.NewSharedInformerFactory(kubefake.NewSimpleClientset(), 10*time.Minute)
+			addonInformers := addoninformers.NewSharedInformerFactory(fakeaddon.NewSimpleClientset(), 10*time.Minute)
+			clusterInformers := clusterinformers.NewSharedInformerFactory(fakecluster.NewSimpleClientset(), 10*time.Minute)
+			for _, cma := range cmas {
+				if err := addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Informer().GetStore().Add(cma); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, addon := range c.addons {
+				if err := addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(addon); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := kubeInformers.Core().V1().Namespaces().Informer().GetStore().Add(c.namespace); err != nil {
+				t.Fatal(err)
+			}
+			if c.cluster != nil {
+				if err := clusterInformers.Cluster().V1().ManagedClusters().Informer().GetStore().Add(c.cluster); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			w := &ManagedClusterAddOnDeletionWebhook{
+				cmaLister:       addonInformers.Addon().V1alpha1().ClusterManagementAddOns().Lister(),
+				addonLister:     addonInformers.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
+				clusterLister:   clusterInformers.Cluster().V1().ManagedClusters().Lister(),
+				namespaceLister: kubeInformers.Core().V1().Namespaces().Lister(),
+			}
+			resp := w.Handle(context.TODO(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: c.operation,
+				Namespace: "cluster1",
+				Name:      "dep",
+			}})
+			if resp.Allowed != c.allowed {
+				t.Errorf("expected allowed %v, but got %v: %v", c.allowed, resp.Allowed, resp.Result)
+			}
+		})
+	}
+}
@@ -23,6 +23,8 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(gv,
 		&ManagedClusterAddOn{},
 		&ClusterManagementAddOn{},
+		&addonv1alpha1.AddOnTemplate{},
+		&addonv1alpha1.AddOnTemplateList{},
 	)
 	metav1.AddToGroupVersion(scheme, gv)
 	return nil
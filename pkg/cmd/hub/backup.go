@@ -0,0 +1,36 @@
+package hub
+
+import (
+	"github.com/spf13/cobra"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"open-cluster-management.io/ocm/pkg/registration/hub/backup"
+)
+
+func NewHubBackup() *cobra.Command {
+	opts := backup.NewOptions()
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Export the hub resources into a backup file",
+		RunE: func(c *cobra.Command, args []string) error {
+			return opts.RunBackup(ctrl.SetupSignalHandler())
+		},
+	}
+
+	opts.AddBackupFlags(cmd.Flags())
+	return cmd
+}
+
+func NewHubRestore() *cobra.Command {
+	opts := backup.NewOptions()
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Restore the hub resources from a backup file",
+		RunE: func(c *cobra.Command, args []string) error {
+			return opts.RunRestore(ctrl.SetupSignalHandler())
+		},
+	}
+
+	opts.AddFlags(cmd.Flags())
+	return cmd
+}
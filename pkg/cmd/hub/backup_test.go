@@ -0,0 +1,29 @@
+package hub
+
+import (
+	"testing"
+)
+
+func TestNewHubBackup(t *testing.T) {
+	cmd := NewHubBackup()
+	if cmd.Use != "backup" {
+		t.Errorf("expected backup, but got %s", cmd.Use)
+	}
+	for _, flag := range []string{"kubeconfig", "file", "hub-server"} {
+		if cmd.Flags().Lookup(flag) == nil {
+			t.Errorf("expected flag %s is registered", flag)
+		}
+	}
+}
+
+func TestNewHubRestore(t *testing.T) {
+	cmd := NewHubRestore()
+	if cmd.Use != "restore" {
+		t.Errorf("expected restore, but got %s", cmd.Use)
+	}
+	for _, flag := range []string{"kubeconfig", "file"} {
+		if cmd.Flags().Lookup(flag) == nil {
+			t.Errorf("expected flag %s is registered", flag)
+		}
+	}
+}
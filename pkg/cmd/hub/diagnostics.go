@@ -0,0 +1,22 @@
+package hub
+
+import (
+	"github.com/spf13/cobra"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"open-cluster-management.io/ocm/pkg/registration/hub/diagnostics"
+)
+
+func NewHubDiagnostics() *cobra.Command {
+	opts := diagnostics.NewOptions()
+	cmd := &cobra.Command{
+		Use:   "diagnostics",
+		Short: "Collect the diagnostics bundle of a managed cluster",
+		RunE: func(c *cobra.Command, args []string) error {
+			return opts.Run(ctrl.SetupSignalHandler())
+		},
+	}
+
+	opts.AddFlags(cmd.Flags())
+	return cmd
+}
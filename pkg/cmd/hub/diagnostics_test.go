@@ -0,0 +1,17 @@
+package hub
+
+import (
+	"testing"
+)
+
+func TestNewHubDiagnostics(t *testing.T) {
+	cmd := NewHubDiagnostics()
+	if cmd.Use != "diagnostics" {
+		t.Errorf("expected diagnostics, but got %s", cmd.Use)
+	}
+	for _, flag := range []string{"kubeconfig", "cluster-name", "file", "spoke-timeout"} {
+		if cmd.Flags().Lookup(flag) == nil {
+			t.Errorf("expected flag %s is registered", flag)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package operator
+
+import (
+	"github.com/spf13/cobra"
+
+	"open-cluster-management.io/ocm/pkg/operator/render"
+)
+
+// NewRenderCmd generates a command to render the manifests of a ClusterManager or Klusterlet without the operator
+func NewRenderCmd() *cobra.Command {
+	opts := render.NewOptions()
+	cmd := &cobra.Command{
+		Use:   "render",
+		Short: "Render the manifests of a ClusterManager or Klusterlet",
+		Long: "Render the manifests that the operator applies for a ClusterManager or Klusterlet, including the crds, " +
+			"the rbac resources, the deployments and the webhook configurations, into a directory or the stdout. " +
+			"In the Hosted mode, the manifests of the hub or managed cluster and the management cluster are written " +
+			"into the sub directories of the output directory. With --chart, the operator and the ClusterManager or " +
+			"Klusterlet are rendered from the operator chart instead.",
+		RunE: func(c *cobra.Command, args []string) error {
+			return opts.Run(c.Context(), c.OutOrStdout())
+		},
+	}
+
+	opts.AddFlags(cmd.Flags())
+	return cmd
+}
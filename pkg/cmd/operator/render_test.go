@@ -0,0 +1,17 @@
+package operator
+
+import (
+	"testing"
+)
+
+func TestNewRenderCmd(t *testing.T) {
+	cmd := NewRenderCmd()
+	if cmd.Use != "render" {
+		t.Errorf("expected render, but got %s", cmd.Use)
+	}
+	for _, flag := range []string{"file", "output-dir", "ca-bundle-file", "bootstrap-kubeconfig", "bootstrap-kubeconfigs"} {
+		if cmd.Flags().Lookup(flag) == nil {
+			t.Errorf("expected flag %s is registered", flag)
+		}
+	}
+}
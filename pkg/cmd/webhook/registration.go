@@ -10,11 +10,12 @@ import (
 
 func NewRegistrationWebhook() *cobra.Command {
 	webhookOptions := commonoptions.NewWebhookOptions()
+	opts := webhook.NewOptions()
 	cmd := &cobra.Command{
 		Use:   "webhook-server",
 		Short: "Start the registration webhook server",
 		RunE: func(c *cobra.Command, args []string) error {
-			if err := webhook.SetupWebhookServer(webhookOptions); err != nil {
+			if err := opts.SetupWebhookServer(webhookOptions); err != nil {
 				return err
 			}
 			return webhookOptions.RunWebhookServer(ctrl.SetupSignalHandler())
@@ -23,6 +24,7 @@ func NewRegistrationWebhook() *cobra.Command {
 
 	flags := cmd.Flags()
 	webhookOptions.AddFlags(flags)
+	opts.AddFlags(flags)
 
 	return cmd
 }
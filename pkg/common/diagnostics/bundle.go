@@ -0,0 +1,197 @@
+package diagnostics
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// FormatVersion is the version of the bundle format, it is changed only if the layout or the content of an
+	// existing file is changed in an incompatible way.
+	FormatVersion = "v1"
+
+	// ManifestFile is the name of the manifest in a bundle or a spoke archive.
+	ManifestFile = "manifest.json"
+
+	// RequestAnnotationKey is set on a ManagedCluster to request the registration agent to upload the spoke
+	// archive, the value is the id of the request.
+	RequestAnnotationKey = "open-cluster-management.io/diagnostics-request"
+
+	// ConfigMapName is the name of the ConfigMap in the cluster namespace on the hub which the registration agent
+	// uploads the spoke archive into. The ConfigMap is annotated with the RequestAnnotationKey of the request.
+	ConfigMapName = "klusterlet-diagnostics"
+
+	// ArchiveKey is the key of the spoke archive in the binary data of the ConfigMap.
+	ArchiveKey = "spoke.tar.gz"
+)
+
+// Manifest describes the content of a bundle or a spoke archive.
+type Manifest struct {
+	// FormatVersion is the version of the bundle format.
+	FormatVersion string `json:"formatVersion"`
+	// ClusterName is the name of the managed cluster.
+	ClusterName string `json:"clusterName"`
+	// RequestID is the id of the request of the spoke archive.
+	RequestID string `json:"requestID,omitempty"`
+	// CollectedAt is the time the collection started.
+	CollectedAt metav1.Time `json:"collectedAt"`
+	// Files are the files in the bundle, sorted by name.
+	Files []string `json:"files"`
+	// Errors are the files that failed to be collected.
+	Errors []FileError `json:"errors,omitempty"`
+	// Truncated are the files cut to fit the size limit of the archive. A log keeps its tail, and the other files
+	// are absent from the archive.
+	Truncated []string `json:"truncated,omitempty"`
+}
+
+// FileError is the error of a file that failed to be collected.
+type FileError struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+// File is a file in a bundle.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Writer writes a bundle or a spoke archive. The manifest is written on Close.
+type Writer struct {
+	gzipWriter *gzip.Writer
+	tarWriter  *tar.Writer
+	manifest   Manifest
+}
+
+// NewWriter returns a Writer that writes the archive into the out.
+func NewWriter(out io.Writer, clusterName, requestID string, collectedAt time.Time) *Writer {
+	gzipWriter := gzip.NewWriter(out)
+	return &Writer{
+		gzipWriter: gzipWriter,
+		tarWriter:  tar.NewWriter(gzipWriter),
+		manifest: Manifest{
+			FormatVersion: FormatVersion,
+			ClusterName:   clusterName,
+			RequestID:     requestID,
+			CollectedAt:   metav1.NewTime(collectedAt.UTC()),
+			Files:         []string{},
+		},
+	}
+}
+
+// AddFile adds a file into the archive as is.
+func (w *Writer) AddFile(name string, data []byte) error {
+	if err := w.tarWriter.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: w.manifest.CollectedAt.Time,
+	}); err != nil {
+		return err
+	}
+	if _, err := w.tarWriter.Write(data); err != nil {
+		return err
+	}
+	w.manifest.Files = append(w.manifest.Files, name)
+	return nil
+}
+
+// AddObjects adds the redacted objects into the archive as a v1 List.
+func (w *Writer) AddObjects(name string, objects []unstructured.Unstructured) error {
+	data, err := MarshalObjects(objects)
+	if err != nil {
+		return err
+	}
+	return w.AddFile(name, data)
+}
+
+// MarshalObjects returns the redacted objects as a v1 List.
+func MarshalObjects(objects []unstructured.Unstructured) ([]byte, error) {
+	items := make([]interface{}, 0, len(objects))
+	for _, obj := range objects {
+		obj = *obj.DeepCopy()
+		RedactObject(obj.Object)
+		items = append(items, obj.Object)
+	}
+	return yaml.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      items,
+	})
+}
+
+// AddLog adds the redacted log into the archive.
+func (w *Writer) AddLog(name string, log []byte) error {
+	return w.AddFile(name, RedactText(log))
+}
+
+// AddError records the error of a file that failed to be collected in the manifest.
+func (w *Writer) AddError(name string, err error) {
+	w.manifest.Errors = append(w.manifest.Errors, FileError{File: name, Error: err.Error()})
+}
+
+// AddTruncated records a file cut to fit the size limit of the archive in the manifest.
+func (w *Writer) AddTruncated(name string) {
+	w.manifest.Truncated = append(w.manifest.Truncated, name)
+}
+
+// Close writes the manifest and flushes the archive.
+func (w *Writer) Close() error {
+	sort.Strings(w.manifest.Files)
+	sort.Strings(w.manifest.Truncated)
+	data, err := json.MarshalIndent(w.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := w.tarWriter.WriteHeader(&tar.Header{
+		Name:    ManifestFile,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: w.manifest.CollectedAt.Time,
+	}); err != nil {
+		return err
+	}
+	if _, err := w.tarWriter.Write(data); err != nil {
+		return err
+	}
+	if err := w.tarWriter.Close(); err != nil {
+		return err
+	}
+	return w.gzipWriter.Close()
+}
+
+// ReadArchive reads the files of a bundle or a spoke archive, including the manifest.
+func ReadArchive(in io.Reader) ([]File, error) {
+	gzipReader, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, err
+	}
+	defer gzipReader.Close()
+
+	var files []File
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(tarReader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", header.Name, err)
+		}
+		files = append(files, File{Name: header.Name, Data: data})
+	}
+}
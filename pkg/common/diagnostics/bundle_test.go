@@ -0,0 +1,87 @@
+package diagnostics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func TestWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewWriter(buf, "cluster1", "req1", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	secret := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name":          "test",
+			"managedFields": []interface{}{map[string]interface{}{"manager": "test"}},
+		},
+		"data": map[string]interface{}{"token": "dGVzdA=="},
+	}}
+	if err := w.AddObjects("hub/secrets.yaml", []unstructured.Unstructured{secret}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.AddLog("spoke/logs/agent/registration.log", []byte("Authorization: Bearer abc.def\n")); err != nil {
+		t.Fatal(err)
+	}
+	w.AddError("hub/leases.yaml", errors.New("forbidden"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// the object is not changed by the redaction
+	if _, ok := secret.Object["metadata"].(map[string]interface{})["managedFields"]; !ok {
+		t.Errorf("expected the object is not changed")
+	}
+
+	files, err := ReadArchive(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := map[string]string{}
+	for _, file := range files {
+		contents[file.Name] = string(file.Data)
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal([]byte(contents[ManifestFile]), manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.FormatVersion != FormatVersion || manifest.ClusterName != "cluster1" || manifest.RequestID != "req1" {
+		t.Errorf("unexpected manifest %v", manifest)
+	}
+	if !reflect.DeepEqual(manifest.Files, []string{"hub/secrets.yaml", "spoke/logs/agent/registration.log"}) {
+		t.Errorf("unexpected files %v", manifest.Files)
+	}
+	if !reflect.DeepEqual(manifest.Errors, []FileError{{File: "hub/leases.yaml", Error: "forbidden"}}) {
+		t.Errorf("unexpected errors %v", manifest.Errors)
+	}
+
+	list := &unstructured.UnstructuredList{}
+	data, err := yaml.YAMLToJSON([]byte(contents["hub/secrets.yaml"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := list.UnmarshalJSON(data); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 {
+		t.Fatalf("expected 1 item, but got %d", len(list.Items))
+	}
+	if value, _, _ := unstructured.NestedString(list.Items[0].Object, "data", "token"); value != Redacted {
+		t.Errorf("expected the secret is redacted, but got %s", value)
+	}
+	if len(list.Items[0].GetManagedFields()) != 0 {
+		t.Errorf("expected the managed fields are removed")
+	}
+	if strings.Contains(contents["spoke/logs/agent/registration.log"], "abc.def") {
+		t.Errorf("expected the log is redacted, but got %s", contents["spoke/logs/agent/registration.log"])
+	}
+}
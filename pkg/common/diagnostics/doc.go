@@ -0,0 +1,29 @@
+// package diagnostics defines the format of the diagnostics bundle of a managed cluster, which is shared by the hub
+// diagnostics command and the registration agent.
+//
+// A bundle is a gzipped tar archive with a stable layout, so that the support tooling can parse it:
+//
+//	manifest.json                          the Manifest of the bundle
+//	hub/managedcluster.yaml                the ManagedCluster
+//	hub/managedclusteraddons.yaml          the ManagedClusterAddOns in the cluster namespace
+//	hub/manifestworks.yaml                 the ManifestWorks in the cluster namespace
+//	hub/leases.yaml                        the leases in the cluster namespace
+//	hub/certificatesigningrequests.yaml    the CSRs of the cluster
+//	hub/events.yaml                        the events in the cluster namespace
+//	spoke/manifest.json                    the Manifest of the spoke archive uploaded by the registration agent
+//	spoke/klusterlets.yaml                 the Klusterlets, including their conditions
+//	spoke/featuregates.yaml                the feature gates of the registration agent
+//	spoke/events.yaml                      the events in the agent namespace
+//	spoke/logs/<pod>/<container>.log       the tail of the logs of the agents
+//
+// The objects are written as v1 Lists. The Secrets, including the ones embedded in other objects such as the
+// manifests of a ManifestWork, the managed fields and the last applied configurations are redacted, and so are the
+// tokens, passwords and private keys found in the logs. A file that fails to be collected is absent from the bundle
+// and its error is recorded in the manifest instead. The spoke archive must fit in a ConfigMap, so the logs are cut
+// to their tails, and then the other spoke files are dropped if it is still too large; they are recorded as
+// truncated in the manifest.
+//
+// The spoke archive is collected on demand: the hub sets the RequestAnnotationKey annotation on the ManagedCluster,
+// and the registration agent uploads the archive into the ConfigMap named ConfigMapName in the cluster namespace on
+// the hub, annotated with the same request. The archive can be downloaded from the ConfigMap directly.
+package diagnostics
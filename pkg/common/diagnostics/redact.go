@@ -0,0 +1,59 @@
+package diagnostics
+
+import (
+	"regexp"
+)
+
+// Redacted replaces the sensitive values in a bundle.
+const Redacted = "REDACTED"
+
+const lastAppliedConfigAnnotationKey = "kubectl.kubernetes.io/last-applied-configuration"
+
+var (
+	privateKeyPattern = regexp.MustCompile(
+		`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)
+	bearerTokenPattern = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*`)
+	credentialPattern  = regexp.MustCompile(`(?i)((?:token|password|passwd|secret)["']?\s*[:=]\s*["']?)[^\s"',}]+`)
+)
+
+// RedactObject removes the managed fields and the last applied configuration of an object, and redacts the data of
+// the Secrets in it, including the Secrets embedded in the object, e.g. the manifests of a ManifestWork.
+func RedactObject(obj map[string]interface{}) {
+	if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
+		delete(metadata, "managedFields")
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			delete(annotations, lastAppliedConfigAnnotationKey)
+		}
+	}
+	redactSecrets(obj)
+}
+
+func redactSecrets(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if v["kind"] == "Secret" && v["apiVersion"] == "v1" {
+			for _, field := range []string{"data", "stringData"} {
+				if data, ok := v[field].(map[string]interface{}); ok {
+					for key := range data {
+						data[key] = Redacted
+					}
+				}
+			}
+		}
+		for _, item := range v {
+			redactSecrets(item)
+		}
+	case []interface{}:
+		for _, item := range v {
+			redactSecrets(item)
+		}
+	}
+}
+
+// RedactText redacts the private keys, the bearer tokens and the values of the tokens, passwords and secrets in a
+// text, e.g. the logs of an agent.
+func RedactText(text []byte) []byte {
+	text = privateKeyPattern.ReplaceAll(text, []byte(Redacted))
+	text = bearerTokenPattern.ReplaceAll(text, []byte("${1}"+Redacted))
+	return credentialPattern.ReplaceAll(text, []byte("${1}"+Redacted))
+}
@@ -0,0 +1,184 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/yaml"
+
+	clusterv1alpha1client "open-cluster-management.io/api/client/cluster/clientset/versioned/typed/cluster/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	clusterv1alpha1 "open-cluster-management.io/api/cluster/v1alpha1"
+)
+
+const (
+	// AgentVersionClaimSuffix is the suffix of the cluster claims reporting the versions of the agents running on
+	// the managed cluster, e.g. work.agentversion.open-cluster-management.io. The addon agents report their versions
+	// with the addon name as the prefix. The claims are always exposed on the ManagedCluster.
+	AgentVersionClaimSuffix = ".agentversion.open-cluster-management.io"
+
+	// RegistrationAgentComponent and WorkAgentComponent are the names of the klusterlet agents in the agent
+	// version claims and the skew policy.
+	RegistrationAgentComponent = "registration"
+	WorkAgentComponent         = "work"
+
+	// AgentVersionSkewPolicyConfigMap is the ConfigMap in the hub namespace holding the skew policy in the
+	// AgentVersionSkewPolicyKey key.
+	AgentVersionSkewPolicyConfigMap = "agent-version-skew-policy"
+	AgentVersionSkewPolicyKey       = "policy"
+
+	// AgentVersionInventoryConfigMap is the ConfigMap in the hub namespace aggregating the agent versions of the
+	// fleet. The AgentVersionsKey key is a json map from the agent component to the number of clusters running each
+	// version, and the UnsupportedFeaturesKey key is a json map from the features of the skew policy to the
+	// UnsupportedFeature. The size of the inventory does not grow with the number of clusters.
+	AgentVersionInventoryConfigMap = "agent-version-inventory"
+	AgentVersionsKey               = "agentVersions"
+	UnsupportedFeaturesKey         = "unsupportedFeatures"
+
+	// MaxInventoryClusters is the max number of cluster names recorded for each unsupported feature in the inventory.
+	MaxInventoryClusters = 10
+)
+
+// UnsupportedFeature records the clusters that cannot support a feature in the inventory.
+type UnsupportedFeature struct {
+	// Count is the number of the clusters that cannot support the feature.
+	Count int `json:"count"`
+	// Clusters are the first MaxInventoryClusters names of the clusters in alphabetical order.
+	Clusters []string `json:"clusters,omitempty"`
+}
+
+// SkewAction is the action taken on the clusters whose agents are too old for the enabled features.
+type SkewAction string
+
+const (
+	// SkewActionWarn reports the skew in the condition of the cluster only.
+	SkewActionWarn SkewAction = "Warn"
+	// SkewActionTaint taints the cluster in addition, so it is not selected by new placements.
+	SkewActionTaint SkewAction = "Taint"
+)
+
+// AgentVersionSkewPolicy defines the min agent versions required by the hub features.
+type AgentVersionSkewPolicy struct {
+	// Action is Warn or Taint, the default is Warn.
+	Action SkewAction `json:"action,omitempty"`
+	// Requirements are the min agent versions of the features.
+	Requirements []FeatureRequirement `json:"requirements,omitempty"`
+}
+
+// FeatureRequirement is the min agent versions required by a feature.
+type FeatureRequirement struct {
+	// Feature is the name of the feature gate.
+	Feature string `json:"feature"`
+	// MinAgentVersions maps the agent component, e.g. registration, work or the addon name, to the min version.
+	MinAgentVersions map[string]string `json:"minAgentVersions"`
+}
+
+// AgentVersionClaimName returns the name of the cluster claim reporting the version of the agent component.
+func AgentVersionClaimName(component string) string {
+	return component + AgentVersionClaimSuffix
+}
+
+// GetAgentVersions returns the agent versions reported in the cluster claims of the cluster.
+func GetAgentVersions(cluster *clusterv1.ManagedCluster) map[string]string {
+	versions := map[string]string{}
+	for _, claim := range cluster.Status.ClusterClaims {
+		if component, ok := strings.CutSuffix(claim.Name, AgentVersionClaimSuffix); ok && len(component) > 0 {
+			versions[component] = claim.Value
+		}
+	}
+	return versions
+}
+
+// EnsureAgentVersionClaim creates or updates the cluster claim reporting the version of the agent component on the
+// managed cluster.
+func EnsureAgentVersionClaim(ctx context.Context, claimClient clusterv1alpha1client.ClusterClaimInterface,
+	component, agentVersion string) error {
+	name := AgentVersionClaimName(component)
+	claim, err := claimClient.Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		_, err = claimClient.Create(ctx, &clusterv1alpha1.ClusterClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       clusterv1alpha1.ClusterClaimSpec{Value: agentVersion},
+		}, metav1.CreateOptions{})
+		return err
+	case err != nil:
+		return err
+	case claim.Spec.Value == agentVersion:
+		return nil
+	}
+
+	claim = claim.DeepCopy()
+	claim.Spec.Value = agentVersion
+	_, err = claimClient.Update(ctx, claim, metav1.UpdateOptions{})
+	return err
+}
+
+// ParseAgentVersionSkewPolicy parses and validates the yaml or json encoded skew policy.
+func ParseAgentVersionSkewPolicy(data []byte) (*AgentVersionSkewPolicy, error) {
+	policy := &AgentVersionSkewPolicy{}
+	if err := yaml.UnmarshalStrict(data, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse the agent version skew policy: %w", err)
+	}
+
+	switch policy.Action {
+	case "":
+		policy.Action = SkewActionWarn
+	case SkewActionWarn, SkewActionTaint:
+	default:
+		return nil, fmt.Errorf("unsupported action %q of the agent version skew policy", policy.Action)
+	}
+
+	features := sets.New[string]()
+	for _, requirement := range policy.Requirements {
+		if len(requirement.Feature) == 0 {
+			return nil, fmt.Errorf("the feature of the agent version skew policy is empty")
+		}
+		if features.Has(requirement.Feature) {
+			return nil, fmt.Errorf("the feature %q is duplicated in the agent version skew policy", requirement.Feature)
+		}
+		features.Insert(requirement.Feature)
+		if len(requirement.MinAgentVersions) == 0 {
+			return nil, fmt.Errorf("the min agent versions of the feature %q are empty", requirement.Feature)
+		}
+		for component, minVersion := range requirement.MinAgentVersions {
+			if _, err := version.ParseGeneric(minVersion); err != nil {
+				return nil, fmt.Errorf("invalid min version of the %s agent for the feature %q: %w",
+					component, requirement.Feature, err)
+			}
+		}
+	}
+	return policy, nil
+}
+
+// UnsupportedFeatures returns the features of the policy that the agents with the given versions cannot support,
+// and the reasons. An agent that does not report its version is skipped, since its version is unknown rather than
+// too old; an agent reporting an invalid version is regarded as too old.
+func (p *AgentVersionSkewPolicy) UnsupportedFeatures(agentVersions map[string]string) map[string]string {
+	unsupported := map[string]string{}
+	for _, requirement := range p.Requirements {
+		components := sets.List(sets.KeySet(requirement.MinAgentVersions))
+		var reasons []string
+		for _, component := range components {
+			minVersion := requirement.MinAgentVersions[component]
+			agentVersion, ok := agentVersions[component]
+			if !ok {
+				continue
+			}
+			current, err := version.ParseGeneric(agentVersion)
+			if err != nil || current.LessThan(version.MustParseGeneric(minVersion)) {
+				reasons = append(reasons, fmt.Sprintf("the %s agent %s or later is required, but got %q",
+					component, minVersion, agentVersion))
+			}
+		}
+		if len(reasons) > 0 {
+			unsupported[requirement.Feature] = strings.Join(reasons, "; ")
+		}
+	}
+	return unsupported
+}
@@ -0,0 +1,126 @@
+package helpers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestParseAgentVersionSkewPolicy(t *testing.T) {
+	tests := []struct {
+		name           string
+		policy         string
+		expectedAction SkewAction
+		expectedErr    string
+	}{
+		{
+			name: "default action",
+			policy: `
+requirements:
+- feature: ManifestWorkReplicaSet
+  minAgentVersions:
+    work: v0.16.0
+`,
+			expectedAction: SkewActionWarn,
+		},
+		{
+			name: "taint",
+			policy: `
+action: Taint
+requirements:
+- feature: ManifestWorkReplicaSet
+  minAgentVersions:
+    work: v0.16.0
+`,
+			expectedAction: SkewActionTaint,
+		},
+		{
+			name:        "unknown action",
+			policy:      `action: Evict`,
+			expectedErr: "unsupported action",
+		},
+		{
+			name: "duplicated feature",
+			policy: `
+requirements:
+- feature: ManifestWorkReplicaSet
+  minAgentVersions:
+    work: v0.16.0
+- feature: ManifestWorkReplicaSet
+  minAgentVersions:
+    work: v0.17.0
+`,
+			expectedErr: "duplicated",
+		},
+		{
+			name: "invalid version",
+			policy: `
+requirements:
+- feature: ManifestWorkReplicaSet
+  minAgentVersions:
+    work: latest
+`,
+			expectedErr: "invalid min version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := ParseAgentVersionSkewPolicy([]byte(tt.policy))
+			if len(tt.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("expected error %q, but got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if policy.Action != tt.expectedAction {
+				t.Errorf("expected action %s, but got %s", tt.expectedAction, policy.Action)
+			}
+		})
+	}
+}
+
+func TestUnsupportedFeatures(t *testing.T) {
+	policy := &AgentVersionSkewPolicy{
+		Requirements: []FeatureRequirement{
+			{Feature: "A", MinAgentVersions: map[string]string{WorkAgentComponent: "v0.16.0"}},
+			{Feature: "B", MinAgentVersions: map[string]string{RegistrationAgentComponent: "v0.15.0", "foo": "v1.2.0"}},
+		},
+	}
+	tests := []struct {
+		name          string
+		agentVersions map[string]string
+		want          []string
+	}{
+		{
+			name:          "supported",
+			agentVersions: map[string]string{WorkAgentComponent: "v0.16.1", RegistrationAgentComponent: "v0.16.1-3-gabcdef"},
+		},
+		{
+			name:          "too old",
+			agentVersions: map[string]string{WorkAgentComponent: "v0.15.2", RegistrationAgentComponent: "v0.16.1", "foo": "v1.1.0"},
+			want:          []string{"A", "B"},
+		},
+		{
+			name:          "unknown klusterlet agent version",
+			agentVersions: map[string]string{WorkAgentComponent: "v0.16.0"},
+		},
+		{
+			name:          "invalid version",
+			agentVersions: map[string]string{WorkAgentComponent: "dev", RegistrationAgentComponent: "v0.16.0"},
+			want:          []string{"A"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			features := sets.List(sets.KeySet(policy.UnsupportedFeatures(tt.agentVersions)))
+			if len(features) != len(tt.want) || (len(features) > 0 && !reflect.DeepEqual(features, tt.want)) {
+				t.Errorf("expected unsupported features %v, but got %v", tt.want, features)
+			}
+		})
+	}
+}
@@ -8,6 +8,17 @@ import (
 	operatorv1 "open-cluster-management.io/api/operator/v1"
 )
 
+// RestoredFromHubAnnotationKey is set on the ManagedClusters and ManifestWorks restored from a hub backup, and its
+// value is the hub hash of the backed up hub. The CSRs of the restored clusters are approved until the agents rejoin,
+// when the annotation is removed from the clusters, and the work agents adopt the AppliedManifestWorks of the
+// restored ManifestWorks keyed by the hub hash.
+const RestoredFromHubAnnotationKey = "open-cluster-management.io/restored-from-hub"
+
+// AgentIDAnnotationKey is set on the accepted ManagedClusters by the hub, and its value is the agent ID in the approved
+// client certificate of the registration agent. It is kept in the hub backup, so the CSRs of a restored cluster are
+// only approved if they are sent by the same agent.
+const AgentIDAnnotationKey = "open-cluster-management.io/agent-id"
+
 func FilterClusterAnnotations(annotations map[string]string) map[string]string {
 	clusterAnnotations := make(map[string]string)
 	if annotations == nil {
@@ -3,7 +3,11 @@
 package features
 
 import (
+	"maps"
+
 	"k8s.io/component-base/featuregate"
+
+	ocmfeature "open-cluster-management.io/api/feature"
 )
 
 var (
@@ -13,3 +17,34 @@ var (
 	// SpokeMutableFeatureGate of multiple mutable feature-gates for agent
 	SpokeMutableFeatureGate = featuregate.NewFeatureGate()
 )
+
+const (
+	// RestoredClusterCSRApproval approves the CSRs of the clusters restored from a hub backup, if the CSRs are sent by
+	// the agents recorded on the restored clusters.
+	RestoredClusterCSRApproval featuregate.Feature = "RestoredClusterCSRApproval"
+
+	// ClientCertificateRevocation revokes the credentials of the managed clusters which are denied, detached or forced
+	// to rotate their credentials, and rejects the requests with the revoked credentials on the hub.
+	ClientCertificateRevocation featuregate.Feature = "ClientCertificateRevocation"
+
+	// KlusterletUpgrade upgrades the klusterlets of the managed clusters in batches with the fleet upgrade ConfigMaps
+	// in the hub namespace.
+	KlusterletUpgrade featuregate.Feature = "KlusterletUpgrade"
+
+	// AgentVersionSkew checks the agent versions of the managed clusters against the agent version skew policy in the
+	// hub namespace, and records the agent version inventory of the fleet.
+	AgentVersionSkew featuregate.Feature = "AgentVersionSkew"
+)
+
+// DefaultHubRegistrationFeatureGates are the feature gates of the hub registration, including the ones defined in the
+// api and the ones only defined in this repo, which are disabled by default.
+var DefaultHubRegistrationFeatureGates = func() map[featuregate.Feature]featuregate.FeatureSpec {
+	featureGates := map[featuregate.Feature]featuregate.FeatureSpec{
+		RestoredClusterCSRApproval:  {Default: false, PreRelease: featuregate.Alpha},
+		ClientCertificateRevocation: {Default: false, PreRelease: featuregate.Alpha},
+		KlusterletUpgrade:           {Default: false, PreRelease: featuregate.Alpha},
+		AgentVersionSkew:            {Default: false, PreRelease: featuregate.Alpha},
+	}
+	maps.Copy(featureGates, ocmfeature.DefaultHubRegistrationFeatureGates)
+	return featureGates
+}()
@@ -53,6 +53,11 @@ const (
 	FeatureGatesReasonAllValid        = "FeatureGatesAllValid"
 	FeatureGatesReasonInvalidExisting = "InvalidFeatureGatesExisting"
 
+	// FeatureGatesTypeAdmitted reports whether the enabled feature gates are supported by the agents of the fleet.
+	FeatureGatesTypeAdmitted      = "FeatureGatesAdmitted"
+	FeatureGatesReasonAllAdmitted = "FeatureGatesAllAdmitted"
+	FeatureGatesReasonRefused     = "FeatureGatesRefused"
+
 	// DefaultAddonNamespace is the default namespace for agent addon
 	DefaultAddonNamespace = "open-cluster-management-agent-addon"
 
@@ -62,6 +67,9 @@ const (
 	// HubLabelKey is used to filter resources in informers
 	HubLabelKey = LabelPrefix + "/created-by-clustermanager"
 
+	// RollbackLabelKey is used to filter the rollback ConfigMaps of the cluster managers in informers
+	RollbackLabelKey = LabelPrefix + "/clustermanager-rollback"
+
 	// AgentLabelKey is used to filter resources in informers
 	AgentLabelKey = LabelPrefix + "/created-by-klusterlet"
 
@@ -915,6 +923,48 @@ func AddLabelsToYaml(objData []byte, cmLabels map[string]string) ([]byte, error)
 	return modifiedYAML, nil
 }
 
+// AddNodePlacementToYaml sets the node placement into the pod template of the deployment manifest, as what
+// ApplyDeployment does before applying the deployment.
+func AddNodePlacementToYaml(objData []byte, nodePlacement operatorapiv1.NodePlacement) ([]byte, error) {
+	jsonData, err := yaml.YAMLToJSON(objData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert YAML to JSON: %w", err)
+	}
+	u := &unstructured.Unstructured{}
+	if err := json.Unmarshal(jsonData, u); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+
+	placement, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&corev1.PodSpec{
+		NodeSelector: nodePlacement.NodeSelector,
+		Tolerations:  nodePlacement.Tolerations,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range []string{"nodeSelector", "tolerations"} {
+		value, ok := placement[field]
+		if !ok {
+			unstructured.RemoveNestedField(u.Object, "spec", "template", "spec", field)
+			continue
+		}
+		if err := unstructured.SetNestedField(u.Object, value, "spec", "template", "spec", field); err != nil {
+			return nil, err
+		}
+	}
+
+	modifiedJSON, err := json.Marshal(u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal updated object: %w", err)
+	}
+	modifiedYAML, err := yaml.JSONToYAML(modifiedJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert JSON to YAML: %w", err)
+	}
+
+	return modifiedYAML, nil
+}
+
 func GRPCAuthEnabled(cm *operatorapiv1.ClusterManager) bool {
 	if cm.Spec.RegistrationConfiguration == nil {
 		return false
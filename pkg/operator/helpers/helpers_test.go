@@ -1778,6 +1778,67 @@ data:
 	}
 }
 
+func TestAddNodePlacementToYaml(t *testing.T) {
+	deployment := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: test
+spec:
+  template:
+    spec:
+      nodeSelector:
+        foo: bar
+      containers:
+      - name: test
+`
+	tests := []struct {
+		name              string
+		nodePlacement     operatorapiv1.NodePlacement
+		wantNodeSelector  map[string]string
+		wantTolerationKey string
+	}{
+		{
+			name: "empty node placement",
+		},
+		{
+			name: "node selector and tolerations",
+			nodePlacement: operatorapiv1.NodePlacement{
+				NodeSelector: map[string]string{"node-role.kubernetes.io/infra": ""},
+				Tolerations:  []corev1.Toleration{{Key: "node-role.kubernetes.io/infra", Operator: corev1.TolerationOpExists}},
+			},
+			wantNodeSelector:  map[string]string{"node-role.kubernetes.io/infra": ""},
+			wantTolerationKey: "node-role.kubernetes.io/infra",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := AddNodePlacementToYaml([]byte(deployment), tt.nodePlacement)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			required, _, err := genericCodec.Decode(out, nil, nil)
+			if err != nil {
+				t.Fatalf("failed to decode output: %v", err)
+			}
+			podSpec := required.(*appsv1.Deployment).Spec.Template.Spec
+			if !reflect.DeepEqual(podSpec.NodeSelector, tt.wantNodeSelector) {
+				t.Errorf("node selector mismatch: got %v, want %v", podSpec.NodeSelector, tt.wantNodeSelector)
+			}
+			if len(tt.wantTolerationKey) == 0 && len(podSpec.Tolerations) > 0 {
+				t.Errorf("expected no tolerations, but got %v", podSpec.Tolerations)
+			}
+			if len(tt.wantTolerationKey) > 0 && (len(podSpec.Tolerations) != 1 || podSpec.Tolerations[0].Key != tt.wantTolerationKey) {
+				t.Errorf("tolerations mismatch: got %v", podSpec.Tolerations)
+			}
+			if len(podSpec.Containers) != 1 {
+				t.Errorf("expected the containers are kept, but got %v", podSpec.Containers)
+			}
+		})
+	}
+}
+
 func TestGRPCAuthEnabled(t *testing.T) {
 	cases := []struct {
 		name          string
@@ -0,0 +1,212 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/ghodss/yaml"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+
+	operatorapiv1 "open-cluster-management.io/api/operator/v1"
+)
+
+const (
+	// ComponentPatchesAnnotationKey is the annotation of the ClusterManager and the Klusterlet to patch the rendered
+	// deployments of the components. The value is a json map from the component name to the patches applied in order,
+	// e.g. {"registration": [{"type": "StrategicMerge", "patch": {"spec": {...}}}]}. The node selector and the
+	// tolerations of the deployments are set by the node placement, and cannot be patched. The hash of the patches
+	// applied to a deployment is recorded in the generations of the status.
+	ComponentPatchesAnnotationKey = "operator.open-cluster-management.io/component-patches"
+
+	// ReasonInvalidComponentPatches is the reason of the Applied condition if the component patches are invalid.
+	ReasonInvalidComponentPatches = "InvalidComponentPatches"
+
+	// componentPatchesResource is the resource of the generation status recording the hash of the patches applied to
+	// the deployment of a component. It is recorded separately from the generation status of the deployment.
+	componentPatchesResource = "componentpatches"
+)
+
+// ComponentPatchType is the type of a component patch.
+type ComponentPatchType string
+
+const (
+	// ComponentPatchTypeStrategicMerge is a strategic merge patch of the deployment.
+	ComponentPatchTypeStrategicMerge ComponentPatchType = "StrategicMerge"
+	// ComponentPatchTypeJSON is a RFC 6902 JSON patch of the deployment.
+	ComponentPatchTypeJSON ComponentPatchType = "JSON"
+)
+
+// ComponentPatch is a patch applied to the rendered deployment of a component.
+type ComponentPatch struct {
+	Type  ComponentPatchType `json:"type"`
+	Patch json.RawMessage    `json:"patch"`
+}
+
+// ComponentPatches are the patches of the components keyed by the component name.
+type ComponentPatches map[string][]ComponentPatch
+
+// ParseComponentPatches parses the component patches from the annotations and validates them against the known
+// components. It returns empty patches if the annotation is not set.
+func ParseComponentPatches(annotations map[string]string, components []string) (ComponentPatches, error) {
+	value, ok := annotations[ComponentPatchesAnnotationKey]
+	if !ok {
+		return ComponentPatches{}, nil
+	}
+
+	patches := ComponentPatches{}
+	if err := json.Unmarshal([]byte(value), &patches); err != nil {
+		return nil, fmt.Errorf("invalid annotation %s: %w", ComponentPatchesAnnotationKey, err)
+	}
+
+	names := make([]string, 0, len(patches))
+	for name := range patches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !slices.Contains(components, name) {
+			return nil, fmt.Errorf("invalid annotation %s: unknown component %q, the components are %s",
+				ComponentPatchesAnnotationKey, name, strings.Join(components, ", "))
+		}
+		for i, patch := range patches[name] {
+			if err := patch.validate(); err != nil {
+				return nil, fmt.Errorf("invalid annotation %s: patch %d of component %q: %w",
+					ComponentPatchesAnnotationKey, i, name, err)
+			}
+		}
+	}
+	return patches, nil
+}
+
+func (p ComponentPatch) validate() error {
+	if len(p.Patch) == 0 {
+		return fmt.Errorf("patch is empty")
+	}
+	switch p.Type {
+	case ComponentPatchTypeStrategicMerge:
+		patch := map[string]interface{}{}
+		if err := json.Unmarshal(p.Patch, &patch); err != nil {
+			return fmt.Errorf("strategic merge patch is not a json object: %w", err)
+		}
+	case ComponentPatchTypeJSON:
+		if _, err := jsonpatch.DecodePatch(p.Patch); err != nil {
+			return fmt.Errorf("invalid json patch: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported patch type %q, the type is %s or %s",
+			p.Type, ComponentPatchTypeStrategicMerge, ComponentPatchTypeJSON)
+	}
+	return nil
+}
+
+// Apply applies the patches of the component to the rendered deployment in order. The patched deployment is
+// validated so that a patch cannot produce an invalid deployment, change its kind, name or namespace, or change the
+// node selector and the tolerations which are overwritten by the node placement when the deployment is applied.
+func (p ComponentPatches) Apply(component string, deploymentData []byte) ([]byte, error) {
+	if len(p[component]) == 0 {
+		return deploymentData, nil
+	}
+
+	original, err := deploymentFromYaml(deploymentData)
+	if err != nil {
+		return nil, err
+	}
+	data, err := yaml.YAMLToJSON(deploymentData)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, patch := range p[component] {
+		switch patch.Type {
+		case ComponentPatchTypeStrategicMerge:
+			data, err = strategicpatch.StrategicMergePatch(data, patch.Patch, appsv1.Deployment{})
+		case ComponentPatchTypeJSON:
+			var decoded jsonpatch.Patch
+			decoded, err = jsonpatch.DecodePatch(patch.Patch)
+			if err == nil {
+				data, err = decoded.Apply(data)
+			}
+		default:
+			err = fmt.Errorf("unsupported patch type %q", patch.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply patch %d of component %q: %w", i, component, err)
+		}
+	}
+
+	patched, err := deploymentFromYaml(data)
+	if err != nil {
+		return nil, fmt.Errorf("patches of component %q produce an invalid deployment: %w", component, err)
+	}
+	if patched.Kind != original.Kind || patched.APIVersion != original.APIVersion ||
+		patched.Name != original.Name || patched.Namespace != original.Namespace {
+		return nil, fmt.Errorf("patches of component %q must not change the kind, name or namespace of deployment %s/%s",
+			component, original.Namespace, original.Name)
+	}
+	if !equality.Semantic.DeepEqual(patched.Spec.Template.Spec.NodeSelector, original.Spec.Template.Spec.NodeSelector) ||
+		!equality.Semantic.DeepEqual(patched.Spec.Template.Spec.Tolerations, original.Spec.Template.Spec.Tolerations) {
+		return nil, fmt.Errorf("patches of component %q must not change the node selector or tolerations of "+
+			"deployment %s/%s, set them in the node placement instead", component, original.Namespace, original.Name)
+	}
+	if len(patched.Spec.Template.Spec.Containers) == 0 {
+		return nil, fmt.Errorf("patches of component %q remove all containers of deployment %s/%s",
+			component, original.Namespace, original.Name)
+	}
+
+	return yaml.JSONToYAML(data)
+}
+
+// Hash returns the hash of the patches of the component, or 0 if the component has no patch.
+func (p ComponentPatches) Hash(component string) int64 {
+	if len(p[component]) == 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	for _, patch := range p[component] {
+		h.Write([]byte(patch.Type))
+		h.Write(patch.Patch)
+	}
+	// keep the hash positive as a generation.
+	return int64(h.Sum64() >> 1)
+}
+
+// SetComponentPatchesGenerationStatus records the hash of the patches applied to the deployment of the component in
+// the generation statuses, the record is removed if the component has no patch.
+func SetComponentPatchesGenerationStatus(generationStatuses *[]operatorapiv1.GenerationStatus,
+	patches ComponentPatches, component, namespace, name string) {
+	generationStatus := operatorapiv1.GenerationStatus{
+		Group:          operatorapiv1.GroupName,
+		Version:        operatorapiv1.GroupVersion.Version,
+		Resource:       componentPatchesResource,
+		Namespace:      namespace,
+		Name:           name,
+		LastGeneration: patches.Hash(component),
+	}
+	if generationStatus.LastGeneration != 0 {
+		SetGenerationStatuses(generationStatuses, generationStatus)
+		return
+	}
+	*generationStatuses = slices.DeleteFunc(*generationStatuses, func(status operatorapiv1.GenerationStatus) bool {
+		return status.Group == generationStatus.Group && status.Resource == generationStatus.Resource &&
+			status.Namespace == namespace && status.Name == name
+	})
+}
+
+func deploymentFromYaml(data []byte) (*appsv1.Deployment, error) {
+	obj, _, err := genericCodec.Decode(data, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	deployment, ok := obj.(*appsv1.Deployment)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a deployment", obj)
+	}
+	return deployment, nil
+}
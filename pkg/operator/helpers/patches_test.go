@@ -0,0 +1,208 @@
+package helpers
+
+import (
+	"strings"
+	"testing"
+
+	operatorapiv1 "open-cluster-management.io/api/operator/v1"
+)
+
+const patchTestDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: test-controller
+  namespace: test
+spec:
+  selector:
+    matchLabels:
+      app: test-controller
+  template:
+    metadata:
+      labels:
+        app: test-controller
+    spec:
+      containers:
+      - name: controller
+        image: test
+        args:
+        - controller
+`
+
+var patchTestComponents = []string{"registration", "work"}
+
+func TestParseComponentPatches(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		expectErr   string
+		expected    int
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name: "valid patches",
+			annotations: map[string]string{ComponentPatchesAnnotationKey: `{"registration":[` +
+				`{"type":"StrategicMerge","patch":{"metadata":{"annotations":{"a":"b"}}}},` +
+				`{"type":"JSON","patch":[{"op":"remove","path":"/spec/replicas"}]}]}`},
+			expected: 2,
+		},
+		{
+			name:        "invalid json",
+			annotations: map[string]string{ComponentPatchesAnnotationKey: `{"registration":`},
+			expectErr:   "invalid annotation",
+		},
+		{
+			name:        "unknown component",
+			annotations: map[string]string{ComponentPatchesAnnotationKey: `{"placement":[]}`},
+			expectErr:   `unknown component "placement"`,
+		},
+		{
+			name:        "unsupported patch type",
+			annotations: map[string]string{ComponentPatchesAnnotationKey: `{"work":[{"type":"Merge","patch":{}}]}`},
+			expectErr:   `unsupported patch type "Merge"`,
+		},
+		{
+			name:        "empty patch",
+			annotations: map[string]string{ComponentPatchesAnnotationKey: `{"work":[{"type":"JSON"}]}`},
+			expectErr:   "patch is empty",
+		},
+		{
+			name:        "strategic merge patch is not an object",
+			annotations: map[string]string{ComponentPatchesAnnotationKey: `{"work":[{"type":"StrategicMerge","patch":[]}]}`},
+			expectErr:   "not a json object",
+		},
+		{
+			name:        "invalid json patch",
+			annotations: map[string]string{ComponentPatchesAnnotationKey: `{"work":[{"type":"JSON","patch":{"op":"add"}}]}`},
+			expectErr:   "invalid json patch",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			patches, err := ParseComponentPatches(c.annotations, patchTestComponents)
+			if len(c.expectErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), c.expectErr) {
+					t.Errorf("expected error %q, but got %v", c.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(patches["registration"]) != c.expected {
+				t.Errorf("expected %d patches, but got %v", c.expected, patches)
+			}
+		})
+	}
+}
+
+func TestApplyComponentPatches(t *testing.T) {
+	cases := []struct {
+		name             string
+		patches          string
+		expectErr        string
+		expectedContents []string
+	}{
+		{
+			name:             "no patch",
+			patches:          `{}`,
+			expectedContents: []string{patchTestDeployment},
+		},
+		{
+			name: "patches applied in order",
+			patches: `{"registration":[` +
+				`{"type":"StrategicMerge","patch":{"spec":{"template":{"spec":{"containers":[` +
+				`{"name":"controller","env":[{"name":"HTTP_PROXY","value":"proxy"}]},{"name":"sidecar","image":"sidecar"}]}}}}},` +
+				`{"type":"JSON","patch":[{"op":"add","path":"/spec/template/spec/containers/0/args/-","value":"--v=4"},` +
+				`{"op":"replace","path":"/spec/template/spec/containers/1/image","value":"sidecar:v1"}]}]}`,
+			expectedContents: []string{
+				"- controller\n        - --v=4",
+				"name: HTTP_PROXY",
+				"image: sidecar:v1",
+				"image: test",
+			},
+		},
+		{
+			name:      "patch fails",
+			patches:   `{"registration":[{"type":"JSON","patch":[{"op":"remove","path":"/spec/replicas"}]}]}`,
+			expectErr: `failed to apply patch 0 of component "registration"`,
+		},
+		{
+			name:      "name changed",
+			patches:   `{"registration":[{"type":"StrategicMerge","patch":{"metadata":{"name":"other"}}}]}`,
+			expectErr: "must not change the kind, name or namespace",
+		},
+		{
+			name: "node selector changed",
+			patches: `{"registration":[{"type":"StrategicMerge","patch":{"spec":{"template":{"spec":{` +
+				`"nodeSelector":{"node-role.kubernetes.io/infra":""}}}}}}]}`,
+			expectErr: "must not change the node selector or tolerations",
+		},
+		{
+			name: "tolerations changed",
+			patches: `{"registration":[{"type":"JSON","patch":[{"op":"add","path":"/spec/template/spec/tolerations",` +
+				`"value":[{"key":"node-role.kubernetes.io/infra","operator":"Exists","effect":"NoSchedule"}]}]}]}`,
+			expectErr: "must not change the node selector or tolerations",
+		},
+		{
+			name:      "all containers removed",
+			patches:   `{"registration":[{"type":"JSON","patch":[{"op":"remove","path":"/spec/template/spec/containers/0"}]}]}`,
+			expectErr: "remove all containers",
+		},
+		{
+			name:      "invalid deployment",
+			patches:   `{"registration":[{"type":"JSON","patch":[{"op":"replace","path":"/spec/template","value":"test"}]}]}`,
+			expectErr: "produce an invalid deployment",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			patches, err := ParseComponentPatches(
+				map[string]string{ComponentPatchesAnnotationKey: c.patches}, patchTestComponents)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := patches.Apply("registration", []byte(patchTestDeployment))
+			if len(c.expectErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), c.expectErr) {
+					t.Errorf("expected error %q, but got %v", c.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, expected := range c.expectedContents {
+				if !strings.Contains(string(data), expected) {
+					t.Errorf("expected %q in the patched deployment:\n%s", expected, data)
+				}
+			}
+		})
+	}
+}
+
+func TestSetComponentPatchesGenerationStatus(t *testing.T) {
+	patches, err := ParseComponentPatches(map[string]string{ComponentPatchesAnnotationKey: `{"registration":[` +
+		`{"type":"StrategicMerge","patch":{"metadata":{"annotations":{"a":"b"}}}}]}`}, patchTestComponents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deploymentGeneration := operatorapiv1.GenerationStatus{
+		Group: "apps", Version: "v1", Resource: "deployments", Namespace: "test", Name: "test-controller", LastGeneration: 1,
+	}
+	generations := []operatorapiv1.GenerationStatus{deploymentGeneration}
+
+	SetComponentPatchesGenerationStatus(&generations, patches, "registration", "test", "test-controller")
+	if len(generations) != 2 || generations[0] != deploymentGeneration || generations[1].Resource != componentPatchesResource ||
+		generations[1].LastGeneration != patches.Hash("registration") || generations[1].LastGeneration <= 0 {
+		t.Errorf("expected the patch hash is recorded, but got %v", generations)
+	}
+
+	SetComponentPatchesGenerationStatus(&generations, ComponentPatches{}, "registration", "test", "test-controller")
+	if len(generations) != 1 || generations[0] != deploymentGeneration {
+		t.Errorf("expected the patch hash is removed, but got %v", generations)
+	}
+}
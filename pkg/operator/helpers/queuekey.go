@@ -48,6 +48,12 @@ const (
 	CaBundleConfigmap = "ca-bundle-configmap"
 
 	GRPCServerSecret = "grpc-server-serving-cert" //#nosec G101
+
+	// SignerCASecret, WebhookCASecret and GRPCServerCASecret are the secrets of the external CAs which sign the
+	// cluster signer, the webhook serving certs and the grpc server serving cert.
+	SignerCASecret     = "hub-signer-ca"      // #nosec G101
+	WebhookCASecret    = "hub-webhook-ca"     // #nosec G101
+	GRPCServerCASecret = "hub-grpc-server-ca" // #nosec G101
 )
 
 func ClusterManagerNamespace(clustermanagername string, mode operatorapiv1.InstallMode) string {
@@ -122,7 +128,8 @@ func ClusterManagerDeploymentQueueKeyFunc(clusterManagerLister operatorlister.Cl
 			strings.HasSuffix(name, "addon-manager-controller") ||
 			strings.HasSuffix(name, "addon-webhook") ||
 			strings.HasSuffix(name, "work-controller") ||
-			strings.HasSuffix(name, "placement-controller") {
+			strings.HasSuffix(name, "placement-controller") ||
+			strings.HasSuffix(name, "grpc-server") {
 			interestedObjectFound = true
 		}
 		if !interestedObjectFound {
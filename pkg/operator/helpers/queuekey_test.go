@@ -191,6 +191,13 @@ func TestClusterManagerQueueKeyFunc(t *testing.T) {
 			queueFunc:      ClusterManagerDeploymentQueueKeyFunc,
 			expectedKey:    []string{"testhub"},
 		},
+		{
+			name:           "key by grpc server",
+			object:         newDeployment("testhub-grpc-server", ClusterManagerDefaultNamespace, 0),
+			clusterManager: newClusterManager("testhub", operatorapiv1.InstallModeDefault),
+			queueFunc:      ClusterManagerDeploymentQueueKeyFunc,
+			expectedKey:    []string{"testhub"},
+		},
 		{
 			name:           "key by wrong deployment",
 			object:         newDeployment("dummy", "test", 0),
@@ -0,0 +1,442 @@
+package certrotationcontroller
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openshift/library-go/pkg/crypto"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/cert"
+
+	operatorv1 "open-cluster-management.io/api/operator/v1"
+	"open-cluster-management.io/sdk-go/pkg/certrotation"
+	sdkhelpers "open-cluster-management.io/sdk-go/pkg/helpers"
+
+	"open-cluster-management.io/ocm/pkg/operator/helpers"
+)
+
+const (
+	// signerCAAnnotationKey, webhookCAAnnotationKey and grpcServerCAAnnotationKey are the annotations on the
+	// cluster manager to choose the CA of the cluster signer, the webhook serving certs and the grpc server serving
+	// cert. The value is one of
+	//   - secret:<name>, a kubernetes.io/tls secret in the cluster manager namespace holding the cert and key of an
+	//     external CA, the certs are signed by the external CA. The secret must be named hub-signer-ca,
+	//     hub-webhook-ca and hub-grpc-server-ca respectively, which are the only secrets the operator is allowed to
+	//     read.
+	//   - issuer:<name>, a cert-manager Issuer in the cluster manager namespace which issues the certs.
+	//   - clusterissuer:<name>, a cert-manager ClusterIssuer which issues the certs.
+	// A self-signed CA is created and rotated by the operator if the annotation is not set.
+	signerCAAnnotationKey     = "operator.open-cluster-management.io/signer-ca"
+	webhookCAAnnotationKey    = "operator.open-cluster-management.io/webhook-ca"
+	grpcServerCAAnnotationKey = "operator.open-cluster-management.io/grpc-server-ca"
+
+	// externalCAAnnotationKey records the external CA secret that the signer secret is copied from.
+	externalCAAnnotationKey = "operator.open-cluster-management.io/external-ca"
+	// certManagerCertificateAnnotationKey is set by cert-manager on the secrets it issues.
+	certManagerCertificateAnnotationKey = "cert-manager.io/certificate-name"
+	// certificateLabelKey is the label on the cert-manager Certificates created by the operator.
+	certificateLabelKey = "operator.open-cluster-management.io/cluster-manager"
+)
+
+var certificateGVR = schema.GroupVersionResource{Group: "cert-manager.io", Version: "v1", Resource: "certificates"}
+
+type caSourceType string
+
+const (
+	caSourceSelfSigned    caSourceType = ""
+	caSourceSecret        caSourceType = "secret"
+	caSourceIssuer        caSourceType = "issuer"
+	caSourceClusterIssuer caSourceType = "clusterissuer"
+)
+
+// caSource is where the certs of a role are signed.
+type caSource struct {
+	sourceType caSourceType
+	name       string
+}
+
+func (s caSource) String() string {
+	if s.sourceType == caSourceSelfSigned {
+		return "self-signed"
+	}
+	return fmt.Sprintf("%s:%s", s.sourceType, s.name)
+}
+
+// issuedByCertManager returns true if the certs of the role are issued by cert-manager.
+func (s caSource) issuedByCertManager() bool {
+	return s.sourceType == caSourceIssuer || s.sourceType == caSourceClusterIssuer
+}
+
+// caSources are the CA sources of the certificate roles of a cluster manager.
+type caSources struct {
+	signer     caSource
+	webhook    caSource
+	grpcServer caSource
+}
+
+func (s caSources) String() string {
+	return fmt.Sprintf("signer: %s, webhook: %s, grpc-server: %s", s.signer, s.webhook, s.grpcServer)
+}
+
+func parseCASources(clusterManager *operatorv1.ClusterManager) (caSources, error) {
+	var sources caSources
+	var err error
+	if sources.signer, err = parseCASource(
+		clusterManager.Annotations[signerCAAnnotationKey], helpers.SignerCASecret); err != nil {
+		return sources, fmt.Errorf("invalid annotation %s: %w", signerCAAnnotationKey, err)
+	}
+	if sources.webhook, err = parseCASource(
+		clusterManager.Annotations[webhookCAAnnotationKey], helpers.WebhookCASecret); err != nil {
+		return sources, fmt.Errorf("invalid annotation %s: %w", webhookCAAnnotationKey, err)
+	}
+	if sources.grpcServer, err = parseCASource(
+		clusterManager.Annotations[grpcServerCAAnnotationKey], helpers.GRPCServerCASecret); err != nil {
+		return sources, fmt.Errorf("invalid annotation %s: %w", grpcServerCAAnnotationKey, err)
+	}
+	return sources, nil
+}
+
+// parseCASource parses the CA source of a role, secretName is the only external CA secret allowed for the role.
+func parseCASource(value, secretName string) (caSource, error) {
+	if len(value) == 0 {
+		return caSource{}, nil
+	}
+	sourceType, name, found := strings.Cut(value, ":")
+	if !found || len(name) == 0 {
+		return caSource{}, fmt.Errorf("%q is not in the format of <type>:<name>", value)
+	}
+	switch caSourceType(sourceType) {
+	case caSourceSecret:
+		if name != secretName {
+			return caSource{}, fmt.Errorf("the external CA secret must be named %s, but got %s", secretName, name)
+		}
+		return caSource{sourceType: caSourceSecret, name: name}, nil
+	case caSourceIssuer, caSourceClusterIssuer:
+		return caSource{sourceType: caSourceType(sourceType), name: name}, nil
+	default:
+		return caSource{}, fmt.Errorf("unsupported type %q, only %s, %s and %s are supported",
+			sourceType, caSourceSecret, caSourceIssuer, caSourceClusterIssuer)
+	}
+}
+
+// getExternalCA reads the cert and key of an external CA from a secret.
+func (c certRotationController) getExternalCA(namespace, name string) (*corev1.Secret, *crypto.CA, error) {
+	secretInformer, ok := c.secretInformers[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("the external CA secret %s/%s is not watched", namespace, name)
+	}
+	secret, err := secretInformer.Lister().Secrets(namespace).Get(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get the external CA secret %s/%s: %w", namespace, name, err)
+	}
+	ca, err := crypto.GetCAFromBytes(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid external CA secret %s/%s: %w", namespace, name, err)
+	}
+	return secret, ca, nil
+}
+
+// ensureExternalSigner copies the external CA into the signer secret, so the cluster signer signs with it.
+func (c certRotationController) ensureExternalSigner(ctx context.Context, rotation certrotation.SigningRotation,
+	source caSource) (*crypto.CA, error) {
+	externalSecret, ca, err := c.getExternalCA(rotation.Namespace, source.name)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := rotation.Lister.Secrets(rotation.Namespace).Get(rotation.Name)
+	if errors.IsNotFound(err) {
+		_, err = c.kubeClient.CoreV1().Secrets(rotation.Namespace).Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   rotation.Namespace,
+				Name:        rotation.Name,
+				Annotations: map[string]string{externalCAAnnotationKey: source.name},
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       externalSecret.Data[corev1.TLSCertKey],
+				corev1.TLSPrivateKeyKey: externalSecret.Data[corev1.TLSPrivateKeyKey],
+			},
+		}, metav1.CreateOptions{})
+		return ca, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if existing.Annotations[externalCAAnnotationKey] == source.name &&
+		bytes.Equal(existing.Data[corev1.TLSCertKey], externalSecret.Data[corev1.TLSCertKey]) &&
+		bytes.Equal(existing.Data[corev1.TLSPrivateKeyKey], externalSecret.Data[corev1.TLSPrivateKeyKey]) {
+		return ca, nil
+	}
+
+	secret := existing.DeepCopy()
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	// the signer secret may be issued by cert-manager before.
+	delete(secret.Annotations, certManagerCertificateAnnotationKey)
+	secret.Annotations[externalCAAnnotationKey] = source.name
+	secret.Data = map[string][]byte{
+		corev1.TLSCertKey:       externalSecret.Data[corev1.TLSCertKey],
+		corev1.TLSPrivateKeyKey: externalSecret.Data[corev1.TLSPrivateKeyKey],
+	}
+	_, err = c.kubeClient.CoreV1().Secrets(rotation.Namespace).Update(ctx, secret, metav1.UpdateOptions{})
+	return ca, err
+}
+
+// certificateRequest is the cert-manager Certificate to issue a cert into a secret.
+type certificateRequest struct {
+	secretName string
+	commonName string
+	hostNames  []string
+	isCA       bool
+	duration   time.Duration
+}
+
+// ensureCertificate creates or updates the cert-manager Certificate which issues the cert into the secret. The
+// Certificate has the same name as the secret.
+func (c certRotationController) ensureCertificate(ctx context.Context, clusterManagerName, namespace string,
+	source caSource, request certificateRequest) error {
+	issuerKind := "Issuer"
+	if source.sourceType == caSourceClusterIssuer {
+		issuerKind = "ClusterIssuer"
+	}
+	spec := map[string]interface{}{
+		"secretName": request.secretName,
+		"commonName": request.commonName,
+		"duration":   request.duration.String(),
+		"issuerRef": map[string]interface{}{
+			"name":  source.name,
+			"kind":  issuerKind,
+			"group": certificateGVR.Group,
+		},
+	}
+	if request.isCA {
+		spec["isCA"] = true
+	}
+	var dnsNames, ipAddresses []interface{}
+	for _, hostName := range request.hostNames {
+		if net.ParseIP(hostName) != nil {
+			ipAddresses = append(ipAddresses, hostName)
+		} else {
+			dnsNames = append(dnsNames, hostName)
+		}
+	}
+	if len(dnsNames) > 0 {
+		spec["dnsNames"] = dnsNames
+	}
+	if len(ipAddresses) > 0 {
+		spec["ipAddresses"] = ipAddresses
+	}
+
+	if c.certificateLister == nil {
+		return fmt.Errorf("the resource %s is not served, restart the operator after cert-manager is installed",
+			certificateGVR.GroupResource())
+	}
+	client := c.dynamicClient.Resource(certificateGVR).Namespace(namespace)
+	obj, err := c.certificateLister.ByNamespace(namespace).Get(request.secretName)
+	switch {
+	case errors.IsNotFound(err):
+		certificate := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": certificateGVR.GroupVersion().String(),
+			"kind":       "Certificate",
+			"metadata": map[string]interface{}{
+				"name":      request.secretName,
+				"namespace": namespace,
+				"labels": map[string]interface{}{
+					certificateLabelKey: clusterManagerName,
+				},
+			},
+			"spec": spec,
+		}}
+		_, err = client.Create(ctx, certificate, metav1.CreateOptions{})
+		return err
+	case err != nil:
+		return fmt.Errorf("failed to get certificate %s/%s: %w", namespace, request.secretName, err)
+	}
+
+	existing, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("unexpected type %T of certificate %s/%s", obj, namespace, request.secretName)
+	}
+	existingSpec, _, _ := unstructured.NestedMap(existing.Object, "spec")
+	if equality.Semantic.DeepEqual(existingSpec, spec) {
+		return nil
+	}
+	certificate := existing.DeepCopy()
+	certificate.Object["spec"] = spec
+	_, err = client.Update(ctx, certificate, metav1.UpdateOptions{})
+	return err
+}
+
+// cleanupCertificates deletes the cert-manager Certificates created for the cluster manager except the ones in keep.
+func (c certRotationController) cleanupCertificates(ctx context.Context, clusterManagerName, namespace string,
+	keep sets.Set[string]) error {
+	// cert-manager is not installed.
+	if c.certificateLister == nil {
+		return nil
+	}
+	certificates, err := c.certificateLister.ByNamespace(namespace).List(
+		labels.SelectorFromSet(labels.Set{certificateLabelKey: clusterManagerName}))
+	if err != nil {
+		return err
+	}
+
+	client := c.dynamicClient.Resource(certificateGVR).Namespace(namespace)
+	for _, certificate := range certificates {
+		accessor, err := meta.Accessor(certificate)
+		if err != nil {
+			return err
+		}
+		if keep.Has(accessor.GetName()) {
+			continue
+		}
+		if err := client.Delete(ctx, accessor.GetName(), metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// certificateQueueKeyFunc returns the cluster manager of a cert-manager Certificate created by the operator.
+func certificateQueueKeyFunc(obj runtime.Object) []string {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return []string{}
+	}
+	if name := accessor.GetLabels()[certificateLabelKey]; len(name) > 0 {
+		return []string{name}
+	}
+	return []string{}
+}
+
+// NewCertificateInformer returns the informer of the cert-manager Certificates created by the operator, or nil if
+// cert-manager is not installed.
+func NewCertificateInformer(discoveryClient discovery.DiscoveryInterface,
+	dynamicClient dynamic.Interface) (informers.GenericInformer, error) {
+	resources, err := discoveryClient.ServerResourcesForGroupVersion(certificateGVR.GroupVersion().String())
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(resources.APIResources, func(resource metav1.APIResource) bool {
+		return resource.Name == certificateGVR.Resource
+	}) {
+		return nil, nil
+	}
+	return dynamicinformer.NewFilteredDynamicInformer(dynamicClient, certificateGVR, metav1.NamespaceAll, 5*time.Minute,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+		func(options *metav1.ListOptions) {
+			options.LabelSelector = certificateLabelKey
+		}), nil
+}
+
+// issuedSecret returns the secret issued by cert-manager, or nil if it is not issued yet.
+func issuedSecret(lister corev1listers.SecretLister, namespace, name string) (*corev1.Secret, error) {
+	secret, err := lister.Secrets(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := secret.Annotations[certManagerCertificateAnnotationKey]; !ok {
+		return nil, nil
+	}
+	return secret, nil
+}
+
+// issuingCACerts returns the certs of the CA which issues the cert in a secret issued by cert-manager. It is the
+// ca.crt of the secret, or the last cert of the chain in tls.crt if ca.crt is not set.
+func issuingCACerts(secret *corev1.Secret) ([]*x509.Certificate, error) {
+	if caData := secret.Data["ca.crt"]; len(caData) > 0 {
+		return cert.ParseCertsPEM(caData)
+	}
+	chain, err := cert.ParseCertsPEM(secret.Data[corev1.TLSCertKey])
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) < 2 {
+		return nil, fmt.Errorf("failed to find the CA of secret %s, neither ca.crt nor the chain in tls.crt is set",
+			secret.Name)
+	}
+	return chain[len(chain)-1:], nil
+}
+
+// ensureCABundle adds the CA certs into the CA bundle configmap, and removes the expired and duplicated ones. All
+// certs are added in one update so that the certs of the different roles do not override each other.
+func ensureCABundle(ctx context.Context, rotation certrotation.CABundleRotation, caCerts []*x509.Certificate) (
+	[]*x509.Certificate, error) {
+	original, err := rotation.Lister.ConfigMaps(rotation.Namespace).Get(rotation.Name)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	configMap := original.DeepCopy()
+	if errors.IsNotFound(err) {
+		configMap = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: rotation.Namespace, Name: rotation.Name}}
+	}
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+
+	certificates := append([]*x509.Certificate{}, caCerts...)
+	if caBundle := configMap.Data["ca-bundle.crt"]; len(caBundle) > 0 {
+		existingCerts, err := cert.ParseCertsPEM([]byte(caBundle))
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, existingCerts...)
+	}
+	certificates = crypto.FilterExpiredCerts(certificates...)
+
+	var finalCertificates []*x509.Certificate
+	for i := range certificates {
+		found := false
+		for j := range finalCertificates {
+			if bytes.Equal(certificates[i].Raw, finalCertificates[j].Raw) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			finalCertificates = append(finalCertificates, certificates[i])
+		}
+	}
+
+	caBytes, err := crypto.EncodeCertificates(finalCertificates...)
+	if err != nil {
+		return nil, err
+	}
+	configMap.Data["ca-bundle.crt"] = string(caBytes)
+
+	if original == nil || !equality.Semantic.DeepEqual(original.Data, configMap.Data) {
+		if _, _, err := sdkhelpers.ApplyConfigMap(ctx, rotation.Client, configMap); err != nil {
+			return nil, err
+		}
+	}
+	return finalCertificates, nil
+}
@@ -0,0 +1,475 @@
+package certrotationcontroller
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/openshift/library-go/pkg/crypto"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	kubeinformers "k8s.io/client-go/informers"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/cert"
+
+	fakeoperatorclient "open-cluster-management.io/api/client/operator/clientset/versioned/fake"
+	operatorinformers "open-cluster-management.io/api/client/operator/informers/externalversions"
+	operatorapiv1 "open-cluster-management.io/api/operator/v1"
+	"open-cluster-management.io/sdk-go/pkg/patcher"
+
+	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
+	"open-cluster-management.io/ocm/pkg/operator/helpers"
+)
+
+func TestParseCASource(t *testing.T) {
+	cases := []struct {
+		name        string
+		value       string
+		expected    caSource
+		expectedErr bool
+	}{
+		{
+			name:     "not set",
+			expected: caSource{},
+		},
+		{
+			name:     "secret",
+			value:    "secret:hub-signer-ca",
+			expected: caSource{sourceType: caSourceSecret, name: "hub-signer-ca"},
+		},
+		{
+			name:        "secret not allowed",
+			value:       "secret:corp-ca",
+			expectedErr: true,
+		},
+		{
+			name:     "issuer",
+			value:    "issuer:corp-issuer",
+			expected: caSource{sourceType: caSourceIssuer, name: "corp-issuer"},
+		},
+		{
+			name:     "cluster issuer",
+			value:    "clusterissuer:corp-issuer",
+			expected: caSource{sourceType: caSourceClusterIssuer, name: "corp-issuer"},
+		},
+		{
+			name:        "no name",
+			value:       "secret:",
+			expectedErr: true,
+		},
+		{
+			name:        "unsupported type",
+			value:       "vault:corp",
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			source, err := parseCASource(c.value, helpers.SignerCASecret)
+			if c.expectedErr != (err != nil) {
+				t.Fatalf("expected error %t, but got %v", c.expectedErr, err)
+			}
+			if source != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, source)
+			}
+		})
+	}
+}
+
+type caSourceTestContext struct {
+	controller      *certRotationController
+	kubeClient      *fakekube.Clientset
+	dynamicClient   *fakedynamic.FakeDynamicClient
+	operatorClient  *fakeoperatorclient.Clientset
+	secretInformers map[string]corev1informers.SecretInformer
+	// certificateStore is the store of the certificate informer.
+	certificateStore cache.Store
+	// clusterManagerStore is the store of the cluster manager informer to update the cluster manager.
+	clusterManagerStore cache.Store
+}
+
+func newCASourceTestContext(t *testing.T, clusterManager *operatorapiv1.ClusterManager,
+	objects ...runtime.Object) *caSourceTestContext {
+	kubeClient := fakekube.NewSimpleClientset(objects...)
+	newOnTermInformer := func(name string) kubeinformers.SharedInformerFactory {
+		return kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, 5*time.Minute,
+			kubeinformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+			}))
+	}
+	secretInformers := map[string]corev1informers.SecretInformer{}
+	for _, name := range []string{helpers.SignerSecret, helpers.RegistrationWebhookSecret, helpers.WorkWebhookSecret,
+		helpers.AddonWebhookSecret, helpers.GRPCServerSecret, helpers.SignerCASecret, helpers.WebhookCASecret,
+		helpers.GRPCServerCASecret} {
+		secretInformers[name] = newOnTermInformer(name).Core().V1().Secrets()
+	}
+	for _, obj := range objects {
+		if secret, ok := obj.(*corev1.Secret); ok {
+			if err := secretInformers[secret.Name].Informer().GetStore().Add(secret); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	operatorClient := fakeoperatorclient.NewSimpleClientset(clusterManager)
+	operatorInformers := operatorinformers.NewSharedInformerFactory(operatorClient, 5*time.Minute)
+	clusterManagerStore := operatorInformers.Operator().V1().ClusterManagers().Informer().GetStore()
+	if err := clusterManagerStore.Add(clusterManager); err != nil {
+		t.Fatal(err)
+	}
+
+	dynamicClient := newFakeDynamicClient()
+	certificateInformer := dynamicinformer.NewFilteredDynamicInformer(dynamicClient, certificateGVR, metav1.NamespaceAll,
+		5*time.Minute, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, nil)
+	return &caSourceTestContext{
+		controller: &certRotationController{
+			rotationMap:   make(map[string]rotations),
+			kubeClient:    kubeClient,
+			dynamicClient: dynamicClient,
+			patcher: patcher.NewPatcher[
+				*operatorapiv1.ClusterManager, operatorapiv1.ClusterManagerSpec, operatorapiv1.ClusterManagerStatus](
+				operatorClient.OperatorV1().ClusterManagers()),
+			secretInformers:      secretInformers,
+			configMapInformer:    newOnTermInformer(helpers.CaBundleConfigmap).Core().V1().ConfigMaps(),
+			certificateLister:    certificateInformer.Lister(),
+			clusterManagerLister: operatorInformers.Operator().V1().ClusterManagers().Lister(),
+		},
+		kubeClient:          kubeClient,
+		dynamicClient:       dynamicClient,
+		operatorClient:      operatorClient,
+		secretInformers:     secretInformers,
+		certificateStore:    certificateInformer.Informer().GetStore(),
+		clusterManagerStore: clusterManagerStore,
+	}
+}
+
+// syncCertificates syncs the certificates created by the controller into the certificate informer.
+func (c *caSourceTestContext) syncCertificates(t *testing.T) {
+	certificates, err := c.dynamicClient.Resource(certificateGVR).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs := []interface{}{}
+	for i := range certificates.Items {
+		objs = append(objs, &certificates.Items[i])
+	}
+	if err := c.certificateStore.Replace(objs, ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *caSourceTestContext) sync(t *testing.T) error {
+	return c.controller.sync(context.TODO(), testingcommon.NewFakeSyncContext(t, testClusterManagerNameDefault),
+		testClusterManagerNameDefault)
+}
+
+func (c *caSourceTestContext) assertCondition(t *testing.T, status metav1.ConditionStatus, reason string) {
+	clusterManager, err := c.operatorClient.OperatorV1().ClusterManagers().Get(
+		context.TODO(), testClusterManagerNameDefault, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cond := meta.FindStatusCondition(clusterManager.Status.Conditions, certificatesReadyConditionType)
+	if cond == nil {
+		t.Fatalf("expected condition %s, but got %v", certificatesReadyConditionType, clusterManager.Status.Conditions)
+	}
+	if cond.Status != status || cond.Reason != reason {
+		t.Errorf("expected condition %s %s, but got %s %s: %s", status, reason, cond.Status, cond.Reason, cond.Message)
+	}
+}
+
+func (c *caSourceTestContext) caBundle(t *testing.T, namespace string) *x509.CertPool {
+	configMap, err := c.kubeClient.CoreV1().ConfigMaps(namespace).Get(context.TODO(), helpers.CaBundleConfigmap, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCerts, err := cert.ParseCertsPEM([]byte(configMap.Data["ca-bundle.crt"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	for _, caCert := range caCerts {
+		pool.AddCert(caCert)
+	}
+	return pool
+}
+
+func newTestCA(t *testing.T, name string) (*crypto.CA, []byte, []byte) {
+	config, err := crypto.MakeSelfSignedCAConfigForDuration(name, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, keyPEM, err := config.GetPEMBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &crypto.CA{Config: config, SerialGenerator: &crypto.RandomSerialGenerator{}}, certPEM, keyPEM
+}
+
+func verifyServingCert(t *testing.T, secret *corev1.Secret, roots *x509.CertPool, dnsName string) {
+	certs, err := cert.ParseCertsPEM(secret.Data[corev1.TLSCertKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       dnsName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	}); err != nil {
+		t.Errorf("failed to verify the cert of secret %s: %v", secret.Name, err)
+	}
+}
+
+func TestExternalCASecret(t *testing.T) {
+	namespace := helpers.ClusterManagerNamespace(testClusterManagerNameDefault, operatorapiv1.InstallModeDefault)
+	clusterManager := newClusterManager(testClusterManagerNameDefault, operatorapiv1.InstallModeDefault)
+	clusterManager.Annotations = map[string]string{
+		signerCAAnnotationKey:  "secret:" + helpers.SignerCASecret,
+		webhookCAAnnotationKey: "secret:" + helpers.WebhookCASecret,
+	}
+	_, signerCert, signerKey := newTestCA(t, "corp-signer")
+	webhookCA, webhookCert, webhookKey := newTestCA(t, "corp-webhook")
+
+	ctx := newCASourceTestContext(t, clusterManager,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: helpers.SignerCASecret, Namespace: namespace},
+			Data:       map[string][]byte{corev1.TLSCertKey: signerCert, corev1.TLSPrivateKeyKey: signerKey},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: helpers.WebhookCASecret, Namespace: namespace},
+			Data:       map[string][]byte{corev1.TLSCertKey: webhookCert, corev1.TLSPrivateKeyKey: webhookKey},
+		},
+	)
+	if err := ctx.sync(t); err != nil {
+		t.Fatal(err)
+	}
+
+	signerSecret, err := ctx.kubeClient.CoreV1().Secrets(namespace).Get(context.TODO(), helpers.SignerSecret, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(signerSecret.Data[corev1.TLSCertKey]) != string(signerCert) {
+		t.Errorf("expected the signer secret to be copied from the external CA")
+	}
+	if signerSecret.Annotations[externalCAAnnotationKey] != helpers.SignerCASecret {
+		t.Errorf("expected the external CA annotation, but got %v", signerSecret.Annotations)
+	}
+
+	roots := ctx.caBundle(t, namespace)
+	webhookSecret, err := ctx.kubeClient.CoreV1().Secrets(namespace).Get(
+		context.TODO(), helpers.RegistrationWebhookSecret, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyServingCert(t, webhookSecret, roots, "cluster-manager-registration-webhook."+namespace+".svc")
+	servingCerts, err := cert.ParseCertsPEM(webhookSecret.Data[corev1.TLSCertKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if servingCerts[0].Issuer.CommonName != webhookCA.Config.Certs[0].Subject.CommonName {
+		t.Errorf("expected the webhook cert to be signed by the external CA, but got %s", servingCerts[0].Issuer.CommonName)
+	}
+
+	ctx.assertCondition(t, metav1.ConditionTrue, reasonCertificatesReady)
+}
+
+func TestCertManagerIssuer(t *testing.T) {
+	namespace := helpers.ClusterManagerNamespace(testClusterManagerNameDefault, operatorapiv1.InstallModeDefault)
+	clusterManager := newClusterManager(testClusterManagerNameDefault, operatorapiv1.InstallModeDefault)
+	clusterManager.Annotations = map[string]string{
+		webhookCAAnnotationKey: "clusterissuer:corp-issuer",
+	}
+
+	ctx := newCASourceTestContext(t, clusterManager, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+	if err := ctx.sync(t); err != nil {
+		t.Fatal(err)
+	}
+	ctx.assertCondition(t, metav1.ConditionFalse, reasonCertificatesPending)
+	ctx.syncCertificates(t)
+
+	webhookSecrets := []string{helpers.RegistrationWebhookSecret, helpers.WorkWebhookSecret, helpers.AddonWebhookSecret}
+	for _, name := range webhookSecrets {
+		certificate, err := ctx.dynamicClient.Resource(certificateGVR).Namespace(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		kind, _, _ := unstructured.NestedString(certificate.Object, "spec", "issuerRef", "kind")
+		issuer, _, _ := unstructured.NestedString(certificate.Object, "spec", "issuerRef", "name")
+		secretName, _, _ := unstructured.NestedString(certificate.Object, "spec", "secretName")
+		if kind != "ClusterIssuer" || issuer != "corp-issuer" || secretName != name {
+			t.Errorf("unexpected certificate %v", certificate.Object["spec"])
+		}
+	}
+	// the grpc server cert is not issued by cert-manager
+	if _, err := ctx.dynamicClient.Resource(certificateGVR).Namespace(namespace).Get(
+		context.TODO(), helpers.GRPCServerSecret, metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expected no certificate for the grpc server, but got %v", err)
+	}
+
+	// cert-manager issues the certs
+	issuerCA, issuerCert, _ := newTestCA(t, "corp-issuer")
+	for _, name := range webhookSecrets {
+		hostName := ctx.controller.rotationMap[testClusterManagerNameDefault].targetRotations[name].HostNames[0]
+		servingCert, err := issuerCA.MakeServerCert(sets.New[string](hostName), time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certPEM, keyPEM, err := servingCert.GetPEMBytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   namespace,
+				Annotations: map[string]string{certManagerCertificateAnnotationKey: name},
+			},
+			Data: map[string][]byte{
+				corev1.TLSCertKey:       certPEM,
+				corev1.TLSPrivateKeyKey: keyPEM,
+				"ca.crt":                issuerCert,
+			},
+		}
+		if _, err := ctx.kubeClient.CoreV1().Secrets(namespace).Create(context.TODO(), secret, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := ctx.secretInformers[name].Informer().GetStore().Add(secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ctx.sync(t); err != nil {
+		t.Fatal(err)
+	}
+
+	roots := ctx.caBundle(t, namespace)
+	for _, name := range webhookSecrets {
+		secret, err := ctx.secretInformers[name].Lister().Secrets(namespace).Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verifyServingCert(t, secret, roots, ctx.controller.rotationMap[testClusterManagerNameDefault].targetRotations[name].HostNames[0])
+	}
+	ctx.assertCondition(t, metav1.ConditionTrue, reasonCertificatesReady)
+
+	// switch back to the self-signed CA, the certificates are deleted and the issued secrets are signed again
+	clusterManager = clusterManager.DeepCopy()
+	clusterManager.Annotations = nil
+	if err := ctx.clusterManagerStore.Update(clusterManager); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.sync(t); err != nil {
+		t.Fatal(err)
+	}
+	ctx.assertCondition(t, metav1.ConditionTrue, reasonCertificatesReady)
+	certificates, err := ctx.dynamicClient.Resource(certificateGVR).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certificates.Items) != 0 {
+		t.Errorf("expected the certificates to be deleted, but got %d", len(certificates.Items))
+	}
+	signerSecret, err := ctx.kubeClient.CoreV1().Secrets(namespace).Get(context.TODO(), helpers.SignerSecret, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signerCerts, err := cert.ParseCertsPEM(signerSecret.Data[corev1.TLSCertKey])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signerRoots := x509.NewCertPool()
+	signerRoots.AddCert(signerCerts[0])
+	for _, name := range webhookSecrets {
+		secret, err := ctx.kubeClient.CoreV1().Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := secret.Annotations[certManagerCertificateAnnotationKey]; ok {
+			t.Errorf("expected the cert-manager annotation of secret %s to be removed", name)
+		}
+		verifyServingCert(t, secret, signerRoots, ctx.controller.rotationMap[testClusterManagerNameDefault].targetRotations[name].HostNames[0])
+	}
+}
+
+func TestCertManagerNotInstalled(t *testing.T) {
+	namespace := helpers.ClusterManagerNamespace(testClusterManagerNameDefault, operatorapiv1.InstallModeDefault)
+	clusterManager := newClusterManager(testClusterManagerNameDefault, operatorapiv1.InstallModeDefault)
+	clusterManager.Annotations = map[string]string{
+		webhookCAAnnotationKey: "issuer:corp-issuer",
+	}
+
+	ctx := newCASourceTestContext(t, clusterManager, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+	ctx.controller.certificateLister = nil
+	if err := ctx.sync(t); err == nil {
+		t.Fatalf("expected an error since cert-manager is not installed")
+	}
+	ctx.assertCondition(t, metav1.ConditionFalse, reasonCertificatesNotReady)
+}
+
+func TestInvalidCASource(t *testing.T) {
+	namespace := helpers.ClusterManagerNamespace(testClusterManagerNameDefault, operatorapiv1.InstallModeDefault)
+	clusterManager := newClusterManager(testClusterManagerNameDefault, operatorapiv1.InstallModeDefault)
+	clusterManager.Annotations = map[string]string{
+		signerCAAnnotationKey: "vault:corp",
+	}
+
+	ctx := newCASourceTestContext(t, clusterManager, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+	if err := ctx.sync(t); err == nil {
+		t.Fatalf("expected an error")
+	}
+	ctx.assertCondition(t, metav1.ConditionFalse, reasonInvalidCASource)
+
+	if _, err := ctx.kubeClient.CoreV1().Secrets(namespace).Get(
+		context.TODO(), helpers.SignerSecret, metav1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Errorf("expected no signer secret created, but got %v", err)
+	}
+}
+
+func TestSwitchSignerToSelfSigned(t *testing.T) {
+	namespace := helpers.ClusterManagerNamespace(testClusterManagerNameDefault, operatorapiv1.InstallModeDefault)
+	clusterManager := newClusterManager(testClusterManagerNameDefault, operatorapiv1.InstallModeDefault)
+	_, signerCert, signerKey := newTestCA(t, "corp-signer")
+	signerSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        helpers.SignerSecret,
+			Namespace:   namespace,
+			Annotations: map[string]string{externalCAAnnotationKey: helpers.SignerCASecret},
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{corev1.TLSCertKey: signerCert, corev1.TLSPrivateKeyKey: signerKey},
+	}
+
+	ctx := newCASourceTestContext(t, clusterManager,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}, signerSecret)
+	if err := ctx.sync(t); err != nil {
+		t.Fatal(err)
+	}
+	ctx.assertCondition(t, metav1.ConditionTrue, reasonCertificatesReady)
+	secret, err := ctx.kubeClient.CoreV1().Secrets(namespace).Get(context.TODO(), helpers.SignerSecret, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := secret.Annotations[externalCAAnnotationKey]; ok {
+		t.Errorf("expected the external CA annotation to be removed, but got %v", secret.Annotations)
+	}
+	if bytes.Equal(secret.Data[corev1.TLSCertKey], signerCert) {
+		t.Errorf("expected the signer secret copied from the external CA to be replaced by a self-signed CA")
+	}
+}
@@ -2,24 +2,36 @@ package certrotationcontroller
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
 	"slices"
+	"strings"
 	"time"
 
+	"github.com/openshift/library-go/pkg/crypto"
 	errorhelpers "github.com/openshift/library-go/pkg/operator/v1helpers"
 	operatorhelpers "github.com/openshift/library-go/pkg/operator/v1helpers"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
 	corev1informers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 
+	operatorv1client "open-cluster-management.io/api/client/operator/clientset/versioned/typed/operator/v1"
 	operatorinformer "open-cluster-management.io/api/client/operator/informers/externalversions/operator/v1"
 	operatorlister "open-cluster-management.io/api/client/operator/listers/operator/v1"
 	operatorv1 "open-cluster-management.io/api/operator/v1"
 	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
 	"open-cluster-management.io/sdk-go/pkg/certrotation"
+	"open-cluster-management.io/sdk-go/pkg/patcher"
 
 	"open-cluster-management.io/ocm/pkg/common/queue"
 	"open-cluster-management.io/ocm/pkg/operator/helpers"
@@ -27,6 +39,13 @@ import (
 
 const (
 	signerNamePrefix = "cluster-manager-webhook"
+
+	// certificatesReadyConditionType reports whether the certs of the cluster manager are signed by their CA sources.
+	certificatesReadyConditionType = "HubCertificatesReady"
+	reasonCertificatesReady        = "CertificatesReady"
+	reasonCertificatesNotReady     = "CertificatesNotReady"
+	reasonCertificatesPending      = "CertificatesPending"
+	reasonInvalidCASource          = "InvalidCASource"
 )
 
 // Follow the rules below to set the value of SigningCertValidity/TargetCertValidity/ResyncInterval:
@@ -45,11 +64,17 @@ var ResyncInterval = time.Minute * 10
 //  3. continuously create target cert/key pairs signed by the latest signing CA
 //     It creates the next one when a given percentage of the validity of the previous cert has
 //     passed, or when a new CA has been created.
+//
+// The signing CA and the target certs can be signed by an external CA or issued by cert-manager instead, see
+// signerCAAnnotationKey. The CAs of them are added into the CA bundle as well.
 type certRotationController struct {
 	rotationMap          map[string]rotations // key is clusterManager's name, value is a rotations struct
 	kubeClient           kubernetes.Interface
+	dynamicClient        dynamic.Interface
+	patcher              patcher.Patcher[*operatorv1.ClusterManager, operatorv1.ClusterManagerSpec, operatorv1.ClusterManagerStatus]
 	secretInformers      map[string]corev1informers.SecretInformer
 	configMapInformer    corev1informers.ConfigMapInformer
+	certificateLister    cache.GenericLister // nil if cert-manager is not installed
 	clusterManagerLister operatorlister.ClusterManagerLister
 }
 
@@ -61,18 +86,25 @@ type rotations struct {
 
 func NewCertRotationController(
 	kubeClient kubernetes.Interface,
+	dynamicClient dynamic.Interface,
+	clusterManagerClient operatorv1client.ClusterManagerInterface,
 	secretInformers map[string]corev1informers.SecretInformer,
 	configMapInformer corev1informers.ConfigMapInformer,
+	certificateInformer informers.GenericInformer,
 	clusterManagerInformer operatorinformer.ClusterManagerInformer,
 ) factory.Controller {
 	c := &certRotationController{
-		rotationMap:          make(map[string]rotations),
-		kubeClient:           kubeClient,
+		rotationMap:   make(map[string]rotations),
+		kubeClient:    kubeClient,
+		dynamicClient: dynamicClient,
+		patcher: patcher.NewPatcher[
+			*operatorv1.ClusterManager, operatorv1.ClusterManagerSpec, operatorv1.ClusterManagerStatus](
+			clusterManagerClient),
 		secretInformers:      secretInformers,
 		configMapInformer:    configMapInformer,
 		clusterManagerLister: clusterManagerInformer.Lister(),
 	}
-	return factory.New().
+	controllerFactory := factory.New().
 		ResyncEvery(ResyncInterval).
 		WithSync(c.sync).
 		WithInformersQueueKeysFunc(queue.QueueKeyByMetaName, clusterManagerInformer.Informer()).
@@ -82,8 +114,15 @@ func NewCertRotationController(
 			secretInformers[helpers.RegistrationWebhookSecret].Informer(),
 			secretInformers[helpers.WorkWebhookSecret].Informer(),
 			secretInformers[helpers.AddonWebhookSecret].Informer(),
-			secretInformers[helpers.GRPCServerSecret].Informer()).
-		ToController("CertRotationController")
+			secretInformers[helpers.GRPCServerSecret].Informer(),
+			secretInformers[helpers.SignerCASecret].Informer(),
+			secretInformers[helpers.WebhookCASecret].Informer(),
+			secretInformers[helpers.GRPCServerCASecret].Informer())
+	if certificateInformer != nil {
+		c.certificateLister = certificateInformer.Lister()
+		controllerFactory = controllerFactory.WithInformersQueueKeysFunc(certificateQueueKeyFunc, certificateInformer.Informer())
+	}
+	return controllerFactory.ToController("CertRotationController")
 }
 
 func (c certRotationController) sync(ctx context.Context, syncCtx factory.SyncContext, key string) error {
@@ -142,6 +181,12 @@ func (c certRotationController) syncOne(ctx context.Context, clustermanager *ope
 	if !clustermanager.DeletionTimestamp.IsZero() {
 		// clean up all resources related with this clustermanager
 		if _, ok := c.rotationMap[clustermanagerName]; ok {
+			// delete the cert-manager certificates
+			err = c.cleanupCertificates(ctx, clustermanagerName, clustermanagerNamespace, sets.New[string]())
+			if err != nil {
+				return fmt.Errorf("clean up deleted cluster-manager, deleting certificates failed, err:%s", err.Error())
+			}
+
 			// delete signerSecret
 			err = c.kubeClient.CoreV1().Secrets(clustermanagerNamespace).Delete(ctx, helpers.SignerSecret, metav1.DeleteOptions{})
 			if err != nil {
@@ -282,24 +327,248 @@ func (c certRotationController) syncOne(ctx context.Context, clustermanager *ope
 		}
 	}
 
+	sources, err := parseCASources(clustermanager)
+	if err != nil {
+		return c.updateCertificatesCondition(ctx, clustermanager, metav1.Condition{
+			Type:    certificatesReadyConditionType,
+			Status:  metav1.ConditionFalse,
+			Reason:  reasonInvalidCASource,
+			Message: err.Error(),
+		}, err)
+	}
+
+	pending, err := c.ensureCertificates(ctx, clustermanager, clustermanagerNamespace, cmRotations, sources)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := errorhelpers.NewMultiLineAggregate(errs); err != nil {
+		return c.updateCertificatesCondition(ctx, clustermanager, metav1.Condition{
+			Type:    certificatesReadyConditionType,
+			Status:  metav1.ConditionFalse,
+			Reason:  reasonCertificatesNotReady,
+			Message: err.Error(),
+		}, err)
+	}
+	// the secrets issued by cert-manager requeue the cluster manager once they are issued, so the pending state
+	// is not an error.
+	if len(pending) > 0 {
+		return c.updateCertificatesCondition(ctx, clustermanager, metav1.Condition{
+			Type:    certificatesReadyConditionType,
+			Status:  metav1.ConditionFalse,
+			Reason:  reasonCertificatesPending,
+			Message: fmt.Sprintf("Waiting for cert-manager to issue the secrets %s", strings.Join(pending, ", ")),
+		}, nil)
+	}
+	return c.updateCertificatesCondition(ctx, clustermanager, metav1.Condition{
+		Type:    certificatesReadyConditionType,
+		Status:  metav1.ConditionTrue,
+		Reason:  reasonCertificatesReady,
+		Message: fmt.Sprintf("Certificates are ready, %s", sources),
+	}, nil)
+}
+
+// ensureCertificates reconciles the signer, the CA bundle and the serving certs with the CA source of each role. It
+// returns the secrets which are not issued by cert-manager yet.
+func (c certRotationController) ensureCertificates(ctx context.Context, clustermanager *operatorv1.ClusterManager,
+	namespace string, cmRotations rotations, sources caSources) ([]string, error) {
+	var errs []error
+
+	// the certificates not issued by cert-manager any more are deleted, so cert-manager stops renewing the secrets.
+	certificates := sets.New[string]()
+	if sources.signer.issuedByCertManager() {
+		certificates.Insert(helpers.SignerSecret)
+	}
+	for name := range cmRotations.targetRotations {
+		if sourceOfTarget(name, sources).issuedByCertManager() {
+			certificates.Insert(name)
+		}
+	}
+	if err := c.cleanupCertificates(ctx, clustermanager.Name, namespace, certificates); err != nil {
+		return nil, err
+	}
+
 	// reconcile cert/key pair for signer
-	signingCertKeyPair, err := cmRotations.signingRotation.EnsureSigningCertKeyPair()
+	signingCertKeyPair, err := c.ensureSigner(ctx, clustermanager.Name, cmRotations.signingRotation, sources.signer)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if signingCertKeyPair == nil {
+		return []string{helpers.SignerSecret}, nil
+	}
+
+	// the CAs of the serving certs which are not signed by the signer are added into the ca bundle as well.
+	caCerts := []*x509.Certificate{signingCertKeyPair.Config.Certs[0]}
+	externalCAs := map[caSource]*crypto.CA{}
+	var pending []string
+	for name, targetRotation := range cmRotations.targetRotations {
+		source := sourceOfTarget(name, sources)
+		switch {
+		case source.sourceType == caSourceSecret:
+			if _, ok := externalCAs[source]; ok {
+				continue
+			}
+			_, ca, err := c.getExternalCA(namespace, source.name)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			externalCAs[source] = ca
+			caCerts = append(caCerts, ca.Config.Certs[0])
+		case source.issuedByCertManager():
+			if err := c.ensureCertificate(ctx, clustermanager.Name, namespace, source, certificateRequest{
+				secretName: name,
+				commonName: targetRotation.HostNames[0],
+				hostNames:  targetRotation.HostNames,
+				duration:   targetRotation.Validity,
+			}); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			secret, err := issuedSecret(targetRotation.Lister, namespace, name)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if secret == nil {
+				pending = append(pending, name)
+				continue
+			}
+			issuingCerts, err := issuingCACerts(secret)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			caCerts = append(caCerts, issuingCerts...)
+		}
 	}
 
 	// reconcile ca bundle
-	cabundleCerts, err := cmRotations.caBundleRotation.EnsureConfigMapCABundle(signingCertKeyPair)
+	cabundleCerts, err := ensureCABundle(ctx, cmRotations.caBundleRotation, caCerts)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// reconcile target cert/key pairs
-	for _, targetRotation := range cmRotations.targetRotations {
-		if err := targetRotation.EnsureTargetCertKeyPair(signingCertKeyPair, cabundleCerts); err != nil {
+	for name, targetRotation := range cmRotations.targetRotations {
+		source := sourceOfTarget(name, sources)
+		signer, bundle := signingCertKeyPair, cabundleCerts
+		switch {
+		case source.issuedByCertManager():
+			// cert-manager renews the cert.
+			continue
+		case source.sourceType == caSourceSecret:
+			ca, ok := externalCAs[source]
+			if !ok {
+				continue
+			}
+			// only the external CA is passed as the bundle, so the cert signed by the previous CA is replaced
+			// immediately.
+			signer, bundle = ca, ca.Config.Certs
+		}
+		targetRotation, err := c.resetIssuedTarget(ctx, targetRotation)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := targetRotation.EnsureTargetCertKeyPair(signer, bundle); err != nil {
 			errs = append(errs, err)
 		}
 	}
 
-	return errorhelpers.NewMultiLineAggregate(errs)
+	slices.Sort(pending)
+	return pending, errorhelpers.NewMultiLineAggregate(errs)
+}
+
+// ensureSigner returns the signing CA of the cluster signer by the CA source, or nil if the signing CA is not
+// issued by cert-manager yet.
+func (c certRotationController) ensureSigner(ctx context.Context, clusterManagerName string,
+	signingRotation certrotation.SigningRotation, source caSource) (*crypto.CA, error) {
+	switch {
+	case source.sourceType == caSourceSecret:
+		return c.ensureExternalSigner(ctx, signingRotation, source)
+	case source.issuedByCertManager():
+		if err := c.ensureCertificate(ctx, clusterManagerName, signingRotation.Namespace, source, certificateRequest{
+			secretName: signingRotation.Name,
+			commonName: signingRotation.SignerNamePrefix,
+			isCA:       true,
+			duration:   signingRotation.Validity,
+		}); err != nil {
+			return nil, err
+		}
+		secret, err := issuedSecret(signingRotation.Lister, signingRotation.Namespace, signingRotation.Name)
+		if err != nil || secret == nil {
+			return nil, err
+		}
+		return crypto.GetCAFromBytes(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	}
+
+	// the signer is self-signed, the external CA or the CA issued by cert-manager used before is replaced by a
+	// self-signed one.
+	secret, err := signingRotation.Lister.Secrets(signingRotation.Namespace).Get(signingRotation.Name)
+	switch {
+	case errors.IsNotFound(err):
+	case err != nil:
+		return nil, err
+	case len(secret.Annotations[externalCAAnnotationKey]) > 0 || len(secret.Annotations[certManagerCertificateAnnotationKey]) > 0:
+		if signingRotation.Lister, err = c.resetSecret(ctx, secret); err != nil {
+			return nil, err
+		}
+	}
+	return signingRotation.EnsureSigningCertKeyPair()
+}
+
+// resetIssuedTarget resets the target secret issued by cert-manager before, so that it is signed again by the
+// operator.
+func (c certRotationController) resetIssuedTarget(ctx context.Context,
+	targetRotation certrotation.TargetRotation) (certrotation.TargetRotation, error) {
+	secret, err := targetRotation.Lister.Secrets(targetRotation.Namespace).Get(targetRotation.Name)
+	if errors.IsNotFound(err) {
+		return targetRotation, nil
+	}
+	if err != nil {
+		return targetRotation, err
+	}
+	if _, ok := secret.Annotations[certManagerCertificateAnnotationKey]; !ok {
+		return targetRotation, nil
+	}
+	targetRotation.Lister, err = c.resetSecret(ctx, secret)
+	return targetRotation, err
+}
+
+// resetSecret removes the cert and key copied from an external CA or issued by cert-manager from the secret, and
+// returns a lister with the reset secret, so that the rotation signs a new cert in the same sync rather than
+// waiting for the informer.
+func (c certRotationController) resetSecret(ctx context.Context, secret *corev1.Secret) (corev1listers.SecretLister, error) {
+	reset := secret.DeepCopy()
+	delete(reset.Annotations, externalCAAnnotationKey)
+	delete(reset.Annotations, certManagerCertificateAnnotationKey)
+	reset.Data = nil
+	updated, err := c.kubeClient.CoreV1().Secrets(reset.Namespace).Update(ctx, reset, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	if err := indexer.Add(updated); err != nil {
+		return nil, err
+	}
+	return corev1listers.NewSecretLister(indexer), nil
+}
+
+func (c certRotationController) updateCertificatesCondition(ctx context.Context, clustermanager *operatorv1.ClusterManager,
+	cond metav1.Condition, syncErr error) error {
+	newClusterManager := clustermanager.DeepCopy()
+	meta.SetStatusCondition(&newClusterManager.Status.Conditions, cond)
+	if _, err := c.patcher.PatchStatus(ctx, newClusterManager, newClusterManager.Status, clustermanager.Status); err != nil {
+		return errorhelpers.NewMultiLineAggregate([]error{syncErr, err})
+	}
+	return syncErr
+}
+
+// sourceOfTarget returns the CA source of a target cert.
+func sourceOfTarget(name string, sources caSources) caSource {
+	if name == helpers.GRPCServerSecret {
+		return sources.grpcServer
+	}
+	return sources.webhook
 }
@@ -12,6 +12,8 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
 	kubeinformers "k8s.io/client-go/informers"
 	corev1informers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
@@ -23,6 +25,7 @@ import (
 	operatorapiv1 "open-cluster-management.io/api/operator/v1"
 	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
 	"open-cluster-management.io/sdk-go/pkg/certrotation"
+	"open-cluster-management.io/sdk-go/pkg/patcher"
 
 	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
 	"open-cluster-management.io/ocm/pkg/operator/helpers"
@@ -59,6 +62,11 @@ func newClusterManager(name string, mode operatorapiv1.InstallMode) *operatorapi
 	}
 }
 
+func newFakeDynamicClient(objects ...runtime.Object) *fakedynamic.FakeDynamicClient {
+	return fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{certificateGVR: "CertificateList"}, objects...)
+}
+
 type validateFunc func(t *testing.T, kubeClient kubernetes.Interface, err error)
 
 func TestCertRotation(t *testing.T) {
@@ -177,6 +185,9 @@ func TestCertRotation(t *testing.T) {
 				helpers.WorkWebhookSecret:         newOnTermInformer(helpers.WorkWebhookSecret).Core().V1().Secrets(),
 				helpers.AddonWebhookSecret:        newOnTermInformer(helpers.AddonWebhookSecret).Core().V1().Secrets(),
 				helpers.GRPCServerSecret:          newOnTermInformer(helpers.GRPCServerSecret).Core().V1().Secrets(),
+				helpers.SignerCASecret:            newOnTermInformer(helpers.SignerCASecret).Core().V1().Secrets(),
+				helpers.WebhookCASecret:           newOnTermInformer(helpers.WebhookCASecret).Core().V1().Secrets(),
+				helpers.GRPCServerCASecret:        newOnTermInformer(helpers.GRPCServerCASecret).Core().V1().Secrets(),
 			}
 
 			configmapInformer := newOnTermInformer(helpers.CaBundleConfigmap).Core().V1().ConfigMaps()
@@ -196,7 +207,8 @@ func TestCertRotation(t *testing.T) {
 
 			syncContext := testingcommon.NewFakeSyncContext(t, c.queueKey)
 
-			controller := NewCertRotationController(kubeClient, secretInformers, configmapInformer, operatorInformers.Operator().V1().ClusterManagers())
+			controller := NewCertRotationController(kubeClient, newFakeDynamicClient(), operatorClient.OperatorV1().ClusterManagers(),
+				secretInformers, configmapInformer, nil, operatorInformers.Operator().V1().ClusterManagers())
 
 			err := controller.Sync(context.TODO(), syncContext, c.queueKey)
 			c.validate(t, kubeClient, err)
@@ -336,8 +348,12 @@ func TestCertRotationGRPCAuth(t *testing.T) {
 
 			// Create the controller to check the rotation map
 			controller := &certRotationController{
-				rotationMap:          make(map[string]rotations),
-				kubeClient:           kubeClient,
+				rotationMap:   make(map[string]rotations),
+				kubeClient:    kubeClient,
+				dynamicClient: newFakeDynamicClient(),
+				patcher: patcher.NewPatcher[
+					*operatorapiv1.ClusterManager, operatorapiv1.ClusterManagerSpec, operatorapiv1.ClusterManagerStatus](
+					operatorClient.OperatorV1().ClusterManagers()),
 				secretInformers:      secretInformers,
 				configMapInformer:    configmapInformer,
 				clusterManagerLister: operatorInformers.Operator().V1().ClusterManagers().Lister(),
@@ -691,8 +707,12 @@ func TestCertRotationGRPCServerHostNames(t *testing.T) {
 			syncContext := testingcommon.NewFakeSyncContext(t, testClusterManagerNameDefault)
 
 			controller := &certRotationController{
-				rotationMap:          make(map[string]rotations),
-				kubeClient:           kubeClient,
+				rotationMap:   make(map[string]rotations),
+				kubeClient:    kubeClient,
+				dynamicClient: newFakeDynamicClient(),
+				patcher: patcher.NewPatcher[
+					*operatorapiv1.ClusterManager, operatorapiv1.ClusterManagerSpec, operatorapiv1.ClusterManagerStatus](
+					operatorClient.OperatorV1().ClusterManagers()),
 				secretInformers:      secretInformers,
 				configMapInformer:    configmapInformer,
 				clusterManagerLister: operatorInformers.Operator().V1().ClusterManagers().Lister(),
@@ -17,6 +17,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 	appsinformer "k8s.io/client-go/informers/apps/v1"
 	corev1informers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
@@ -37,7 +38,9 @@ import (
 	"open-cluster-management.io/ocm/manifests"
 	commonhelper "open-cluster-management.io/ocm/pkg/common/helpers"
 	"open-cluster-management.io/ocm/pkg/common/queue"
+	"open-cluster-management.io/ocm/pkg/features"
 	"open-cluster-management.io/ocm/pkg/operator/helpers"
+	"open-cluster-management.io/ocm/pkg/operator/operators/crdmanager"
 )
 
 const (
@@ -50,12 +53,15 @@ const (
 )
 
 type clusterManagerController struct {
-	patcher              patcher.Patcher[*operatorapiv1.ClusterManager, operatorapiv1.ClusterManagerSpec, operatorapiv1.ClusterManagerStatus]
-	clusterManagerLister operatorlister.ClusterManagerLister
-	operatorKubeClient   kubernetes.Interface
-	operatorKubeconfig   *rest.Config
-	configMapLister      corev1listers.ConfigMapLister
-	cache                resourceapply.ResourceCache
+	patcher                 patcher.Patcher[*operatorapiv1.ClusterManager, operatorapiv1.ClusterManagerSpec, operatorapiv1.ClusterManagerStatus]
+	clusterManagerLister    operatorlister.ClusterManagerLister
+	operatorKubeClient      kubernetes.Interface
+	operatorKubeconfig      *rest.Config
+	configMapLister         corev1listers.ConfigMapLister
+	rollbackConfigMapLister corev1listers.ConfigMapLister
+	inventoryLister         corev1listers.ConfigMapLister
+	cache                   resourceapply.ResourceCache
+	fieldUsageCache         *crdmanager.FieldUsageCache
 	// For testcases which don't need these functions, we could set fake funcs
 	ensureSAKubeconfigs func(ctx context.Context, clusterManagerName, clusterManagerNamespace string,
 		hubConfig *rest.Config, hubClient, managementClient kubernetes.Interface, recorder events.Recorder,
@@ -89,6 +95,8 @@ func NewClusterManagerController(
 	clusterManagerInformer operatorinformer.ClusterManagerInformer,
 	deploymentInformer appsinformer.DeploymentInformer,
 	configMapInformer corev1informers.ConfigMapInformer,
+	rollbackConfigMapInformer corev1informers.ConfigMapInformer,
+	inventoryInformer corev1informers.ConfigMapInformer,
 	skipRemoveCRDs bool,
 	controlPlaneNodeLabelSelector string,
 	deploymentReplicas int32,
@@ -103,9 +111,12 @@ func NewClusterManagerController(
 			clusterManagerClient),
 		clusterManagerLister:          clusterManagerInformer.Lister(),
 		configMapLister:               configMapInformer.Lister(),
+		rollbackConfigMapLister:       rollbackConfigMapInformer.Lister(),
+		inventoryLister:               inventoryInformer.Lister(),
 		generateHubClusterClients:     generateHubClients,
 		ensureSAKubeconfigs:           ensureSAKubeconfigs,
 		cache:                         resourceapply.NewResourceCache(),
+		fieldUsageCache:               crdmanager.NewFieldUsageCache(),
 		skipRemoveCRDs:                skipRemoveCRDs,
 		controlPlaneNodeLabelSelector: controlPlaneNodeLabelSelector,
 		deploymentReplicas:            deploymentReplicas,
@@ -120,7 +131,12 @@ func NewClusterManagerController(
 			helpers.ClusterManagerQueueKeyFunc(controller.clusterManagerLister),
 			queue.FilterByNames(helpers.CaBundleConfigmap),
 			configMapInformer.Informer()).
+		WithFilteredEventsInformersQueueKeysFunc(
+			helpers.ClusterManagerQueueKeyFunc(controller.clusterManagerLister),
+			queue.FilterByNames(commonhelper.AgentVersionInventoryConfigMap),
+			inventoryInformer.Informer()).
 		WithInformersQueueKeysFunc(queue.QueueKeyByMetaName, clusterManagerInformer.Informer()).
+		WithBareInformers(rollbackConfigMapInformer.Informer()).
 		ToController("ClusterManagerController")
 }
 
@@ -146,94 +162,11 @@ func (n *clusterManagerController) sync(ctx context.Context, controllerContext f
 		return err
 	}
 
-	// default driver is kube
-	workDriver := operatorapiv1.WorkDriverTypeKube
-	if clusterManager.Spec.WorkConfiguration != nil && clusterManager.Spec.WorkConfiguration.WorkDriver != "" {
-		workDriver = clusterManager.Spec.WorkConfiguration.WorkDriver
-	}
-
 	replica := n.deploymentReplicas
 	if replica <= 0 {
 		replica = helpers.DetermineReplica(ctx, n.operatorKubeClient, clusterManager.Spec.DeployOption.Mode, n.controlPlaneNodeLabelSelector)
 	}
 
-	// This config is used to render template of manifests.
-	registrationWebhook, workWebhook := webhookConfigurations(clusterManager.Spec.DeployOption)
-	config := manifests.HubConfig{
-		ClusterManagerName:      clusterManager.Name,
-		ClusterManagerNamespace: clusterManagerNamespace,
-		OperatorNamespace:       n.operatorNamespace,
-		RegistrationImage:       clusterManager.Spec.RegistrationImagePullSpec,
-		WorkImage:               clusterManager.Spec.WorkImagePullSpec,
-		PlacementImage:          clusterManager.Spec.PlacementImagePullSpec,
-		AddOnManagerImage:       clusterManager.Spec.AddOnManagerImagePullSpec,
-		Replica:                 replica,
-		HostedMode:              clusterManager.Spec.DeployOption.Mode == operatorapiv1.InstallModeHosted,
-		RegistrationWebhook:     registrationWebhook,
-		WorkWebhook:             workWebhook,
-		AddonWebhook: manifests.Webhook{
-			Port: defaultWebhookPort,
-		},
-		ResourceRequirementResourceType: helpers.ResourceType(clusterManager),
-		ResourceRequirements:            resourceRequirements,
-		WorkDriver:                      string(workDriver),
-	}
-
-	var registrationFeatureMsgs, workFeatureMsgs, addonFeatureMsgs string
-	// If there are some invalid feature gates of registration or work, will output
-	// condition `ValidFeatureGates` False in ClusterManager.
-	var registrationFeatureGates []operatorapiv1.FeatureGate
-	if clusterManager.Spec.RegistrationConfiguration != nil {
-		registrationFeatureGates = clusterManager.Spec.RegistrationConfiguration.FeatureGates
-		config.AutoApproveUsers = strings.Join(clusterManager.Spec.RegistrationConfiguration.AutoApproveUsers, ",")
-	}
-	config.RegistrationFeatureGates, registrationFeatureMsgs = helpers.ConvertToFeatureGateFlags("Registration",
-		registrationFeatureGates, ocmfeature.DefaultHubRegistrationFeatureGates)
-	config.ClusterProfileEnabled = helpers.FeatureGateEnabled(registrationFeatureGates, ocmfeature.DefaultHubRegistrationFeatureGates, ocmfeature.ClusterProfile)
-	// setting for cluster importer.
-	// TODO(qiujian16) since this is disabled by feature gate, the image is obtained from cluster manager's env var. Need a more elegant approach.
-	config.ClusterImporterEnabled = helpers.FeatureGateEnabled(registrationFeatureGates, ocmfeature.DefaultHubRegistrationFeatureGates, ocmfeature.ClusterImporter)
-	if config.ClusterImporterEnabled {
-		config.AgentImage = os.Getenv("AGENT_IMAGE")
-		if clusterManager.Spec.RegistrationConfiguration != nil && clusterManager.Spec.RegistrationConfiguration.ImporterConfiguration != nil {
-			config.ImporterRenderers = strings.Join(clusterManager.Spec.RegistrationConfiguration.ImporterConfiguration.Renderers, ",")
-		}
-	}
-
-	var workFeatureGates []operatorapiv1.FeatureGate
-	if clusterManager.Spec.WorkConfiguration != nil {
-		workFeatureGates = clusterManager.Spec.WorkConfiguration.FeatureGates
-	}
-	config.WorkFeatureGates, workFeatureMsgs = helpers.ConvertToFeatureGateFlags("Work", workFeatureGates, ocmfeature.DefaultHubWorkFeatureGates)
-	// start work controller if ManifestWorkReplicaSet or CleanUpCompletedManifestWork is enabled
-	config.WorkControllerEnabled = helpers.FeatureGateEnabled(workFeatureGates, ocmfeature.DefaultHubWorkFeatureGates, ocmfeature.ManifestWorkReplicaSet) ||
-		helpers.FeatureGateEnabled(workFeatureGates, ocmfeature.DefaultHubWorkFeatureGates, ocmfeature.CleanUpCompletedManifestWork)
-	config.CloudEventsDriverEnabled = helpers.FeatureGateEnabled(workFeatureGates, ocmfeature.DefaultHubWorkFeatureGates, ocmfeature.CloudEventsDrivers)
-
-	var addonFeatureGates []operatorapiv1.FeatureGate
-	if clusterManager.Spec.AddOnManagerConfiguration != nil {
-		addonFeatureGates = clusterManager.Spec.AddOnManagerConfiguration.FeatureGates
-	}
-	_, addonFeatureMsgs = helpers.ConvertToFeatureGateFlags("Addon", addonFeatureGates, ocmfeature.DefaultHubAddonManagerFeatureGates)
-	featureGateCondition := helpers.BuildFeatureCondition(registrationFeatureMsgs, workFeatureMsgs, addonFeatureMsgs)
-
-	// Check if addon management is enabled by the feature gate
-	config.AddOnManagerEnabled = helpers.FeatureGateEnabled(addonFeatureGates, ocmfeature.DefaultHubAddonManagerFeatureGates, ocmfeature.AddonManagement)
-
-	// Compute and populate the value of managed cluster identity creator role to be used in cluster manager registration service account
-	config.ManagedClusterIdentityCreatorRole = getIdentityCreatorRoleAndTags(*clusterManager)
-
-	config.Labels = helpers.GetClusterManagerHubLabels(clusterManager, n.enableSyncLabels)
-	config.LabelsString = helpers.GetRegistrationLabelString(config.Labels)
-
-	// Determine if the gRPC auth is enabled
-	config.GRPCAuthEnabled = helpers.GRPCAuthEnabled(clusterManager)
-
-	// Get gRPC endpoint type
-	if config.GRPCAuthEnabled {
-		config.GRPCEndpointType = helpers.GRPCServerEndpointType(clusterManager)
-	}
-
 	// Update finalizer at first
 	if clusterManager.DeletionTimestamp.IsZero() {
 		updated, err := n.patcher.AddFinalizer(ctx, clusterManager, clusterManagerFinalizer)
@@ -253,15 +186,42 @@ func (n *clusterManagerController) sync(ctx context.Context, controllerContext f
 	}
 	managementClient := n.operatorKubeClient // We assume that operator is always running on the management cluster.
 
+	// refuse to enable the features that the agents of the fleet cannot support yet, the admitted features are
+	// recorded in the annotation before the components are updated.
+	admission := &featureAdmission{admitted: enabledFeatures(clusterManager)}
+	if clusterManager.DeletionTimestamp.IsZero() {
+		getInventory := listerInventoryGetter(n.inventoryLister, clusterManagerNamespace)
+		if clusterManagerMode == operatorapiv1.InstallModeHosted {
+			getInventory = hubInventoryGetter(ctx, hubClient, clusterManagerNamespace)
+		}
+		admission, err = admitFeatures(getInventory, clusterManager)
+		if err != nil {
+			return err
+		}
+	}
+	if admission.inventoryFound {
+		newObjectMeta := clusterManager.ObjectMeta.DeepCopy()
+		if newObjectMeta.Annotations == nil {
+			newObjectMeta.Annotations = map[string]string{}
+		}
+		newObjectMeta.Annotations[admittedFeaturesAnnotationKey] = admission.annotation()
+		if updated, err := n.patcher.PatchLabelAnnotations(ctx, clusterManager, *newObjectMeta, clusterManager.ObjectMeta); updated {
+			return err
+		}
+	}
+
+	config, featureGateCondition := newHubConfig(clusterManager, n.operatorNamespace, replica, resourceRequirements,
+		n.enableSyncLabels, admission)
+
 	var errs []error
 	reconcilers := []clusterManagerReconcile{
-		&crdReconcile{cache: n.cache, recorder: controllerContext.Recorder(), hubAPIExtensionClient: hubApiExtensionClient,
-			hubMigrationClient: hubMigrationClient, skipRemoveCRDs: n.skipRemoveCRDs},
+		&crdReconcile{cache: n.cache, fieldUsageCache: n.fieldUsageCache, recorder: controllerContext.Recorder(),
+			hubAPIExtensionClient: hubApiExtensionClient, hubMigrationClient: hubMigrationClient, skipRemoveCRDs: n.skipRemoveCRDs},
 		&secretReconcile{cache: n.cache, recorder: controllerContext.Recorder(), operatorKubeClient: n.operatorKubeClient,
 			hubKubeClient: hubClient, operatorNamespace: n.operatorNamespace, enableSyncLabels: n.enableSyncLabels},
 		&hubReconcile{cache: n.cache, recorder: controllerContext.Recorder(), hubKubeClient: hubClient},
 		&runtimeReconcile{cache: n.cache, recorder: controllerContext.Recorder(), hubKubeConfig: hubKubeConfig, hubKubeClient: hubClient,
-			kubeClient: managementClient, ensureSAKubeconfigs: n.ensureSAKubeconfigs},
+			kubeClient: managementClient, ensureSAKubeconfigs: n.ensureSAKubeconfigs, rollbackConfigMapLister: n.rollbackConfigMapLister},
 		&webhookReconcile{cache: n.cache, recorder: controllerContext.Recorder(), hubKubeClient: hubClient, kubeClient: managementClient},
 	}
 
@@ -333,6 +293,11 @@ func (n *clusterManagerController) sync(ctx context.Context, controllerContext f
 
 	// Update status
 	meta.SetStatusCondition(&clusterManager.Status.Conditions, featureGateCondition)
+	if admissionCondition := admission.condition(); admissionCondition != nil {
+		meta.SetStatusCondition(&clusterManager.Status.Conditions, *admissionCondition)
+	} else {
+		meta.RemoveStatusCondition(&clusterManager.Status.Conditions, helpers.FeatureGatesTypeAdmitted)
+	}
 	clusterManager.Status.ObservedGeneration = clusterManager.Generation
 	if len(errs) == 0 {
 		meta.SetStatusCondition(&clusterManager.Status.Conditions, metav1.Condition{
@@ -355,6 +320,105 @@ func (n *clusterManagerController) sync(ctx context.Context, controllerContext f
 	return utilerrors.NewAggregate(errs)
 }
 
+// newHubConfig returns the config to render the manifests of the cluster manager with the admitted features, and the
+// condition of the feature gates.
+func newHubConfig(clusterManager *operatorapiv1.ClusterManager, operatorNamespace string, replica int32,
+	resourceRequirements []byte, enableSyncLabels bool, admission *featureAdmission) (manifests.HubConfig, metav1.Condition) {
+	clusterManagerNamespace := helpers.ClusterManagerNamespace(clusterManager.Name, clusterManager.Spec.DeployOption.Mode)
+
+	// default driver is kube
+	workDriver := operatorapiv1.WorkDriverTypeKube
+	if clusterManager.Spec.WorkConfiguration != nil && clusterManager.Spec.WorkConfiguration.WorkDriver != "" {
+		workDriver = clusterManager.Spec.WorkConfiguration.WorkDriver
+	}
+
+	// This config is used to render template of manifests.
+	registrationWebhook, workWebhook := webhookConfigurations(clusterManager.Spec.DeployOption)
+	config := manifests.HubConfig{
+		ClusterManagerName:      clusterManager.Name,
+		ClusterManagerNamespace: clusterManagerNamespace,
+		OperatorNamespace:       operatorNamespace,
+		RegistrationImage:       clusterManager.Spec.RegistrationImagePullSpec,
+		WorkImage:               clusterManager.Spec.WorkImagePullSpec,
+		PlacementImage:          clusterManager.Spec.PlacementImagePullSpec,
+		AddOnManagerImage:       clusterManager.Spec.AddOnManagerImagePullSpec,
+		Replica:                 replica,
+		HostedMode:              clusterManager.Spec.DeployOption.Mode == operatorapiv1.InstallModeHosted,
+		RegistrationWebhook:     registrationWebhook,
+		WorkWebhook:             workWebhook,
+		AddonWebhook: manifests.Webhook{
+			Port: defaultWebhookPort,
+		},
+		ResourceRequirementResourceType: helpers.ResourceType(clusterManager),
+		ResourceRequirements:            resourceRequirements,
+		WorkDriver:                      string(workDriver),
+	}
+
+	// Compute and populate the value of managed cluster identity creator role to be used in cluster manager registration service account
+	config.ManagedClusterIdentityCreatorRole = getIdentityCreatorRoleAndTags(*clusterManager)
+
+	config.Labels = helpers.GetClusterManagerHubLabels(clusterManager, enableSyncLabels)
+	config.LabelsString = helpers.GetRegistrationLabelString(config.Labels)
+
+	// Determine if the gRPC auth is enabled
+	config.GRPCAuthEnabled = helpers.GRPCAuthEnabled(clusterManager)
+
+	// Get gRPC endpoint type
+	if config.GRPCAuthEnabled {
+		config.GRPCEndpointType = helpers.GRPCServerEndpointType(clusterManager)
+	}
+
+	config.EnabledHubFeatures = strings.Join(sets.List(admission.admitted), ",")
+
+	var registrationFeatureMsgs, workFeatureMsgs, addonFeatureMsgs string
+	// If there are some invalid feature gates of registration or work, will output
+	// condition `ValidFeatureGates` False in ClusterManager.
+	var registrationFeatureGates []operatorapiv1.FeatureGate
+	if clusterManager.Spec.RegistrationConfiguration != nil {
+		registrationFeatureGates = clusterManager.Spec.RegistrationConfiguration.FeatureGates
+		config.AutoApproveUsers = strings.Join(clusterManager.Spec.RegistrationConfiguration.AutoApproveUsers, ",")
+	}
+	registrationFeatureGates = admission.featureGates(registrationFeatureGates, features.DefaultHubRegistrationFeatureGates)
+	config.RegistrationFeatureGates, registrationFeatureMsgs = helpers.ConvertToFeatureGateFlags("Registration",
+		registrationFeatureGates, features.DefaultHubRegistrationFeatureGates)
+	config.ClusterProfileEnabled = helpers.FeatureGateEnabled(registrationFeatureGates, features.DefaultHubRegistrationFeatureGates, ocmfeature.ClusterProfile)
+	config.ClientCertificateRevocationEnabled = helpers.FeatureGateEnabled(registrationFeatureGates,
+		features.DefaultHubRegistrationFeatureGates, features.ClientCertificateRevocation)
+	// setting for cluster importer.
+	// TODO(qiujian16) since this is disabled by feature gate, the image is obtained from cluster manager's env var. Need a more elegant approach.
+	config.ClusterImporterEnabled = helpers.FeatureGateEnabled(registrationFeatureGates, features.DefaultHubRegistrationFeatureGates, ocmfeature.ClusterImporter)
+	if config.ClusterImporterEnabled {
+		config.AgentImage = os.Getenv("AGENT_IMAGE")
+		if clusterManager.Spec.RegistrationConfiguration != nil && clusterManager.Spec.RegistrationConfiguration.ImporterConfiguration != nil {
+			config.ImporterRenderers = strings.Join(clusterManager.Spec.RegistrationConfiguration.ImporterConfiguration.Renderers, ",")
+		}
+	}
+
+	var workFeatureGates []operatorapiv1.FeatureGate
+	if clusterManager.Spec.WorkConfiguration != nil {
+		workFeatureGates = clusterManager.Spec.WorkConfiguration.FeatureGates
+	}
+	workFeatureGates = admission.featureGates(workFeatureGates, ocmfeature.DefaultHubWorkFeatureGates)
+	config.WorkFeatureGates, workFeatureMsgs = helpers.ConvertToFeatureGateFlags("Work", workFeatureGates, ocmfeature.DefaultHubWorkFeatureGates)
+	// start work controller if ManifestWorkReplicaSet or CleanUpCompletedManifestWork is enabled
+	config.WorkControllerEnabled = helpers.FeatureGateEnabled(workFeatureGates, ocmfeature.DefaultHubWorkFeatureGates, ocmfeature.ManifestWorkReplicaSet) ||
+		helpers.FeatureGateEnabled(workFeatureGates, ocmfeature.DefaultHubWorkFeatureGates, ocmfeature.CleanUpCompletedManifestWork)
+	config.CloudEventsDriverEnabled = helpers.FeatureGateEnabled(workFeatureGates, ocmfeature.DefaultHubWorkFeatureGates, ocmfeature.CloudEventsDrivers)
+
+	var addonFeatureGates []operatorapiv1.FeatureGate
+	if clusterManager.Spec.AddOnManagerConfiguration != nil {
+		addonFeatureGates = clusterManager.Spec.AddOnManagerConfiguration.FeatureGates
+	}
+	addonFeatureGates = admission.featureGates(addonFeatureGates, ocmfeature.DefaultHubAddonManagerFeatureGates)
+	_, addonFeatureMsgs = helpers.ConvertToFeatureGateFlags("Addon", addonFeatureGates, ocmfeature.DefaultHubAddonManagerFeatureGates)
+	featureGateCondition := helpers.BuildFeatureCondition(registrationFeatureMsgs, workFeatureMsgs, addonFeatureMsgs)
+
+	// Check if addon management is enabled by the feature gate
+	config.AddOnManagerEnabled = helpers.FeatureGateEnabled(addonFeatureGates, ocmfeature.DefaultHubAddonManagerFeatureGates, ocmfeature.AddonManagement)
+
+	return config, featureGateCondition
+}
+
 func generateHubClients(hubKubeConfig *rest.Config) (kubernetes.Interface, apiextensionsclient.Interface,
 	migrationclient.StorageVersionMigrationsGetter, error) {
 	hubClient, err := kubernetes.NewForConfig(hubKubeConfig)
@@ -109,6 +109,7 @@ func newTestControllerWithoutCaBundle(t *testing.T, clustermanager *operatorapiv
 			fakeOperatorClient.OperatorV1().ClusterManagers()),
 		clusterManagerLister: operatorInformers.Operator().V1().ClusterManagers().Lister(),
 		configMapLister:      kubeInfomers.Core().V1().ConfigMaps().Lister(),
+		inventoryLister:      kubeInfomers.Core().V1().ConfigMaps().Lister(),
 		cache:                resourceapply.NewResourceCache(),
 	}
 
@@ -141,6 +142,7 @@ func newTestController(t *testing.T, clustermanager *operatorapiv1.ClusterManage
 			fakeOperatorClient.OperatorV1().ClusterManagers()),
 		clusterManagerLister: operatorInformers.Operator().V1().ClusterManagers().Lister(),
 		configMapLister:      kubeInfomers.Core().V1().ConfigMaps().Lister(),
+		inventoryLister:      kubeInfomers.Core().V1().ConfigMaps().Lister(),
 		cache:                resourceapply.NewResourceCache(),
 	}
 
@@ -391,7 +393,10 @@ func ensureObject(t *testing.T, object runtime.Object, hubCore *operatorapiv1.Cl
 
 	switch o := object.(type) { //nolint:gocritic
 	case *corev1.Namespace:
-		testingcommon.AssertEqualNameNamespace(t, access.GetName(), "", helpers.ClusterManagerNamespace(hubCore.Name, hubCore.Spec.DeployOption.Mode), "")
+		clusterManagerNamespace := helpers.ClusterManagerNamespace(hubCore.Name, hubCore.Spec.DeployOption.Mode)
+		if access.GetName() != clusterManagerNamespace+"-addon-charts" {
+			testingcommon.AssertEqualNameNamespace(t, access.GetName(), "", clusterManagerNamespace, "")
+		}
 	case *appsv1.Deployment:
 		if strings.Contains(o.Name, "registration") && hubCore.Spec.RegistrationImagePullSpec != o.Spec.Template.Spec.Containers[0].Image {
 			t.Errorf("Registration image does not match to the expected.")
@@ -485,7 +490,9 @@ func assertDeletion(t *testing.T, clusterManager *operatorapiv1.ClusterManager,
 	for _, action := range deleteKubeActions {
 		switch action.Resource.Resource { //nolint:gocritic
 		case "namespaces":
-			testingcommon.AssertEqualNameNamespace(t, action.Name, "", clusterManagerNamespace, "")
+			if action.Name != clusterManagerNamespace+"-addon-charts" {
+				testingcommon.AssertEqualNameNamespace(t, action.Name, "", clusterManagerNamespace, "")
+			}
 		}
 	}
 }
@@ -622,7 +629,7 @@ func TestSyncDeploy(t *testing.T) {
 		"open-cluster-management.io/cluster-name": "test"}
 	clusterManager := newClusterManager("testhub")
 	clusterManager.SetLabels(labels)
-	assertDeployments(t, clusterManager, 30, 12)
+	assertDeployments(t, clusterManager, 36, 12)
 }
 
 func TestSyncDeployWithGRPCAuthEnabled(t *testing.T) {
@@ -640,7 +647,7 @@ func TestSyncDeployWithGRPCAuthEnabled(t *testing.T) {
 			},
 		},
 	}
-	assertDeployments(t, clusterManager, 34, 12)
+	assertDeployments(t, clusterManager, 40, 12)
 }
 
 func TestSyncDeployNoWebhook(t *testing.T) {
@@ -666,7 +673,7 @@ func TestSyncDeployNoWebhook(t *testing.T) {
 
 	// Check if resources are created as expected
 	// We expect create the namespace twice respectively in the management cluster and the hub cluster.
-	testingcommon.AssertEqualNumber(t, len(createKubeObjects), 33)
+	testingcommon.AssertEqualNumber(t, len(createKubeObjects), 38)
 	for _, object := range createKubeObjects {
 		ensureObject(t, object, clusterManager, false)
 	}
@@ -713,7 +720,7 @@ func TestSyncDelete(t *testing.T) {
 	now := metav1.Now()
 	clusterManager.ObjectMeta.SetDeletionTimestamp(&now)
 
-	assertDeletion(t, clusterManager, 32, 16)
+	assertDeletion(t, clusterManager, 39, 16)
 }
 
 func TestSyncDeleteWithGRPCAuthEnabled(t *testing.T) {
@@ -730,7 +737,7 @@ func TestSyncDeleteWithGRPCAuthEnabled(t *testing.T) {
 	}
 	now := metav1.Now()
 	clusterManager.ObjectMeta.SetDeletionTimestamp(&now)
-	assertDeletion(t, clusterManager, 36, 16)
+	assertDeletion(t, clusterManager, 43, 16)
 }
 
 // TestDeleteCRD test delete crds
@@ -6,6 +6,7 @@ package clustermanagercontroller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/openshift/library-go/pkg/assets"
@@ -62,8 +63,9 @@ type crdReconcile struct {
 	hubMigrationClient    migrationclient.StorageVersionMigrationsGetter
 	skipRemoveCRDs        bool
 
-	cache    resourceapply.ResourceCache
-	recorder events.Recorder
+	cache           resourceapply.ResourceCache
+	fieldUsageCache *crdmanager.FieldUsageCache
+	recorder        events.Recorder
 }
 
 func (c *crdReconcile) reconcile(ctx context.Context, cm *operatorapiv1.ClusterManager,
@@ -71,7 +73,9 @@ func (c *crdReconcile) reconcile(ctx context.Context, cm *operatorapiv1.ClusterM
 	crdManager := crdmanager.NewManager[*apiextensionsv1.CustomResourceDefinition](
 		c.hubAPIExtensionClient.ApiextensionsV1().CustomResourceDefinitions(),
 		crdmanager.EqualV1,
-	)
+	).WithObjectLister(crdmanager.NewRESTObjectLister(c.hubAPIExtensionClient.ApiextensionsV1().RESTClient())).
+		WithFieldUsageCache(c.fieldUsageCache).
+		WithMigrationChecker(crdmanager.NewStorageVersionMigrationChecker(c.hubMigrationClient))
 
 	// CRD resource files to deploy
 	hubDeployCRDResources := hubCRDResourceFiles
@@ -100,10 +104,15 @@ func (c *crdReconcile) reconcile(ctx context.Context, cm *operatorapiv1.ClusterM
 			return objData, nil
 		},
 		hubDeployCRDResources...); err != nil {
+		reason := operatorapiv1.ReasonClusterManagerCRDApplyFailed
+		// the stored objects must be migrated before the crd is updated.
+		if errors.Is(err, crdmanager.ErrUnsafeUpdate) {
+			reason = crdmanager.ReasonCRDUpdateBlocked
+		}
 		meta.SetStatusCondition(&cm.Status.Conditions, metav1.Condition{
 			Type:    operatorapiv1.ConditionClusterManagerApplied,
 			Status:  metav1.ConditionFalse,
-			Reason:  operatorapiv1.ReasonClusterManagerCRDApplyFailed,
+			Reason:  reason,
 			Message: fmt.Sprintf("Failed to apply crd: %v", err),
 		})
 		return cm, reconcileStop, err
@@ -0,0 +1,423 @@
+package clustermanagercontroller
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	operatorapiv1 "open-cluster-management.io/api/operator/v1"
+)
+
+// deployProfileAnnotationKey is the annotation of the ClusterManager to customize how the hub components are deployed
+// for high availability, the value is a deployProfile in json.
+const deployProfileAnnotationKey = "operator.open-cluster-management.io/deploy-profile"
+
+// reasonInvalidDeployProfile is the reason of the Applied condition when the deploy profile annotation is invalid.
+const reasonInvalidDeployProfile = "InvalidDeployProfile"
+
+// the default leader election durations of the hub controllers, see pkg/common/options.
+var (
+	defaultLeaseDuration = 137 * time.Second
+	defaultRenewDeadline = 107 * time.Second
+	defaultRetryPeriod   = 26 * time.Second
+)
+
+// deployComponent is a hub component deployed by a deployment manifest.
+type deployComponent struct {
+	name string
+	// leaderElection is true if the component is a controller running with leader election.
+	leaderElection bool
+}
+
+var deployComponents = map[string]deployComponent{
+	"cluster-manager/management/registration/deployment.yaml":          {name: "registration", leaderElection: true},
+	"cluster-manager/management/registration/webhook-deployment.yaml":  {name: "registration-webhook"},
+	"cluster-manager/management/placement/deployment.yaml":             {name: "placement", leaderElection: true},
+	"cluster-manager/management/work/webhook-deployment.yaml":          {name: "work-webhook"},
+	"cluster-manager/management/work/deployment.yaml":                  {name: "work", leaderElection: true},
+	"cluster-manager/management/addon-manager/webhook-deployment.yaml": {name: "addon-webhook"},
+	"cluster-manager/management/addon-manager/deployment.yaml":         {name: "addon-manager", leaderElection: true},
+	"cluster-manager/management/grpc-server/deployment.yaml":           {name: "grpc-server", leaderElection: true},
+}
+
+// deployComponentNames returns the sorted names of the hub components.
+func deployComponentNames() []string {
+	names := make([]string, 0, len(deployComponents))
+	for _, component := range deployComponents {
+		names = append(names, component.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// deployProfile customizes the deployments of the hub components. The default profile applies to all components,
+// and the fields set in the profile of a component override the default ones.
+type deployProfile struct {
+	Default    componentProfile            `json:"default,omitempty"`
+	Components map[string]componentProfile `json:"components,omitempty"`
+}
+
+type componentProfile struct {
+	// Replicas overrides the replicas determined by the operator.
+	Replicas *int32 `json:"replicas,omitempty"`
+	// PodDisruptionBudget creates a PodDisruptionBudget for the pods of the component.
+	PodDisruptionBudget *podDisruptionBudgetProfile `json:"podDisruptionBudget,omitempty"`
+	// TopologySpreadConstraints of the pods, the label selector defaults to the selector of the deployment.
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+	// LeaderElection tunes the leader election of the controllers, it is not supported by the webhooks.
+	LeaderElection *leaderElectionProfile `json:"leaderElection,omitempty"`
+	// PriorityClassName of the pods.
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// Rollback opts in to rolling back the deployment to the last known-good spec if a rollout does not become
+	// available within the deadline.
+	Rollback *rollbackProfile `json:"rollback,omitempty"`
+}
+
+type podDisruptionBudgetProfile struct {
+	MinAvailable   *intstr.IntOrString `json:"minAvailable,omitempty"`
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+type rollbackProfile struct {
+	// ProgressDeadline is the progressDeadlineSeconds of the deployment, the rollout is regarded as failed once the
+	// deployment reports ProgressDeadlineExceeded.
+	ProgressDeadline metav1.Duration `json:"progressDeadline"`
+}
+
+type leaderElectionProfile struct {
+	LeaseDuration *metav1.Duration `json:"leaseDuration,omitempty"`
+	RenewDeadline *metav1.Duration `json:"renewDeadline,omitempty"`
+	RetryPeriod   *metav1.Duration `json:"retryPeriod,omitempty"`
+}
+
+// parseDeployProfile returns the validated deploy profile of the cluster manager, or an empty profile if the
+// annotation is not set.
+func parseDeployProfile(clusterManager *operatorapiv1.ClusterManager) (*deployProfile, error) {
+	profile := &deployProfile{}
+	value, ok := clusterManager.Annotations[deployProfileAnnotationKey]
+	if !ok {
+		return profile, nil
+	}
+	if err := json.Unmarshal([]byte(value), profile); err != nil {
+		return nil, fmt.Errorf("invalid deploy profile annotation %s: %w", deployProfileAnnotationKey, err)
+	}
+	if err := profile.validate(); err != nil {
+		return nil, fmt.Errorf("invalid deploy profile annotation %s: %w", deployProfileAnnotationKey, err)
+	}
+	return profile, nil
+}
+
+func (p *deployProfile) validate() error {
+	leaderElection := map[string]bool{}
+	for _, component := range deployComponents {
+		leaderElection[component.name] = component.leaderElection
+	}
+
+	var errs []error
+	if err := p.Default.validate("default"); err != nil {
+		errs = append(errs, err)
+	}
+	names := make([]string, 0, len(p.Components))
+	for name := range p.Components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		supportLeaderElection, ok := leaderElection[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown component %q", name))
+			continue
+		}
+		// the leader election of the default profile only applies to the controllers.
+		if p.Components[name].LeaderElection != nil && !supportLeaderElection {
+			errs = append(errs, fmt.Errorf("%s: leaderElection is not supported", name))
+		}
+		if err := p.merged(name).validate(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (c componentProfile) validate(name string) error {
+	var errs []error
+	if c.Replicas != nil && *c.Replicas < 1 {
+		errs = append(errs, fmt.Errorf("%s: replicas must be at least 1", name))
+	}
+	if pdb := c.PodDisruptionBudget; pdb != nil && (pdb.MinAvailable == nil) == (pdb.MaxUnavailable == nil) {
+		errs = append(errs, fmt.Errorf("%s: exactly one of minAvailable and maxUnavailable of podDisruptionBudget must be set", name))
+	}
+	for i, constraint := range c.TopologySpreadConstraints {
+		if constraint.MaxSkew < 1 || len(constraint.TopologyKey) == 0 || len(constraint.WhenUnsatisfiable) == 0 {
+			errs = append(errs, fmt.Errorf(
+				"%s: topologySpreadConstraints[%d] must set maxSkew, topologyKey and whenUnsatisfiable", name, i))
+		}
+	}
+	if c.Rollback != nil && c.Rollback.ProgressDeadline.Duration < time.Second {
+		errs = append(errs, fmt.Errorf("%s: progressDeadline of rollback must be at least 1s", name))
+	}
+	if le := c.LeaderElection; le != nil {
+		lease, renew, retry := le.durations()
+		if retry <= 0 || renew <= retry || lease <= renew {
+			errs = append(errs, fmt.Errorf(
+				"%s: leaderElection must satisfy leaseDuration > renewDeadline > retryPeriod > 0, but got %v, %v, %v",
+				name, lease, renew, retry))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// durations returns the leader election durations with the defaults of the hub controllers.
+func (l *leaderElectionProfile) durations() (lease, renew, retry time.Duration) {
+	lease, renew, retry = defaultLeaseDuration, defaultRenewDeadline, defaultRetryPeriod
+	if l.LeaseDuration != nil {
+		lease = l.LeaseDuration.Duration
+	}
+	if l.RenewDeadline != nil {
+		renew = l.RenewDeadline.Duration
+	}
+	if l.RetryPeriod != nil {
+		retry = l.RetryPeriod.Duration
+	}
+	return lease, renew, retry
+}
+
+// merged returns the profile of the component overriding the default profile.
+func (p *deployProfile) merged(name string) componentProfile {
+	merged := p.Default
+	component, ok := p.Components[name]
+	if !ok {
+		return merged
+	}
+	if component.Replicas != nil {
+		merged.Replicas = component.Replicas
+	}
+	if component.PodDisruptionBudget != nil {
+		merged.PodDisruptionBudget = component.PodDisruptionBudget
+	}
+	if component.TopologySpreadConstraints != nil {
+		merged.TopologySpreadConstraints = component.TopologySpreadConstraints
+	}
+	if component.LeaderElection != nil {
+		merged.LeaderElection = component.LeaderElection
+	}
+	if len(component.PriorityClassName) > 0 {
+		merged.PriorityClassName = component.PriorityClassName
+	}
+	if component.Rollback != nil {
+		merged.Rollback = component.Rollback
+	}
+	return merged
+}
+
+// component returns the profile of the component deployed by the deployment file, and whether the component runs
+// with leader election.
+func (p *deployProfile) component(file string) (componentProfile, bool) {
+	component, ok := deployComponents[file]
+	if !ok {
+		return componentProfile{}, false
+	}
+	return p.merged(component.name), component.leaderElection
+}
+
+// rollbackEnabled returns true if any component opts in to the rollback.
+func (p *deployProfile) rollbackEnabled() bool {
+	if p.Default.Rollback != nil {
+		return true
+	}
+	for _, component := range p.Components {
+		if component.Rollback != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// applyDeployProfile sets the deploy profile of the component into the rendered deployment.
+func applyDeployProfile(objData []byte, profile componentProfile, leaderElection bool) ([]byte, error) {
+	u, err := yamlToUnstructured(objData)
+	if err != nil {
+		return nil, err
+	}
+
+	if profile.Replicas != nil {
+		if err := unstructured.SetNestedField(u.Object, int64(*profile.Replicas), "spec", "replicas"); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(profile.PriorityClassName) > 0 {
+		if err := unstructured.SetNestedField(
+			u.Object, profile.PriorityClassName, "spec", "template", "spec", "priorityClassName"); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(profile.TopologySpreadConstraints) > 0 {
+		selector, err := deploymentSelector(u)
+		if err != nil {
+			return nil, err
+		}
+		var constraints []corev1.TopologySpreadConstraint
+		for _, constraint := range profile.TopologySpreadConstraints {
+			constraint := *constraint.DeepCopy()
+			if constraint.LabelSelector == nil {
+				constraint.LabelSelector = selector
+			}
+			constraints = append(constraints, constraint)
+		}
+		podSpec, err := runtime.DefaultUnstructuredConverter.ToUnstructured(
+			&corev1.PodSpec{TopologySpreadConstraints: constraints})
+		if err != nil {
+			return nil, err
+		}
+		if err := unstructured.SetNestedField(u.Object, podSpec["topologySpreadConstraints"],
+			"spec", "template", "spec", "topologySpreadConstraints"); err != nil {
+			return nil, err
+		}
+	}
+
+	if profile.Rollback != nil {
+		if err := unstructured.SetNestedField(u.Object, int64(profile.Rollback.ProgressDeadline.Seconds()),
+			"spec", "progressDeadlineSeconds"); err != nil {
+			return nil, err
+		}
+	}
+
+	if profile.LeaderElection != nil && leaderElection {
+		lease, renew, retry := profile.LeaderElection.durations()
+		if err := setContainerArgs(u, map[string]string{
+			"--leader-election-lease-duration": lease.String(),
+			"--leader-election-renew-deadline": renew.String(),
+			"--leader-election-retry-period":   retry.String(),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return unstructuredToYaml(u)
+}
+
+// podDisruptionBudget returns the PodDisruptionBudget of the rendered deployment, or nil if it is not required by
+// the profile. The PodDisruptionBudget has the same name, namespace, labels and selector as the deployment.
+func podDisruptionBudget(objData []byte, profile componentProfile) (*policyv1.PodDisruptionBudget, error) {
+	if profile.PodDisruptionBudget == nil {
+		return nil, nil
+	}
+	u, err := yamlToUnstructured(objData)
+	if err != nil {
+		return nil, err
+	}
+	selector, err := deploymentSelector(u)
+	if err != nil {
+		return nil, err
+	}
+	return &policyv1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: policyv1.SchemeGroupVersion.String(),
+			Kind:       "PodDisruptionBudget",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      u.GetName(),
+			Namespace: u.GetNamespace(),
+			Labels:    u.GetLabels(),
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MinAvailable:   profile.PodDisruptionBudget.MinAvailable,
+			MaxUnavailable: profile.PodDisruptionBudget.MaxUnavailable,
+			Selector:       selector,
+		},
+	}, nil
+}
+
+// podDisruptionBudgetToYaml returns the manifest of the PodDisruptionBudget without the empty creationTimestamp and
+// status.
+func podDisruptionBudgetToYaml(pdb *policyv1.PodDisruptionBudget) ([]byte, error) {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pdb)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: obj}
+	unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(u.Object, "status")
+	return unstructuredToYaml(u)
+}
+
+func deploymentSelector(u *unstructured.Unstructured) (*metav1.LabelSelector, error) {
+	matchLabels, _, err := unstructured.NestedStringMap(u.Object, "spec", "selector", "matchLabels")
+	if err != nil {
+		return nil, err
+	}
+	return &metav1.LabelSelector{MatchLabels: matchLabels}, nil
+}
+
+// setContainerArgs sets the flags into the args of the first container, the existing values of the flags are
+// replaced.
+func setContainerArgs(u *unstructured.Unstructured, flags map[string]string) error {
+	containers, _, err := unstructured.NestedSlice(u.Object, "spec", "template", "spec", "containers")
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return fmt.Errorf("no container found in deployment %s", u.GetName())
+	}
+	container, ok := containers[0].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid container in deployment %s", u.GetName())
+	}
+	args, _, err := unstructured.NestedStringSlice(container, "args")
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var newArgs []interface{}
+	for _, arg := range args {
+		if _, ok := flags[strings.SplitN(arg, "=", 2)[0]]; ok {
+			continue
+		}
+		newArgs = append(newArgs, arg)
+	}
+	for _, name := range names {
+		newArgs = append(newArgs, fmt.Sprintf("%s=%s", name, flags[name]))
+	}
+	container["args"] = newArgs
+	containers[0] = container
+	return unstructured.SetNestedSlice(u.Object, containers, "spec", "template", "spec", "containers")
+}
+
+func yamlToUnstructured(objData []byte) (*unstructured.Unstructured, error) {
+	jsonData, err := yaml.YAMLToJSON(objData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert YAML to JSON: %w", err)
+	}
+	u := &unstructured.Unstructured{}
+	if err := json.Unmarshal(jsonData, u); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	return u, nil
+}
+
+func unstructuredToYaml(u *unstructured.Unstructured) ([]byte, error) {
+	jsonData, err := json.Marshal(u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal updated object: %w", err)
+	}
+	return yaml.JSONToYAML(jsonData)
+}
@@ -0,0 +1,326 @@
+package clustermanagercontroller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/yaml"
+
+	operatorapiv1 "open-cluster-management.io/api/operator/v1"
+
+	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
+	"open-cluster-management.io/ocm/pkg/operator/helpers"
+)
+
+const testDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: cluster-manager-placement-controller
+  namespace: open-cluster-management-hub
+  labels:
+    app: cluster-manager-placement-controller
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app: clustermanager-placement-controller
+  template:
+    spec:
+      containers:
+      - name: placement-controller
+        args:
+        - "/placement"
+        - "controller"
+        - "--leader-election-lease-duration=10s"
+`
+
+func TestParseDeployProfile(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotation  string
+		expectedErr string
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name: "valid profile",
+			annotation: `{"default":{"replicas":3,"podDisruptionBudget":{"minAvailable":1}},` +
+				`"components":{"registration":{"leaderElection":{"leaseDuration":"60s","renewDeadline":"40s","retryPeriod":"10s"}}}}`,
+		},
+		{
+			name:        "invalid json",
+			annotation:  `{"default":`,
+			expectedErr: "invalid deploy profile annotation",
+		},
+		{
+			name:        "unknown component",
+			annotation:  `{"components":{"registration-controller":{}}}`,
+			expectedErr: `unknown component "registration-controller"`,
+		},
+		{
+			name:        "invalid replicas",
+			annotation:  `{"components":{"work":{"replicas":0}}}`,
+			expectedErr: "work: replicas must be at least 1",
+		},
+		{
+			name:        "leader election of webhook",
+			annotation:  `{"components":{"work-webhook":{"leaderElection":{"leaseDuration":"60s"}}}}`,
+			expectedErr: "work-webhook: leaderElection is not supported",
+		},
+		{
+			name:        "lease duration less than the default renew deadline",
+			annotation:  `{"default":{"leaderElection":{"leaseDuration":"60s"}}}`,
+			expectedErr: "default: leaderElection must satisfy",
+		},
+		{
+			name:        "both minAvailable and maxUnavailable",
+			annotation:  `{"default":{"podDisruptionBudget":{"minAvailable":1,"maxUnavailable":1}}}`,
+			expectedErr: "exactly one of minAvailable and maxUnavailable",
+		},
+		{
+			name:        "invalid rollback deadline",
+			annotation:  `{"components":{"work":{"rollback":{"progressDeadline":"0s"}}}}`,
+			expectedErr: "work: progressDeadline of rollback must be at least 1s",
+		},
+		{
+			name:        "invalid topology spread constraint",
+			annotation:  `{"components":{"placement":{"topologySpreadConstraints":[{"maxSkew":1}]}}}`,
+			expectedErr: "placement: topologySpreadConstraints[0] must set",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			clusterManager := newClusterManager("testhub")
+			if len(c.annotation) > 0 {
+				clusterManager.Annotations = map[string]string{deployProfileAnnotationKey: c.annotation}
+			}
+			_, err := parseDeployProfile(clusterManager)
+			if len(c.expectedErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.expectedErr) {
+				t.Errorf("expected error %q, but got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestComponentProfile(t *testing.T) {
+	profile := &deployProfile{
+		Default: componentProfile{
+			Replicas:          ptr.To[int32](3),
+			PriorityClassName: "default-priority",
+		},
+		Components: map[string]componentProfile{
+			"placement": {Replicas: ptr.To[int32](5)},
+		},
+	}
+
+	component, leaderElection := profile.component("cluster-manager/management/placement/deployment.yaml")
+	if !leaderElection {
+		t.Errorf("expected placement runs with leader election")
+	}
+	if *component.Replicas != 5 || component.PriorityClassName != "default-priority" {
+		t.Errorf("expected the profile of placement overrides the default, but got %v", component)
+	}
+
+	component, leaderElection = profile.component("cluster-manager/management/work/webhook-deployment.yaml")
+	if leaderElection {
+		t.Errorf("expected work webhook runs without leader election")
+	}
+	if *component.Replicas != 3 {
+		t.Errorf("expected the default profile, but got %v", component)
+	}
+}
+
+func TestApplyDeployProfile(t *testing.T) {
+	profile := componentProfile{
+		Replicas:          ptr.To[int32](3),
+		PriorityClassName: "system-cluster-critical",
+		TopologySpreadConstraints: []corev1.TopologySpreadConstraint{
+			{MaxSkew: 1, TopologyKey: "topology.kubernetes.io/zone", WhenUnsatisfiable: corev1.ScheduleAnyway},
+		},
+		LeaderElection: &leaderElectionProfile{
+			LeaseDuration: &metav1.Duration{Duration: time.Minute},
+			RenewDeadline: &metav1.Duration{Duration: 40 * time.Second},
+		},
+		Rollback: &rollbackProfile{ProgressDeadline: metav1.Duration{Duration: 5 * time.Minute}},
+	}
+
+	cases := []struct {
+		name           string
+		leaderElection bool
+		expectedArgs   []string
+	}{
+		{
+			name:           "controller",
+			leaderElection: true,
+			expectedArgs: []string{"/placement", "controller", "--leader-election-lease-duration=1m0s",
+				"--leader-election-renew-deadline=40s", "--leader-election-retry-period=26s"},
+		},
+		{
+			name:         "webhook",
+			expectedArgs: []string{"/placement", "controller", "--leader-election-lease-duration=10s"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			objData, err := applyDeployProfile([]byte(testDeployment), profile, c.leaderElection)
+			if err != nil {
+				t.Fatal(err)
+			}
+			deployment := &appsv1.Deployment{}
+			if err := yaml.Unmarshal(objData, deployment); err != nil {
+				t.Fatal(err)
+			}
+
+			if *deployment.Spec.Replicas != 3 {
+				t.Errorf("expected 3 replicas, but got %d", *deployment.Spec.Replicas)
+			}
+			if *deployment.Spec.ProgressDeadlineSeconds != 300 {
+				t.Errorf("expected the progress deadline is set, but got %d", *deployment.Spec.ProgressDeadlineSeconds)
+			}
+			podSpec := deployment.Spec.Template.Spec
+			if podSpec.PriorityClassName != "system-cluster-critical" {
+				t.Errorf("expected the priority class is set, but got %q", podSpec.PriorityClassName)
+			}
+			expectedSelector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "clustermanager-placement-controller"}}
+			if len(podSpec.TopologySpreadConstraints) != 1 ||
+				!reflect.DeepEqual(podSpec.TopologySpreadConstraints[0].LabelSelector, expectedSelector) {
+				t.Errorf("expected the topology spread constraint selects the pods of the deployment, but got %v",
+					podSpec.TopologySpreadConstraints)
+			}
+			if !reflect.DeepEqual(podSpec.Containers[0].Args, c.expectedArgs) {
+				t.Errorf("expected args %v, but got %v", c.expectedArgs, podSpec.Containers[0].Args)
+			}
+		})
+	}
+}
+
+func TestPodDisruptionBudget(t *testing.T) {
+	pdb, err := podDisruptionBudget([]byte(testDeployment), componentProfile{})
+	if err != nil || pdb != nil {
+		t.Errorf("expected no PodDisruptionBudget, but got %v, %v", pdb, err)
+	}
+
+	minAvailable := intstr.FromString("50%")
+	pdb, err = podDisruptionBudget([]byte(testDeployment), componentProfile{
+		PodDisruptionBudget: &podDisruptionBudgetProfile{MinAvailable: &minAvailable},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pdb.Name != "cluster-manager-placement-controller" || pdb.Namespace != "open-cluster-management-hub" {
+		t.Errorf("expected the PodDisruptionBudget is named after the deployment, but got %s/%s", pdb.Namespace, pdb.Name)
+	}
+	expectedSpec := policyv1.PodDisruptionBudgetSpec{
+		MinAvailable: &minAvailable,
+		Selector:     &metav1.LabelSelector{MatchLabels: map[string]string{"app": "clustermanager-placement-controller"}},
+	}
+	if !reflect.DeepEqual(pdb.Spec, expectedSpec) {
+		t.Errorf("expected spec %v, but got %v", expectedSpec, pdb.Spec)
+	}
+}
+
+func TestSyncDeployProfile(t *testing.T) {
+	cases := []struct {
+		name                 string
+		annotation           string
+		existingPDB          bool
+		expectErr            bool
+		expectedPDBs         []string
+		expectedDeletedPDBs  []string
+		expectedAppliedState metav1.ConditionStatus
+	}{
+		{
+			name:                 "create PodDisruptionBudget",
+			annotation:           `{"components":{"registration":{"podDisruptionBudget":{"maxUnavailable":1}}}}`,
+			expectedPDBs:         []string{"testhub-registration-controller"},
+			expectedAppliedState: metav1.ConditionTrue,
+		},
+		{
+			name:                 "delete PodDisruptionBudget removed from the profile",
+			existingPDB:          true,
+			expectedDeletedPDBs:  []string{"testhub-registration-controller"},
+			expectedAppliedState: metav1.ConditionTrue,
+		},
+		{
+			name:                 "invalid profile",
+			annotation:           `{"components":{"registration":{"replicas":-1}}}`,
+			expectErr:            true,
+			expectedAppliedState: metav1.ConditionFalse,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			clusterManager := newClusterManager("testhub")
+			if len(c.annotation) > 0 {
+				clusterManager.Annotations = map[string]string{deployProfileAnnotationKey: c.annotation}
+			}
+			clusterManagerNamespace := helpers.ClusterManagerNamespace(clusterManager.Name, clusterManager.Spec.DeployOption.Mode)
+			if c.existingPDB {
+				clusterManager.Status.RelatedResources = []operatorapiv1.RelatedResourceMeta{
+					podDisruptionBudgetResource(clusterManagerNamespace, "testhub-registration-controller"),
+				}
+			}
+			tc := newTestController(t, clusterManager)
+			setup(t, tc, setDeployment(clusterManager.Name, clusterManagerNamespace))
+
+			err := tc.clusterManagerController.sync(ctx, testingcommon.NewFakeSyncContext(t, "testhub"), "testhub")
+			if c.expectErr != (err != nil) {
+				t.Errorf("expected error %v, but got %v", c.expectErr, err)
+			}
+
+			var createdPDBs, deletedPDBs []string
+			for _, action := range tc.managementKubeClient.Actions() {
+				if action.GetResource().Resource != "poddisruptionbudgets" {
+					continue
+				}
+				switch action.GetVerb() {
+				case createVerb:
+					pdb := action.(clienttesting.CreateActionImpl).Object.(*policyv1.PodDisruptionBudget)
+					createdPDBs = append(createdPDBs, pdb.Name)
+				case "delete":
+					deletedPDBs = append(deletedPDBs, action.(clienttesting.DeleteActionImpl).Name)
+				}
+			}
+			if !reflect.DeepEqual(createdPDBs, c.expectedPDBs) {
+				t.Errorf("expected created PodDisruptionBudgets %v, but got %v", c.expectedPDBs, createdPDBs)
+			}
+			if !reflect.DeepEqual(deletedPDBs, c.expectedDeletedPDBs) {
+				t.Errorf("expected deleted PodDisruptionBudgets %v, but got %v", c.expectedDeletedPDBs, deletedPDBs)
+			}
+
+			updated, err := tc.operatorClient.OperatorV1().ClusterManagers().Get(ctx, "testhub", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			applied := meta.FindStatusCondition(updated.Status.Conditions, operatorapiv1.ConditionClusterManagerApplied)
+			if applied == nil || applied.Status != c.expectedAppliedState {
+				t.Errorf("expected applied condition %s, but got %v", c.expectedAppliedState, applied)
+			}
+			recorded := helpers.FindRelatedResourcesStatus(updated.Status.RelatedResources,
+				podDisruptionBudgetResource(clusterManagerNamespace, "testhub-registration-controller")) != nil
+			if c.expectedAppliedState == metav1.ConditionTrue && recorded != (len(c.expectedPDBs) > 0) {
+				t.Errorf("expected PodDisruptionBudget recorded %v, but got %v", len(c.expectedPDBs) > 0, recorded)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package clustermanagercontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/component-base/featuregate"
+
+	ocmfeature "open-cluster-management.io/api/feature"
+	operatorapiv1 "open-cluster-management.io/api/operator/v1"
+
+	commonhelper "open-cluster-management.io/ocm/pkg/common/helpers"
+	"open-cluster-management.io/ocm/pkg/features"
+	"open-cluster-management.io/ocm/pkg/operator/helpers"
+)
+
+// admittedFeaturesAnnotationKey records the hub features admitted for the fleet, the admitted features are kept
+// enabled even if the clusters that cannot support them join the hub later.
+const admittedFeaturesAnnotationKey = "operator.open-cluster-management.io/admitted-feature-gates"
+
+// featureAdmission is the result of checking the features enabled in the ClusterManager against the agent version
+// inventory of the fleet aggregated by the registration controller.
+type featureAdmission struct {
+	// inventoryFound is false if the inventory does not exist, and all features are admitted.
+	inventoryFound bool
+	// admitted are the enabled features that the fleet supports, or were admitted before.
+	admitted sets.Set[string]
+	// refused maps the features that are newly enabled but the fleet cannot support yet to the clusters that cannot
+	// support them.
+	refused map[string]commonhelper.UnsupportedFeature
+}
+
+// inventoryGetter returns the agent version inventory ConfigMap in the cluster manager namespace.
+type inventoryGetter func() (*corev1.ConfigMap, error)
+
+// listerInventoryGetter returns the inventory from the informer of the operator, which only watches the hub in the
+// Default mode.
+func listerInventoryGetter(lister corev1listers.ConfigMapLister, clusterManagerNamespace string) inventoryGetter {
+	return func() (*corev1.ConfigMap, error) {
+		return lister.ConfigMaps(clusterManagerNamespace).Get(commonhelper.AgentVersionInventoryConfigMap)
+	}
+}
+
+// hubInventoryGetter reads the inventory from the hub in the Hosted mode, where the hub is not watched by the
+// informers of the operator.
+func hubInventoryGetter(ctx context.Context, hubClient kubernetes.Interface, clusterManagerNamespace string) inventoryGetter {
+	return func() (*corev1.ConfigMap, error) {
+		return hubClient.CoreV1().ConfigMaps(clusterManagerNamespace).Get(
+			ctx, commonhelper.AgentVersionInventoryConfigMap, metav1.GetOptions{})
+	}
+}
+
+// admitFeatures refuses to enable the features that are not admitted yet if the agents of some clusters are too old
+// for them. All enabled features are admitted if the ClusterManager is not recorded with the admitted features, or
+// the AgentVersionSkew feature gate is disabled, in which case the inventory is not maintained.
+func admitFeatures(getInventory inventoryGetter, clusterManager *operatorapiv1.ClusterManager) (*featureAdmission, error) {
+	enabled := enabledFeatures(clusterManager)
+	admission := &featureAdmission{admitted: sets.New[string](), refused: map[string]commonhelper.UnsupportedFeature{}}
+	if !enabled.Has(string(features.AgentVersionSkew)) {
+		admission.admitted = enabled
+		return admission, nil
+	}
+
+	inventory, err := getInventory()
+	if errors.IsNotFound(err) {
+		admission.admitted = enabled
+		return admission, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	admission.inventoryFound = true
+
+	unsupported := map[string]commonhelper.UnsupportedFeature{}
+	if err := json.Unmarshal([]byte(inventory.Data[commonhelper.UnsupportedFeaturesKey]), &unsupported); err != nil {
+		return nil, fmt.Errorf("invalid unsupported features of the agent version inventory: %w", err)
+	}
+
+	previous, recorded := clusterManager.Annotations[admittedFeaturesAnnotationKey]
+	previouslyAdmitted := sets.New(strings.Split(previous, ",")...)
+	for feature := range enabled {
+		if !recorded || previouslyAdmitted.Has(feature) || unsupported[feature].Count == 0 {
+			admission.admitted.Insert(feature)
+			continue
+		}
+		admission.refused[feature] = unsupported[feature]
+	}
+	return admission, nil
+}
+
+// annotation returns the value of the admitted features annotation.
+func (a *featureAdmission) annotation() string {
+	return strings.Join(sets.List(a.admitted), ",")
+}
+
+// featureGates returns the feature gates of a component with the refused features disabled.
+func (a *featureAdmission) featureGates(featureGates []operatorapiv1.FeatureGate,
+	defaultFeatureGates map[featuregate.Feature]featuregate.FeatureSpec) []operatorapiv1.FeatureGate {
+	if len(a.refused) == 0 {
+		return featureGates
+	}
+
+	var admitted []operatorapiv1.FeatureGate
+	for _, featureGate := range featureGates {
+		if _, ok := a.refused[featureGate.Feature]; !ok {
+			admitted = append(admitted, featureGate)
+		}
+	}
+	for _, feature := range sets.List(sets.KeySet(a.refused)) {
+		if _, ok := defaultFeatureGates[featuregate.Feature(feature)]; ok {
+			admitted = append(admitted, operatorapiv1.FeatureGate{
+				Feature: feature,
+				Mode:    operatorapiv1.FeatureGateModeTypeDisable,
+			})
+		}
+	}
+	return admitted
+}
+
+// condition returns the FeatureGatesAdmitted condition, nil if the inventory does not exist.
+func (a *featureAdmission) condition() *metav1.Condition {
+	if !a.inventoryFound {
+		return nil
+	}
+	if len(a.refused) == 0 {
+		return &metav1.Condition{
+			Type:    helpers.FeatureGatesTypeAdmitted,
+			Status:  metav1.ConditionTrue,
+			Reason:  helpers.FeatureGatesReasonAllAdmitted,
+			Message: "Feature gates are all supported by the agents of the managed clusters",
+		}
+	}
+
+	var messages []string
+	for _, feature := range sets.List(sets.KeySet(a.refused)) {
+		refused := a.refused[feature]
+		clusters := refused.Clusters
+		if len(clusters) > 5 {
+			clusters = clusters[:5:5]
+		}
+		if refused.Count > len(clusters) {
+			clusters = append(clusters, fmt.Sprintf("and %d more", refused.Count-len(clusters)))
+		}
+		messages = append(messages, fmt.Sprintf("%s (%s)", feature, strings.Join(clusters, ", ")))
+	}
+	return &metav1.Condition{
+		Type:   helpers.FeatureGatesTypeAdmitted,
+		Status: metav1.ConditionFalse,
+		Reason: helpers.FeatureGatesReasonRefused,
+		Message: fmt.Sprintf("Feature gates %s are not enabled until the agents of the managed clusters are upgraded",
+			strings.Join(messages, ", ")),
+	}
+}
+
+// enabledFeatures returns the features enabled in the ClusterManager or enabled by default.
+func enabledFeatures(clusterManager *operatorapiv1.ClusterManager) sets.Set[string] {
+	var registrationFeatureGates, workFeatureGates, addonFeatureGates []operatorapiv1.FeatureGate
+	if clusterManager.Spec.RegistrationConfiguration != nil {
+		registrationFeatureGates = clusterManager.Spec.RegistrationConfiguration.FeatureGates
+	}
+	if clusterManager.Spec.WorkConfiguration != nil {
+		workFeatureGates = clusterManager.Spec.WorkConfiguration.FeatureGates
+	}
+	if clusterManager.Spec.AddOnManagerConfiguration != nil {
+		addonFeatureGates = clusterManager.Spec.AddOnManagerConfiguration.FeatureGates
+	}
+
+	enabled := sets.New[string]()
+	for _, component := range []struct {
+		featureGates        []operatorapiv1.FeatureGate
+		defaultFeatureGates map[featuregate.Feature]featuregate.FeatureSpec
+	}{
+		{registrationFeatureGates, features.DefaultHubRegistrationFeatureGates},
+		{workFeatureGates, ocmfeature.DefaultHubWorkFeatureGates},
+		{addonFeatureGates, ocmfeature.DefaultHubAddonManagerFeatureGates},
+	} {
+		for feature := range component.defaultFeatureGates {
+			if helpers.FeatureGateEnabled(component.featureGates, component.defaultFeatureGates, feature) {
+				enabled.Insert(string(feature))
+			}
+		}
+	}
+	return enabled
+}
@@ -0,0 +1,139 @@
+package clustermanagercontroller
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	kubeinformers "k8s.io/client-go/informers"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+
+	ocmfeature "open-cluster-management.io/api/feature"
+	operatorapiv1 "open-cluster-management.io/api/operator/v1"
+
+	commonhelper "open-cluster-management.io/ocm/pkg/common/helpers"
+	"open-cluster-management.io/ocm/pkg/features"
+	"open-cluster-management.io/ocm/pkg/operator/helpers"
+)
+
+func TestAdmitFeatures(t *testing.T) {
+	manifestWorkReplicaSet := string(ocmfeature.ManifestWorkReplicaSet)
+	cases := []struct {
+		name              string
+		annotations       map[string]string
+		inventory         string
+		skewDisabled      bool
+		expectedRefused   []string
+		expectedCondition *metav1.ConditionStatus
+	}{
+		{
+			name: "no inventory",
+		},
+		{
+			name:              "admit the features the fleet supports",
+			annotations:       map[string]string{admittedFeaturesAnnotationKey: ""},
+			inventory:         `{"ClusterProfile":{"count":1,"clusters":["cluster1"]}}`,
+			expectedCondition: ptr.To(metav1.ConditionTrue),
+		},
+		{
+			name:              "admit the enabled features once the annotation is not recorded",
+			inventory:         `{"ManifestWorkReplicaSet":{"count":1,"clusters":["cluster1"]}}`,
+			expectedCondition: ptr.To(metav1.ConditionTrue),
+		},
+		{
+			name:              "keep the admitted features",
+			annotations:       map[string]string{admittedFeaturesAnnotationKey: manifestWorkReplicaSet},
+			inventory:         `{"ManifestWorkReplicaSet":{"count":1,"clusters":["cluster1"]}}`,
+			expectedCondition: ptr.To(metav1.ConditionTrue),
+		},
+		{
+			name:         "agent version skew is disabled",
+			annotations:  map[string]string{admittedFeaturesAnnotationKey: ""},
+			inventory:    `{"ManifestWorkReplicaSet":{"count":1,"clusters":["cluster1"]}}`,
+			skewDisabled: true,
+		},
+		{
+			name:              "refuse the newly enabled features the fleet cannot support",
+			annotations:       map[string]string{admittedFeaturesAnnotationKey: ""},
+			inventory:         `{"ManifestWorkReplicaSet":{"count":1,"clusters":["cluster1"]}}`,
+			expectedRefused:   []string{manifestWorkReplicaSet},
+			expectedCondition: ptr.To(metav1.ConditionFalse),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			clusterManager := newClusterManager("testhub")
+			clusterManager.Annotations = c.annotations
+			clusterManager.Spec.WorkConfiguration = &operatorapiv1.WorkConfiguration{
+				FeatureGates: []operatorapiv1.FeatureGate{
+					{Feature: manifestWorkReplicaSet, Mode: operatorapiv1.FeatureGateModeTypeEnable},
+				},
+			}
+			if !c.skewDisabled {
+				clusterManager.Spec.RegistrationConfiguration = &operatorapiv1.RegistrationHubConfiguration{
+					FeatureGates: []operatorapiv1.FeatureGate{
+						{Feature: string(features.AgentVersionSkew), Mode: operatorapiv1.FeatureGateModeTypeEnable},
+					},
+				}
+			}
+			configMapInformer := kubeinformers.NewSharedInformerFactory(fakekube.NewSimpleClientset(), 5*time.Minute).
+				Core().V1().ConfigMaps()
+			if len(c.inventory) > 0 {
+				if err := configMapInformer.Informer().GetStore().Add(&corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: commonhelper.AgentVersionInventoryConfigMap, Namespace: "ocm-hub"},
+					Data:       map[string]string{commonhelper.UnsupportedFeaturesKey: c.inventory},
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			admission, err := admitFeatures(listerInventoryGetter(configMapInformer.Lister(), "ocm-hub"), clusterManager)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if refused := sets.List(sets.KeySet(admission.refused)); len(refused) != len(c.expectedRefused) ||
+				(len(refused) > 0 && !reflect.DeepEqual(refused, c.expectedRefused)) {
+				t.Errorf("expected refused features %v, but got %v", c.expectedRefused, refused)
+			}
+			if admission.admitted.Has(manifestWorkReplicaSet) == (len(c.expectedRefused) > 0) {
+				t.Errorf("unexpected admitted features %v", sets.List(admission.admitted))
+			}
+
+			condition := admission.condition()
+			switch {
+			case c.expectedCondition == nil && condition != nil:
+				t.Errorf("expected no condition, but got %v", condition)
+			case c.expectedCondition != nil && (condition == nil || condition.Status != *c.expectedCondition):
+				t.Errorf("expected condition %s, but got %v", *c.expectedCondition, condition)
+			case condition != nil && condition.Type != helpers.FeatureGatesTypeAdmitted:
+				t.Errorf("unexpected condition type %s", condition.Type)
+			}
+
+			featureGates := admission.featureGates(clusterManager.Spec.WorkConfiguration.FeatureGates,
+				ocmfeature.DefaultHubWorkFeatureGates)
+			if enabled := helpers.FeatureGateEnabled(featureGates, ocmfeature.DefaultHubWorkFeatureGates,
+				ocmfeature.ManifestWorkReplicaSet); enabled == (len(c.expectedRefused) > 0) {
+				t.Errorf("unexpected feature gates %v", featureGates)
+			}
+		})
+	}
+}
+
+func TestFeatureAdmissionConditionMessage(t *testing.T) {
+	admission := &featureAdmission{
+		inventoryFound: true,
+		refused: map[string]commonhelper.UnsupportedFeature{
+			"ManifestWorkReplicaSet": {Count: 12, Clusters: []string{"c1", "c2", "c3", "c4", "c5", "c6", "c7"}},
+		},
+	}
+	expected := "Feature gates ManifestWorkReplicaSet (c1, c2, c3, c4, c5, and 7 more) are not enabled until " +
+		"the agents of the managed clusters are upgraded"
+	if condition := admission.condition(); condition.Message != expected {
+		t.Errorf("expected message %q, but got %q", expected, condition.Message)
+	}
+}
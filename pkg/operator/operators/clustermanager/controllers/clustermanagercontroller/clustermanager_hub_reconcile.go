@@ -46,6 +46,8 @@ var (
 		"cluster-manager/hub/placement/clusterrolebinding.yaml",
 		"cluster-manager/hub/placement/serviceaccount.yaml",
 		// addon-webhook
+		"cluster-manager/hub/addon-manager/webhook-clusterrole.yaml",
+		"cluster-manager/hub/addon-manager/webhook-clusterrolebinding.yaml",
 		"cluster-manager/hub/addon-manager/webhook-serviceaccount.yaml",
 	}
 
@@ -60,10 +62,16 @@ var (
 		// addon-manager
 		"cluster-manager/hub/addon-manager/clusterrole.yaml",
 		"cluster-manager/hub/addon-manager/clusterrolebinding.yaml",
+		"cluster-manager/hub/addon-manager/role.yaml",
+		"cluster-manager/hub/addon-manager/rolebinding.yaml",
 		"cluster-manager/hub/addon-manager/work-executor-admin-clusterrolebinding.yaml",
 		"cluster-manager/hub/addon-manager/serviceaccount.yaml",
 	}
 
+	// hubAddOnChartNamespaceFile is the namespace of the helm chart archives of the addon templates, it is not removed
+	// when the addon manager is disabled to keep the charts of the users.
+	hubAddOnChartNamespaceFile = "cluster-manager/hub/addon-manager/chart-namespace.yaml"
+
 	// The hubHostedWebhookServiceFiles should only be deployed on the hub cluster when the deploy mode is hosted.
 	hubDefaultWebhookServiceFiles = []string{
 		"cluster-manager/hub/registration/webhook-service.yaml",
@@ -170,6 +178,7 @@ func getHubResources(mode operatorapiv1.InstallMode, config manifests.HubConfig)
 	hubResources := []string{namespaceResource}
 	hubResources = append(hubResources, hubRbacResourceFiles...)
 	if config.AddOnManagerEnabled {
+		hubResources = append(hubResources, hubAddOnChartNamespaceFile)
 		hubResources = append(hubResources, hubAddOnManagerRbacResourceFiles...)
 	}
 
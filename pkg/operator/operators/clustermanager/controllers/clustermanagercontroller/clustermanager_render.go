@@ -0,0 +1,196 @@
+package clustermanagercontroller
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/openshift/library-go/pkg/assets"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	operatorapiv1 "open-cluster-management.io/api/operator/v1"
+
+	"open-cluster-management.io/ocm/manifests"
+	"open-cluster-management.io/ocm/pkg/operator/helpers"
+)
+
+// RenderOptions is the configuration to render the manifests of a cluster manager without the operator.
+type RenderOptions struct {
+	// OperatorNamespace is the namespace the operator would run in.
+	OperatorNamespace string
+	// Replica is the replica of the deployments.
+	Replica int32
+	// EnableSyncLabels syncs the labels of the cluster manager to the rendered resources.
+	EnableSyncLabels bool
+	// CABundle is the PEM encoded CA bundle to verify the serving certificates of the webhooks, it is required since
+	// the webhooks fail closed. The serving certificate secrets are not rendered, they are provisioned out of the band.
+	CABundle []byte
+	// HubKubeConfig is the kubeconfig of the hub used by the components on the management cluster in the Hosted mode.
+	// The operator generates the kubeconfigs with the tokens of the service accounts on the hub instead, which are
+	// not available without the hub.
+	HubKubeConfig []byte
+}
+
+// RenderManifests renders the manifests that the operator applies for the cluster manager, in the order they are
+// applied: the crds, the namespace and rbac resources, the deployments patched by the component patches with the
+// PodDisruptionBudgets required by the deploy profile, and the webhook configurations. All enabled features are
+// admitted since there is no fleet to check against.
+//
+// The manifests applied on the hub are returned as the hubObjects. In the Hosted mode, the namespace, the kubeconfig
+// secrets and the deployments applied on the management cluster are returned as the managementObjects, otherwise
+// they are returned in the hubObjects.
+func RenderManifests(ctx context.Context, clusterManager *operatorapiv1.ClusterManager, opts RenderOptions) (
+	hubObjects, managementObjects [][]byte, err error) {
+	mode := clusterManager.Spec.DeployOption.Mode
+	if len(opts.CABundle) == 0 {
+		return nil, nil, fmt.Errorf("the CA bundle of the webhooks is required")
+	}
+	if helpers.IsHosted(mode) && len(opts.HubKubeConfig) == 0 {
+		return nil, nil, fmt.Errorf("the hub kubeconfig is required in %s mode", mode)
+	}
+
+	resourceRequirements, err := helpers.ResourceRequirements(ctx, clusterManager)
+	if err != nil {
+		return nil, nil, err
+	}
+	profile, err := parseDeployProfile(clusterManager)
+	if err != nil {
+		return nil, nil, err
+	}
+	patches, err := helpers.ParseComponentPatches(clusterManager.Annotations, deployComponentNames())
+	if err != nil {
+		return nil, nil, err
+	}
+	config, featureGateCondition := newHubConfig(clusterManager, opts.OperatorNamespace, opts.Replica,
+		resourceRequirements, opts.EnableSyncLabels, &featureAdmission{admitted: enabledFeatures(clusterManager)})
+	if featureGateCondition.Status == metav1.ConditionFalse {
+		return nil, nil, errors.New(featureGateCondition.Message)
+	}
+	setRegistrationDrivers(clusterManager, &config)
+
+	encodedCaBundle := base64.StdEncoding.EncodeToString(opts.CABundle)
+	config.RegistrationAPIServiceCABundle = encodedCaBundle
+	config.WorkAPIServiceCABundle = encodedCaBundle
+	config.AddonAPIServiceCABundle = encodedCaBundle
+
+	render := func(name string) ([]byte, error) {
+		template, err := manifests.ClusterManagerManifestFiles.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		return assets.MustCreateAssetFromTemplate(name, template, config).Data, nil
+	}
+
+	crdFiles := hubCRDResourceFiles
+	if config.ClusterProfileEnabled {
+		crdFiles = append(crdFiles, hubClusterProfileCRDResourceFiles...)
+	}
+	for _, file := range crdFiles {
+		objData, err := render(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		if objData, err = helpers.AddLabelsToYaml(objData, clusterManager.Labels); err != nil {
+			return nil, nil, fmt.Errorf("failed to add labels to template %s: %w", file, err)
+		}
+		hubObjects = append(hubObjects, objData)
+	}
+
+	for _, file := range getHubResources(mode, config) {
+		objData, err := render(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		hubObjects = append(hubObjects, objData)
+	}
+
+	if helpers.IsHosted(mode) {
+		namespace, err := render(namespaceResource)
+		if err != nil {
+			return nil, nil, err
+		}
+		managementObjects = append(managementObjects, namespace)
+		for _, sa := range getSAs(config.WorkControllerEnabled, config.AddOnManagerEnabled, config.GRPCAuthEnabled) {
+			secret, err := yaml.Marshal(map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Secret",
+				"metadata": map[string]interface{}{
+					"name":      sa + "-kubeconfig",
+					"namespace": config.ClusterManagerNamespace,
+				},
+				"data": map[string]interface{}{
+					"kubeconfig": opts.HubKubeConfig,
+				},
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			managementObjects = append(managementObjects, secret)
+		}
+	}
+
+	deployResources := deploymentFiles
+	if config.AddOnManagerEnabled {
+		deployResources = append(deployResources, addOnManagerDeploymentFiles...)
+	}
+	if config.WorkControllerEnabled {
+		deployResources = append(deployResources, workControllerDeploymentFiles...)
+	}
+	if config.GRPCAuthEnabled {
+		deployResources = append(deployResources, grpcServerDeploymentFiles...)
+	}
+	var deployObjects [][]byte
+	for _, file := range deployResources {
+		objData, err := render(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		if objData, err = helpers.AddNodePlacementToYaml(objData, clusterManager.Spec.NodePlacement); err != nil {
+			return nil, nil, fmt.Errorf("failed to add node placement to template %s: %w", file, err)
+		}
+		component, leaderElection := profile.component(file)
+		if objData, err = applyDeployProfile(objData, component, leaderElection); err != nil {
+			return nil, nil, fmt.Errorf("failed to apply deploy profile to template %s: %w", file, err)
+		}
+		if objData, err = patches.Apply(deployComponents[file].name, objData); err != nil {
+			return nil, nil, err
+		}
+		deployObjects = append(deployObjects, objData)
+
+		pdb, err := podDisruptionBudget(objData, component)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to render PodDisruptionBudget of template %s: %w", file, err)
+		}
+		if pdb == nil {
+			continue
+		}
+		pdbData, err := podDisruptionBudgetToYaml(pdb)
+		if err != nil {
+			return nil, nil, err
+		}
+		deployObjects = append(deployObjects, pdbData)
+	}
+	if helpers.IsHosted(mode) {
+		managementObjects = append(managementObjects, deployObjects...)
+	} else {
+		hubObjects = append(hubObjects, deployObjects...)
+	}
+
+	// the addon webhook is not supported in the Hosted mode.
+	webhookResources := hubRegistrationWebhookResourceFiles
+	webhookResources = append(webhookResources, hubWorkWebhookResourceFiles...)
+	if !config.HostedMode {
+		webhookResources = append(webhookResources, hubAddonWebhookResourceFiles...)
+	}
+	for _, file := range webhookResources {
+		objData, err := render(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		hubObjects = append(hubObjects, objData)
+	}
+
+	return hubObjects, managementObjects, nil
+}
@@ -0,0 +1,175 @@
+package clustermanagercontroller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	operatorapiv1 "open-cluster-management.io/api/operator/v1"
+
+	"open-cluster-management.io/ocm/pkg/operator/helpers"
+)
+
+func TestRenderManifests(t *testing.T) {
+	cases := []struct {
+		name               string
+		mode               operatorapiv1.InstallMode
+		annotations        map[string]string
+		featureGates       []operatorapiv1.FeatureGate
+		caBundle           []byte
+		expectErr          bool
+		expectedContents   []string
+		unexpectedContents []string
+		// expectedManagementContents are rendered for the management cluster in the Hosted mode.
+		expectedManagementContents []string
+	}{
+		{
+			name: "default mode",
+			mode: operatorapiv1.InstallModeDefault,
+			expectedContents: []string{
+				"name: managedclusters.cluster.open-cluster-management.io",
+				"name: cluster-manager-registration-controller",
+				"name: cluster-manager-work-controller",
+				"name: cluster-manager-addon-manager-controller",
+				"name: addontemplatevalidators.admission.addon.open-cluster-management.io",
+				"caBundle: dGVzdC1jYQ==",
+				"node-role.kubernetes.io/infra",
+			},
+			unexpectedContents: []string{
+				"name: cluster-manager-grpc-server",
+			},
+		},
+		{
+			name: "default mode with deploy profile",
+			mode: operatorapiv1.InstallModeDefault,
+			annotations: map[string]string{deployProfileAnnotationKey: `{"default":{"priorityClassName":"system-cluster-critical",` +
+				`"leaderElection":{"leaseDuration":"60s","renewDeadline":"40s","retryPeriod":"10s"}},` +
+				`"components":{"placement":{"replicas":3,"podDisruptionBudget":{"minAvailable":2}}}}`},
+			expectedContents: []string{
+				"priorityClassName: system-cluster-critical",
+				"--leader-election-lease-duration=1m0s",
+				"kind: PodDisruptionBudget\nmetadata:\n  labels:\n    app: cluster-manager-placement-controller",
+				"minAvailable: 2",
+			},
+		},
+		{
+			name:        "invalid deploy profile",
+			mode:        operatorapiv1.InstallModeDefault,
+			annotations: map[string]string{deployProfileAnnotationKey: `{"components":{"unknown":{}}}`},
+			expectErr:   true,
+		},
+		{
+			name: "default mode with component patches",
+			mode: operatorapiv1.InstallModeDefault,
+			annotations: map[string]string{helpers.ComponentPatchesAnnotationKey: `{"placement":[` +
+				`{"type":"StrategicMerge","patch":{"spec":{"template":{"metadata":{"annotations":{"sidecar.istio.io/inject":"true"}}}}}},` +
+				`{"type":"JSON","patch":[{"op":"add","path":"/spec/template/spec/containers/0/args/-","value":"--v=4"}]}]}`},
+			expectedContents: []string{
+				"sidecar.istio.io/inject: \"true\"",
+				"- --v=4",
+			},
+		},
+		{
+			name:        "invalid component patches",
+			mode:        operatorapiv1.InstallModeDefault,
+			annotations: map[string]string{helpers.ComponentPatchesAnnotationKey: `{"placement":[{"type":"Merge","patch":{}}]}`},
+			expectErr:   true,
+		},
+		{
+			name: "hosted mode",
+			mode: operatorapiv1.InstallModeHosted,
+			expectedContents: []string{
+				"name: managedclusters.cluster.open-cluster-management.io",
+				"name: managedclustervalidators.admission.cluster.open-cluster-management.io",
+			},
+			unexpectedContents: []string{
+				"name: cluster-manager-registration-controller\n",
+				"name: addontemplatevalidators.admission.addon.open-cluster-management.io",
+			},
+			expectedManagementContents: []string{
+				"kind: Namespace",
+				"name: registration-controller-sa-kubeconfig",
+				"kubeconfig: a3ViZWNvbmZpZw==",
+				"name: cluster-manager-registration-controller",
+			},
+		},
+		{
+			name:      "without ca bundle",
+			mode:      operatorapiv1.InstallModeDefault,
+			caBundle:  []byte{},
+			expectErr: true,
+		},
+		{
+			name:         "invalid feature gates",
+			mode:         operatorapiv1.InstallModeDefault,
+			featureGates: []operatorapiv1.FeatureGate{{Feature: "Unknown", Mode: operatorapiv1.FeatureGateModeTypeEnable}},
+			expectErr:    true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			clusterManager := newClusterManager("cluster-manager")
+			clusterManager.Spec.DeployOption.Mode = c.mode
+			clusterManager.Annotations = c.annotations
+			clusterManager.Spec.NodePlacement = operatorapiv1.NodePlacement{
+				Tolerations: []corev1.Toleration{{Key: "node-role.kubernetes.io/infra", Operator: corev1.TolerationOpExists}},
+			}
+
+			if len(c.featureGates) > 0 {
+				clusterManager.Spec.RegistrationConfiguration = &operatorapiv1.RegistrationHubConfiguration{
+					FeatureGates: c.featureGates,
+				}
+			}
+			caBundle := []byte("test-ca")
+			if c.caBundle != nil {
+				caBundle = c.caBundle
+			}
+
+			objects, managementObjects, err := RenderManifests(context.TODO(), clusterManager, RenderOptions{
+				OperatorNamespace: "open-cluster-management",
+				Replica:           1,
+				CABundle:          caBundle,
+				HubKubeConfig:     []byte("kubeconfig"),
+			})
+			if c.expectErr {
+				if err == nil {
+					t.Errorf("expected error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var rendered []string
+			for _, objData := range objects {
+				rendered = append(rendered, string(objData))
+			}
+			content := strings.Join(rendered, "\n---\n")
+			for _, expected := range c.expectedContents {
+				if !strings.Contains(content, expected) {
+					t.Errorf("expected %q is rendered", expected)
+				}
+			}
+			for _, unexpected := range c.unexpectedContents {
+				if strings.Contains(content, unexpected) {
+					t.Errorf("expected %q is not rendered", unexpected)
+				}
+			}
+
+			var renderedManagement []string
+			for _, objData := range managementObjects {
+				renderedManagement = append(renderedManagement, string(objData))
+			}
+			managementContent := strings.Join(renderedManagement, "\n---\n")
+			for _, expected := range c.expectedManagementContents {
+				if !strings.Contains(managementContent, expected) {
+					t.Errorf("expected %q is rendered for the management cluster", expected)
+				}
+			}
+		})
+	}
+}
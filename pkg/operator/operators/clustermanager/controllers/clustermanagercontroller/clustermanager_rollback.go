@@ -0,0 +1,285 @@
+package clustermanagercontroller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"sigs.k8s.io/yaml"
+
+	operatorapiv1 "open-cluster-management.io/api/operator/v1"
+
+	"open-cluster-management.io/ocm/pkg/operator/helpers"
+)
+
+const (
+	// ConditionHubComponentsRolledBack is true if the rollouts of some hub components failed and the components are
+	// rolled back to their last known-good spec.
+	ConditionHubComponentsRolledBack = "HubComponentsRolledBack"
+	reasonRolloutFailed              = "RolloutFailed"
+
+	// the reason of the Progressing condition of a deployment whose rollout exceeds progressDeadlineSeconds.
+	reasonProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+)
+
+// rollbackConfigMapName returns the name of the ConfigMap in the cluster manager namespace that records the last
+// known-good and the failed rollouts of the components opted in to the rollback.
+func rollbackConfigMapName(clusterManagerName string) string {
+	return clusterManagerName + "-rollback"
+}
+
+// rollbackRecord is the rollout history of a component.
+type rollbackRecord struct {
+	// KnownGoodHash is the hash of the last rendered deployment that became available.
+	KnownGoodHash string `json:"knownGoodHash,omitempty"`
+	// KnownGoodTemplate is the pod template of the last rendered deployment that became available, and
+	// KnownGoodNodePlacement is the node placement it was applied with.
+	KnownGoodTemplate      *corev1.PodTemplateSpec     `json:"knownGoodTemplate,omitempty"`
+	KnownGoodNodePlacement operatorapiv1.NodePlacement `json:"knownGoodNodePlacement,omitempty"`
+	// FailedHash is the hash of the rendered deployment that failed to become available, it is not applied again
+	// until the rendered deployment changes.
+	FailedHash       string `json:"failedHash,omitempty"`
+	FailedGeneration int64  `json:"failedGeneration,omitempty"`
+}
+
+// rollbackRecords are the rollback records of the components stored in the rollback ConfigMap.
+type rollbackRecords struct {
+	configMap *corev1.ConfigMap
+	records   map[string]*rollbackRecord
+	changed   bool
+}
+
+func loadRollbackRecords(lister corev1listers.ConfigMapLister,
+	namespace, clusterManagerName string) (*rollbackRecords, error) {
+	r := &rollbackRecords{records: map[string]*rollbackRecord{}}
+	configMap, err := lister.ConfigMaps(namespace).Get(rollbackConfigMapName(clusterManagerName))
+	switch {
+	case errors.IsNotFound(err):
+		r.configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      rollbackConfigMapName(clusterManagerName),
+				Namespace: namespace,
+				Labels:    map[string]string{helpers.RollbackLabelKey: clusterManagerName},
+			},
+		}
+		return r, nil
+	case err != nil:
+		return nil, err
+	}
+
+	r.configMap = configMap.DeepCopy()
+	for component, data := range configMap.Data {
+		record := &rollbackRecord{}
+		if err := json.Unmarshal([]byte(data), record); err != nil {
+			return nil, fmt.Errorf("invalid rollback record of %s in ConfigMap %s/%s: %w",
+				component, namespace, configMap.Name, err)
+		}
+		r.records[component] = record
+	}
+	return r, nil
+}
+
+func (r *rollbackRecords) get(component string) *rollbackRecord {
+	if _, ok := r.records[component]; !ok {
+		r.records[component] = &rollbackRecord{}
+	}
+	return r.records[component]
+}
+
+// save creates or updates the rollback ConfigMap if any record is changed.
+func (r *rollbackRecords) save(ctx context.Context, client kubernetes.Interface) error {
+	if !r.changed {
+		return nil
+	}
+	configMap := r.configMap.DeepCopy()
+	configMap.Data = map[string]string{}
+	for component, record := range r.records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		configMap.Data[component] = string(data)
+	}
+
+	var err error
+	if len(configMap.ResourceVersion) == 0 {
+		_, err = client.CoreV1().ConfigMaps(configMap.Namespace).Create(ctx, configMap, metav1.CreateOptions{})
+	} else {
+		_, err = client.CoreV1().ConfigMaps(configMap.Namespace).Update(ctx, configMap, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save rollback records into ConfigMap %s/%s: %w", configMap.Namespace, configMap.Name, err)
+	}
+	r.changed = false
+	return nil
+}
+
+// applyDeployment applies the rendered deployment of the file. If the component opts in to the rollback, the last
+// known-good deployment is applied instead once the rollout of the rendered deployment failed, and the failed
+// generation is returned.
+func (c *runtimeReconcile) applyDeployment(ctx context.Context, cm *operatorapiv1.ClusterManager, file string,
+	deploymentData []byte, component componentProfile, records *rollbackRecords) (*appsv1.Deployment, int64, error) {
+	apply := func(data []byte, nodePlacement operatorapiv1.NodePlacement) (*appsv1.Deployment, error) {
+		deployment, currentGeneration, err := helpers.ApplyDeployment(
+			ctx,
+			c.kubeClient,
+			cm.Status.Generations,
+			nodePlacement,
+			func(name string) ([]byte, error) {
+				return data, nil
+			},
+			c.recorder,
+			file)
+		if err != nil {
+			return nil, err
+		}
+		helpers.SetGenerationStatuses(&cm.Status.Generations, currentGeneration)
+		return deployment, nil
+	}
+
+	if component.Rollback == nil || records == nil {
+		deployment, err := apply(deploymentData, cm.Spec.NodePlacement)
+		return deployment, 0, err
+	}
+
+	record := records.get(deployComponents[file].name)
+	hash, err := deploymentHash(deploymentData, cm.Spec.NodePlacement)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	applyKnownGood := func() (*appsv1.Deployment, error) {
+		data, err := knownGoodDeployment(deploymentData, record.KnownGoodTemplate)
+		if err != nil {
+			return nil, err
+		}
+		return apply(data, record.KnownGoodNodePlacement)
+	}
+
+	// keep the known-good deployment until the rendered deployment is changed.
+	if record.FailedHash == hash && record.KnownGoodTemplate != nil {
+		deployment, err := applyKnownGood()
+		return deployment, record.FailedGeneration, err
+	}
+
+	deployment, err := apply(deploymentData, cm.Spec.NodePlacement)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch {
+	case rolloutComplete(deployment):
+		if record.KnownGoodHash != hash || len(record.FailedHash) > 0 {
+			template, err := podTemplate(deploymentData)
+			if err != nil {
+				return nil, 0, err
+			}
+			*record = rollbackRecord{
+				KnownGoodHash:          hash,
+				KnownGoodTemplate:      template,
+				KnownGoodNodePlacement: cm.Spec.NodePlacement,
+			}
+			records.changed = true
+		}
+	case rolloutFailed(deployment) && record.FailedHash != hash:
+		record.FailedHash = hash
+		record.FailedGeneration = deployment.Generation
+		records.changed = true
+		if record.KnownGoodTemplate == nil {
+			c.recorder.Warningf(ctx, "DeploymentRolloutFailed",
+				"deployment %s/%s generation %d failed to become available, there is no known-good spec to roll back to",
+				deployment.Namespace, deployment.Name, deployment.Generation)
+			return deployment, 0, nil
+		}
+		c.recorder.Warningf(ctx, "DeploymentRolledBack",
+			"deployment %s/%s generation %d failed to become available within %v, rolled back to the last known-good spec",
+			deployment.Namespace, deployment.Name, deployment.Generation, component.Rollback.ProgressDeadline.Duration)
+		deployment, err = applyKnownGood()
+		return deployment, record.FailedGeneration, err
+	}
+	return deployment, 0, nil
+}
+
+// knownGoodDeployment returns the rendered deployment with its pod template replaced by the known-good one.
+func knownGoodDeployment(deploymentData []byte, template *corev1.PodTemplateSpec) ([]byte, error) {
+	deployment := &appsv1.Deployment{}
+	if err := yaml.Unmarshal(deploymentData, deployment); err != nil {
+		return nil, err
+	}
+	deployment.Spec.Template = *template
+	return json.Marshal(deployment)
+}
+
+// podTemplate returns the pod template of the rendered deployment.
+func podTemplate(deploymentData []byte) (*corev1.PodTemplateSpec, error) {
+	deployment := &appsv1.Deployment{}
+	if err := yaml.Unmarshal(deploymentData, deployment); err != nil {
+		return nil, err
+	}
+	return &deployment.Spec.Template, nil
+}
+
+// rolledBackCondition returns the condition of the components rolled back, or nil if no component is rolled back.
+func rolledBackCondition(failedGenerations map[string]int64) *metav1.Condition {
+	if len(failedGenerations) == 0 {
+		return nil
+	}
+	var messages []string
+	for name, generation := range failedGenerations {
+		messages = append(messages, fmt.Sprintf("%s generation %d", name, generation))
+	}
+	sort.Strings(messages)
+	return &metav1.Condition{
+		Type:   ConditionHubComponentsRolledBack,
+		Status: metav1.ConditionTrue,
+		Reason: reasonRolloutFailed,
+		Message: fmt.Sprintf("Deployments %s failed to become available and are rolled back to the last known-good spec",
+			strings.Join(messages, ", ")),
+	}
+}
+
+// deploymentHash returns the hash of the rendered deployment and the node placement applied to it.
+func deploymentHash(deploymentData []byte, nodePlacement operatorapiv1.NodePlacement) (string, error) {
+	placementData, err := json.Marshal(nodePlacement)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(deploymentData)
+	h.Write(placementData)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// rolloutComplete returns true if all replicas of the current generation of the deployment are available.
+func rolloutComplete(deployment *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	return deployment.Generation == deployment.Status.ObservedGeneration &&
+		deployment.Status.UpdatedReplicas == replicas &&
+		deployment.Status.AvailableReplicas == replicas &&
+		deployment.Status.Replicas == replicas
+}
+
+// rolloutFailed returns true if the rollout of the current generation of the deployment exceeds its progress deadline.
+func rolloutFailed(deployment *appsv1.Deployment) bool {
+	if deployment.Generation != deployment.Status.ObservedGeneration {
+		return false
+	}
+	for _, cond := range deployment.Status.Conditions {
+		if cond.Type == appsv1.DeploymentProgressing {
+			return cond.Status == corev1.ConditionFalse && cond.Reason == reasonProgressDeadlineExceeded
+		}
+	}
+	return false
+}
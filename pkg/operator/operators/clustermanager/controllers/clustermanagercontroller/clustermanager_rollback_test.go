@@ -0,0 +1,231 @@
+package clustermanagercontroller
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+
+	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
+	"open-cluster-management.io/ocm/pkg/operator/helpers"
+)
+
+const placementDeploymentFile = "cluster-manager/management/placement/deployment.yaml"
+
+var deploymentGVR = appsv1.SchemeGroupVersion.WithResource("deployments")
+
+// newGenerationClient returns a fake client which increases the generation of deployments when the spec is changed
+// as the api server does.
+func newGenerationClient() *fakekube.Clientset {
+	client := fakekube.NewSimpleClientset()
+	client.PrependReactor("create", "deployments", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		deployment := action.(clienttesting.CreateAction).GetObject().(*appsv1.Deployment).DeepCopy()
+		deployment.Generation = 1
+		return true, deployment, client.Tracker().Create(deploymentGVR, deployment, deployment.Namespace)
+	})
+	client.PrependReactor("update", "deployments", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		deployment := action.(clienttesting.UpdateAction).GetObject().(*appsv1.Deployment).DeepCopy()
+		obj, err := client.Tracker().Get(deploymentGVR, deployment.Namespace, deployment.Name)
+		if err != nil {
+			return true, nil, err
+		}
+		existing := obj.(*appsv1.Deployment)
+		deployment.Generation = existing.Generation
+		if !equality.Semantic.DeepEqual(existing.Spec, deployment.Spec) {
+			deployment.Generation++
+		}
+		return true, deployment, client.Tracker().Update(deploymentGVR, deployment, deployment.Namespace)
+	})
+	return client
+}
+
+func setDeploymentStatus(t *testing.T, client *fakekube.Clientset, failed bool) {
+	obj, err := client.Tracker().Get(deploymentGVR, "open-cluster-management-hub", "cluster-manager-placement-controller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployment := obj.(*appsv1.Deployment)
+	deployment.Status = appsv1.DeploymentStatus{
+		ObservedGeneration: deployment.Generation,
+		Replicas:           1,
+		UpdatedReplicas:    1,
+		AvailableReplicas:  1,
+		Conditions: []appsv1.DeploymentCondition{
+			{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionTrue, Reason: "NewReplicaSetAvailable"},
+		},
+	}
+	if failed {
+		deployment.Status.AvailableReplicas = 0
+		deployment.Status.Conditions[0].Status = corev1.ConditionFalse
+		deployment.Status.Conditions[0].Reason = reasonProgressDeadlineExceeded
+	}
+	if err := client.Tracker().Update(deploymentGVR, deployment, deployment.Namespace); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func currentArgs(t *testing.T, client *fakekube.Clientset) []string {
+	obj, err := client.Tracker().Get(deploymentGVR, "open-cluster-management-hub", "cluster-manager-placement-controller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj.(*appsv1.Deployment).Spec.Template.Spec.Containers[0].Args
+}
+
+func TestApplyDeploymentRollback(t *testing.T) {
+	goodData := []byte(testDeployment)
+	badData := []byte(strings.Replace(testDeployment, `"controller"`, `"bad-controller"`, 1))
+	fixedData := []byte(strings.Replace(testDeployment, `"controller"`, `"fixed-controller"`, 1))
+	component := componentProfile{Rollback: &rollbackProfile{ProgressDeadline: metav1.Duration{Duration: time.Minute}}}
+
+	client := newGenerationClient()
+	cm := newClusterManager("cluster-manager")
+	c := &runtimeReconcile{kubeClient: client, recorder: testingcommon.NewFakeSyncContext(t, "").Recorder()}
+	configMapIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	records, err := loadRollbackRecords(corev1listers.NewConfigMapLister(configMapIndexer), "open-cluster-management-hub", cm.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name                     string
+		data                     []byte
+		failed                   bool
+		setStatus                bool
+		expectedFailedGeneration int64
+		expectedArg              string
+	}{
+		{name: "create the deployment", data: goodData, expectedArg: "controller"},
+		{name: "record the known-good deployment", data: goodData, setStatus: true, expectedArg: "controller"},
+		{name: "roll out a bad deployment", data: badData, expectedArg: "bad-controller"},
+		{
+			name: "roll back the failed rollout", data: badData, setStatus: true, failed: true,
+			expectedFailedGeneration: 2, expectedArg: "controller",
+		},
+		{name: "keep the known-good deployment", data: badData, expectedFailedGeneration: 2, expectedArg: "controller"},
+		{name: "roll out a fixed deployment", data: fixedData, expectedArg: "fixed-controller"},
+	}
+	for _, step := range steps {
+		if step.setStatus {
+			setDeploymentStatus(t, client, step.failed)
+		}
+		_, failedGeneration, err := c.applyDeployment(ctx, cm, placementDeploymentFile, step.data, component, records)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", step.name, err)
+		}
+		if failedGeneration != step.expectedFailedGeneration {
+			t.Errorf("%s: expected failed generation %d, but got %d", step.name, step.expectedFailedGeneration, failedGeneration)
+		}
+		if args := currentArgs(t, client); args[1] != step.expectedArg {
+			t.Errorf("%s: expected the deployment runs %q, but got %v", step.name, step.expectedArg, args)
+		}
+	}
+
+	if err := records.save(ctx, client); err != nil {
+		t.Fatal(err)
+	}
+	configMap, err := client.CoreV1().ConfigMaps("open-cluster-management-hub").Get(
+		ctx, rollbackConfigMapName(cm.Name), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := &rollbackRecord{}
+	if err := json.Unmarshal([]byte(configMap.Data["placement"]), record); err != nil {
+		t.Fatal(err)
+	}
+	goodTemplate, err := podTemplate(goodData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(record.KnownGoodTemplate, goodTemplate) || record.FailedGeneration != 2 {
+		t.Errorf("expected the known-good and the failed rollout are recorded, but got %v", record)
+	}
+	if configMap.Labels[helpers.RollbackLabelKey] != cm.Name {
+		t.Errorf("expected the rollback ConfigMap is labeled, but got %v", configMap.Labels)
+	}
+
+	// the records are loaded from the lister once the ConfigMap is observed.
+	if err := configMapIndexer.Add(configMap); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadRollbackRecords(corev1listers.NewConfigMapLister(configMapIndexer), "open-cluster-management-hub", cm.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equality.Semantic.DeepEqual(loaded.get("placement"), record) {
+		t.Errorf("expected the saved records are loaded, but got %v", loaded.get("placement"))
+	}
+}
+
+func TestRolloutState(t *testing.T) {
+	cases := []struct {
+		name             string
+		deployment       *appsv1.Deployment
+		expectedComplete bool
+		expectedFailed   bool
+	}{
+		{
+			name: "rollout in progress",
+			deployment: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Generation: 2},
+				Status:     appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+			},
+		},
+		{
+			name: "rollout complete",
+			deployment: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Generation: 2},
+				Status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+			},
+			expectedComplete: true,
+		},
+		{
+			name: "rollout failed",
+			deployment: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Generation: 2},
+				Status: appsv1.DeploymentStatus{
+					ObservedGeneration: 2,
+					Replicas:           2,
+					UpdatedReplicas:    1,
+					AvailableReplicas:  1,
+					Conditions: []appsv1.DeploymentCondition{{
+						Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: reasonProgressDeadlineExceeded,
+					}},
+				},
+			},
+			expectedFailed: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if complete := rolloutComplete(c.deployment); complete != c.expectedComplete {
+				t.Errorf("expected complete %v, but got %v", c.expectedComplete, complete)
+			}
+			if failed := rolloutFailed(c.deployment); failed != c.expectedFailed {
+				t.Errorf("expected failed %v, but got %v", c.expectedFailed, failed)
+			}
+		})
+	}
+}
+
+func TestRolledBackCondition(t *testing.T) {
+	if cond := rolledBackCondition(nil); cond != nil {
+		t.Errorf("expected no condition, but got %v", cond)
+	}
+	cond := rolledBackCondition(map[string]int64{"hub-work-controller": 3, "hub-placement-controller": 2})
+	expected := "Deployments hub-placement-controller generation 2, hub-work-controller generation 3 failed"
+	if cond.Status != metav1.ConditionTrue || !strings.HasPrefix(cond.Message, expected) {
+		t.Errorf("expected message %q, but got %v", expected, cond)
+	}
+}
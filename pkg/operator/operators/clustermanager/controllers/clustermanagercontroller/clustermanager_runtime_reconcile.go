@@ -7,20 +7,26 @@ package clustermanagercontroller
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/openshift/library-go/pkg/assets"
 	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/rest"
 
 	operatorapiv1 "open-cluster-management.io/api/operator/v1"
 	"open-cluster-management.io/sdk-go/pkg/basecontroller/events"
 
 	"open-cluster-management.io/ocm/manifests"
+	commonrecorder "open-cluster-management.io/ocm/pkg/common/recorder"
 	"open-cluster-management.io/ocm/pkg/operator/helpers"
 )
 
@@ -56,6 +62,8 @@ type runtimeReconcile struct {
 		hubConfig *rest.Config, hubClient, managementClient kubernetes.Interface, recorder events.Recorder,
 		mwctrEnabled, addonManagerEnabled, grpcAuthEnabled bool) error
 
+	rollbackConfigMapLister corev1listers.ConfigMapLister
+
 	cache    resourceapply.ResourceCache
 	recorder events.Recorder
 }
@@ -86,37 +94,29 @@ func (c *runtimeReconcile) reconcile(ctx context.Context, cm *operatorapiv1.Clus
 		}
 	}
 
-	if cm.Spec.RegistrationConfiguration != nil && cm.Spec.RegistrationConfiguration.RegistrationDrivers != nil {
-		var enabledRegistrationDrivers []string
-		for _, registrationDriver := range cm.Spec.RegistrationConfiguration.RegistrationDrivers {
-			enabledRegistrationDrivers = append(enabledRegistrationDrivers, registrationDriver.AuthType)
-			switch registrationDriver.AuthType {
-			case operatorapiv1.AwsIrsaAuthType:
-				if registrationDriver.AwsIrsa != nil {
-					config.HubClusterArn = registrationDriver.AwsIrsa.HubClusterArn
-					config.AutoApprovedARNPatterns = strings.Join(registrationDriver.AwsIrsa.AutoApprovedIdentities, ",")
-					config.AwsResourceTags = strings.Join(registrationDriver.AwsIrsa.Tags, ",")
-				}
-			case operatorapiv1.CSRAuthType:
-				if registrationDriver.CSR != nil {
-					config.AutoApprovedCSRUsers = strings.Join(registrationDriver.CSR.AutoApprovedIdentities, ",")
-				}
-			case operatorapiv1.GRPCAuthType:
-				if registrationDriver.GRPC != nil {
-					config.GRPCAutoApprovedUsers = strings.Join(registrationDriver.GRPC.AutoApprovedIdentities, ",")
-				}
-
-				// Prefer ServerConfiguration image when set; otherwise fall back to registration image.
-				if sc := cm.Spec.ServerConfiguration; sc == nil || strings.TrimSpace(sc.ImagePullSpec) == "" {
-					config.GRPCServerImage = cm.Spec.RegistrationImagePullSpec
-				} else {
-					config.GRPCServerImage = sc.ImagePullSpec
-				}
-			}
-		}
-		config.EnabledRegistrationDrivers = strings.Join(enabledRegistrationDrivers, ",")
+	profile, err := parseDeployProfile(cm)
+	if err != nil {
+		meta.SetStatusCondition(&cm.Status.Conditions, metav1.Condition{
+			Type:    operatorapiv1.ConditionClusterManagerApplied,
+			Status:  metav1.ConditionFalse,
+			Reason:  reasonInvalidDeployProfile,
+			Message: err.Error(),
+		})
+		return cm, reconcileStop, err
+	}
+	patches, err := helpers.ParseComponentPatches(cm.Annotations, deployComponentNames())
+	if err != nil {
+		meta.SetStatusCondition(&cm.Status.Conditions, metav1.Condition{
+			Type:    operatorapiv1.ConditionClusterManagerApplied,
+			Status:  metav1.ConditionFalse,
+			Reason:  helpers.ReasonInvalidComponentPatches,
+			Message: err.Error(),
+		})
+		return cm, reconcileStop, err
 	}
 
+	setRegistrationDrivers(cm, &config)
+
 	// In the Hosted mode, ensure the rbac kubeconfig secrets is existed for deployments to mount.
 	// In this step, we get serviceaccount token from the hub cluster to form a kubeconfig and set it as a secret on the management cluster.
 	// Before this step, the serviceaccounts in the hub cluster and the namespace in the management cluster should be applied first.
@@ -175,34 +175,73 @@ func (c *runtimeReconcile) reconcile(ctx context.Context, cm *operatorapiv1.Clus
 	if config.GRPCAuthEnabled {
 		deployResources = append(deployResources, grpcServerDeploymentFiles...)
 	}
+	var records *rollbackRecords
+	if profile.rollbackEnabled() {
+		if records, err = loadRollbackRecords(c.rollbackConfigMapLister, config.ClusterManagerNamespace, cm.Name); err != nil {
+			appliedErrs = append(appliedErrs, err)
+		}
+	}
+
+	podDisruptionBudgets := sets.New[string]()
+	failedGenerations := map[string]int64{}
 	for _, file := range deployResources {
-		updatedDeployment, currentGeneration, err := helpers.ApplyDeployment(
-			ctx,
-			c.kubeClient,
-			cm.Status.Generations,
-			cm.Spec.NodePlacement,
-			func(name string) ([]byte, error) {
-				template, err := manifests.ClusterManagerManifestFiles.ReadFile(name)
-				if err != nil {
-					return nil, err
-				}
-				objData := assets.MustCreateAssetFromTemplate(name, template, config).Data
-				helpers.SetRelatedResourcesStatusesWithObj(ctx, &cm.Status.RelatedResources, objData)
-				return objData, nil
-			},
-			c.recorder,
-			file)
+		component, leaderElection := profile.component(file)
+		template, err := manifests.ClusterManagerManifestFiles.ReadFile(file)
+		if err != nil {
+			appliedErrs = append(appliedErrs, err)
+			continue
+		}
+		deploymentData := assets.MustCreateAssetFromTemplate(file, template, config).Data
+		if deploymentData, err = applyDeployProfile(deploymentData, component, leaderElection); err != nil {
+			appliedErrs = append(appliedErrs, fmt.Errorf("failed to apply deploy profile to template %s: %w", file, err))
+			continue
+		}
+		if deploymentData, err = patches.Apply(deployComponents[file].name, deploymentData); err != nil {
+			appliedErrs = append(appliedErrs, err)
+			continue
+		}
+		helpers.SetRelatedResourcesStatusesWithObj(ctx, &cm.Status.RelatedResources, deploymentData)
+
+		updatedDeployment, failedGeneration, err := c.applyDeployment(ctx, cm, file, deploymentData, component, records)
 		if err != nil {
 			appliedErrs = append(appliedErrs, err)
 			continue
 		}
-		helpers.SetGenerationStatuses(&cm.Status.Generations, currentGeneration)
+		helpers.SetComponentPatchesGenerationStatus(&cm.Status.Generations, patches, deployComponents[file].name,
+			updatedDeployment.Namespace, updatedDeployment.Name)
+		if failedGeneration > 0 {
+			failedGenerations[updatedDeployment.Name] = failedGeneration
+		}
+
+		pdb, err := c.applyPodDisruptionBudget(ctx, cm, deploymentData, component)
+		if err != nil {
+			appliedErrs = append(appliedErrs, fmt.Errorf("failed to apply PodDisruptionBudget of %q: %v", file, err))
+		}
+		if pdb != nil {
+			podDisruptionBudgets.Insert(pdb.Namespace + "/" + pdb.Name)
+		}
 
 		if updatedDeployment.Generation != updatedDeployment.Status.ObservedGeneration || *updatedDeployment.Spec.Replicas != updatedDeployment.Status.ReadyReplicas {
 			progressingDeployments = append(progressingDeployments, updatedDeployment.Name)
 		}
 	}
 
+	// remove the PodDisruptionBudgets no longer required by the deploy profile, or of the disabled components.
+	if err := c.cleanPodDisruptionBudgets(ctx, cm, podDisruptionBudgets); err != nil {
+		appliedErrs = append(appliedErrs, err)
+	}
+
+	if records != nil {
+		if err := records.save(ctx, c.kubeClient); err != nil {
+			appliedErrs = append(appliedErrs, err)
+		}
+	}
+	if cond := rolledBackCondition(failedGenerations); cond != nil {
+		meta.SetStatusCondition(&cm.Status.Conditions, *cond)
+	} else {
+		meta.RemoveStatusCondition(&cm.Status.Conditions, ConditionHubComponentsRolledBack)
+	}
+
 	if len(progressingDeployments) > 0 {
 		meta.SetStatusCondition(&cm.Status.Conditions, metav1.Condition{
 			Type:    operatorapiv1.ConditionProgressing,
@@ -239,6 +278,51 @@ func (c *runtimeReconcile) clean(ctx context.Context, cm *operatorapiv1.ClusterM
 	return cleanResources(ctx, c.kubeClient, cm, config, managementResources...)
 }
 
+// applyPodDisruptionBudget applies the PodDisruptionBudget of the deployment if it is required by the profile of the
+// component, and records it in the related resources.
+func (c *runtimeReconcile) applyPodDisruptionBudget(ctx context.Context, cm *operatorapiv1.ClusterManager,
+	deploymentData []byte, component componentProfile) (*policyv1.PodDisruptionBudget, error) {
+	pdb, err := podDisruptionBudget(deploymentData, component)
+	if err != nil || pdb == nil {
+		return pdb, err
+	}
+	recorderWrapper := commonrecorder.NewEventsRecorderWrapper(ctx, c.recorder)
+	if _, _, err := resourceapply.ApplyPodDisruptionBudget(ctx, c.kubeClient.PolicyV1(), recorderWrapper, pdb); err != nil {
+		return pdb, err
+	}
+	helpers.SetRelatedResourcesStatuses(&cm.Status.RelatedResources, podDisruptionBudgetResource(pdb.Namespace, pdb.Name))
+	return pdb, nil
+}
+
+// cleanPodDisruptionBudgets deletes the PodDisruptionBudgets in the related resources except the required ones.
+func (c *runtimeReconcile) cleanPodDisruptionBudgets(ctx context.Context, cm *operatorapiv1.ClusterManager,
+	required sets.Set[string]) error {
+	var errs []error
+	for _, resource := range slices.Clone(cm.Status.RelatedResources) {
+		if resource != podDisruptionBudgetResource(resource.Namespace, resource.Name) ||
+			required.Has(resource.Namespace+"/"+resource.Name) {
+			continue
+		}
+		err := c.kubeClient.PolicyV1().PodDisruptionBudgets(resource.Namespace).Delete(ctx, resource.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("failed to delete PodDisruptionBudget %s/%s: %v", resource.Namespace, resource.Name, err))
+			continue
+		}
+		helpers.RemoveRelatedResourcesStatus(&cm.Status.RelatedResources, resource)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func podDisruptionBudgetResource(namespace, name string) operatorapiv1.RelatedResourceMeta {
+	return operatorapiv1.RelatedResourceMeta{
+		Group:     policyv1.GroupName,
+		Version:   policyv1.SchemeGroupVersion.Version,
+		Resource:  "poddisruptionbudgets",
+		Namespace: namespace,
+		Name:      name,
+	}
+}
+
 // getSAs return serviceaccount names of all hub components
 func getSAs(mwctrEnabled, addonManagerEnabled, grpcAuthEnabled bool) []string {
 	sas := []string{
@@ -258,3 +342,37 @@ func getSAs(mwctrEnabled, addonManagerEnabled, grpcAuthEnabled bool) []string {
 	}
 	return sas
 }
+
+// setRegistrationDrivers sets the registration drivers enabled by the cluster manager into the config.
+func setRegistrationDrivers(cm *operatorapiv1.ClusterManager, config *manifests.HubConfig) {
+	if cm.Spec.RegistrationConfiguration != nil && cm.Spec.RegistrationConfiguration.RegistrationDrivers != nil {
+		var enabledRegistrationDrivers []string
+		for _, registrationDriver := range cm.Spec.RegistrationConfiguration.RegistrationDrivers {
+			enabledRegistrationDrivers = append(enabledRegistrationDrivers, registrationDriver.AuthType)
+			switch registrationDriver.AuthType {
+			case operatorapiv1.AwsIrsaAuthType:
+				if registrationDriver.AwsIrsa != nil {
+					config.HubClusterArn = registrationDriver.AwsIrsa.HubClusterArn
+					config.AutoApprovedARNPatterns = strings.Join(registrationDriver.AwsIrsa.AutoApprovedIdentities, ",")
+					config.AwsResourceTags = strings.Join(registrationDriver.AwsIrsa.Tags, ",")
+				}
+			case operatorapiv1.CSRAuthType:
+				if registrationDriver.CSR != nil {
+					config.AutoApprovedCSRUsers = strings.Join(registrationDriver.CSR.AutoApprovedIdentities, ",")
+				}
+			case operatorapiv1.GRPCAuthType:
+				if registrationDriver.GRPC != nil {
+					config.GRPCAutoApprovedUsers = strings.Join(registrationDriver.GRPC.AutoApprovedIdentities, ",")
+				}
+
+				// Prefer ServerConfiguration image when set; otherwise fall back to registration image.
+				if sc := cm.Spec.ServerConfiguration; sc == nil || strings.TrimSpace(sc.ImagePullSpec) == "" {
+					config.GRPCServerImage = cm.Spec.RegistrationImagePullSpec
+				} else {
+					config.GRPCServerImage = sc.ImagePullSpec
+				}
+			}
+		}
+		config.EnabledRegistrationDrivers = strings.Join(enabledRegistrationDrivers, ",")
+	}
+}
@@ -31,9 +31,25 @@ var (
 		"cluster-manager/hub/registration/webhook-mutatingconfiguration.yaml",
 		"cluster-manager/hub/registration/webhook-clustersetbinding-validatingconfiguration.yaml",
 	}
+	// The revocation webhook is only served if the ClientCertificateRevocation feature gate is enabled.
+	hubRevocationWebhookResourceFiles = []string{
+		"cluster-manager/hub/registration/webhook-revocation-validatingconfiguration.yaml",
+	}
 	hubWorkWebhookResourceFiles = []string{
 		"cluster-manager/hub/work/webhook-validatingconfiguration.yaml",
 	}
+	// Note: addon webhook is not supported in hosted mode, it is reported by the AddOnWebhookSupported condition.
+	hubAddonWebhookResourceFiles = []string{
+		"cluster-manager/hub/addon-manager/webhook-validatingconfiguration.yaml",
+	}
+)
+
+const (
+	// ConditionAddOnWebhookSupported is false if the addon manager is enabled in hosted mode, where the addon webhook
+	// is not registered, so the addon templates are not validated and the ManagedClusterAddOns depending on by the
+	// other addons are not protected from the deletion.
+	ConditionAddOnWebhookSupported = "AddOnWebhookSupported"
+	reasonHostedModeUnsupported    = "HostedModeUnsupported"
 )
 
 type webhookReconcile struct {
@@ -48,12 +64,37 @@ func (c *webhookReconcile) reconcile(ctx context.Context, cm *operatorapiv1.Clus
 	config manifests.HubConfig) (*operatorapiv1.ClusterManager, reconcileState, error) {
 	var appliedErrs []error
 
+	if config.HostedMode && config.AddOnManagerEnabled {
+		meta.SetStatusCondition(&cm.Status.Conditions, metav1.Condition{
+			Type:   ConditionAddOnWebhookSupported,
+			Status: metav1.ConditionFalse,
+			Reason: reasonHostedModeUnsupported,
+			Message: "The addon webhook is not supported in hosted mode, the addon templates are not validated and " +
+				"the addons depending on by the other addons can be deleted",
+		})
+	} else {
+		meta.RemoveStatusCondition(&cm.Status.Conditions, ConditionAddOnWebhookSupported)
+	}
+
 	if !meta.IsStatusConditionFalse(cm.Status.Conditions, operatorapiv1.ConditionProgressing) {
 		return cm, reconcileStop, commonhelpers.NewRequeueError("Deployment is not ready", clusterManagerReSyncTime)
 	}
 
+	if !config.ClientCertificateRevocationEnabled {
+		_, _, err := cleanResources(ctx, c.hubKubeClient, cm, config, hubRevocationWebhookResourceFiles...)
+		if err != nil {
+			return cm, reconcileStop, err
+		}
+	}
+
 	webhookResources := hubRegistrationWebhookResourceFiles
 	webhookResources = append(webhookResources, hubWorkWebhookResourceFiles...)
+	if config.ClientCertificateRevocationEnabled {
+		webhookResources = append(webhookResources, hubRevocationWebhookResourceFiles...)
+	}
+	if !config.HostedMode {
+		webhookResources = append(webhookResources, hubAddonWebhookResourceFiles...)
+	}
 	// If all webhook pod running , then apply webhook config files
 	resourceResults := helpers.ApplyDirectly(
 		ctx,
@@ -97,5 +138,7 @@ func (c *webhookReconcile) clean(ctx context.Context, cm *operatorapiv1.ClusterM
 	// Remove All webhook files
 	webhookResources := hubRegistrationWebhookResourceFiles
 	webhookResources = append(webhookResources, hubWorkWebhookResourceFiles...)
+	webhookResources = append(webhookResources, hubRevocationWebhookResourceFiles...)
+	webhookResources = append(webhookResources, hubAddonWebhookResourceFiles...)
 	return cleanResources(ctx, c.kubeClient, cm, config, webhookResources...)
 }
@@ -0,0 +1,62 @@
+package clustermanagercontroller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+
+	operatorapiv1 "open-cluster-management.io/api/operator/v1"
+
+	"open-cluster-management.io/ocm/manifests"
+)
+
+func TestAddOnWebhookSupportedCondition(t *testing.T) {
+	cases := []struct {
+		name                string
+		hostedMode          bool
+		addOnManagerEnabled bool
+		expectedUnsupported bool
+	}{
+		{
+			name:                "default mode",
+			addOnManagerEnabled: true,
+		},
+		{
+			name:       "hosted mode without addon manager",
+			hostedMode: true,
+		},
+		{
+			name:                "hosted mode with addon manager",
+			hostedMode:          true,
+			addOnManagerEnabled: true,
+			expectedUnsupported: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cm := newClusterManager("testhub")
+			meta.SetStatusCondition(&cm.Status.Conditions, metav1.Condition{
+				Type: ConditionAddOnWebhookSupported, Status: metav1.ConditionFalse, Reason: reasonHostedModeUnsupported,
+			})
+			// the webhook resources are not applied until the hub components are rolled out
+			meta.SetStatusCondition(&cm.Status.Conditions, metav1.Condition{
+				Type: operatorapiv1.ConditionProgressing, Status: metav1.ConditionTrue, Reason: "Progressing",
+			})
+
+			r := &webhookReconcile{kubeClient: fakekube.NewSimpleClientset(), hubKubeClient: fakekube.NewSimpleClientset()}
+			cm, _, _ = r.reconcile(context.TODO(), cm, manifests.HubConfig{
+				HostedMode:          c.hostedMode,
+				AddOnManagerEnabled: c.addOnManagerEnabled,
+			})
+
+			unsupported := meta.IsStatusConditionFalse(cm.Status.Conditions, ConditionAddOnWebhookSupported)
+			if unsupported != c.expectedUnsupported {
+				t.Errorf("expected addon webhook unsupported %v, but got conditions %v", c.expectedUnsupported, cm.Status.Conditions)
+			}
+		})
+	}
+}
@@ -35,6 +35,7 @@ import (
 	"open-cluster-management.io/ocm/manifests"
 	"open-cluster-management.io/ocm/pkg/common/queue"
 	"open-cluster-management.io/ocm/pkg/operator/helpers"
+	"open-cluster-management.io/ocm/pkg/operator/operators/crdmanager"
 )
 
 var (
@@ -137,8 +138,10 @@ func (c *crdMigrationController) sync(ctx context.Context, controllerContext fac
 		return err
 	}
 
-	// do not apply storage version migrations until other resources are applied
-	if applied := meta.IsStatusConditionTrue(clusterManager.Status.Conditions, operatorapiv1.ConditionClusterManagerApplied); !applied {
+	// do not apply storage version migrations until other resources are applied, unless the crd update is blocked
+	// to wait for the migrations.
+	appliedCond := meta.FindStatusCondition(clusterManager.Status.Conditions, operatorapiv1.ConditionClusterManagerApplied)
+	if appliedCond == nil || (appliedCond.Status != metav1.ConditionTrue && appliedCond.Reason != crdmanager.ReasonCRDUpdateBlocked) {
 		controllerContext.Queue().AddRateLimited(clusterManagerName)
 		return nil
 	}
@@ -3,6 +3,7 @@ package statuscontroller
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -22,6 +23,32 @@ import (
 	"open-cluster-management.io/ocm/pkg/operator/helpers"
 )
 
+const (
+	// ConditionHubWorkDegraded means the work webhook or the work controller is not ready to serve on the hub.
+	ConditionHubWorkDegraded = "HubWorkDegraded"
+	// ConditionHubAddOnManagerDegraded means the addon webhook or the addon manager is not ready to serve on the hub.
+	ConditionHubAddOnManagerDegraded = "HubAddOnManagerDegraded"
+	// ConditionHubGRPCServerDegraded means the grpc server is not ready to serve on the hub.
+	ConditionHubGRPCServerDegraded = "HubGRPCServerDegraded"
+
+	reasonUnavailablePods     = "UnavailablePods"
+	reasonComponentFunctional = "ComponentFunctional"
+)
+
+// hubComponent is a hub component whose availability is reported by its degraded condition. The components may be
+// disabled, so the condition is only reported when some deployments of the component exist.
+type hubComponent struct {
+	conditionType string
+	// deployments are the names of the deployments of the component without the cluster manager name prefix.
+	deployments []string
+}
+
+var optionalHubComponents = []hubComponent{
+	{conditionType: ConditionHubWorkDegraded, deployments: []string{"work-webhook", "work-controller"}},
+	{conditionType: ConditionHubAddOnManagerDegraded, deployments: []string{"addon-webhook", "addon-manager-controller"}},
+	{conditionType: ConditionHubGRPCServerDegraded, deployments: []string{"grpc-server"}},
+}
+
 type clusterManagerStatusController struct {
 	deploymentLister     appslister.DeploymentLister
 	patcher              patcher.Patcher[*operatorapiv1.ClusterManager, operatorapiv1.ClusterManagerSpec, operatorapiv1.ClusterManagerStatus]
@@ -78,6 +105,18 @@ func (s *clusterManagerStatusController) sync(ctx context.Context, controllerCon
 	placementCond := s.updateStatusOfPlacement(clusterManager.Name, clusterManagerNamespace)
 	placementCond.ObservedGeneration = clusterManager.Generation
 	meta.SetStatusCondition(&newClusterManager.Status.Conditions, placementCond)
+	for _, component := range optionalHubComponents {
+		cond, err := s.componentCondition(clusterManager.Name, clusterManagerNamespace, component)
+		if err != nil {
+			return err
+		}
+		if cond == nil {
+			meta.RemoveStatusCondition(&newClusterManager.Status.Conditions, component.conditionType)
+			continue
+		}
+		cond.ObservedGeneration = clusterManager.Generation
+		meta.SetStatusCondition(&newClusterManager.Status.Conditions, *cond)
+	}
 
 	_, err = s.patcher.PatchStatus(ctx, newClusterManager, newClusterManager.Status, clusterManager.Status)
 	return err
@@ -152,3 +191,46 @@ func (s *clusterManagerStatusController) updateStatusOfPlacement(clusterManagerN
 		Message: "Placement is scheduling placement decisions",
 	}
 }
+
+// componentCondition checks the deployments of the component and returns its degraded condition, or nil if none of
+// the deployments exist.
+func (s *clusterManagerStatusController) componentCondition(
+	clusterManagerName, clusterManagerNamespace string, component hubComponent) (*metav1.Condition, error) {
+	var found int
+	var unavailable []string
+	for _, deployment := range component.deployments {
+		deploymentName := fmt.Sprintf("%s-%s", clusterManagerName, deployment)
+		componentDeployment, err := s.deploymentLister.Deployments(clusterManagerNamespace).Get(deploymentName)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found++
+
+		if unavailablePod := helpers.NumOfUnavailablePod(componentDeployment); unavailablePod > 0 {
+			unavailable = append(unavailable, fmt.Sprintf("%d of %d replicas of deployment %q %q are unavailable",
+				unavailablePod, unavailablePod+componentDeployment.Status.AvailableReplicas,
+				clusterManagerNamespace, deploymentName))
+		}
+	}
+
+	switch {
+	case found == 0:
+		return nil, nil
+	case len(unavailable) > 0:
+		return &metav1.Condition{
+			Type:    component.conditionType,
+			Status:  metav1.ConditionTrue,
+			Reason:  reasonUnavailablePods,
+			Message: strings.Join(unavailable, "; "),
+		}, nil
+	}
+	return &metav1.Condition{
+		Type:    component.conditionType,
+		Status:  metav1.ConditionFalse,
+		Reason:  reasonComponentFunctional,
+		Message: "All replicas are available",
+	}, nil
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	kubeinformers "k8s.io/client-go/informers"
@@ -89,6 +91,21 @@ func newPlacementDeployment(desiredReplica, availableReplica int32) *appsv1.Depl
 	}
 }
 
+func newHubDeployment(name string, desiredReplica, availableReplica int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s", testClusterManagerName, name),
+			Namespace: "open-cluster-management-hub",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &desiredReplica,
+		},
+		Status: appsv1.DeploymentStatus{
+			AvailableReplicas: availableReplica,
+		},
+	}
+}
+
 func TestSyncStatus(t *testing.T) {
 	appliedCond := metav1.Condition{
 		Type:   operatorapiv1.ConditionClusterManagerApplied,
@@ -200,6 +217,39 @@ func TestSyncStatus(t *testing.T) {
 				testinghelper.AssertOnlyConditions(t, klusterlet, appliedCond, expectedCondition1, expectedCondition2)
 			},
 		},
+		{
+			name:            "unavailable work controller pods and addon manager functional",
+			queueKey:        testClusterManagerName,
+			clusterManagers: []runtime.Object{newClusterManager()},
+			deployments: []runtime.Object{
+				newRegistrationDeployment(3, 3),
+				newRegistrationWebhookDeployment(3, 3),
+				newPlacementDeployment(3, 3),
+				newHubDeployment("work-webhook", 3, 3),
+				newHubDeployment("work-controller", 3, 1),
+				newHubDeployment("addon-webhook", 3, 3),
+				newHubDeployment("addon-manager-controller", 3, 3),
+			},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				klusterlet := &operatorapiv1.Klusterlet{}
+				patchData := actions[0].(clienttesting.PatchActionImpl).Patch
+				err := json.Unmarshal(patchData, klusterlet)
+				if err != nil {
+					t.Fatal(err)
+				}
+				expectedCondition1 := testinghelper.NamedCondition(operatorapiv1.ConditionHubRegistrationDegraded, "RegistrationFunctional", metav1.ConditionFalse)
+				expectedCondition2 := testinghelper.NamedCondition(operatorapiv1.ConditionHubPlacementDegraded, "PlacementFunctional", metav1.ConditionFalse)
+				expectedCondition3 := testinghelper.NamedCondition(ConditionHubWorkDegraded, "UnavailablePods", metav1.ConditionTrue)
+				expectedCondition4 := testinghelper.NamedCondition(ConditionHubAddOnManagerDegraded, "ComponentFunctional", metav1.ConditionFalse)
+				testinghelper.AssertOnlyConditions(t, klusterlet, appliedCond, expectedCondition1, expectedCondition2,
+					expectedCondition3, expectedCondition4)
+				cond := meta.FindStatusCondition(klusterlet.Status.Conditions, ConditionHubWorkDegraded)
+				if !strings.Contains(cond.Message, "2 of 3 replicas") {
+					t.Errorf("expected the unavailable replicas are reported, but got %q", cond.Message)
+				}
+			},
+		},
 	}
 
 	for _, c := range cases {
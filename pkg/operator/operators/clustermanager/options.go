@@ -8,6 +8,7 @@ import (
 	"github.com/openshift/library-go/pkg/controller/controllercmd"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	corev1informers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
@@ -16,6 +17,7 @@ import (
 	operatorclient "open-cluster-management.io/api/client/operator/clientset/versioned"
 	operatorinformer "open-cluster-management.io/api/client/operator/informers/externalversions"
 
+	commonhelpers "open-cluster-management.io/ocm/pkg/common/helpers"
 	"open-cluster-management.io/ocm/pkg/operator/helpers"
 	"open-cluster-management.io/ocm/pkg/operator/operators/clustermanager/controllers/certrotationcontroller"
 	"open-cluster-management.io/ocm/pkg/operator/operators/clustermanager/controllers/clustermanagercontroller"
@@ -47,6 +49,11 @@ func (o *Options) RunClusterManagerOperator(ctx context.Context, controllerConte
 		return err
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(controllerContext.KubeConfig)
+	if err != nil {
+		return err
+	}
+
 	newOneTermInformer := func(name string) informers.SharedInformerFactory {
 		return informers.NewSharedInformerFactoryWithOptions(kubeClient, 5*time.Minute,
 			informers.WithTweakListOptions(func(options *metav1.ListOptions) {
@@ -59,7 +66,15 @@ func (o *Options) RunClusterManagerOperator(ctx context.Context, controllerConte
 	workSecretInformer := newOneTermInformer(helpers.WorkWebhookSecret)
 	addonSecretInformer := newOneTermInformer(helpers.AddonWebhookSecret)
 	grpcServerSecretInformer := newOneTermInformer(helpers.GRPCServerSecret)
+	signerCASecretInformer := newOneTermInformer(helpers.SignerCASecret)
+	webhookCASecretInformer := newOneTermInformer(helpers.WebhookCASecret)
+	grpcServerCASecretInformer := newOneTermInformer(helpers.GRPCServerCASecret)
 	configmapInformer := newOneTermInformer(helpers.CaBundleConfigmap)
+	inventoryInformer := newOneTermInformer(commonhelpers.AgentVersionInventoryConfigMap)
+	rollbackConfigMapInformer := informers.NewSharedInformerFactoryWithOptions(kubeClient, 5*time.Minute,
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = helpers.RollbackLabelKey
+		}))
 
 	deploymentInformer := informers.NewSharedInformerFactoryWithOptions(kubeClient, 5*time.Minute,
 		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
@@ -80,6 +95,15 @@ func (o *Options) RunClusterManagerOperator(ctx context.Context, controllerConte
 		helpers.WorkWebhookSecret:         workSecretInformer.Core().V1().Secrets(),
 		helpers.AddonWebhookSecret:        addonSecretInformer.Core().V1().Secrets(),
 		helpers.GRPCServerSecret:          grpcServerSecretInformer.Core().V1().Secrets(),
+		helpers.SignerCASecret:            signerCASecretInformer.Core().V1().Secrets(),
+		helpers.WebhookCASecret:           webhookCASecretInformer.Core().V1().Secrets(),
+		helpers.GRPCServerCASecret:        grpcServerCASecretInformer.Core().V1().Secrets(),
+	}
+
+	// the cert-manager certificates are only watched if cert-manager is installed when the operator starts.
+	certificateInformer, err := certrotationcontroller.NewCertificateInformer(kubeClient.Discovery(), dynamicClient)
+	if err != nil {
+		return err
 	}
 
 	// Build operator client and informer
@@ -96,6 +120,8 @@ func (o *Options) RunClusterManagerOperator(ctx context.Context, controllerConte
 		operatorInformer.Operator().V1().ClusterManagers(),
 		deploymentInformer.Apps().V1().Deployments(),
 		configmapInformer.Core().V1().ConfigMaps(),
+		rollbackConfigMapInformer.Core().V1().ConfigMaps(),
+		inventoryInformer.Core().V1().ConfigMaps(),
 		o.SkipRemoveCRDs,
 		o.ControlPlaneNodeLabelSelector,
 		o.DeploymentReplicas,
@@ -110,8 +136,11 @@ func (o *Options) RunClusterManagerOperator(ctx context.Context, controllerConte
 
 	certRotationController := certrotationcontroller.NewCertRotationController(
 		kubeClient,
+		dynamicClient,
+		operatorClient.OperatorV1().ClusterManagers(),
 		secretInformers,
 		configmapInformer.Core().V1().ConfigMaps(),
+		certificateInformer,
 		operatorInformer.Operator().V1().ClusterManagers())
 
 	crdMigrationController := migrationcontroller.NewCRDMigrationController(
@@ -132,7 +161,15 @@ func (o *Options) RunClusterManagerOperator(ctx context.Context, controllerConte
 	go workSecretInformer.Start(ctx.Done())
 	go addonSecretInformer.Start(ctx.Done())
 	go grpcServerSecretInformer.Start(ctx.Done())
+	go signerCASecretInformer.Start(ctx.Done())
+	go webhookCASecretInformer.Start(ctx.Done())
+	go grpcServerCASecretInformer.Start(ctx.Done())
+	if certificateInformer != nil {
+		go certificateInformer.Informer().Run(ctx.Done())
+	}
 	go configmapInformer.Start(ctx.Done())
+	go rollbackConfigMapInformer.Start(ctx.Done())
+	go inventoryInformer.Start(ctx.Done())
 	go clusterManagerController.Run(ctx, 1)
 	go statusController.Run(ctx, 1)
 	go certRotationController.Run(ctx, 1)
@@ -0,0 +1,325 @@
+package crdmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/rest"
+	migrationv1alpha1 "sigs.k8s.io/kube-storage-version-migrator/pkg/apis/migration/v1alpha1"
+	migrationv1alpha1client "sigs.k8s.io/kube-storage-version-migrator/pkg/clients/clientset/typed/migration/v1alpha1"
+)
+
+// ReasonCRDUpdateBlocked is the reason of the applied condition when a crd update is blocked since it is not
+// compatible with the objects stored in the cluster.
+const ReasonCRDUpdateBlocked = "CRDUpdateBlocked"
+
+const (
+	// objectListPageSize is the page size to list the objects of a crd.
+	objectListPageSize = 500
+	// fieldUsageCacheTTL is how long the removed fields in use of a crd generation are cached.
+	fieldUsageCacheTTL = 30 * time.Minute
+)
+
+// ErrUnsafeUpdate is returned when a crd update is blocked since it is not compatible with the objects stored in the
+// cluster. Use errors.Is to check it on the error returned by Apply.
+var ErrUnsafeUpdate = errors.New("unsafe crd update")
+
+// ObjectLister lists the objects of a resource in the given version, it is used to find whether the fields removed
+// from the schema of the crd are still used by the existing objects. The objects are passed to visit page by page so
+// they are not loaded into memory at once, and the listing stops once visit returns false.
+type ObjectLister func(ctx context.Context, gvr schema.GroupVersionResource,
+	visit func(objects []unstructured.Unstructured) bool) error
+
+// MigrationChecker returns true if the objects of the resource stored in the given version have been migrated to
+// the storage version of the crd.
+type MigrationChecker func(ctx context.Context, gvr schema.GroupVersionResource) (bool, error)
+
+// NewRESTObjectLister returns an ObjectLister with a rest client of the cluster, e.g. the rest client of the
+// apiextensions client, so no dynamic client is required.
+func NewRESTObjectLister(client rest.Interface) ObjectLister {
+	return func(ctx context.Context, gvr schema.GroupVersionResource,
+		visit func(objects []unstructured.Unstructured) bool) error {
+		continueToken := ""
+		for {
+			request := client.Get().AbsPath("/apis", gvr.Group, gvr.Version, gvr.Resource).
+				Param("limit", strconv.Itoa(objectListPageSize))
+			if len(continueToken) > 0 {
+				request = request.Param("continue", continueToken)
+			}
+			data, err := request.Do(ctx).Raw()
+			if err != nil {
+				return err
+			}
+			list := &unstructured.UnstructuredList{}
+			if err := list.UnmarshalJSON(data); err != nil {
+				return err
+			}
+			if !visit(list.Items) {
+				return nil
+			}
+			if continueToken = list.GetContinue(); len(continueToken) == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// FieldUsageCache caches the removed fields found in use by the existing objects per crd generation, so the objects
+// are not listed on every reconcile. It is shared by the reconciles of a controller, and an entry expires after
+// fieldUsageCacheTTL since the objects may stop using the removed fields.
+type FieldUsageCache struct {
+	lock    sync.Mutex
+	entries map[string]fieldUsage
+}
+
+type fieldUsage struct {
+	unsafeReasons []string
+	expiry        time.Time
+}
+
+func NewFieldUsageCache() *FieldUsageCache {
+	return &FieldUsageCache{entries: map[string]fieldUsage{}}
+}
+
+func (c *FieldUsageCache) get(key string) ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	usage, ok := c.entries[key]
+	if !ok || time.Now().After(usage.expiry) {
+		return nil, false
+	}
+	return usage.unsafeReasons, true
+}
+
+func (c *FieldUsageCache) set(key string, unsafeReasons []string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now()
+	// the entries of the previous crd generations are never hit again, they are removed once expired.
+	for k, usage := range c.entries {
+		if now.After(usage.expiry) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = fieldUsage{unsafeReasons: unsafeReasons, expiry: now.Add(fieldUsageCacheTTL)}
+}
+
+// NewStorageVersionMigrationChecker returns a MigrationChecker which checks whether a StorageVersionMigration
+// migrating the objects from the given version has succeeded.
+func NewStorageVersionMigrationChecker(client migrationv1alpha1client.StorageVersionMigrationsGetter) MigrationChecker {
+	return func(ctx context.Context, gvr schema.GroupVersionResource) (bool, error) {
+		migrations, err := client.StorageVersionMigrations().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, migration := range migrations.Items {
+			resource := migration.Spec.Resource
+			if resource.Group != gvr.Group || resource.Resource != gvr.Resource || resource.Version != gvr.Version {
+				continue
+			}
+			for _, cond := range migration.Status.Conditions {
+				if cond.Type == migrationv1alpha1.MigrationSucceeded && cond.Status == corev1.ConditionTrue {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	}
+}
+
+// compatibility is the result of the compatibility analysis of a crd update.
+type compatibility struct {
+	// prunableVersions are the stored versions removed by the update whose objects have been migrated, they are
+	// removed from the stored versions of the crd before the update.
+	prunableVersions []string
+	// unsafeReasons are why the update is not safe.
+	unsafeReasons []string
+}
+
+// checkCompatibility checks whether the required crd is compatible with the objects stored with the existing crd:
+//  1. a stored version can be removed only if its objects have been migrated to the storage version.
+//  2. a field can be removed from the schema only if it is not used by any existing object.
+func (m *Manager[T]) checkCompatibility(ctx context.Context, existing, required *apiextensionsv1.CustomResourceDefinition) (
+	*compatibility, error) {
+	result := &compatibility{}
+
+	requiredVersions := map[string]*apiextensionsv1.CustomResourceDefinitionVersion{}
+	for i := range required.Spec.Versions {
+		requiredVersions[required.Spec.Versions[i].Name] = &required.Spec.Versions[i]
+	}
+	storedVersions := sets.New[string](existing.Status.StoredVersions...)
+
+	for _, existingVersion := range existing.Spec.Versions {
+		gvr := schema.GroupVersionResource{
+			Group:    existing.Spec.Group,
+			Version:  existingVersion.Name,
+			Resource: existing.Spec.Names.Plural,
+		}
+
+		requiredVersion, ok := requiredVersions[existingVersion.Name]
+		if !ok {
+			if !storedVersions.Has(existingVersion.Name) {
+				continue
+			}
+			migrated := false
+			if m.migrationChecker != nil && !existingVersion.Storage {
+				var err error
+				if migrated, err = m.migrationChecker(ctx, gvr); err != nil {
+					return nil, err
+				}
+			}
+			if !migrated {
+				result.unsafeReasons = append(result.unsafeReasons, fmt.Sprintf(
+					"stored version %s is removed before its objects are migrated to the storage version", existingVersion.Name))
+				continue
+			}
+			result.prunableVersions = append(result.prunableVersions, existingVersion.Name)
+			continue
+		}
+
+		removedFields := removedFieldPaths(schemaOf(&existingVersion), schemaOf(requiredVersion))
+		if len(removedFields) == 0 || m.objectLister == nil || !existingVersion.Served {
+			continue
+		}
+		unsafeReasons, err := m.removedFieldsInUse(ctx, existing, gvr, removedFields)
+		if err != nil {
+			return nil, err
+		}
+		result.unsafeReasons = append(result.unsafeReasons, unsafeReasons...)
+	}
+
+	return result, nil
+}
+
+// removedFieldsInUse returns the unsafe reasons of the removed fields used by the existing objects of the version.
+func (m *Manager[T]) removedFieldsInUse(ctx context.Context, existing *apiextensionsv1.CustomResourceDefinition,
+	gvr schema.GroupVersionResource, removedFields []string) ([]string, error) {
+	key := fmt.Sprintf("%s/%d/%s/%s", existing.UID, existing.Generation, gvr.Version, strings.Join(removedFields, ","))
+	if unsafeReasons, ok := m.fieldUsageCache.get(key); ok {
+		return unsafeReasons, nil
+	}
+
+	var unsafeReasons []string
+	remaining := sets.New[string](removedFields...)
+	err := m.objectLister(ctx, gvr, func(objects []unstructured.Unstructured) bool {
+		for _, field := range sets.List(remaining) {
+			for _, obj := range objects {
+				if fieldInUse(obj.Object, strings.Split(field, ".")) {
+					unsafeReasons = append(unsafeReasons, fmt.Sprintf(
+						"field %s of version %s is removed but used by %s", field, gvr.Version, objectKey(obj)))
+					remaining.Delete(field)
+					break
+				}
+			}
+		}
+		return remaining.Len() > 0
+	})
+	if apierrors.IsForbidden(err) {
+		// the fields in use cannot be checked without the permission, the update is blocked until it is granted.
+		return []string{fmt.Sprintf("fields %s of version %s are removed but the objects cannot be listed to check them: %v",
+			strings.Join(removedFields, ", "), gvr.Version, err)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s to check the removed fields: %w", gvr, err)
+	}
+
+	sort.Strings(unsafeReasons)
+	m.fieldUsageCache.set(key, unsafeReasons)
+	return unsafeReasons, nil
+}
+
+func schemaOf(version *apiextensionsv1.CustomResourceDefinitionVersion) *apiextensionsv1.JSONSchemaProps {
+	if version.Schema == nil {
+		return nil
+	}
+	return version.Schema.OpenAPIV3Schema
+}
+
+// removedFieldPaths returns the paths of the fields in the existing schema that are pruned by the required schema.
+// The items of an array are represented by [] in the path.
+func removedFieldPaths(existing, required *apiextensionsv1.JSONSchemaProps) []string {
+	var removed []string
+	var walk func(path []string, existing, required *apiextensionsv1.JSONSchemaProps)
+	walk = func(path []string, existing, required *apiextensionsv1.JSONSchemaProps) {
+		// the unknown fields are kept, so nothing under it is removed.
+		if existing == nil || required == nil ||
+			(required.XPreserveUnknownFields != nil && *required.XPreserveUnknownFields) {
+			return
+		}
+		for name := range existing.Properties {
+			existingProp := existing.Properties[name]
+			fieldPath := append(append([]string{}, path...), name)
+			requiredProp, ok := required.Properties[name]
+			if !ok {
+				removed = append(removed, strings.Join(fieldPath, "."))
+				continue
+			}
+			walk(fieldPath, &existingProp, &requiredProp)
+		}
+		if existing.Items != nil && existing.Items.Schema != nil && required.Items != nil && required.Items.Schema != nil {
+			walk(append(append([]string{}, path...), "[]"), existing.Items.Schema, required.Items.Schema)
+		}
+	}
+	walk(nil, existing, required)
+	sort.Strings(removed)
+	return removed
+}
+
+// fieldInUse returns true if the field on the path is set in the object.
+func fieldInUse(obj interface{}, path []string) bool {
+	if len(path) == 0 {
+		return true
+	}
+	if path[0] == "[]" {
+		items, ok := obj.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, item := range items {
+			if fieldInUse(item, path[1:]) {
+				return true
+			}
+		}
+		return false
+	}
+	fields, ok := obj.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	value, ok := fields[path[0]]
+	if !ok {
+		return false
+	}
+	return fieldInUse(value, path[1:])
+}
+
+func objectKey(obj unstructured.Unstructured) string {
+	if len(obj.GetNamespace()) == 0 {
+		return obj.GetName()
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// unsafeUpdateError returns the error of a blocked crd update.
+func unsafeUpdateError(name string, reasons []string) error {
+	return fmt.Errorf("%w of %s: %s", ErrUnsafeUpdate, name, strings.Join(reasons, "; "))
+}
@@ -0,0 +1,379 @@
+package crdmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	fakeapiextensions "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	versionutil "k8s.io/apimachinery/pkg/util/version"
+	clienttesting "k8s.io/client-go/testing"
+	migrationv1alpha1 "sigs.k8s.io/kube-storage-version-migrator/pkg/apis/migration/v1alpha1"
+	fakemigrationclient "sigs.k8s.io/kube-storage-version-migrator/pkg/clients/clientset/fake"
+
+	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
+)
+
+func TestApplyIncompatibleCRD(t *testing.T) {
+	cases := []struct {
+		name             string
+		existingCRD      *apiextensionsv1.CustomResourceDefinition
+		requiredCRD      *apiextensionsv1.CustomResourceDefinition
+		objects          []unstructured.Unstructured
+		listErr          error
+		migrationChecker MigrationChecker
+		expectBlocked    bool
+		verify           func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name:          "stored version removed without migration checker",
+			existingCRD:   newVersionedCRD("0.8.0", []string{"v1alpha1", "v1beta1"}, "v1alpha1", "v1beta1"),
+			requiredCRD:   newVersionedCRD("", nil, "v1beta1"),
+			expectBlocked: true,
+			verify: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "get")
+			},
+		},
+		{
+			name:        "stored version removed before migrated",
+			existingCRD: newVersionedCRD("0.8.0", []string{"v1alpha1", "v1beta1"}, "v1alpha1", "v1beta1"),
+			requiredCRD: newVersionedCRD("", nil, "v1beta1"),
+			migrationChecker: func(ctx context.Context, gvr schema.GroupVersionResource) (bool, error) {
+				return false, nil
+			},
+			expectBlocked: true,
+			verify: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "get")
+			},
+		},
+		{
+			name:        "stored version removed after migrated",
+			existingCRD: newVersionedCRD("0.8.0", []string{"v1alpha1", "v1beta1"}, "v1alpha1", "v1beta1"),
+			requiredCRD: newVersionedCRD("", nil, "v1beta1"),
+			migrationChecker: func(ctx context.Context, gvr schema.GroupVersionResource) (bool, error) {
+				return gvr.Version == "v1alpha1", nil
+			},
+			verify: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "get", "update", "update")
+				if actions[1].GetSubresource() != "status" {
+					t.Errorf("expect the stored versions are pruned first, but got %v", actions[1])
+				}
+				crd := actions[1].(clienttesting.UpdateActionImpl).Object.(*apiextensionsv1.CustomResourceDefinition)
+				if !reflect.DeepEqual(crd.Status.StoredVersions, []string{"v1beta1"}) {
+					t.Errorf("unexpected stored versions %v", crd.Status.StoredVersions)
+				}
+			},
+		},
+		{
+			name:        "removed version not stored",
+			existingCRD: newVersionedCRD("0.8.0", []string{"v1beta1"}, "v1alpha1", "v1beta1"),
+			requiredCRD: newVersionedCRD("", nil, "v1beta1"),
+			verify: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "get", "update")
+			},
+		},
+		{
+			name:        "removed field in use",
+			existingCRD: newVersionedCRD("0.8.0", []string{"v1beta1"}, "v1beta1"),
+			requiredCRD: removeField(newVersionedCRD("", nil, "v1beta1"), "bar"),
+			objects: []unstructured.Unstructured{
+				newObject("test1", map[string]interface{}{"foo": "a"}),
+				newObject("test2", map[string]interface{}{"foo": "a", "bar": []interface{}{"b"}}),
+			},
+			expectBlocked: true,
+			verify: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "get")
+			},
+		},
+		{
+			name:        "removed field cannot be checked without permission",
+			existingCRD: newVersionedCRD("0.8.0", []string{"v1beta1"}, "v1beta1"),
+			requiredCRD: removeField(newVersionedCRD("", nil, "v1beta1"), "bar"),
+			listErr: apierrors.NewForbidden(
+				schema.GroupResource{Group: "test.io", Resource: "foos"}, "", errors.New("not allowed")),
+			expectBlocked: true,
+			verify: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "get")
+			},
+		},
+		{
+			name:        "removed field not in use",
+			existingCRD: newVersionedCRD("0.8.0", []string{"v1beta1"}, "v1beta1"),
+			requiredCRD: removeField(newVersionedCRD("", nil, "v1beta1"), "bar"),
+			objects: []unstructured.Unstructured{
+				newObject("test1", map[string]interface{}{"foo": "a"}),
+			},
+			verify: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "get", "update")
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client := fakeapiextensions.NewSimpleClientset(c.existingCRD)
+			manager := NewManager[*apiextensionsv1.CustomResourceDefinition](
+				client.ApiextensionsV1().CustomResourceDefinitions(), EqualV1).
+				WithObjectLister(newPagedObjectLister(c.objects, c.listErr, nil)).
+				WithMigrationChecker(c.migrationChecker)
+			v, _ := versionutil.ParseSemantic("v0.9.0")
+			manager.version = v
+
+			err := manager.Apply(context.TODO(), func(index string) ([]byte, error) {
+				return json.Marshal(c.requiredCRD)
+			}, "0")
+			if c.expectBlocked && !errors.Is(err, ErrUnsafeUpdate) {
+				t.Errorf("expect the update is blocked, but got %v", err)
+			}
+			if !c.expectBlocked && err != nil {
+				t.Errorf("apply error: %v", err)
+			}
+
+			c.verify(t, client.Actions())
+		})
+	}
+}
+
+func TestFieldUsageCache(t *testing.T) {
+	existingCRD := newVersionedCRD("0.8.0", []string{"v1beta1"}, "v1beta1")
+	requiredCRD := removeField(removeField(newVersionedCRD("", nil, "v1beta1"), "bar"), "foo")
+	objects := []unstructured.Unstructured{
+		newObject("test1", map[string]interface{}{"foo": "a"}),
+		newObject("test2", map[string]interface{}{"foo": "a", "bar": []interface{}{"b"}}),
+		newObject("test3", map[string]interface{}{"foo": "a"}),
+	}
+
+	pages := 0
+	cache := NewFieldUsageCache()
+	client := fakeapiextensions.NewSimpleClientset(existingCRD)
+	manager := NewManager[*apiextensionsv1.CustomResourceDefinition](
+		client.ApiextensionsV1().CustomResourceDefinitions(), EqualV1).
+		WithObjectLister(newPagedObjectLister(objects, nil, &pages)).
+		WithFieldUsageCache(cache)
+	v, _ := versionutil.ParseSemantic("v0.9.0")
+	manager.version = v
+
+	for i := 0; i < 2; i++ {
+		err := manager.Apply(context.TODO(), func(index string) ([]byte, error) {
+			return json.Marshal(requiredCRD)
+		}, "0")
+		if !errors.Is(err, ErrUnsafeUpdate) {
+			t.Errorf("expect the update is blocked, but got %v", err)
+		}
+	}
+	// the listing stops once all the removed fields are found in use, and the result is cached.
+	if pages != 2 {
+		t.Errorf("expect 2 pages are listed, but got %d", pages)
+	}
+}
+
+func TestStorageVersionMigrationChecker(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "test.io", Version: "v1alpha1", Resource: "foos"}
+	cases := []struct {
+		name       string
+		migrations []*migrationv1alpha1.StorageVersionMigration
+		expected   bool
+	}{
+		{
+			name: "no migration",
+		},
+		{
+			name: "migration running",
+			migrations: []*migrationv1alpha1.StorageVersionMigration{
+				newMigration("foos", "v1alpha1", migrationv1alpha1.MigrationRunning),
+			},
+		},
+		{
+			name: "migration of another version succeeded",
+			migrations: []*migrationv1alpha1.StorageVersionMigration{
+				newMigration("foos", "v1beta1", migrationv1alpha1.MigrationSucceeded),
+			},
+		},
+		{
+			name: "migration succeeded",
+			migrations: []*migrationv1alpha1.StorageVersionMigration{
+				newMigration("foos", "v1alpha1", migrationv1alpha1.MigrationSucceeded),
+			},
+			expected: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client := fakemigrationclient.NewSimpleClientset()
+			for _, migration := range c.migrations {
+				if err := client.Tracker().Add(migration); err != nil {
+					t.Fatal(err)
+				}
+			}
+			migrated, err := NewStorageVersionMigrationChecker(client.MigrationV1alpha1())(context.TODO(), gvr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if migrated != c.expected {
+				t.Errorf("expect migrated %t, but got %t", c.expected, migrated)
+			}
+		})
+	}
+}
+
+func TestRemovedFieldPaths(t *testing.T) {
+	existing := &apiextensionsv1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiextensionsv1.JSONSchemaProps{
+			"spec": {
+				Type: "object",
+				Properties: map[string]apiextensionsv1.JSONSchemaProps{
+					"foo": {Type: "string"},
+					"items": {
+						Type: "array",
+						Items: &apiextensionsv1.JSONSchemaPropsOrArray{Schema: &apiextensionsv1.JSONSchemaProps{
+							Type: "object",
+							Properties: map[string]apiextensionsv1.JSONSchemaProps{
+								"name": {Type: "string"},
+								"old":  {Type: "string"},
+							},
+						}},
+					},
+					"config": {
+						Type: "object",
+						Properties: map[string]apiextensionsv1.JSONSchemaProps{
+							"old": {Type: "string"},
+						},
+					},
+				},
+			},
+		},
+	}
+	preserveUnknownFields := true
+	required := &apiextensionsv1.JSONSchemaProps{
+		Type: "object",
+		Properties: map[string]apiextensionsv1.JSONSchemaProps{
+			"spec": {
+				Type: "object",
+				Properties: map[string]apiextensionsv1.JSONSchemaProps{
+					"items": {
+						Type: "array",
+						Items: &apiextensionsv1.JSONSchemaPropsOrArray{Schema: &apiextensionsv1.JSONSchemaProps{
+							Type: "object",
+							Properties: map[string]apiextensionsv1.JSONSchemaProps{
+								"name": {Type: "string"},
+							},
+						}},
+					},
+					"config": {
+						Type:                   "object",
+						XPreserveUnknownFields: &preserveUnknownFields,
+					},
+				},
+			},
+		},
+	}
+
+	removed := removedFieldPaths(existing, required)
+	expected := []string{"spec.foo", "spec.items.[].old"}
+	if !reflect.DeepEqual(removed, expected) {
+		t.Errorf("expect removed fields %v, but got %v", expected, removed)
+	}
+
+	obj := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"name": "a"},
+				map[string]interface{}{"name": "b", "old": "c"},
+			},
+		},
+	}
+	if fieldInUse(obj, []string{"spec", "foo"}) {
+		t.Errorf("expect spec.foo not in use")
+	}
+	if !fieldInUse(obj, []string{"spec", "items", "[]", "old"}) {
+		t.Errorf("expect spec.items.[].old in use")
+	}
+}
+
+// newVersionedCRD returns a crd with the given versions, the last one is the storage version.
+func newVersionedCRD(version string, storedVersions []string, versions ...string) *apiextensionsv1.CustomResourceDefinition {
+	crd := newV1CRD("foos.test.io", version)
+	crd.Spec.Group = "test.io"
+	crd.Spec.Names = apiextensionsv1.CustomResourceDefinitionNames{Plural: "foos", Kind: "Foo"}
+	for i, v := range versions {
+		crd.Spec.Versions = append(crd.Spec.Versions, apiextensionsv1.CustomResourceDefinitionVersion{
+			Name:    v,
+			Served:  true,
+			Storage: i == len(versions)-1,
+			Schema: &apiextensionsv1.CustomResourceValidation{
+				OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+					Type: "object",
+					Properties: map[string]apiextensionsv1.JSONSchemaProps{
+						"spec": {
+							Type: "object",
+							Properties: map[string]apiextensionsv1.JSONSchemaProps{
+								"foo": {Type: "string"},
+								"bar": {Type: "array", Items: &apiextensionsv1.JSONSchemaPropsOrArray{
+									Schema: &apiextensionsv1.JSONSchemaProps{Type: "string"},
+								}},
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+	crd.Status.StoredVersions = storedVersions
+	return crd
+}
+
+func removeField(crd *apiextensionsv1.CustomResourceDefinition, field string) *apiextensionsv1.CustomResourceDefinition {
+	for _, v := range crd.Spec.Versions {
+		delete(v.Schema.OpenAPIV3Schema.Properties["spec"].Properties, field)
+	}
+	return crd
+}
+
+// newPagedObjectLister returns an ObjectLister which visits one object per page, pages counts the visited pages.
+func newPagedObjectLister(objects []unstructured.Unstructured, listErr error, pages *int) ObjectLister {
+	return func(ctx context.Context, gvr schema.GroupVersionResource,
+		visit func(objects []unstructured.Unstructured) bool) error {
+		if listErr != nil {
+			return listErr
+		}
+		for i := range objects {
+			if pages != nil {
+				*pages++
+			}
+			if !visit(objects[i : i+1]) {
+				return nil
+			}
+		}
+		return nil
+	}
+}
+
+func newObject(name string, spec map[string]interface{}) unstructured.Unstructured {
+	obj := unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	obj.SetName(name)
+	return obj
+}
+
+func newMigration(resource, version string, conditionType migrationv1alpha1.MigrationConditionType) *migrationv1alpha1.StorageVersionMigration {
+	return &migrationv1alpha1.StorageVersionMigration{
+		ObjectMeta: metav1.ObjectMeta{Name: resource + "-" + version},
+		Spec: migrationv1alpha1.StorageVersionMigrationSpec{
+			Resource: migrationv1alpha1.GroupVersionResource{Group: "test.io", Version: version, Resource: resource},
+		},
+		Status: migrationv1alpha1.StorageVersionMigrationStatus{
+			Conditions: []migrationv1alpha1.MigrationCondition{
+				{Type: conditionType, Status: corev1.ConditionTrue},
+			},
+		},
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	versionutil "k8s.io/apimachinery/pkg/util/version"
 	"k8s.io/klog/v2"
 	"k8s.io/utils/pointer"
@@ -50,12 +51,17 @@ type Manager[T CRD] struct {
 	client  crdClient[T]
 	equal   func(old, new T) bool
 	version *versionutil.Version
+
+	objectLister     ObjectLister
+	fieldUsageCache  *FieldUsageCache
+	migrationChecker MigrationChecker
 }
 
 type crdClient[T CRD] interface {
 	Get(ctx context.Context, name string, opt metav1.GetOptions) (T, error)
 	Create(ctx context.Context, obj T, opt metav1.CreateOptions) (T, error)
 	Update(ctx context.Context, obj T, opt metav1.UpdateOptions) (T, error)
+	UpdateStatus(ctx context.Context, obj T, opt metav1.UpdateOptions) (T, error)
 	Delete(ctx context.Context, name string, opt metav1.DeleteOptions) error
 }
 
@@ -85,6 +91,26 @@ func NewManager[T CRD](client crdClient[T], equalFunc func(old, new T) bool) *Ma
 	return manager
 }
 
+// WithObjectLister sets the lister to check whether the fields removed from a crd are used by the existing objects.
+func (m *Manager[T]) WithObjectLister(lister ObjectLister) *Manager[T] {
+	m.objectLister = lister
+	return m
+}
+
+// WithFieldUsageCache sets the cache of the removed fields in use found by the object lister, the objects are listed
+// on every apply if it is not set.
+func (m *Manager[T]) WithFieldUsageCache(cache *FieldUsageCache) *Manager[T] {
+	m.fieldUsageCache = cache
+	return m
+}
+
+// WithMigrationChecker sets the checker to find whether the objects of a stored version removed from a crd have been
+// migrated. A stored version is never removed if it is not set.
+func (m *Manager[T]) WithMigrationChecker(checker MigrationChecker) *Manager[T] {
+	m.migrationChecker = checker
+	return m
+}
+
 func (m *Manager[T]) CleanOne(ctx context.Context, name string, skip bool) error {
 	// remove version annotation if skip clean
 	if skip {
@@ -224,6 +250,32 @@ func (m *Manager[T]) applyOne(ctx context.Context, required T) error {
 		return nil
 	}
 
+	compatibility, err := m.checkCompatibility(ctx,
+		(*apiextensionsv1.CustomResourceDefinition)(existing), (*apiextensionsv1.CustomResourceDefinition)(required))
+	if err != nil {
+		return err
+	}
+	if len(compatibility.unsafeReasons) > 0 {
+		return unsafeUpdateError(accessor.GetName(), compatibility.unsafeReasons)
+	}
+	// the migrated versions are removed from the stored versions, otherwise the apiserver rejects the update.
+	if len(compatibility.prunableVersions) > 0 {
+		pruned := (*apiextensionsv1.CustomResourceDefinition)(existing).DeepCopy()
+		prunable := sets.New[string](compatibility.prunableVersions...)
+		var storedVersions []string
+		for _, v := range pruned.Status.StoredVersions {
+			if !prunable.Has(v) {
+				storedVersions = append(storedVersions, v)
+			}
+		}
+		pruned.Status.StoredVersions = storedVersions
+		if existing, err = m.client.UpdateStatus(ctx, T(pruned), metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		logger.Info("stored versions of crd are pruned", "crdName", accessor.GetName(),
+			"versions", compatibility.prunableVersions)
+	}
+
 	existingAccessor, err := meta.Accessor(existing)
 	if err != nil {
 		return err
@@ -51,8 +51,8 @@ func TestSyncDelete(t *testing.T) {
 	}
 
 	// 11 managed static manifests + 12 management static manifests + 1 hub kubeconfig + 2 namespaces + 2 deployments
-	if len(deleteActions) != 28 {
-		t.Errorf("Expected 28 delete actions, but got %d", len(deleteActions))
+	if len(deleteActions) != 30 {
+		t.Errorf("Expected 30 delete actions, but got %d", len(deleteActions))
 	}
 
 	var updateWorkActions []clienttesting.PatchActionImpl
@@ -123,8 +123,8 @@ func TestSyncDeleteHosted(t *testing.T) {
 	}
 
 	// 12 static manifests + 2 namespaces
-	if len(deleteActionsManaged) != 14 {
-		t.Errorf("Expected 14 delete actions, but got %d", len(deleteActionsManaged))
+	if len(deleteActionsManaged) != 16 {
+		t.Errorf("Expected 16 delete actions, but got %d", len(deleteActionsManaged))
 	}
 
 	var updateWorkActions []clienttesting.PatchActionImpl
@@ -34,6 +34,7 @@ import (
 	"open-cluster-management.io/ocm/pkg/common/queue"
 	commonrecorder "open-cluster-management.io/ocm/pkg/common/recorder"
 	"open-cluster-management.io/ocm/pkg/operator/helpers"
+	"open-cluster-management.io/ocm/pkg/operator/operators/crdmanager"
 )
 
 const (
@@ -52,6 +53,7 @@ type klusterletController struct {
 	kubeVersion                   *version.Version
 	operatorNamespace             string
 	cache                         resourceapply.ResourceCache
+	fieldUsageCache               *crdmanager.FieldUsageCache
 	managedClusterClientsBuilder  managedClusterClientsBuilderInterface
 	controlPlaneNodeLabelSelector string
 	deploymentReplicas            int32
@@ -94,6 +96,7 @@ func NewKlusterletController(
 		kubeVersion:                   kubeVersion,
 		operatorNamespace:             operatorNamespace,
 		cache:                         resourceapply.NewResourceCache(),
+		fieldUsageCache:               crdmanager.NewFieldUsageCache(),
 		managedClusterClientsBuilder:  newManagedClusterClientsBuilder(kubeClient, apiExtensionClient, appliedManifestWorkClient),
 		controlPlaneNodeLabelSelector: controlPlaneNodeLabelSelector,
 		deploymentReplicas:            deploymentReplicas,
@@ -265,38 +268,8 @@ func (n *klusterletController) sync(ctx context.Context, controllerContext facto
 		replica = helpers.DetermineReplica(ctx, n.kubeClient, klusterlet.Spec.DeployOption.Mode, n.controlPlaneNodeLabelSelector)
 	}
 
-	config := klusterletConfig{
-		KlusterletName:      klusterlet.Name,
-		KlusterletNamespace: helpers.KlusterletNamespace(klusterlet),
-		AgentNamespace:      helpers.AgentNamespace(klusterlet),
-		AgentID:             string(klusterlet.UID),
-		RegistrationImage:   klusterlet.Spec.RegistrationImagePullSpec,
-		WorkImage:           klusterlet.Spec.WorkImagePullSpec,
-		ClusterName:         klusterlet.Spec.ClusterName,
-		SingletonImage:      klusterlet.Spec.ImagePullSpec,
-		HubKubeConfigSecret: helpers.HubKubeConfig,
-		ExternalServerURL:   getServersFromKlusterlet(klusterlet),
-		OperatorNamespace:   n.operatorNamespace,
-		Replica:             replica,
-		PriorityClassName:   helpers.AgentPriorityClassName(ctx, klusterlet, n.kubeVersion),
-
-		ExternalManagedKubeConfigSecret:             helpers.ExternalManagedKubeConfig,
-		ExternalManagedKubeConfigRegistrationSecret: helpers.ExternalManagedKubeConfigRegistration,
-		ExternalManagedKubeConfigWorkSecret:         helpers.ExternalManagedKubeConfigWork,
-		ExternalManagedKubeConfigAgentSecret:        helpers.ExternalManagedKubeConfigAgent,
-		InstallMode:                                 klusterlet.Spec.DeployOption.Mode,
-		HubApiServerHostAlias:                       klusterlet.Spec.HubApiServerHostAlias,
-
-		RegistrationServiceAccount:      serviceAccountName("registration-sa", klusterlet),
-		WorkServiceAccount:              serviceAccountName("work-sa", klusterlet),
-		ResourceRequirementResourceType: helpers.ResourceType(klusterlet),
-		ResourceRequirements:            resourceRequirements,
-		DisableAddonNamespace:           n.disableAddonNamespace,
-	}
-
-	config.populateBootstrap(klusterlet)
-
-	config.Labels = helpers.GetKlusterletAgentLabels(klusterlet, n.enableSyncLabels)
+	config, featureGateCondition := newKlusterletConfig(ctx, klusterlet, n.operatorNamespace, replica, resourceRequirements,
+		n.kubeVersion, n.disableAddonNamespace, n.enableSyncLabels)
 
 	managedClusterClients, err := n.managedClusterClientsBuilder.
 		withMode(config.InstallMode).
@@ -343,6 +316,109 @@ func (n *klusterletController) sync(ctx context.Context, controllerContext facto
 		return nil
 	}
 
+	meta.SetStatusCondition(&klusterlet.Status.Conditions, featureGateCondition)
+
+	reconcilers := []klusterletReconcile{
+		&crdReconcile{
+			managedClusterClients: managedClusterClients,
+			recorder:              controllerContext.Recorder(),
+			cache:                 n.cache,
+			fieldUsageCache:       n.fieldUsageCache},
+		&managedReconcile{
+			managedClusterClients: managedClusterClients,
+			kubeClient:            n.kubeClient,
+			kubeVersion:           n.kubeVersion,
+			operatorNamespace:     n.operatorNamespace,
+			recorder:              controllerContext.Recorder(),
+			cache:                 n.cache,
+			enableSyncLabels:      n.enableSyncLabels},
+		&managementReconcile{
+			kubeClient:        n.kubeClient,
+			operatorNamespace: n.operatorNamespace,
+			recorder:          controllerContext.Recorder(),
+			cache:             n.cache,
+			enableSyncLabels:  n.enableSyncLabels},
+		&runtimeReconcile{
+			managedClusterClients: managedClusterClients,
+			kubeClient:            n.kubeClient,
+			recorder:              controllerContext.Recorder(),
+			cache:                 n.cache,
+			enableSyncLabels:      n.enableSyncLabels},
+		&namespaceReconcile{
+			managedClusterClients: managedClusterClients,
+		},
+	}
+
+	var errs []error
+	for _, reconciler := range reconcilers {
+		var state reconcileState
+		klusterlet, state, err = reconciler.reconcile(ctx, klusterlet, config)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if state == reconcileStop {
+			break
+		}
+	}
+
+	klusterlet.Status.ObservedGeneration = klusterlet.Generation
+
+	if len(errs) == 0 {
+		meta.SetStatusCondition(&klusterlet.Status.Conditions, metav1.Condition{
+			Type: operatorapiv1.ConditionKlusterletApplied, Status: metav1.ConditionTrue, Reason: operatorapiv1.ReasonKlusterletApplied,
+			Message: "Klusterlet Component Applied"})
+	} else {
+		// When appliedCondition is false, we should not update related resources and resource generations
+		klusterlet.Status.RelatedResources = originalKlusterlet.Status.RelatedResources
+		klusterlet.Status.Generations = originalKlusterlet.Status.Generations
+	}
+
+	// If we get here, we have successfully applied everything.
+	_, updatedErr := n.patcher.PatchStatus(ctx, klusterlet, klusterlet.Status, originalKlusterlet.Status)
+	if updatedErr != nil {
+		errs = append(errs, updatedErr)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// newKlusterletConfig returns the config to render the manifests of the klusterlet, and the condition of the feature
+// gates.
+func newKlusterletConfig(ctx context.Context, klusterlet *operatorapiv1.Klusterlet, operatorNamespace string, replica int32,
+	resourceRequirements []byte, kubeVersion *version.Version, disableAddonNamespace, enableSyncLabels bool) (
+	klusterletConfig, metav1.Condition) {
+	config := klusterletConfig{
+		KlusterletName:      klusterlet.Name,
+		KlusterletNamespace: helpers.KlusterletNamespace(klusterlet),
+		AgentNamespace:      helpers.AgentNamespace(klusterlet),
+		AgentID:             string(klusterlet.UID),
+		RegistrationImage:   klusterlet.Spec.RegistrationImagePullSpec,
+		WorkImage:           klusterlet.Spec.WorkImagePullSpec,
+		ClusterName:         klusterlet.Spec.ClusterName,
+		SingletonImage:      klusterlet.Spec.ImagePullSpec,
+		HubKubeConfigSecret: helpers.HubKubeConfig,
+		ExternalServerURL:   getServersFromKlusterlet(klusterlet),
+		OperatorNamespace:   operatorNamespace,
+		Replica:             replica,
+		PriorityClassName:   helpers.AgentPriorityClassName(ctx, klusterlet, kubeVersion),
+
+		ExternalManagedKubeConfigSecret:             helpers.ExternalManagedKubeConfig,
+		ExternalManagedKubeConfigRegistrationSecret: helpers.ExternalManagedKubeConfigRegistration,
+		ExternalManagedKubeConfigWorkSecret:         helpers.ExternalManagedKubeConfigWork,
+		ExternalManagedKubeConfigAgentSecret:        helpers.ExternalManagedKubeConfigAgent,
+		InstallMode:                                 klusterlet.Spec.DeployOption.Mode,
+		HubApiServerHostAlias:                       klusterlet.Spec.HubApiServerHostAlias,
+
+		RegistrationServiceAccount:      serviceAccountName("registration-sa", klusterlet),
+		WorkServiceAccount:              serviceAccountName("work-sa", klusterlet),
+		ResourceRequirementResourceType: helpers.ResourceType(klusterlet),
+		ResourceRequirements:            resourceRequirements,
+		DisableAddonNamespace:           disableAddonNamespace,
+	}
+
+	config.populateBootstrap(klusterlet)
+
+	config.Labels = helpers.GetKlusterletAgentLabels(klusterlet, enableSyncLabels)
+
 	// If there are some invalid feature gates of registration or work, will output condition `ValidFeatureGates`
 	// False in Klusterlet.
 	// TODO: For the work feature gates, when splitting permissions in the future, if the ExecutorValidatingCaches
@@ -431,7 +507,7 @@ func (n *klusterletController) sync(ctx context.Context, controllerContext facto
 	}
 
 	config.WorkFeatureGates, workFeatureMsgs = helpers.ConvertToFeatureGateFlags("Work", workFeatureGates, ocmfeature.DefaultSpokeWorkFeatureGates)
-	meta.SetStatusCondition(&klusterlet.Status.Conditions, helpers.BuildFeatureCondition(registrationFeatureMsgs, workFeatureMsgs))
+	featureGateCondition := helpers.BuildFeatureCondition(registrationFeatureMsgs, workFeatureMsgs)
 
 	// for singleton agent, the QPS and Burst use the max one between the configurations of registration and work
 	config.AgentKubeAPIQPS = config.RegistrationKubeAPIQPS
@@ -443,66 +519,7 @@ func (n *klusterletController) sync(ctx context.Context, controllerContext facto
 		config.AgentKubeAPIBurst = config.WorkKubeAPIBurst
 	}
 
-	reconcilers := []klusterletReconcile{
-		&crdReconcile{
-			managedClusterClients: managedClusterClients,
-			recorder:              controllerContext.Recorder(),
-			cache:                 n.cache},
-		&managedReconcile{
-			managedClusterClients: managedClusterClients,
-			kubeClient:            n.kubeClient,
-			kubeVersion:           n.kubeVersion,
-			operatorNamespace:     n.operatorNamespace,
-			recorder:              controllerContext.Recorder(),
-			cache:                 n.cache,
-			enableSyncLabels:      n.enableSyncLabels},
-		&managementReconcile{
-			kubeClient:        n.kubeClient,
-			operatorNamespace: n.operatorNamespace,
-			recorder:          controllerContext.Recorder(),
-			cache:             n.cache,
-			enableSyncLabels:  n.enableSyncLabels},
-		&runtimeReconcile{
-			managedClusterClients: managedClusterClients,
-			kubeClient:            n.kubeClient,
-			recorder:              controllerContext.Recorder(),
-			cache:                 n.cache,
-			enableSyncLabels:      n.enableSyncLabels},
-		&namespaceReconcile{
-			managedClusterClients: managedClusterClients,
-		},
-	}
-
-	var errs []error
-	for _, reconciler := range reconcilers {
-		var state reconcileState
-		klusterlet, state, err = reconciler.reconcile(ctx, klusterlet, config)
-		if err != nil {
-			errs = append(errs, err)
-		}
-		if state == reconcileStop {
-			break
-		}
-	}
-
-	klusterlet.Status.ObservedGeneration = klusterlet.Generation
-
-	if len(errs) == 0 {
-		meta.SetStatusCondition(&klusterlet.Status.Conditions, metav1.Condition{
-			Type: operatorapiv1.ConditionKlusterletApplied, Status: metav1.ConditionTrue, Reason: operatorapiv1.ReasonKlusterletApplied,
-			Message: "Klusterlet Component Applied"})
-	} else {
-		// When appliedCondition is false, we should not update related resources and resource generations
-		klusterlet.Status.RelatedResources = originalKlusterlet.Status.RelatedResources
-		klusterlet.Status.Generations = originalKlusterlet.Status.Generations
-	}
-
-	// If we get here, we have successfully applied everything.
-	_, updatedErr := n.patcher.PatchStatus(ctx, klusterlet, klusterlet.Status, originalKlusterlet.Status)
-	if updatedErr != nil {
-		errs = append(errs, updatedErr)
-	}
-	return utilerrors.NewAggregate(errs)
+	return config, featureGateCondition
 }
 
 // TODO also read CABundle from ExternalServerURLs and set into registration deployment
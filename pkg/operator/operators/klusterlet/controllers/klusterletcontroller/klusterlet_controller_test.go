@@ -628,8 +628,8 @@ func TestSyncDeploy(t *testing.T) {
 
 			// Check if resources are created as expected
 			// 11 managed static manifests + 12 management static manifests - 2 duplicated service account manifests + 1 addon namespace + 2 deployments
-			if len(createObjects) != 24 {
-				t.Errorf("Expect 24 objects created in the sync loop, actual %d", len(createObjects))
+			if len(createObjects) != 26 {
+				t.Errorf("Expect 26 objects created in the sync loop, actual %d", len(createObjects))
 			}
 			for _, object := range createObjects {
 				ensureObject(t, object, klusterlet, false)
@@ -710,8 +710,8 @@ func TestSyncDeploySingleton(t *testing.T) {
 
 			// Check if resources are created as expected
 			// 10 managed static manifests + 11 management static manifests - 1 service account manifests + 1 addon namespace + 1 deployments
-			if len(createObjects) != 22 {
-				t.Errorf("Expect 21 objects created in the sync loop, actual %d", len(createObjects))
+			if len(createObjects) != 24 {
+				t.Errorf("Expect 24 objects created in the sync loop, actual %d", len(createObjects))
 			}
 			for _, object := range createObjects {
 				ensureObject(t, object, klusterlet, false)
@@ -800,8 +800,8 @@ func TestSyncDeployHosted(t *testing.T) {
 	}
 	// Check if resources are created as expected on the managed cluster
 	// 12 static manifests + 2 namespaces + 1 pull secret in the addon namespace
-	if len(createObjectsManaged) != 15 {
-		t.Errorf("Expect 15 objects created in the sync loop, actual %d", len(createObjectsManaged))
+	if len(createObjectsManaged) != 17 {
+		t.Errorf("Expect 17 objects created in the sync loop, actual %d", len(createObjectsManaged))
 	}
 	for _, object := range createObjectsManaged {
 		ensureObject(t, object, klusterlet, false)
@@ -6,6 +6,7 @@ package klusterletcontroller
 
 import (
 	"context"
+	"errors"
 
 	"github.com/openshift/library-go/pkg/assets"
 	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
@@ -35,14 +36,17 @@ type crdReconcile struct {
 	managedClusterClients *managedClusterClients
 	recorder              events.Recorder
 	cache                 resourceapply.ResourceCache
+	fieldUsageCache       *crdmanager.FieldUsageCache
 }
 
 func (r *crdReconcile) reconcile(ctx context.Context, klusterlet *operatorapiv1.Klusterlet,
 	config klusterletConfig) (*operatorapiv1.Klusterlet, reconcileState, error) {
+	// there is no storage version migration on the managed cluster, so a stored version is never removed.
 	crdManager := crdmanager.NewManager[*apiextensionsv1.CustomResourceDefinition](
 		r.managedClusterClients.apiExtensionClient.ApiextensionsV1().CustomResourceDefinitions(),
 		crdmanager.EqualV1,
-	)
+	).WithObjectLister(crdmanager.NewRESTObjectLister(r.managedClusterClients.apiExtensionClient.ApiextensionsV1().RESTClient())).
+		WithFieldUsageCache(r.fieldUsageCache)
 
 	var crdFiles []string
 	crdFiles = append(crdFiles, crdV1StaticFiles...)
@@ -64,8 +68,12 @@ func (r *crdReconcile) reconcile(ctx context.Context, klusterlet *operatorapiv1.
 	)
 
 	if applyErr != nil {
+		reason := operatorapiv1.ReasonKlusterletCRDApplyFailed
+		if errors.Is(applyErr, crdmanager.ErrUnsafeUpdate) {
+			reason = crdmanager.ReasonCRDUpdateBlocked
+		}
 		meta.SetStatusCondition(&klusterlet.Status.Conditions, metav1.Condition{
-			Type: operatorapiv1.ConditionKlusterletApplied, Status: metav1.ConditionFalse, Reason: operatorapiv1.ReasonKlusterletCRDApplyFailed,
+			Type: operatorapiv1.ConditionKlusterletApplied, Status: metav1.ConditionFalse, Reason: reason,
 			Message: applyErr.Error(),
 		})
 		return klusterlet, reconcileStop, applyErr
@@ -37,9 +37,11 @@ var managedStaticResourceFiles = []string{
 	"klusterlet/managed/klusterlet-work-serviceaccount.yaml",
 	"klusterlet/managed/klusterlet-work-clusterrole.yaml",
 	"klusterlet/managed/klusterlet-work-clusterrole-execution.yaml",
+	"klusterlet/managed/klusterlet-work-clusterrole-upgrade.yaml",
 	"klusterlet/managed/klusterlet-work-clusterrolebinding.yaml",
 	"klusterlet/managed/klusterlet-work-clusterrolebinding-aggregate.yaml",
 	"klusterlet/managed/klusterlet-work-clusterrolebinding-execution-admin.yaml",
+	"klusterlet/managed/klusterlet-work-clusterrolebinding-upgrade.yaml",
 }
 
 // managedReconcile apply resources to managed clusters
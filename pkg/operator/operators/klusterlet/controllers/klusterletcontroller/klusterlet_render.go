@@ -0,0 +1,240 @@
+package klusterletcontroller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/openshift/library-go/pkg/assets"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/yaml"
+
+	operatorapiv1 "open-cluster-management.io/api/operator/v1"
+
+	"open-cluster-management.io/ocm/manifests"
+	"open-cluster-management.io/ocm/pkg/operator/helpers"
+)
+
+// RenderOptions is the configuration to render the manifests of a klusterlet without the operator.
+type RenderOptions struct {
+	// OperatorNamespace is the namespace the operator would run in.
+	OperatorNamespace string
+	// Replica is the replica of the deployments.
+	Replica int32
+	// KubeVersion is the version of the managed cluster, the priority class of the agents is set only if it is set.
+	KubeVersion *version.Version
+	// EnableSyncLabels syncs the labels of the klusterlet to the rendered resources.
+	EnableSyncLabels bool
+	// DisableAddonNamespace skips rendering the default addon namespace.
+	DisableAddonNamespace bool
+	// BootstrapKubeConfigs maps the names of the bootstrap kubeconfig secrets to the bootstrap kubeconfigs, the
+	// secrets are rendered into the agent namespace.
+	BootstrapKubeConfigs map[string][]byte
+	// ManagedKubeConfig is the kubeconfig of the managed cluster used by the agents on the management cluster in the
+	// Hosted and SingletonHosted modes. The operator generates the kubeconfigs with the tokens of the service
+	// accounts on the managed cluster instead, which are not available without the managed cluster.
+	ManagedKubeConfig []byte
+}
+
+// RenderManifests renders the manifests that the operator applies for the klusterlet, in the order they are applied:
+// the namespaces, the bootstrap kubeconfig secrets, the crds, the rbac resources and the deployments patched by the
+// component patches.
+//
+// The manifests applied on the managed cluster are returned as the managedObjects. In the Hosted and SingletonHosted
+// modes, the agent namespace, the secrets, the rbac resources and the deployments applied on the management cluster
+// are returned as the managementObjects, otherwise they are returned in the managedObjects.
+func RenderManifests(ctx context.Context, klusterlet *operatorapiv1.Klusterlet, opts RenderOptions) (
+	managedObjects, managementObjects [][]byte, err error) {
+	mode := klusterlet.Spec.DeployOption.Mode
+	hosted := helpers.IsHosted(mode)
+	if hosted && len(opts.ManagedKubeConfig) == 0 {
+		return nil, nil, fmt.Errorf("the managed cluster kubeconfig is required in %s mode", mode)
+	}
+	// the work agent reads the cluster name from the hub kubeconfig secret if it is not set, which does not exist
+	// before the registration agent bootstraps.
+	if !helpers.IsSingleton(mode) && len(klusterlet.Spec.ClusterName) == 0 {
+		return nil, nil, fmt.Errorf("the cluster name of the klusterlet is required")
+	}
+
+	resourceRequirements, err := helpers.ResourceRequirements(ctx, klusterlet)
+	if err != nil {
+		return nil, nil, err
+	}
+	patches, err := helpers.ParseComponentPatches(klusterlet.Annotations, componentNames())
+	if err != nil {
+		return nil, nil, err
+	}
+	config, featureGateCondition := newKlusterletConfig(ctx, klusterlet, opts.OperatorNamespace, opts.Replica,
+		resourceRequirements, opts.KubeVersion, opts.DisableAddonNamespace, opts.EnableSyncLabels)
+	if featureGateCondition.Status == metav1.ConditionFalse {
+		return nil, nil, errors.New(featureGateCondition.Message)
+	}
+
+	render := func(name string) ([]byte, error) {
+		template, err := manifests.KlusterletManifestFiles.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		return assets.MustCreateAssetFromTemplate(name, template, config).Data, nil
+	}
+
+	labels := helpers.GetKlusterletAgentLabels(klusterlet, opts.EnableSyncLabels)
+	managedNamespaceLabels := map[string]map[string]string{}
+	if !config.DisableAddonNamespace {
+		managedNamespaceLabels[helpers.DefaultAddonNamespace] = labels
+	}
+	klusterletNamespaceLabels := helpers.GetKlusterletAgentLabels(klusterlet, opts.EnableSyncLabels)
+	klusterletNamespaceLabels[klusterletNamespaceLabelKey] = klusterlet.Name
+	managedNamespaceLabels[config.KlusterletNamespace] = klusterletNamespaceLabels
+	// the agent namespace is the klusterlet namespace unless the agents run on the management cluster.
+	managementNamespaceLabels := map[string]map[string]string{}
+	if hosted {
+		managementNamespaceLabels[config.AgentNamespace] = labels
+	}
+
+	managedObjects, err = encodeObjects(namespaceObjects(managedNamespaceLabels))
+	if err != nil {
+		return nil, nil, err
+	}
+	files := append([]string{}, crdV1StaticFiles...)
+	if config.AboutAPIEnabled {
+		files = append(files, aboutAPIFile)
+	}
+	files = append(files, managedStaticResourceFiles...)
+	for _, file := range files {
+		objData, err := render(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		managedObjects = append(managedObjects, objData)
+	}
+	// the aggregation clusterrole for work is created by the operator directly.
+	aggregateClusterRole, err := encodeObjects([]runtime.Object{&rbacv1.ClusterRole{
+		TypeMeta: metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   fmt.Sprintf("open-cluster-management:%s-work:aggregate", klusterlet.Name),
+			Labels: labels,
+		},
+		AggregationRule: &rbacv1.AggregationRule{
+			ClusterRoleSelectors: []metav1.LabelSelector{
+				{
+					MatchLabels: map[string]string{
+						"open-cluster-management.io/aggregate-to-work": "true",
+					},
+				},
+			},
+		},
+		Rules: []rbacv1.PolicyRule{},
+	}})
+	if err != nil {
+		return nil, nil, err
+	}
+	managedObjects = append(managedObjects, aggregateClusterRole...)
+
+	secrets := map[string][]byte{}
+	for name, kubeconfig := range opts.BootstrapKubeConfigs {
+		secrets[name] = kubeconfig
+	}
+	switch mode {
+	case operatorapiv1.InstallModeHosted:
+		secrets[config.ExternalManagedKubeConfigRegistrationSecret] = opts.ManagedKubeConfig
+		secrets[config.ExternalManagedKubeConfigWorkSecret] = opts.ManagedKubeConfig
+	case operatorapiv1.InstallModeSingletonHosted:
+		secrets[config.ExternalManagedKubeConfigAgentSecret] = opts.ManagedKubeConfig
+	}
+	objects := namespaceObjects(managementNamespaceLabels)
+	for _, name := range sets.List(sets.KeySet(secrets)) {
+		objects = append(objects, &corev1.Secret{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: config.AgentNamespace,
+				Labels:    labels,
+			},
+			Data: map[string][]byte{
+				"kubeconfig": secrets[name],
+			},
+		})
+	}
+	agentObjects, err := encodeObjects(objects)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, file := range managementStaticResourceFiles {
+		objData, err := render(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		agentObjects = append(agentObjects, objData)
+	}
+
+	deploymentFiles := []string{
+		"klusterlet/management/klusterlet-registration-deployment.yaml",
+		"klusterlet/management/klusterlet-work-deployment.yaml",
+	}
+	if helpers.IsSingleton(mode) {
+		deploymentFiles = []string{"klusterlet/management/klusterlet-agent-deployment.yaml"}
+	}
+	for _, file := range deploymentFiles {
+		objData, err := render(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		if objData, err = helpers.AddNodePlacementToYaml(objData, klusterlet.Spec.NodePlacement); err != nil {
+			return nil, nil, fmt.Errorf("failed to add node placement to template %s: %w", file, err)
+		}
+		if objData, err = patches.Apply(deploymentComponents[file], objData); err != nil {
+			return nil, nil, err
+		}
+		agentObjects = append(agentObjects, objData)
+	}
+
+	if hosted {
+		return managedObjects, agentObjects, nil
+	}
+	return append(managedObjects, agentObjects...), nil, nil
+}
+
+// namespaceObjects returns the namespaces with the labels, sorted by the names.
+func namespaceObjects(namespaceLabels map[string]map[string]string) []runtime.Object {
+	var objects []runtime.Object
+	for _, namespace := range sets.List(sets.KeySet(namespaceLabels)) {
+		objects = append(objects, &corev1.Namespace{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: namespace,
+				Annotations: map[string]string{
+					"workload.openshift.io/allowed": "management",
+				},
+				Labels: namespaceLabels[namespace],
+			},
+		})
+	}
+	return objects
+}
+
+// encodeObjects encodes the objects into yaml without the creation timestamp and the status.
+func encodeObjects(objects []runtime.Object) ([][]byte, error) {
+	var encoded [][]byte
+	for _, obj := range objects {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		unstructured.RemoveNestedField(content, "metadata", "creationTimestamp")
+		unstructured.RemoveNestedField(content, "status")
+		data, err := yaml.Marshal(content)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, data)
+	}
+	return encoded, nil
+}
@@ -0,0 +1,151 @@
+package klusterletcontroller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	operatorapiv1 "open-cluster-management.io/api/operator/v1"
+
+	"open-cluster-management.io/ocm/pkg/operator/helpers"
+)
+
+func TestRenderManifests(t *testing.T) {
+	cases := []struct {
+		name               string
+		mode               operatorapiv1.InstallMode
+		clusterName        string
+		annotations        map[string]string
+		expectErr          bool
+		expectedContents   []string
+		unexpectedContents []string
+		// expectedManagementContents are rendered for the management cluster in the hosted modes.
+		expectedManagementContents []string
+	}{
+		{
+			name:        "default mode",
+			mode:        operatorapiv1.InstallModeDefault,
+			clusterName: "cluster1",
+			expectedContents: []string{
+				"name: " + helpers.BootstrapHubKubeConfig,
+				"name: appliedmanifestworks.work.open-cluster-management.io",
+				"name: open-cluster-management:klusterlet-work:aggregate",
+				"name: klusterlet-registration-agent",
+				"name: klusterlet-work-agent",
+				"--spoke-cluster-name=cluster1",
+			},
+			unexpectedContents: []string{
+				"name: klusterlet-agent\n",
+			},
+		},
+		{
+			name: "singleton mode without cluster name",
+			mode: operatorapiv1.InstallModeSingleton,
+			expectedContents: []string{
+				"name: klusterlet-agent\n",
+			},
+			unexpectedContents: []string{
+				"name: klusterlet-registration-agent",
+			},
+		},
+		{
+			name: "singleton mode with component patches",
+			mode: operatorapiv1.InstallModeSingleton,
+			annotations: map[string]string{helpers.ComponentPatchesAnnotationKey: `{"agent":[{"type":"StrategicMerge","patch":` +
+				`{"spec":{"template":{"spec":{"containers":[{"name":"klusterlet-agent","env":[{"name":"HTTP_PROXY","value":"proxy"}]}]}}}}}]}`},
+			expectedContents: []string{
+				"name: HTTP_PROXY",
+			},
+		},
+		{
+			name:        "patches of unknown component",
+			mode:        operatorapiv1.InstallModeSingleton,
+			annotations: map[string]string{helpers.ComponentPatchesAnnotationKey: `{"registration-webhook":[]}`},
+			expectErr:   true,
+		},
+		{
+			name:      "default mode without cluster name",
+			mode:      operatorapiv1.InstallModeDefault,
+			expectErr: true,
+		},
+		{
+			name:        "hosted mode",
+			mode:        operatorapiv1.InstallModeHosted,
+			clusterName: "cluster1",
+			expectedContents: []string{
+				"name: appliedmanifestworks.work.open-cluster-management.io",
+				"name: open-cluster-management:klusterlet-work:aggregate",
+			},
+			unexpectedContents: []string{
+				"name: " + helpers.BootstrapHubKubeConfig,
+				"kind: Deployment",
+			},
+			expectedManagementContents: []string{
+				"name: klusterlet\n",
+				"name: " + helpers.BootstrapHubKubeConfig,
+				"name: " + helpers.ExternalManagedKubeConfigRegistration,
+				"name: " + helpers.ExternalManagedKubeConfigWork,
+				"name: klusterlet-registration-agent",
+			},
+		},
+		{
+			name: "singleton hosted mode",
+			mode: operatorapiv1.InstallModeSingletonHosted,
+			expectedManagementContents: []string{
+				"name: " + helpers.ExternalManagedKubeConfigAgent,
+				"name: klusterlet-agent\n",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			klusterlet := newKlusterlet("klusterlet", "open-cluster-management-agent", c.clusterName)
+			klusterlet.Spec.DeployOption.Mode = c.mode
+			klusterlet.Annotations = c.annotations
+
+			objects, managementObjects, err := RenderManifests(context.TODO(), klusterlet, RenderOptions{
+				OperatorNamespace:    "open-cluster-management",
+				Replica:              1,
+				BootstrapKubeConfigs: map[string][]byte{helpers.BootstrapHubKubeConfig: []byte("kubeconfig")},
+				ManagedKubeConfig:    []byte("kubeconfig"),
+			})
+			if c.expectErr {
+				if err == nil {
+					t.Errorf("expected error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var rendered []string
+			for _, objData := range objects {
+				rendered = append(rendered, string(objData))
+			}
+			content := strings.Join(rendered, "\n---\n")
+			for _, expected := range c.expectedContents {
+				if !strings.Contains(content, expected) {
+					t.Errorf("expected %q is rendered", expected)
+				}
+			}
+			for _, unexpected := range c.unexpectedContents {
+				if strings.Contains(content, unexpected) {
+					t.Errorf("expected %q is not rendered", unexpected)
+				}
+			}
+
+			var renderedManagement []string
+			for _, objData := range managementObjects {
+				renderedManagement = append(renderedManagement, string(objData))
+			}
+			managementContent := strings.Join(renderedManagement, "\n---\n")
+			for _, expected := range c.expectedManagementContents {
+				if !strings.Contains(managementContent, expected) {
+					t.Errorf("expected %q is rendered for the management cluster", expected)
+				}
+			}
+		})
+	}
+}
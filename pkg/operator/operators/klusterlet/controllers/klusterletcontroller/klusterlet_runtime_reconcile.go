@@ -7,6 +7,7 @@ package klusterletcontroller
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/openshift/library-go/pkg/assets"
@@ -23,6 +24,24 @@ import (
 	"open-cluster-management.io/ocm/pkg/operator/helpers"
 )
 
+// deploymentComponents maps the deployment manifests of the klusterlet to the names of the components, which are the
+// keys of the component patches.
+var deploymentComponents = map[string]string{
+	"klusterlet/management/klusterlet-registration-deployment.yaml": "registration",
+	"klusterlet/management/klusterlet-work-deployment.yaml":         "work",
+	"klusterlet/management/klusterlet-agent-deployment.yaml":        "agent",
+}
+
+// componentNames returns the sorted names of the klusterlet components.
+func componentNames() []string {
+	names := make([]string, 0, len(deploymentComponents))
+	for _, name := range deploymentComponents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // runtimeReconcile ensure all runtime of klusterlet is applied
 type runtimeReconcile struct {
 	managedClusterClients *managedClusterClients
@@ -34,15 +53,26 @@ type runtimeReconcile struct {
 
 func (r *runtimeReconcile) reconcile(ctx context.Context, klusterlet *operatorapiv1.Klusterlet,
 	config klusterletConfig) (*operatorapiv1.Klusterlet, reconcileState, error) {
+	patches, err := helpers.ParseComponentPatches(klusterlet.Annotations, componentNames())
+	if err != nil {
+		meta.SetStatusCondition(&klusterlet.Status.Conditions, metav1.Condition{
+			Type:    operatorapiv1.ConditionKlusterletApplied,
+			Status:  metav1.ConditionFalse,
+			Reason:  helpers.ReasonInvalidComponentPatches,
+			Message: err.Error(),
+		})
+		return klusterlet, reconcileStop, err
+	}
+
 	if helpers.IsSingleton(config.InstallMode) {
-		return r.installSingletonAgent(ctx, klusterlet, config)
+		return r.installSingletonAgent(ctx, klusterlet, config, patches)
 	}
 
-	return r.installAgent(ctx, klusterlet, config)
+	return r.installAgent(ctx, klusterlet, config, patches)
 }
 
 func (r *runtimeReconcile) installAgent(ctx context.Context, klusterlet *operatorapiv1.Klusterlet,
-	runtimeConfig klusterletConfig) (*operatorapiv1.Klusterlet, reconcileState, error) {
+	runtimeConfig klusterletConfig, patches helpers.ComponentPatches) (*operatorapiv1.Klusterlet, reconcileState, error) {
 	if helpers.IsHosted(runtimeConfig.InstallMode) {
 		// Create managed config secret for registration and work.
 		if err := r.createManagedClusterKubeconfig(ctx, klusterlet, runtimeConfig.KlusterletNamespace, runtimeConfig.AgentNamespace,
@@ -57,30 +87,13 @@ func (r *runtimeReconcile) installAgent(ctx context.Context, klusterlet *operato
 		}
 	}
 	// Deploy registration agent
-	_, generationStatus, err := helpers.ApplyDeployment(
-		ctx,
-		r.kubeClient,
-		klusterlet.Status.Generations,
-		klusterlet.Spec.NodePlacement,
-		func(name string) ([]byte, error) {
-			template, err := manifests.KlusterletManifestFiles.ReadFile(name)
-			if err != nil {
-				return nil, err
-			}
-			objData := assets.MustCreateAssetFromTemplate(name, template, runtimeConfig).Data
-			helpers.SetRelatedResourcesStatusesWithObj(ctx, &klusterlet.Status.RelatedResources, objData)
-			return objData, nil
-		},
-		r.recorder,
+	err := r.applyDeployment(ctx, klusterlet, runtimeConfig, patches,
 		"klusterlet/management/klusterlet-registration-deployment.yaml")
-
 	if err != nil {
 		// TODO update condition
 		return klusterlet, reconcileStop, err
 	}
 
-	helpers.SetGenerationStatuses(&klusterlet.Status.Generations, generationStatus)
-
 	// If cluster name is empty, read cluster name from hub config secret.
 	// registration-agent generated the cluster name and set it into hub config secret.
 	workConfig := runtimeConfig
@@ -106,23 +119,8 @@ func (r *runtimeReconcile) installAgent(ctx context.Context, klusterlet *operato
 	}
 
 	// Deploy work agent
-	_, generationStatus, err = helpers.ApplyDeployment(
-		ctx,
-		r.kubeClient,
-		klusterlet.Status.Generations,
-		klusterlet.Spec.NodePlacement,
-		func(name string) ([]byte, error) {
-			template, err := manifests.KlusterletManifestFiles.ReadFile(name)
-			if err != nil {
-				return nil, err
-			}
-			objData := assets.MustCreateAssetFromTemplate(name, template, workConfig).Data
-			helpers.SetRelatedResourcesStatusesWithObj(ctx, &klusterlet.Status.RelatedResources, objData)
-			return objData, nil
-		},
-		r.recorder,
+	err = r.applyDeployment(ctx, klusterlet, workConfig, patches,
 		"klusterlet/management/klusterlet-work-deployment.yaml")
-
 	if err != nil {
 		// TODO update condition
 		return klusterlet, reconcileStop, err
@@ -137,15 +135,13 @@ func (r *runtimeReconcile) installAgent(ctx context.Context, klusterlet *operato
 		}
 	}
 
-	helpers.SetGenerationStatuses(&klusterlet.Status.Generations, generationStatus)
-
 	// TODO check progressing condition
 
 	return klusterlet, reconcileContinue, nil
 }
 
 func (r *runtimeReconcile) installSingletonAgent(ctx context.Context, klusterlet *operatorapiv1.Klusterlet,
-	config klusterletConfig) (*operatorapiv1.Klusterlet, reconcileState, error) {
+	config klusterletConfig, patches helpers.ComponentPatches) (*operatorapiv1.Klusterlet, reconcileState, error) {
 	if helpers.IsHosted(config.InstallMode) {
 		// Create managed config secret for agent. In singletonHosted mode, service account for registration/work is actually
 		// the same one, and we just pick one of them to build the external kubeconfig.
@@ -156,23 +152,8 @@ func (r *runtimeReconcile) installSingletonAgent(ctx context.Context, klusterlet
 		}
 	}
 	// Deploy singleton agent
-	_, generationStatus, err := helpers.ApplyDeployment(
-		ctx,
-		r.kubeClient,
-		klusterlet.Status.Generations,
-		klusterlet.Spec.NodePlacement,
-		func(name string) ([]byte, error) {
-			template, err := manifests.KlusterletManifestFiles.ReadFile(name)
-			if err != nil {
-				return nil, err
-			}
-			objData := assets.MustCreateAssetFromTemplate(name, template, config).Data
-			helpers.SetRelatedResourcesStatusesWithObj(ctx, &klusterlet.Status.RelatedResources, objData)
-			return objData, nil
-		},
-		r.recorder,
+	err := r.applyDeployment(ctx, klusterlet, config, patches,
 		"klusterlet/management/klusterlet-agent-deployment.yaml")
-
 	if err != nil {
 		// TODO update condition
 		return klusterlet, reconcileStop, err
@@ -190,10 +171,43 @@ func (r *runtimeReconcile) installSingletonAgent(ctx context.Context, klusterlet
 		}
 	}
 
-	helpers.SetGenerationStatuses(&klusterlet.Status.Generations, generationStatus)
 	return klusterlet, reconcileContinue, nil
 }
 
+// applyDeployment renders the deployment of the file with the config, applies the patches of the component to it and
+// applies it. The generations of the deployment and of the applied patches are recorded in the klusterlet status.
+func (r *runtimeReconcile) applyDeployment(ctx context.Context, klusterlet *operatorapiv1.Klusterlet,
+	config klusterletConfig, patches helpers.ComponentPatches, file string) error {
+	component := deploymentComponents[file]
+	deployment, generationStatus, err := helpers.ApplyDeployment(
+		ctx,
+		r.kubeClient,
+		klusterlet.Status.Generations,
+		klusterlet.Spec.NodePlacement,
+		func(name string) ([]byte, error) {
+			template, err := manifests.KlusterletManifestFiles.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			objData := assets.MustCreateAssetFromTemplate(name, template, config).Data
+			if objData, err = patches.Apply(component, objData); err != nil {
+				return nil, err
+			}
+			helpers.SetRelatedResourcesStatusesWithObj(ctx, &klusterlet.Status.RelatedResources, objData)
+			return objData, nil
+		},
+		r.recorder,
+		file)
+	if err != nil {
+		return err
+	}
+
+	helpers.SetGenerationStatuses(&klusterlet.Status.Generations, generationStatus)
+	helpers.SetComponentPatchesGenerationStatus(&klusterlet.Status.Generations, patches, component,
+		deployment.Namespace, deployment.Name)
+	return nil
+}
+
 func (r *runtimeReconcile) createManagedClusterKubeconfig(
 	ctx context.Context,
 	klusterlet *operatorapiv1.Klusterlet,
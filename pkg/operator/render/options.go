@@ -0,0 +1,368 @@
+// package render renders the manifests that the operators apply for a ClusterManager or a Klusterlet without running
+// the operators, so that the components can be installed by the GitOps tools or in the air-gapped environments, and
+// the changes of the components between releases can be compared. The operator charts can be rendered as well, to
+// install the operators instead of the components.
+package render
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/yaml"
+
+	operatorapiv1 "open-cluster-management.io/api/operator/v1"
+
+	"open-cluster-management.io/ocm/pkg/operator/helpers"
+	"open-cluster-management.io/ocm/pkg/operator/helpers/chart"
+	"open-cluster-management.io/ocm/pkg/operator/operators/clustermanager/controllers/clustermanagercontroller"
+	"open-cluster-management.io/ocm/pkg/operator/operators/klusterlet/controllers/klusterletcontroller"
+)
+
+const defaultOperatorNamespace = "open-cluster-management"
+
+const (
+	// chartClusterManager and chartKlusterlet are the charts that install the operators.
+	chartClusterManager = "cluster-manager"
+	chartKlusterlet     = "klusterlet"
+)
+
+// manifestGroup is the manifests applied on the same cluster, they are written into the dir of the output directory.
+type manifestGroup struct {
+	dir     string
+	objects [][]byte
+}
+
+// Options holds the configuration of the render command.
+type Options struct {
+	// File is the path of the ClusterManager or Klusterlet to render, or the path of the chart values if the Chart
+	// is set.
+	File string
+	// Chart is the operator chart to render, cluster-manager or klusterlet. The operator and the ClusterManager or
+	// Klusterlet are rendered from the chart instead of the components.
+	Chart string
+	// OutputDir is the directory to write the manifests into, one file per manifest. The manifests are written to
+	// the stdout if it is empty.
+	OutputDir string
+	// OperatorNamespace is the namespace the operator would run in.
+	OperatorNamespace string
+	// Replica is the replica of the deployments.
+	Replica int32
+	// EnableSyncLabels syncs the labels of the ClusterManager or Klusterlet to the rendered resources.
+	EnableSyncLabels bool
+	// CABundleFile is the path of the CA bundle to verify the serving certificates of the hub webhooks, it is
+	// required to render a ClusterManager.
+	CABundleFile string
+	// HubKubeconfig is the path of the hub kubeconfig used by the hub components in the Hosted mode.
+	HubKubeconfig string
+	// ManagedKubeconfig is the path of the managed cluster kubeconfig used by the agents in the Hosted mode.
+	ManagedKubeconfig string
+	// BootstrapKubeconfig is the path of the bootstrap kubeconfig of the klusterlet.
+	BootstrapKubeconfig string
+	// BootstrapKubeconfigs maps the names of the bootstrap kubeconfig secrets to the paths of the bootstrap
+	// kubeconfigs, it is used when the MultipleHubs feature gate is enabled on the klusterlet.
+	BootstrapKubeconfigs map[string]string
+	// KubeVersion is the kubernetes version of the managed cluster.
+	KubeVersion string
+}
+
+func NewOptions() *Options {
+	return &Options{
+		OperatorNamespace: defaultOperatorNamespace,
+		Replica:           1,
+	}
+}
+
+// AddFlags registers flags for the render command.
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVarP(&o.File, "file", "f", o.File,
+		"The path of the ClusterManager or Klusterlet to render, or the path of the chart values if --chart is set.")
+	fs.StringVar(&o.Chart, "chart", o.Chart,
+		"The operator chart to render with the values of the file, cluster-manager or klusterlet. The operator and "+
+			"the ClusterManager or Klusterlet are rendered instead of the components.")
+	fs.StringVar(&o.OutputDir, "output-dir", o.OutputDir,
+		"The directory to write the manifests into, the manifests are written to the stdout if it is not set.")
+	fs.StringVar(&o.OperatorNamespace, "operator-namespace", o.OperatorNamespace,
+		"The namespace the operator would run in.")
+	fs.Int32Var(&o.Replica, "replica", o.Replica, "The replica of the deployments.")
+	fs.BoolVar(&o.EnableSyncLabels, "enable-sync-labels", o.EnableSyncLabels,
+		"Sync the labels of the ClusterManager or Klusterlet to the rendered resources.")
+	fs.StringVar(&o.CABundleFile, "ca-bundle-file", o.CABundleFile,
+		"The path of the CA bundle to verify the serving certificates of the hub webhooks, it is required to render "+
+			"a ClusterManager. The serving certificate secrets are not rendered and must be provisioned separately.")
+	fs.StringVar(&o.HubKubeconfig, "hub-kubeconfig", o.HubKubeconfig,
+		"The path of the hub kubeconfig used by the hub components on the management cluster, it is required to "+
+			"render a ClusterManager in the Hosted mode.")
+	fs.StringVar(&o.ManagedKubeconfig, "managed-kubeconfig", o.ManagedKubeconfig,
+		"The path of the managed cluster kubeconfig used by the agents on the management cluster, it is required "+
+			"to render a Klusterlet in the Hosted or SingletonHosted mode.")
+	fs.StringVar(&o.BootstrapKubeconfig, "bootstrap-kubeconfig", o.BootstrapKubeconfig,
+		"The path of the bootstrap kubeconfig of the klusterlet.")
+	fs.StringToStringVar(&o.BootstrapKubeconfigs, "bootstrap-kubeconfigs", o.BootstrapKubeconfigs,
+		"The names of the bootstrap kubeconfig secrets and the paths of the bootstrap kubeconfigs of the klusterlet, "+
+			"in the format of name=path. It is used when the MultipleHubs feature gate is enabled.")
+	fs.StringVar(&o.KubeVersion, "kube-version", o.KubeVersion,
+		"The kubernetes version of the managed cluster, the priority class of the agents is set only if it is set.")
+}
+
+// Run renders the manifests of the ClusterManager or Klusterlet, and writes them into the output directory or the out.
+// In the Hosted mode, the manifests applied on the hub or managed cluster and the management cluster are written
+// into the sub directories of the output directory.
+func (o *Options) Run(ctx context.Context, out io.Writer) error {
+	if len(o.File) == 0 {
+		return errors.New("the file of the ClusterManager or Klusterlet is required")
+	}
+	data, err := os.ReadFile(o.File)
+	if err != nil {
+		return err
+	}
+	if len(o.Chart) > 0 {
+		objects, err := o.renderChart(ctx, data)
+		if err != nil {
+			return err
+		}
+		return o.write(out, []manifestGroup{{objects: objects}})
+	}
+
+	typeMeta := &metav1.TypeMeta{}
+	if err := yaml.Unmarshal(data, typeMeta); err != nil {
+		return fmt.Errorf("invalid file %s: %w", o.File, err)
+	}
+
+	var groups []manifestGroup
+	switch {
+	case typeMeta.GroupVersionKind() == operatorapiv1.SchemeGroupVersion.WithKind("ClusterManager"):
+		clusterManager := &operatorapiv1.ClusterManager{}
+		if err := yaml.UnmarshalStrict(data, clusterManager); err != nil {
+			return fmt.Errorf("invalid ClusterManager %s: %w", o.File, err)
+		}
+		groups, err = o.renderClusterManager(ctx, clusterManager)
+	case typeMeta.GroupVersionKind() == operatorapiv1.SchemeGroupVersion.WithKind("Klusterlet"):
+		klusterlet := &operatorapiv1.Klusterlet{}
+		if err := yaml.UnmarshalStrict(data, klusterlet); err != nil {
+			return fmt.Errorf("invalid Klusterlet %s: %w", o.File, err)
+		}
+		groups, err = o.renderKlusterlet(ctx, klusterlet)
+	default:
+		return fmt.Errorf("unsupported kind %s in %s, only ClusterManager and Klusterlet are supported",
+			typeMeta.GroupVersionKind(), o.File)
+	}
+	if err != nil {
+		return err
+	}
+
+	return o.write(out, groups)
+}
+
+// renderChart renders the operator chart with the values, the crds are rendered before the other objects.
+func (o *Options) renderChart(ctx context.Context, values []byte) ([][]byte, error) {
+	var crdObjects, objects [][]byte
+	var err error
+	switch o.Chart {
+	case chartClusterManager:
+		config := chart.NewDefaultClusterManagerChartConfig()
+		if err := yaml.UnmarshalStrict(values, config); err != nil {
+			return nil, fmt.Errorf("invalid values of the %s chart %s: %w", o.Chart, o.File, err)
+		}
+		crdObjects, objects, err = chart.RenderClusterManagerChart(ctx, config, o.OperatorNamespace)
+	case chartKlusterlet:
+		config := chart.NewDefaultKlusterletChartConfig()
+		if err := yaml.UnmarshalStrict(values, config); err != nil {
+			return nil, fmt.Errorf("invalid values of the %s chart %s: %w", o.Chart, o.File, err)
+		}
+		crdObjects, objects, err = chart.RenderKlusterletChart(ctx, config, o.OperatorNamespace)
+	default:
+		return nil, fmt.Errorf("unsupported chart %s, only %s and %s are supported",
+			o.Chart, chartClusterManager, chartKlusterlet)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(crdObjects, objects...), nil
+}
+
+func (o *Options) renderClusterManager(ctx context.Context, clusterManager *operatorapiv1.ClusterManager) (
+	[]manifestGroup, error) {
+	// the defaults of the crd are not applied without the apiserver, so the images must be set explicitly.
+	if err := requireImages(map[string]string{
+		"registrationImagePullSpec": clusterManager.Spec.RegistrationImagePullSpec,
+		"workImagePullSpec":         clusterManager.Spec.WorkImagePullSpec,
+		"placementImagePullSpec":    clusterManager.Spec.PlacementImagePullSpec,
+		"addOnManagerImagePullSpec": clusterManager.Spec.AddOnManagerImagePullSpec,
+	}); err != nil {
+		return nil, err
+	}
+
+	// the webhooks fail closed, so they cannot be rendered without the CA bundle.
+	if len(o.CABundleFile) == 0 {
+		return nil, errors.New("--ca-bundle-file is required to render the webhooks of the ClusterManager")
+	}
+	caBundle, err := os.ReadFile(o.CABundleFile)
+	if err != nil {
+		return nil, err
+	}
+	hosted := helpers.IsHosted(clusterManager.Spec.DeployOption.Mode)
+	var hubKubeconfig []byte
+	if hosted {
+		if len(o.HubKubeconfig) == 0 {
+			return nil, errors.New("--hub-kubeconfig is required to render the ClusterManager in the Hosted mode")
+		}
+		if hubKubeconfig, err = os.ReadFile(o.HubKubeconfig); err != nil {
+			return nil, err
+		}
+	}
+
+	hubObjects, managementObjects, err := clustermanagercontroller.RenderManifests(ctx, clusterManager,
+		clustermanagercontroller.RenderOptions{
+			OperatorNamespace: o.OperatorNamespace,
+			Replica:           o.Replica,
+			EnableSyncLabels:  o.EnableSyncLabels,
+			CABundle:          caBundle,
+			HubKubeConfig:     hubKubeconfig,
+		})
+	if err != nil {
+		return nil, err
+	}
+	if !hosted {
+		return []manifestGroup{{objects: hubObjects}}, nil
+	}
+	return []manifestGroup{{dir: "hub", objects: hubObjects}, {dir: "management", objects: managementObjects}}, nil
+}
+
+func (o *Options) renderKlusterlet(ctx context.Context, klusterlet *operatorapiv1.Klusterlet) ([]manifestGroup, error) {
+	images := map[string]string{
+		"registrationImagePullSpec": klusterlet.Spec.RegistrationImagePullSpec,
+		"workImagePullSpec":         klusterlet.Spec.WorkImagePullSpec,
+	}
+	if helpers.IsSingleton(klusterlet.Spec.DeployOption.Mode) {
+		images = map[string]string{"imagePullSpec": klusterlet.Spec.ImagePullSpec}
+	}
+	if err := requireImages(images); err != nil {
+		return nil, err
+	}
+
+	bootstrapKubeconfigs := map[string][]byte{}
+	paths := map[string]string{}
+	if len(o.BootstrapKubeconfig) > 0 {
+		paths[helpers.BootstrapHubKubeConfig] = o.BootstrapKubeconfig
+	}
+	for name, path := range o.BootstrapKubeconfigs {
+		paths[name] = path
+	}
+	for name, path := range paths {
+		kubeconfig, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		bootstrapKubeconfigs[name] = kubeconfig
+	}
+
+	var kubeVersion *version.Version
+	if len(o.KubeVersion) > 0 {
+		var err error
+		if kubeVersion, err = version.ParseGeneric(o.KubeVersion); err != nil {
+			return nil, fmt.Errorf("invalid kube version %s: %w", o.KubeVersion, err)
+		}
+	}
+
+	hosted := helpers.IsHosted(klusterlet.Spec.DeployOption.Mode)
+	var managedKubeconfig []byte
+	if hosted {
+		if len(o.ManagedKubeconfig) == 0 {
+			return nil, fmt.Errorf("--managed-kubeconfig is required to render the Klusterlet in the %s mode",
+				klusterlet.Spec.DeployOption.Mode)
+		}
+		var err error
+		if managedKubeconfig, err = os.ReadFile(o.ManagedKubeconfig); err != nil {
+			return nil, err
+		}
+	}
+
+	managedObjects, managementObjects, err := klusterletcontroller.RenderManifests(ctx, klusterlet,
+		klusterletcontroller.RenderOptions{
+			OperatorNamespace:    o.OperatorNamespace,
+			Replica:              o.Replica,
+			KubeVersion:          kubeVersion,
+			EnableSyncLabels:     o.EnableSyncLabels,
+			BootstrapKubeConfigs: bootstrapKubeconfigs,
+			ManagedKubeConfig:    managedKubeconfig,
+		})
+	if err != nil {
+		return nil, err
+	}
+	if !hosted {
+		return []manifestGroup{{objects: managedObjects}}, nil
+	}
+	return []manifestGroup{{dir: "managed", objects: managedObjects}, {dir: "management", objects: managementObjects}}, nil
+}
+
+// write writes each manifest into a file named with its order, kind and name in the directory of its group under the
+// output directory, or writes the manifests as a multi-document yaml into the out if the output directory is not set.
+// The manifests of multiple groups are applied on different clusters, so they can only be written into the output
+// directory.
+func (o *Options) write(out io.Writer, groups []manifestGroup) error {
+	if len(o.OutputDir) == 0 && len(groups) > 1 {
+		return errors.New("--output-dir is required since the manifests are applied on multiple clusters")
+	}
+
+	for _, group := range groups {
+		dir := filepath.Join(o.OutputDir, group.dir)
+		if len(o.OutputDir) > 0 {
+			if err := os.MkdirAll(dir, 0750); err != nil {
+				return err
+			}
+		}
+
+		index := 0
+		for _, objData := range group.objects {
+			objData = bytes.TrimSpace(objData)
+			// a template renders nothing if all of its content is disabled.
+			if len(objData) == 0 {
+				continue
+			}
+
+			if len(o.OutputDir) == 0 {
+				if _, err := fmt.Fprintf(out, "---\n%s\n", objData); err != nil {
+					return err
+				}
+				continue
+			}
+
+			obj := &unstructured.Unstructured{}
+			if err := yaml.Unmarshal(objData, &obj.Object); err != nil {
+				return err
+			}
+			fileName := fmt.Sprintf("%03d-%s-%s.yaml", index, strings.ToLower(obj.GetKind()), obj.GetName())
+			if err := os.WriteFile(filepath.Join(dir, fileName), append(objData, '\n'), 0600); err != nil {
+				return err
+			}
+			index++
+		}
+	}
+	return nil
+}
+
+func requireImages(images map[string]string) error {
+	var missing []string
+	for field, image := range images {
+		if len(image) == 0 {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("%s must be set since the defaults of the api are not applied without the apiserver",
+		strings.Join(missing, ", "))
+}
@@ -0,0 +1,179 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const klusterlet = `apiVersion: operator.open-cluster-management.io/v1
+kind: Klusterlet
+metadata:
+  name: klusterlet
+spec:
+  clusterName: cluster1
+  registrationImagePullSpec: quay.io/open-cluster-management/registration
+  workImagePullSpec: quay.io/open-cluster-management/work
+`
+
+const clusterManager = `apiVersion: operator.open-cluster-management.io/v1
+kind: ClusterManager
+metadata:
+  name: cluster-manager
+spec:
+  registrationImagePullSpec: quay.io/open-cluster-management/registration
+  workImagePullSpec: quay.io/open-cluster-management/work
+  placementImagePullSpec: quay.io/open-cluster-management/placement
+`
+
+const hostedKlusterlet = `apiVersion: operator.open-cluster-management.io/v1
+kind: Klusterlet
+metadata:
+  name: klusterlet
+spec:
+  clusterName: cluster1
+  registrationImagePullSpec: quay.io/open-cluster-management/registration
+  workImagePullSpec: quay.io/open-cluster-management/work
+  deployOption:
+    mode: Hosted
+`
+
+const clusterManagerWithImages = clusterManager + `  addOnManagerImagePullSpec: quay.io/open-cluster-management/addon-manager
+`
+
+func TestRun(t *testing.T) {
+	cases := []struct {
+		name             string
+		content          string
+		chart            string
+		caBundle         bool
+		outputDir        bool
+		expectErr        string
+		expectedContents []string
+		expectedFiles    []string
+	}{
+		{
+			name:    "render klusterlet to stdout",
+			content: klusterlet,
+			expectedContents: []string{
+				"kind: Namespace",
+				"name: bootstrap-hub-kubeconfig",
+				"name: klusterlet-registration-agent",
+			},
+		},
+		{
+			name:      "render klusterlet to directory",
+			content:   klusterlet,
+			outputDir: true,
+			expectedFiles: []string{
+				"000-namespace-open-cluster-management-agent.yaml",
+				"018-secret-bootstrap-hub-kubeconfig.yaml",
+			},
+		},
+		{
+			name:      "cluster manager without images",
+			content:   clusterManager,
+			expectErr: "addOnManagerImagePullSpec must be set",
+		},
+		{
+			name:      "cluster manager without ca bundle",
+			content:   clusterManagerWithImages,
+			expectErr: "--ca-bundle-file is required",
+		},
+		{
+			name:     "render cluster manager",
+			content:  clusterManagerWithImages,
+			caBundle: true,
+			expectedContents: []string{
+				"name: cluster-manager-registration-controller",
+				"kind: ValidatingWebhookConfiguration",
+			},
+		},
+		{
+			name:      "hosted klusterlet to stdout",
+			content:   hostedKlusterlet,
+			expectErr: "--output-dir is required",
+		},
+		{
+			name:      "render hosted klusterlet to directory",
+			content:   hostedKlusterlet,
+			outputDir: true,
+			expectedFiles: []string{
+				"managed/000-namespace-open-cluster-management-agent.yaml",
+				"management/000-namespace-klusterlet.yaml",
+				"management/002-secret-external-managed-kubeconfig-registration.yaml",
+			},
+		},
+		{
+			name:    "render klusterlet chart",
+			content: "klusterlet:\n  clusterName: cluster1\n",
+			chart:   "klusterlet",
+			expectedContents: []string{
+				"name: klusterlets.operator.open-cluster-management.io",
+				"kind: Klusterlet",
+			},
+		},
+		{
+			name:      "unsupported chart",
+			content:   "{}",
+			chart:     "unknown",
+			expectErr: "unsupported chart",
+		},
+		{
+			name:      "unsupported kind",
+			content:   "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\n",
+			expectErr: "unsupported kind",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, "cr.yaml")
+			if err := os.WriteFile(file, []byte(c.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			kubeconfig := filepath.Join(dir, "kubeconfig")
+			if err := os.WriteFile(kubeconfig, []byte("apiVersion: v1\nkind: Config\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			o := NewOptions()
+			o.File = file
+			o.Chart = c.chart
+			o.BootstrapKubeconfig = kubeconfig
+			o.ManagedKubeconfig = kubeconfig
+			if c.caBundle {
+				o.CABundleFile = kubeconfig
+			}
+			if c.outputDir {
+				o.OutputDir = filepath.Join(dir, "output")
+			}
+			out := &bytes.Buffer{}
+			err := o.Run(context.TODO(), out)
+			if len(c.expectErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), c.expectErr) {
+					t.Errorf("expected error %q, but got %v", c.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, expected := range c.expectedContents {
+				if !strings.Contains(out.String(), expected) {
+					t.Errorf("expected %q is rendered", expected)
+				}
+			}
+			for _, expected := range c.expectedFiles {
+				if _, err := os.Stat(filepath.Join(o.OutputDir, expected)); err != nil {
+					t.Errorf("expected file %s is written, but got %v", expected, err)
+				}
+			}
+		})
+	}
+}
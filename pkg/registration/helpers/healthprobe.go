@@ -0,0 +1,16 @@
+package helpers
+
+const (
+	// HealthProbeConditionTypePrefix is the prefix of the ManagedCluster condition types reported by the health probes
+	// of the registration agent, the full condition type is the prefix followed by the probe name.
+	HealthProbeConditionTypePrefix = "healthprobe.open-cluster-management.io/"
+
+	// HealthProbeReasonFailedTaint is the reason of the condition of a failed health probe which is configured to taint
+	// the cluster. The hub taints the cluster with the condition type as the taint key only if the probe condition is
+	// False with this reason, the failures of the other probes are only reported.
+	HealthProbeReasonFailedTaint = "HealthProbeFailedTaint"
+
+	// ReasonHealthProbeFailed is the reason of the ManagedClusterConditionAvailable when it is set to False by the
+	// failed health probes that affect the availability.
+	ReasonHealthProbeFailed = "ManagedClusterHealthProbeFailed"
+)
@@ -0,0 +1,82 @@
+package helpers
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+const (
+	// MaintenanceUntilAnnotationKey is the annotation on a ManagedCluster to declare a planned maintenance. The value is
+	// the end time of the maintenance window in RFC3339 format. During the window, the cluster is tainted by the
+	// MaintenanceTaintKey instead of the unavailable/unreachable taints.
+	MaintenanceUntilAnnotationKey = "cluster.open-cluster-management.io/maintenance-until"
+
+	// ManagedClusterConditionMaintenance is the condition type reporting the maintenance state of a ManagedCluster.
+	ManagedClusterConditionMaintenance = "ManagedClusterMaintenance"
+
+	// MaintenanceTaintKey is the key of the taint added to a managed cluster in maintenance. Its effect is
+	// NoSelectIfNew, so the existing placement decisions are kept, and placements may tolerate it.
+	MaintenanceTaintKey = "cluster.open-cluster-management.io/maintenance"
+
+	// ReasonMaintenanceWindowExceeded is the reason of the maintenance condition when the maintenance lasts for
+	// MaxMaintenanceWindow before the declared end time. The cluster does not enter the maintenance again until the
+	// declared end time passes or the annotation is removed.
+	ReasonMaintenanceWindowExceeded = "ManagedClusterMaintenanceWindowExceeded"
+
+	// MaxMaintenanceWindow is the maximum duration of a maintenance, since the maintenance condition of the cluster
+	// turns true, no matter how late the declared end time is.
+	MaxMaintenanceWindow = 24 * time.Hour
+)
+
+// DeclaredMaintenanceUntil returns the end time of the maintenance window declared on the managed cluster. It returns
+// false if no maintenance is declared or the end time is invalid.
+func DeclaredMaintenanceUntil(cluster *clusterv1.ManagedCluster) (time.Time, bool) {
+	value, ok := cluster.Annotations[MaintenanceUntilAnnotationKey]
+	if !ok {
+		return time.Time{}, false
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// MaintenanceUntil returns the effective end time of the maintenance window of the managed cluster, which is the
+// declared end time limited to MaxMaintenanceWindow since the maintenance started. It returns false if no maintenance
+// is declared or the end time is invalid.
+func MaintenanceUntil(cluster *clusterv1.ManagedCluster) (time.Time, bool) {
+	until, ok := DeclaredMaintenanceUntil(cluster)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	cond := meta.FindStatusCondition(cluster.Status.Conditions, ManagedClusterConditionMaintenance)
+	switch {
+	case cond == nil:
+	case cond.Status == metav1.ConditionTrue:
+		if limit := cond.LastTransitionTime.Add(MaxMaintenanceWindow); limit.Before(until) {
+			until = limit
+		}
+	case cond.Reason == ReasonMaintenanceWindowExceeded:
+		// the maintenance ended when it exceeded the maximum window.
+		if cond.LastTransitionTime.Time.Before(until) {
+			until = cond.LastTransitionTime.Time
+		}
+	}
+	return until, true
+}
+
+// IsInMaintenance returns whether the managed cluster is in its maintenance window at the given time, and the time
+// left until the window ends.
+func IsInMaintenance(cluster *clusterv1.ManagedCluster, now time.Time) (bool, time.Duration) {
+	until, ok := MaintenanceUntil(cluster)
+	if !ok || !now.Before(until) {
+		return false, 0
+	}
+	return true, until.Sub(now)
+}
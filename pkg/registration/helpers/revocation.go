@@ -0,0 +1,37 @@
+package helpers
+
+import (
+	"time"
+
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+const (
+	// RevokeCredentialsIssuedBeforeAnnotationKey is the annotation on a ManagedCluster to force the rotation of the
+	// cluster credentials. The value is a time in RFC3339 format, the agents renew their credentials issued before the
+	// time once the time is passed, and the hub revokes the credentials issued before the time after they are renewed.
+	RevokeCredentialsIssuedBeforeAnnotationKey = "cluster.open-cluster-management.io/revoke-credentials-issued-before"
+
+	// certificateBackdate is the duration the kube signers backdate the NotBefore of the issued certificates to
+	// tolerate the clock skew.
+	certificateBackdate = 5 * time.Minute
+)
+
+// RevokeCredentialsIssuedBefore returns the time before which the credentials of the cluster are revoked, it returns
+// false if the annotation is not set or invalid.
+func RevokeCredentialsIssuedBefore(cluster *clusterv1.ManagedCluster) (time.Time, bool) {
+	value, ok := cluster.Annotations[RevokeCredentialsIssuedBeforeAnnotationKey]
+	if !ok {
+		return time.Time{}, false
+	}
+	issuedBefore, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return issuedBefore, true
+}
+
+// CertificateIssuedAt returns the time a certificate is issued from its NotBefore.
+func CertificateIssuedAt(notBefore time.Time) time.Time {
+	return notBefore.Add(certificateBackdate)
+}
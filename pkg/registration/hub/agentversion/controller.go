@@ -0,0 +1,276 @@
+package agentversion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	clientset "open-cluster-management.io/api/client/cluster/clientset/versioned"
+	clusterinformerv1 "open-cluster-management.io/api/client/cluster/informers/externalversions/cluster/v1"
+	clusterlisterv1 "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
+	"open-cluster-management.io/sdk-go/pkg/patcher"
+
+	"open-cluster-management.io/ocm/pkg/common/helpers"
+	"open-cluster-management.io/ocm/pkg/common/queue"
+	registrationhelpers "open-cluster-management.io/ocm/pkg/registration/helpers"
+)
+
+const (
+	// ManagedClusterConditionAgentVersionCompatible reports whether the agents of the managed cluster support the
+	// enabled hub features required by the skew policy.
+	ManagedClusterConditionAgentVersionCompatible = "AgentVersionCompatible"
+
+	// AgentVersionSkewedTaintKey is the key of the taint added to the managed cluster whose agents are too old for
+	// the enabled hub features if the action of the skew policy is Taint. Its effect is NoSelectIfNew, so the
+	// existing placement decisions are kept.
+	AgentVersionSkewedTaintKey = "cluster.open-cluster-management.io/agent-version-skewed"
+
+	// inventoryQueueKey is the queue key to aggregate the inventory, it is never a valid cluster name.
+	inventoryQueueKey = "_inventory"
+
+	// the inventory is aggregated at most once in the interval, since it is updated by the changes of any cluster.
+	inventoryInterval = 10 * time.Second
+)
+
+// SkewedTaint is the taint added to the managed cluster whose agents are too old for the enabled hub features.
+var SkewedTaint = clusterv1.Taint{
+	Key:    AgentVersionSkewedTaintKey,
+	Effect: clusterv1.TaintEffectNoSelectIfNew,
+}
+
+// agentVersionController checks the agent versions reported by the managed clusters against the skew policy, and
+// aggregates the agent versions of the fleet into the inventory ConfigMap.
+type agentVersionController struct {
+	patcher         patcher.Patcher[*clusterv1.ManagedCluster, clusterv1.ManagedClusterSpec, clusterv1.ManagedClusterStatus]
+	kubeClient      kubernetes.Interface
+	clusterLister   clusterlisterv1.ManagedClusterLister
+	configMapLister corev1listers.ConfigMapLister
+	namespace       string
+	enabledFeatures sets.Set[string]
+}
+
+// NewAgentVersionController creates a new agent version controller. The skew policy and the inventory are the
+// ConfigMaps in the namespace, and the enabledFeatures are the features enabled on the hub.
+func NewAgentVersionController(
+	kubeClient kubernetes.Interface,
+	clusterClient clientset.Interface,
+	clusterInformer clusterinformerv1.ManagedClusterInformer,
+	configMapInformer corev1informers.ConfigMapInformer,
+	namespace string,
+	enabledFeatures []string) factory.Controller {
+	c := &agentVersionController{
+		patcher: patcher.NewPatcher[
+			*clusterv1.ManagedCluster, clusterv1.ManagedClusterSpec, clusterv1.ManagedClusterStatus](
+			clusterClient.ClusterV1().ManagedClusters()),
+		kubeClient:      kubeClient,
+		clusterLister:   clusterInformer.Lister(),
+		configMapLister: configMapInformer.Lister(),
+		namespace:       namespace,
+		enabledFeatures: sets.New(enabledFeatures...),
+	}
+
+	return factory.New().
+		WithInformersQueueKeysFunc(queue.QueueKeyByMetaName, clusterInformer.Informer()).
+		WithFilteredEventsInformersQueueKeysFunc(
+			queue.QueueKeyByMetaNamespaceName,
+			queue.FilterByNames(helpers.AgentVersionSkewPolicyConfigMap),
+			configMapInformer.Informer()).
+		WithSync(c.sync).
+		ToController("AgentVersionController")
+}
+
+func (c *agentVersionController) sync(ctx context.Context, syncCtx factory.SyncContext, key string) error {
+	switch {
+	case key == inventoryQueueKey:
+		return c.syncInventory(ctx)
+	case strings.Contains(key, "/"):
+		// the skew policy is changed, recheck all clusters.
+		if _, err := c.getPolicy(); err != nil {
+			syncCtx.Recorder().Warningf(ctx, "AgentVersionSkewPolicyInvalid", "%v", err)
+		}
+		clusters, err := c.clusterLister.List(labels.Everything())
+		if err != nil {
+			return err
+		}
+		for _, cluster := range clusters {
+			syncCtx.Queue().Add(cluster.Name)
+		}
+		syncCtx.Queue().Add(inventoryQueueKey)
+		return nil
+	default:
+		// the inventory is aggregated after the cluster is checked.
+		syncCtx.Queue().AddAfter(inventoryQueueKey, inventoryInterval)
+		return c.syncCluster(ctx, key)
+	}
+}
+
+func (c *agentVersionController) syncCluster(ctx context.Context, clusterName string) error {
+	logger := klog.FromContext(ctx).WithValues("managedClusterName", clusterName)
+	cluster, err := c.clusterLister.Get(clusterName)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !cluster.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	policy, err := c.getPolicy()
+	if err != nil {
+		// keep the clusters unchanged until the policy is fixed.
+		logger.Error(err, "Failed to get the agent version skew policy")
+		return nil
+	}
+
+	newCluster := cluster.DeepCopy()
+	if policy == nil {
+		registrationhelpers.RemoveTaints(&newCluster.Spec.Taints, SkewedTaint)
+		meta.RemoveStatusCondition(&newCluster.Status.Conditions, ManagedClusterConditionAgentVersionCompatible)
+	} else {
+		unsupported := policy.UnsupportedFeatures(helpers.GetAgentVersions(cluster))
+		var reasons []string
+		for _, feature := range sets.List(sets.KeySet(unsupported)) {
+			if c.enabledFeatures.Has(feature) {
+				reasons = append(reasons, fmt.Sprintf("%s: %s", feature, unsupported[feature]))
+			}
+		}
+
+		condition := metav1.Condition{
+			Type:    ManagedClusterConditionAgentVersionCompatible,
+			Status:  metav1.ConditionTrue,
+			Reason:  "AgentVersionsSupported",
+			Message: "The agents support all enabled hub features",
+		}
+		if len(reasons) > 0 {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "AgentVersionsSkewed"
+			condition.Message = fmt.Sprintf("The agents are too old for the enabled hub features, %s",
+				strings.Join(reasons, ", "))
+		}
+		meta.SetStatusCondition(&newCluster.Status.Conditions, condition)
+
+		if policy.Action == helpers.SkewActionTaint && len(reasons) > 0 {
+			registrationhelpers.AddTaints(&newCluster.Spec.Taints, SkewedTaint)
+		} else {
+			registrationhelpers.RemoveTaints(&newCluster.Spec.Taints, SkewedTaint)
+		}
+	}
+
+	// the status is updated after the taints are updated, since both patches require the latest resource version.
+	if updated, err := c.patcher.PatchSpec(ctx, newCluster, newCluster.Spec, cluster.Spec); updated {
+		return err
+	}
+	_, err = c.patcher.PatchStatus(ctx, newCluster, newCluster.Status, cluster.Status)
+	return err
+}
+
+// syncInventory aggregates the number of clusters running each agent version, and the clusters that cannot support
+// each feature of the skew policy, into the inventory ConfigMap. Only the counts and the first names of the clusters
+// are recorded, so the inventory does not grow with the fleet.
+func (c *agentVersionController) syncInventory(ctx context.Context) error {
+	clusters, err := c.clusterLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	policy, err := c.getPolicy()
+	if err != nil {
+		klog.FromContext(ctx).Error(err, "Failed to get the agent version skew policy")
+	}
+
+	// the clusters are sorted, so the recorded cluster names are the first ones in alphabetical order.
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Name < clusters[j].Name })
+	agentVersions := map[string]map[string]int{}
+	unsupportedFeatures := map[string]*helpers.UnsupportedFeature{}
+	for _, cluster := range clusters {
+		versions := helpers.GetAgentVersions(cluster)
+		for component, agentVersion := range versions {
+			if _, ok := agentVersions[component]; !ok {
+				agentVersions[component] = map[string]int{}
+			}
+			agentVersions[component][agentVersion]++
+		}
+		if policy == nil {
+			continue
+		}
+		for feature := range policy.UnsupportedFeatures(versions) {
+			unsupported, ok := unsupportedFeatures[feature]
+			if !ok {
+				unsupported = &helpers.UnsupportedFeature{}
+				unsupportedFeatures[feature] = unsupported
+			}
+			unsupported.Count++
+			if len(unsupported.Clusters) < helpers.MaxInventoryClusters {
+				unsupported.Clusters = append(unsupported.Clusters, cluster.Name)
+			}
+		}
+	}
+
+	versionsData, err := json.Marshal(agentVersions)
+	if err != nil {
+		return err
+	}
+	featuresData, err := json.Marshal(unsupportedFeatures)
+	if err != nil {
+		return err
+	}
+	data := map[string]string{
+		helpers.AgentVersionsKey:       string(versionsData),
+		helpers.UnsupportedFeaturesKey: string(featuresData),
+	}
+
+	inventory, err := c.configMapLister.ConfigMaps(c.namespace).Get(helpers.AgentVersionInventoryConfigMap)
+	switch {
+	case errors.IsNotFound(err):
+		_, err = c.kubeClient.CoreV1().ConfigMaps(c.namespace).Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: helpers.AgentVersionInventoryConfigMap, Namespace: c.namespace},
+			Data:       data,
+		}, metav1.CreateOptions{})
+		return err
+	case err != nil:
+		return err
+	case inventory.Data[helpers.AgentVersionsKey] == data[helpers.AgentVersionsKey] &&
+		inventory.Data[helpers.UnsupportedFeaturesKey] == data[helpers.UnsupportedFeaturesKey]:
+		return nil
+	}
+
+	inventory = inventory.DeepCopy()
+	inventory.Data = data
+	_, err = c.kubeClient.CoreV1().ConfigMaps(c.namespace).Update(ctx, inventory, metav1.UpdateOptions{})
+	return err
+}
+
+// getPolicy returns the skew policy, nil if the policy ConfigMap does not exist.
+func (c *agentVersionController) getPolicy() (*helpers.AgentVersionSkewPolicy, error) {
+	configMap, err := c.configMapLister.ConfigMaps(c.namespace).Get(helpers.AgentVersionSkewPolicyConfigMap)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	policy, err := helpers.ParseAgentVersionSkewPolicy([]byte(configMap.Data[helpers.AgentVersionSkewPolicyKey]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid agent version skew policy %s: %w",
+			cache.NewObjectName(configMap.Namespace, configMap.Name), err)
+	}
+	return policy, nil
+}
@@ -0,0 +1,258 @@
+package agentversion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	kubeinformers "k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterinformers "open-cluster-management.io/api/client/cluster/informers/externalversions"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"open-cluster-management.io/sdk-go/pkg/patcher"
+
+	"open-cluster-management.io/ocm/pkg/common/helpers"
+	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
+	testinghelpers "open-cluster-management.io/ocm/pkg/registration/helpers/testing"
+)
+
+const testNamespace = "open-cluster-management-hub"
+
+func TestSyncCluster(t *testing.T) {
+	cases := []struct {
+		name            string
+		cluster         *clusterv1.ManagedCluster
+		policy          string
+		enabledFeatures []string
+		validateActions func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name:            "no policy",
+			cluster:         newCluster("v0.15.0"),
+			enabledFeatures: []string{"ManifestWorkReplicaSet"},
+			validateActions: testingcommon.AssertNoActions,
+		},
+		{
+			name:            "agents support the enabled features",
+			cluster:         newCluster("v0.16.0"),
+			policy:          testPolicy("Warn"),
+			enabledFeatures: []string{"ManifestWorkReplicaSet"},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				assertCondition(t, actions[0], metav1.ConditionTrue)
+			},
+		},
+		{
+			name:    "feature is not enabled",
+			cluster: newCluster("v0.15.0"),
+			policy:  testPolicy("Taint"),
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				assertCondition(t, actions[0], metav1.ConditionTrue)
+			},
+		},
+		{
+			name:            "warn the skewed cluster",
+			cluster:         newCluster("v0.15.0"),
+			policy:          testPolicy("Warn"),
+			enabledFeatures: []string{"ManifestWorkReplicaSet"},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				assertCondition(t, actions[0], metav1.ConditionFalse)
+			},
+		},
+		{
+			name:            "taint the skewed cluster",
+			cluster:         newCluster("v0.15.0"),
+			policy:          testPolicy("Taint"),
+			enabledFeatures: []string{"ManifestWorkReplicaSet"},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				cluster := &clusterv1.ManagedCluster{}
+				if err := json.Unmarshal(actions[0].(clienttesting.PatchAction).GetPatch(), cluster); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(cluster.Spec.Taints, []clusterv1.Taint{SkewedTaint}) {
+					t.Errorf("expected the skewed taint, but got %v", cluster.Spec.Taints)
+				}
+			},
+		},
+		{
+			name: "remove the taint once the agents are upgraded",
+			cluster: func() *clusterv1.ManagedCluster {
+				cluster := newCluster("v0.16.0")
+				cluster.Spec.Taints = []clusterv1.Taint{SkewedTaint}
+				return cluster
+			}(),
+			policy:          testPolicy("Taint"),
+			enabledFeatures: []string{"ManifestWorkReplicaSet"},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				cluster := &clusterv1.ManagedCluster{}
+				if err := json.Unmarshal(actions[0].(clienttesting.PatchAction).GetPatch(), cluster); err != nil {
+					t.Fatal(err)
+				}
+				if len(cluster.Spec.Taints) != 0 {
+					t.Errorf("expected the taint is removed, but got %v", cluster.Spec.Taints)
+				}
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var configMaps []runtime.Object
+			if len(c.policy) > 0 {
+				configMaps = append(configMaps, newPolicy(c.policy))
+			}
+			ctrl, clusterClient, _ := newTestController(t, []runtime.Object{c.cluster}, configMaps, c.enabledFeatures)
+			if err := ctrl.syncCluster(context.TODO(), c.cluster.Name); err != nil {
+				t.Fatal(err)
+			}
+			c.validateActions(t, clusterClient.Actions())
+		})
+	}
+}
+
+func TestSyncInventory(t *testing.T) {
+	clusters := []runtime.Object{newCluster("v0.15.0"), newCluster("v0.16.0")}
+	clusters[1].(*clusterv1.ManagedCluster).Name = "cluster2"
+	ctrl, _, kubeClient := newTestController(t, clusters, []runtime.Object{newPolicy(testPolicy("Warn"))}, nil)
+	if err := ctrl.sync(context.TODO(), testingcommon.NewFakeSyncContext(t, inventoryQueueKey), inventoryQueueKey); err != nil {
+		t.Fatal(err)
+	}
+
+	inventory, err := kubeClient.CoreV1().ConfigMaps(testNamespace).Get(
+		context.TODO(), helpers.AgentVersionInventoryConfigMap, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsupportedFeatures := map[string]helpers.UnsupportedFeature{}
+	if err := json.Unmarshal([]byte(inventory.Data[helpers.UnsupportedFeaturesKey]), &unsupportedFeatures); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]helpers.UnsupportedFeature{
+		"ManifestWorkReplicaSet": {Count: 1, Clusters: []string{testinghelpers.TestManagedClusterName}},
+	}
+	if !reflect.DeepEqual(unsupportedFeatures, expected) {
+		t.Errorf("expected unsupported features %v, but got %v", expected, unsupportedFeatures)
+	}
+	agentVersions := map[string]map[string]int{}
+	if err := json.Unmarshal([]byte(inventory.Data[helpers.AgentVersionsKey]), &agentVersions); err != nil {
+		t.Fatal(err)
+	}
+	expectedVersions := map[string]map[string]int{
+		helpers.RegistrationAgentComponent: {"v0.16.0": 2},
+		helpers.WorkAgentComponent:         {"v0.15.0": 1, "v0.16.0": 1},
+	}
+	if !reflect.DeepEqual(agentVersions, expectedVersions) {
+		t.Errorf("expected agent versions %v, but got %v", expectedVersions, agentVersions)
+	}
+}
+
+func TestSyncInventoryLimitsClusters(t *testing.T) {
+	var clusters []runtime.Object
+	for i := 0; i < helpers.MaxInventoryClusters+5; i++ {
+		cluster := newCluster("v0.15.0")
+		cluster.Name = fmt.Sprintf("cluster%02d", i)
+		clusters = append(clusters, cluster)
+	}
+	ctrl, _, kubeClient := newTestController(t, clusters, []runtime.Object{newPolicy(testPolicy("Warn"))}, nil)
+	if err := ctrl.syncInventory(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+
+	inventory, err := kubeClient.CoreV1().ConfigMaps(testNamespace).Get(
+		context.TODO(), helpers.AgentVersionInventoryConfigMap, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsupportedFeatures := map[string]helpers.UnsupportedFeature{}
+	if err := json.Unmarshal([]byte(inventory.Data[helpers.UnsupportedFeaturesKey]), &unsupportedFeatures); err != nil {
+		t.Fatal(err)
+	}
+	unsupported := unsupportedFeatures["ManifestWorkReplicaSet"]
+	if unsupported.Count != helpers.MaxInventoryClusters+5 {
+		t.Errorf("expected %d clusters, but got %d", helpers.MaxInventoryClusters+5, unsupported.Count)
+	}
+	if len(unsupported.Clusters) != helpers.MaxInventoryClusters || unsupported.Clusters[0] != "cluster00" {
+		t.Errorf("expected the first %d clusters, but got %v", helpers.MaxInventoryClusters, unsupported.Clusters)
+	}
+}
+
+func newTestController(t *testing.T, clusters, configMaps []runtime.Object, enabledFeatures []string) (
+	*agentVersionController, *clusterfake.Clientset, *kubefake.Clientset) {
+	clusterClient := clusterfake.NewSimpleClientset(clusters...)
+	clusterInformerFactory := clusterinformers.NewSharedInformerFactory(clusterClient, time.Minute*10)
+	for _, cluster := range clusters {
+		if err := clusterInformerFactory.Cluster().V1().ManagedClusters().Informer().GetStore().Add(cluster); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kubeClient := kubefake.NewSimpleClientset(configMaps...)
+	kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubeClient, time.Minute*10)
+	for _, configMap := range configMaps {
+		if err := kubeInformerFactory.Core().V1().ConfigMaps().Informer().GetStore().Add(configMap); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &agentVersionController{
+		patcher: patcher.NewPatcher[
+			*clusterv1.ManagedCluster, clusterv1.ManagedClusterSpec, clusterv1.ManagedClusterStatus](
+			clusterClient.ClusterV1().ManagedClusters()),
+		kubeClient:      kubeClient,
+		clusterLister:   clusterInformerFactory.Cluster().V1().ManagedClusters().Lister(),
+		configMapLister: kubeInformerFactory.Core().V1().ConfigMaps().Lister(),
+		namespace:       testNamespace,
+		enabledFeatures: sets.New(enabledFeatures...),
+	}, clusterClient, kubeClient
+}
+
+func newCluster(workVersion string) *clusterv1.ManagedCluster {
+	cluster := testinghelpers.NewAvailableManagedCluster()
+	cluster.Status.ClusterClaims = []clusterv1.ManagedClusterClaim{
+		{Name: helpers.AgentVersionClaimName(helpers.RegistrationAgentComponent), Value: "v0.16.0"},
+		{Name: helpers.AgentVersionClaimName(helpers.WorkAgentComponent), Value: workVersion},
+	}
+	return cluster
+}
+
+func testPolicy(action string) string {
+	return `
+action: ` + action + `
+requirements:
+- feature: ManifestWorkReplicaSet
+  minAgentVersions:
+    work: v0.16.0
+`
+}
+
+func newPolicy(policy string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: helpers.AgentVersionSkewPolicyConfigMap, Namespace: testNamespace},
+		Data:       map[string]string{helpers.AgentVersionSkewPolicyKey: policy},
+	}
+}
+
+func assertCondition(t *testing.T, action clienttesting.Action, status metav1.ConditionStatus) {
+	cluster := &clusterv1.ManagedCluster{}
+	if err := json.Unmarshal(action.(clienttesting.PatchAction).GetPatch(), cluster); err != nil {
+		t.Fatal(err)
+	}
+	if !meta.IsStatusConditionPresentAndEqual(cluster.Status.Conditions, ManagedClusterConditionAgentVersionCompatible, status) {
+		t.Errorf("expected condition %s is %s, but got %v", ManagedClusterConditionAgentVersionCompatible, status,
+			cluster.Status.Conditions)
+	}
+}
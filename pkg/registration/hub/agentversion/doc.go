@@ -0,0 +1,13 @@
+// package agentversion checks the versions of the agents on the managed clusters against the agent version skew
+// policy of the hub.
+//
+// The agents report their versions in the cluster claims with the helpers.AgentVersionClaimSuffix suffix. The skew
+// policy is the ConfigMap helpers.AgentVersionSkewPolicyConfigMap in the hub namespace, which declares the min agent
+// versions required by the hub features. A cluster whose agents are too old for the features enabled on the hub is
+// reported in the AgentVersionCompatible condition, and is tainted if the action of the policy is Taint. An agent
+// that does not report its version is skipped. The number of clusters running each agent version and the clusters
+// that cannot support each feature of the policy are aggregated into the ConfigMap
+// helpers.AgentVersionInventoryConfigMap, which is used by the cluster manager operator to refuse enabling the
+// features the fleet cannot support yet. The agent versions are only checked if the AgentVersionSkew feature gate of
+// the hub registration is enabled.
+package agentversion
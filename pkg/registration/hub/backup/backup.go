@@ -0,0 +1,167 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	clusterv1beta1 "open-cluster-management.io/api/cluster/v1beta1"
+	clusterv1beta2 "open-cluster-management.io/api/cluster/v1beta2"
+	workv1 "open-cluster-management.io/api/work/v1"
+	workv1alpha1 "open-cluster-management.io/api/work/v1alpha1"
+
+	"open-cluster-management.io/ocm/pkg/common/helpers"
+)
+
+// Resources are the hub resources in the backup, in the order they are restored. The ManagedClusters are restored
+// before the resources in the cluster namespaces, and the ManifestWorks are restored at last, so the work agents
+// adopt the restored ManifestWorks once the clusters are accepted.
+var Resources = []schema.GroupVersionResource{
+	clusterv1beta2.SchemeGroupVersion.WithResource("managedclustersets"),
+	clusterv1.SchemeGroupVersion.WithResource("managedclusters"),
+	clusterv1beta2.SchemeGroupVersion.WithResource("managedclustersetbindings"),
+	clusterv1beta1.SchemeGroupVersion.WithResource("placements"),
+	addonv1alpha1.SchemeGroupVersion.WithResource("addontemplates"),
+	addonv1alpha1.SchemeGroupVersion.WithResource("addondeploymentconfigs"),
+	addonv1alpha1.SchemeGroupVersion.WithResource("clustermanagementaddons"),
+	addonv1alpha1.SchemeGroupVersion.WithResource("managedclusteraddons"),
+	workv1alpha1.SchemeGroupVersion.WithResource("manifestworkreplicasets"),
+	workv1.SchemeGroupVersion.WithResource("manifestworks"),
+}
+
+// Backup is the exported state of a hub.
+type Backup struct {
+	// HubHash is the hub hash of the backed up hub, which is computed by helper.HubHash from the hub API server URL
+	// the agents connect to. The work agents use it to name the AppliedManifestWorks of the hub.
+	HubHash string `json:"hubHash"`
+
+	// Resources maps the resource, in the form of resource.version.group, to the exported objects.
+	Resources map[string][]*unstructured.Unstructured `json:"resources"`
+}
+
+// Export lists the hub resources and strips the fields that are owned by the backed up hub, including the status.
+// Each resource is listed once, so the objects of a resource are consistent with each other. The objects that are
+// being deleted are not exported, and the resources whose CRDs are not installed are skipped.
+func Export(ctx context.Context, client dynamic.Interface, hubHash string) (*Backup, error) {
+	logger := klog.FromContext(ctx)
+	backup := &Backup{HubHash: hubHash, Resources: map[string][]*unstructured.Unstructured{}}
+	for _, gvr := range Resources {
+		list, err := client.Resource(gvr).List(ctx, metav1.ListOptions{})
+		if errors.IsNotFound(err) {
+			logger.Info("Resource is not served on the hub, skip it", "resource", resourceKey(gvr))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", resourceKey(gvr), err)
+		}
+
+		objects := []*unstructured.Unstructured{}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if obj.GetDeletionTimestamp() != nil {
+				continue
+			}
+			objects = append(objects, sanitize(obj))
+		}
+		backup.Resources[resourceKey(gvr)] = objects
+		logger.Info("Resource is exported", "resource", resourceKey(gvr), "count", len(objects))
+	}
+	return backup, nil
+}
+
+// Restore creates the objects of the backup on the hub in the order of the Resources, the existing objects are kept
+// unchanged so the restore can be retried. The ManagedClusters and the ManifestWorks are annotated with the hub hash
+// of the backup, so the registration controller approves the CSRs of the restored clusters, and the work agents
+// adopt the workloads applied by the backed up hub rather than evicting them.
+func Restore(ctx context.Context, client dynamic.Interface, kubeClient kubernetes.Interface, backup *Backup) error {
+	logger := klog.FromContext(ctx)
+	namespaces := sets.New[string]()
+	var errs []error
+	for _, gvr := range Resources {
+		objects, ok := backup.Resources[resourceKey(gvr)]
+		if !ok {
+			continue
+		}
+
+		created := 0
+		for _, obj := range objects {
+			obj = obj.DeepCopy()
+			if len(backup.HubHash) > 0 && (gvr.Resource == "managedclusters" || gvr.Resource == "manifestworks") {
+				annotations := obj.GetAnnotations()
+				if annotations == nil {
+					annotations = map[string]string{}
+				}
+				annotations[helpers.RestoredFromHubAnnotationKey] = backup.HubHash
+				obj.SetAnnotations(annotations)
+			}
+
+			var resourceClient dynamic.ResourceInterface = client.Resource(gvr)
+			if namespace := obj.GetNamespace(); len(namespace) > 0 {
+				if !namespaces.Has(namespace) {
+					if err := ensureNamespace(ctx, kubeClient, namespace); err != nil {
+						errs = append(errs, err)
+						continue
+					}
+					namespaces.Insert(namespace)
+				}
+				resourceClient = client.Resource(gvr).Namespace(namespace)
+			}
+
+			_, err := resourceClient.Create(ctx, obj, metav1.CreateOptions{})
+			switch {
+			case errors.IsAlreadyExists(err):
+				logger.V(4).Info("Object already exists, skip it", "resource", resourceKey(gvr),
+					"namespace", obj.GetNamespace(), "name", obj.GetName())
+			case err != nil:
+				errs = append(errs, fmt.Errorf("failed to restore %s %s/%s: %w",
+					resourceKey(gvr), obj.GetNamespace(), obj.GetName(), err))
+			default:
+				created++
+			}
+		}
+		logger.Info("Resource is restored", "resource", resourceKey(gvr), "created", created, "count", len(objects))
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// sanitize removes the fields that are set by the backed up hub. The owner references and the finalizers are
+// removed as well since the uids of the owners change, and the controllers on the new hub add them back.
+func sanitize(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	obj = obj.DeepCopy()
+	obj.SetUID("")
+	obj.SetResourceVersion("")
+	obj.SetGeneration(0)
+	obj.SetCreationTimestamp(metav1.Time{})
+	obj.SetManagedFields(nil)
+	obj.SetSelfLink("")
+	obj.SetOwnerReferences(nil)
+	obj.SetFinalizers(nil)
+	unstructured.RemoveNestedField(obj.Object, "status")
+	return obj
+}
+
+func ensureNamespace(ctx context.Context, kubeClient kubernetes.Interface, namespace string) error {
+	_, err := kubeClient.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: namespace},
+	}, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create namespace %s: %w", namespace, err)
+	}
+	return nil
+}
+
+func resourceKey(gvr schema.GroupVersionResource) string {
+	return fmt.Sprintf("%s.%s.%s", gvr.Resource, gvr.Version, gvr.Group)
+}
@@ -0,0 +1,123 @@
+package backup
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/yaml"
+
+	"open-cluster-management.io/ocm/pkg/common/helpers"
+	"open-cluster-management.io/ocm/pkg/work/helper"
+)
+
+func TestExportAndRestore(t *testing.T) {
+	cluster := newObject("cluster.open-cluster-management.io/v1", "ManagedCluster", "", "cluster1")
+	cluster.Object["spec"] = map[string]interface{}{"hubAcceptsClient": true}
+	cluster.Object["status"] = map[string]interface{}{"version": map[string]interface{}{"kubernetes": "v1.30.0"}}
+	cluster.SetFinalizers([]string{"cluster.open-cluster-management.io/api-resource-cleanup"})
+	work := newObject("work.open-cluster-management.io/v1", "ManifestWork", "cluster1", "work1")
+	work.SetOwnerReferences([]metav1.OwnerReference{{Kind: "ManifestWorkReplicaSet", Name: "mwrs", UID: "123"}})
+	deleting := newObject("work.open-cluster-management.io/v1", "ManifestWork", "cluster1", "work2")
+	now := metav1.Now()
+	deleting.SetDeletionTimestamp(&now)
+
+	backup, err := Export(context.TODO(), newDynamicClient(cluster, work, deleting), helper.HubHash("https://hub:6443"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clusters := backup.Resources["managedclusters.v1.cluster.open-cluster-management.io"]
+	if len(clusters) != 1 {
+		t.Fatalf("expected 1 cluster, but got %d", len(clusters))
+	}
+	if len(clusters[0].GetUID()) > 0 || len(clusters[0].GetResourceVersion()) > 0 ||
+		len(clusters[0].GetFinalizers()) > 0 {
+		t.Errorf("expected the fields of the hub are removed, but got %v", clusters[0].Object)
+	}
+	if _, found := clusters[0].Object["status"]; found {
+		t.Errorf("expected the status is removed, but got %v", clusters[0].Object)
+	}
+	works := backup.Resources["manifestworks.v1.work.open-cluster-management.io"]
+	if len(works) != 1 || len(works[0].GetOwnerReferences()) > 0 {
+		t.Fatalf("expected the work without owners, but got %v", works)
+	}
+
+	// the backup is restored from the file content.
+	data, err := yaml.Marshal(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := &Backup{}
+	if err := yaml.Unmarshal(data, restored); err != nil {
+		t.Fatal(err)
+	}
+
+	dynamicClient := newDynamicClient()
+	kubeClient := kubefake.NewSimpleClientset()
+	if err := Restore(context.TODO(), dynamicClient, kubeClient, restored); err != nil {
+		t.Fatal(err)
+	}
+	// restore again to verify the existing objects are skipped.
+	if err := Restore(context.TODO(), dynamicClient, kubeClient, restored); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := kubeClient.CoreV1().Namespaces().Get(context.TODO(), "cluster1", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the cluster namespace is created, but got %v", err)
+	}
+	restoredCluster, err := dynamicClient.Resource(schema.GroupVersionResource{
+		Group: "cluster.open-cluster-management.io", Version: "v1", Resource: "managedclusters",
+	}).Get(context.TODO(), "cluster1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restoredCluster.GetAnnotations()[helpers.RestoredFromHubAnnotationKey] != helper.HubHash("https://hub:6443") {
+		t.Errorf("expected the cluster is annotated with the hub hash, but got %v", restoredCluster.GetAnnotations())
+	}
+	if accepted, _, _ := unstructured.NestedBool(restoredCluster.Object, "spec", "hubAcceptsClient"); !accepted {
+		t.Errorf("expected the acceptance is kept")
+	}
+	restoredWork, err := dynamicClient.Resource(schema.GroupVersionResource{
+		Group: "work.open-cluster-management.io", Version: "v1", Resource: "manifestworks",
+	}).Namespace("cluster1").Get(context.TODO(), "work1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restoredWork.GetAnnotations()[helpers.RestoredFromHubAnnotationKey]) == 0 {
+		t.Errorf("expected the work is annotated with the hub hash")
+	}
+}
+
+func newObject(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetUID("uid")
+	obj.SetResourceVersion("10")
+	return obj
+}
+
+func newDynamicClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	listKinds := map[schema.GroupVersionResource]string{}
+	for _, gvr := range Resources {
+		listKinds[gvr] = "List"
+	}
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objects...)
+}
+
+func TestRunBackupRequiresHubServer(t *testing.T) {
+	opts := NewOptions()
+	opts.File = "backup.yaml"
+	if err := opts.RunBackup(context.TODO()); err == nil || !strings.Contains(err.Error(), "hub server") {
+		t.Errorf("expected the hub server is required, but got %v", err)
+	}
+}
@@ -0,0 +1,14 @@
+// package backup exports the OCM state of a hub into a backup file, and restores it onto a new hub.
+//
+// The backup contains the ManagedClusters, the cluster sets and bindings, the Placements, the addon resources and the
+// ManifestWorks, without the status and the fields owned by the backed up hub, and the hub hash of the backed up hub.
+// The restored ManagedClusters keep the acceptance, and the restored ManagedClusters and ManifestWorks are annotated
+// with helpers.RestoredFromHubAnnotationKey, so that:
+//   - the registration controller approves the CSRs of the restored clusters until their agents rejoin the new hub,
+//     in a limited period after the restore, if the RestoredClusterCSRApproval feature gate is enabled. A CSR is only
+//     approved if its agent ID is the one recorded on the cluster by the backed up hub with
+//     helpers.AgentIDAnnotationKey. The agents started with --hub-credential-rejected-timeout rebootstrap once the
+//     new hub rejects their client certificates signed by the old hub;
+//   - the work agents adopt the AppliedManifestWorks named with the hub hash of the backed up hub, instead of
+//     evicting the workloads on the managed clusters.
+package backup
@@ -0,0 +1,104 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
+
+	"open-cluster-management.io/ocm/pkg/work/helper"
+)
+
+// Options holds the configuration of the hub backup and restore commands.
+type Options struct {
+	// Kubeconfig is the kubeconfig of the hub, the in-cluster config is used if it is empty.
+	Kubeconfig string
+	// File is the path of the backup file.
+	File string
+	// HubServer is the hub API server URL in the hub kubeconfigs of the agents, it is used to compute the hub hash of
+	// the backed up hub. It is required by the backup, since the server of the kubeconfig used by the command is not
+	// necessarily the one the agents connect to.
+	HubServer string
+}
+
+func NewOptions() *Options {
+	return &Options{}
+}
+
+// AddFlags registers flags for the backup and restore commands.
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Kubeconfig, "kubeconfig", o.Kubeconfig,
+		"The path of the kubeconfig of the hub, the in-cluster config is used if it is not set.")
+	fs.StringVar(&o.File, "file", o.File, "The path of the backup file.")
+}
+
+// AddBackupFlags registers flags for the backup command.
+func (o *Options) AddBackupFlags(fs *pflag.FlagSet) {
+	o.AddFlags(fs)
+	fs.StringVar(&o.HubServer, "hub-server", o.HubServer,
+		"The hub API server URL in the hub kubeconfigs of the agents, which is used to compute the hub hash.")
+}
+
+// RunBackup exports the hub resources into the backup file.
+func (o *Options) RunBackup(ctx context.Context) error {
+	if len(o.File) == 0 {
+		return errors.New("the backup file is required")
+	}
+	if len(o.HubServer) == 0 {
+		return errors.New("the hub server is required")
+	}
+	config, err := clientcmd.BuildConfigFromFlags("", o.Kubeconfig)
+	if err != nil {
+		return err
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	backup, err := Export(ctx, dynamicClient, helper.HubHash(o.HubServer))
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(backup)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(o.File, data, 0600)
+}
+
+// RunRestore restores the hub resources from the backup file.
+func (o *Options) RunRestore(ctx context.Context) error {
+	if len(o.File) == 0 {
+		return errors.New("the backup file is required")
+	}
+	data, err := os.ReadFile(o.File)
+	if err != nil {
+		return err
+	}
+	backup := &Backup{}
+	if err := yaml.Unmarshal(data, backup); err != nil {
+		return fmt.Errorf("invalid backup file %s: %w", o.File, err)
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", o.Kubeconfig)
+	if err != nil {
+		return err
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	return Restore(ctx, dynamicClient, kubeClient, backup)
+}
@@ -0,0 +1,159 @@
+package diagnostics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/ocm/pkg/common/diagnostics"
+)
+
+const clusterNameLabelKey = "open-cluster-management.io/cluster-name"
+
+var (
+	managedClusterGVR = clusterv1.SchemeGroupVersion.WithResource("managedclusters")
+	spokeArchivePoll  = 2 * time.Second
+)
+
+// hubResource is a resource of the cluster collected into the hub part of the bundle.
+type hubResource struct {
+	file string
+	gvr  schema.GroupVersionResource
+	// namespaced resources are listed in the cluster namespace, and the others are listed with the options.
+	namespaced bool
+	options    func(clusterName string) metav1.ListOptions
+}
+
+var hubResources = []hubResource{
+	{
+		file: "hub/managedcluster.yaml",
+		gvr:  managedClusterGVR,
+		options: func(clusterName string) metav1.ListOptions {
+			return metav1.ListOptions{FieldSelector: "metadata.name=" + clusterName}
+		},
+	},
+	{
+		file:       "hub/managedclusteraddons.yaml",
+		gvr:        addonv1alpha1.SchemeGroupVersion.WithResource("managedclusteraddons"),
+		namespaced: true,
+	},
+	{
+		file:       "hub/manifestworks.yaml",
+		gvr:        workv1.SchemeGroupVersion.WithResource("manifestworks"),
+		namespaced: true,
+	},
+	{
+		file:       "hub/leases.yaml",
+		gvr:        schema.GroupVersionResource{Group: "coordination.k8s.io", Version: "v1", Resource: "leases"},
+		namespaced: true,
+	},
+	{
+		file: "hub/certificatesigningrequests.yaml",
+		gvr:  schema.GroupVersionResource{Group: "certificates.k8s.io", Version: "v1", Resource: "certificatesigningrequests"},
+		options: func(clusterName string) metav1.ListOptions {
+			return metav1.ListOptions{LabelSelector: clusterNameLabelKey + "=" + clusterName}
+		},
+	},
+	{
+		file:       "hub/events.yaml",
+		gvr:        schema.GroupVersionResource{Version: "v1", Resource: "events"},
+		namespaced: true,
+	},
+}
+
+// CollectHub writes the resources of the cluster on the hub into the bundle. A resource failed to be listed is
+// recorded in the manifest of the bundle.
+func CollectHub(ctx context.Context, client dynamic.Interface, clusterName string, w *diagnostics.Writer) error {
+	for _, resource := range hubResources {
+		var list *unstructured.UnstructuredList
+		var err error
+		if resource.namespaced {
+			list, err = client.Resource(resource.gvr).Namespace(clusterName).List(ctx, metav1.ListOptions{})
+		} else {
+			list, err = client.Resource(resource.gvr).List(ctx, resource.options(clusterName))
+		}
+		if err != nil {
+			w.AddError(resource.file, err)
+			continue
+		}
+		if err := w.AddObjects(resource.file, list.Items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewRequestID returns a new id of the request of the spoke archive.
+func NewRequestID(now time.Time) string {
+	return fmt.Sprintf("%s-%s", now.UTC().Format("20060102-150405"), rand.String(5))
+}
+
+// RequestSpokeArchive requests the registration agent of the cluster to upload the spoke archive, and waits until
+// the archive of the request is uploaded or the timeout.
+func RequestSpokeArchive(ctx context.Context, client dynamic.Interface, kubeClient kubernetes.Interface,
+	clusterName, requestID string, timeout time.Duration) ([]byte, error) {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{diagnostics.RequestAnnotationKey: requestID},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := client.Resource(managedClusterGVR).Patch(
+		ctx, clusterName, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return nil, fmt.Errorf("failed to request the diagnostics of cluster %s: %w", clusterName, err)
+	}
+	klog.FromContext(ctx).Info("Waiting for the spoke diagnostics", "clusterName", clusterName, "requestID", requestID)
+
+	var archive []byte
+	err = wait.PollUntilContextTimeout(ctx, spokeArchivePoll, timeout, true, func(ctx context.Context) (bool, error) {
+		configMap, err := kubeClient.CoreV1().ConfigMaps(clusterName).Get(ctx, diagnostics.ConfigMapName, metav1.GetOptions{})
+		switch {
+		case errors.IsNotFound(err):
+			return false, nil
+		case err != nil:
+			return false, err
+		case configMap.Annotations[diagnostics.RequestAnnotationKey] != requestID:
+			return false, nil
+		}
+		archive = configMap.BinaryData[diagnostics.ArchiveKey]
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to wait for the diagnostics of request %s uploaded by the agent: %w", requestID, err)
+	}
+	return archive, nil
+}
+
+// AddSpokeArchive writes the files of the spoke archive into the spoke directory of the bundle.
+func AddSpokeArchive(w *diagnostics.Writer, archive []byte) error {
+	files, err := diagnostics.ReadArchive(bytes.NewReader(archive))
+	if err != nil {
+		return fmt.Errorf("invalid spoke archive: %w", err)
+	}
+	for _, file := range files {
+		if err := w.AddFile(path.Join("spoke", file.Name), file.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package diagnostics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	"open-cluster-management.io/ocm/pkg/common/diagnostics"
+	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
+)
+
+const clusterName = "cluster1"
+
+func newDynamicClient(objects ...runtime.Object) *dynamicfake.FakeDynamicClient {
+	listKinds := map[schema.GroupVersionResource]string{}
+	for _, resource := range hubResources {
+		listKinds[resource.gvr] = "List"
+	}
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objects...)
+}
+
+func TestCollectHub(t *testing.T) {
+	client := newDynamicClient(
+		testingcommon.NewUnstructured("cluster.open-cluster-management.io/v1", "ManagedCluster", "", clusterName),
+		testingcommon.NewUnstructured("work.open-cluster-management.io/v1", "ManifestWork", clusterName, "work1"),
+		testingcommon.NewUnstructured("work.open-cluster-management.io/v1", "ManifestWork", "cluster2", "work2"),
+	)
+
+	buf := &bytes.Buffer{}
+	w := diagnostics.NewWriter(buf, clusterName, "", time.Now())
+	if err := CollectHub(context.TODO(), client, clusterName, w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := diagnostics.ReadArchive(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := &diagnostics.Manifest{}
+	for _, file := range files {
+		if file.Name == diagnostics.ManifestFile {
+			if err := json.Unmarshal(file.Data, manifest); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if file.Name == "hub/manifestworks.yaml" && bytes.Contains(file.Data, []byte("work2")) {
+			t.Errorf("expected only the manifestworks in the cluster namespace are collected")
+		}
+	}
+	expectedFiles := []string{
+		"hub/certificatesigningrequests.yaml",
+		"hub/events.yaml",
+		"hub/leases.yaml",
+		"hub/managedcluster.yaml",
+		"hub/managedclusteraddons.yaml",
+		"hub/manifestworks.yaml",
+	}
+	if !reflect.DeepEqual(manifest.Files, expectedFiles) {
+		t.Errorf("expected files %v, but got %v", expectedFiles, manifest.Files)
+	}
+}
+
+func TestRequestSpokeArchive(t *testing.T) {
+	spokeArchive := &bytes.Buffer{}
+	w := diagnostics.NewWriter(spokeArchive, clusterName, "req1", time.Now())
+	if err := w.AddFile("featuregates.yaml", []byte("Test: true\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		requestID string
+		expectErr bool
+	}{
+		{
+			name:      "archive is uploaded",
+			requestID: "req1",
+		},
+		{
+			name:      "archive is not uploaded",
+			requestID: "req2",
+			expectErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client := newDynamicClient(
+				testingcommon.NewUnstructured("cluster.open-cluster-management.io/v1", "ManagedCluster", "", clusterName))
+			kubeClient := kubefake.NewSimpleClientset(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        diagnostics.ConfigMapName,
+					Namespace:   clusterName,
+					Annotations: map[string]string{diagnostics.RequestAnnotationKey: "req1"},
+				},
+				BinaryData: map[string][]byte{diagnostics.ArchiveKey: spokeArchive.Bytes()},
+			})
+
+			archive, err := RequestSpokeArchive(context.TODO(), client, kubeClient, clusterName, c.requestID, time.Second)
+			if c.expectErr {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cluster, err := client.Resource(managedClusterGVR).Get(context.TODO(), clusterName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cluster.GetAnnotations()[diagnostics.RequestAnnotationKey] != c.requestID {
+				t.Errorf("expected the request is annotated, but got %v", cluster.GetAnnotations())
+			}
+
+			buf := &bytes.Buffer{}
+			bundle := diagnostics.NewWriter(buf, clusterName, c.requestID, time.Now())
+			if err := AddSpokeArchive(bundle, archive); err != nil {
+				t.Fatal(err)
+			}
+			if err := bundle.Close(); err != nil {
+				t.Fatal(err)
+			}
+			files, err := diagnostics.ReadArchive(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, file := range files {
+				names = append(names, file.Name)
+			}
+			expected := []string{"spoke/featuregates.yaml", "spoke/manifest.json", diagnostics.ManifestFile}
+			if !reflect.DeepEqual(names, expected) {
+				t.Errorf("expected files %v, but got %v", expected, names)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+// package diagnostics collects the diagnostics bundle of a managed cluster from the hub.
+//
+// The hub part of the bundle is listed from the hub directly. The spoke part is requested by annotating the
+// ManagedCluster, and is uploaded by the registration agent into a ConfigMap in the cluster namespace. See the
+// package open-cluster-management.io/ocm/pkg/common/diagnostics for the format of the bundle.
+package diagnostics
@@ -0,0 +1,92 @@
+package diagnostics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"open-cluster-management.io/ocm/pkg/common/diagnostics"
+)
+
+// Options holds the configuration of the diagnostics command.
+type Options struct {
+	// Kubeconfig is the kubeconfig of the hub, the in-cluster config is used if it is empty.
+	Kubeconfig string
+	// ClusterName is the name of the managed cluster to diagnose.
+	ClusterName string
+	// File is the path of the bundle.
+	File string
+	// SpokeTimeout is how long to wait for the registration agent to upload the spoke archive. The spoke archive is
+	// not requested if it is zero.
+	SpokeTimeout time.Duration
+}
+
+func NewOptions() *Options {
+	return &Options{
+		SpokeTimeout: 2 * time.Minute,
+	}
+}
+
+// AddFlags registers flags for the diagnostics command.
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Kubeconfig, "kubeconfig", o.Kubeconfig,
+		"The path of the kubeconfig of the hub, the in-cluster config is used if it is not set.")
+	fs.StringVar(&o.ClusterName, "cluster-name", o.ClusterName, "The name of the managed cluster to diagnose.")
+	fs.StringVar(&o.File, "file", o.File, "The path of the diagnostics bundle.")
+	fs.DurationVar(&o.SpokeTimeout, "spoke-timeout", o.SpokeTimeout,
+		"How long to wait for the registration agent to upload the diagnostics of the managed cluster, the "+
+			"diagnostics of the managed cluster are not collected if it is 0.")
+}
+
+// Run collects the diagnostics bundle of the managed cluster into the file. The bundle is written even if the spoke
+// archive is not uploaded in time, and the error is recorded in its manifest.
+func (o *Options) Run(ctx context.Context) error {
+	if len(o.ClusterName) == 0 {
+		return errors.New("the cluster name is required")
+	}
+	if len(o.File) == 0 {
+		return errors.New("the bundle file is required")
+	}
+	config, err := clientcmd.BuildConfigFromFlags("", o.Kubeconfig)
+	if err != nil {
+		return err
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	requestID := NewRequestID(now)
+	buf := &bytes.Buffer{}
+	w := diagnostics.NewWriter(buf, o.ClusterName, requestID, now)
+	if err := CollectHub(ctx, dynamicClient, o.ClusterName, w); err != nil {
+		return err
+	}
+
+	if o.SpokeTimeout > 0 {
+		archive, err := RequestSpokeArchive(ctx, dynamicClient, kubeClient, o.ClusterName, requestID, o.SpokeTimeout)
+		if err == nil {
+			err = AddSpokeArchive(w, archive)
+		}
+		if err != nil {
+			w.AddError("spoke", err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(o.File, buf.Bytes(), 0600)
+}
@@ -0,0 +1,474 @@
+package klusterletupgrade
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	coordinformers "k8s.io/client-go/informers/coordination/v1"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	coordlisters "k8s.io/client-go/listers/coordination/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	clusterinformerv1 "open-cluster-management.io/api/client/cluster/informers/externalversions/cluster/v1"
+	clusterinformerv1beta1 "open-cluster-management.io/api/client/cluster/informers/externalversions/cluster/v1beta1"
+	clusterlisterv1 "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
+	clusterlisterv1beta1 "open-cluster-management.io/api/client/cluster/listers/cluster/v1beta1"
+	workclientset "open-cluster-management.io/api/client/work/clientset/versioned"
+	workinformerv1 "open-cluster-management.io/api/client/work/informers/externalversions/work/v1"
+	worklisterv1 "open-cluster-management.io/api/client/work/listers/work/v1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	clusterv1beta1 "open-cluster-management.io/api/cluster/v1beta1"
+	workv1 "open-cluster-management.io/api/work/v1"
+	clustersdkv1alpha1 "open-cluster-management.io/sdk-go/pkg/apis/cluster/v1alpha1"
+	clustersdkv1beta1 "open-cluster-management.io/sdk-go/pkg/apis/cluster/v1beta1"
+	workapplier "open-cluster-management.io/sdk-go/pkg/apis/work/v1/applier"
+	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
+
+	"open-cluster-management.io/ocm/pkg/common/helpers"
+	"open-cluster-management.io/ocm/pkg/common/queue"
+)
+
+const (
+	leaseName = "managed-cluster-lease"
+
+	// the klusterlets being upgraded are verified periodically, since the lease of the cluster is renewed without
+	// triggering the controller.
+	progressingRecheckInterval = 30 * time.Second
+)
+
+// klusterletUpgradeController rolls out the fleet upgrades of the klusterlets to the clusters selected by the
+// placements.
+type klusterletUpgradeController struct {
+	hubNamespace            string
+	kubeClient              kubernetes.Interface
+	workApplier             *workapplier.WorkApplier
+	configMapLister         corev1listers.ConfigMapLister
+	clusterLister           clusterlisterv1.ManagedClusterLister
+	leaseLister             coordlisters.LeaseLister
+	placementLister         clusterlisterv1beta1.PlacementLister
+	placementDecisionLister clusterlisterv1beta1.PlacementDecisionLister
+	workLister              worklisterv1.ManifestWorkLister
+}
+
+// NewKlusterletUpgradeController creates a new klusterlet upgrade controller. The configMapInformer should only
+// watch the ConfigMaps with the KlusterletUpgradeLabelKey label in the hub namespace. The fleet upgrades are only
+// accepted from the hub namespace, since anyone able to create a ConfigMap would otherwise change the klusterlets
+// of the fleet on behalf of the hub.
+func NewKlusterletUpgradeController(
+	hubNamespace string,
+	kubeClient kubernetes.Interface,
+	workClient workclientset.Interface,
+	configMapInformer corev1informers.ConfigMapInformer,
+	clusterInformer clusterinformerv1.ManagedClusterInformer,
+	leaseInformer coordinformers.LeaseInformer,
+	placementInformer clusterinformerv1beta1.PlacementInformer,
+	placementDecisionInformer clusterinformerv1beta1.PlacementDecisionInformer,
+	workInformer workinformerv1.ManifestWorkInformer) factory.Controller {
+	c := &klusterletUpgradeController{
+		hubNamespace:            hubNamespace,
+		kubeClient:              kubeClient,
+		workApplier:             workapplier.NewWorkApplierWithTypedClient(workClient, workInformer.Lister()),
+		configMapLister:         configMapInformer.Lister(),
+		clusterLister:           clusterInformer.Lister(),
+		leaseLister:             leaseInformer.Lister(),
+		placementLister:         placementInformer.Lister(),
+		placementDecisionLister: placementDecisionInformer.Lister(),
+		workLister:              workInformer.Lister(),
+	}
+	return factory.New().
+		WithFilteredEventsInformersQueueKeysFunc(
+			queue.QueueKeyByMetaNamespaceName, queue.FileterByLabel(KlusterletUpgradeLabelKey), configMapInformer.Informer()).
+		WithFilteredEventsInformersQueueKeysFunc(
+			upgradeKeyOfWork, queue.FileterByLabel(KlusterletUpgradeLabelKey), workInformer.Informer()).
+		WithInformersQueueKeysFunc(c.upgradeKeysOfPlacementDecision, placementDecisionInformer.Informer()).
+		WithBareInformers(clusterInformer.Informer(), leaseInformer.Informer(), placementInformer.Informer()).
+		WithSync(c.sync).
+		ToController("KlusterletUpgradeController")
+}
+
+// upgradeKeysOfPlacementDecision returns the fleet upgrades of the placement, so the new selected clusters are
+// upgraded.
+func (c *klusterletUpgradeController) upgradeKeysOfPlacementDecision(obj runtime.Object) []string {
+	accessor, _ := meta.Accessor(obj)
+	placementName, ok := accessor.GetLabels()[clusterv1beta1.PlacementLabel]
+	if !ok || accessor.GetNamespace() != c.hubNamespace {
+		return nil
+	}
+
+	configMaps, err := c.configMapLister.ConfigMaps(accessor.GetNamespace()).List(labels.Everything())
+	if err != nil {
+		return nil
+	}
+	var keys []string
+	for _, configMap := range configMaps {
+		spec, err := ParseSpec(configMap)
+		if err != nil || spec.Placement != placementName {
+			continue
+		}
+		keys = append(keys, configMap.Namespace+"/"+configMap.Name)
+	}
+	return keys
+}
+
+func (c *klusterletUpgradeController) sync(ctx context.Context, syncCtx factory.SyncContext, key string) error {
+	logger := klog.FromContext(ctx).WithValues("klusterletUpgrade", key)
+	logger.V(4).Info("Reconciling klusterlet upgrade")
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		// ignore the bad format key
+		return nil
+	}
+	if namespace != c.hubNamespace {
+		logger.V(4).Info("Ignore the klusterlet upgrade out of the hub namespace", "hubNamespace", c.hubNamespace)
+		return nil
+	}
+
+	works, err := c.listUpgradeWorks(key)
+	if err != nil {
+		return err
+	}
+
+	configMap, err := c.configMapLister.ConfigMaps(namespace).Get(name)
+	switch {
+	case errors.IsNotFound(err):
+		// the upgrade is deleted, the works are removed and the klusterlets are orphaned
+		var errs []error
+		for _, work := range works {
+			errs = append(errs, c.workApplier.Delete(ctx, work.Namespace, work.Name))
+		}
+		return utilerrors.NewAggregate(errs)
+	case err != nil:
+		return err
+	}
+
+	spec, err := ParseSpec(configMap)
+	if err != nil {
+		return c.updateStatus(ctx, configMap, &KlusterletUpgradeStatus{Message: err.Error()})
+	}
+	placement, err := c.placementLister.Placements(namespace).Get(spec.Placement)
+	switch {
+	case errors.IsNotFound(err):
+		return c.updateStatus(ctx, configMap, &KlusterletUpgradeStatus{
+			Message: fmt.Sprintf("the placement %s is not found", spec.Placement)})
+	case err != nil:
+		return err
+	}
+
+	states := map[string]*upgradeState{}
+	var existingStatuses []clustersdkv1alpha1.ClusterRolloutStatus
+	for _, work := range works {
+		state, err := getUpgradeState(work)
+		if err != nil {
+			// the work is overwritten as a new upgrade
+			logger.Error(err, "Invalid upgrade work", "managedClusterName", work.Namespace)
+			continue
+		}
+		states[work.Namespace] = state
+		existingStatuses = append(existingStatuses, rolloutStatus(work.Namespace, state, spec.KlusterletImages))
+	}
+
+	tracker := clustersdkv1beta1.NewPlacementDecisionClustersTracker(
+		placement, helpers.PlacementDecisionGetter{Client: c.placementDecisionLister}, sets.KeySet(states))
+	if err := tracker.Refresh(); err != nil {
+		return err
+	}
+	rolloutHandler, err := clustersdkv1alpha1.NewRolloutHandler(tracker,
+		func(clusterName string, state *upgradeState) (clustersdkv1alpha1.ClusterRolloutStatus, error) {
+			return rolloutStatus(clusterName, state, spec.KlusterletImages), nil
+		})
+	if err != nil {
+		return err
+	}
+	_, result, err := rolloutHandler.GetRolloutCluster(spec.RolloutStrategy, existingStatuses)
+	if err != nil {
+		return c.updateStatus(ctx, configMap, &KlusterletUpgradeStatus{Message: err.Error()})
+	}
+
+	now := metav1.Now()
+	messages := map[string]string{}
+	var errs []error
+	for _, rolloutCluster := range result.ClustersToRollout {
+		clusterName := rolloutCluster.ClusterName
+		desired, message := c.progress(clusterName, states[clusterName], works[clusterName], spec, now)
+		messages[clusterName] = message
+		if desired == states[clusterName] {
+			continue
+		}
+		if err := c.applyState(ctx, key, clusterName, spec, desired); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		states[clusterName] = desired
+	}
+	for _, timeoutCluster := range result.ClustersTimeOut {
+		clusterName := timeoutCluster.ClusterName
+		desired := rollback(states[clusterName], now)
+		if desired == nil {
+			continue
+		}
+		logger.Info("Klusterlet is not upgraded in time", "managedClusterName", clusterName, "phase", desired.phase)
+		if err := c.applyState(ctx, key, clusterName, spec, desired); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		states[clusterName] = desired
+	}
+	for _, removedCluster := range result.ClustersRemoved {
+		// the cluster is not selected by the placement anymore, the klusterlet is kept as is
+		if err := c.workApplier.Delete(ctx, removedCluster.ClusterName, workName(namespace, name)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		delete(states, removedCluster.ClusterName)
+	}
+
+	status := aggregateStatus(states, messages, tracker.ExistingClusterGroupsBesides().GetClusters().Len())
+	status.MaxFailureBreach = result.MaxFailureBreach
+	errs = append(errs, c.updateStatus(ctx, configMap, status))
+
+	if status.Progressing > 0 {
+		recheckAfter := progressingRecheckInterval
+		if result.RecheckAfter != nil && *result.RecheckAfter < recheckAfter {
+			recheckAfter = *result.RecheckAfter
+		}
+		syncCtx.Queue().AddAfter(key, recheckAfter)
+	} else if result.RecheckAfter != nil {
+		syncCtx.Queue().AddAfter(key, *result.RecheckAfter)
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// listUpgradeWorks returns the works of the fleet upgrade keyed by the cluster name.
+func (c *klusterletUpgradeController) listUpgradeWorks(key string) (map[string]*workv1.ManifestWork, error) {
+	works, err := c.workLister.List(labels.SelectorFromSet(labels.Set{KlusterletUpgradeLabelKey: "true"}))
+	if err != nil {
+		return nil, err
+	}
+	upgradeWorks := map[string]*workv1.ManifestWork{}
+	for _, work := range works {
+		if work.Annotations[ownerAnnotationKey] == key {
+			upgradeWorks[work.Namespace] = work
+		}
+	}
+	return upgradeWorks, nil
+}
+
+// progress returns the next state of the upgrade on a cluster selected to rollout, and the message why the cluster
+// is not upgraded yet.
+func (c *klusterletUpgradeController) progress(
+	clusterName string, state *upgradeState, work *workv1.ManifestWork, spec *KlusterletUpgradeSpec, now metav1.Time,
+) (*upgradeState, string) {
+	switch {
+	case state == nil:
+		return &upgradeState{phase: PhasePreparing, transitionTime: now, target: spec.KlusterletImages}, ""
+	case state.target != spec.KlusterletImages && state.previous != nil:
+		// the target is changed, the new target is applied and the original images are kept for rollback
+		return &upgradeState{phase: PhaseUpgrading, transitionTime: now, target: spec.KlusterletImages,
+			previous: state.previous, previousVersions: state.previousVersions}, ""
+	case state.target != spec.KlusterletImages:
+		return &upgradeState{phase: PhasePreparing, transitionTime: now, target: spec.KlusterletImages}, ""
+	}
+
+	feedback := klusterletFeedback(work)
+	switch state.phase {
+	case PhasePreparing:
+		previous, ok := currentImages(feedback)
+		if !ok {
+			return state, fmt.Sprintf("the klusterlet %s is not reported", spec.KlusterletName)
+		}
+		cluster, err := c.clusterLister.Get(clusterName)
+		if err != nil {
+			return state, fmt.Sprintf("failed to get the cluster: %v", err)
+		}
+		return &upgradeState{phase: PhaseUpgrading, transitionTime: now, target: state.target, previous: previous,
+			previousVersions: upgradedAgentVersions(cluster, state.target)}, ""
+	case PhaseUpgrading:
+		if upgraded, message := klusterletUpgraded(feedback, state.target); !upgraded {
+			return state, message
+		}
+		cluster, err := c.clusterLister.Get(clusterName)
+		if err != nil {
+			return state, fmt.Sprintf("failed to get the cluster: %v", err)
+		}
+		if upgraded, message := agentsUpgraded(cluster, state, spec.AgentVersion); !upgraded {
+			return state, message
+		}
+		if back, message := c.clusterBack(cluster, state.transitionTime); !back {
+			return state, message
+		}
+		return &upgradeState{phase: PhaseSucceeded, transitionTime: now, target: state.target,
+			previous: state.previous, previousVersions: state.previousVersions}, ""
+	}
+	return state, ""
+}
+
+// upgradedAgents returns the agent components whose images are changed by the target images. The ImagePullSpec is
+// the image of the agents running in the Singleton mode.
+func upgradedAgents(target KlusterletImages) []string {
+	if len(target.ImagePullSpec) > 0 {
+		return []string{helpers.RegistrationAgentComponent, helpers.WorkAgentComponent}
+	}
+	var components []string
+	if len(target.RegistrationImagePullSpec) > 0 {
+		components = append(components, helpers.RegistrationAgentComponent)
+	}
+	if len(target.WorkImagePullSpec) > 0 {
+		components = append(components, helpers.WorkAgentComponent)
+	}
+	return components
+}
+
+// upgradedAgentVersions returns the versions reported by the agents upgraded to the target images.
+func upgradedAgentVersions(cluster *clusterv1.ManagedCluster, target KlusterletImages) map[string]string {
+	reported := helpers.GetAgentVersions(cluster)
+	versions := map[string]string{}
+	for _, component := range upgradedAgents(target) {
+		if version, ok := reported[component]; ok {
+			versions[component] = version
+		}
+	}
+	return versions
+}
+
+// agentsUpgraded returns true if the agents upgraded to the target images report the agent version, or report
+// versions different from the ones before the upgrade if the agent version is not set. The Klusterlet is updated
+// before the new agents are rolled out, so the agents still running with the previous images are not counted.
+func agentsUpgraded(cluster *clusterv1.ManagedCluster, state *upgradeState, agentVersion string) (bool, string) {
+	reported := helpers.GetAgentVersions(cluster)
+	for _, component := range upgradedAgents(state.target) {
+		version, ok := reported[component]
+		switch {
+		case !ok:
+			return false, fmt.Sprintf("the version of the %s agent is not reported", component)
+		case len(agentVersion) > 0 && version != agentVersion:
+			return false, fmt.Sprintf("the %s agent reports version %s, expected %s", component, version, agentVersion)
+		case len(agentVersion) == 0 && version == state.previousVersions[component]:
+			return false, fmt.Sprintf("the %s agent still reports the version %s before the upgrade", component, version)
+		}
+	}
+	return true, ""
+}
+
+// clusterBack returns true if the cluster is available and its lease is renewed after the upgrade.
+func (c *klusterletUpgradeController) clusterBack(cluster *clusterv1.ManagedCluster, since metav1.Time) (bool, string) {
+	clusterName := cluster.Name
+	if !meta.IsStatusConditionTrue(cluster.Status.Conditions, clusterv1.ManagedClusterConditionAvailable) {
+		return false, "the cluster is not available"
+	}
+
+	lease, err := c.leaseLister.Leases(clusterName).Get(leaseName)
+	if err != nil {
+		return false, fmt.Sprintf("failed to get the lease of the cluster: %v", err)
+	}
+	if lease.Spec.RenewTime == nil || !lease.Spec.RenewTime.After(since.Time) {
+		return false, "the lease of the cluster is not renewed after the upgrade"
+	}
+	return true, ""
+}
+
+func (c *klusterletUpgradeController) applyState(
+	ctx context.Context, key, clusterName string, spec *KlusterletUpgradeSpec, state *upgradeState) error {
+	work, err := newUpgradeWork(key, clusterName, spec.KlusterletName, spec.AgentNamespace, state)
+	if err != nil {
+		return err
+	}
+	_, err = c.workApplier.Apply(ctx, work)
+	return err
+}
+
+func (c *klusterletUpgradeController) updateStatus(
+	ctx context.Context, configMap *corev1.ConfigMap, status *KlusterletUpgradeStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	if configMap.Data[StatusKey] == string(data) {
+		return nil
+	}
+
+	configMap = configMap.DeepCopy()
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data[StatusKey] = string(data)
+	_, err = c.kubeClient.CoreV1().ConfigMaps(configMap.Namespace).Update(ctx, configMap, metav1.UpdateOptions{})
+	return err
+}
+
+// rolloutStatus returns the rollout status of the upgrade on a cluster. The clusters rolled back or failed are
+// counted as failures and are not retried unless the target is changed.
+func rolloutStatus(clusterName string, state *upgradeState, target KlusterletImages) clustersdkv1alpha1.ClusterRolloutStatus {
+	status := clustersdkv1alpha1.ClusterRolloutStatus{ClusterName: clusterName, Status: clustersdkv1alpha1.ToApply}
+	if state == nil || state.target != target {
+		return status
+	}
+
+	transitionTime := state.transitionTime
+	status.LastTransitionTime = &transitionTime
+	switch state.phase {
+	case PhasePreparing, PhaseUpgrading:
+		status.Status = clustersdkv1alpha1.Progressing
+	case PhaseSucceeded:
+		status.Status = clustersdkv1alpha1.Succeeded
+	case PhaseRolledBack, PhaseFailed:
+		status.Status = clustersdkv1alpha1.TimeOut
+	}
+	return status
+}
+
+// rollback returns the state of the upgrade timed out on a cluster, nil if nothing is changed.
+func rollback(state *upgradeState, now metav1.Time) *upgradeState {
+	if state == nil {
+		return nil
+	}
+	switch state.phase {
+	case PhaseUpgrading:
+		return &upgradeState{phase: PhaseRolledBack, transitionTime: now, target: state.target, previous: state.previous}
+	case PhasePreparing:
+		return &upgradeState{phase: PhaseFailed, transitionTime: now, target: state.target}
+	}
+	return nil
+}
+
+func aggregateStatus(states map[string]*upgradeState, messages map[string]string, total int) *KlusterletUpgradeStatus {
+	status := &KlusterletUpgradeStatus{Total: total}
+	for clusterName, state := range states {
+		switch state.phase {
+		case PhasePreparing, PhaseUpgrading:
+			status.Progressing++
+		case PhaseSucceeded:
+			status.Succeeded++
+		case PhaseRolledBack:
+			status.RolledBack++
+		case PhaseFailed:
+			status.Failed++
+		}
+		status.Clusters = append(status.Clusters, ClusterUpgradeStatus{
+			ClusterName:        clusterName,
+			Phase:              state.phase,
+			LastTransitionTime: state.transitionTime,
+			PreviousImages:     state.previous,
+			Message:            messages[clusterName],
+		})
+	}
+	sort.Slice(status.Clusters, func(i, j int) bool {
+		return status.Clusters[i].ClusterName < status.Clusters[j].ClusterName
+	})
+	return status
+}
@@ -0,0 +1,387 @@
+package klusterletupgrade
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	coordv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeinformers "k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterinformers "open-cluster-management.io/api/client/cluster/informers/externalversions"
+	workfake "open-cluster-management.io/api/client/work/clientset/versioned/fake"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	clusterv1alpha1 "open-cluster-management.io/api/cluster/v1alpha1"
+	clusterv1beta1 "open-cluster-management.io/api/cluster/v1beta1"
+	workv1 "open-cluster-management.io/api/work/v1"
+
+	"open-cluster-management.io/ocm/pkg/common/helpers"
+	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
+)
+
+const (
+	testNamespace   = "default"
+	testUpgradeName = "upgrade"
+	testUpgradeKey  = testNamespace + "/" + testUpgradeName
+	testCluster     = "cluster1"
+)
+
+var (
+	testTarget   = KlusterletImages{RegistrationImagePullSpec: "quay.io/ocm/registration:v2"}
+	testPrevious = &KlusterletImages{RegistrationImagePullSpec: "quay.io/ocm/registration:v1"}
+)
+
+func newUpgradeConfigMap(t *testing.T, spec *KlusterletUpgradeSpec) *corev1.ConfigMap {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testUpgradeName,
+			Namespace: testNamespace,
+			Labels:    map[string]string{KlusterletUpgradeLabelKey: "true"},
+		},
+		Data: map[string]string{SpecKey: "{}"},
+	}
+	if spec != nil {
+		data, err := json.Marshal(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		configMap.Data[SpecKey] = string(data)
+	}
+	return configMap
+}
+
+func newTestUpgradeWork(t *testing.T, state *upgradeState, feedback *KlusterletImages) *workv1.ManifestWork {
+	work, err := newUpgradeWork(testUpgradeKey, testCluster, DefaultKlusterletName, DefaultAgentNamespace, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feedback == nil {
+		return work
+	}
+	generation := int64(2)
+	work.Status.ResourceStatus.Manifests = []workv1.ManifestCondition{{
+		ResourceMeta: workv1.ManifestResourceMeta{Resource: "klusterlets", Name: DefaultKlusterletName},
+		StatusFeedbacks: workv1.StatusFeedbackResult{Values: []workv1.FeedbackValue{
+			{Name: feedbackRegistrationImage, Value: workv1.FieldValue{
+				Type: workv1.String, String: &feedback.RegistrationImagePullSpec}},
+			{Name: feedbackGeneration, Value: workv1.FieldValue{Type: workv1.Integer, Integer: &generation}},
+			{Name: feedbackObservedGeneration, Value: workv1.FieldValue{Type: workv1.Integer, Integer: &generation}},
+		}},
+	}}
+	return work
+}
+
+func newAvailableCluster(registrationVersion string) *clusterv1.ManagedCluster {
+	return &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: testCluster},
+		Status: clusterv1.ManagedClusterStatus{
+			Conditions: []metav1.Condition{{
+				Type: clusterv1.ManagedClusterConditionAvailable, Status: metav1.ConditionTrue}},
+			ClusterClaims: []clusterv1.ManagedClusterClaim{{
+				Name:  helpers.AgentVersionClaimName(helpers.RegistrationAgentComponent),
+				Value: registrationVersion,
+			}},
+		},
+	}
+}
+
+func newClusterLease(renewTime time.Time) *coordv1.Lease {
+	return &coordv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: leaseName, Namespace: testCluster},
+		Spec:       coordv1.LeaseSpec{RenewTime: &metav1.MicroTime{Time: renewTime}},
+	}
+}
+
+// appliedStates returns the states of the upgrade works created or patched.
+func appliedStates(t *testing.T, workClient *workfake.Clientset) []*upgradeState {
+	var states []*upgradeState
+	for _, action := range workClient.Actions() {
+		if action.GetVerb() != "create" && action.GetVerb() != "patch" {
+			continue
+		}
+		work, err := workClient.WorkV1().ManifestWorks(action.GetNamespace()).Get(
+			context.TODO(), workName(testNamespace, testUpgradeName), metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		state, err := getUpgradeState(work)
+		if err != nil {
+			t.Fatal(err)
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+func TestSync(t *testing.T) {
+	spec := &KlusterletUpgradeSpec{
+		Placement: "placement1",
+		RolloutStrategy: clusterv1alpha1.RolloutStrategy{
+			Type: clusterv1alpha1.All,
+			All:  &clusterv1alpha1.RolloutAll{RolloutConfig: clusterv1alpha1.RolloutConfig{ProgressDeadline: "10m"}},
+		},
+		KlusterletImages: testTarget,
+	}
+	now := time.Now()
+	started := metav1.NewTime(now.Add(-time.Minute).Truncate(time.Second))
+	timedOut := metav1.NewTime(now.Add(-time.Hour).Truncate(time.Second))
+
+	cases := []struct {
+		name           string
+		configMap      *corev1.ConfigMap
+		cluster        *clusterv1.ManagedCluster
+		works          []runtime.Object
+		leases         []runtime.Object
+		expectedPhases []ClusterUpgradePhase
+		expectedDelete bool
+		validateStatus func(t *testing.T, status *KlusterletUpgradeStatus)
+	}{
+		{
+			name:      "invalid spec",
+			configMap: newUpgradeConfigMap(t, nil),
+			validateStatus: func(t *testing.T, status *KlusterletUpgradeStatus) {
+				if status.Message != "the placement is not set" {
+					t.Errorf("unexpected message %q", status.Message)
+				}
+			},
+		},
+		{
+			name:           "upgrade is started",
+			configMap:      newUpgradeConfigMap(t, spec),
+			expectedPhases: []ClusterUpgradePhase{PhasePreparing},
+			validateStatus: func(t *testing.T, status *KlusterletUpgradeStatus) {
+				if status.Total != 1 || status.Progressing != 1 {
+					t.Errorf("unexpected status %v", status)
+				}
+			},
+		},
+		{
+			name: "upgrade out of the hub namespace",
+			configMap: func() *corev1.ConfigMap {
+				configMap := newUpgradeConfigMap(t, spec)
+				configMap.Namespace = "other"
+				return configMap
+			}(),
+		},
+		{
+			name:      "previous images are collected",
+			configMap: newUpgradeConfigMap(t, spec),
+			works: []runtime.Object{newTestUpgradeWork(t, &upgradeState{
+				phase: PhasePreparing, transitionTime: started, target: testTarget}, testPrevious)},
+			expectedPhases: []ClusterUpgradePhase{PhaseUpgrading},
+			validateStatus: func(t *testing.T, status *KlusterletUpgradeStatus) {
+				if status.Progressing != 1 {
+					t.Errorf("unexpected status %v", status)
+				}
+			},
+		},
+		{
+			name:      "agent still runs the previous version",
+			configMap: newUpgradeConfigMap(t, spec),
+			works: []runtime.Object{newTestUpgradeWork(t, &upgradeState{
+				phase: PhaseUpgrading, transitionTime: started, target: testTarget, previous: testPrevious,
+				previousVersions: map[string]string{helpers.RegistrationAgentComponent: "v1.0.0"}}, &testTarget)},
+			cluster: newAvailableCluster("v1.0.0"),
+			leases:  []runtime.Object{newClusterLease(now)},
+			validateStatus: func(t *testing.T, status *KlusterletUpgradeStatus) {
+				if status.Progressing != 1 ||
+					status.Clusters[0].Message != "the registration agent still reports the version v1.0.0 before the upgrade" {
+					t.Errorf("unexpected status %v", status)
+				}
+			},
+		},
+		{
+			name: "agent does not report the expected version",
+			configMap: newUpgradeConfigMap(t, func() *KlusterletUpgradeSpec {
+				versionedSpec := *spec
+				versionedSpec.AgentVersion = "v2.0.0"
+				return &versionedSpec
+			}()),
+			works: []runtime.Object{newTestUpgradeWork(t, &upgradeState{
+				phase: PhaseUpgrading, transitionTime: started, target: testTarget, previous: testPrevious}, &testTarget)},
+			cluster: newAvailableCluster("v1.1.0"),
+			leases:  []runtime.Object{newClusterLease(now)},
+			validateStatus: func(t *testing.T, status *KlusterletUpgradeStatus) {
+				if status.Progressing != 1 ||
+					status.Clusters[0].Message != "the registration agent reports version v1.1.0, expected v2.0.0" {
+					t.Errorf("unexpected status %v", status)
+				}
+			},
+		},
+		{
+			name:      "lease is not renewed after upgrade",
+			configMap: newUpgradeConfigMap(t, spec),
+			works: []runtime.Object{newTestUpgradeWork(t, &upgradeState{
+				phase: PhaseUpgrading, transitionTime: started, target: testTarget, previous: testPrevious}, &testTarget)},
+			leases: []runtime.Object{newClusterLease(started.Add(-time.Second))},
+			validateStatus: func(t *testing.T, status *KlusterletUpgradeStatus) {
+				if status.Progressing != 1 || status.Clusters[0].Message != "the lease of the cluster is not renewed after the upgrade" {
+					t.Errorf("unexpected status %v", status)
+				}
+			},
+		},
+		{
+			name:      "upgrade is succeeded",
+			configMap: newUpgradeConfigMap(t, spec),
+			works: []runtime.Object{newTestUpgradeWork(t, &upgradeState{
+				phase: PhaseUpgrading, transitionTime: started, target: testTarget, previous: testPrevious,
+				previousVersions: map[string]string{helpers.RegistrationAgentComponent: "v1.0.0"}}, &testTarget)},
+			leases:         []runtime.Object{newClusterLease(now)},
+			expectedPhases: []ClusterUpgradePhase{PhaseSucceeded},
+			validateStatus: func(t *testing.T, status *KlusterletUpgradeStatus) {
+				if status.Succeeded != 1 || *status.Clusters[0].PreviousImages != *testPrevious {
+					t.Errorf("unexpected status %v", status)
+				}
+			},
+		},
+		{
+			name:      "upgrade is timed out",
+			configMap: newUpgradeConfigMap(t, spec),
+			works: []runtime.Object{newTestUpgradeWork(t, &upgradeState{
+				phase: PhaseUpgrading, transitionTime: timedOut, target: testTarget, previous: testPrevious}, testPrevious)},
+			expectedPhases: []ClusterUpgradePhase{PhaseRolledBack},
+			validateStatus: func(t *testing.T, status *KlusterletUpgradeStatus) {
+				if status.RolledBack != 1 {
+					t.Errorf("unexpected status %v", status)
+				}
+			},
+		},
+		{
+			name:      "target is changed after rollback",
+			configMap: newUpgradeConfigMap(t, spec),
+			works: []runtime.Object{newTestUpgradeWork(t, &upgradeState{
+				phase: PhaseRolledBack, transitionTime: timedOut,
+				target:   KlusterletImages{RegistrationImagePullSpec: "quay.io/ocm/registration:bad"},
+				previous: testPrevious}, testPrevious)},
+			expectedPhases: []ClusterUpgradePhase{PhaseUpgrading},
+		},
+		{
+			name: "upgrade is deleted",
+			works: []runtime.Object{newTestUpgradeWork(t, &upgradeState{
+				phase: PhaseSucceeded, transitionTime: started, target: testTarget, previous: testPrevious}, &testTarget)},
+			expectedDelete: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var configMaps []runtime.Object
+			if c.configMap != nil {
+				configMaps = append(configMaps, c.configMap)
+			}
+			kubeClient := kubefake.NewSimpleClientset(configMaps...)
+			kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubeClient, 10*time.Minute)
+			for _, obj := range configMaps {
+				if err := kubeInformerFactory.Core().V1().ConfigMaps().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, obj := range c.leases {
+				if err := kubeInformerFactory.Coordination().V1().Leases().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			clusterClient := clusterfake.NewSimpleClientset()
+			clusterInformerFactory := clusterinformers.NewSharedInformerFactory(clusterClient, 10*time.Minute)
+			cluster := c.cluster
+			if cluster == nil {
+				cluster = newAvailableCluster("v2.0.0")
+			}
+			if err := clusterInformerFactory.Cluster().V1().ManagedClusters().Informer().GetStore().Add(
+				cluster); err != nil {
+				t.Fatal(err)
+			}
+			if err := clusterInformerFactory.Cluster().V1beta1().Placements().Informer().GetStore().Add(
+				&clusterv1beta1.Placement{ObjectMeta: metav1.ObjectMeta{Name: "placement1", Namespace: testNamespace}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := clusterInformerFactory.Cluster().V1beta1().PlacementDecisions().Informer().GetStore().Add(
+				&clusterv1beta1.PlacementDecision{
+					ObjectMeta: metav1.ObjectMeta{Name: "placement1", Namespace: testNamespace,
+						Labels: map[string]string{
+							clusterv1beta1.PlacementLabel:          "placement1",
+							clusterv1beta1.DecisionGroupIndexLabel: "0",
+						}},
+					Status: clusterv1beta1.PlacementDecisionStatus{
+						Decisions: []clusterv1beta1.ClusterDecision{{ClusterName: testCluster}}},
+				}); err != nil {
+				t.Fatal(err)
+			}
+
+			workClient := workfake.NewSimpleClientset(c.works...)
+			workInformerFactory := workinformers.NewSharedInformerFactory(workClient, 10*time.Minute)
+			for _, obj := range c.works {
+				if err := workInformerFactory.Work().V1().ManifestWorks().Informer().GetStore().Add(obj); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ctrl := NewKlusterletUpgradeController(
+				testNamespace,
+				kubeClient,
+				workClient,
+				kubeInformerFactory.Core().V1().ConfigMaps(),
+				clusterInformerFactory.Cluster().V1().ManagedClusters(),
+				kubeInformerFactory.Coordination().V1().Leases(),
+				clusterInformerFactory.Cluster().V1beta1().Placements(),
+				clusterInformerFactory.Cluster().V1beta1().PlacementDecisions(),
+				workInformerFactory.Work().V1().ManifestWorks(),
+			)
+			upgradeKey := testUpgradeKey
+			if c.configMap != nil {
+				upgradeKey = c.configMap.Namespace + "/" + c.configMap.Name
+			}
+			if err := ctrl.Sync(context.TODO(), testingcommon.NewFakeSyncContext(t, upgradeKey), upgradeKey); err != nil {
+				t.Fatal(err)
+			}
+
+			states := appliedStates(t, workClient)
+			if len(states) != len(c.expectedPhases) {
+				t.Fatalf("expected phases %v, but got %d works applied", c.expectedPhases, len(states))
+			}
+			for i, state := range states {
+				if state.phase != c.expectedPhases[i] {
+					t.Errorf("expected phase %s, but got %s", c.expectedPhases[i], state.phase)
+				}
+			}
+
+			var deleted bool
+			for _, action := range workClient.Actions() {
+				if action.GetVerb() == "delete" {
+					deleted = true
+				}
+			}
+			if deleted != c.expectedDelete {
+				t.Errorf("expected work deleted %v, but got %v", c.expectedDelete, deleted)
+			}
+
+			if c.validateStatus == nil {
+				return
+			}
+			var status *KlusterletUpgradeStatus
+			for _, action := range kubeClient.Actions() {
+				if action.GetVerb() != "update" {
+					continue
+				}
+				configMap := action.(clienttesting.UpdateActionImpl).Object.(*corev1.ConfigMap)
+				status = &KlusterletUpgradeStatus{}
+				if err := json.Unmarshal([]byte(configMap.Data[StatusKey]), status); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if status == nil {
+				t.Fatal("expected the status to be updated")
+			}
+			c.validateStatus(t, status)
+		})
+	}
+}
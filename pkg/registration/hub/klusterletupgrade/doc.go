@@ -0,0 +1,16 @@
+// package klusterletupgrade upgrades the klusterlets of the managed clusters selected by a placement from the hub.
+//
+// A fleet upgrade is a ConfigMap in the hub namespace with the label KlusterletUpgradeLabelKey, the ConfigMaps in
+// the other namespaces are ignored. Its "spec" key is a json encoded KlusterletUpgradeSpec. The new images are
+// delivered to the Klusterlet on each managed cluster by a ManifestWork following the rollout strategy of the
+// upgrade, so canary clusters can be upgraded first with the mandatory decision groups of the progressive rollout
+// strategies. The work agent applies the ManifestWork as the ExecutorServiceAccount in the agent namespace, which is
+// the only identity the klusterlet operator allows to update the Klusterlet. A cluster is upgraded once its
+// Klusterlet is reconciled with the new images, the upgraded agents report the new version in the agent version
+// claims, and the cluster is available and renews its lease after the upgrade.
+// The klusterlet of a cluster is rolled back to the previous images if it is not upgraded within the progress
+// deadline of the rollout strategy, which requires the work agent of the cluster is still running. The progress of
+// the upgrade is aggregated in the "status" key of the ConfigMap.
+//
+// The fleet upgrades are only handled if the KlusterletUpgrade feature gate of the hub registration is enabled.
+package klusterletupgrade
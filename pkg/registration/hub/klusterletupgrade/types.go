@@ -0,0 +1,122 @@
+package klusterletupgrade
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	clusterv1alpha1 "open-cluster-management.io/api/cluster/v1alpha1"
+)
+
+const (
+	// KlusterletUpgradeLabelKey is the label of the fleet upgrade ConfigMaps and the ManifestWorks created for them.
+	KlusterletUpgradeLabelKey = "cluster.open-cluster-management.io/klusterlet-upgrade"
+
+	// SpecKey is the key of the ConfigMap data holding the json encoded KlusterletUpgradeSpec.
+	SpecKey = "spec"
+	// StatusKey is the key of the ConfigMap data holding the json encoded KlusterletUpgradeStatus.
+	StatusKey = "status"
+
+	// DefaultKlusterletName is the name of the Klusterlet upgraded if the name is not set in the spec.
+	DefaultKlusterletName = "klusterlet"
+	// DefaultAgentNamespace is the namespace of the klusterlet agents if the namespace is not set in the spec.
+	DefaultAgentNamespace = "open-cluster-management-agent"
+	// ExecutorServiceAccount is the service account in the agent namespace that the work agent runs as to apply the
+	// upgrade works, the klusterlet operator grants it the permission to update the Klusterlet.
+	ExecutorServiceAccount = "klusterlet-upgrade"
+)
+
+// KlusterletImages are the image pull specs of a Klusterlet, an empty image is not changed by the upgrade.
+type KlusterletImages struct {
+	RegistrationImagePullSpec string `json:"registrationImagePullSpec,omitempty"`
+	WorkImagePullSpec         string `json:"workImagePullSpec,omitempty"`
+	ImagePullSpec             string `json:"imagePullSpec,omitempty"`
+}
+
+// KlusterletUpgradeSpec upgrades the klusterlets of the clusters selected by the placement to the images.
+type KlusterletUpgradeSpec struct {
+	// Placement is the name of the placement in the namespace of the ConfigMap selecting the clusters.
+	Placement string `json:"placement"`
+	// RolloutStrategy is the strategy to upgrade the clusters. The clusters not upgraded within the progress
+	// deadline are rolled back, and are counted as failures.
+	RolloutStrategy clusterv1alpha1.RolloutStrategy `json:"rolloutStrategy"`
+	// KlusterletName is the name of the Klusterlet on the managed clusters, it is "klusterlet" by default.
+	KlusterletName string `json:"klusterletName,omitempty"`
+	// AgentNamespace is the namespace of the klusterlet agents on the managed clusters, which is the namespace in the
+	// spec of the Klusterlet. It is "open-cluster-management-agent" by default.
+	AgentNamespace string `json:"agentNamespace,omitempty"`
+	// AgentVersion is the version reported by the upgraded agents in the agent version claims. If it is not set,
+	// the agents are upgraded once they report versions different from the ones before the upgrade.
+	AgentVersion string `json:"agentVersion,omitempty"`
+	KlusterletImages
+}
+
+// ClusterUpgradePhase is the phase of the upgrade on a cluster.
+type ClusterUpgradePhase string
+
+const (
+	// PhasePreparing means the current images of the Klusterlet are being collected.
+	PhasePreparing ClusterUpgradePhase = "Preparing"
+	// PhaseUpgrading means the new images are applied, and the klusterlet is not verified yet.
+	PhaseUpgrading ClusterUpgradePhase = "Upgrading"
+	// PhaseSucceeded means the klusterlet is upgraded, the upgraded agents are running and the cluster comes back.
+	PhaseSucceeded ClusterUpgradePhase = "Succeeded"
+	// PhaseRolledBack means the klusterlet is not upgraded in time and the previous images are applied.
+	PhaseRolledBack ClusterUpgradePhase = "RolledBack"
+	// PhaseFailed means the current images of the Klusterlet are not collected in time, the klusterlet is not
+	// changed.
+	PhaseFailed ClusterUpgradePhase = "Failed"
+)
+
+// ClusterUpgradeStatus is the status of the upgrade on a cluster.
+type ClusterUpgradeStatus struct {
+	ClusterName        string              `json:"clusterName"`
+	Phase              ClusterUpgradePhase `json:"phase"`
+	LastTransitionTime metav1.Time         `json:"lastTransitionTime"`
+	// PreviousImages are the images of the Klusterlet before the upgrade, which are applied on rollback.
+	PreviousImages *KlusterletImages `json:"previousImages,omitempty"`
+	Message        string            `json:"message,omitempty"`
+}
+
+// KlusterletUpgradeStatus is the aggregated status of a fleet upgrade.
+type KlusterletUpgradeStatus struct {
+	// Message is set if the upgrade cannot proceed, e.g. the spec is invalid or the placement is not found.
+	Message     string `json:"message,omitempty"`
+	Total       int    `json:"total"`
+	Progressing int    `json:"progressing"`
+	Succeeded   int    `json:"succeeded"`
+	RolledBack  int    `json:"rolledBack"`
+	Failed      int    `json:"failed"`
+	// MaxFailureBreach is true if the rollout is stopped since the failures exceed the max failures of the
+	// rollout strategy.
+	MaxFailureBreach bool                   `json:"maxFailureBreach,omitempty"`
+	Clusters         []ClusterUpgradeStatus `json:"clusters,omitempty"`
+}
+
+// ParseSpec returns the spec of the fleet upgrade ConfigMap.
+func ParseSpec(configMap *corev1.ConfigMap) (*KlusterletUpgradeSpec, error) {
+	data, ok := configMap.Data[SpecKey]
+	if !ok {
+		return nil, fmt.Errorf("the key %q is not found", SpecKey)
+	}
+
+	spec := &KlusterletUpgradeSpec{}
+	if err := json.Unmarshal([]byte(data), spec); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", SpecKey, err)
+	}
+	if len(spec.Placement) == 0 {
+		return nil, fmt.Errorf("the placement is not set")
+	}
+	if spec.KlusterletImages == (KlusterletImages{}) {
+		return nil, fmt.Errorf("none of the images is set")
+	}
+	if len(spec.KlusterletName) == 0 {
+		spec.KlusterletName = DefaultKlusterletName
+	}
+	if len(spec.AgentNamespace) == 0 {
+		spec.AgentNamespace = DefaultAgentNamespace
+	}
+	return spec, nil
+}
@@ -0,0 +1,275 @@
+package klusterletupgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+
+	operatorv1 "open-cluster-management.io/api/operator/v1"
+	workv1 "open-cluster-management.io/api/work/v1"
+)
+
+const (
+	// the annotations of the upgrade work recording the upgrade on the cluster.
+	ownerAnnotationKey            = "cluster.open-cluster-management.io/klusterlet-upgrade-owner"
+	phaseAnnotationKey            = "cluster.open-cluster-management.io/klusterlet-upgrade-phase"
+	transitionTimeAnnotationKey   = "cluster.open-cluster-management.io/klusterlet-upgrade-transition-time"
+	targetAnnotationKey           = "cluster.open-cluster-management.io/klusterlet-upgrade-target"
+	previousAnnotationKey         = "cluster.open-cluster-management.io/klusterlet-upgrade-previous"
+	previousVersionsAnnotationKey = "cluster.open-cluster-management.io/klusterlet-upgrade-previous-versions"
+
+	// the names of the status feedbacks of the Klusterlet.
+	feedbackRegistrationImage  = "registrationImagePullSpec"
+	feedbackWorkImage          = "workImagePullSpec"
+	feedbackImage              = "imagePullSpec"
+	feedbackGeneration         = "generation"
+	feedbackObservedGeneration = "observedGeneration"
+)
+
+var klusterletFeedbackRule = workv1.FeedbackRule{
+	Type: workv1.JSONPathsType,
+	JsonPaths: []workv1.JsonPath{
+		{Name: feedbackRegistrationImage, Path: ".spec.registrationImagePullSpec"},
+		{Name: feedbackWorkImage, Path: ".spec.workImagePullSpec"},
+		{Name: feedbackImage, Path: ".spec.imagePullSpec"},
+		{Name: feedbackGeneration, Path: ".metadata.generation"},
+		{Name: feedbackObservedGeneration, Path: ".status.observedGeneration"},
+	},
+}
+
+// upgradeState is the upgrade on a cluster recorded in the annotations of the upgrade work.
+type upgradeState struct {
+	phase          ClusterUpgradePhase
+	transitionTime metav1.Time
+	target         KlusterletImages
+	previous       *KlusterletImages
+	// previousVersions are the versions reported by the agents before the upgrade, keyed by the agent component.
+	previousVersions map[string]string
+}
+
+// workName returns the name of the upgrade work of the fleet upgrade ConfigMap.
+func workName(upgradeNamespace, upgradeName string) string {
+	return fmt.Sprintf("klusterlet-upgrade-%s.%s", upgradeNamespace, upgradeName)
+}
+
+// upgradeKeyOfWork returns the queue key of the fleet upgrade the work belongs to.
+func upgradeKeyOfWork(obj runtime.Object) []string {
+	work, ok := obj.(*workv1.ManifestWork)
+	if !ok {
+		return nil
+	}
+	if key, ok := work.Annotations[ownerAnnotationKey]; ok {
+		return []string{key}
+	}
+	return nil
+}
+
+// newUpgradeWork returns the work delivering the state of the upgrade to the Klusterlet on the cluster. The current
+// images of the Klusterlet are collected by the status feedbacks without changing it in the Preparing and Failed
+// phase, otherwise the target or the previous images are applied. The work is applied as the ExecutorServiceAccount
+// in the agent namespace, so the default executor of the work agent is not allowed to update the Klusterlet. The
+// Klusterlet is orphaned when the work is deleted.
+func newUpgradeWork(upgradeKey, clusterName, klusterletName, agentNamespace string,
+	state *upgradeState) (*workv1.ManifestWork, error) {
+	upgradeNamespace, upgradeName, err := cache.SplitMetaNamespaceKey(upgradeKey)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := map[string]string{
+		ownerAnnotationKey:          upgradeKey,
+		phaseAnnotationKey:          string(state.phase),
+		transitionTimeAnnotationKey: state.transitionTime.UTC().Format(time.RFC3339),
+	}
+	target, err := json.Marshal(state.target)
+	if err != nil {
+		return nil, err
+	}
+	annotations[targetAnnotationKey] = string(target)
+	if state.previous != nil {
+		previous, err := json.Marshal(state.previous)
+		if err != nil {
+			return nil, err
+		}
+		annotations[previousAnnotationKey] = string(previous)
+	}
+	if state.previousVersions != nil {
+		previousVersions, err := json.Marshal(state.previousVersions)
+		if err != nil {
+			return nil, err
+		}
+		annotations[previousVersionsAnnotationKey] = string(previousVersions)
+	}
+
+	klusterlet := &unstructured.Unstructured{}
+	klusterlet.SetAPIVersion(operatorv1.GroupVersion.String())
+	klusterlet.SetKind("Klusterlet")
+	klusterlet.SetName(klusterletName)
+	updateStrategy := &workv1.UpdateStrategy{Type: workv1.UpdateStrategyTypeReadOnly}
+
+	var images *KlusterletImages
+	switch state.phase {
+	case PhaseUpgrading, PhaseSucceeded:
+		images = &state.target
+	case PhaseRolledBack:
+		images = state.previous
+	}
+	if images != nil {
+		// the images not set are released by the server side apply and removed from the Klusterlet, they are not
+		// restored to any value. The previous images not set were empty before the upgrade, so the Klusterlet falls
+		// back to the default images on rollback.
+		for field, image := range map[string]string{
+			"registrationImagePullSpec": images.RegistrationImagePullSpec,
+			"workImagePullSpec":         images.WorkImagePullSpec,
+			"imagePullSpec":             images.ImagePullSpec,
+		} {
+			if len(image) == 0 {
+				continue
+			}
+			if err := unstructured.SetNestedField(klusterlet.Object, image, "spec", field); err != nil {
+				return nil, err
+			}
+		}
+		updateStrategy = &workv1.UpdateStrategy{
+			Type:            workv1.UpdateStrategyTypeServerSideApply,
+			ServerSideApply: &workv1.ServerSideApplyConfig{Force: true},
+		}
+	}
+	raw, err := klusterlet.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	return &workv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        workName(upgradeNamespace, upgradeName),
+			Namespace:   clusterName,
+			Labels:      map[string]string{KlusterletUpgradeLabelKey: "true"},
+			Annotations: annotations,
+		},
+		Spec: workv1.ManifestWorkSpec{
+			Workload: workv1.ManifestsTemplate{
+				Manifests: []workv1.Manifest{{RawExtension: runtime.RawExtension{Raw: raw}}},
+			},
+			DeleteOption: &workv1.DeleteOption{PropagationPolicy: workv1.DeletePropagationPolicyTypeOrphan},
+			Executor: &workv1.ManifestWorkExecutor{
+				Subject: workv1.ManifestWorkExecutorSubject{
+					Type: workv1.ExecutorSubjectTypeServiceAccount,
+					ServiceAccount: &workv1.ManifestWorkSubjectServiceAccount{
+						Namespace: agentNamespace,
+						Name:      ExecutorServiceAccount,
+					},
+				},
+			},
+			ManifestConfigs: []workv1.ManifestConfigOption{
+				{
+					ResourceIdentifier: workv1.ResourceIdentifier{
+						Group:    operatorv1.GroupName,
+						Resource: "klusterlets",
+						Name:     klusterletName,
+					},
+					FeedbackRules:  []workv1.FeedbackRule{klusterletFeedbackRule},
+					UpdateStrategy: updateStrategy,
+				},
+			},
+		},
+	}, nil
+}
+
+// getUpgradeState returns the state of the upgrade recorded in the work.
+func getUpgradeState(work *workv1.ManifestWork) (*upgradeState, error) {
+	state := &upgradeState{phase: ClusterUpgradePhase(work.Annotations[phaseAnnotationKey])}
+	switch state.phase {
+	case PhasePreparing, PhaseUpgrading, PhaseSucceeded, PhaseRolledBack, PhaseFailed:
+	default:
+		return nil, fmt.Errorf("unknown phase %q", state.phase)
+	}
+
+	transitionTime, err := time.Parse(time.RFC3339, work.Annotations[transitionTimeAnnotationKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid transition time: %v", err)
+	}
+	state.transitionTime = metav1.NewTime(transitionTime)
+
+	if err := json.Unmarshal([]byte(work.Annotations[targetAnnotationKey]), &state.target); err != nil {
+		return nil, fmt.Errorf("invalid target images: %v", err)
+	}
+	if previous, ok := work.Annotations[previousAnnotationKey]; ok {
+		state.previous = &KlusterletImages{}
+		if err := json.Unmarshal([]byte(previous), state.previous); err != nil {
+			return nil, fmt.Errorf("invalid previous images: %v", err)
+		}
+	}
+	if previousVersions, ok := work.Annotations[previousVersionsAnnotationKey]; ok {
+		if err := json.Unmarshal([]byte(previousVersions), &state.previousVersions); err != nil {
+			return nil, fmt.Errorf("invalid previous versions: %v", err)
+		}
+	}
+	return state, nil
+}
+
+// klusterletFeedback returns the status feedbacks of the Klusterlet in the work.
+func klusterletFeedback(work *workv1.ManifestWork) map[string]workv1.FieldValue {
+	values := map[string]workv1.FieldValue{}
+	for _, manifest := range work.Status.ResourceStatus.Manifests {
+		if manifest.ResourceMeta.Resource != "klusterlets" {
+			continue
+		}
+		for _, value := range manifest.StatusFeedbacks.Values {
+			values[value.Name] = value.Value
+		}
+	}
+	return values
+}
+
+// currentImages returns the images of the Klusterlet reported by the status feedbacks, false if the Klusterlet is
+// not reported yet.
+func currentImages(feedback map[string]workv1.FieldValue) (*KlusterletImages, bool) {
+	if _, ok := feedback[feedbackGeneration]; !ok {
+		return nil, false
+	}
+	return &KlusterletImages{
+		RegistrationImagePullSpec: stringValue(feedback, feedbackRegistrationImage),
+		WorkImagePullSpec:         stringValue(feedback, feedbackWorkImage),
+		ImagePullSpec:             stringValue(feedback, feedbackImage),
+	}, true
+}
+
+// klusterletUpgraded returns true if the Klusterlet has the target images and is reconciled by the klusterlet
+// operator, otherwise returns the reason.
+func klusterletUpgraded(feedback map[string]workv1.FieldValue, target KlusterletImages) (bool, string) {
+	current, ok := currentImages(feedback)
+	if !ok {
+		return false, "the klusterlet is not reported"
+	}
+	if (len(target.RegistrationImagePullSpec) > 0 && current.RegistrationImagePullSpec != target.RegistrationImagePullSpec) ||
+		(len(target.WorkImagePullSpec) > 0 && current.WorkImagePullSpec != target.WorkImagePullSpec) ||
+		(len(target.ImagePullSpec) > 0 && current.ImagePullSpec != target.ImagePullSpec) {
+		return false, "the images of the klusterlet are not updated"
+	}
+	if intValue(feedback, feedbackGeneration) != intValue(feedback, feedbackObservedGeneration) {
+		return false, "the klusterlet is not reconciled by the klusterlet operator"
+	}
+	return true, ""
+}
+
+func stringValue(feedback map[string]workv1.FieldValue, name string) string {
+	value, ok := feedback[name]
+	if !ok || value.String == nil {
+		return ""
+	}
+	return *value.String
+}
+
+func intValue(feedback map[string]workv1.FieldValue, name string) int64 {
+	value, ok := feedback[name]
+	if !ok || value.Integer == nil {
+		return 0
+	}
+	return *value.Integer
+}
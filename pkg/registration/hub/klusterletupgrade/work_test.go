@@ -0,0 +1,128 @@
+package klusterletupgrade
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	workv1 "open-cluster-management.io/api/work/v1"
+)
+
+func TestNewUpgradeWork(t *testing.T) {
+	transitionTime := metav1.NewTime(time.Now().Truncate(time.Second))
+	cases := []struct {
+		name             string
+		state            *upgradeState
+		expectedStrategy workv1.UpdateStrategyType
+		expectedSpec     map[string]interface{}
+	}{
+		{
+			name:             "preparing",
+			state:            &upgradeState{phase: PhasePreparing, transitionTime: transitionTime, target: testTarget},
+			expectedStrategy: workv1.UpdateStrategyTypeReadOnly,
+		},
+		{
+			name: "upgrading",
+			state: &upgradeState{phase: PhaseUpgrading, transitionTime: transitionTime, target: testTarget,
+				previous: testPrevious, previousVersions: map[string]string{"registration": "v1.0.0"}},
+			expectedStrategy: workv1.UpdateStrategyTypeServerSideApply,
+			expectedSpec:     map[string]interface{}{"registrationImagePullSpec": testTarget.RegistrationImagePullSpec},
+		},
+		{
+			name: "rolled back",
+			state: &upgradeState{phase: PhaseRolledBack, transitionTime: transitionTime, target: testTarget,
+				previous: &KlusterletImages{WorkImagePullSpec: "quay.io/ocm/work:v1"}},
+			expectedStrategy: workv1.UpdateStrategyTypeServerSideApply,
+			expectedSpec:     map[string]interface{}{"workImagePullSpec": "quay.io/ocm/work:v1"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			work, err := newUpgradeWork(testUpgradeKey, testCluster, DefaultKlusterletName, DefaultAgentNamespace, c.state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if work.Spec.DeleteOption.PropagationPolicy != workv1.DeletePropagationPolicyTypeOrphan {
+				t.Errorf("expected the klusterlet is orphaned, but got %v", work.Spec.DeleteOption)
+			}
+			if sa := work.Spec.Executor.Subject.ServiceAccount; sa == nil ||
+				sa.Namespace != DefaultAgentNamespace || sa.Name != ExecutorServiceAccount {
+				t.Errorf("expected the work is applied as the upgrade executor, but got %v", work.Spec.Executor)
+			}
+			if strategy := work.Spec.ManifestConfigs[0].UpdateStrategy.Type; strategy != c.expectedStrategy {
+				t.Errorf("expected update strategy %s, but got %s", c.expectedStrategy, strategy)
+			}
+
+			klusterlet := &unstructured.Unstructured{}
+			if err := klusterlet.UnmarshalJSON(work.Spec.Workload.Manifests[0].Raw); err != nil {
+				t.Fatal(err)
+			}
+			spec, _, _ := unstructured.NestedMap(klusterlet.Object, "spec")
+			if !reflect.DeepEqual(spec, c.expectedSpec) {
+				t.Errorf("expected spec %v, but got %v", c.expectedSpec, spec)
+			}
+
+			state, err := getUpgradeState(work)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(state.previous, c.state.previous) ||
+				!reflect.DeepEqual(state.previousVersions, c.state.previousVersions) || state.phase != c.state.phase ||
+				state.target != c.state.target || !state.transitionTime.Equal(&c.state.transitionTime) {
+				t.Errorf("expected state %v, but got %v", c.state, state)
+			}
+		})
+	}
+}
+
+func TestKlusterletUpgraded(t *testing.T) {
+	generation, observedGeneration := int64(3), int64(2)
+	image := testTarget.RegistrationImagePullSpec
+	oldImage := testPrevious.RegistrationImagePullSpec
+	cases := []struct {
+		name     string
+		feedback map[string]workv1.FieldValue
+		expected bool
+	}{
+		{
+			name:     "not reported",
+			feedback: map[string]workv1.FieldValue{},
+		},
+		{
+			name: "image is not updated",
+			feedback: map[string]workv1.FieldValue{
+				feedbackRegistrationImage: {Type: workv1.String, String: &oldImage},
+				feedbackGeneration:        {Type: workv1.Integer, Integer: &generation},
+			},
+		},
+		{
+			name: "not reconciled",
+			feedback: map[string]workv1.FieldValue{
+				feedbackRegistrationImage:  {Type: workv1.String, String: &image},
+				feedbackGeneration:         {Type: workv1.Integer, Integer: &generation},
+				feedbackObservedGeneration: {Type: workv1.Integer, Integer: &observedGeneration},
+			},
+		},
+		{
+			name: "upgraded",
+			feedback: map[string]workv1.FieldValue{
+				feedbackRegistrationImage:  {Type: workv1.String, String: &image},
+				feedbackGeneration:         {Type: workv1.Integer, Integer: &generation},
+				feedbackObservedGeneration: {Type: workv1.Integer, Integer: &generation},
+			},
+			expected: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if upgraded, message := klusterletUpgraded(c.feedback, testTarget); upgraded != c.expected {
+				t.Errorf("expected %v, but got %v: %s", c.expected, upgraded, message)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package lease
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	coordv1 "k8s.io/api/coordination/v1"
@@ -23,10 +24,12 @@ import (
 	"open-cluster-management.io/sdk-go/pkg/patcher"
 
 	"open-cluster-management.io/ocm/pkg/common/queue"
+	"open-cluster-management.io/ocm/pkg/registration/helpers"
 )
 
 const leaseDurationTimes = 5
 const leaseName = "managed-cluster-lease"
+const reasonInMaintenance = "ManagedClusterInMaintenance"
 
 var (
 	// LeaseDurationSeconds is lease update time interval
@@ -91,7 +94,7 @@ func (c *leaseController) sync(ctx context.Context, syncCtx factory.SyncContext,
 	if errors.IsNotFound(err) {
 		if !cluster.DeletionTimestamp.IsZero() {
 			// the lease is not found and the cluster is deleting, update the cluster to unknown immediately
-			return c.updateClusterStatus(ctx, cluster)
+			return c.updateClusterStatus(ctx, cluster, true, time.Now())
 		}
 
 		// the lease is not found, try to create it
@@ -122,11 +125,12 @@ func (c *leaseController) sync(ctx context.Context, syncCtx factory.SyncContext,
 	now := time.Now()
 	leaseExpired := !now.Before(observedLease.Spec.RenewTime.Add(gracePeriod))
 
+	// the maintenance condition is updated even though the lease is fresh
+	if err := c.updateClusterStatus(ctx, cluster, leaseExpired, now); err != nil {
+		return err
+	}
+
 	if leaseExpired {
-		// the lease is not updated constantly, change the cluster available condition to unknown
-		if err := c.updateClusterStatus(ctx, cluster); err != nil {
-			return err
-		}
 		// Requeue after grace period. Recovery will be detected immediately via lease watch.
 		syncCtx.Queue().AddAfter(clusterName, gracePeriod)
 	} else {
@@ -135,29 +139,96 @@ func (c *leaseController) sync(ctx context.Context, syncCtx factory.SyncContext,
 		syncCtx.Queue().AddAfter(clusterName, timeUntilExpiry)
 	}
 
+	// requeue when the maintenance window ends to check whether the cluster is back
+	if inMaintenance, remaining := helpers.IsInMaintenance(cluster, now); inMaintenance {
+		syncCtx.Queue().AddAfter(clusterName, remaining)
+	}
+
 	return nil
 }
 
-func (c *leaseController) updateClusterStatus(ctx context.Context, cluster *clusterv1.ManagedCluster) error {
-	if meta.IsStatusConditionPresentAndEqual(cluster.Status.Conditions, clusterv1.ManagedClusterConditionAvailable, metav1.ConditionUnknown) {
-		// the managed cluster available condition alreay is unknown, do nothing
-		return nil
-	}
-
+// updateClusterStatus updates the maintenance condition of the cluster, and changes the cluster available condition to
+// unknown if the lease is expired.
+func (c *leaseController) updateClusterStatus(ctx context.Context, cluster *clusterv1.ManagedCluster, leaseExpired bool, now time.Time) error {
 	newCluster := cluster.DeepCopy()
-	meta.SetStatusCondition(&newCluster.Status.Conditions, metav1.Condition{
-		Type:    clusterv1.ManagedClusterConditionAvailable,
-		Status:  metav1.ConditionUnknown,
-		Reason:  "ManagedClusterLeaseUpdateStopped",
-		Message: "Registration agent stopped updating its lease.",
-	})
+	maintenanceExpired := setMaintenanceCondition(newCluster, leaseExpired, now)
+	inMaintenance, _ := helpers.IsInMaintenance(cluster, now)
+
+	availableUpdated := false
+	if leaseExpired {
+		reason, message := "ManagedClusterLeaseUpdateStopped", "Registration agent stopped updating its lease."
+		if inMaintenance {
+			reason, message = reasonInMaintenance, "Registration agent stopped updating its lease during the planned maintenance."
+		}
+
+		// the managed cluster available condition already is unknown, only update it when the maintenance state changes
+		cond := meta.FindStatusCondition(cluster.Status.Conditions, clusterv1.ManagedClusterConditionAvailable)
+		if cond == nil || cond.Status != metav1.ConditionUnknown || inMaintenance != (cond.Reason == reasonInMaintenance) {
+			meta.SetStatusCondition(&newCluster.Status.Conditions, metav1.Condition{
+				Type:    clusterv1.ManagedClusterConditionAvailable,
+				Status:  metav1.ConditionUnknown,
+				Reason:  reason,
+				Message: message,
+			})
+			availableUpdated = true
+		}
+	}
 
 	updated, err := c.patcher.PatchStatus(ctx, newCluster, newCluster.Status, cluster.Status)
-	if updated {
-		newCluster.SetNamespace(newCluster.Name)
+	if !updated {
+		return err
+	}
+
+	newCluster.SetNamespace(newCluster.Name)
+	switch {
+	case availableUpdated && inMaintenance:
+		c.mcEventRecorder.Eventf(newCluster, nil, corev1.EventTypeNormal, "AvailableUnknown", "AvailableUnknown",
+			"The %s is in the planned maintenance, and the connection check from the managed cluster to the hub cluster has stopped", cluster.Name)
+	case availableUpdated:
 		c.mcEventRecorder.Eventf(newCluster, nil, corev1.EventTypeWarning, "AvailableUnknown", "AvailableUnknown",
 			"The %s is successfully imported. However, the connection check from the managed cluster to the hub cluster has failed", cluster.Name)
 	}
+	if maintenanceExpired {
+		c.mcEventRecorder.Eventf(newCluster, nil, corev1.EventTypeWarning, "MaintenanceExpired", "MaintenanceExpired",
+			"The maintenance window of %s has ended, but its lease is still not updated", cluster.Name)
+	}
 
 	return err
 }
+
+// setMaintenanceCondition sets the maintenance condition according to the maintenance window declared on the cluster.
+// It returns true if the window has just ended while the lease is still expired.
+func setMaintenanceCondition(cluster *clusterv1.ManagedCluster, leaseExpired bool, now time.Time) bool {
+	until, ok := helpers.MaintenanceUntil(cluster)
+	if !ok {
+		meta.RemoveStatusCondition(&cluster.Status.Conditions, helpers.ManagedClusterConditionMaintenance)
+		return false
+	}
+
+	declaredUntil, _ := helpers.DeclaredMaintenanceUntil(cluster)
+	cond := metav1.Condition{Type: helpers.ManagedClusterConditionMaintenance}
+	switch {
+	case now.Before(until):
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = reasonInMaintenance
+		cond.Message = fmt.Sprintf("The managed cluster is in the planned maintenance until %s", until.Format(time.RFC3339))
+	case now.Before(declaredUntil):
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = helpers.ReasonMaintenanceWindowExceeded
+		cond.Message = fmt.Sprintf("The planned maintenance ended at %s, it exceeded the maximum window %v",
+			until.Format(time.RFC3339), helpers.MaxMaintenanceWindow)
+	case leaseExpired:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "ManagedClusterMaintenanceExpired"
+		cond.Message = fmt.Sprintf("The planned maintenance ended at %s, but the lease is still not updated", until.Format(time.RFC3339))
+	default:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "ManagedClusterMaintenanceEnded"
+		cond.Message = fmt.Sprintf("The planned maintenance ended at %s", until.Format(time.RFC3339))
+	}
+
+	existing := meta.FindStatusCondition(cluster.Status.Conditions, helpers.ManagedClusterConditionMaintenance)
+	expired := cond.Reason == "ManagedClusterMaintenanceExpired" && (existing == nil || existing.Reason != cond.Reason)
+	meta.SetStatusCondition(&cluster.Status.Conditions, cond)
+	return expired
+}
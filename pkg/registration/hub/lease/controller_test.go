@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	kubeinformers "k8s.io/client-go/informers"
@@ -22,6 +23,7 @@ import (
 	"open-cluster-management.io/sdk-go/pkg/patcher"
 
 	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
+	"open-cluster-management.io/ocm/pkg/registration/helpers"
 	testinghelpers "open-cluster-management.io/ocm/pkg/registration/helpers/testing"
 )
 
@@ -148,6 +150,106 @@ func TestSync(t *testing.T) {
 				testingcommon.AssertNoActions(t, clusterActions)
 			},
 		},
+		{
+			name: "managed cluster in maintenance stop update lease",
+			clusters: []runtime.Object{newMaintenanceManagedCluster(
+				testinghelpers.NewAvailableManagedCluster(), now.Add(time.Hour))},
+			clusterLeases: []runtime.Object{testinghelpers.NewManagedClusterLease("managed-cluster-lease", now.Add(-5*time.Minute))},
+			validateActions: func(t *testing.T, leaseActions, clusterActions []clienttesting.Action) {
+				testingcommon.AssertActions(t, clusterActions, "patch")
+				patch := clusterActions[0].(clienttesting.PatchAction).GetPatch()
+				managedCluster := &clusterv1.ManagedCluster{}
+				err := json.Unmarshal(patch, managedCluster)
+				if err != nil {
+					t.Fatal(err)
+				}
+				testingcommon.AssertCondition(t, managedCluster.Status.Conditions, metav1.Condition{
+					Type:    clusterv1.ManagedClusterConditionAvailable,
+					Status:  metav1.ConditionUnknown,
+					Reason:  "ManagedClusterInMaintenance",
+					Message: "Registration agent stopped updating its lease during the planned maintenance.",
+				})
+				if !meta.IsStatusConditionTrue(managedCluster.Status.Conditions, helpers.ManagedClusterConditionMaintenance) {
+					t.Errorf("expected maintenance condition is true, but got %v", managedCluster.Status.Conditions)
+				}
+			},
+		},
+		{
+			name: "maintenance is ended but lease is not updated",
+			clusters: []runtime.Object{func() *clusterv1.ManagedCluster {
+				cluster := newMaintenanceManagedCluster(testinghelpers.NewUnknownManagedCluster(), now.Add(-time.Minute))
+				cluster.Status.Conditions = append(cluster.Status.Conditions, metav1.Condition{
+					Type:   helpers.ManagedClusterConditionMaintenance,
+					Status: metav1.ConditionTrue,
+					Reason: "ManagedClusterInMaintenance",
+				})
+				return cluster
+			}()},
+			clusterLeases: []runtime.Object{testinghelpers.NewManagedClusterLease("managed-cluster-lease", now.Add(-5*time.Minute))},
+			validateActions: func(t *testing.T, leaseActions, clusterActions []clienttesting.Action) {
+				testingcommon.AssertActions(t, clusterActions, "patch")
+				patch := clusterActions[0].(clienttesting.PatchAction).GetPatch()
+				managedCluster := &clusterv1.ManagedCluster{}
+				err := json.Unmarshal(patch, managedCluster)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cond := meta.FindStatusCondition(managedCluster.Status.Conditions, helpers.ManagedClusterConditionMaintenance)
+				if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != "ManagedClusterMaintenanceExpired" {
+					t.Errorf("expected maintenance expired condition, but got %v", cond)
+				}
+				// the alert event is recorded
+				if len(leaseActions) != 1 || leaseActions[0].GetResource().Resource != "events" {
+					t.Errorf("expected an event, but got %v", leaseActions)
+				}
+			},
+		},
+		{
+			name: "maintenance exceeds the maximum window",
+			clusters: []runtime.Object{func() *clusterv1.ManagedCluster {
+				cluster := newMaintenanceManagedCluster(testinghelpers.NewUnknownManagedCluster(), now.Add(time.Hour))
+				cluster.Status.Conditions = append(cluster.Status.Conditions, metav1.Condition{
+					Type:               helpers.ManagedClusterConditionMaintenance,
+					Status:             metav1.ConditionTrue,
+					Reason:             "ManagedClusterInMaintenance",
+					LastTransitionTime: metav1.NewTime(now.Add(-helpers.MaxMaintenanceWindow)),
+				})
+				return cluster
+			}()},
+			clusterLeases: []runtime.Object{testinghelpers.NewManagedClusterLease("managed-cluster-lease", now.Add(-5*time.Minute))},
+			validateActions: func(t *testing.T, leaseActions, clusterActions []clienttesting.Action) {
+				testingcommon.AssertActions(t, clusterActions, "patch")
+				patch := clusterActions[0].(clienttesting.PatchAction).GetPatch()
+				managedCluster := &clusterv1.ManagedCluster{}
+				err := json.Unmarshal(patch, managedCluster)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cond := meta.FindStatusCondition(managedCluster.Status.Conditions, helpers.ManagedClusterConditionMaintenance)
+				if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != helpers.ReasonMaintenanceWindowExceeded {
+					t.Errorf("expected maintenance window exceeded condition, but got %v", cond)
+				}
+			},
+		},
+		{
+			name: "maintenance is ended and lease is updated",
+			clusters: []runtime.Object{newMaintenanceManagedCluster(
+				testinghelpers.NewAvailableManagedCluster(), now.Add(-time.Minute))},
+			clusterLeases: []runtime.Object{testinghelpers.NewManagedClusterLease("managed-cluster-lease", now)},
+			validateActions: func(t *testing.T, leaseActions, clusterActions []clienttesting.Action) {
+				testingcommon.AssertActions(t, clusterActions, "patch")
+				patch := clusterActions[0].(clienttesting.PatchAction).GetPatch()
+				managedCluster := &clusterv1.ManagedCluster{}
+				err := json.Unmarshal(patch, managedCluster)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cond := meta.FindStatusCondition(managedCluster.Status.Conditions, helpers.ManagedClusterConditionMaintenance)
+				if cond == nil || cond.Status != metav1.ConditionFalse || cond.Reason != "ManagedClusterMaintenanceEnded" {
+					t.Errorf("expected maintenance ended condition, but got %v", cond)
+				}
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -197,6 +299,11 @@ func TestSync(t *testing.T) {
 	}
 }
 
+func newMaintenanceManagedCluster(cluster *clusterv1.ManagedCluster, until time.Time) *clusterv1.ManagedCluster {
+	cluster.Annotations = map[string]string{helpers.MaintenanceUntilAnnotationKey: until.Format(time.RFC3339)}
+	return cluster
+}
+
 func newDeletingManagedCluster() *clusterv1.ManagedCluster {
 	now := metav1.Now()
 	cluster := testinghelpers.NewAcceptedManagedCluster()
@@ -246,6 +246,17 @@ func (c *managedClusterController) sync(ctx context.Context, syncCtx factory.Syn
 		return err
 	}
 
+	// The agent of the cluster restored from a hub backup has rejoined the hub, so its csrs are not auto approved
+	// any longer.
+	if _, restored := managedCluster.Annotations[commonhelper.RestoredFromHubAnnotationKey]; restored &&
+		meta.IsStatusConditionTrue(managedCluster.Status.Conditions, v1.ManagedClusterConditionJoined) {
+		delete(newManagedCluster.Annotations, commonhelper.RestoredFromHubAnnotationKey)
+		if _, err := c.patcher.PatchLabelAnnotations(
+			ctx, newManagedCluster, newManagedCluster.ObjectMeta, managedCluster.ObjectMeta); err != nil {
+			return err
+		}
+	}
+
 	resourceResults := c.applier.Apply(ctx, syncCtx.Recorder(),
 		helpers.ManagedClusterAssetFnWithAccepted(manifests.RBACManifests, managedClusterName, managedCluster.Spec.HubAcceptsClient, c.labels),
 		append(manifests.ClusterSpecificRBACFiles, manifests.ClusterSpecificRoleBindings...)...)
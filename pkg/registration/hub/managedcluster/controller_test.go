@@ -26,6 +26,7 @@ import (
 	"open-cluster-management.io/sdk-go/pkg/patcher"
 
 	"open-cluster-management.io/ocm/pkg/common/apply"
+	commonhelper "open-cluster-management.io/ocm/pkg/common/helpers"
 	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
 	"open-cluster-management.io/ocm/pkg/features"
 	testinghelpers "open-cluster-management.io/ocm/pkg/registration/helpers/testing"
@@ -116,6 +117,37 @@ func TestSyncManagedCluster(t *testing.T) {
 					"create") // work rolebinding
 			},
 		},
+		{
+			name: "clear the restored annotation once the spoke cluster joined",
+			startingObjects: []runtime.Object{func() *v1.ManagedCluster {
+				cluster := testinghelpers.NewJoinedManagedCluster()
+				cluster.Annotations = map[string]string{commonhelper.RestoredFromHubAnnotationKey: "hubhash"}
+				return cluster
+			}()},
+			validateClusterActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				patch := struct {
+					Metadata struct {
+						Annotations map[string]*string `json:"annotations"`
+					} `json:"metadata"`
+				}{}
+				if err := json.Unmarshal(actions[0].(clienttesting.PatchAction).GetPatch(), &patch); err != nil {
+					t.Fatal(err)
+				}
+				annotations := patch.Metadata.Annotations
+				if value, ok := annotations[commonhelper.RestoredFromHubAnnotationKey]; !ok || value != nil {
+					t.Errorf("expected the restored annotation is removed, but got %v", annotations)
+				}
+			},
+			validateKubeActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions,
+					"get", "create", // namespace
+					"create", // clusterrole
+					"create", // clusterrolebinding
+					"create", // registration rolebinding
+					"create") // work rolebinding
+			},
+		},
 		{
 			name:            "deny an accepted spoke cluster",
 			startingObjects: []runtime.Object{testinghelpers.NewDeniedManagedCluster("True")},
@@ -331,7 +363,7 @@ func TestSyncManagedCluster(t *testing.T) {
 		},
 	}
 
-	features.HubMutableFeatureGate.Add(ocmfeature.DefaultHubRegistrationFeatureGates)
+	features.HubMutableFeatureGate.Add(features.DefaultHubRegistrationFeatureGates)
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
@@ -8,6 +8,7 @@ import (
 	"github.com/openshift/library-go/pkg/controller/controllercmd"
 	"github.com/spf13/pflag"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
@@ -34,6 +35,7 @@ import (
 
 	"open-cluster-management.io/ocm/pkg/features"
 	"open-cluster-management.io/ocm/pkg/registration/hub/addon"
+	"open-cluster-management.io/ocm/pkg/registration/hub/agentversion"
 	"open-cluster-management.io/ocm/pkg/registration/hub/clusterprofile"
 	"open-cluster-management.io/ocm/pkg/registration/hub/clusterrole"
 	"open-cluster-management.io/ocm/pkg/registration/hub/gc"
@@ -41,10 +43,12 @@ import (
 	importeroptions "open-cluster-management.io/ocm/pkg/registration/hub/importer/options"
 	cloudproviders "open-cluster-management.io/ocm/pkg/registration/hub/importer/providers"
 	"open-cluster-management.io/ocm/pkg/registration/hub/importer/providers/capi"
+	"open-cluster-management.io/ocm/pkg/registration/hub/klusterletupgrade"
 	"open-cluster-management.io/ocm/pkg/registration/hub/lease"
 	"open-cluster-management.io/ocm/pkg/registration/hub/managedcluster"
 	"open-cluster-management.io/ocm/pkg/registration/hub/managedclusterset"
 	"open-cluster-management.io/ocm/pkg/registration/hub/managedclustersetbinding"
+	"open-cluster-management.io/ocm/pkg/registration/hub/revocation"
 	"open-cluster-management.io/ocm/pkg/registration/hub/taint"
 	"open-cluster-management.io/ocm/pkg/registration/register"
 	awsirsa "open-cluster-management.io/ocm/pkg/registration/register/aws_irsa"
@@ -68,6 +72,13 @@ type HubManagerOptions struct {
 	GRPCCAFile            string
 	GRPCCAKeyFile         string
 	GRPCSigningDuration   time.Duration
+
+	// TaintRulesFile is the path of the file that declares the taint rules of the managed clusters.
+	TaintRulesFile string
+
+	// EnabledHubFeatures are the features enabled on the hub, the agents of the managed clusters are checked against
+	// the agent version skew policy for these features.
+	EnabledHubFeatures []string
 }
 
 // NewHubManagerOptions returns a HubManagerOptions
@@ -108,6 +119,11 @@ func (m *HubManagerOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&m.GRPCCAFile, "grpc-ca-file", m.GRPCCAFile, "ca file to sign client cert for grpc")
 	fs.StringVar(&m.GRPCCAKeyFile, "grpc-key-file", m.GRPCCAKeyFile, "ca key file to sign client cert for grpc")
 	fs.DurationVar(&m.GRPCSigningDuration, "grpc-signing-duration", m.GRPCSigningDuration, "The max length of duration signed certificates will be given.")
+	fs.StringVar(&m.TaintRulesFile, "taint-rules-file", m.TaintRulesFile,
+		"The path of the file that declares the taint rules. A rule adds its taint to the managed clusters on which its CEL expression is evaluated to true.")
+	fs.StringSliceVar(&m.EnabledHubFeatures, "enabled-hub-features", m.EnabledHubFeatures,
+		"A list of features enabled on the hub. The managed clusters whose agents are too old for these features "+
+			"according to the agent version skew policy are reported or tainted.")
 	m.ImportOption.AddFlags(fs)
 }
 
@@ -207,7 +223,8 @@ func (m *HubManagerOptions) RunControllerManagerWithInformers(
 			if len(m.AutoApprovedCSRUsers) > 0 {
 				autoApprovedCSRUsers = m.AutoApprovedCSRUsers
 			}
-			csrDriver, err := csr.NewCSRHubDriver(kubeClient, kubeInformers, autoApprovedCSRUsers)
+			csrDriver, err := csr.NewCSRHubDriver(kubeClient, kubeInformers, clusterClient,
+				clusterInformers.Cluster().V1().ManagedClusters(), autoApprovedCSRUsers)
 			if err != nil {
 				return err
 			}
@@ -251,15 +268,25 @@ func (m *HubManagerOptions) RunControllerManagerWithInformers(
 		labelsMap,
 	)
 
-	taintController := taint.NewTaintController(
-		clusterClient,
-		clusterInformers.Cluster().V1().ManagedClusters(),
-	)
-
 	mcRecorder, err := events.NewEventRecorder(ctx, clusterscheme.Scheme, kubeClient.EventsV1(), "registration-controller")
 	if err != nil {
 		return err
 	}
+
+	taintRules := &taint.Rules{}
+	if len(m.TaintRulesFile) > 0 {
+		taintRules, err = taint.LoadRules(m.TaintRulesFile)
+		if err != nil {
+			return err
+		}
+	}
+	taintController := taint.NewTaintController(
+		clusterClient,
+		clusterInformers.Cluster().V1().ManagedClusters(),
+		addOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+		taintRules,
+		mcRecorder,
+	)
 	leaseController := lease.NewClusterLeaseController(
 		kubeClient,
 		clusterClient,
@@ -363,6 +390,68 @@ func (m *HubManagerOptions) RunControllerManagerWithInformers(
 		)
 	}
 
+	var revocationInformers kubeinformers.SharedInformerFactory
+	var revocationController factory.Controller
+	if features.HubMutableFeatureGate.Enabled(features.ClientCertificateRevocation) {
+		// the revocations are kept in a ConfigMap in the hub namespace, which is not managed by registration
+		revocationInformers = kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, 30*time.Minute,
+			kubeinformers.WithNamespace(controllerContext.OperatorNamespace),
+			kubeinformers.WithTweakListOptions(func(listOptions *metav1.ListOptions) {
+				listOptions.FieldSelector = fields.OneTermEqualSelector("metadata.name", revocation.ConfigMapName).String()
+			}))
+		revocationController = revocation.NewRevocationController(
+			controllerContext.OperatorNamespace,
+			kubeClient,
+			clusterInformers.Cluster().V1().ManagedClusters(),
+			addOnInformers.Addon().V1alpha1().ManagedClusterAddOns(),
+			kubeInformers.Certificates().V1().CertificateSigningRequests(),
+			revocationInformers.Core().V1().ConfigMaps(),
+		)
+	}
+
+	var upgradeInformers kubeinformers.SharedInformerFactory
+	var klusterletUpgradeController factory.Controller
+	if features.HubMutableFeatureGate.Enabled(features.KlusterletUpgrade) {
+		// the fleet upgrade ConfigMaps are not managed by registration, so they are watched by a separate informer,
+		// and only the ones in the hub namespace are accepted.
+		upgradeWorkClient, err := workv1client.NewForConfig(controllerContext.KubeConfig)
+		if err != nil {
+			return err
+		}
+		upgradeInformers = kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, 30*time.Minute,
+			kubeinformers.WithNamespace(controllerContext.OperatorNamespace),
+			kubeinformers.WithTweakListOptions(func(listOptions *metav1.ListOptions) {
+				listOptions.LabelSelector = klusterletupgrade.KlusterletUpgradeLabelKey
+			}))
+		klusterletUpgradeController = klusterletupgrade.NewKlusterletUpgradeController(
+			controllerContext.OperatorNamespace,
+			kubeClient,
+			upgradeWorkClient,
+			upgradeInformers.Core().V1().ConfigMaps(),
+			clusterInformers.Cluster().V1().ManagedClusters(),
+			kubeInformers.Coordination().V1().Leases(),
+			clusterInformers.Cluster().V1beta1().Placements(),
+			clusterInformers.Cluster().V1beta1().PlacementDecisions(),
+			workInformers.Work().V1().ManifestWorks(),
+		)
+	}
+
+	var agentVersionInformers kubeinformers.SharedInformerFactory
+	var agentVersionController factory.Controller
+	if features.HubMutableFeatureGate.Enabled(features.AgentVersionSkew) {
+		// the skew policy and the inventory are in the hub namespace
+		agentVersionInformers = kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, 30*time.Minute,
+			kubeinformers.WithNamespace(controllerContext.OperatorNamespace))
+		agentVersionController = agentversion.NewAgentVersionController(
+			kubeClient,
+			clusterClient,
+			clusterInformers.Cluster().V1().ManagedClusters(),
+			agentVersionInformers.Core().V1().ConfigMaps(),
+			controllerContext.OperatorNamespace,
+			m.EnabledHubFeatures,
+		)
+	}
+
 	gcController := gc.NewGCController(
 		clusterInformers.Cluster().V1().ManagedClusters(),
 		clusterClient,
@@ -377,6 +466,15 @@ func (m *HubManagerOptions) RunControllerManagerWithInformers(
 	if features.HubMutableFeatureGate.Enabled(ocmfeature.ClusterProfile) {
 		go clusterProfileInformers.Start(ctx.Done())
 	}
+	if features.HubMutableFeatureGate.Enabled(features.ClientCertificateRevocation) {
+		go revocationInformers.Start(ctx.Done())
+	}
+	if features.HubMutableFeatureGate.Enabled(features.KlusterletUpgrade) {
+		go upgradeInformers.Start(ctx.Done())
+	}
+	if features.HubMutableFeatureGate.Enabled(features.AgentVersionSkew) {
+		go agentVersionInformers.Start(ctx.Done())
+	}
 
 	go managedClusterController.Run(ctx, 1)
 	go taintController.Run(ctx, 1)
@@ -408,6 +506,18 @@ func (m *HubManagerOptions) RunControllerManagerWithInformers(
 		go gcController.Run(ctx, 1)
 	}
 
+	if features.HubMutableFeatureGate.Enabled(features.ClientCertificateRevocation) {
+		go revocationController.Run(ctx, 1)
+	}
+
+	if features.HubMutableFeatureGate.Enabled(features.KlusterletUpgrade) {
+		go klusterletUpgradeController.Run(ctx, 1)
+	}
+
+	if features.HubMutableFeatureGate.Enabled(features.AgentVersionSkew) {
+		go agentVersionController.Run(ctx, 1)
+	}
+
 	<-ctx.Done()
 	return nil
 }
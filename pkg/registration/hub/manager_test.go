@@ -58,7 +58,7 @@ var _ = ginkgo.BeforeSuite(func() {
 
 	err = features.SpokeMutableFeatureGate.Add(ocmfeature.DefaultSpokeRegistrationFeatureGates)
 	gomega.Expect(err).ToNot(gomega.HaveOccurred())
-	err = features.HubMutableFeatureGate.Add(ocmfeature.DefaultHubRegistrationFeatureGates)
+	err = features.HubMutableFeatureGate.Add(features.DefaultHubRegistrationFeatureGates)
 	gomega.Expect(err).ToNot(gomega.HaveOccurred())
 
 	err = clusterv1.Install(scheme.Scheme)
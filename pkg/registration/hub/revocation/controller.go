@@ -0,0 +1,364 @@
+package revocation
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"reflect"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	certificatesv1informers "k8s.io/client-go/informers/certificates/v1"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	certificatesv1listers "k8s.io/client-go/listers/certificates/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+
+	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
+	clusterinformerv1 "open-cluster-management.io/api/client/cluster/informers/externalversions/cluster/v1"
+	clusterlisterv1 "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
+
+	"open-cluster-management.io/ocm/pkg/common/queue"
+	"open-cluster-management.io/ocm/pkg/registration/helpers"
+)
+
+const (
+	// credentialLifetime is the maximum lifetime of the revoked credentials whose expiration is unknown, e.g. the
+	// certificates whose CSRs are already removed and the tokens. It is the default duration of the kube-apiserver
+	// client signer and the addon agent tokens.
+	credentialLifetime = 365 * 24 * time.Hour
+
+	// rotationGracePeriod is the duration to wait for the agent to renew its credentials once the time of a forced
+	// rotation is passed, the credentials are revoked once the agent certificate is renewed or the period is passed.
+	rotationGracePeriod = 10 * time.Minute
+)
+
+// revocationController populates the revocations of the managed cluster credentials when a managed cluster is
+// denied, detached or its credentials are forced to rotate. A revocation is removed once all the revoked credentials
+// are expired.
+type revocationController struct {
+	namespace       string
+	kubeClient      kubernetes.Interface
+	clusterLister   clusterlisterv1.ManagedClusterLister
+	addOnLister     addonlisterv1alpha1.ManagedClusterAddOnLister
+	csrLister       certificatesv1listers.CertificateSigningRequestLister
+	configMapLister corev1listers.ConfigMapLister
+}
+
+// NewRevocationController creates a new revocation controller keeping the revocations in the ConfigMap of the hub
+// namespace.
+func NewRevocationController(
+	namespace string,
+	kubeClient kubernetes.Interface,
+	clusterInformer clusterinformerv1.ManagedClusterInformer,
+	addOnInformer addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	csrInformer certificatesv1informers.CertificateSigningRequestInformer,
+	configMapInformer corev1informers.ConfigMapInformer) factory.Controller {
+	c := &revocationController{
+		namespace:       namespace,
+		kubeClient:      kubeClient,
+		clusterLister:   clusterInformer.Lister(),
+		addOnLister:     addOnInformer.Lister(),
+		csrLister:       csrInformer.Lister(),
+		configMapLister: configMapInformer.Lister(),
+	}
+	return factory.New().
+		WithInformersQueueKeysFunc(queue.QueueKeyByMetaName, clusterInformer.Informer()).
+		WithInformersQueueKeysFunc(queue.QueueKeyByMetaNamespace, addOnInformer.Informer()).
+		WithInformersQueueKeysFunc(queue.QueueKeyByLabel(clusterv1.ClusterNameLabelKey), csrInformer.Informer()).
+		// the revocations of the detached clusters are only reconciled by the ConfigMap events
+		WithFilteredEventsInformersQueueKeysFunc(revocationQueueKeys,
+			queue.FilterByNames(ConfigMapName), configMapInformer.Informer()).
+		WithSync(c.sync).
+		ToController("CredentialRevocationController")
+}
+
+func revocationQueueKeys(obj runtime.Object) []string {
+	configMap, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(configMap.Data))
+	for clusterName := range configMap.Data {
+		keys = append(keys, clusterName)
+	}
+	return keys
+}
+
+func (c *revocationController) sync(ctx context.Context, syncCtx factory.SyncContext, clusterName string) error {
+	logger := klog.FromContext(ctx).WithValues("managedClusterName", clusterName)
+	logger.V(4).Info("Reconciling credential revocation")
+
+	configMap, err := c.configMapLister.ConfigMaps(c.namespace).Get(ConfigMapName)
+	switch {
+	case errors.IsNotFound(err):
+		configMap = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName, Namespace: c.namespace}}
+	case err != nil:
+		return err
+	default:
+		configMap = configMap.DeepCopy()
+	}
+
+	var existing *Revocation
+	data, found := configMap.Data[clusterName]
+	if found {
+		existing, err = Parse(data)
+		if err != nil {
+			logger.Error(err, "Invalid revocation is replaced")
+			existing = nil
+		}
+	}
+
+	now := time.Now()
+	var desired *Revocation
+	cluster, err := c.clusterLister.Get(clusterName)
+	switch {
+	case errors.IsNotFound(err):
+		// the cluster is detached
+		desired, err = c.revoke(existing, clusterName, ReasonDetached, now, now)
+		if err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case !cluster.Spec.HubAcceptsClient:
+		// there is no credential issued if the cluster has never been accepted
+		accepted := meta.FindStatusCondition(cluster.Status.Conditions, clusterv1.ManagedClusterConditionHubAccepted)
+		if existing == nil && accepted == nil {
+			return nil
+		}
+		// the credentials are not issued since the cluster is denied
+		deniedAt := now
+		if accepted != nil && accepted.Status == metav1.ConditionFalse && !accepted.LastTransitionTime.IsZero() {
+			deniedAt = accepted.LastTransitionTime.Time
+		}
+		desired, err = c.revoke(existing, clusterName, ReasonDenied, deniedAt, now)
+		if err != nil {
+			return err
+		}
+	default:
+		desired, err = c.rotate(ctx, syncCtx, cluster, existing, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	// all the revoked credentials are expired
+	if desired != nil && !now.Before(desired.ExpiresAt.Time) {
+		desired = nil
+	}
+	if desired != nil {
+		syncCtx.Queue().AddAfter(clusterName, desired.ExpiresAt.Sub(now))
+	}
+
+	switch {
+	case desired == nil && !found:
+		return nil
+	case desired == nil:
+		delete(configMap.Data, clusterName)
+		_, err = c.kubeClient.CoreV1().ConfigMaps(c.namespace).Update(ctx, configMap, metav1.UpdateOptions{})
+		return err
+	case reflect.DeepEqual(existing, desired):
+		return nil
+	}
+
+	revocationData, err := json.Marshal(desired)
+	if err != nil {
+		return err
+	}
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data[clusterName] = string(revocationData)
+	if len(configMap.ResourceVersion) == 0 {
+		_, err = c.kubeClient.CoreV1().ConfigMaps(c.namespace).Create(ctx, configMap, metav1.CreateOptions{})
+	} else {
+		_, err = c.kubeClient.CoreV1().ConfigMaps(c.namespace).Update(ctx, configMap, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+
+	if existing == nil || !existing.RevokedAt.Equal(&desired.RevokedAt) {
+		syncCtx.Recorder().Eventf(ctx, "CredentialsRevoked", "The credentials of managed cluster %s are revoked, reason: %s",
+			clusterName, desired.Reason)
+	}
+	return nil
+}
+
+// revoke revokes all the credentials of the cluster, which are not issued since the given time.
+func (c *revocationController) revoke(
+	existing *Revocation, clusterName, reason string, since, now time.Time) (*Revocation, error) {
+	if existing != nil && existing.IssuedBefore == nil && existing.Reason == reason {
+		return existing, nil
+	}
+
+	revocation, err := c.newRevocation(clusterName, nil, since)
+	if err != nil {
+		return nil, err
+	}
+	revocation.RevokedAt = metav1.NewTime(now)
+	revocation.Reason = reason
+	return revocation.merge(existing), nil
+}
+
+// rotate revokes the credentials of an accepted cluster issued before the time requested by the annotation. The
+// credentials issued after a previous revocation are accepted again once the cluster is accepted.
+func (c *revocationController) rotate(ctx context.Context,
+	syncCtx factory.SyncContext, cluster *clusterv1.ManagedCluster, existing *Revocation, now time.Time) (*Revocation, error) {
+	var desired *Revocation
+	if existing != nil {
+		desired = existing.DeepCopy()
+		if desired.IssuedBefore == nil {
+			desired.IssuedBefore = &desired.RevokedAt
+		}
+	}
+
+	if _, ok := cluster.Annotations[helpers.RevokeCredentialsIssuedBeforeAnnotationKey]; !ok {
+		return desired, nil
+	}
+	issuedBefore, ok := helpers.RevokeCredentialsIssuedBefore(cluster)
+	if !ok {
+		syncCtx.Recorder().Warningf(ctx, "InvalidCredentialRotation",
+			"The annotation %s of managed cluster %s is invalid", helpers.RevokeCredentialsIssuedBeforeAnnotationKey, cluster.Name)
+		return desired, nil
+	}
+	if desired != nil && !desired.IssuedBefore.Time.Before(issuedBefore) {
+		return desired, nil
+	}
+	if now.Before(issuedBefore) {
+		// the agents renew their credentials once the time is passed
+		syncCtx.Queue().AddAfter(cluster.Name, issuedBefore.Sub(now))
+		return desired, nil
+	}
+
+	// revoke the credentials once the agent renews its certificate, so the agent is not locked out of the hub.
+	renewed, err := c.isCertificateRenewed(cluster.Name, issuedBefore)
+	if err != nil {
+		return nil, err
+	}
+	if deadline := issuedBefore.Add(rotationGracePeriod); !renewed && now.Before(deadline) {
+		syncCtx.Queue().AddAfter(cluster.Name, deadline.Sub(now))
+		return desired, nil
+	}
+
+	revocation, err := c.newRevocation(cluster.Name, &issuedBefore, issuedBefore)
+	if err != nil {
+		return nil, err
+	}
+	revocation.IssuedBefore = &metav1.Time{Time: issuedBefore}
+	revocation.RevokedAt = metav1.NewTime(now)
+	revocation.Reason = ReasonForcedRotation
+	return revocation.merge(desired), nil
+}
+
+// newRevocation returns the revocation of the credentials of the cluster, the credentials issued after the time of
+// issuedBefore are not revoked if it is set. The credentials of unknown expiration are not issued since the given time.
+func (c *revocationController) newRevocation(clusterName string, issuedBefore *time.Time, since time.Time) (*Revocation, error) {
+	serviceAccountUsers, err := c.serviceAccountUsers(clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := since.Add(credentialLifetime)
+	credentialIDs := sets.New[string]()
+	certs, err := c.certificates(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	for _, cert := range certs {
+		if issuedBefore != nil && !helpers.CertificateIssuedAt(cert.NotBefore).Before(*issuedBefore) {
+			continue
+		}
+		credentialIDs.Insert(CertificateCredentialID(cert))
+		if cert.NotAfter.After(expiresAt) {
+			expiresAt = cert.NotAfter
+		}
+	}
+
+	return &Revocation{
+		Identities:    ClusterIdentities(clusterName, serviceAccountUsers...),
+		CredentialIDs: sets.List(credentialIDs),
+		ExpiresAt:     metav1.NewTime(expiresAt),
+	}, nil
+}
+
+// merge keeps the credentials revoked by the previous revocation.
+func (r *Revocation) merge(previous *Revocation) *Revocation {
+	if previous == nil {
+		return r
+	}
+	r.Identities = sets.List(sets.New(r.Identities...).Insert(previous.Identities...))
+	r.CredentialIDs = sets.List(sets.New(r.CredentialIDs...).Insert(previous.CredentialIDs...))
+	if previous.ExpiresAt.After(r.ExpiresAt.Time) {
+		r.ExpiresAt = previous.ExpiresAt
+	}
+	return r
+}
+
+// serviceAccountUsers returns the users of the service accounts the addon agents of the cluster are registered with.
+func (c *revocationController) serviceAccountUsers(clusterName string) ([]string, error) {
+	addOns, err := c.addOnLister.ManagedClusterAddOns(clusterName).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	users := sets.New[string]()
+	prefix := "system:serviceaccount:" + clusterName + ":"
+	for _, addOn := range addOns {
+		for _, registration := range addOn.Status.Registrations {
+			if strings.HasPrefix(registration.Subject.User, prefix) {
+				users.Insert(registration.Subject.User)
+			}
+		}
+	}
+	return sets.List(users), nil
+}
+
+// isCertificateRenewed returns whether the agent of the cluster has a certificate issued after the time.
+func (c *revocationController) isCertificateRenewed(clusterName string, issuedAfter time.Time) (bool, error) {
+	certs, err := c.certificates(clusterName)
+	if err != nil {
+		return false, err
+	}
+	for _, cert := range certs {
+		if !helpers.CertificateIssuedAt(cert.NotBefore).Before(issuedAfter) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// certificates returns the certificates issued to the cluster agent by the approved CSRs.
+func (c *revocationController) certificates(clusterName string) ([]*x509.Certificate, error) {
+	csrs, err := c.csrLister.List(labels.SelectorFromSet(labels.Set{clusterv1.ClusterNameLabelKey: clusterName}))
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	for _, csr := range csrs {
+		block, _ := pem.Decode(csr.Status.Certificate)
+		if block == nil {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
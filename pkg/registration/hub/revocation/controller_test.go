@@ -0,0 +1,269 @@
+package revocation
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeinformers "k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonfake "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterinformers "open-cluster-management.io/api/client/cluster/informers/externalversions"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
+	"open-cluster-management.io/ocm/pkg/registration/helpers"
+	testinghelpers "open-cluster-management.io/ocm/pkg/registration/helpers/testing"
+)
+
+func newRevocationConfigMap(t *testing.T, revocation Revocation) *corev1.ConfigMap {
+	data, err := json.Marshal(revocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName, Namespace: "open-cluster-management-hub", ResourceVersion: "1"},
+		Data:       map[string]string{testinghelpers.TestManagedClusterName: string(data)},
+	}
+}
+
+func newRotatedCluster(issuedBefore time.Time) *clusterv1.ManagedCluster {
+	cluster := testinghelpers.NewAcceptedManagedCluster()
+	cluster.Annotations = map[string]string{helpers.RevokeCredentialsIssuedBeforeAnnotationKey: issuedBefore.Format(time.RFC3339)}
+	return cluster
+}
+
+func TestSync(t *testing.T) {
+	now := time.Now()
+	revokedAt := metav1.NewTime(now.Add(-time.Hour).Truncate(time.Second))
+	expiresAt := metav1.NewTime(now.Add(time.Hour).Truncate(time.Second))
+	issuedCSR := testinghelpers.NewApprovedCSR(testinghelpers.CSRHolder{
+		Name:   "csr1",
+		Labels: map[string]string{clusterv1.ClusterNameLabelKey: testinghelpers.TestManagedClusterName},
+	})
+	issuedCSR.Status.Certificate = testinghelpers.NewTestCert("test", time.Hour).Cert
+	block, _ := pem.Decode(issuedCSR.Status.Certificate)
+	issuedCert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuedCredentialID := CertificateCredentialID(issuedCert)
+
+	tokenAddOn := testinghelpers.NewManagedClusterAddons("test", testinghelpers.TestManagedClusterName, nil, nil)
+	tokenAddOn.Status.Registrations = []addonv1alpha1.RegistrationConfig{{
+		SignerName: "kubernetes.io/kube-apiserver-client",
+		Subject:    addonv1alpha1.Subject{User: "system:serviceaccount:" + testinghelpers.TestManagedClusterName + ":test-agent"},
+	}}
+
+	cases := []struct {
+		name               string
+		clusters           []runtime.Object
+		addOns             []runtime.Object
+		csrs               []runtime.Object
+		configMaps         []runtime.Object
+		expectedAction     string
+		expectedIdentities []string
+		validateRevocation func(t *testing.T, revocation *Revocation)
+	}{
+		{
+			name:     "cluster is never accepted",
+			clusters: []runtime.Object{testinghelpers.NewManagedCluster()},
+		},
+		{
+			name:     "cluster is accepted",
+			clusters: []runtime.Object{testinghelpers.NewAcceptedManagedCluster()},
+		},
+		{
+			name:               "cluster is denied",
+			clusters:           []runtime.Object{testinghelpers.NewDeniedManagedCluster("False")},
+			addOns:             []runtime.Object{tokenAddOn},
+			csrs:               []runtime.Object{issuedCSR},
+			expectedAction:     "create",
+			expectedIdentities: ClusterIdentities(testinghelpers.TestManagedClusterName, tokenAddOn.Status.Registrations[0].Subject.User),
+			validateRevocation: func(t *testing.T, revocation *Revocation) {
+				if revocation.Reason != ReasonDenied || revocation.IssuedBefore != nil {
+					t.Errorf("expected all credentials are revoked, but got %v", revocation)
+				}
+				if !reflect.DeepEqual(revocation.CredentialIDs, []string{issuedCredentialID}) {
+					t.Errorf("expected certificates are revoked, but got %v", revocation.CredentialIDs)
+				}
+				if revocation.ExpiresAt.Time.Before(now.Add(credentialLifetime - time.Minute)) {
+					t.Errorf("expected the revocation expires after the credential lifetime, but got %v", revocation.ExpiresAt)
+				}
+			},
+		},
+		{
+			name:           "cluster is detached",
+			expectedAction: "create",
+			validateRevocation: func(t *testing.T, revocation *Revocation) {
+				if revocation.Reason != ReasonDetached || revocation.IssuedBefore != nil {
+					t.Errorf("expected all credentials are revoked, but got %v", revocation)
+				}
+			},
+		},
+		{
+			name:     "cluster is already denied",
+			clusters: []runtime.Object{testinghelpers.NewDeniedManagedCluster("False")},
+			configMaps: []runtime.Object{newRevocationConfigMap(t, Revocation{
+				Identities:    ClusterIdentities(testinghelpers.TestManagedClusterName),
+				CredentialIDs: []string{issuedCredentialID},
+				RevokedAt:     revokedAt,
+				ExpiresAt:     expiresAt,
+				Reason:        ReasonDenied,
+			})},
+		},
+		{
+			name: "revocation of detached cluster is expired",
+			configMaps: []runtime.Object{newRevocationConfigMap(t, Revocation{
+				Identities: ClusterIdentities(testinghelpers.TestManagedClusterName),
+				RevokedAt:  revokedAt,
+				ExpiresAt:  revokedAt,
+				Reason:     ReasonDetached,
+			})},
+			expectedAction: "update",
+		},
+		{
+			name:     "cluster is accepted again",
+			clusters: []runtime.Object{testinghelpers.NewAcceptedManagedCluster()},
+			configMaps: []runtime.Object{newRevocationConfigMap(t, Revocation{
+				Identities: ClusterIdentities(testinghelpers.TestManagedClusterName),
+				RevokedAt:  revokedAt,
+				ExpiresAt:  expiresAt,
+				Reason:     ReasonDenied,
+			})},
+			expectedAction: "update",
+			validateRevocation: func(t *testing.T, revocation *Revocation) {
+				if revocation.IssuedBefore == nil || !revocation.IssuedBefore.Equal(&revokedAt) {
+					t.Errorf("expected the credentials issued before the revocation are revoked, but got %v", revocation)
+				}
+			},
+		},
+		{
+			name:           "credentials are forced to rotate after the certificate is renewed",
+			clusters:       []runtime.Object{newRotatedCluster(now.Add(-time.Minute))},
+			csrs:           []runtime.Object{issuedCSR},
+			expectedAction: "create",
+			validateRevocation: func(t *testing.T, revocation *Revocation) {
+				if revocation.Reason != ReasonForcedRotation || revocation.IssuedBefore == nil {
+					t.Errorf("expected the credentials issued before the time are revoked, but got %v", revocation)
+				}
+				// the certificate is issued after the time
+				if len(revocation.CredentialIDs) != 0 {
+					t.Errorf("expected no certificate is revoked, but got %v", revocation.CredentialIDs)
+				}
+			},
+		},
+		{
+			name:     "certificate is not renewed yet",
+			clusters: []runtime.Object{newRotatedCluster(now.Add(-time.Minute))},
+		},
+		{
+			name:           "credentials are forced to rotate after the grace period",
+			clusters:       []runtime.Object{newRotatedCluster(revokedAt.Time)},
+			expectedAction: "create",
+			validateRevocation: func(t *testing.T, revocation *Revocation) {
+				if revocation.Reason != ReasonForcedRotation || revocation.IssuedBefore == nil ||
+					!revocation.IssuedBefore.Equal(&revokedAt) {
+					t.Errorf("expected the credentials issued before %v are revoked, but got %v", revokedAt, revocation)
+				}
+			},
+		},
+		{
+			name:     "credentials are already rotated",
+			clusters: []runtime.Object{newRotatedCluster(revokedAt.Time)},
+			configMaps: []runtime.Object{newRevocationConfigMap(t, Revocation{
+				Identities:   ClusterIdentities(testinghelpers.TestManagedClusterName),
+				IssuedBefore: &revokedAt,
+				RevokedAt:    revokedAt,
+				ExpiresAt:    expiresAt,
+				Reason:       ReasonForcedRotation,
+			})},
+		},
+		{
+			name:     "rotation is in the future",
+			clusters: []runtime.Object{newRotatedCluster(now.Add(time.Hour))},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			clusterClient := clusterfake.NewSimpleClientset(c.clusters...)
+			clusterInformerFactory := clusterinformers.NewSharedInformerFactory(clusterClient, 10*time.Minute)
+			for _, cluster := range c.clusters {
+				if err := clusterInformerFactory.Cluster().V1().ManagedClusters().Informer().GetStore().Add(cluster); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			addOnInformerFactory := addoninformers.NewSharedInformerFactory(addonfake.NewSimpleClientset(), 10*time.Minute)
+			for _, addOn := range c.addOns {
+				if err := addOnInformerFactory.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(addOn); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			kubeClient := kubefake.NewSimpleClientset(c.configMaps...)
+			kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubeClient, 10*time.Minute)
+			for _, csr := range c.csrs {
+				if err := kubeInformerFactory.Certificates().V1().CertificateSigningRequests().Informer().GetStore().Add(csr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, configMap := range c.configMaps {
+				if err := kubeInformerFactory.Core().V1().ConfigMaps().Informer().GetStore().Add(configMap); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ctrl := &revocationController{
+				namespace:       "open-cluster-management-hub",
+				kubeClient:      kubeClient,
+				clusterLister:   clusterInformerFactory.Cluster().V1().ManagedClusters().Lister(),
+				addOnLister:     addOnInformerFactory.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
+				csrLister:       kubeInformerFactory.Certificates().V1().CertificateSigningRequests().Lister(),
+				configMapLister: kubeInformerFactory.Core().V1().ConfigMaps().Lister(),
+			}
+			syncCtx := testingcommon.NewFakeSyncContext(t, testinghelpers.TestManagedClusterName)
+			if err := ctrl.sync(context.TODO(), syncCtx, testinghelpers.TestManagedClusterName); err != nil {
+				t.Errorf("unexpected err: %v", err)
+			}
+
+			if len(c.expectedAction) == 0 {
+				testingcommon.AssertNoActions(t, kubeClient.Actions())
+				return
+			}
+			testingcommon.AssertActions(t, kubeClient.Actions(), c.expectedAction)
+			configMap := kubeClient.Actions()[0].(interface{ GetObject() runtime.Object }).GetObject().(*corev1.ConfigMap)
+			data, ok := configMap.Data[testinghelpers.TestManagedClusterName]
+			if c.validateRevocation == nil {
+				if ok {
+					t.Errorf("expected the revocation is removed, but got %s", data)
+				}
+				return
+			}
+			revocation, err := Parse(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectedIdentities := c.expectedIdentities
+			if expectedIdentities == nil {
+				expectedIdentities = ClusterIdentities(testinghelpers.TestManagedClusterName)
+			}
+			if !reflect.DeepEqual(revocation.Identities, expectedIdentities) {
+				t.Errorf("expected identities %v, but got %v", expectedIdentities, revocation.Identities)
+			}
+			c.validateRevocation(t, revocation)
+		})
+	}
+}
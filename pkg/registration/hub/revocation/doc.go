@@ -0,0 +1,8 @@
+// package revocation maintains the denylist of the revoked managed cluster credentials on the hub.
+//
+// The denylist is a ConfigMap in the hub namespace, each key of the ConfigMap is a managed cluster
+// name and the value is the revocation of the cluster credentials. It is populated by the revocation controller when
+// a managed cluster is denied, detached or its credentials are forced to rotate, and is enforced by the gRPC
+// authenticators and the authorization webhook of the hub kube-apiserver. The revocation is only maintained and enforced
+// if the ClientCertificateRevocation feature gate is enabled.
+package revocation
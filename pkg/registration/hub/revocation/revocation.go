@@ -0,0 +1,223 @@
+package revocation
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	"open-cluster-management.io/ocm/pkg/registration/hub/user"
+)
+
+const (
+	// ConfigMapName is the name of the ConfigMap holding the revoked managed cluster credentials.
+	ConfigMapName        = "managed-cluster-credential-revocations"
+	ReasonDenied         = "Denied"
+	ReasonDetached       = "Detached"
+	ReasonForcedRotation = "ForcedRotation"
+
+	// CredentialIDKey is the key of the user extra the kube-apiserver sets to the id of the authenticated credential.
+	CredentialIDKey = "authentication.kubernetes.io/credential-id"
+)
+
+// Revocation is the revocation of the credentials of a managed cluster.
+type Revocation struct {
+	// Identities are the user names and groups of the cluster credentials. An identity ending with * matches the
+	// user names and groups with the prefix.
+	Identities []string `json:"identities,omitempty"`
+	// CredentialIDs are the ids of the revoked client certificates in the format of the kube-apiserver credential id.
+	CredentialIDs []string `json:"credentialIDs,omitempty"`
+	// IssuedBefore is the time before which the credentials of the identities are revoked. All credentials of the
+	// identities are revoked if it is not set, e.g. the cluster is denied or detached.
+	IssuedBefore *metav1.Time `json:"issuedBefore,omitempty"`
+	// RevokedAt is the time of the revocation.
+	RevokedAt metav1.Time `json:"revokedAt"`
+	// ExpiresAt is the time all the revoked credentials are expired, the revocation is removed after it.
+	ExpiresAt metav1.Time `json:"expiresAt"`
+	// Reason is the reason of the revocation.
+	Reason string `json:"reason"`
+}
+
+// DeepCopy returns a deep copy of the revocation.
+func (r *Revocation) DeepCopy() *Revocation {
+	out := &Revocation{
+		Identities:    append([]string(nil), r.Identities...),
+		CredentialIDs: append([]string(nil), r.CredentialIDs...),
+		RevokedAt:     *r.RevokedAt.DeepCopy(),
+		ExpiresAt:     *r.ExpiresAt.DeepCopy(),
+		Reason:        r.Reason,
+	}
+	if r.IssuedBefore != nil {
+		out.IssuedBefore = r.IssuedBefore.DeepCopy()
+	}
+	return out
+}
+
+// Credential is the credential of a request to the hub.
+type Credential struct {
+	User   string
+	Groups []string
+	// ID is the credential id of the client certificate, it is empty if unknown.
+	ID string
+	// IssuedAt is the time the credential is issued, it is zero if unknown.
+	IssuedAt time.Time
+}
+
+// ClusterIdentities returns the identities of the credentials issued to a managed cluster, including the agent
+// certificates, the addon agent certificates and the tokens of the given addon agent service account users.
+func ClusterIdentities(clusterName string, serviceAccountUsers ...string) []string {
+	identities := []string{
+		user.SubjectPrefix + clusterName,
+		fmt.Sprintf("%scluster:%s:addon:*", user.SubjectPrefix, clusterName),
+	}
+	return append(identities, serviceAccountUsers...)
+}
+
+// CertificateCredentialID returns the credential id of the client certificate, it is the same as the id the
+// kube-apiserver sets in the user extra for the certificate.
+func CertificateCredentialID(cert *x509.Certificate) string {
+	fingerprint := sha256.Sum256(cert.Raw)
+	return "X509SHA256=" + hex.EncodeToString(fingerprint[:])
+}
+
+// IsRevoked returns whether the credential is revoked by the revocation.
+func (r *Revocation) IsRevoked(cred Credential) bool {
+	if len(cred.ID) > 0 {
+		for _, id := range r.CredentialIDs {
+			if id == cred.ID {
+				return true
+			}
+		}
+	}
+
+	if !r.matchIdentity(cred) {
+		return false
+	}
+	if r.IssuedBefore == nil {
+		return true
+	}
+	// the issue time of the credential is unknown, it cannot be revoked by time
+	return !cred.IssuedAt.IsZero() && cred.IssuedAt.Before(r.IssuedBefore.Time)
+}
+
+func (r *Revocation) matchIdentity(cred Credential) bool {
+	for _, identity := range r.Identities {
+		if matchIdentity(identity, cred.User) {
+			return true
+		}
+		for _, group := range cred.Groups {
+			if matchIdentity(identity, group) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchIdentity(identity, name string) bool {
+	if prefix, ok := strings.CutSuffix(identity, "*"); ok {
+		return strings.HasPrefix(name, prefix)
+	}
+	return identity == name
+}
+
+type clusterRevocation struct {
+	clusterName string
+	revocation  *Revocation
+}
+
+// Checker checks the credentials against the revocations in the ConfigMap. The revocations are parsed once the
+// ConfigMap is changed and cached for the checks.
+type Checker struct {
+	lock        sync.RWMutex
+	revocations []clusterRevocation
+	hasSynced   cache.InformerSynced
+}
+
+// NewChecker returns a Checker reading the revocations from the ConfigMap in the hub namespace by the informer.
+func NewChecker(namespace string, configMapInformer corev1informers.ConfigMapInformer) (*Checker, error) {
+	c := &Checker{}
+	registration, err := configMapInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: func(obj interface{}) bool {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			cm, ok := obj.(*corev1.ConfigMap)
+			return ok && cm.Namespace == namespace && cm.Name == ConfigMapName
+		},
+		Handler: cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				c.update(obj.(*corev1.ConfigMap))
+			},
+			UpdateFunc: func(_, newObj interface{}) {
+				c.update(newObj.(*corev1.ConfigMap))
+			},
+			DeleteFunc: func(_ interface{}) {
+				c.update(nil)
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.hasSynced = registration.HasSynced
+	return c, nil
+}
+
+func (c *Checker) update(cm *corev1.ConfigMap) {
+	revocations := []clusterRevocation{}
+	if cm != nil {
+		for clusterName, data := range cm.Data {
+			revocation, err := Parse(data)
+			if err != nil {
+				// an invalid revocation is replaced by the revocation controller, it should not block the others
+				klog.ErrorS(err, "Invalid revocation is ignored", "managedClusterName", clusterName)
+				continue
+			}
+			revocations = append(revocations, clusterRevocation{clusterName: clusterName, revocation: revocation})
+		}
+	}
+	sort.Slice(revocations, func(i, j int) bool {
+		return revocations[i].clusterName < revocations[j].clusterName
+	})
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.revocations = revocations
+}
+
+// IsRevoked returns whether the credential is revoked, and the managed cluster name of the revocation.
+func (c *Checker) IsRevoked(cred Credential) (bool, string, error) {
+	if !c.hasSynced() {
+		return false, "", fmt.Errorf("the revocations are not synced")
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for _, r := range c.revocations {
+		if r.revocation.IsRevoked(cred) {
+			return true, r.clusterName, nil
+		}
+	}
+	return false, "", nil
+}
+
+// Parse decodes a revocation from the ConfigMap data.
+func Parse(data string) (*Revocation, error) {
+	revocation := &Revocation{}
+	if err := json.Unmarshal([]byte(data), revocation); err != nil {
+		return nil, err
+	}
+	return revocation, nil
+}
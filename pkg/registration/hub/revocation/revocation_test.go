@@ -0,0 +1,159 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubeinformers "k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestIsRevoked(t *testing.T) {
+	revokedAt := time.Now()
+	cases := []struct {
+		name       string
+		revocation Revocation
+		credential Credential
+		expected   bool
+	}{
+		{
+			name:       "certificate is revoked",
+			revocation: Revocation{CredentialIDs: []string{"X509SHA256=1a"}},
+			credential: Credential{User: "test", ID: "X509SHA256=1a"},
+			expected:   true,
+		},
+		{
+			name:       "token of the addon agent is revoked",
+			revocation: Revocation{Identities: ClusterIdentities("cluster1", "system:serviceaccount:cluster1:test-agent")},
+			credential: Credential{
+				User:   "system:serviceaccount:cluster1:test-agent",
+				Groups: []string{"system:serviceaccounts", "system:serviceaccounts:cluster1"},
+			},
+			expected: true,
+		},
+		{
+			name:       "token of other service account in the cluster namespace",
+			revocation: Revocation{Identities: ClusterIdentities("cluster1", "system:serviceaccount:cluster1:test-agent")},
+			credential: Credential{
+				User:   "system:serviceaccount:cluster1:other",
+				Groups: []string{"system:serviceaccounts", "system:serviceaccounts:cluster1"},
+			},
+		},
+		{
+			name:       "agent identity is revoked",
+			revocation: Revocation{Identities: ClusterIdentities("cluster1")},
+			credential: Credential{
+				User:   "system:open-cluster-management:cluster1:agent",
+				Groups: []string{"system:open-cluster-management:cluster1", "system:open-cluster-management:managed-clusters"},
+			},
+			expected: true,
+		},
+		{
+			name:       "addon identity is revoked",
+			revocation: Revocation{Identities: ClusterIdentities("cluster1")},
+			credential: Credential{
+				User:   "system:open-cluster-management:cluster:cluster1:addon:test:agent:test",
+				Groups: []string{"system:open-cluster-management:cluster:cluster1:addon:test"},
+			},
+			expected: true,
+		},
+		{
+			name:       "identity of another cluster",
+			revocation: Revocation{Identities: ClusterIdentities("cluster1")},
+			credential: Credential{
+				User:   "system:open-cluster-management:cluster:cluster10:addon:test:agent:test",
+				Groups: []string{"system:open-cluster-management:cluster10", "system:serviceaccounts:cluster10"},
+			},
+		},
+		{
+			name: "credential issued before the time",
+			revocation: Revocation{
+				Identities:   ClusterIdentities("cluster1"),
+				IssuedBefore: &metav1.Time{Time: revokedAt},
+			},
+			credential: Credential{
+				Groups:   []string{"system:open-cluster-management:cluster1"},
+				IssuedAt: revokedAt.Add(-time.Minute),
+			},
+			expected: true,
+		},
+		{
+			name: "credential issued after the time",
+			revocation: Revocation{
+				Identities:   ClusterIdentities("cluster1"),
+				IssuedBefore: &metav1.Time{Time: revokedAt},
+			},
+			credential: Credential{
+				Groups:   []string{"system:open-cluster-management:cluster1"},
+				IssuedAt: revokedAt.Add(time.Minute),
+			},
+		},
+		{
+			name: "issue time is unknown",
+			revocation: Revocation{
+				Identities:   ClusterIdentities("cluster1"),
+				IssuedBefore: &metav1.Time{Time: revokedAt},
+			},
+			credential: Credential{Groups: []string{"system:open-cluster-management:cluster1"}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if actual := c.revocation.IsRevoked(c.credential); actual != c.expected {
+				t.Errorf("expected %v, but got %v", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestChecker(t *testing.T) {
+	kubeClient := kubefake.NewSimpleClientset()
+	kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubeClient, 0)
+	checker, err := NewChecker("open-cluster-management-hub", kubeInformerFactory.Core().V1().ConfigMaps())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := checker.IsRevoked(Credential{User: "test"}); err == nil {
+		t.Errorf("expected error before the revocations are synced")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	kubeInformerFactory.Start(ctx.Done())
+	kubeInformerFactory.WaitForCacheSync(ctx.Done())
+
+	revoked, _, err := checker.IsRevoked(Credential{User: "test"})
+	if err != nil || revoked {
+		t.Errorf("expected not revoked without the configmap, but got %v, %v", revoked, err)
+	}
+
+	data, err := json.Marshal(Revocation{Identities: ClusterIdentities("cluster1"), Reason: ReasonDenied})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kubeClient.CoreV1().ConfigMaps("open-cluster-management-hub").Create(ctx, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName, Namespace: "open-cluster-management-hub"},
+		Data:       map[string]string{"cluster1": string(data), "cluster2": "invalid"},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true,
+		func(context.Context) (bool, error) {
+			revoked, clusterName, err := checker.IsRevoked(Credential{Groups: []string{"system:open-cluster-management:cluster1"}})
+			return err == nil && revoked && clusterName == "cluster1", nil
+		}); err != nil {
+		t.Errorf("expected revoked by cluster1: %v", err)
+	}
+	revoked, _, err = checker.IsRevoked(Credential{Groups: []string{"system:open-cluster-management:cluster2"}})
+	if err != nil || revoked {
+		t.Errorf("expected not revoked, but got %v, %v", revoked, err)
+	}
+}
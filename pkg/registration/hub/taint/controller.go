@@ -2,17 +2,26 @@ package taint
 
 import (
 	"context"
+	"strings"
+	"sync"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	kevents "k8s.io/client-go/tools/events"
 	"k8s.io/klog/v2"
 
+	addoninformerv1alpha1 "open-cluster-management.io/api/client/addon/informers/externalversions/addon/v1alpha1"
+	addonlisterv1alpha1 "open-cluster-management.io/api/client/addon/listers/addon/v1alpha1"
 	clientset "open-cluster-management.io/api/client/cluster/clientset/versioned"
 	informerv1 "open-cluster-management.io/api/client/cluster/informers/externalversions/cluster/v1"
 	listerv1 "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
 	v1 "open-cluster-management.io/api/cluster/v1"
 	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
+	ocmcelcommon "open-cluster-management.io/sdk-go/pkg/cel/common"
 	"open-cluster-management.io/sdk-go/pkg/patcher"
 
 	"open-cluster-management.io/ocm/pkg/common/queue"
@@ -29,26 +38,92 @@ var (
 		Key:    v1.ManagedClusterTaintUnreachable,
 		Effect: v1.TaintEffectNoSelect,
 	}
+
+	// MaintenanceTaint replaces the unavailable/unreachable taints during the planned maintenance of a managed
+	// cluster, so the existing placement decisions are kept.
+	MaintenanceTaint = v1.Taint{
+		Key:    helpers.MaintenanceTaintKey,
+		Effect: v1.TaintEffectNoSelectIfNew,
+	}
 )
 
 // taintController
 type taintController struct {
-	patcher       patcher.Patcher[*v1.ManagedCluster, v1.ManagedClusterSpec, v1.ManagedClusterStatus]
-	clusterLister listerv1.ManagedClusterLister
+	patcher         patcher.Patcher[*v1.ManagedCluster, v1.ManagedClusterSpec, v1.ManagedClusterStatus]
+	clusterLister   listerv1.ManagedClusterLister
+	addOnLister     addonlisterv1alpha1.ManagedClusterAddOnLister
+	rules           *Rules
+	mcEventRecorder kevents.EventRecorder
+	// failures records the last reported evaluation failure of each rule on each cluster, so the failure is
+	// reported once until it changes or the rule is evaluated successfully again.
+	failures evaluationFailures
+}
+
+// evaluationFailures tracks the taint rule evaluation failures keyed by the cluster name and the rule name.
+type evaluationFailures struct {
+	lock     sync.Mutex
+	messages map[string]string
 }
 
-// NewTaintController creates a new taint controller
+// report records the failure and returns true if it is not reported yet.
+func (f *evaluationFailures) report(clusterName, ruleName, message string) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.messages == nil {
+		f.messages = map[string]string{}
+	}
+	key := clusterName + "/" + ruleName
+	if last, ok := f.messages[key]; ok && last == message {
+		return false
+	}
+	f.messages[key] = message
+	return true
+}
+
+// resolve forgets the failure of the rule on the cluster.
+func (f *evaluationFailures) resolve(clusterName, ruleName string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.messages, clusterName+"/"+ruleName)
+}
+
+// forget drops all the failures of the cluster.
+func (f *evaluationFailures) forget(clusterName string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for key := range f.messages {
+		if strings.HasPrefix(key, clusterName+"/") {
+			delete(f.messages, key)
+		}
+	}
+}
+
+// NewTaintController creates a new taint controller, besides the built-in taints, the taints declared by the rules
+// are added to or removed from the managed clusters.
 func NewTaintController(
 	clusterClient clientset.Interface,
-	clusterInformer informerv1.ManagedClusterInformer) factory.Controller {
+	clusterInformer informerv1.ManagedClusterInformer,
+	addOnInformer addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	rules *Rules,
+	mcEventRecorder kevents.EventRecorder) factory.Controller {
 	c := &taintController{
 		patcher: patcher.NewPatcher[
 			*v1.ManagedCluster, v1.ManagedClusterSpec, v1.ManagedClusterStatus](
 			clusterClient.ClusterV1().ManagedClusters()),
-		clusterLister: clusterInformer.Lister(),
+		clusterLister:   clusterInformer.Lister(),
+		addOnLister:     addOnInformer.Lister(),
+		rules:           rules,
+		mcEventRecorder: mcEventRecorder,
+	}
+
+	controllerFactory := factory.New().
+		WithInformersQueueKeysFunc(queue.QueueKeyByMetaName, clusterInformer.Informer())
+	if rules.Len() > 0 {
+		// the addons are only required by the taint rules
+		controllerFactory = controllerFactory.WithInformersQueueKeysFunc(queue.QueueKeyByMetaNamespace, addOnInformer.Informer())
 	}
-	return factory.New().
-		WithInformersQueueKeysFunc(queue.QueueKeyByMetaName, clusterInformer.Informer()).
+
+	return controllerFactory.
 		WithSync(c.sync).
 		ToController("taintController")
 }
@@ -59,12 +134,14 @@ func (c *taintController) sync(ctx context.Context, syncCtx factory.SyncContext,
 	managedCluster, err := c.clusterLister.Get(managedClusterName)
 	if errors.IsNotFound(err) {
 		// Spoke cluster not found, could have been deleted, do nothing.
+		c.failures.forget(managedClusterName)
 		return nil
 	}
 	if err != nil {
 		return err
 	}
 	if !managedCluster.DeletionTimestamp.IsZero() {
+		c.failures.forget(managedClusterName)
 		return nil
 	}
 
@@ -73,7 +150,20 @@ func (c *taintController) sync(ctx context.Context, syncCtx factory.SyncContext,
 	cond := meta.FindStatusCondition(newManagedCluster.Status.Conditions, v1.ManagedClusterConditionAvailable)
 	var updated bool
 
+	inMaintenance, remaining := helpers.IsInMaintenance(managedCluster, time.Now())
 	switch {
+	case inMaintenance:
+		// the maintenance only covers the lost connection of the cluster, a cluster reporting itself unavailable
+		// during the maintenance is still tainted as unavailable.
+		if cond != nil && cond.Status == metav1.ConditionFalse {
+			updated = helpers.RemoveTaints(&newTaints, UnreachableTaint)
+			updated = helpers.AddTaints(&newTaints, UnavailableTaint) || updated
+		} else {
+			updated = helpers.RemoveTaints(&newTaints, UnavailableTaint, UnreachableTaint)
+		}
+		updated = helpers.AddTaints(&newTaints, MaintenanceTaint) || updated
+		// requeue when the maintenance window ends to restore the taints
+		syncCtx.Queue().AddAfter(managedClusterName, remaining)
 	case cond == nil || cond.Status == metav1.ConditionUnknown:
 		updated = helpers.RemoveTaints(&newTaints, UnavailableTaint)
 		updated = helpers.AddTaints(&newTaints, UnreachableTaint) || updated
@@ -84,6 +174,12 @@ func (c *taintController) sync(ctx context.Context, syncCtx factory.SyncContext,
 		updated = helpers.RemoveTaints(&newTaints, UnavailableTaint, UnreachableTaint)
 	}
 
+	if !inMaintenance {
+		updated = helpers.RemoveTaints(&newTaints, MaintenanceTaint) || updated
+	}
+	updated = syncHealthProbeTaints(&newTaints, newManagedCluster.Status.Conditions) || updated
+	updated = c.syncRuleTaints(ctx, managedCluster, &newTaints) || updated
+
 	if updated {
 		newManagedCluster.Spec.Taints = newTaints
 		if _, err = c.patcher.PatchSpec(ctx, newManagedCluster, newManagedCluster.Spec, managedCluster.Spec); err != nil {
@@ -93,3 +189,97 @@ func (c *taintController) sync(ctx context.Context, syncCtx factory.SyncContext,
 	}
 	return nil
 }
+
+// syncHealthProbeTaints adds a NoSelect taint for each failed health probe reported by the registration agent which
+// is configured to taint the cluster, the taint key is the condition type of the probe. The taints of the other probes
+// and the probes that pass or are not reported are removed.
+func syncHealthProbeTaints(taints *[]v1.Taint, conditions []metav1.Condition) bool {
+	var updated bool
+	for _, cond := range conditions {
+		if !strings.HasPrefix(cond.Type, helpers.HealthProbeConditionTypePrefix) {
+			continue
+		}
+		probeTaint := v1.Taint{Key: cond.Type, Effect: v1.TaintEffectNoSelect}
+		if cond.Status == metav1.ConditionFalse && cond.Reason == helpers.HealthProbeReasonFailedTaint {
+			updated = helpers.AddTaints(taints, probeTaint) || updated
+		} else {
+			updated = helpers.RemoveTaints(taints, probeTaint) || updated
+		}
+	}
+
+	for _, taint := range *taints {
+		if strings.HasPrefix(taint.Key, helpers.HealthProbeConditionTypePrefix) && meta.FindStatusCondition(conditions, taint.Key) == nil {
+			updated = helpers.RemoveTaints(taints, taint) || updated
+		}
+	}
+	return updated
+}
+
+// syncRuleTaints evaluates the taint rules against the managed cluster, the taint of a rule is added if the rule is
+// matched and removed otherwise. The taints are kept unchanged if a rule fails to be evaluated.
+func (c *taintController) syncRuleTaints(ctx context.Context, managedCluster *v1.ManagedCluster, taints *[]v1.Taint) bool {
+	if c.rules.Len() == 0 {
+		return false
+	}
+
+	logger := klog.FromContext(ctx)
+	cluster, err := ocmcelcommon.ConvertObjectToUnstructured(managedCluster)
+	if err != nil {
+		logger.Error(err, "Failed to convert the managed cluster", "managedClusterName", managedCluster.Name)
+		return false
+	}
+
+	addOns, err := c.addOnLister.ManagedClusterAddOns(managedCluster.Name).List(labels.Everything())
+	if err != nil {
+		logger.Error(err, "Failed to list the addons", "managedClusterName", managedCluster.Name)
+		return false
+	}
+	addOnObjects := make([]any, 0, len(addOns))
+	for _, addOn := range addOns {
+		obj, err := ocmcelcommon.ConvertObjectToUnstructured(addOn)
+		if err != nil {
+			logger.Error(err, "Failed to convert the addon", "managedClusterName", managedCluster.Name, "addonName", addOn.Name)
+			return false
+		}
+		addOnObjects = append(addOnObjects, obj.Object)
+	}
+
+	input := map[string]any{
+		"managedCluster": cluster.Object,
+		"addOns":         addOnObjects,
+	}
+
+	// the events are recorded in the managed cluster namespace
+	eventCluster := managedCluster.DeepCopy()
+	eventCluster.SetNamespace(managedCluster.Name)
+
+	var updated bool
+	budget := globalCostBudget
+	for _, rule := range c.rules.rules {
+		var matched bool
+		matched, budget, err = rule.evaluate(ctx, input, budget)
+		if err != nil {
+			logger.V(4).Info("Failed to evaluate the taint rule", "managedClusterName", managedCluster.Name, "rule", rule.Name, "err", err)
+			// the rule is evaluated on every sync, only report the failure once until it changes
+			if c.failures.report(managedCluster.Name, rule.Name, err.Error()) {
+				c.mcEventRecorder.Eventf(eventCluster, nil, corev1.EventTypeWarning, "TaintRuleEvaluationFailed", "TaintRuleEvaluationFailed",
+					"Failed to evaluate the taint rule %q: %v", rule.Name, err)
+			}
+			continue
+		}
+		c.failures.resolve(managedCluster.Name, rule.Name)
+		logger.V(4).Info("Taint rule is evaluated", "managedClusterName", managedCluster.Name, "rule", rule.Name, "matched", matched)
+
+		switch {
+		case matched && helpers.AddTaints(taints, rule.Taint):
+			updated = true
+			c.mcEventRecorder.Eventf(eventCluster, nil, corev1.EventTypeNormal, "TaintAdded", "TaintAdded",
+				"The taint %q is added by the taint rule %q", rule.Taint.Key, rule.Name)
+		case !matched && helpers.RemoveTaints(taints, rule.Taint):
+			updated = true
+			c.mcEventRecorder.Eventf(eventCluster, nil, corev1.EventTypeNormal, "TaintRemoved", "TaintRemoved",
+				"The taint %q is removed by the taint rule %q", rule.Taint.Key, rule.Name)
+		}
+	}
+	return updated
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clienttesting "k8s.io/client-go/testing"
 
@@ -16,6 +17,7 @@ import (
 	"open-cluster-management.io/sdk-go/pkg/patcher"
 
 	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
+	"open-cluster-management.io/ocm/pkg/registration/helpers"
 	testinghelpers "open-cluster-management.io/ocm/pkg/registration/helpers/testing"
 )
 
@@ -83,6 +85,175 @@ func TestSyncTaintCluster(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "health probe is failed without taint",
+			startingObjects: []runtime.Object{func() *v1.ManagedCluster {
+				cluster := testinghelpers.NewAvailableManagedCluster()
+				cluster.Status.Conditions = append(cluster.Status.Conditions,
+					testinghelpers.NewManagedClusterCondition(helpers.HealthProbeConditionTypePrefix+"nodes", "False",
+						"HealthProbeFailed", "", nil))
+				return cluster
+			}()},
+			validateActions: testingcommon.AssertNoActions,
+		},
+		{
+			name: "health probe is failed",
+			startingObjects: []runtime.Object{func() *v1.ManagedCluster {
+				cluster := testinghelpers.NewAvailableManagedCluster()
+				cluster.Status.Conditions = append(cluster.Status.Conditions,
+					testinghelpers.NewManagedClusterCondition(helpers.HealthProbeConditionTypePrefix+"nodes", "False",
+						helpers.HealthProbeReasonFailedTaint, "", nil))
+				return cluster
+			}()},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				patchData := actions[0].(clienttesting.PatchActionImpl).Patch
+				managedCluster := &v1.ManagedCluster{}
+				err := json.Unmarshal(patchData, managedCluster)
+				if err != nil {
+					t.Fatal(err)
+				}
+				taints := []v1.Taint{{Key: helpers.HealthProbeConditionTypePrefix + "nodes", Effect: v1.TaintEffectNoSelect}}
+				if !reflect.DeepEqual(managedCluster.Spec.Taints, taints) {
+					t.Errorf("expected taint %#v, but actualTaints: %#v", taints, managedCluster.Spec.Taints)
+				}
+			},
+		},
+		{
+			name: "health probe is recovered",
+			startingObjects: []runtime.Object{func() *v1.ManagedCluster {
+				cluster := testinghelpers.NewAvailableManagedCluster()
+				cluster.Spec.Taints = []v1.Taint{{Key: helpers.HealthProbeConditionTypePrefix + "nodes", Effect: v1.TaintEffectNoSelect}}
+				cluster.Status.Conditions = append(cluster.Status.Conditions,
+					testinghelpers.NewManagedClusterCondition(helpers.HealthProbeConditionTypePrefix+"nodes", "True", "", "", nil))
+				return cluster
+			}()},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				patchData := actions[0].(clienttesting.PatchActionImpl).Patch
+				managedCluster := &v1.ManagedCluster{}
+				err := json.Unmarshal(patchData, managedCluster)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(managedCluster.Spec.Taints) != 0 {
+					t.Errorf("expected no taints, but actualTaints: %#v", managedCluster.Spec.Taints)
+				}
+			},
+		},
+		{
+			name: "health probe is removed",
+			startingObjects: []runtime.Object{func() *v1.ManagedCluster {
+				cluster := testinghelpers.NewAvailableManagedCluster()
+				cluster.Spec.Taints = []v1.Taint{{Key: helpers.HealthProbeConditionTypePrefix + "nodes", Effect: v1.TaintEffectNoSelect}}
+				return cluster
+			}()},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+			},
+		},
+		{
+			name: "cluster is in maintenance",
+			startingObjects: []runtime.Object{func() *v1.ManagedCluster {
+				cluster := testinghelpers.NewUnknownManagedCluster()
+				cluster.Annotations = map[string]string{
+					helpers.MaintenanceUntilAnnotationKey: time.Now().Add(time.Hour).Format(time.RFC3339),
+				}
+				cluster.Spec.Taints = []v1.Taint{UnreachableTaint}
+				return cluster
+			}()},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				patchData := actions[0].(clienttesting.PatchActionImpl).Patch
+				managedCluster := &v1.ManagedCluster{}
+				err := json.Unmarshal(patchData, managedCluster)
+				if err != nil {
+					t.Fatal(err)
+				}
+				taints := []v1.Taint{MaintenanceTaint}
+				if !reflect.DeepEqual(managedCluster.Spec.Taints, taints) {
+					t.Errorf("expected taint %#v, but actualTaints: %#v", taints, managedCluster.Spec.Taints)
+				}
+			},
+		},
+		{
+			name: "unavailable cluster is in maintenance",
+			startingObjects: []runtime.Object{func() *v1.ManagedCluster {
+				cluster := testinghelpers.NewUnAvailableManagedCluster()
+				cluster.Annotations = map[string]string{
+					helpers.MaintenanceUntilAnnotationKey: time.Now().Add(time.Hour).Format(time.RFC3339),
+				}
+				cluster.Spec.Taints = []v1.Taint{UnavailableTaint}
+				return cluster
+			}()},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				patchData := actions[0].(clienttesting.PatchActionImpl).Patch
+				managedCluster := &v1.ManagedCluster{}
+				err := json.Unmarshal(patchData, managedCluster)
+				if err != nil {
+					t.Fatal(err)
+				}
+				taints := []v1.Taint{UnavailableTaint, MaintenanceTaint}
+				if !reflect.DeepEqual(managedCluster.Spec.Taints, taints) {
+					t.Errorf("expected taint %#v, but actualTaints: %#v", taints, managedCluster.Spec.Taints)
+				}
+			},
+		},
+		{
+			name: "maintenance exceeds the maximum window",
+			startingObjects: []runtime.Object{func() *v1.ManagedCluster {
+				cluster := testinghelpers.NewUnknownManagedCluster()
+				cluster.Annotations = map[string]string{
+					helpers.MaintenanceUntilAnnotationKey: time.Now().Add(time.Hour).Format(time.RFC3339),
+				}
+				cluster.Status.Conditions = append(cluster.Status.Conditions, metav1.Condition{
+					Type:               helpers.ManagedClusterConditionMaintenance,
+					Status:             metav1.ConditionTrue,
+					Reason:             "ManagedClusterInMaintenance",
+					LastTransitionTime: metav1.NewTime(time.Now().Add(-helpers.MaxMaintenanceWindow)),
+				})
+				cluster.Spec.Taints = []v1.Taint{MaintenanceTaint}
+				return cluster
+			}()},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				patchData := actions[0].(clienttesting.PatchActionImpl).Patch
+				managedCluster := &v1.ManagedCluster{}
+				err := json.Unmarshal(patchData, managedCluster)
+				if err != nil {
+					t.Fatal(err)
+				}
+				taints := []v1.Taint{UnreachableTaint}
+				if !reflect.DeepEqual(managedCluster.Spec.Taints, taints) {
+					t.Errorf("expected taint %#v, but actualTaints: %#v", taints, managedCluster.Spec.Taints)
+				}
+			},
+		},
+		{
+			name: "maintenance is ended",
+			startingObjects: []runtime.Object{func() *v1.ManagedCluster {
+				cluster := testinghelpers.NewUnknownManagedCluster()
+				cluster.Annotations = map[string]string{
+					helpers.MaintenanceUntilAnnotationKey: time.Now().Add(-time.Hour).Format(time.RFC3339),
+				}
+				cluster.Spec.Taints = []v1.Taint{MaintenanceTaint}
+				return cluster
+			}()},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				patchData := actions[0].(clienttesting.PatchActionImpl).Patch
+				managedCluster := &v1.ManagedCluster{}
+				err := json.Unmarshal(patchData, managedCluster)
+				if err != nil {
+					t.Fatal(err)
+				}
+				taints := []v1.Taint{UnreachableTaint}
+				if !reflect.DeepEqual(managedCluster.Spec.Taints, taints) {
+					t.Errorf("expected taint %#v, but actualTaints: %#v", taints, managedCluster.Spec.Taints)
+				}
+			},
+		},
 		{
 			name:            "sync a deleted spoke cluster",
 			startingObjects: []runtime.Object{},
@@ -104,10 +275,10 @@ func TestSyncTaintCluster(t *testing.T) {
 			}
 
 			ctrl := taintController{
-				patcher.NewPatcher[
+				patcher: patcher.NewPatcher[
 					*v1.ManagedCluster, v1.ManagedClusterSpec, v1.ManagedClusterStatus](
 					clusterClient.ClusterV1().ManagedClusters()),
-				clusterInformerFactory.Cluster().V1().ManagedClusters().Lister()}
+				clusterLister: clusterInformerFactory.Cluster().V1().ManagedClusters().Lister()}
 			syncErr := ctrl.sync(context.TODO(),
 				testingcommon.NewFakeSyncContext(t, testinghelpers.TestManagedClusterName),
 				testinghelpers.TestManagedClusterName,
@@ -120,3 +291,29 @@ func TestSyncTaintCluster(t *testing.T) {
 		})
 	}
 }
+
+func TestEvaluationFailures(t *testing.T) {
+	failures := evaluationFailures{}
+	if !failures.report("cluster1", "rule1", "error1") {
+		t.Errorf("expected the first failure to be reported")
+	}
+	if failures.report("cluster1", "rule1", "error1") {
+		t.Errorf("expected the same failure not to be reported again")
+	}
+	if !failures.report("cluster2", "rule1", "error1") {
+		t.Errorf("expected the failure on another cluster to be reported")
+	}
+	if !failures.report("cluster1", "rule1", "error2") {
+		t.Errorf("expected the changed failure to be reported")
+	}
+
+	failures.resolve("cluster1", "rule1")
+	if !failures.report("cluster1", "rule1", "error2") {
+		t.Errorf("expected the failure to be reported again after the rule is evaluated successfully")
+	}
+
+	failures.forget("cluster2")
+	if !failures.report("cluster2", "rule1", "error1") {
+		t.Errorf("expected the failure to be reported again after the cluster is forgotten")
+	}
+}
@@ -0,0 +1,164 @@
+package taint
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/cel-go/cel"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	celconfig "k8s.io/apiserver/pkg/apis/cel"
+
+	v1 "open-cluster-management.io/api/cluster/v1"
+	ocmcelcommon "open-cluster-management.io/sdk-go/pkg/cel/common"
+	ocmcellibrary "open-cluster-management.io/sdk-go/pkg/cel/library"
+
+	commonhelpers "open-cluster-management.io/ocm/pkg/common/helpers"
+	"open-cluster-management.io/ocm/pkg/registration/helpers"
+	"open-cluster-management.io/ocm/pkg/registration/hub/agentversion"
+)
+
+var globalCostBudget = int64(celconfig.RuntimeCELCostBudget)
+
+// RulesConfig is the configuration of the taint rules.
+type RulesConfig struct {
+	Rules []Rule `json:"rules,omitempty"`
+}
+
+// Rule declares a taint that is added to a managed cluster when the CEL expression is evaluated to true, and removed
+// from the managed cluster when the expression is evaluated to false.
+//
+// The expression can access the variables:
+//   - managedCluster, the ManagedCluster, including its labels, conditions and cluster claims.
+//   - addOns, the list of the ManagedClusterAddOns in the managed cluster namespace.
+type Rule struct {
+	Name       string   `json:"name"`
+	Expression string   `json:"expression"`
+	Taint      v1.Taint `json:"taint"`
+}
+
+// Rules is the compiled taint rules.
+type Rules struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	Rule
+	program cel.Program
+}
+
+// LoadRules reads the taint rules from a yaml file and compiles them.
+func LoadRules(file string) (*Rules, error) {
+	data, err := os.ReadFile(path.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+
+	config := &RulesConfig{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("unable to parse taint rules config %q: %w", file, err)
+	}
+	return NewRules(config)
+}
+
+// NewRules validates the taint rules and compiles their expressions.
+func NewRules(config *RulesConfig) (*Rules, error) {
+	if config == nil || len(config.Rules) == 0 {
+		return &Rules{}, nil
+	}
+
+	env, err := cel.NewEnv(slices.Concat(
+		[]cel.EnvOption{
+			cel.Variable("managedCluster", cel.MapType(cel.StringType, cel.DynType)),
+			cel.Variable("addOns", cel.ListType(cel.DynType)),
+			ocmcellibrary.ConditionsLib(),
+			ocmcellibrary.JsonLib(),
+		},
+		ocmcelcommon.BaseEnvOpts,
+	)...)
+	if err != nil {
+		return nil, err
+	}
+
+	names, taintKeys := sets.New[string](), sets.New[string]()
+	var rules []compiledRule
+	for _, rule := range config.Rules {
+		if len(rule.Name) == 0 || names.Has(rule.Name) {
+			return nil, fmt.Errorf("the name of taint rule %q is empty or duplicated", rule.Name)
+		}
+		names.Insert(rule.Name)
+
+		if errs := validation.IsQualifiedName(rule.Taint.Key); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid taint key %q of taint rule %q: %v", rule.Taint.Key, rule.Name, errs)
+		}
+		switch rule.Taint.Effect {
+		case v1.TaintEffectNoSelect, v1.TaintEffectPreferNoSelect, v1.TaintEffectNoSelectIfNew:
+		default:
+			return nil, fmt.Errorf("invalid taint effect %q of taint rule %q", rule.Taint.Effect, rule.Name)
+		}
+		switch rule.Taint.Key {
+		case v1.ManagedClusterTaintUnavailable, v1.ManagedClusterTaintUnreachable, MaintenanceTaint.Key,
+			agentversion.AgentVersionSkewedTaintKey:
+			return nil, fmt.Errorf("the taint %q of taint rule %q is reserved", rule.Taint.Key, rule.Name)
+		}
+		if strings.HasPrefix(rule.Taint.Key, helpers.HealthProbeConditionTypePrefix) {
+			return nil, fmt.Errorf("the taint %q of taint rule %q uses the prefix %q reserved for the health probes",
+				rule.Taint.Key, rule.Name, helpers.HealthProbeConditionTypePrefix)
+		}
+		// the taint of a rule is removed when its expression is false, the rules sharing a taint would remove the
+		// taint added by each other.
+		if taintKeys.Has(rule.Taint.Key) {
+			return nil, fmt.Errorf("the taint %q of taint rule %q is duplicated", rule.Taint.Key, rule.Name)
+		}
+		taintKeys.Insert(rule.Taint.Key)
+
+		ast, iss := env.Compile(rule.Expression)
+		if iss.Err() != nil {
+			return nil, fmt.Errorf("invalid expression of taint rule %q: %w", rule.Name, iss.Err())
+		}
+		if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+			return nil, fmt.Errorf("expected bool result of taint rule %q, got %v", rule.Name, ast.OutputType())
+		}
+		program, err := env.Program(ast,
+			cel.CostLimit(celconfig.PerCallLimit),
+			cel.CostTracking(&ocmcelcommon.BaseEnvCostEstimator{CostEstimator: &ocmcellibrary.CostEstimator{}}),
+			cel.InterruptCheckFrequency(celconfig.CheckFrequency),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression of taint rule %q: %w", rule.Name, err)
+		}
+
+		// the time is set by the webhook when the taint is added
+		rule.Taint.TimeAdded = metav1.Time{}
+		rules = append(rules, compiledRule{Rule: rule, program: program})
+	}
+	return &Rules{rules: rules}, nil
+}
+
+// Len returns the number of the rules.
+func (r *Rules) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.rules)
+}
+
+// evaluate returns whether the taint of the rule should be added to the managed cluster.
+func (r *compiledRule) evaluate(ctx context.Context, input map[string]any, budget int64) (bool, int64, error) {
+	result, remainingBudget := commonhelpers.EvaluateSingleExpression(ctx, r.program, budget, r.Expression, input)
+	if result == nil {
+		return false, remainingBudget, fmt.Errorf("failed to evaluate the expression of taint rule %q", r.Name)
+	}
+
+	matched, ok := result.Value().(bool)
+	if !ok {
+		return false, remainingBudget, fmt.Errorf("expected bool result of taint rule %q, got %T", r.Name, result.Value())
+	}
+	return matched, remainingBudget, nil
+}
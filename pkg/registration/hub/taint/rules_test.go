@@ -0,0 +1,211 @@
+package taint
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	addonv1alpha1 "open-cluster-management.io/api/addon/v1alpha1"
+	addonfake "open-cluster-management.io/api/client/addon/clientset/versioned/fake"
+	addoninformers "open-cluster-management.io/api/client/addon/informers/externalversions"
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterscheme "open-cluster-management.io/api/client/cluster/clientset/versioned/scheme"
+	clusterinformers "open-cluster-management.io/api/client/cluster/informers/externalversions"
+	v1 "open-cluster-management.io/api/cluster/v1"
+	"open-cluster-management.io/sdk-go/pkg/basecontroller/events"
+	"open-cluster-management.io/sdk-go/pkg/patcher"
+
+	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
+	"open-cluster-management.io/ocm/pkg/registration/helpers"
+	testinghelpers "open-cluster-management.io/ocm/pkg/registration/helpers/testing"
+)
+
+var deniedTaint = v1.Taint{Key: "example.com/denied", Effect: v1.TaintEffectNoSelect}
+
+func TestNewRules(t *testing.T) {
+	cases := []struct {
+		name        string
+		rules       []Rule
+		expectedErr bool
+	}{
+		{
+			name:  "valid rule",
+			rules: []Rule{{Name: "denied", Expression: "managedCluster.spec.hubAcceptsClient == false", Taint: deniedTaint}},
+		},
+		{
+			name: "duplicated rule",
+			rules: []Rule{
+				{Name: "denied", Expression: "true", Taint: deniedTaint},
+				{Name: "denied", Expression: "true", Taint: deniedTaint},
+			},
+			expectedErr: true,
+		},
+		{
+			name:        "invalid expression",
+			rules:       []Rule{{Name: "denied", Expression: "managedCluster.", Taint: deniedTaint}},
+			expectedErr: true,
+		},
+		{
+			name:        "non bool expression",
+			rules:       []Rule{{Name: "denied", Expression: "'test'", Taint: deniedTaint}},
+			expectedErr: true,
+		},
+		{
+			name:        "invalid effect",
+			rules:       []Rule{{Name: "denied", Expression: "true", Taint: v1.Taint{Key: "example.com/denied", Effect: "NoExecute"}}},
+			expectedErr: true,
+		},
+		{
+			name:        "reserved taint",
+			rules:       []Rule{{Name: "denied", Expression: "true", Taint: UnreachableTaint}},
+			expectedErr: true,
+		},
+		{
+			name: "duplicated taint",
+			rules: []Rule{
+				{Name: "denied", Expression: "true", Taint: deniedTaint},
+				{Name: "not-accepted", Expression: "true", Taint: deniedTaint},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "health probe taint",
+			rules: []Rule{{Name: "nodes", Expression: "true", Taint: v1.Taint{
+				Key: helpers.HealthProbeConditionTypePrefix + "nodes", Effect: v1.TaintEffectNoSelect}}},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := NewRules(&RulesConfig{Rules: c.rules})
+			if c.expectedErr != (err != nil) {
+				t.Errorf("expected error %v, but got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestSyncRuleTaints(t *testing.T) {
+	rules, err := NewRules(&RulesConfig{Rules: []Rule{
+		{
+			Name: "not-accepted",
+			Expression: "managedCluster.status.conditions.exists(c, c.type == 'HubAcceptedManagedCluster' && " +
+				"c.status == 'False')",
+			Taint: deniedTaint,
+		},
+		{
+			Name:       "addon-degraded",
+			Expression: "addOns.exists(a, a.metadata.name == 'test' && a.status.conditions.exists(c, c.type == 'Degraded' && c.status == 'True'))",
+			Taint:      v1.Taint{Key: "example.com/addon-degraded", Value: "test", Effect: v1.TaintEffectPreferNoSelect},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name           string
+		cluster        *v1.ManagedCluster
+		addOns         []runtime.Object
+		expectedTaints []v1.Taint
+		expectedPatch  bool
+	}{
+		{
+			name:    "no rule is matched",
+			cluster: testinghelpers.NewAvailableManagedCluster(),
+		},
+		{
+			name: "cluster is not accepted",
+			cluster: func() *v1.ManagedCluster {
+				cluster := testinghelpers.NewAvailableManagedCluster()
+				cluster.Status.Conditions = append(cluster.Status.Conditions,
+					testinghelpers.NewManagedClusterCondition(v1.ManagedClusterConditionHubAccepted, "False", "", "", nil))
+				return cluster
+			}(),
+			expectedTaints: []v1.Taint{deniedTaint},
+			expectedPatch:  true,
+		},
+		{
+			name:    "addon is degraded",
+			cluster: testinghelpers.NewAvailableManagedCluster(),
+			addOns: []runtime.Object{func() *addonv1alpha1.ManagedClusterAddOn {
+				addOn := testinghelpers.NewManagedClusterAddons("test", testinghelpers.TestManagedClusterName, nil, nil)
+				addOn.Status.Conditions = append(addOn.Status.Conditions,
+					testinghelpers.NewManagedClusterCondition("Degraded", "True", "", "", nil))
+				return addOn
+			}()},
+			expectedTaints: []v1.Taint{{Key: "example.com/addon-degraded", Value: "test", Effect: v1.TaintEffectPreferNoSelect}},
+			expectedPatch:  true,
+		},
+		{
+			name: "rule is not matched anymore",
+			cluster: func() *v1.ManagedCluster {
+				cluster := testinghelpers.NewAvailableManagedCluster()
+				cluster.Spec.Taints = []v1.Taint{deniedTaint}
+				return cluster
+			}(),
+			expectedPatch: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			clusterClient := clusterfake.NewSimpleClientset(c.cluster)
+			clusterInformerFactory := clusterinformers.NewSharedInformerFactory(clusterClient, time.Minute*10)
+			if err := clusterInformerFactory.Cluster().V1().ManagedClusters().Informer().GetStore().Add(c.cluster); err != nil {
+				t.Fatal(err)
+			}
+			addOnClient := addonfake.NewSimpleClientset(c.addOns...)
+			addOnInformerFactory := addoninformers.NewSharedInformerFactory(addOnClient, time.Minute*10)
+			for _, addOn := range c.addOns {
+				if err := addOnInformerFactory.Addon().V1alpha1().ManagedClusterAddOns().Informer().GetStore().Add(addOn); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			ctx := context.TODO()
+			mcEventRecorder, err := events.NewEventRecorder(ctx, clusterscheme.Scheme, kubefake.NewSimpleClientset().EventsV1(), "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctrl := taintController{
+				patcher: patcher.NewPatcher[
+					*v1.ManagedCluster, v1.ManagedClusterSpec, v1.ManagedClusterStatus](
+					clusterClient.ClusterV1().ManagedClusters()),
+				clusterLister:   clusterInformerFactory.Cluster().V1().ManagedClusters().Lister(),
+				addOnLister:     addOnInformerFactory.Addon().V1alpha1().ManagedClusterAddOns().Lister(),
+				rules:           rules,
+				mcEventRecorder: mcEventRecorder,
+			}
+			if err := ctrl.sync(ctx, testingcommon.NewFakeSyncContext(t, testinghelpers.TestManagedClusterName),
+				testinghelpers.TestManagedClusterName); err != nil {
+				t.Errorf("unexpected err: %v", err)
+			}
+
+			if !c.expectedPatch {
+				testingcommon.AssertNoActions(t, clusterClient.Actions())
+				return
+			}
+			testingcommon.AssertActions(t, clusterClient.Actions(), "patch")
+			patchData := clusterClient.Actions()[0].(clienttesting.PatchActionImpl).Patch
+			managedCluster := &v1.ManagedCluster{}
+			if err := json.Unmarshal(patchData, managedCluster); err != nil {
+				t.Fatal(err)
+			}
+			if len(c.expectedTaints) == 0 && len(managedCluster.Spec.Taints) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(managedCluster.Spec.Taints, c.expectedTaints) {
+				t.Errorf("expected taints %#v, but got %#v", c.expectedTaints, managedCluster.Spec.Taints)
+			}
+		})
+	}
+}
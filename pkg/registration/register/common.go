@@ -252,6 +252,8 @@ type Clients struct {
 	EventsClient    eventsv1.EventsV1Interface
 	ClusterInformer clusterv1informer.ManagedClusterInformer
 	AddonInformer   addonv1alpha1informers.ManagedClusterAddOnInformer
+	// KubeClient is the kube client of the hub, it is nil if the hub is not connected through the kube apiserver.
+	KubeClient kubernetes.Interface
 }
 
 // AddOnClients hold clients and informers used by addon driver
@@ -291,6 +293,7 @@ func BuildClientsFromConfig(kubeConfig *rest.Config, clusterName string) (*Clien
 	if err != nil {
 		return nil, err
 	}
+	clients.KubeClient = kubeClient
 	clients.LeaseClient = kubeClient.CoordinationV1().Leases(clusterName)
 	clients.EventsClient = kubeClient.EventsV1()
 	clients.ClusterClient, err = clusterv1client.NewForConfig(kubeConfig)
@@ -0,0 +1,129 @@
+package csr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	certificatesv1informers "k8s.io/client-go/informers/certificates/v1"
+	certificatesv1listers "k8s.io/client-go/listers/certificates/v1"
+	"k8s.io/klog/v2"
+
+	clusterv1client "open-cluster-management.io/api/client/cluster/clientset/versioned"
+	clusterv1informers "open-cluster-management.io/api/client/cluster/informers/externalversions/cluster/v1"
+	clusterv1listers "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
+	"open-cluster-management.io/sdk-go/pkg/patcher"
+
+	commonhelpers "open-cluster-management.io/ocm/pkg/common/helpers"
+	"open-cluster-management.io/ocm/pkg/common/queue"
+	"open-cluster-management.io/ocm/pkg/registration/hub/user"
+)
+
+// agentIDController records the agent ID in the latest approved client certificate csr of an accepted cluster on the
+// cluster, so the csrs of the cluster restored from a hub backup are only approved if they are sent by the same agent.
+type agentIDController struct {
+	signer        string
+	csrLister     certificatesv1listers.CertificateSigningRequestLister
+	clusterLister clusterv1listers.ManagedClusterLister
+	patcher       patcher.Patcher[*clusterv1.ManagedCluster, clusterv1.ManagedClusterSpec, clusterv1.ManagedClusterStatus]
+}
+
+func newAgentIDController(
+	clusterClient clusterv1client.Interface,
+	csrInformer certificatesv1informers.CertificateSigningRequestInformer,
+	clusterInformer clusterv1informers.ManagedClusterInformer,
+	signer string) factory.Controller {
+	c := &agentIDController{
+		signer:        signer,
+		csrLister:     csrInformer.Lister(),
+		clusterLister: clusterInformer.Lister(),
+		patcher: patcher.NewPatcher[
+			*clusterv1.ManagedCluster, clusterv1.ManagedClusterSpec, clusterv1.ManagedClusterStatus](
+			clusterClient.ClusterV1().ManagedClusters()),
+	}
+
+	return factory.New().
+		WithFilteredEventsInformersQueueKeysFunc(clusterNameQueueKeysFunc, eventFilter, csrInformer.Informer()).
+		WithInformersQueueKeysFunc(queue.QueueKeyByMetaName, clusterInformer.Informer()).
+		WithSync(c.sync).
+		ToController("AgentIDController")
+}
+
+func clusterNameQueueKeysFunc(obj runtime.Object) []string {
+	csr, ok := obj.(*certificatesv1.CertificateSigningRequest)
+	if !ok {
+		return nil
+	}
+	clusterName, ok := csr.Labels[clusterv1.ClusterNameLabelKey]
+	if !ok {
+		return nil
+	}
+	return []string{clusterName}
+}
+
+func (c *agentIDController) sync(ctx context.Context, _ factory.SyncContext, clusterName string) error {
+	logger := klog.FromContext(ctx).WithValues("clusterName", clusterName)
+
+	cluster, err := c.clusterLister.Get(clusterName)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !cluster.Spec.HubAcceptsClient {
+		return nil
+	}
+
+	csrs, err := c.csrLister.List(labels.SelectorFromSet(labels.Set{clusterv1.ClusterNameLabelKey: clusterName}))
+	if err != nil {
+		return err
+	}
+	var latest *certificatesv1.CertificateSigningRequest
+	agentID := ""
+	for _, csr := range csrs {
+		if !isCSRApproved(csr) {
+			continue
+		}
+		valid, csrClusterName, commonName := validateCSR(logger, c.signer, getCSRInfo(csr))
+		if !valid || csrClusterName != clusterName {
+			continue
+		}
+		if latest != nil && !latest.CreationTimestamp.Before(&csr.CreationTimestamp) {
+			continue
+		}
+		latest = csr
+		agentID = strings.TrimPrefix(commonName, fmt.Sprintf("%s%s:", user.SubjectPrefix, clusterName))
+	}
+	if latest == nil || len(agentID) == 0 || cluster.Annotations[commonhelpers.AgentIDAnnotationKey] == agentID {
+		return nil
+	}
+
+	newCluster := cluster.DeepCopy()
+	if newCluster.Annotations == nil {
+		newCluster.Annotations = map[string]string{}
+	}
+	newCluster.Annotations[commonhelpers.AgentIDAnnotationKey] = agentID
+	_, err = c.patcher.PatchLabelAnnotations(ctx, newCluster, newCluster.ObjectMeta, cluster.ObjectMeta)
+	return err
+}
+
+func isCSRApproved(csr *certificatesv1.CertificateSigningRequest) bool {
+	for _, condition := range csr.Status.Conditions {
+		if condition.Type == certificatesv1.CertificateDenied || condition.Type == certificatesv1.CertificateFailed {
+			return false
+		}
+	}
+	for _, condition := range csr.Status.Conditions {
+		if condition.Type == certificatesv1.CertificateApproved {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package csr
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterinformers "open-cluster-management.io/api/client/cluster/informers/externalversions"
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	commonhelpers "open-cluster-management.io/ocm/pkg/common/helpers"
+	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
+	testinghelpers "open-cluster-management.io/ocm/pkg/registration/helpers/testing"
+	"open-cluster-management.io/ocm/pkg/registration/hub/user"
+)
+
+func TestRecordAgentID(t *testing.T) {
+	newCluster := func(accepted bool, agentID string) *clusterv1.ManagedCluster {
+		cluster := &clusterv1.ManagedCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "managedcluster1"},
+			Spec:       clusterv1.ManagedClusterSpec{HubAcceptsClient: accepted},
+		}
+		if len(agentID) > 0 {
+			cluster.Annotations = map[string]string{commonhelpers.AgentIDAnnotationKey: agentID}
+		}
+		return cluster
+	}
+	newApprovedCSR := func(name, agentID string, created time.Time) *certificatesv1.CertificateSigningRequest {
+		holder := validCSR
+		holder.Name = name
+		holder.CN = user.SubjectPrefix + "managedcluster1:" + agentID
+		csr := testinghelpers.NewApprovedCSR(holder)
+		csr.CreationTimestamp = metav1.NewTime(created)
+		return csr
+	}
+	assertAgentID := func(agentID string) func(t *testing.T, actions []clienttesting.Action) {
+		return func(t *testing.T, actions []clienttesting.Action) {
+			testingcommon.AssertActions(t, actions, "patch")
+			patch := &clusterv1.ManagedCluster{}
+			if err := json.Unmarshal(actions[0].(clienttesting.PatchActionImpl).Patch, patch); err != nil {
+				t.Fatal(err)
+			}
+			if patch.Annotations[commonhelpers.AgentIDAnnotationKey] != agentID {
+				t.Errorf("expected agent ID %q is recorded, but got %v", agentID, patch.Annotations)
+			}
+		}
+	}
+
+	now := time.Now()
+	cases := []struct {
+		name            string
+		cluster         *clusterv1.ManagedCluster
+		csrs            []runtime.Object
+		validateActions func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name:            "cluster is not accepted",
+			cluster:         newCluster(false, ""),
+			csrs:            []runtime.Object{newApprovedCSR("csr1", "spokeagent1", now)},
+			validateActions: testingcommon.AssertNoActions,
+		},
+		{
+			name:            "csr is not approved",
+			cluster:         newCluster(true, ""),
+			csrs:            []runtime.Object{testinghelpers.NewCSR(validCSR)},
+			validateActions: testingcommon.AssertNoActions,
+		},
+		{
+			name:            "record the agent ID",
+			cluster:         newCluster(true, ""),
+			csrs:            []runtime.Object{newApprovedCSR("csr1", "spokeagent1", now)},
+			validateActions: assertAgentID("spokeagent1"),
+		},
+		{
+			name:    "record the agent ID of the latest csr",
+			cluster: newCluster(true, "spokeagent1"),
+			csrs: []runtime.Object{
+				newApprovedCSR("csr1", "spokeagent1", now.Add(-time.Minute)),
+				newApprovedCSR("csr2", "spokeagent2", now),
+			},
+			validateActions: assertAgentID("spokeagent2"),
+		},
+		{
+			name:            "agent ID is recorded",
+			cluster:         newCluster(true, "spokeagent1"),
+			csrs:            []runtime.Object{newApprovedCSR("csr1", "spokeagent1", now)},
+			validateActions: testingcommon.AssertNoActions,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kubeClient := kubefake.NewSimpleClientset(c.csrs...)
+			informerFactory := informers.NewSharedInformerFactory(kubeClient, 3*time.Minute)
+			for _, csr := range c.csrs {
+				if err := informerFactory.Certificates().V1().CertificateSigningRequests().Informer().GetStore().Add(csr); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			clusterClient := clusterfake.NewSimpleClientset(c.cluster)
+			clusterInformerFactory := clusterinformers.NewSharedInformerFactory(clusterClient, 10*time.Minute)
+			if err := clusterInformerFactory.Cluster().V1().ManagedClusters().Informer().GetStore().Add(c.cluster); err != nil {
+				t.Fatal(err)
+			}
+
+			ctrl := newAgentIDController(clusterClient, informerFactory.Certificates().V1().CertificateSigningRequests(),
+				clusterInformerFactory.Cluster().V1().ManagedClusters(), certificatesv1.KubeAPIServerClientSignerName)
+			syncCtx := testingcommon.NewFakeSyncContext(t, c.cluster.Name)
+			if err := ctrl.Sync(context.TODO(), syncCtx, c.cluster.Name); err != nil {
+				t.Errorf("unexpected err: %v", err)
+			}
+			c.validateActions(t, clusterClient.Actions())
+		})
+	}
+}
@@ -6,18 +6,23 @@ import (
 	"encoding/pem"
 	"fmt"
 	"strings"
+	"time"
 
 	authorizationv1 "k8s.io/api/authorization/v1"
 	certificatesv1 "k8s.io/api/certificates/v1"
 	certificatesv1beta1 "k8s.io/api/certificates/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
 
+	clusterv1listers "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
 
+	commonhelpers "open-cluster-management.io/ocm/pkg/common/helpers"
 	"open-cluster-management.io/ocm/pkg/registration/hub/user"
 )
 
@@ -28,6 +33,10 @@ const (
 	reconcileContinue
 )
 
+// restoredClusterCSRApprovalPeriod is the period after a cluster is restored from a hub backup in which the csrs of
+// the cluster are auto approved. The agents that do not rejoin the hub in the period have to be accepted again.
+const restoredClusterCSRApprovalPeriod = 24 * time.Hour
+
 type CSRInfo struct {
 	Name       string
 	Labels     map[string]string
@@ -127,6 +136,69 @@ func (b *csrBootstrapReconciler) Reconcile(ctx context.Context, syncCtx factory.
 	return reconcileStop, nil
 }
 
+type csrRestoreReconciler struct {
+	signer        string
+	kubeClient    kubernetes.Interface
+	clusterLister clusterv1listers.ManagedClusterLister
+}
+
+// NewCSRRestoreReconciler approves the csrs of the accepted clusters restored from a hub backup, so the agents whose
+// client certificates are signed by the backed up hub rejoin the hub without being accepted again. A csr is only
+// approved if its agent ID is the one recorded on the restored cluster by the backed up hub, so a bootstrap user
+// cannot take over the identity of the cluster. The csrs are not approved once the cluster joins the hub, or after
+// the restoredClusterCSRApprovalPeriod since the cluster is restored.
+func NewCSRRestoreReconciler(kubeClient kubernetes.Interface,
+	signer string,
+	clusterLister clusterv1listers.ManagedClusterLister) Reconciler {
+	return &csrRestoreReconciler{
+		signer:        signer,
+		kubeClient:    kubeClient,
+		clusterLister: clusterLister,
+	}
+}
+
+func (r *csrRestoreReconciler) Reconcile(ctx context.Context, syncCtx factory.SyncContext, csr CSRInfo, approveCSR approveCSRFunc) (reconcileState, error) {
+	logger := klog.FromContext(ctx)
+	valid, clusterName, commonName := validateCSR(logger, r.signer, csr)
+	if !valid {
+		logger.V(4).Info("CSR was not recognized", "csrName", csr.Name)
+		return reconcileStop, nil
+	}
+
+	cluster, err := r.clusterLister.Get(clusterName)
+	if errors.IsNotFound(err) {
+		return reconcileContinue, nil
+	}
+	if err != nil {
+		return reconcileContinue, err
+	}
+
+	// Check whether the cluster is restored and accepted recently, and its agent has not rejoined the hub yet. The
+	// restored cluster is created by the restore, so its creation time is the time it is restored.
+	if _, restored := cluster.Annotations[commonhelpers.RestoredFromHubAnnotationKey]; !restored ||
+		!cluster.Spec.HubAcceptsClient ||
+		meta.IsStatusConditionTrue(cluster.Status.Conditions, clusterv1.ManagedClusterConditionJoined) ||
+		time.Since(cluster.CreationTimestamp.Time) > restoredClusterCSRApprovalPeriod {
+		return reconcileContinue, nil
+	}
+
+	// Check whether the csr is sent by the agent recorded on the cluster by the backed up hub.
+	agentID := cluster.Annotations[commonhelpers.AgentIDAnnotationKey]
+	if len(agentID) == 0 || commonName != fmt.Sprintf("%s%s:%s", user.SubjectPrefix, clusterName, agentID) {
+		logger.V(4).Info("CSR of the restored cluster is not sent by the recorded agent",
+			"csrName", csr.Name, "commonName", commonName)
+		return reconcileContinue, nil
+	}
+
+	if err := approveCSR(r.kubeClient); err != nil {
+		return reconcileContinue, err
+	}
+
+	syncCtx.Recorder().Eventf(ctx, "RestoredManagedClusterCSRApproved",
+		"csr %q of the restored managed cluster %q is auto approved", csr.Name, clusterName)
+	return reconcileStop, nil
+}
+
 // To validate a managed cluster csr, we check
 // 1. if the signer name in csr request is valid.
 // 2. if organization field and commonName field in csr request is valid.
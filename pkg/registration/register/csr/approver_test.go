@@ -24,6 +24,7 @@ import (
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	ocmfeature "open-cluster-management.io/api/feature"
 
+	commonhelpers "open-cluster-management.io/ocm/pkg/common/helpers"
 	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
 	"open-cluster-management.io/ocm/pkg/features"
 	testinghelpers "open-cluster-management.io/ocm/pkg/registration/helpers/testing"
@@ -170,6 +171,122 @@ func TestSync(t *testing.T) {
 				testinghelpers.AssertCSRCondition(t, actual.(*certificatesv1.CertificateSigningRequest).Status.Conditions, expectedCondition)
 			},
 		},
+		{
+			name: "auto approve a csr request of the restored cluster",
+			startingClusters: []runtime.Object{
+				&clusterv1.ManagedCluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "managedcluster1",
+						Annotations: map[string]string{
+							commonhelpers.RestoredFromHubAnnotationKey: "hubhash",
+							commonhelpers.AgentIDAnnotationKey:         "spokeagent1",
+						},
+						CreationTimestamp: metav1.Now(),
+					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+				},
+			},
+			startingCSRs: []runtime.Object{func() *certificatesv1.CertificateSigningRequest {
+				csr := testinghelpers.NewCSR(validCSR)
+				csr.Spec.Username = "test"
+				return csr
+			}()},
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "update")
+				actual := actions[0].(clienttesting.UpdateActionImpl).Object
+				testinghelpers.AssertCSRCondition(t, actual.(*certificatesv1.CertificateSigningRequest).Status.Conditions,
+					certificatesv1.CertificateSigningRequestCondition{
+						Type:    certificatesv1.CertificateApproved,
+						Status:  corev1.ConditionTrue,
+						Reason:  "AutoApprovedByHubCSRApprovingController",
+						Message: "Auto approving Managed cluster agent certificate after SubjectAccessReview.",
+					})
+			},
+		},
+		{
+			name: "do not approve the csr request of the restored cluster sent by another agent",
+			startingClusters: []runtime.Object{
+				&clusterv1.ManagedCluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "managedcluster1",
+						Annotations: map[string]string{
+							commonhelpers.RestoredFromHubAnnotationKey: "hubhash",
+							commonhelpers.AgentIDAnnotationKey:         "spokeagent2",
+						},
+						CreationTimestamp: metav1.Now(),
+					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+				},
+			},
+			startingCSRs: []runtime.Object{func() *certificatesv1.CertificateSigningRequest {
+				csr := testinghelpers.NewCSR(validCSR)
+				csr.Spec.Username = "test"
+				return csr
+			}()},
+			validateActions: testingcommon.AssertNoActions,
+		},
+		{
+			name: "do not approve the csr request of the restored cluster without the recorded agent",
+			startingClusters: []runtime.Object{
+				&clusterv1.ManagedCluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:              "managedcluster1",
+						Annotations:       map[string]string{commonhelpers.RestoredFromHubAnnotationKey: "hubhash"},
+						CreationTimestamp: metav1.Now(),
+					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+				},
+			},
+			startingCSRs: []runtime.Object{func() *certificatesv1.CertificateSigningRequest {
+				csr := testinghelpers.NewCSR(validCSR)
+				csr.Spec.Username = "test"
+				return csr
+			}()},
+			validateActions: testingcommon.AssertNoActions,
+		},
+		{
+			name: "do not approve the csr request once the approval period of the restored cluster expires",
+			startingClusters: []runtime.Object{
+				&clusterv1.ManagedCluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:              "managedcluster1",
+						Annotations:       map[string]string{commonhelpers.RestoredFromHubAnnotationKey: "hubhash"},
+						CreationTimestamp: metav1.NewTime(time.Now().Add(-restoredClusterCSRApprovalPeriod - time.Minute)),
+					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+				},
+			},
+			startingCSRs: []runtime.Object{func() *certificatesv1.CertificateSigningRequest {
+				csr := testinghelpers.NewCSR(validCSR)
+				csr.Spec.Username = "test"
+				return csr
+			}()},
+			validateActions: testingcommon.AssertNoActions,
+		},
+		{
+			name: "do not approve the csr request once the restored cluster joined",
+			startingClusters: []runtime.Object{
+				&clusterv1.ManagedCluster{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:              "managedcluster1",
+						Annotations:       map[string]string{commonhelpers.RestoredFromHubAnnotationKey: "hubhash"},
+						CreationTimestamp: metav1.Now(),
+					},
+					Spec: clusterv1.ManagedClusterSpec{HubAcceptsClient: true},
+					Status: clusterv1.ManagedClusterStatus{
+						Conditions: []metav1.Condition{
+							{Type: clusterv1.ManagedClusterConditionJoined, Status: metav1.ConditionTrue},
+						},
+					},
+				},
+			},
+			startingCSRs: []runtime.Object{func() *certificatesv1.CertificateSigningRequest {
+				csr := testinghelpers.NewCSR(validCSR)
+				csr.Spec.Username = "test"
+				return csr
+			}()},
+			validateActions: testingcommon.AssertNoActions,
+		},
 	}
 
 	for _, c := range cases {
@@ -214,6 +331,8 @@ func TestSync(t *testing.T) {
 						approvalUsers: sets.Set[string]{},
 					},
 					NewCSRRenewalReconciler(kubeClient, certificatesv1.KubeAPIServerClientSignerName),
+					NewCSRRestoreReconciler(kubeClient, certificatesv1.KubeAPIServerClientSignerName,
+						clusterInformerFactory.Cluster().V1().ManagedClusters().Lister()),
 					NewCSRBootstrapReconciler(
 						kubeClient,
 						certificatesv1.KubeAPIServerClientSignerName,
@@ -334,14 +453,17 @@ func TestIsSpokeClusterClientCertRenewal(t *testing.T) {
 func TestNewApprover(t *testing.T) {
 	kubeClient := kubefake.NewClientset()
 	informerFactory := informers.NewSharedInformerFactory(kubeClient, 3*time.Minute)
-	utilruntime.Must(features.HubMutableFeatureGate.Add(ocmfeature.DefaultHubRegistrationFeatureGates))
-	_, err := NewCSRHubDriver(kubeClient, informerFactory, []string{})
+	clusterClient := clusterfake.NewSimpleClientset()
+	utilruntime.Must(features.HubMutableFeatureGate.Add(features.DefaultHubRegistrationFeatureGates))
+	_, err := NewCSRHubDriver(kubeClient, informerFactory, clusterClient,
+		clusterinformers.NewSharedInformerFactory(clusterClient, 3*time.Minute).Cluster().V1().ManagedClusters(), []string{})
 	if err != nil {
 		t.Error(err)
 	}
 
 	features.HubMutableFeatureGate.Set(fmt.Sprintf("%s=true", ocmfeature.ManagedClusterAutoApproval))
-	_, err = NewCSRHubDriver(kubeClient, informerFactory, []string{})
+	_, err = NewCSRHubDriver(kubeClient, informerFactory, clusterClient,
+		clusterinformers.NewSharedInformerFactory(clusterClient, 3*time.Minute).Cluster().V1().ManagedClusters(), []string{})
 	if err != nil {
 		t.Error(err)
 	}
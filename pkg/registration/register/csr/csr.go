@@ -31,6 +31,7 @@ import (
 	"open-cluster-management.io/sdk-go/pkg/basecontroller/events"
 	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
 
+	"open-cluster-management.io/ocm/pkg/registration/helpers"
 	"open-cluster-management.io/ocm/pkg/registration/hub/user"
 	"open-cluster-management.io/ocm/pkg/registration/register"
 	"open-cluster-management.io/ocm/pkg/registration/register/token"
@@ -80,6 +81,9 @@ type CSRDriver struct {
 	opt register.CSRConfiguration
 
 	csrOption *CSROption
+
+	// revokeIssuedBefore returns the time before which the certificates are revoked by the hub
+	revokeIssuedBefore register.RevokeCredentialsIssuedBeforeFunc
 }
 
 func (c *CSRDriver) Process(
@@ -183,7 +187,8 @@ func (c *CSRDriver) Process(
 		secret,
 		recorder,
 		c.csrOption.Subject,
-		additionalSecretData)
+		additionalSecretData,
+		c.revokeIssuedBefore)
 	if err != nil {
 		return secret, nil, err
 	}
@@ -309,6 +314,9 @@ func (c *CSRDriver) Fork(addonName string, authConfig register.AddonAuthConfig,
 		return nil, err
 	}
 	if tokenDriver != nil {
+		if d, ok := tokenDriver.(register.CredentialRotationDriver); ok && c.revokeIssuedBefore != nil {
+			d.SetRevokeCredentialsIssuedBeforeFunc(c.revokeIssuedBefore)
+		}
 		return tokenDriver, nil
 	}
 
@@ -323,7 +331,9 @@ func (c *CSRDriver) Fork(addonName string, authConfig register.AddonAuthConfig,
 		return nil, fmt.Errorf("CSR configuration is nil for addon %s", addonName)
 	}
 
-	return NewCSRDriverForAddOn(addonName, csrConfig, secretOption, c.csrControl), nil
+	driver := NewCSRDriverForAddOn(addonName, csrConfig, secretOption, c.csrControl)
+	driver.revokeIssuedBefore = c.revokeIssuedBefore
+	return driver, nil
 }
 
 func (c *CSRDriver) BuildClients(ctx context.Context, secretOption register.SecretOption, bootstrap bool) (*register.Clients, error) {
@@ -395,7 +405,13 @@ func (c *CSRDriver) SetTokenControl(tokenControl token.TokenControl) {
 	c.tokenControl = tokenControl
 }
 
+// SetRevokeCredentialsIssuedBeforeFunc sets the func of the time before which the certificates are revoked by the hub
+func (c *CSRDriver) SetRevokeCredentialsIssuedBeforeFunc(f register.RevokeCredentialsIssuedBeforeFunc) {
+	c.revokeIssuedBefore = f
+}
+
 var _ register.RegisterDriver = &CSRDriver{}
+var _ register.CredentialRotationDriver = &CSRDriver{}
 var _ register.AddonDriverFactory = &CSRDriver{}
 
 // NewCSRDriverForAddOn creates a CSRDriver for addon registration with the given parameters
@@ -485,7 +501,8 @@ func shouldCreateCSR(
 	secret *corev1.Secret,
 	recorder events.Recorder,
 	subject *pkix.Name,
-	additionalSecretData map[string][]byte) (bool, error) {
+	additionalSecretData map[string][]byte,
+	revokeIssuedBefore register.RevokeCredentialsIssuedBeforeFunc) (bool, error) {
 	// create a csr to request new client certificate if
 	// a.there is no valid client certificate issued for the current cluster/agent
 	valid, err := IsCertificateValid(logger, secret.Data[TLSCertFile], subject)
@@ -509,6 +526,17 @@ func shouldCreateCSR(
 	if err != nil {
 		return false, err
 	}
+
+	// d.client certificate is issued before the time the hub revokes the certificates, and the time is passed
+	if revokeIssuedBefore != nil {
+		if issuedBefore, ok := revokeIssuedBefore(); ok && !time.Now().Before(issuedBefore) &&
+			helpers.CertificateIssuedAt(*notBefore).Before(issuedBefore) {
+			recorder.Eventf(ctx, "CertificateRotationRequested",
+				"The current client certificate for %s is revoked by the hub since %v. Start certificate rotation",
+				controllerName, issuedBefore)
+			return true, nil
+		}
+	}
 	total := notAfter.Sub(*notBefore)
 	remaining := time.Until(*notAfter)
 	logger.V(4).Info("Client certificate for:", "name", controllerName, "time total", total,
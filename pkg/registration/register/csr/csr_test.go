@@ -2,8 +2,14 @@ package csr
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"math/big"
 	"os"
 	"path"
 	"reflect"
@@ -62,6 +68,7 @@ func TestProcess(t *testing.T) {
 		csrNameExpected   bool
 		expectedCondition *metav1.Condition
 		validateActions   func(t *testing.T, hubActions []clienttesting.Action, secret *corev1.Secret)
+		revokeBefore      register.RevokeCredentialsIssuedBeforeFunc
 	}{
 		{
 			name:     "syc csr after bootstrap",
@@ -129,6 +136,42 @@ func TestProcess(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:     "sync a hub kubeconfig secret revoked by the hub",
+			queueKey: testSecretName,
+			secret: testinghelpers.NewHubKubeconfigSecret(
+				testNamespace, testSecretName, "1",
+				newBackdatedTestCert(t, commonName, time.Hour, 10000*time.Second),
+				map[string][]byte{
+					register.ClusterNameFile: []byte(testinghelpers.TestManagedClusterName),
+					register.AgentNameFile:   []byte(testAgentName),
+				}),
+			revokeBefore: func() (time.Time, bool) {
+				return time.Now().Add(-time.Minute), true
+			},
+			keyDataExpected: true,
+			csrNameExpected: true,
+			validateActions: func(t *testing.T, hubActions []clienttesting.Action, secret *corev1.Secret) {
+				testingcommon.AssertActions(t, hubActions, "create")
+			},
+		},
+		{
+			name:     "sync a hub kubeconfig secret before the revocation time",
+			queueKey: testSecretName,
+			secret: testinghelpers.NewHubKubeconfigSecret(
+				testNamespace, testSecretName, "1",
+				newBackdatedTestCert(t, commonName, time.Hour, 10000*time.Second),
+				map[string][]byte{
+					register.ClusterNameFile: []byte(testinghelpers.TestManagedClusterName),
+					register.AgentNameFile:   []byte(testAgentName),
+				}),
+			revokeBefore: func() (time.Time, bool) {
+				return time.Now().Add(time.Hour), true
+			},
+			validateActions: func(t *testing.T, hubActions []clienttesting.Action, secret *corev1.Secret) {
+				testingcommon.AssertNoActions(t, hubActions)
+			},
+		},
 		{
 			name:     "sync when additional secret data changes",
 			queueKey: testSecretName,
@@ -191,6 +234,7 @@ func TestProcess(t *testing.T) {
 				csrOption:       csrOption,
 				opt:             NewCSROption(),
 			}
+			driver.SetRevokeCredentialsIssuedBeforeFunc(c.revokeBefore)
 
 			if c.approvedCSRCert != nil {
 				driver.csrName = testCSRName
@@ -224,6 +268,34 @@ func TestProcess(t *testing.T) {
 	}
 }
 
+// newBackdatedTestCert returns a self-signed client certificate issued the given age ago.
+func newBackdatedTestCert(t *testing.T, commonName string, age, duration time.Duration) *testinghelpers.TestCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-age),
+		NotAfter:     time.Now().Add(duration),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(cryptorand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyData, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testinghelpers.TestCert{
+		Cert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		Key:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyData}),
+	}
+}
+
 var _ CSRControl = &mockCSRControl{}
 
 func conditionEqual(expected, actual *metav1.Condition) bool {
@@ -14,6 +14,8 @@ import (
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 
+	clusterv1client "open-cluster-management.io/api/client/cluster/clientset/versioned"
+	clusterv1informers "open-cluster-management.io/api/client/cluster/informers/externalversions/cluster/v1"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	ocmfeature "open-cluster-management.io/api/feature"
 	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
@@ -159,10 +161,14 @@ func (c *csrV1beta1Approver) approve(ctx context.Context, csr *certificatesv1bet
 }
 
 type CSRHubDriver struct {
-	controller factory.Controller
+	controller        factory.Controller
+	agentIDController factory.Controller
 }
 
 func (c *CSRHubDriver) Run(ctx context.Context, workers int) {
+	if c.agentIDController != nil {
+		go c.agentIDController.Run(ctx, 1)
+	}
 	c.controller.Run(ctx, workers)
 }
 
@@ -175,10 +181,18 @@ func (c *CSRHubDriver) Cleanup(_ context.Context, _ *clusterv1.ManagedCluster) e
 func NewCSRHubDriver(
 	kubeClient kubernetes.Interface,
 	kubeInformers informers.SharedInformerFactory,
+	clusterClient clusterv1client.Interface,
+	clusterInformer clusterv1informers.ManagedClusterInformer,
 	autoApprovedCSRUsers []string) (register.HubDriver, error) {
 	csrDriverForHub := &CSRHubDriver{}
 
-	csrReconciles := []Reconciler{NewCSRRenewalReconciler(kubeClient, certificatesv1.KubeAPIServerClientSignerName)}
+	csrReconciles := []Reconciler{
+		NewCSRRenewalReconciler(kubeClient, certificatesv1.KubeAPIServerClientSignerName),
+	}
+	if features.HubMutableFeatureGate.Enabled(features.RestoredClusterCSRApproval) {
+		csrReconciles = append(csrReconciles, NewCSRRestoreReconciler(
+			kubeClient, certificatesv1.KubeAPIServerClientSignerName, clusterInformer.Lister()))
+	}
 	if features.HubMutableFeatureGate.Enabled(ocmfeature.ManagedClusterAutoApproval) {
 		csrReconciles = append(csrReconciles, NewCSRBootstrapReconciler(
 			kubeClient,
@@ -206,6 +220,11 @@ func NewCSRHubDriver(
 		}
 	}
 
+	// the agent IDs are only recorded with the v1 csr api, so the csrs of the clusters restored onto a hub serving
+	// the v1beta1 csr api only are not approved.
+	csrDriverForHub.agentIDController = newAgentIDController(clusterClient,
+		kubeInformers.Certificates().V1().CertificateSigningRequests(), clusterInformer,
+		certificatesv1.KubeAPIServerClientSignerName)
 	csrDriverForHub.controller = NewCSRApprovingController(
 		kubeInformers.Certificates().V1().CertificateSigningRequests().Informer(),
 		kubeInformers.Certificates().V1().CertificateSigningRequests().Lister(),
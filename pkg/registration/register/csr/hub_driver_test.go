@@ -9,8 +9,9 @@ import (
 	"k8s.io/client-go/informers"
 	kubefake "k8s.io/client-go/kubernetes/fake"
 
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterinformers "open-cluster-management.io/api/client/cluster/informers/externalversions"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
-	ocmfeature "open-cluster-management.io/api/feature"
 
 	"open-cluster-management.io/ocm/pkg/features"
 )
@@ -34,8 +35,10 @@ func TestAccept(t *testing.T) {
 
 	kubeClient := kubefake.NewClientset()
 	informerFactory := informers.NewSharedInformerFactory(kubeClient, 3*time.Minute)
-	utilruntime.Must(features.HubMutableFeatureGate.Add(ocmfeature.DefaultHubRegistrationFeatureGates))
-	csrHubDriver, err := NewCSRHubDriver(kubeClient, informerFactory, []string{})
+	clusterClient := clusterfake.NewSimpleClientset()
+	utilruntime.Must(features.HubMutableFeatureGate.Add(features.DefaultHubRegistrationFeatureGates))
+	csrHubDriver, err := NewCSRHubDriver(kubeClient, informerFactory, clusterClient,
+		clusterinformers.NewSharedInformerFactory(clusterClient, 3*time.Minute).Cluster().V1().ManagedClusters(), []string{})
 
 	if err != nil {
 		t.Error(err)
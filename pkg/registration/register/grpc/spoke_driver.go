@@ -54,6 +54,7 @@ type GRPCDriver struct {
 
 var _ register.RegisterDriver = &GRPCDriver{}
 var _ register.AddonDriverFactory = &GRPCDriver{}
+var _ register.CredentialRotationDriver = &GRPCDriver{}
 
 func NewGRPCDriver(opt *Option, csrOption *csr.Option, secretOption register.SecretOption) (register.RegisterDriver, error) {
 	secretOption.Signer = operatorv1.GRPCAuthSigner
@@ -196,6 +197,11 @@ func (d *GRPCDriver) Fork(addonName string, authConfig register.AddonAuthConfig,
 	return d.csrDriver.Fork(addonName, authConfig, secretOption)
 }
 
+// SetRevokeCredentialsIssuedBeforeFunc sets the func of the time before which the certificates are revoked by the hub
+func (d *GRPCDriver) SetRevokeCredentialsIssuedBeforeFunc(f register.RevokeCredentialsIssuedBeforeFunc) {
+	d.csrDriver.SetRevokeCredentialsIssuedBeforeFunc(f)
+}
+
 func (d *GRPCDriver) Process(
 	ctx context.Context, controllerName string, secret *corev1.Secret, additionalSecretData map[string][]byte,
 	recorder events.Recorder) (*corev1.Secret, *metav1.Condition, error) {
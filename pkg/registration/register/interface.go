@@ -3,6 +3,7 @@ package register
 import (
 	"context"
 	"crypto/x509/pkix"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -93,6 +94,18 @@ type RegisterDriver interface {
 	BuildClients(ctx context.Context, secretOption SecretOption, bootstrap bool) (*Clients, error)
 }
 
+// RevokeCredentialsIssuedBeforeFunc returns the time before which the credentials issued to the cluster are revoked
+// by the hub, it returns false if no revocation is requested.
+type RevokeCredentialsIssuedBeforeFunc func() (time.Time, bool)
+
+// CredentialRotationDriver is implemented by the drivers renewing the credentials forced to rotate by the hub.
+type CredentialRotationDriver interface {
+	// SetRevokeCredentialsIssuedBeforeFunc sets the func of the time requested by the hub. The driver renews the
+	// credentials issued before the time once the time is passed, so the hub revokes them after they are renewed.
+	// The drivers forked for the addons inherit the func.
+	SetRevokeCredentialsIssuedBeforeFunc(f RevokeCredentialsIssuedBeforeFunc)
+}
+
 // AddonAuthConfig provides complete configuration for addon registration,
 // including authentication method and access to driver options.
 type AddonAuthConfig interface {
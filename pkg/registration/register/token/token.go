@@ -99,9 +99,13 @@ type TokenDriver struct {
 	// addonPatcher for updating addon status
 	addonPatcher patcher.Patcher[
 		*addonv1alpha1.ManagedClusterAddOn, addonv1alpha1.ManagedClusterAddOnSpec, addonv1alpha1.ManagedClusterAddOnStatus]
+
+	// revokeIssuedBefore returns the time before which the tokens are revoked by the hub
+	revokeIssuedBefore register.RevokeCredentialsIssuedBeforeFunc
 }
 
 var _ register.RegisterDriver = &TokenDriver{}
+var _ register.CredentialRotationDriver = &TokenDriver{}
 
 // NewTokenDriverForAddOn creates a new token driver instance for an addon.
 // This should only be called from a cluster driver's Fork() method.
@@ -343,10 +347,27 @@ func (t *TokenDriver) shouldRefreshToken(ctx context.Context, secret *corev1.Sec
 		return true, nil
 	}
 
+	// Refresh the token issued before the time the hub revokes the tokens once the time is passed
+	if t.revokeIssuedBefore != nil {
+		if issuedBefore, ok := t.revokeIssuedBefore(); ok && !time.Now().Before(issuedBefore) {
+			issueTime, _, _, err := parseToken(tokenData)
+			if err == nil && issueTime.Before(issuedBefore) {
+				logger.Info("Token refresh needed: token is revoked by the hub", "addon", t.addonName,
+					"issuedBefore", issuedBefore)
+				return true, nil
+			}
+		}
+	}
+
 	logger.V(4).Info("Token is valid, no refresh needed", "addon", t.addonName)
 	return false, nil
 }
 
+// SetRevokeCredentialsIssuedBeforeFunc sets the func of the time before which the tokens are revoked by the hub
+func (t *TokenDriver) SetRevokeCredentialsIssuedBeforeFunc(f register.RevokeCredentialsIssuedBeforeFunc) {
+	t.revokeIssuedBefore = f
+}
+
 // readTokenFile reads the token file from the specified directory
 func (t *TokenDriver) readTokenFile(hubKubeconfigDir string) ([]byte, error) {
 	tokenPath := path.Join(hubKubeconfigDir, TokenFile)
@@ -113,6 +113,7 @@ func TestShouldRefreshToken(t *testing.T) {
 		tokenAge      time.Duration
 		tokenExpiry   time.Duration
 		shouldRefresh bool
+		revokeBefore  register.RevokeCredentialsIssuedBeforeFunc
 	}{
 		{
 			name:          "fresh token - no refresh",
@@ -120,6 +121,24 @@ func TestShouldRefreshToken(t *testing.T) {
 			tokenExpiry:   1 * time.Hour,
 			shouldRefresh: false,
 		},
+		{
+			name:          "token revoked by the hub - refresh needed",
+			tokenAge:      10 * time.Minute,
+			tokenExpiry:   1 * time.Hour,
+			shouldRefresh: true,
+			revokeBefore: func() (time.Time, bool) {
+				return time.Now().Add(-time.Minute), true
+			},
+		},
+		{
+			name:          "token revocation time not passed - no refresh",
+			tokenAge:      10 * time.Minute,
+			tokenExpiry:   1 * time.Hour,
+			shouldRefresh: false,
+			revokeBefore: func() (time.Time, bool) {
+				return time.Now().Add(time.Minute), true
+			},
+		},
 		{
 			name:          "token near expiry - refresh needed",
 			tokenAge:      50 * time.Minute,
@@ -134,6 +153,7 @@ func TestShouldRefreshToken(t *testing.T) {
 			addonClients := newTestAddonClients()
 
 			driver := NewTokenDriverForAddOn("test-addon", "test-cluster", opt, nil, addonClients)
+			driver.SetRevokeCredentialsIssuedBeforeFunc(tt.revokeBefore)
 
 			now := time.Now()
 			iat := now.Add(-tt.tokenAge).Unix()
@@ -129,6 +129,22 @@ func (c *addOnRegistrationController) syncAddOn(ctx context.Context, syncCtx fac
 	// do not clean up during addon is deleting.
 	// in some case the addon agent may need hub-kubeconfig to do cleanup during deleting.
 	if !addOn.DeletionTimestamp.IsZero() {
+		// record the registration configs if they are not cached, e.g. the agent restarts when the addon is
+		// deleting, so the secrets of the registrations, including the client certificates signed by the custom
+		// signers, are removed once the addon is deleted.
+		if _, ok := c.addOnRegistrationConfigs[addOnName]; ok {
+			return nil
+		}
+		configs, err := getRegistrationConfigs(addOnName, addonInstallOption{
+			AgentRunningOutsideManagedCluster: isAddonRunningOutsideManagedCluster(addOn),
+			InstallationNamespace:             getAddOnInstallationNamespace(addOn),
+		}, addOn.Status.Registrations, addOn.Status.KubeClientDriver)
+		if err != nil {
+			return err
+		}
+		if len(configs) > 0 {
+			c.addOnRegistrationConfigs[addOnName] = configs
+		}
 		return nil
 	}
 
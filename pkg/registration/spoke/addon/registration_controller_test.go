@@ -148,6 +148,25 @@ func TestRegistrationSync(t *testing.T) {
 				testingcommon.AssertActions(t, actions, "delete")
 			},
 		},
+		{
+			name:     "deleting addon registration not cached",
+			queueKey: addOnName,
+			addOn: func() *addonv1alpha1.ManagedClusterAddOn {
+				addOn := newManagedClusterAddOn(clusterName, addOnName,
+					[]addonv1alpha1.RegistrationConfig{config1}, false)
+				now := metav1.Now()
+				addOn.DeletionTimestamp = &now
+				return addOn
+			}(),
+			expectedAddOnRegistrationConfigHashs: map[string][]string{
+				addOnName: {hash(config1, "", false)},
+			},
+			validateActions: func(t *testing.T, actions, managementActions []clienttesting.Action) {
+				if len(actions) != 0 {
+					t.Errorf("expect 0 actions but got %d", len(actions))
+				}
+			},
+		},
 		{
 			name:     "hosted addon registration enabled",
 			queueKey: addOnName,
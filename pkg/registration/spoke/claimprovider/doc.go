@@ -0,0 +1,2 @@
+// package claimprovider contains the built-in cluster claim providers of the registration agent.
+package claimprovider
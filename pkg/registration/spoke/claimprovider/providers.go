@@ -0,0 +1,349 @@
+package claimprovider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	clusterv1alpha1 "open-cluster-management.io/api/cluster/v1alpha1"
+
+	"open-cluster-management.io/ocm/pkg/common/helpers"
+)
+
+const defaultExecTimeout = 30 * time.Second
+
+// cloudPlatforms maps the scheme of the node providerID to the platform name.
+var cloudPlatforms = map[string]string{
+	"aws":       "AWS",
+	"gce":       "GCP",
+	"azure":     "Azure",
+	"vsphere":   "VSphere",
+	"openstack": "OpenStack",
+	"ibm":       "IBM",
+	"alicloud":  "AlibabaCloud",
+}
+
+// Clients holds the clients of the managed cluster that are used by the claim providers.
+type Clients struct {
+	DiscoveryClient discovery.DiscoveryInterface
+	NodeLister      corev1lister.NodeLister
+}
+
+// Providers caches the claims of the claim providers, which are refreshed in the background once the refresh
+// interval of a provider is passed.
+type Providers struct {
+	providers             []ProviderConfig
+	clients               Clients
+	reservedClaimSuffixes []string
+
+	lock   sync.RWMutex
+	claims map[string][]clusterv1.ManagedClusterClaim
+}
+
+// NewProviders returns the claim providers of the config. The claims with the reserved names or the reserved
+// suffixes are not accepted from the providers.
+func NewProviders(config *Config, clients Clients, reservedClaimSuffixes []string) *Providers {
+	p := &Providers{
+		clients:               clients,
+		reservedClaimSuffixes: append([]string{helpers.AgentVersionClaimSuffix}, reservedClaimSuffixes...),
+		claims:                map[string][]clusterv1.ManagedClusterClaim{},
+	}
+	if config != nil {
+		p.providers = config.Providers
+	}
+	return p
+}
+
+// Run refreshes the claims of each provider every refresh interval of the provider until the context is done. The
+// providers run in the background, so that a slow provider does not delay the status sync of the managed cluster,
+// which reads the latest claims by Claims.
+func (p *Providers) Run(ctx context.Context) {
+	if p == nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, provider := range p.providers {
+		wg.Add(1)
+		go func(provider ProviderConfig) {
+			defer wg.Done()
+			wait.UntilWithContext(ctx, func(ctx context.Context) {
+				p.refresh(ctx, provider)
+			}, provider.refreshInterval())
+		}(provider)
+	}
+	wg.Wait()
+}
+
+// Refresh refreshes the claims of all the providers once.
+func (p *Providers) Refresh(ctx context.Context) {
+	if p == nil {
+		return
+	}
+
+	for _, provider := range p.providers {
+		p.refresh(ctx, provider)
+	}
+}
+
+// refresh runs the provider and caches its claims, the last claims are kept if the provider fails.
+func (p *Providers) refresh(ctx context.Context, provider ProviderConfig) {
+	logger := klog.FromContext(ctx).WithValues("provider", provider.Name)
+	providerClaims, err := p.provide(ctx, provider)
+	if err != nil {
+		logger.Error(err, "Failed to refresh the claims of claim provider")
+		return
+	}
+
+	var claims []clusterv1.ManagedClusterClaim
+	for _, claim := range providerClaims {
+		if err := p.validateClaimName(claim.Name); err != nil {
+			logger.Error(err, "Claim of claim provider is ignored", "claim", claim.Name)
+			continue
+		}
+		claims = append(claims, claim)
+	}
+	logger.V(4).Info("Claims are refreshed", "claims", len(claims))
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.claims[provider.Name] = claims
+}
+
+// validateClaimName verifies the name of a claim from a provider, so a provider cannot override the reserved claims.
+func (p *Providers) validateClaimName(name string) error {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("invalid claim name %q: %v", name, errs)
+	}
+	if isReservedClaimName(name) {
+		return fmt.Errorf("claim name %q is reserved", name)
+	}
+	for _, suffix := range p.reservedClaimSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return fmt.Errorf("claim name %q has the reserved suffix %q", name, suffix)
+		}
+	}
+	return nil
+}
+
+func isReservedClaimName(name string) bool {
+	for _, reserved := range clusterv1alpha1.ReservedClusterClaimNames {
+		if name == reserved {
+			return true
+		}
+	}
+	return false
+}
+
+// Claims returns the latest claims of all the providers.
+func (p *Providers) Claims() []clusterv1.ManagedClusterClaim {
+	if p == nil {
+		return nil
+	}
+
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	var claims []clusterv1.ManagedClusterClaim
+	for _, provider := range p.providers {
+		claims = append(claims, p.claims[provider.Name]...)
+	}
+	return claims
+}
+
+func (p *Providers) provide(ctx context.Context, provider ProviderConfig) ([]clusterv1.ManagedClusterClaim, error) {
+	switch provider.Type {
+	case NodeLabelsProviderType:
+		return p.nodeLabelClaims(provider.NodeLabels)
+	case CloudMetadataProviderType:
+		return p.cloudMetadataClaims(provider.CloudMetadata)
+	case APIPresenceProviderType:
+		return p.apiPresenceClaims(provider.APIPresence)
+	case ExecProviderType:
+		return execClaims(ctx, provider.Exec)
+	case FileProviderType:
+		data, err := os.ReadFile(path.Clean(provider.File.Path))
+		if err != nil {
+			return nil, err
+		}
+		return parseClaims(data)
+	}
+	return nil, fmt.Errorf("unsupported claim provider type %q", provider.Type)
+}
+
+func (p *Providers) nodeLabelClaims(config *NodeLabelsProvider) ([]clusterv1.ManagedClusterClaim, error) {
+	nodes, err := p.clients.NodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	var claims []clusterv1.ManagedClusterClaim
+	for _, labelClaim := range config.Labels {
+		values := sets.New[string]()
+		for _, node := range nodes {
+			if value, ok := node.Labels[labelClaim.Label]; ok {
+				values.Insert(value)
+			}
+		}
+		if values.Len() == 0 {
+			continue
+		}
+
+		value := strings.Join(sets.List(values), ",")
+		if labelClaim.Aggregation == AggregationCount {
+			value = strconv.Itoa(values.Len())
+		}
+		claims = append(claims, clusterv1.ManagedClusterClaim{Name: labelClaim.ClaimName, Value: value})
+	}
+
+	for _, resourceClaim := range config.Resources {
+		total := resource.Quantity{}
+		for _, node := range nodes {
+			if quantity, ok := node.Status.Capacity[corev1.ResourceName(resourceClaim.Resource)]; ok {
+				total.Add(quantity)
+			}
+		}
+		claims = append(claims, clusterv1.ManagedClusterClaim{Name: resourceClaim.ClaimName, Value: total.String()})
+	}
+	return claims, nil
+}
+
+func (p *Providers) cloudMetadataClaims(config *CloudMetadataProvider) ([]clusterv1.ManagedClusterClaim, error) {
+	nodes, err := p.clients.NodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	platformClaimName, accountClaimName := config.ProviderClaimName, config.AccountClaimName
+
+	for _, node := range nodes {
+		platform, account := parseProviderID(node.Spec.ProviderID)
+		if len(platform) == 0 {
+			continue
+		}
+
+		claims := []clusterv1.ManagedClusterClaim{{Name: platformClaimName, Value: platform}}
+		if len(accountClaimName) > 0 && len(account) > 0 {
+			claims = append(claims, clusterv1.ManagedClusterClaim{Name: accountClaimName, Value: account})
+		}
+		return claims, nil
+	}
+	return nil, nil
+}
+
+// parseProviderID returns the platform and the account of a node providerID, e.g.
+//   - aws:///us-east-1a/i-0123456789abcdef0
+//   - gce://my-project/us-central1-a/my-node
+//   - azure:///subscriptions/<subscription>/resourceGroups/<group>/providers/Microsoft.Compute/virtualMachines/<vm>
+func parseProviderID(providerID string) (platform, account string) {
+	scheme, rest, found := strings.Cut(providerID, "://")
+	if !found {
+		return "", ""
+	}
+
+	platform, ok := cloudPlatforms[scheme]
+	if !ok {
+		return "", ""
+	}
+
+	segments := strings.Split(rest, "/")
+	switch scheme {
+	case "gce":
+		account = segments[0]
+	case "azure":
+		for i := 0; i < len(segments)-1; i++ {
+			if strings.EqualFold(segments[i], "subscriptions") {
+				account = segments[i+1]
+				break
+			}
+		}
+	}
+	return platform, account
+}
+
+func (p *Providers) apiPresenceClaims(config *APIPresenceProvider) ([]clusterv1.ManagedClusterClaim, error) {
+	var claims []clusterv1.ManagedClusterClaim
+	for _, api := range config.APIs {
+		present := false
+		resources, err := p.clients.DiscoveryClient.ServerResourcesForGroupVersion(api.GroupVersion)
+		switch {
+		case errors.IsNotFound(err):
+		case err != nil:
+			return nil, err
+		default:
+			for _, r := range resources.APIResources {
+				if r.Name == api.Resource {
+					present = true
+					break
+				}
+			}
+		}
+		claims = append(claims, clusterv1.ManagedClusterClaim{Name: api.ClaimName, Value: strconv.FormatBool(present)})
+	}
+	return claims, nil
+}
+
+func execClaims(ctx context.Context, config *ExecProvider) ([]clusterv1.ManagedClusterClaim, error) {
+	timeout := defaultExecTimeout
+	if config.TimeoutSeconds > 0 {
+		timeout = time.Duration(config.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// the command is declared by the agent administrator in the claim provider config
+	cmd := exec.CommandContext(ctx, config.Command[0], config.Command[1:]...) // #nosec G204
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", err, stderr.String())
+	}
+	return parseClaims(output)
+}
+
+// parseClaims reads the claims from the lines with the format of name=value, the empty lines and the lines starting
+// with # are ignored.
+func parseClaims(data []byte) ([]clusterv1.ManagedClusterClaim, error) {
+	var claims []clusterv1.ManagedClusterClaim
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, found := strings.Cut(line, "=")
+		if !found || len(strings.TrimSpace(name)) == 0 {
+			return nil, fmt.Errorf("invalid claim %q, the format should be name=value", line)
+		}
+		claims = append(claims, clusterv1.ManagedClusterClaim{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(claims, func(i, j int) bool {
+		return claims[i].Name < claims[j].Name
+	})
+	return claims, nil
+}
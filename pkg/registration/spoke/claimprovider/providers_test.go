@@ -0,0 +1,271 @@
+package claimprovider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	kubeinformers "k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+)
+
+func newNode(name, providerID string, labels map[string]string, gpus int64) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec:       corev1.NodeSpec{ProviderID: providerID},
+		Status: corev1.NodeStatus{
+			Capacity: corev1.ResourceList{"nvidia.com/gpu": *resource.NewQuantity(gpus, resource.DecimalSI)},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name        string
+		providers   []ProviderConfig
+		expectedErr bool
+	}{
+		{
+			name: "valid providers",
+			providers: []ProviderConfig{
+				{Name: "nodes", Type: NodeLabelsProviderType, NodeLabels: &NodeLabelsProvider{
+					Labels: []NodeLabelClaim{{ClaimName: "zones.example.com", Label: "topology.kubernetes.io/zone"}}}},
+				{Name: "cloud", Type: CloudMetadataProviderType, CloudMetadata: &CloudMetadataProvider{
+					ProviderClaimName: "cloud.example.com"}},
+				{Name: "file", Type: FileProviderType, File: &FileProvider{Path: "/etc/claims"}},
+			},
+		},
+		{
+			name: "duplicated name",
+			providers: []ProviderConfig{
+				{Name: "file", Type: FileProviderType, File: &FileProvider{Path: "/etc/claims"}},
+				{Name: "file", Type: FileProviderType, File: &FileProvider{Path: "/etc/claims"}},
+			},
+			expectedErr: true,
+		},
+		{
+			name:        "no provider claim name",
+			providers:   []ProviderConfig{{Name: "cloud", Type: CloudMetadataProviderType}},
+			expectedErr: true,
+		},
+		{
+			name: "reserved claim name",
+			providers: []ProviderConfig{{Name: "cloud", Type: CloudMetadataProviderType, CloudMetadata: &CloudMetadataProvider{
+				ProviderClaimName: "platform.open-cluster-management.io"}}},
+			expectedErr: true,
+		},
+		{
+			name: "invalid claim name",
+			providers: []ProviderConfig{{Name: "nodes", Type: NodeLabelsProviderType, NodeLabels: &NodeLabelsProvider{
+				Labels: []NodeLabelClaim{{ClaimName: "Zones", Label: "topology.kubernetes.io/zone"}}}}},
+			expectedErr: true,
+		},
+		{
+			name: "invalid refresh interval",
+			providers: []ProviderConfig{{Name: "file", Type: FileProviderType, File: &FileProvider{Path: "/etc/claims"},
+				RefreshInterval: &metav1.Duration{Duration: -time.Second}}},
+			expectedErr: true,
+		},
+		{
+			name:        "no command",
+			providers:   []ProviderConfig{{Name: "exec", Type: ExecProviderType, Exec: &ExecProvider{}}},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := &Config{Providers: c.providers}
+			err := config.Validate()
+			if c.expectedErr != (err != nil) {
+				t.Errorf("expected error %v, but got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestClaims(t *testing.T) {
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/apis/cert-manager.io/v1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		output, err := json.Marshal(metav1.APIResourceList{
+			GroupVersion: "cert-manager.io/v1",
+			APIResources: []metav1.APIResource{{Name: "certificates", Kind: "Certificate"}},
+		})
+		if err != nil {
+			t.Errorf("unexpected encoding error: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(output); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer apiServer.Close()
+	discoveryClient := discovery.NewDiscoveryClientForConfigOrDie(&rest.Config{Host: apiServer.URL})
+
+	claimsFile := filepath.Join(t.TempDir(), "claims")
+	claims := "# comment\nowner.example.com=team-a\nid.k8s.io=fake\nhub.agentversion.open-cluster-management.io=v1\n" +
+		"region.reserved.example.com=us\n"
+	if err := os.WriteFile(claimsFile, []byte(claims), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name           string
+		provider       ProviderConfig
+		nodes          []*corev1.Node
+		expectedClaims []clusterv1.ManagedClusterClaim
+	}{
+		{
+			name: "node labels",
+			provider: ProviderConfig{Name: "nodes", Type: NodeLabelsProviderType, NodeLabels: &NodeLabelsProvider{
+				Labels: []NodeLabelClaim{
+					{ClaimName: "zones.example.com", Label: "topology.kubernetes.io/zone"},
+					{ClaimName: "zonecount.example.com", Label: "topology.kubernetes.io/zone", Aggregation: AggregationCount},
+				},
+				Resources: []NodeResourceClaim{{ClaimName: "gpus.example.com", Resource: "nvidia.com/gpu"}},
+			}},
+			nodes: []*corev1.Node{
+				newNode("node1", "", map[string]string{"topology.kubernetes.io/zone": "zone-b"}, 2),
+				newNode("node2", "", map[string]string{"topology.kubernetes.io/zone": "zone-a"}, 1),
+				newNode("node3", "", map[string]string{"topology.kubernetes.io/zone": "zone-a"}, 0),
+			},
+			expectedClaims: []clusterv1.ManagedClusterClaim{
+				{Name: "zones.example.com", Value: "zone-a,zone-b"},
+				{Name: "zonecount.example.com", Value: "2"},
+				{Name: "gpus.example.com", Value: "3"},
+			},
+		},
+		{
+			name: "cloud metadata",
+			provider: ProviderConfig{Name: "cloud", Type: CloudMetadataProviderType, CloudMetadata: &CloudMetadataProvider{
+				ProviderClaimName: "cloud.example.com",
+				AccountClaimName:  "account.example.com",
+			}},
+			nodes: []*corev1.Node{newNode("node1", "gce://my-project/us-central1-a/node1", nil, 0)},
+			expectedClaims: []clusterv1.ManagedClusterClaim{
+				{Name: "cloud.example.com", Value: "GCP"},
+				{Name: "account.example.com", Value: "my-project"},
+			},
+		},
+		{
+			name: "api presence",
+			provider: ProviderConfig{Name: "apis", Type: APIPresenceProviderType, APIPresence: &APIPresenceProvider{
+				APIs: []APIClaim{
+					{ClaimName: "certmanager.example.com", GroupVersion: "cert-manager.io/v1", Resource: "certificates"},
+					{ClaimName: "istio.example.com", GroupVersion: "networking.istio.io/v1", Resource: "gateways"},
+				},
+			}},
+			expectedClaims: []clusterv1.ManagedClusterClaim{
+				{Name: "certmanager.example.com", Value: "true"},
+				{Name: "istio.example.com", Value: "false"},
+			},
+		},
+		{
+			name:     "file without the reserved claims",
+			provider: ProviderConfig{Name: "file", Type: FileProviderType, File: &FileProvider{Path: claimsFile}},
+			expectedClaims: []clusterv1.ManagedClusterClaim{
+				{Name: "owner.example.com", Value: "team-a"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubefake.NewSimpleClientset(), 0)
+			for _, node := range c.nodes {
+				if err := kubeInformerFactory.Core().V1().Nodes().Informer().GetStore().Add(node); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			providers := NewProviders(&Config{Providers: []ProviderConfig{c.provider}}, Clients{
+				DiscoveryClient: discoveryClient,
+				NodeLister:      kubeInformerFactory.Core().V1().Nodes().Lister(),
+			}, []string{".reserved.example.com"})
+			providers.Refresh(context.TODO())
+			claims := providers.Claims()
+			if !reflect.DeepEqual(claims, c.expectedClaims) {
+				t.Errorf("expected claims %v, but got %v", c.expectedClaims, claims)
+			}
+		})
+	}
+}
+
+func TestClaimsRefresh(t *testing.T) {
+	claimsFile := filepath.Join(t.TempDir(), "claims")
+	if err := os.WriteFile(claimsFile, []byte("a=b"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	providers := NewProviders(&Config{Providers: []ProviderConfig{{
+		Name: "file", Type: FileProviderType, File: &FileProvider{Path: claimsFile},
+	}}}, Clients{}, nil)
+
+	assertClaims := func(expected string) {
+		claims := providers.Claims()
+		if len(claims) != 1 || claims[0].Value != expected {
+			t.Errorf("expected claim value %q, but got %v", expected, claims)
+		}
+	}
+
+	if claims := providers.Claims(); len(claims) != 0 {
+		t.Errorf("expected no claims before the refresh, but got %v", claims)
+	}
+	providers.Refresh(context.TODO())
+	assertClaims("b")
+	if err := os.WriteFile(claimsFile, []byte("a=c"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// the cached claims are returned before the next refresh
+	assertClaims("b")
+	providers.Refresh(context.TODO())
+	assertClaims("c")
+
+	// the last claims are kept if the provider fails
+	if err := os.Remove(claimsFile); err != nil {
+		t.Fatal(err)
+	}
+	providers.Refresh(context.TODO())
+	assertClaims("c")
+}
+
+func TestParseProviderID(t *testing.T) {
+	cases := []struct {
+		providerID       string
+		expectedPlatform string
+		expectedAccount  string
+	}{
+		{providerID: "aws:///us-east-1a/i-0123456789abcdef0", expectedPlatform: "AWS"},
+		{providerID: "gce://my-project/us-central1-a/node1", expectedPlatform: "GCP", expectedAccount: "my-project"},
+		{
+			providerID:       "azure:///subscriptions/sub-id/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm",
+			expectedPlatform: "Azure", expectedAccount: "sub-id",
+		},
+		{providerID: "kind://docker/kind/kind-control-plane"},
+		{providerID: ""},
+	}
+
+	for _, c := range cases {
+		platform, account := parseProviderID(c.providerID)
+		if platform != c.expectedPlatform || account != c.expectedAccount {
+			t.Errorf("expected %q %q, but got %q %q for %q", c.expectedPlatform, c.expectedAccount, platform, account, c.providerID)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package claimprovider
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"open-cluster-management.io/ocm/pkg/common/helpers"
+)
+
+const defaultRefreshInterval = 10 * time.Minute
+
+// ProviderType is the type of a claim provider.
+type ProviderType string
+
+const (
+	// NodeLabelsProviderType aggregates the labels and resources of the nodes into claims.
+	NodeLabelsProviderType ProviderType = "NodeLabels"
+	// CloudMetadataProviderType reads the cloud provider and account from the providerID of the nodes.
+	CloudMetadataProviderType ProviderType = "CloudMetadata"
+	// APIPresenceProviderType reports whether the APIs, including the ones of the CRDs, are served.
+	APIPresenceProviderType ProviderType = "APIPresence"
+	// ExecProviderType runs a command and reads the claims from its output.
+	ExecProviderType ProviderType = "Exec"
+	// FileProviderType reads the claims from a file.
+	FileProviderType ProviderType = "File"
+)
+
+// AggregationType is how the values of a node label are aggregated.
+type AggregationType string
+
+const (
+	// AggregationValues joins the distinct values with comma.
+	AggregationValues AggregationType = "Values"
+	// AggregationCount counts the distinct values.
+	AggregationCount AggregationType = "Count"
+)
+
+// Config is the claim provider configuration of the registration agent.
+type Config struct {
+	Providers []ProviderConfig `json:"providers,omitempty"`
+}
+
+// ProviderConfig describes one claim provider.
+type ProviderConfig struct {
+	// Name is the name of the provider, it must be a DNS-1123 label.
+	Name string `json:"name"`
+	// Type is the type of the provider.
+	Type ProviderType `json:"type"`
+	// RefreshInterval is the interval to refresh the claims of the provider, the default is 10 minutes.
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+
+	NodeLabels    *NodeLabelsProvider    `json:"nodeLabels,omitempty"`
+	CloudMetadata *CloudMetadataProvider `json:"cloudMetadata,omitempty"`
+	APIPresence   *APIPresenceProvider   `json:"apiPresence,omitempty"`
+	Exec          *ExecProvider          `json:"exec,omitempty"`
+	File          *FileProvider          `json:"file,omitempty"`
+}
+
+// NodeLabelsProvider aggregates node labels and node resources into claims.
+type NodeLabelsProvider struct {
+	Labels    []NodeLabelClaim    `json:"labels,omitempty"`
+	Resources []NodeResourceClaim `json:"resources,omitempty"`
+}
+
+// NodeLabelClaim exposes the aggregated values of a node label, e.g. the zones or the instance types.
+type NodeLabelClaim struct {
+	ClaimName   string          `json:"claimName"`
+	Label       string          `json:"label"`
+	Aggregation AggregationType `json:"aggregation,omitempty"`
+}
+
+// NodeResourceClaim exposes the sum of a resource capacity of the nodes, e.g. the GPU count.
+type NodeResourceClaim struct {
+	ClaimName string `json:"claimName"`
+	Resource  string `json:"resource"`
+}
+
+// CloudMetadataProvider exposes the cloud provider name and the account parsed from the providerID of the nodes.
+type CloudMetadataProvider struct {
+	// ProviderClaimName is the claim name of the cloud provider name, e.g. AWS, GCP or Azure. It cannot be the
+	// reserved platform.open-cluster-management.io claim.
+	ProviderClaimName string `json:"providerClaimName"`
+	// AccountClaimName is the claim name of the account, e.g. the gce project or the azure subscription.
+	AccountClaimName string `json:"accountClaimName,omitempty"`
+}
+
+// APIPresenceProvider exposes "true" or "false" claims for the presence of APIs.
+type APIPresenceProvider struct {
+	APIs []APIClaim `json:"apis"`
+}
+
+// APIClaim is the claim of an API, the API is present if the resource is served in the group version.
+type APIClaim struct {
+	ClaimName    string `json:"claimName"`
+	GroupVersion string `json:"groupVersion"`
+	Resource     string `json:"resource"`
+}
+
+// ExecProvider runs a command, each line in its output with the format of name=value is a claim.
+type ExecProvider struct {
+	Command        []string `json:"command"`
+	TimeoutSeconds int32    `json:"timeoutSeconds,omitempty"`
+}
+
+// FileProvider reads a file, each line in the file with the format of name=value is a claim.
+type FileProvider struct {
+	Path string `json:"path"`
+}
+
+// LoadConfig reads the claim provider configuration from a yaml file.
+func LoadConfig(file string) (*Config, error) {
+	data, err := os.ReadFile(path.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("unable to parse claim provider config %q: %w", file, err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate verifies the providers in the config.
+func (c *Config) Validate() error {
+	names := sets.New[string]()
+	for _, provider := range c.Providers {
+		if errs := validation.IsDNS1123Label(provider.Name); len(errs) > 0 {
+			return fmt.Errorf("invalid claim provider name %q: %v", provider.Name, errs)
+		}
+		if names.Has(provider.Name) {
+			return fmt.Errorf("duplicated claim provider name %q", provider.Name)
+		}
+		names.Insert(provider.Name)
+
+		if provider.RefreshInterval != nil && provider.RefreshInterval.Duration <= 0 {
+			return fmt.Errorf("the refresh interval of claim provider %q must be greater than zero", provider.Name)
+		}
+
+		if err := provider.validate(); err != nil {
+			return fmt.Errorf("invalid claim provider %q: %w", provider.Name, err)
+		}
+	}
+	return nil
+}
+
+func (p *ProviderConfig) validate() error {
+	var claimNames []string
+	switch p.Type {
+	case NodeLabelsProviderType:
+		if p.NodeLabels == nil {
+			return fmt.Errorf("nodeLabels must be specified")
+		}
+		for _, l := range p.NodeLabels.Labels {
+			if len(l.Label) == 0 {
+				return fmt.Errorf("label must be specified")
+			}
+			switch l.Aggregation {
+			case "", AggregationValues, AggregationCount:
+			default:
+				return fmt.Errorf("unsupported aggregation %q", l.Aggregation)
+			}
+			claimNames = append(claimNames, l.ClaimName)
+		}
+		for _, r := range p.NodeLabels.Resources {
+			if len(r.Resource) == 0 {
+				return fmt.Errorf("resource must be specified")
+			}
+			claimNames = append(claimNames, r.ClaimName)
+		}
+	case CloudMetadataProviderType:
+		if p.CloudMetadata == nil || len(p.CloudMetadata.ProviderClaimName) == 0 {
+			return fmt.Errorf("providerClaimName must be specified")
+		}
+		claimNames = append(claimNames, p.CloudMetadata.ProviderClaimName)
+		if len(p.CloudMetadata.AccountClaimName) > 0 {
+			claimNames = append(claimNames, p.CloudMetadata.AccountClaimName)
+		}
+	case APIPresenceProviderType:
+		if p.APIPresence == nil || len(p.APIPresence.APIs) == 0 {
+			return fmt.Errorf("apis must be specified")
+		}
+		for _, api := range p.APIPresence.APIs {
+			if len(api.GroupVersion) == 0 || len(api.Resource) == 0 {
+				return fmt.Errorf("groupVersion and resource must be specified")
+			}
+			claimNames = append(claimNames, api.ClaimName)
+		}
+	case ExecProviderType:
+		if p.Exec == nil || len(p.Exec.Command) == 0 {
+			return fmt.Errorf("command must be specified")
+		}
+	case FileProviderType:
+		if p.File == nil || len(p.File.Path) == 0 {
+			return fmt.Errorf("path must be specified")
+		}
+	default:
+		return fmt.Errorf("unsupported claim provider type %q", p.Type)
+	}
+
+	for _, name := range claimNames {
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			return fmt.Errorf("invalid claim name %q: %v", name, errs)
+		}
+		if isReservedClaimName(name) || strings.HasSuffix(name, helpers.AgentVersionClaimSuffix) {
+			return fmt.Errorf("claim name %q is reserved", name)
+		}
+	}
+	return nil
+}
+
+func (p *ProviderConfig) refreshInterval() time.Duration {
+	if p.RefreshInterval == nil {
+		return defaultRefreshInterval
+	}
+	return p.RefreshInterval.Duration
+}
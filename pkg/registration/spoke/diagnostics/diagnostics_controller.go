@@ -0,0 +1,291 @@
+package diagnostics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/component-base/featuregate"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/yaml"
+
+	clusterv1informer "open-cluster-management.io/api/client/cluster/informers/externalversions/cluster/v1"
+	clusterv1listers "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
+	operatorv1 "open-cluster-management.io/api/operator/v1"
+	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
+
+	"open-cluster-management.io/ocm/pkg/common/diagnostics"
+)
+
+const (
+	// the logs are limited so that the archive fits in a ConfigMap.
+	logTailLines  = 1000
+	logLimitBytes = 256 * 1024
+	// minLogLimitBytes is the tail of the logs kept before the other files are dropped from an archive too large.
+	minLogLimitBytes = 16 * 1024
+	// maxArchiveSize is the max size of the archive, the limit of a ConfigMap is 1MiB. The logs are cut further if
+	// the archive is still too large.
+	maxArchiveSize = 900 * 1024
+)
+
+var (
+	klusterletGVR = operatorv1.SchemeGroupVersion.WithResource("klusterlets")
+	eventGVR      = corev1.SchemeGroupVersion.WithResource("events")
+)
+
+// diagnosticsController collects the spoke archive of the diagnostics bundle when it is requested by the
+// diagnostics.RequestAnnotationKey annotation of the managed cluster on the hub, and uploads it into the
+// diagnostics.ConfigMapName ConfigMap in the cluster namespace on the hub.
+type diagnosticsController struct {
+	clusterName             string
+	agentNamespace          string
+	hubClusterLister        clusterv1listers.ManagedClusterLister
+	hubConfigMapClient      corev1client.ConfigMapInterface
+	managementKubeClient    kubernetes.Interface
+	managementDynamicClient dynamic.Interface
+	featureGate             featuregate.MutableFeatureGate
+	// lastRequestID is the last request that is uploaded, so the ConfigMap is not read on every sync.
+	lastRequestID string
+}
+
+// NewDiagnosticsController creates a new diagnostics controller on the managed cluster. The klusterlets, the events
+// and the logs of the agents are read from the cluster where the agents run.
+func NewDiagnosticsController(
+	clusterName, agentNamespace string,
+	hubConfigMapClient corev1client.ConfigMapInterface,
+	hubClusterInformer clusterv1informer.ManagedClusterInformer,
+	managementKubeClient kubernetes.Interface,
+	managementDynamicClient dynamic.Interface,
+	featureGate featuregate.MutableFeatureGate) factory.Controller {
+	c := &diagnosticsController{
+		clusterName:             clusterName,
+		agentNamespace:          agentNamespace,
+		hubClusterLister:        hubClusterInformer.Lister(),
+		hubConfigMapClient:      hubConfigMapClient,
+		managementKubeClient:    managementKubeClient,
+		managementDynamicClient: managementDynamicClient,
+		featureGate:             featureGate,
+	}
+
+	return factory.New().
+		WithInformers(hubClusterInformer.Informer()).
+		WithSync(c.sync).
+		ToController("DiagnosticsController")
+}
+
+func (c *diagnosticsController) sync(ctx context.Context, syncCtx factory.SyncContext, _ string) error {
+	cluster, err := c.hubClusterLister.Get(c.clusterName)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	requestID := cluster.Annotations[diagnostics.RequestAnnotationKey]
+	if len(requestID) == 0 || requestID == c.lastRequestID {
+		return nil
+	}
+
+	existing, err := c.hubConfigMapClient.Get(ctx, diagnostics.ConfigMapName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		existing = nil
+	case err != nil:
+		return err
+	case existing.Annotations[diagnostics.RequestAnnotationKey] == requestID:
+		c.lastRequestID = requestID
+		return nil
+	}
+
+	archive, err := c.collect(ctx, requestID)
+	if err != nil {
+		return err
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        diagnostics.ConfigMapName,
+			Namespace:   c.clusterName,
+			Annotations: map[string]string{diagnostics.RequestAnnotationKey: requestID},
+		},
+		BinaryData: map[string][]byte{diagnostics.ArchiveKey: archive},
+	}
+	if existing == nil {
+		_, err = c.hubConfigMapClient.Create(ctx, configMap, metav1.CreateOptions{})
+	} else {
+		configMap.ResourceVersion = existing.ResourceVersion
+		_, err = c.hubConfigMapClient.Update(ctx, configMap, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to upload the diagnostics of request %s: %w", requestID, err)
+	}
+
+	c.lastRequestID = requestID
+	syncCtx.Recorder().Eventf(ctx, "DiagnosticsUploaded", "Diagnostics of request %s is uploaded to the hub", requestID)
+	return nil
+}
+
+// collection is the files of the spoke archive collected in a sync.
+type collection struct {
+	// files are the files except the logs.
+	files []diagnostics.File
+	// logs are the redacted logs.
+	logs []diagnostics.File
+	// errors are the errors of the files failed to be collected, keyed by the file names.
+	errors map[string]error
+}
+
+// collect collects the spoke archive. A file failed to be collected is recorded in the manifest of the archive, so
+// that the other files are still uploaded.
+func (c *diagnosticsController) collect(ctx context.Context, requestID string) ([]byte, error) {
+	collected := &collection{errors: map[string]error{}}
+
+	// the klusterlets are cluster scoped, and the events are in the agent namespace.
+	for _, resource := range []struct {
+		file   string
+		client dynamic.ResourceInterface
+	}{
+		{file: "klusterlets.yaml", client: c.managementDynamicClient.Resource(klusterletGVR)},
+		{file: "events.yaml", client: c.managementDynamicClient.Resource(eventGVR).Namespace(c.agentNamespace)},
+	} {
+		objects, err := resource.client.List(ctx, metav1.ListOptions{})
+		if err != nil {
+			collected.errors[resource.file] = err
+			continue
+		}
+		data, err := diagnostics.MarshalObjects(objects.Items)
+		if err != nil {
+			return nil, err
+		}
+		collected.files = append(collected.files, diagnostics.File{Name: resource.file, Data: data})
+	}
+
+	featureGates := map[string]bool{}
+	for feature := range c.featureGate.GetAll() {
+		featureGates[string(feature)] = c.featureGate.Enabled(feature)
+	}
+	data, err := yaml.Marshal(featureGates)
+	if err != nil {
+		return nil, err
+	}
+	collected.files = append(collected.files, diagnostics.File{Name: "featuregates.yaml", Data: data})
+
+	c.collectLogs(ctx, collected)
+
+	return writeArchive(c.clusterName, requestID, time.Now(), collected, maxArchiveSize)
+}
+
+// writeArchive writes the collected files into an archive no larger than maxSize, so the upload does not fail on
+// every retry. The logs are cut to their tails first, then the other files are dropped from the largest one, and at
+// last the logs are cut further.
+func writeArchive(clusterName, requestID string, collectedAt time.Time, collected *collection,
+	maxSize int) ([]byte, error) {
+	files := append([]diagnostics.File{}, collected.files...)
+	var dropped []string
+	for logLimit := logLimitBytes; ; {
+		buf := &bytes.Buffer{}
+		w := diagnostics.NewWriter(buf, clusterName, requestID, collectedAt)
+		for _, name := range sets.List(sets.KeySet(collected.errors)) {
+			w.AddError(name, collected.errors[name])
+		}
+		for _, file := range files {
+			if err := w.AddFile(file.Name, file.Data); err != nil {
+				return nil, err
+			}
+		}
+		for _, name := range dropped {
+			w.AddTruncated(name)
+		}
+		for _, log := range collected.logs {
+			if len(log.Data) > logLimit {
+				w.AddTruncated(log.Name)
+			}
+			if err := w.AddFile(log.Name, tail(log.Data, logLimit)); err != nil {
+				return nil, err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		if buf.Len() <= maxSize {
+			return buf.Bytes(), nil
+		}
+
+		switch {
+		case logLimit > minLogLimitBytes:
+			logLimit /= 2
+		case len(files) > 0:
+			largest := 0
+			for i := range files {
+				if len(files[i].Data) > len(files[largest].Data) {
+					largest = i
+				}
+			}
+			dropped = append(dropped, files[largest].Name)
+			files = append(files[:largest], files[largest+1:]...)
+		case logLimit > 0:
+			logLimit /= 2
+		default:
+			return nil, fmt.Errorf("the diagnostics archive of %d bytes exceeds the limit of %d bytes", buf.Len(), maxSize)
+		}
+	}
+}
+
+// tail returns the last limit bytes of the log, starting from a new line.
+func tail(log []byte, limit int) []byte {
+	if len(log) <= limit {
+		return log
+	}
+	log = log[len(log)-limit:]
+	if i := bytes.IndexByte(log, '\n'); i >= 0 {
+		return log[i+1:]
+	}
+	return []byte{}
+}
+
+// collectLogs collects the redacted tail of the logs of the containers of the pods in the agent namespace.
+func (c *diagnosticsController) collectLogs(ctx context.Context, collected *collection) {
+	pods, err := c.managementKubeClient.CoreV1().Pods(c.agentNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		collected.errors["logs"] = err
+		return
+	}
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+
+	for _, pod := range pods.Items {
+		for _, container := range pod.Spec.Containers {
+			file := fmt.Sprintf("logs/%s/%s.log", pod.Name, container.Name)
+			log, err := c.readLog(ctx, pod.Name, container.Name)
+			if err != nil {
+				collected.errors[file] = err
+				continue
+			}
+			// the log is redacted before it is cut, so no secret is left behind by a cut line.
+			collected.logs = append(collected.logs, diagnostics.File{Name: file, Data: diagnostics.RedactText(log)})
+		}
+	}
+}
+
+func (c *diagnosticsController) readLog(ctx context.Context, pod, container string) ([]byte, error) {
+	stream, err := c.managementKubeClient.CoreV1().Pods(c.agentNamespace).GetLogs(pod, &corev1.PodLogOptions{
+		Container:  container,
+		TailLines:  ptr.To[int64](logTailLines),
+		LimitBytes: ptr.To[int64](logLimitBytes),
+	}).Stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	return io.ReadAll(stream)
+}
@@ -0,0 +1,260 @@
+package diagnostics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/component-base/featuregate"
+
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+	clusterinformers "open-cluster-management.io/api/client/cluster/informers/externalversions"
+
+	"open-cluster-management.io/ocm/pkg/common/diagnostics"
+	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
+	testinghelpers "open-cluster-management.io/ocm/pkg/registration/helpers/testing"
+)
+
+const agentNamespace = "open-cluster-management-agent"
+
+func TestSync(t *testing.T) {
+	cases := []struct {
+		name              string
+		requestID         string
+		lastRequestID     string
+		existingConfigMap *corev1.ConfigMap
+		validateActions   func(t *testing.T, actions []clienttesting.Action)
+	}{
+		{
+			name: "no request",
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertNoActions(t, actions)
+			},
+		},
+		{
+			name:          "request is uploaded",
+			requestID:     "req1",
+			lastRequestID: "req1",
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertNoActions(t, actions)
+			},
+		},
+		{
+			name:              "request is uploaded before restart",
+			requestID:         "req1",
+			existingConfigMap: newConfigMap("req1"),
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "get")
+			},
+		},
+		{
+			name:      "upload a new request",
+			requestID: "req1",
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "get", "create")
+				configMap := actions[1].(clienttesting.CreateActionImpl).Object.(*corev1.ConfigMap)
+				assertArchive(t, configMap, "req1")
+			},
+		},
+		{
+			name:              "upload a request again",
+			requestID:         "req2",
+			lastRequestID:     "req1",
+			existingConfigMap: newConfigMap("req1"),
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "get", "update")
+				configMap := actions[1].(clienttesting.UpdateActionImpl).Object.(*corev1.ConfigMap)
+				assertArchive(t, configMap, "req2")
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cluster := testinghelpers.NewAcceptedManagedCluster()
+			if len(c.requestID) > 0 {
+				cluster.Annotations = map[string]string{diagnostics.RequestAnnotationKey: c.requestID}
+			}
+			clusterClient := clusterfake.NewSimpleClientset(cluster)
+			clusterInformerFactory := clusterinformers.NewSharedInformerFactory(clusterClient, time.Minute*10)
+			if err := clusterInformerFactory.Cluster().V1().ManagedClusters().Informer().GetStore().Add(cluster); err != nil {
+				t.Fatal(err)
+			}
+
+			var hubObjects []runtime.Object
+			if c.existingConfigMap != nil {
+				hubObjects = append(hubObjects, c.existingConfigMap)
+			}
+			hubKubeClient := kubefake.NewSimpleClientset(hubObjects...)
+
+			managementKubeClient := kubefake.NewSimpleClientset(&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "klusterlet-agent", Namespace: agentNamespace},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "agent"}}},
+			})
+			managementDynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{
+					klusterletGVR: "KlusterletList",
+					eventGVR:      "EventList",
+				},
+				testingcommon.NewUnstructured("operator.open-cluster-management.io/v1", "Klusterlet", "", "klusterlet"))
+
+			featureGate := featuregate.NewFeatureGate()
+			if err := featureGate.Add(map[featuregate.Feature]featuregate.FeatureSpec{
+				"Test": {Default: true},
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			ctrl := &diagnosticsController{
+				clusterName:             testinghelpers.TestManagedClusterName,
+				agentNamespace:          agentNamespace,
+				hubClusterLister:        clusterInformerFactory.Cluster().V1().ManagedClusters().Lister(),
+				hubConfigMapClient:      hubKubeClient.CoreV1().ConfigMaps(testinghelpers.TestManagedClusterName),
+				managementKubeClient:    managementKubeClient,
+				managementDynamicClient: managementDynamicClient,
+				featureGate:             featureGate,
+				lastRequestID:           c.lastRequestID,
+			}
+
+			if err := ctrl.sync(context.TODO(), testingcommon.NewFakeSyncContext(t, ""), ""); err != nil {
+				t.Errorf("unexpected err: %v", err)
+			}
+			c.validateActions(t, hubKubeClient.Actions())
+			if ctrl.lastRequestID != c.requestID {
+				t.Errorf("expected last request %q, but got %q", c.requestID, ctrl.lastRequestID)
+			}
+		})
+	}
+}
+
+func newConfigMap(requestID string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        diagnostics.ConfigMapName,
+			Namespace:   testinghelpers.TestManagedClusterName,
+			Annotations: map[string]string{diagnostics.RequestAnnotationKey: requestID},
+		},
+	}
+}
+
+func assertArchive(t *testing.T, configMap *corev1.ConfigMap, requestID string) {
+	if configMap.Annotations[diagnostics.RequestAnnotationKey] != requestID {
+		t.Errorf("expected request %s, but got %v", requestID, configMap.Annotations)
+	}
+	files, err := diagnostics.ReadArchive(bytes.NewReader(configMap.BinaryData[diagnostics.ArchiveKey]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := map[string]string{}
+	for _, file := range files {
+		contents[file.Name] = string(file.Data)
+	}
+
+	manifest := &diagnostics.Manifest{}
+	if err := json.Unmarshal([]byte(contents[diagnostics.ManifestFile]), manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.RequestID != requestID || manifest.ClusterName != testinghelpers.TestManagedClusterName {
+		t.Errorf("unexpected manifest %v", manifest)
+	}
+	expectedFiles := []string{"events.yaml", "featuregates.yaml", "klusterlets.yaml", "logs/klusterlet-agent/agent.log"}
+	if strings.Join(manifest.Files, ",") != strings.Join(expectedFiles, ",") {
+		t.Errorf("expected files %v, but got %v", expectedFiles, manifest.Files)
+	}
+	if !strings.Contains(contents["klusterlets.yaml"], "name: klusterlet") {
+		t.Errorf("expected the klusterlet is collected, but got %s", contents["klusterlets.yaml"])
+	}
+	if !strings.Contains(contents["featuregates.yaml"], "Test: true") {
+		t.Errorf("expected the feature gates are collected, but got %s", contents["featuregates.yaml"])
+	}
+}
+
+func TestWriteArchiveTruncates(t *testing.T) {
+	// random data is not compressed, so the size of the archive is close to the size of the files.
+	random := rand.New(rand.NewSource(1)) //nolint:gosec
+	randomLines := func(size int) []byte {
+		data := make([]byte, 0, size)
+		for len(data) < size {
+			data = append(data, []byte(fmt.Sprintf("%x\n", random.Uint64()))...)
+		}
+		return data
+	}
+	collected := &collection{
+		files: []diagnostics.File{
+			{Name: "events.yaml", Data: randomLines(64 * 1024)},
+			{Name: "featuregates.yaml", Data: []byte("Test: true\n")},
+		},
+		logs: []diagnostics.File{
+			{Name: "logs/klusterlet-agent/agent.log", Data: randomLines(128 * 1024)},
+		},
+		errors: map[string]error{},
+	}
+
+	cases := []struct {
+		name              string
+		maxSize           int
+		expectedFiles     []string
+		expectedTruncated []string
+	}{
+		{
+			name:          "not truncated",
+			maxSize:       1024 * 1024,
+			expectedFiles: []string{"events.yaml", "featuregates.yaml", "logs/klusterlet-agent/agent.log"},
+		},
+		{
+			name:              "log truncated",
+			maxSize:           64 * 1024,
+			expectedFiles:     []string{"events.yaml", "featuregates.yaml", "logs/klusterlet-agent/agent.log"},
+			expectedTruncated: []string{"logs/klusterlet-agent/agent.log"},
+		},
+		{
+			name:              "file dropped",
+			maxSize:           16 * 1024,
+			expectedFiles:     []string{"featuregates.yaml", "logs/klusterlet-agent/agent.log"},
+			expectedTruncated: []string{"events.yaml", "logs/klusterlet-agent/agent.log"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			archive, err := writeArchive(testinghelpers.TestManagedClusterName, "req1", time.Now(), collected, c.maxSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(archive) > c.maxSize {
+				t.Errorf("expected the archive no larger than %d, but got %d", c.maxSize, len(archive))
+			}
+			files, err := diagnostics.ReadArchive(bytes.NewReader(archive))
+			if err != nil {
+				t.Fatal(err)
+			}
+			manifest := &diagnostics.Manifest{}
+			for _, file := range files {
+				if file.Name != diagnostics.ManifestFile {
+					continue
+				}
+				if err := json.Unmarshal(file.Data, manifest); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if strings.Join(manifest.Files, ",") != strings.Join(c.expectedFiles, ",") {
+				t.Errorf("expected files %v, but got %v", c.expectedFiles, manifest.Files)
+			}
+			if strings.Join(manifest.Truncated, ",") != strings.Join(c.expectedTruncated, ",") {
+				t.Errorf("expected truncated files %v, but got %v", c.expectedTruncated, manifest.Truncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,2 @@
+// package healthprobe contains the configurable health probes that the registration agent runs on the managed cluster.
+package healthprobe
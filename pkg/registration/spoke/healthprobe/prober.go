@@ -0,0 +1,322 @@
+package healthprobe
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	celconfig "k8s.io/apiserver/pkg/apis/cel"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	corev1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
+
+	ocmcelcommon "open-cluster-management.io/sdk-go/pkg/cel/common"
+	ocmcellibrary "open-cluster-management.io/sdk-go/pkg/cel/library"
+
+	commonhelpers "open-cluster-management.io/ocm/pkg/common/helpers"
+)
+
+const defaultHTTPTimeout = 10 * time.Second
+
+// Clients holds the clients of the managed cluster that are used by the probers.
+type Clients struct {
+	KubeClient    kubernetes.Interface
+	DynamicClient dynamic.Interface
+	// RESTClient is used to request the kube-apiserver paths of HTTP probes
+	RESTClient rest.Interface
+	NodeLister corev1lister.NodeLister
+}
+
+// Prober runs one health probe.
+type Prober struct {
+	probe   Probe
+	clients Clients
+
+	nodeSelector labels.Selector
+	celProgram   cel.Program
+	httpClient   *http.Client
+
+	// result is the latest result of the probe run in the background.
+	lock   sync.RWMutex
+	result *metav1.Condition
+}
+
+// NewProbers builds the probers for the given config.
+func NewProbers(config *Config, clients Clients) ([]*Prober, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	var probers []*Prober
+	for _, probe := range config.Probes {
+		prober, err := newProber(probe, clients)
+		if err != nil {
+			return nil, fmt.Errorf("unable to build health probe %q: %w", probe.Name, err)
+		}
+		probers = append(probers, prober)
+	}
+	return probers, nil
+}
+
+func newProber(probe Probe, clients Clients) (*Prober, error) {
+	p := &Prober{probe: probe, clients: clients, nodeSelector: labels.Everything()}
+	switch probe.Type {
+	case NodeReadinessProbeType:
+		if probe.NodeReadiness != nil && len(probe.NodeReadiness.LabelSelector) > 0 {
+			selector, err := labels.Parse(probe.NodeReadiness.LabelSelector)
+			if err != nil {
+				return nil, err
+			}
+			p.nodeSelector = selector
+		}
+	case CELProbeType:
+		env, err := cel.NewEnv(slices.Concat(
+			[]cel.EnvOption{cel.Variable("objects", cel.ListType(cel.DynType)), ocmcellibrary.JsonLib()},
+			ocmcelcommon.BaseEnvOpts,
+		)...)
+		if err != nil {
+			return nil, err
+		}
+		ast, iss := env.Compile(probe.CEL.Expression)
+		if iss.Err() != nil {
+			return nil, iss.Err()
+		}
+		if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+			return nil, fmt.Errorf("expected bool result of expression, got %v", ast.OutputType())
+		}
+		p.celProgram, err = env.Program(ast,
+			cel.CostLimit(celconfig.PerCallLimit),
+			cel.CostTracking(&ocmcelcommon.BaseEnvCostEstimator{CostEstimator: &ocmcellibrary.CostEstimator{}}),
+			cel.InterruptCheckFrequency(celconfig.CheckFrequency),
+		)
+		if err != nil {
+			return nil, err
+		}
+	case HTTPProbeType:
+		timeout := defaultHTTPTimeout
+		if probe.HTTP.TimeoutSeconds > 0 {
+			timeout = time.Duration(probe.HTTP.TimeoutSeconds) * time.Second
+		}
+		p.httpClient = &http.Client{Timeout: timeout}
+	}
+	return p, nil
+}
+
+// Name returns the name of the probe.
+func (p *Prober) Name() string {
+	return p.probe.Name
+}
+
+// AffectsAvailable returns whether the probe result feeds into the ManagedClusterConditionAvailable.
+func (p *Prober) AffectsAvailable() bool {
+	return p.probe.AffectsAvailable
+}
+
+// Run runs the probe every period until the context is done. The probes run in the background, so that a slow probe
+// does not delay the status sync of the managed cluster, which reads the latest result by Result.
+func (p *Prober) Run(ctx context.Context, period time.Duration) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		condition := p.Probe(ctx)
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		p.result = &condition
+	}, period)
+}
+
+// Result returns the latest result of the probe, it returns false if the probe has not run yet.
+func (p *Prober) Result() (metav1.Condition, bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.result == nil {
+		return metav1.Condition{}, false
+	}
+	return *p.result, true
+}
+
+// Probe runs the probe and returns its result as a ManagedCluster condition.
+func (p *Prober) Probe(ctx context.Context) metav1.Condition {
+	condition := metav1.Condition{Type: ConditionType(p.probe.Name)}
+
+	var healthy bool
+	var message string
+	var err error
+	switch p.probe.Type {
+	case NodeReadinessProbeType:
+		healthy, message, err = p.probeNodeReadiness()
+	case WorkloadProbeType:
+		healthy, message, err = p.probeWorkloads(ctx)
+	case CELProbeType:
+		healthy, message, err = p.probeCEL(ctx)
+	case HTTPProbeType:
+		healthy, message, err = p.probeHTTP(ctx)
+	default:
+		err = fmt.Errorf("unsupported health probe type %q", p.probe.Type)
+	}
+
+	switch {
+	case err != nil:
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = ReasonProbeError
+		condition.Message = err.Error()
+	case healthy:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = ReasonProbeSucceeded
+		condition.Message = message
+	case p.probe.TaintOnFailure:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = ReasonProbeFailedTaint
+		condition.Message = message
+	default:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = ReasonProbeFailed
+		condition.Message = message
+	}
+	return condition
+}
+
+func (p *Prober) probeNodeReadiness() (bool, string, error) {
+	nodes, err := p.clients.NodeLister.List(p.nodeSelector)
+	if err != nil {
+		return false, "", err
+	}
+	if len(nodes) == 0 {
+		return false, "No nodes are found", nil
+	}
+
+	ready := 0
+	for _, node := range nodes {
+		for _, cond := range node.Status.Conditions {
+			if cond.Type == corev1.NodeReady && cond.Status == corev1.ConditionTrue {
+				ready++
+				break
+			}
+		}
+	}
+
+	minReadyPercentage := int32(100)
+	if p.probe.NodeReadiness != nil && p.probe.NodeReadiness.MinReadyPercentage != nil {
+		minReadyPercentage = *p.probe.NodeReadiness.MinReadyPercentage
+	}
+
+	message := fmt.Sprintf("%d of %d nodes are ready", ready, len(nodes))
+	return ready*100 >= int(minReadyPercentage)*len(nodes), message, nil
+}
+
+func (p *Prober) probeWorkloads(ctx context.Context) (bool, string, error) {
+	var unavailable []string
+	for _, w := range p.probe.Workload.Workloads {
+		available, err := p.isWorkloadAvailable(ctx, w)
+		if err != nil {
+			return false, "", err
+		}
+		if !available {
+			unavailable = append(unavailable, fmt.Sprintf("%s %s/%s", w.Kind, w.Namespace, w.Name))
+		}
+	}
+
+	if len(unavailable) > 0 {
+		return false, fmt.Sprintf("Workloads are not available: %s", strings.Join(unavailable, ", ")), nil
+	}
+	return true, "All workloads are available", nil
+}
+
+func (p *Prober) isWorkloadAvailable(ctx context.Context, w WorkloadReference) (bool, error) {
+	appsClient := p.clients.KubeClient.AppsV1()
+	switch w.Kind {
+	case "Deployment":
+		deploy, err := appsClient.Deployments(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return deploy.Status.AvailableReplicas >= desiredReplicas(deploy.Spec.Replicas) &&
+			deploy.Status.UnavailableReplicas == 0, nil
+	case "StatefulSet":
+		sts, err := appsClient.StatefulSets(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return sts.Status.AvailableReplicas >= desiredReplicas(sts.Spec.Replicas), nil
+	case "DaemonSet":
+		ds, err := appsClient.DaemonSets(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return ds.Status.NumberUnavailable == 0 && ds.Status.NumberAvailable >= ds.Status.DesiredNumberScheduled, nil
+	}
+	return false, fmt.Errorf("unsupported workload kind %q", w.Kind)
+}
+
+// desiredReplicas returns the desired replicas of a workload, which defaults to 1 if it is not set.
+func desiredReplicas(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+func (p *Prober) probeCEL(ctx context.Context) (bool, string, error) {
+	list, err := p.clients.DynamicClient.Resource(p.probe.CEL.GroupVersionResource()).
+		Namespace(p.probe.CEL.Namespace).
+		List(ctx, metav1.ListOptions{LabelSelector: p.probe.CEL.LabelSelector})
+	if err != nil {
+		return false, "", err
+	}
+
+	objects := make([]any, 0, len(list.Items))
+	for _, item := range list.Items {
+		objects = append(objects, item.Object)
+	}
+
+	result, details, err := p.celProgram.ContextEval(ctx, map[string]any{"objects": objects})
+	if err != nil {
+		return false, "", fmt.Errorf("failed to evaluate expression %q: %w", p.probe.CEL.Expression, err)
+	}
+	if ok, _ := commonhelpers.CostCalculation(
+		ctx, details, int64(celconfig.RuntimeCELCostBudget), p.probe.CEL.Expression); !ok {
+		return false, "", fmt.Errorf("expression %q exceeds the cost budget", p.probe.CEL.Expression)
+	}
+
+	healthy, ok := result.Value().(bool)
+	if !ok {
+		return false, "", fmt.Errorf("expected bool result of expression %q, got %T", p.probe.CEL.Expression, result.Value())
+	}
+	return healthy, fmt.Sprintf("Expression is evaluated to %v on %d %s", healthy, len(objects), p.probe.CEL.Resource), nil
+}
+
+func (p *Prober) probeHTTP(ctx context.Context) (bool, string, error) {
+	statusCode := 0
+	if len(p.probe.HTTP.Path) > 0 {
+		ctx, cancel := context.WithTimeout(ctx, p.httpClient.Timeout)
+		defer cancel()
+		result := p.clients.RESTClient.Get().AbsPath(p.probe.HTTP.Path).Do(ctx).StatusCode(&statusCode)
+		// the request is not sent or no response is received.
+		if statusCode == 0 {
+			if err := result.Error(); err != nil {
+				return false, err.Error(), nil
+			}
+		}
+	} else {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.probe.HTTP.URL, nil)
+		if err != nil {
+			return false, "", err
+		}
+		resp, err := p.httpClient.Do(req)
+		if err != nil {
+			return false, err.Error(), nil
+		}
+		defer resp.Body.Close()
+		statusCode = resp.StatusCode
+	}
+
+	return statusCode == http.StatusOK, fmt.Sprintf("Status code: %d", statusCode), nil
+}
@@ -0,0 +1,248 @@
+package healthprobe
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubeinformers "k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+)
+
+func newNode(name string, ready bool) *corev1.Node {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name        string
+		probes      []Probe
+		expectedErr bool
+	}{
+		{
+			name:   "valid probes",
+			probes: []Probe{{Name: "nodes", Type: NodeReadinessProbeType}, {Name: "dns", Type: HTTPProbeType, HTTP: &HTTPProbe{Path: "/readyz"}}},
+		},
+		{
+			name:        "invalid name",
+			probes:      []Probe{{Name: "Nodes", Type: NodeReadinessProbeType}},
+			expectedErr: true,
+		},
+		{
+			name:        "duplicated name",
+			probes:      []Probe{{Name: "nodes", Type: NodeReadinessProbeType}, {Name: "nodes", Type: NodeReadinessProbeType}},
+			expectedErr: true,
+		},
+		{
+			name: "invalid percentage",
+			probes: []Probe{{Name: "nodes", Type: NodeReadinessProbeType,
+				NodeReadiness: &NodeReadinessProbe{MinReadyPercentage: ptr.To[int32](101)}}},
+			expectedErr: true,
+		},
+		{
+			name: "unsupported workload kind",
+			probes: []Probe{{Name: "dns", Type: WorkloadProbeType,
+				Workload: &WorkloadProbe{Workloads: []WorkloadReference{{Kind: "Pod", Namespace: "ns", Name: "dns"}}}}},
+			expectedErr: true,
+		},
+		{
+			name:        "both path and url",
+			probes:      []Probe{{Name: "http", Type: HTTPProbeType, HTTP: &HTTPProbe{Path: "/readyz", URL: "http://test"}}},
+			expectedErr: true,
+		},
+		{
+			name:        "unsupported type",
+			probes:      []Probe{{Name: "unknown", Type: "unknown"}},
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := &Config{Probes: c.probes}
+			err := config.Validate()
+			if c.expectedErr != (err != nil) {
+				t.Errorf("expected error %v, but got %v", c.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestProbe(t *testing.T) {
+	podsGVR := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+	cases := []struct {
+		name           string
+		probe          Probe
+		nodes          []*corev1.Node
+		kubeObjects    []runtime.Object
+		dynamicObjects []runtime.Object
+		expectedStatus metav1.ConditionStatus
+		expectedReason string
+	}{
+		{
+			name:           "all nodes are ready",
+			probe:          Probe{Name: "nodes", Type: NodeReadinessProbeType},
+			nodes:          []*corev1.Node{newNode("node1", true), newNode("node2", true)},
+			expectedStatus: metav1.ConditionTrue,
+		},
+		{
+			name:           "not all nodes are ready",
+			probe:          Probe{Name: "nodes", Type: NodeReadinessProbeType},
+			nodes:          []*corev1.Node{newNode("node1", true), newNode("node2", false)},
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: ReasonProbeFailed,
+		},
+		{
+			name:           "not all nodes are ready with taint on failure",
+			probe:          Probe{Name: "nodes", Type: NodeReadinessProbeType, TaintOnFailure: true},
+			nodes:          []*corev1.Node{newNode("node1", true), newNode("node2", false)},
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: ReasonProbeFailedTaint,
+		},
+		{
+			name: "ready nodes reach the percentage",
+			probe: Probe{Name: "nodes", Type: NodeReadinessProbeType,
+				NodeReadiness: &NodeReadinessProbe{MinReadyPercentage: ptr.To[int32](50)}},
+			nodes:          []*corev1.Node{newNode("node1", true), newNode("node2", false)},
+			expectedStatus: metav1.ConditionTrue,
+		},
+		{
+			name: "workload is available",
+			probe: Probe{Name: "dns", Type: WorkloadProbeType, Workload: &WorkloadProbe{
+				Workloads: []WorkloadReference{{Kind: "Deployment", Namespace: "kube-system", Name: "coredns"}}}},
+			kubeObjects: []runtime.Object{&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "coredns"},
+				Status:     appsv1.DeploymentStatus{AvailableReplicas: 2},
+			}},
+			expectedStatus: metav1.ConditionTrue,
+		},
+		{
+			name: "workload is not available",
+			probe: Probe{Name: "dns", Type: WorkloadProbeType, Workload: &WorkloadProbe{
+				Workloads: []WorkloadReference{{Kind: "Deployment", Namespace: "kube-system", Name: "coredns"}}}},
+			kubeObjects: []runtime.Object{&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "coredns"},
+				Status:     appsv1.DeploymentStatus{AvailableReplicas: 1, UnavailableReplicas: 1},
+			}},
+			expectedStatus: metav1.ConditionFalse,
+		},
+		{
+			name: "workload is scaled to zero",
+			probe: Probe{Name: "dns", Type: WorkloadProbeType, Workload: &WorkloadProbe{
+				Workloads: []WorkloadReference{{Kind: "Deployment", Namespace: "kube-system", Name: "coredns"}}}},
+			kubeObjects: []runtime.Object{&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "coredns"},
+				Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](0)},
+			}},
+			expectedStatus: metav1.ConditionTrue,
+		},
+		{
+			name: "workload has less available replicas than desired",
+			probe: Probe{Name: "dns", Type: WorkloadProbeType, Workload: &WorkloadProbe{
+				Workloads: []WorkloadReference{{Kind: "StatefulSet", Namespace: "kube-system", Name: "etcd"}}}},
+			kubeObjects: []runtime.Object{&appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "etcd"},
+				Spec:       appsv1.StatefulSetSpec{Replicas: ptr.To[int32](3)},
+				Status:     appsv1.StatefulSetStatus{Replicas: 2, AvailableReplicas: 2},
+			}},
+			expectedStatus: metav1.ConditionFalse,
+		},
+		{
+			name: "workload is not found",
+			probe: Probe{Name: "dns", Type: WorkloadProbeType, Workload: &WorkloadProbe{
+				Workloads: []WorkloadReference{{Kind: "Deployment", Namespace: "kube-system", Name: "coredns"}}}},
+			expectedStatus: metav1.ConditionUnknown,
+		},
+		{
+			name: "cel expression is true",
+			probe: Probe{Name: "pods", Type: CELProbeType, CEL: &CELProbe{
+				Version: "v1", Resource: "pods", Namespace: "test",
+				Expression: "objects.all(o, o.status.phase == 'Running')"}},
+			dynamicObjects: []runtime.Object{newPod("test", "pod1", "Running")},
+			expectedStatus: metav1.ConditionTrue,
+		},
+		{
+			name: "cel expression is false",
+			probe: Probe{Name: "pods", Type: CELProbeType, CEL: &CELProbe{
+				Version: "v1", Resource: "pods", Namespace: "test",
+				Expression: "objects.all(o, o.status.phase == 'Running')"}},
+			dynamicObjects: []runtime.Object{newPod("test", "pod1", "Running"), newPod("test", "pod2", "Pending")},
+			expectedStatus: metav1.ConditionFalse,
+		},
+		{
+			name: "cel expression fails to evaluate",
+			probe: Probe{Name: "pods", Type: CELProbeType, CEL: &CELProbe{
+				Version: "v1", Resource: "pods", Namespace: "test",
+				Expression: "objects.all(o, o.status.ready)"}},
+			dynamicObjects: []runtime.Object{newPod("test", "pod1", "Running")},
+			expectedStatus: metav1.ConditionUnknown,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kubeClient := kubefake.NewSimpleClientset(c.kubeObjects...)
+			kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubeClient, 0)
+			for _, node := range c.nodes {
+				if err := kubeInformerFactory.Core().V1().Nodes().Informer().GetStore().Add(node); err != nil {
+					t.Fatal(err)
+				}
+			}
+			dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{podsGVR: "PodList"}, c.dynamicObjects...)
+
+			probers, err := NewProbers(&Config{Probes: []Probe{c.probe}}, Clients{
+				KubeClient:    kubeClient,
+				DynamicClient: dynamicClient,
+				NodeLister:    kubeInformerFactory.Core().V1().Nodes().Lister(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			condition := probers[0].Probe(context.TODO())
+			if condition.Type != ConditionType(c.probe.Name) {
+				t.Errorf("unexpected condition type %q", condition.Type)
+			}
+			if condition.Status != c.expectedStatus {
+				t.Errorf("expected status %q, but got %q: %s", c.expectedStatus, condition.Status, condition.Message)
+			}
+			if len(c.expectedReason) > 0 && condition.Reason != c.expectedReason {
+				t.Errorf("expected reason %q, but got %q", c.expectedReason, condition.Reason)
+			}
+		})
+	}
+}
+
+func TestNewProbersWithInvalidExpression(t *testing.T) {
+	_, err := NewProbers(&Config{Probes: []Probe{{Name: "pods", Type: CELProbeType, CEL: &CELProbe{
+		Version: "v1", Resource: "pods", Expression: "objects.size() +"}}}}, Clients{})
+	if err == nil {
+		t.Errorf("expected error, but got nil")
+	}
+}
+
+func newPod(namespace, name, phase string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]any{"namespace": namespace, "name": name},
+		"status":     map[string]any{"phase": phase},
+	}}
+}
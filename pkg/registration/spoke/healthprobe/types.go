@@ -0,0 +1,190 @@
+package healthprobe
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"open-cluster-management.io/ocm/pkg/registration/helpers"
+)
+
+const (
+	// ConditionTypePrefix is the prefix of the ManagedCluster condition type reported for each health probe,
+	// the full condition type is the prefix followed by the probe name.
+	ConditionTypePrefix = helpers.HealthProbeConditionTypePrefix
+
+	// ReasonProbeSucceeded is the condition reason when a probe passes.
+	ReasonProbeSucceeded = "HealthProbeSucceeded"
+	// ReasonProbeFailed is the condition reason when a probe fails.
+	ReasonProbeFailed = "HealthProbeFailed"
+	// ReasonProbeFailedTaint is the condition reason when a probe with TaintOnFailure fails.
+	ReasonProbeFailedTaint = helpers.HealthProbeReasonFailedTaint
+	// ReasonProbeError is the condition reason when a probe cannot be evaluated.
+	ReasonProbeError = "HealthProbeError"
+)
+
+// ProbeType is the type of a health probe.
+type ProbeType string
+
+const (
+	// NodeReadinessProbeType checks the ratio of Ready nodes on the managed cluster.
+	NodeReadinessProbeType ProbeType = "NodeReadiness"
+	// WorkloadProbeType checks that named critical workloads are available.
+	WorkloadProbeType ProbeType = "Workload"
+	// CELProbeType evaluates a CEL expression over a list of selected resources.
+	CELProbeType ProbeType = "CEL"
+	// HTTPProbeType sends a GET request to a kube-apiserver path or an http endpoint.
+	HTTPProbeType ProbeType = "HTTP"
+)
+
+// Config is the health probe configuration of the registration agent.
+type Config struct {
+	Probes []Probe `json:"probes,omitempty"`
+}
+
+// Probe describes one health probe. Each probe is reported as its own condition on the ManagedCluster.
+type Probe struct {
+	// Name is the name of the probe, it must be a DNS-1123 label.
+	Name string `json:"name"`
+	// Type is the type of the probe.
+	Type ProbeType `json:"type"`
+	// AffectsAvailable sets the ManagedClusterConditionAvailable to False when the probe fails.
+	AffectsAvailable bool `json:"affectsAvailable,omitempty"`
+	// TaintOnFailure makes the hub taint the ManagedCluster with a NoSelect taint keyed by the condition type of the
+	// probe when the probe fails. The failure is only reported in the condition by default.
+	TaintOnFailure bool `json:"taintOnFailure,omitempty"`
+
+	NodeReadiness *NodeReadinessProbe `json:"nodeReadiness,omitempty"`
+	Workload      *WorkloadProbe      `json:"workload,omitempty"`
+	CEL           *CELProbe           `json:"cel,omitempty"`
+	HTTP          *HTTPProbe          `json:"http,omitempty"`
+}
+
+// NodeReadinessProbe passes when the percentage of Ready nodes is not less than MinReadyPercentage.
+type NodeReadinessProbe struct {
+	// MinReadyPercentage is in the range of [0, 100], the default is 100.
+	MinReadyPercentage *int32 `json:"minReadyPercentage,omitempty"`
+	// LabelSelector selects the nodes to check, all nodes are checked if it is empty.
+	LabelSelector string `json:"labelSelector,omitempty"`
+}
+
+// WorkloadProbe passes when all of the workloads are available.
+type WorkloadProbe struct {
+	Workloads []WorkloadReference `json:"workloads"`
+}
+
+// WorkloadReference references a Deployment, StatefulSet or DaemonSet on the managed cluster.
+type WorkloadReference struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// CELProbe lists the selected resources and evaluates the expression against them. The expression must return a
+// bool, and the resources are exposed as the variable "objects".
+type CELProbe struct {
+	Group         string `json:"group,omitempty"`
+	Version       string `json:"version"`
+	Resource      string `json:"resource"`
+	Namespace     string `json:"namespace,omitempty"`
+	LabelSelector string `json:"labelSelector,omitempty"`
+	Expression    string `json:"expression"`
+}
+
+// GroupVersionResource returns the resource selected by the probe.
+func (p *CELProbe) GroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: p.Group, Version: p.Version, Resource: p.Resource}
+}
+
+// HTTPProbe passes when a GET request returns 200. Exactly one of Path and URL should be set, the Path is requested
+// through the managed cluster kube-apiserver.
+type HTTPProbe struct {
+	Path           string `json:"path,omitempty"`
+	URL            string `json:"url,omitempty"`
+	TimeoutSeconds int32  `json:"timeoutSeconds,omitempty"`
+}
+
+// ConditionType returns the ManagedCluster condition type of the probe.
+func ConditionType(probeName string) string {
+	return ConditionTypePrefix + probeName
+}
+
+// LoadConfig reads the health probe configuration from a yaml file.
+func LoadConfig(file string) (*Config, error) {
+	data, err := os.ReadFile(path.Clean(file))
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("unable to parse health probe config %q: %w", file, err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate verifies the probes in the config.
+func (c *Config) Validate() error {
+	names := sets.New[string]()
+	for _, probe := range c.Probes {
+		if errs := validation.IsDNS1123Label(probe.Name); len(errs) > 0 {
+			return fmt.Errorf("invalid health probe name %q: %v", probe.Name, errs)
+		}
+		if names.Has(probe.Name) {
+			return fmt.Errorf("duplicated health probe name %q", probe.Name)
+		}
+		names.Insert(probe.Name)
+
+		if err := probe.validate(); err != nil {
+			return fmt.Errorf("invalid health probe %q: %w", probe.Name, err)
+		}
+	}
+	return nil
+}
+
+func (p *Probe) validate() error {
+	switch p.Type {
+	case NodeReadinessProbeType:
+		if p.NodeReadiness == nil {
+			return nil
+		}
+		if p.NodeReadiness.MinReadyPercentage != nil &&
+			(*p.NodeReadiness.MinReadyPercentage < 0 || *p.NodeReadiness.MinReadyPercentage > 100) {
+			return fmt.Errorf("minReadyPercentage must be in the range of [0, 100]")
+		}
+	case WorkloadProbeType:
+		if p.Workload == nil || len(p.Workload.Workloads) == 0 {
+			return fmt.Errorf("workloads must be specified")
+		}
+		for _, w := range p.Workload.Workloads {
+			switch w.Kind {
+			case "Deployment", "StatefulSet", "DaemonSet":
+			default:
+				return fmt.Errorf("unsupported workload kind %q", w.Kind)
+			}
+			if len(w.Namespace) == 0 || len(w.Name) == 0 {
+				return fmt.Errorf("namespace and name of workload must be specified")
+			}
+		}
+	case CELProbeType:
+		if p.CEL == nil || len(p.CEL.Version) == 0 || len(p.CEL.Resource) == 0 || len(p.CEL.Expression) == 0 {
+			return fmt.Errorf("version, resource and expression must be specified")
+		}
+	case HTTPProbeType:
+		if p.HTTP == nil || (len(p.HTTP.Path) == 0) == (len(p.HTTP.URL) == 0) {
+			return fmt.Errorf("exactly one of path and url must be specified")
+		}
+	default:
+		return fmt.Errorf("unsupported health probe type %q", p.Type)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package managedcluster
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
+
+	"open-cluster-management.io/ocm/pkg/common/helpers"
+)
+
+// agentVersionReconcile reports the version of the registration agent in the claims of the managed cluster, so the
+// hub is able to check the agent version skew of the fleet. The versions of the other agents are reported by the
+// cluster claims they create on the managed cluster.
+type agentVersionReconcile struct {
+	agentVersion string
+}
+
+func (r *agentVersionReconcile) reconcile(_ context.Context, _ factory.SyncContext, cluster *clusterv1.ManagedCluster) (*clusterv1.ManagedCluster, reconcileState, error) {
+	// the version is not set in the development builds.
+	if len(r.agentVersion) == 0 || !meta.IsStatusConditionTrue(cluster.Status.Conditions, clusterv1.ManagedClusterConditionJoined) {
+		return cluster, reconcileContinue, nil
+	}
+
+	claimName := helpers.AgentVersionClaimName(helpers.RegistrationAgentComponent)
+	for i, claim := range cluster.Status.ClusterClaims {
+		if claim.Name == claimName {
+			cluster.Status.ClusterClaims[i].Value = r.agentVersion
+			return cluster, reconcileContinue, nil
+		}
+	}
+
+	cluster.Status.ClusterClaims = append(cluster.Status.ClusterClaims,
+		clusterv1.ManagedClusterClaim{Name: claimName, Value: r.agentVersion})
+	return cluster, reconcileContinue, nil
+}
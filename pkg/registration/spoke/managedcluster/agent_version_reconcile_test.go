@@ -0,0 +1,69 @@
+package managedcluster
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
+	testinghelpers "open-cluster-management.io/ocm/pkg/registration/helpers/testing"
+)
+
+func TestAgentVersionReconcile(t *testing.T) {
+	cases := []struct {
+		name           string
+		cluster        *clusterv1.ManagedCluster
+		agentVersion   string
+		expectedClaims []clusterv1.ManagedClusterClaim
+	}{
+		{
+			name:    "version is not set",
+			cluster: newManagedCluster([]clusterv1.ManagedClusterClaim{{Name: "a", Value: "b"}}),
+			expectedClaims: []clusterv1.ManagedClusterClaim{
+				{Name: "a", Value: "b"},
+			},
+		},
+		{
+			name:         "cluster does not join the hub yet",
+			cluster:      testinghelpers.NewManagedCluster(),
+			agentVersion: "v0.16.0",
+		},
+		{
+			name:         "add the version claim",
+			cluster:      newManagedCluster([]clusterv1.ManagedClusterClaim{{Name: "a", Value: "b"}}),
+			agentVersion: "v0.16.0",
+			expectedClaims: []clusterv1.ManagedClusterClaim{
+				{Name: "a", Value: "b"},
+				{Name: "registration.agentversion.open-cluster-management.io", Value: "v0.16.0"},
+			},
+		},
+		{
+			name: "update the version claim",
+			cluster: newManagedCluster([]clusterv1.ManagedClusterClaim{
+				{Name: "registration.agentversion.open-cluster-management.io", Value: "v0.15.0"},
+			}),
+			agentVersion: "v0.16.0",
+			expectedClaims: []clusterv1.ManagedClusterClaim{
+				{Name: "registration.agentversion.open-cluster-management.io", Value: "v0.16.0"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := &agentVersionReconcile{agentVersion: c.agentVersion}
+			cluster, state, err := r.reconcile(context.TODO(), testingcommon.NewFakeSyncContext(t, c.cluster.Name), c.cluster)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state != reconcileContinue {
+				t.Errorf("expected the reconcile continues")
+			}
+			if !reflect.DeepEqual(cluster.Status.ClusterClaims, c.expectedClaims) {
+				t.Errorf("expected claims %v, but got %v", c.expectedClaims, cluster.Status.ClusterClaims)
+			}
+		})
+	}
+}
@@ -18,7 +18,9 @@ import (
 	ocmfeature "open-cluster-management.io/api/feature"
 	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
 
+	"open-cluster-management.io/ocm/pkg/common/helpers"
 	"open-cluster-management.io/ocm/pkg/features"
+	"open-cluster-management.io/ocm/pkg/registration/spoke/claimprovider"
 )
 
 const labelCustomizedOnly = "open-cluster-management.io/spoke-only"
@@ -28,6 +30,7 @@ type claimReconcile struct {
 	aboutLister                  aboutv1alpha1listers.ClusterPropertyLister
 	maxCustomClusterClaims       int
 	reservedClusterClaimSuffixes []string
+	claimProviders               *claimprovider.Providers
 }
 
 func (r *claimReconcile) reconcile(ctx context.Context, syncCtx factory.SyncContext, cluster *clusterv1.ManagedCluster) (*clusterv1.ManagedCluster, reconcileState, error) {
@@ -85,12 +88,21 @@ func (r *claimReconcile) exposeClaims(ctx context.Context, syncCtx factory.SyncC
 				}
 			}
 		}
+
+		// the claims from the built-in providers, which are refreshed in the background, have the lowest priority,
+		// the claims from the cluster properties or cluster claims with the same name override them.
+		for _, claim := range r.claimProviders.Claims() {
+			if _, ok := claimsMap[claim.Name]; !ok {
+				claimsMap[claim.Name] = claim
+			}
+		}
 	}
 
 	// check if the cluster claim is one of the reserved claims or has a reserved suffix.
 	// if so, it will be treated as a reserved claim and will always be exposed.
 	reservedClaimNames := sets.New(clusterv1alpha1.ReservedClusterClaimNames[:]...)
-	reservedClaimSuffixes := sets.New(r.reservedClusterClaimSuffixes...)
+	// the claims reporting the agent versions are always exposed for the hub to check the version skew.
+	reservedClaimSuffixes := sets.New(r.reservedClusterClaimSuffixes...).Insert(helpers.AgentVersionClaimSuffix)
 
 	for _, managedClusterClaim := range claimsMap {
 		if matchReservedClaims(reservedClaimNames, reservedClaimSuffixes, managedClusterClaim) {
@@ -3,6 +3,8 @@ package managedcluster
 import (
 	"context"
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -28,6 +30,7 @@ import (
 	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
 	"open-cluster-management.io/ocm/pkg/features"
 	testinghelpers "open-cluster-management.io/ocm/pkg/registration/helpers/testing"
+	"open-cluster-management.io/ocm/pkg/registration/spoke/claimprovider"
 )
 
 func init() {
@@ -216,6 +219,8 @@ func TestSync(t *testing.T) {
 				kubeInformerFactory.Core().V1().Nodes(),
 				20,
 				[]string{},
+				nil,
+				nil,
 				hubEventRecorder,
 			)
 
@@ -235,9 +240,48 @@ func TestExposeClaims(t *testing.T) {
 		properties                   []*aboutv1alpha1.ClusterProperty
 		maxCustomClusterClaims       int
 		reservedClusterClaimSuffixes []string
+		providedClaims               string
 		validateActions              func(t *testing.T, actions []clienttesting.Action)
 		expectedErr                  string
 	}{
+		{
+			name:    "sync claims of claim providers into status of the managed cluster",
+			cluster: testinghelpers.NewJoinedManagedCluster(),
+			claims: []*clusterv1alpha1.ClusterClaim{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "a",
+					},
+					Spec: clusterv1alpha1.ClusterClaimSpec{
+						Value: "b",
+					},
+				},
+			},
+			providedClaims: "a=x\nc=d\nplatform.open-cluster-management.io=AWS\n",
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				patch := actions[0].(clienttesting.PatchAction).GetPatch()
+				cluster := &clusterv1.ManagedCluster{}
+				err := json.Unmarshal(patch, cluster)
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected := []clusterv1.ManagedClusterClaim{
+					{
+						Name:  "a",
+						Value: "b",
+					},
+					{
+						Name:  "c",
+						Value: "d",
+					},
+				}
+				actual := cluster.Status.ClusterClaims
+				if !reflect.DeepEqual(actual, expected) {
+					t.Errorf("expected cluster claim %v but got: %v", expected, actual)
+				}
+			},
+		},
 		{
 			name:    "sync properties into status of the managed cluster",
 			cluster: testinghelpers.NewJoinedManagedCluster(),
@@ -424,6 +468,60 @@ func TestExposeClaims(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:    "always expose the agent version claims",
+			cluster: testinghelpers.NewJoinedManagedCluster(),
+			claims: []*clusterv1alpha1.ClusterClaim{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "a",
+					},
+					Spec: clusterv1alpha1.ClusterClaimSpec{
+						Value: "b",
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "c",
+					},
+					Spec: clusterv1alpha1.ClusterClaimSpec{
+						Value: "d",
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "work.agentversion.open-cluster-management.io",
+					},
+					Spec: clusterv1alpha1.ClusterClaimSpec{
+						Value: "v0.16.0",
+					},
+				},
+			},
+			maxCustomClusterClaims: 1,
+			validateActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+				patch := actions[0].(clienttesting.PatchAction).GetPatch()
+				cluster := &clusterv1.ManagedCluster{}
+				err := json.Unmarshal(patch, cluster)
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected := []clusterv1.ManagedClusterClaim{
+					{
+						Name:  "work.agentversion.open-cluster-management.io",
+						Value: "v0.16.0",
+					},
+					{
+						Name:  "a",
+						Value: "b",
+					},
+				}
+				actual := cluster.Status.ClusterClaims
+				if !reflect.DeepEqual(actual, expected) {
+					t.Errorf("expected cluster claim %v but got: %v", expected, actual)
+				}
+			},
+		},
 		{
 			name: "remove claims from managed cluster",
 			cluster: newManagedCluster([]clusterv1.ManagedClusterClaim{
@@ -574,6 +672,18 @@ func TestExposeClaims(t *testing.T) {
 				c.maxCustomClusterClaims = 20
 			}
 
+			var claimProviders *claimprovider.Providers
+			if len(c.providedClaims) > 0 {
+				claimsFile := filepath.Join(t.TempDir(), "claims")
+				if err := os.WriteFile(claimsFile, []byte(c.providedClaims), 0600); err != nil {
+					t.Fatal(err)
+				}
+				claimProviders = claimprovider.NewProviders(&claimprovider.Config{Providers: []claimprovider.ProviderConfig{
+					{Name: "file", Type: claimprovider.FileProviderType, File: &claimprovider.FileProvider{Path: claimsFile}},
+				}}, claimprovider.Clients{}, c.reservedClusterClaimSuffixes)
+				claimProviders.Refresh(context.TODO())
+			}
+
 			fakeHubClient := kubefake.NewClientset()
 			ctx := context.TODO()
 			hubEventRecorder, err := events.NewEventRecorder(ctx,
@@ -594,6 +704,8 @@ func TestExposeClaims(t *testing.T) {
 				kubeInformerFactory.Core().V1().Nodes(),
 				c.maxCustomClusterClaims,
 				c.reservedClusterClaimSuffixes,
+				nil,
+				claimProviders,
 				hubEventRecorder,
 			)
 
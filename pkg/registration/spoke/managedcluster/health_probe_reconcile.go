@@ -0,0 +1,59 @@
+package managedcluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
+
+	"open-cluster-management.io/ocm/pkg/registration/helpers"
+	"open-cluster-management.io/ocm/pkg/registration/spoke/healthprobe"
+)
+
+// healthProbeReconcile reports the latest result of each health probe, which runs in the background, as a condition on
+// the managed cluster and sets the available condition to false if a probe that affects availability fails.
+type healthProbeReconcile struct {
+	probers []*healthprobe.Prober
+}
+
+func (r *healthProbeReconcile) reconcile(_ context.Context, _ factory.SyncContext,
+	cluster *clusterv1.ManagedCluster) (*clusterv1.ManagedCluster, reconcileState, error) {
+	probeConditionTypes := map[string]bool{}
+	var failedProbes []string
+	for _, prober := range r.probers {
+		probeConditionTypes[healthprobe.ConditionType(prober.Name())] = true
+		// keep the reported condition until the probe finishes its first run.
+		condition, ok := prober.Result()
+		if !ok {
+			continue
+		}
+		meta.SetStatusCondition(&cluster.Status.Conditions, condition)
+
+		if prober.AffectsAvailable() && condition.Status != metav1.ConditionTrue {
+			failedProbes = append(failedProbes, prober.Name())
+		}
+	}
+
+	// remove the conditions of the probes that are not configured anymore
+	for _, condition := range cluster.Status.DeepCopy().Conditions {
+		if strings.HasPrefix(condition.Type, healthprobe.ConditionTypePrefix) && !probeConditionTypes[condition.Type] {
+			meta.RemoveStatusCondition(&cluster.Status.Conditions, condition.Type)
+		}
+	}
+
+	if len(failedProbes) > 0 && meta.IsStatusConditionTrue(cluster.Status.Conditions, clusterv1.ManagedClusterConditionAvailable) {
+		meta.SetStatusCondition(&cluster.Status.Conditions, metav1.Condition{
+			Type:    clusterv1.ManagedClusterConditionAvailable,
+			Status:  metav1.ConditionFalse,
+			Reason:  helpers.ReasonHealthProbeFailed,
+			Message: fmt.Sprintf("The health probes are failed: %s", strings.Join(failedProbes, ", ")),
+		})
+	}
+
+	return cluster, reconcileContinue, nil
+}
@@ -0,0 +1,115 @@
+package managedcluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kubeinformers "k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	clusterv1 "open-cluster-management.io/api/cluster/v1"
+
+	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
+	testinghelpers "open-cluster-management.io/ocm/pkg/registration/helpers/testing"
+	"open-cluster-management.io/ocm/pkg/registration/spoke/healthprobe"
+)
+
+func TestHealthProbeReconcile(t *testing.T) {
+	cases := []struct {
+		name                    string
+		affectsAvailable        bool
+		nodeReady               bool
+		existingConditions      []metav1.Condition
+		expectedProbeStatus     metav1.ConditionStatus
+		expectedAvailableStatus metav1.ConditionStatus
+		unexpectedConditions    []string
+	}{
+		{
+			name:                    "probe succeeds",
+			affectsAvailable:        true,
+			nodeReady:               true,
+			expectedProbeStatus:     metav1.ConditionTrue,
+			expectedAvailableStatus: metav1.ConditionTrue,
+		},
+		{
+			name:                    "probe fails and affects available",
+			affectsAvailable:        true,
+			expectedProbeStatus:     metav1.ConditionFalse,
+			expectedAvailableStatus: metav1.ConditionFalse,
+		},
+		{
+			name:                    "probe fails and does not affect available",
+			expectedProbeStatus:     metav1.ConditionFalse,
+			expectedAvailableStatus: metav1.ConditionTrue,
+		},
+		{
+			name:      "remove the condition of the removed probe",
+			nodeReady: true,
+			existingConditions: []metav1.Condition{
+				testinghelpers.NewManagedClusterCondition(healthprobe.ConditionType("dns"), "False", "", "", nil),
+			},
+			expectedProbeStatus:     metav1.ConditionTrue,
+			expectedAvailableStatus: metav1.ConditionTrue,
+			unexpectedConditions:    []string{healthprobe.ConditionType("dns")},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubefake.NewSimpleClientset(), 0)
+			node := testinghelpers.NewNode("node1", testinghelpers.NewResourceList(1, 1), testinghelpers.NewResourceList(1, 1))
+			readyStatus := corev1.ConditionFalse
+			if c.nodeReady {
+				readyStatus = corev1.ConditionTrue
+			}
+			node.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: readyStatus}}
+			if err := kubeInformerFactory.Core().V1().Nodes().Informer().GetStore().Add(node); err != nil {
+				t.Fatal(err)
+			}
+
+			probers, err := healthprobe.NewProbers(&healthprobe.Config{Probes: []healthprobe.Probe{
+				{Name: "nodes", Type: healthprobe.NodeReadinessProbeType, AffectsAvailable: c.affectsAvailable},
+			}}, healthprobe.Clients{NodeLister: kubeInformerFactory.Core().V1().Nodes().Lister()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+			go probers[0].Run(ctx, time.Hour)
+			if err := wait.PollUntilContextTimeout(ctx, 10*time.Millisecond, 5*time.Second, true,
+				func(context.Context) (bool, error) {
+					_, ok := probers[0].Result()
+					return ok, nil
+				}); err != nil {
+				t.Fatal(err)
+			}
+
+			cluster := testinghelpers.NewAvailableManagedCluster()
+			cluster.Status.Conditions = append(cluster.Status.Conditions, c.existingConditions...)
+			r := &healthProbeReconcile{probers: probers}
+			cluster, _, err = r.reconcile(context.TODO(), testingcommon.NewFakeSyncContext(t, ""), cluster)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			probeCond := meta.FindStatusCondition(cluster.Status.Conditions, healthprobe.ConditionType("nodes"))
+			if probeCond == nil || probeCond.Status != c.expectedProbeStatus {
+				t.Errorf("expected probe condition status %q, but got %v", c.expectedProbeStatus, probeCond)
+			}
+			availableCond := meta.FindStatusCondition(cluster.Status.Conditions, clusterv1.ManagedClusterConditionAvailable)
+			if availableCond == nil || availableCond.Status != c.expectedAvailableStatus {
+				t.Errorf("expected available condition status %q, but got %v", c.expectedAvailableStatus, availableCond)
+			}
+			for _, condType := range c.unexpectedConditions {
+				if meta.FindStatusCondition(cluster.Status.Conditions, condType) != nil {
+					t.Errorf("unexpected condition %q", condType)
+				}
+			}
+		})
+	}
+}
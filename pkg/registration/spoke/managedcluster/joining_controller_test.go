@@ -103,6 +103,8 @@ func TestSyncManagedCluster(t *testing.T) {
 				kubeInformerFactory.Core().V1().Nodes(),
 				20,
 				[]string{},
+				nil,
+				nil,
 				hubEventRecorder,
 			)
 
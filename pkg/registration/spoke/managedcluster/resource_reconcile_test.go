@@ -337,6 +337,8 @@ func TestHealthCheck(t *testing.T) {
 				kubeInformerFactory.Core().V1().Nodes(),
 				20,
 				[]string{},
+				nil,
+				nil,
 				hubEventRecorder,
 			)
 			syncErr := ctrl.sync(context.TODO(), testingcommon.NewFakeSyncContext(t, ""), "")
@@ -25,6 +25,10 @@ import (
 	"open-cluster-management.io/sdk-go/pkg/patcher"
 
 	"open-cluster-management.io/ocm/pkg/features"
+	"open-cluster-management.io/ocm/pkg/registration/helpers"
+	"open-cluster-management.io/ocm/pkg/registration/spoke/claimprovider"
+	"open-cluster-management.io/ocm/pkg/registration/spoke/healthprobe"
+	"open-cluster-management.io/ocm/pkg/version"
 )
 
 // managedClusterStatusController checks the kube-apiserver health on managed cluster to determine it whether is available
@@ -62,6 +66,8 @@ func NewManagedClusterStatusController(
 	nodeInformer corev1informers.NodeInformer,
 	maxCustomClusterClaims int,
 	reservedClusterClaimSuffixes []string,
+	healthProbers []*healthprobe.Prober,
+	claimProviders *claimprovider.Providers,
 	resyncInterval time.Duration,
 	hubEventRecorder kevents.EventRecorder) factory.Controller {
 	c := newManagedClusterStatusController(
@@ -77,6 +83,8 @@ func NewManagedClusterStatusController(
 		nodeInformer,
 		maxCustomClusterClaims,
 		reservedClusterClaimSuffixes,
+		healthProbers,
+		claimProviders,
 		hubEventRecorder,
 	)
 
@@ -107,6 +115,8 @@ func newManagedClusterStatusController(
 	nodeInformer corev1informers.NodeInformer,
 	maxCustomClusterClaims int,
 	reservedClusterClaimSuffixes []string,
+	healthProbers []*healthprobe.Prober,
+	claimProviders *claimprovider.Providers,
 	hubEventRecorder kevents.EventRecorder) *managedClusterStatusController {
 	return &managedClusterStatusController{
 		clusterName: clusterName,
@@ -116,11 +126,14 @@ func newManagedClusterStatusController(
 		reconcilers: []statusReconcile{
 			&joiningReconcile{},
 			&resoureReconcile{managedClusterDiscoveryClient: managedClusterDiscoveryClient, nodeLister: nodeInformer.Lister()},
+			&healthProbeReconcile{probers: healthProbers},
 			&claimReconcile{claimLister: claimInformer.Lister(),
 				maxCustomClusterClaims:       maxCustomClusterClaims,
 				reservedClusterClaimSuffixes: reservedClusterClaimSuffixes,
 				aboutLister:                  propertyInformer.Lister(),
+				claimProviders:               claimProviders,
 			},
+			&agentVersionReconcile{agentVersion: version.Get().GitVersion},
 			&managedNamespaceReconcile{
 				hubClusterSetLabel:   GetHubClusterSetLabel(hubHash),
 				spokeKubeClient:      spokeKubeClient,
@@ -191,6 +204,11 @@ func (c *managedClusterStatusController) sendAvailableConditionEvent(
 			cluster.Name)
 
 	case metav1.ConditionFalse:
+		if newCondition.Reason == helpers.ReasonHealthProbeFailed {
+			c.hubEventRecorder.Eventf(newCluster, nil, corev1.EventTypeWarning, "Unavailable", "Unavailable",
+				"The %s is successfully imported. However, %s", cluster.Name, newCondition.Message)
+			return
+		}
 		c.hubEventRecorder.Eventf(newCluster, nil, corev1.EventTypeWarning, "Unavailable", "Unavailable",
 			"The %s is successfully imported. However, its Kube API server is unavailable", cluster.Name)
 	}
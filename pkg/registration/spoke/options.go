@@ -30,6 +30,11 @@ type SpokeAgentOptions struct {
 	// See more details in: https://github.com/open-cluster-management-io/ocm/pull/443#discussion_r1610868646
 	HubConnectionTimeoutSeconds int32
 
+	// HubCredentialRejectedTimeout is the duration the hub keeps rejecting the client credential before the agent
+	// invalidates the credential and rebootstraps. It is zero by default, which means the agent never rebootstraps
+	// for the rejection.
+	HubCredentialRejectedTimeout time.Duration
+
 	HubKubeconfigSecret          string
 	SpokeExternalServerURLs      []string
 	ClusterHealthCheckPeriod     time.Duration
@@ -37,6 +42,12 @@ type SpokeAgentOptions struct {
 	ReservedClusterClaimSuffixes []string
 	ClusterAnnotations           map[string]string
 
+	// HealthProbeConfigFile is the path of the file that declares the health probes of the managed cluster.
+	HealthProbeConfigFile string
+
+	// ClaimProviderConfigFile is the path of the file that declares the built-in cluster claim providers.
+	ClaimProviderConfigFile string
+
 	RegisterDriverOption *registerfactory.Options
 }
 
@@ -64,6 +75,8 @@ func (o *SpokeAgentOptions) AddFlags(fs *pflag.FlagSet) {
 		"The name of secrets in component namespace storing bootstrap kubeconfigs for agent bootstrap.")
 	fs.Int32Var(&o.HubConnectionTimeoutSeconds, "hub-connection-timeout-seconds", o.HubConnectionTimeoutSeconds,
 		"The timeout in seconds to connect to hub cluster.")
+	fs.DurationVar(&o.HubCredentialRejectedTimeout, "hub-credential-rejected-timeout", o.HubCredentialRejectedTimeout,
+		"The duration the hub keeps rejecting the client credential before the agent rebootstraps, the rebootstrap is disabled by default.")
 	fs.StringVar(&o.HubKubeconfigSecret, "hub-kubeconfig-secret", o.HubKubeconfigSecret,
 		"The name of secret in component namespace storing kubeconfig for hub.")
 	fs.StringArrayVar(&o.SpokeExternalServerURLs, "spoke-external-server-urls", o.SpokeExternalServerURLs,
@@ -77,6 +90,12 @@ func (o *SpokeAgentOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringToStringVar(&o.ClusterAnnotations, "cluster-annotations", o.ClusterAnnotations, `the annotations with the reserve
 	 prefix "agent.open-cluster-management.io" set on ManagedCluster when creating only, other actors can update it afterwards.`)
 
+	fs.StringVar(&o.HealthProbeConfigFile, "cluster-health-probe-config", o.HealthProbeConfigFile,
+		"The path of the file that declares the health probes of the managed cluster. Each probe is reported as a condition on the ManagedCluster.")
+
+	fs.StringVar(&o.ClaimProviderConfigFile, "cluster-claim-provider-config", o.ClaimProviderConfigFile,
+		"The path of the file that declares the built-in cluster claim providers. The provided claims are limited by max-custom-cluster-claims as well.")
+
 	o.RegisterDriverOption.AddFlags(fs)
 }
 
@@ -0,0 +1,71 @@
+package registration
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+
+	clientset "open-cluster-management.io/api/client/cluster/clientset/versioned"
+	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
+)
+
+// hubCredentialController handles the case that the hub keeps rejecting the client credential of the agent, which
+// happens when the hub is restored from a backup onto a new hub whose signer is different from the backed up hub.
+type hubCredentialController struct {
+	clusterName      string
+	hubClusterClient clientset.Interface
+	timeout          time.Duration
+	handleRejected   func(ctx context.Context) error
+
+	// rejectedSince is the time the hub starts to reject the credential, it is zero if the credential is accepted.
+	rejectedSince time.Time
+}
+
+// NewHubCredentialController returns a controller which calls handleRejected once the hub keeps rejecting the client
+// credential of the agent for the timeout, so the agent can rebootstrap to re-establish the credential.
+func NewHubCredentialController(
+	clusterName string,
+	hubClusterClient clientset.Interface,
+	timeout time.Duration,
+	handleRejected func(ctx context.Context) error,
+) factory.Controller {
+	c := &hubCredentialController{
+		clusterName:      clusterName,
+		hubClusterClient: hubClusterClient,
+		timeout:          timeout,
+		handleRejected:   handleRejected,
+	}
+	return factory.New().WithSync(c.sync).ResyncEvery(time.Minute).
+		ToController("HubCredentialController")
+}
+
+func (c *hubCredentialController) sync(ctx context.Context, _ factory.SyncContext, _ string) error {
+	logger := klog.FromContext(ctx)
+	if c.handleRejected == nil {
+		return nil
+	}
+
+	_, err := c.hubClusterClient.ClusterV1().ManagedClusters().Get(ctx, c.clusterName, metav1.GetOptions{})
+	if !errors.IsUnauthorized(err) {
+		// the credential is accepted, or the hub is not connectable which is handled by the timeout controller.
+		c.rejectedSince = time.Time{}
+		return nil
+	}
+
+	if c.rejectedSince.IsZero() {
+		c.rejectedSince = time.Now()
+	}
+	if time.Since(c.rejectedSince) < c.timeout {
+		logger.V(4).Info("Hub rejects the client credential", "cluster", c.clusterName, "since", c.rejectedSince)
+		return nil
+	}
+
+	logger.Info("Hub keeps rejecting the client credential", "cluster", c.clusterName, "since", c.rejectedSince)
+	if err := c.handleRejected(ctx); err != nil {
+		logger.Error(err, "Failed to handle the rejected client credential", "cluster", c.clusterName)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package registration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+
+	clusterfake "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
+
+	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
+	testinghelpers "open-cluster-management.io/ocm/pkg/registration/helpers/testing"
+)
+
+func TestHubCredentialController_Sync(t *testing.T) {
+	cases := []struct {
+		name          string
+		rejected      bool
+		rejectedSince time.Time
+		expectHandled bool
+		expectReset   bool
+	}{
+		{
+			name:          "credential is accepted",
+			rejectedSince: time.Now().Add(-10 * time.Minute),
+			expectReset:   true,
+		},
+		{
+			name:     "credential is rejected for the first time",
+			rejected: true,
+		},
+		{
+			name:          "credential is rejected within the timeout",
+			rejected:      true,
+			rejectedSince: time.Now().Add(-time.Minute),
+		},
+		{
+			name:          "credential is rejected for the timeout",
+			rejected:      true,
+			rejectedSince: time.Now().Add(-10 * time.Minute),
+			expectHandled: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			clusterClient := clusterfake.NewSimpleClientset(testinghelpers.NewAcceptedManagedCluster())
+			if c.rejected {
+				clusterClient.PrependReactor("get", "managedclusters",
+					func(action clienttesting.Action) (bool, runtime.Object, error) {
+						return true, nil, errors.NewUnauthorized("Unauthorized")
+					})
+			}
+
+			handled := false
+			controller := &hubCredentialController{
+				clusterName:      testinghelpers.TestManagedClusterName,
+				hubClusterClient: clusterClient,
+				timeout:          5 * time.Minute,
+				handleRejected: func(ctx context.Context) error {
+					handled = true
+					return nil
+				},
+				rejectedSince: c.rejectedSince,
+			}
+
+			if err := controller.sync(context.Background(), testingcommon.NewFakeSyncContext(t, ""), ""); err != nil {
+				t.Fatal(err)
+			}
+			if handled != c.expectHandled {
+				t.Errorf("expect handled %v, but got %v", c.expectHandled, handled)
+			}
+			if controller.rejectedSince.IsZero() != c.expectReset {
+				t.Errorf("unexpected rejected time %v", controller.rejectedSince)
+			}
+		})
+	}
+}
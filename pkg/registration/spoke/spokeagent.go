@@ -5,16 +5,20 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"os"
+	"path"
 	"time"
 
 	"github.com/openshift/library-go/pkg/controller/controllercmd"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apiserver/pkg/server/healthz"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog/v2"
 	aboutclient "sigs.k8s.io/about-api/pkg/generated/clientset/versioned"
@@ -29,8 +33,13 @@ import (
 
 	commonoptions "open-cluster-management.io/ocm/pkg/common/options"
 	"open-cluster-management.io/ocm/pkg/features"
+	"open-cluster-management.io/ocm/pkg/registration/helpers"
 	"open-cluster-management.io/ocm/pkg/registration/register"
+	"open-cluster-management.io/ocm/pkg/registration/register/csr"
 	"open-cluster-management.io/ocm/pkg/registration/spoke/addon"
+	"open-cluster-management.io/ocm/pkg/registration/spoke/claimprovider"
+	"open-cluster-management.io/ocm/pkg/registration/spoke/diagnostics"
+	"open-cluster-management.io/ocm/pkg/registration/spoke/healthprobe"
 	"open-cluster-management.io/ocm/pkg/registration/spoke/lease"
 	"open-cluster-management.io/ocm/pkg/registration/spoke/managedcluster"
 	"open-cluster-management.io/ocm/pkg/registration/spoke/registration"
@@ -341,6 +350,16 @@ func (o *SpokeAgentConfig) RunSpokeAgentWithSpokeInformers(ctx context.Context,
 	if err != nil {
 		return err
 	}
+	// renew the credentials once the hub forces them to rotate
+	if rotationDriver, ok := o.driver.(register.CredentialRotationDriver); ok {
+		rotationDriver.SetRevokeCredentialsIssuedBeforeFunc(func() (time.Time, bool) {
+			cluster, err := hubClient.ClusterInformer.Lister().Get(o.agentOptions.SpokeClusterName)
+			if err != nil {
+				return time.Time{}, false
+			}
+			return helpers.RevokeCredentialsIssuedBefore(cluster)
+		})
+	}
 	hubDriverInformer, _ := o.driver.InformerHandler()
 
 	recorder.Event(ctx, "HubClientConfigReady", "Client config for hub is ready.")
@@ -383,6 +402,23 @@ func (o *SpokeAgentConfig) RunSpokeAgentWithSpokeInformers(ctx context.Context,
 		}),
 	)
 
+	healthProbers, err := o.newHealthProbers(spokeClientConfig, spokeKubeClient, spokeKubeInformerFactory)
+	if err != nil {
+		return err
+	}
+
+	var claimProviderConfig *claimprovider.Config
+	if len(o.registrationOption.ClaimProviderConfigFile) > 0 {
+		claimProviderConfig, err = claimprovider.LoadConfig(o.registrationOption.ClaimProviderConfigFile)
+		if err != nil {
+			return err
+		}
+	}
+	claimProviders := claimprovider.NewProviders(claimProviderConfig, claimprovider.Clients{
+		DiscoveryClient: spokeKubeClient.Discovery(),
+		NodeLister:      spokeKubeInformerFactory.Core().V1().Nodes().Lister(),
+	}, o.registrationOption.ReservedClusterClaimSuffixes)
+
 	// create NewManagedClusterStatusController to update the spoke cluster status
 	// now includes managed namespace reconciler
 	managedClusterHealthCheckController := managedcluster.NewManagedClusterStatusController(
@@ -398,6 +434,8 @@ func (o *SpokeAgentConfig) RunSpokeAgentWithSpokeInformers(ctx context.Context,
 		spokeKubeInformerFactory.Core().V1().Nodes(),
 		o.registrationOption.MaxCustomClusterClaims,
 		o.registrationOption.ReservedClusterClaimSuffixes,
+		healthProbers,
+		claimProviders,
 		o.registrationOption.ClusterHealthCheckPeriod,
 		hubEventRecorder,
 	)
@@ -454,6 +492,41 @@ func (o *SpokeAgentConfig) RunSpokeAgentWithSpokeInformers(ctx context.Context,
 		)
 	}
 
+	var hubCredentialController factory.Controller
+	if o.registrationOption.HubCredentialRejectedTimeout > 0 {
+		hubCredentialController = registration.NewHubCredentialController(
+			o.agentOptions.SpokeClusterName,
+			hubClient.ClusterClient,
+			o.registrationOption.HubCredentialRejectedTimeout,
+			func(ctx context.Context) error {
+				logger.Info("Failed to connect to hub because of the client credential is rejected, restart agent to rebootstrap")
+				if err := o.invalidateHubClientCert(ctx, managementKubeClient); err != nil {
+					return err
+				}
+				o.agentStopFunc()
+				return nil
+			},
+		)
+	}
+
+	// the spoke diagnostics are uploaded into a ConfigMap on the hub, which requires the kube client of the hub.
+	var diagnosticsController factory.Controller
+	if hubClient.KubeClient != nil {
+		managementDynamicClient, err := dynamic.NewForConfig(kubeConfig)
+		if err != nil {
+			return err
+		}
+		diagnosticsController = diagnostics.NewDiagnosticsController(
+			o.agentOptions.SpokeClusterName,
+			o.agentOptions.ComponentNamespace,
+			hubClient.KubeClient.CoreV1().ConfigMaps(o.agentOptions.SpokeClusterName),
+			hubClient.ClusterInformer,
+			managementKubeClient,
+			managementDynamicClient,
+			features.SpokeMutableFeatureGate,
+		)
+	}
+
 	if hubDriverInformer != nil {
 		go hubDriverInformer.Run(ctx.Done())
 	}
@@ -474,6 +547,26 @@ func (o *SpokeAgentConfig) RunSpokeAgentWithSpokeInformers(ctx context.Context,
 	go secretController.Run(ctx, 1)
 	go managedClusterLeaseController.Run(ctx, 1)
 	go managedClusterHealthCheckController.Run(ctx, 1)
+	if len(healthProbers) > 0 {
+		go func() {
+			// the node readiness probes read nodes from the informer.
+			if !cache.WaitForCacheSync(ctx.Done(), spokeKubeInformerFactory.Core().V1().Nodes().Informer().HasSynced) {
+				return
+			}
+			for _, prober := range healthProbers {
+				go prober.Run(ctx, o.registrationOption.ClusterHealthCheckPeriod)
+			}
+		}()
+	}
+	if features.SpokeMutableFeatureGate.Enabled(ocmfeature.ClusterClaim) && claimProviderConfig != nil {
+		go func() {
+			// the node label and cloud metadata providers read nodes from the informer.
+			if !cache.WaitForCacheSync(ctx.Done(), spokeKubeInformerFactory.Core().V1().Nodes().Informer().HasSynced) {
+				return
+			}
+			claimProviders.Run(ctx)
+		}()
+	}
 	if features.SpokeMutableFeatureGate.Enabled(ocmfeature.AddonManagement) {
 		go addOnLeaseController.Run(ctx, 1)
 		// addon registration controller runs when the driver implements AddonDriverFactory
@@ -493,6 +586,14 @@ func (o *SpokeAgentConfig) RunSpokeAgentWithSpokeInformers(ctx context.Context,
 		go hubTimeoutController.Run(ctx, 1)
 	}
 
+	if hubCredentialController != nil {
+		go hubCredentialController.Run(ctx, 1)
+	}
+
+	if diagnosticsController != nil {
+		go diagnosticsController.Run(ctx, 1)
+	}
+
 	<-ctx.Done()
 	return nil
 }
@@ -519,6 +620,32 @@ func (o *SpokeAgentConfig) getSpokeClusterCABundle(kubeConfig *rest.Config) ([]b
 	return data, nil
 }
 
+// invalidateHubClientCert removes the client certificate and key from the hub kubeconfig secret and the hub kubeconfig
+// dir, so the hub kubeconfig is invalid and the agent rebootstraps after it restarts.
+func (o *SpokeAgentConfig) invalidateHubClientCert(ctx context.Context, kubeClient kubernetes.Interface) error {
+	secret, err := kubeClient.CoreV1().Secrets(o.agentOptions.ComponentNamespace).Get(
+		ctx, o.registrationOption.HubKubeconfigSecret, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+	case err != nil:
+		return err
+	default:
+		secret = secret.DeepCopy()
+		delete(secret.Data, csr.TLSKeyFile)
+		delete(secret.Data, csr.TLSCertFile)
+		if _, err := kubeClient.CoreV1().Secrets(secret.Namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range []string{csr.TLSKeyFile, csr.TLSCertFile} {
+		if err := os.Remove(path.Join(o.agentOptions.HubKubeconfigDir, file)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
 func (o *SpokeAgentConfig) getHubHash() (string, error) {
 	kubeConfig, err := clientcmd.BuildConfigFromFlags("", o.currentBootstrapKubeConfig)
 	if err != nil {
@@ -526,3 +653,29 @@ func (o *SpokeAgentConfig) getHubHash() (string, error) {
 	}
 	return fmt.Sprintf("%x", sha256.Sum256([]byte(kubeConfig.Host))), nil
 }
+
+// newHealthProbers builds the health probers declared in the health probe config file, it returns nothing if the
+// config file is not specified.
+func (o *SpokeAgentConfig) newHealthProbers(spokeClientConfig *rest.Config, spokeKubeClient kubernetes.Interface,
+	spokeKubeInformerFactory informers.SharedInformerFactory) ([]*healthprobe.Prober, error) {
+	if len(o.registrationOption.HealthProbeConfigFile) == 0 {
+		return nil, nil
+	}
+
+	config, err := healthprobe.LoadConfig(o.registrationOption.HealthProbeConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	spokeDynamicClient, err := dynamic.NewForConfig(spokeClientConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return healthprobe.NewProbers(config, healthprobe.Clients{
+		KubeClient:    spokeKubeClient,
+		DynamicClient: spokeDynamicClient,
+		RESTClient:    spokeKubeClient.Discovery().RESTClient(),
+		NodeLister:    spokeKubeInformerFactory.Core().V1().Nodes().Lister(),
+	})
+}
@@ -0,0 +1,22 @@
+package webhook
+
+import "github.com/spf13/pflag"
+
+// Options are the options of the registration webhook.
+type Options struct {
+	// HubNamespace is the namespace of the cluster manager on the hub, where the revocations of the managed cluster
+	// credentials are kept.
+	HubNamespace string
+}
+
+// NewOptions constructs a new set of default options for webhook.
+func NewOptions() *Options {
+	return &Options{
+		HubNamespace: "open-cluster-management-hub",
+	}
+}
+
+func (c *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&c.HubNamespace, "hub-namespace", c.HubNamespace,
+		"The namespace of the cluster manager on the hub, where the revoked managed cluster credentials are kept.")
+}
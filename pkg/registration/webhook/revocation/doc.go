@@ -0,0 +1,2 @@
+// package revocation contains the admission webhook rejecting the requests of the revoked managed cluster credentials.
+package revocation
@@ -0,0 +1,84 @@
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	kubeinformers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	hubrevocation "open-cluster-management.io/ocm/pkg/registration/hub/revocation"
+)
+
+// WebhookPath is the path of the admission webhook serving the credential revocation.
+const WebhookPath = "/validate-credential-revocation"
+
+// RevocationWebhook is a validating admission webhook of the hub kube-apiserver rejecting the requests of the revoked
+// managed cluster credentials.
+//
+// The kube-apiserver verifies the client certificates and the service account tokens itself and never consults an
+// authentication webhook for them, so the revocation is enforced on the requests changing the hub resources by the
+// admission webhook registered by the cluster manager operator. The admission request carries the credential id of
+// the certificates and the identities, but not the credential issue time, so the forced rotation only rejects the
+// certificates whose CSRs are still on the hub, the gRPC server rejects all of them at the authentication.
+type RevocationWebhook struct {
+	// Namespace is the hub namespace of the ConfigMap keeping the revocations.
+	Namespace string
+	checker   *hubrevocation.Checker
+}
+
+var _ admission.Handler = &RevocationWebhook{}
+
+func (w *RevocationWebhook) Init(mgr ctrl.Manager) error {
+	kubeClient, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+
+	informers := kubeinformers.NewSharedInformerFactoryWithOptions(kubeClient, 30*time.Minute,
+		kubeinformers.WithNamespace(w.Namespace),
+		kubeinformers.WithTweakListOptions(func(listOptions *metav1.ListOptions) {
+			listOptions.FieldSelector = fields.OneTermEqualSelector("metadata.name", hubrevocation.ConfigMapName).String()
+		}))
+	w.checker, err = hubrevocation.NewChecker(w.Namespace, informers.Core().V1().ConfigMaps())
+	if err != nil {
+		return err
+	}
+	if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+		informers.Start(ctx.Done())
+		<-ctx.Done()
+		return nil
+	})); err != nil {
+		return err
+	}
+
+	mgr.GetWebhookServer().Register(WebhookPath, &admission.Webhook{Handler: w})
+	return nil
+}
+
+// Handle denies the request if its credential is revoked.
+func (w *RevocationWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	cred := hubrevocation.Credential{User: req.UserInfo.Username, Groups: req.UserInfo.Groups}
+	if ids := req.UserInfo.Extra[hubrevocation.CredentialIDKey]; len(ids) > 0 {
+		cred.ID = ids[0]
+	}
+
+	revoked, clusterName, err := w.checker.IsRevoked(cred)
+	if err != nil {
+		return admission.Errored(http.StatusServiceUnavailable, err)
+	}
+	if !revoked {
+		return admission.Allowed("")
+	}
+
+	klog.FromContext(ctx).V(4).Info("Credential is revoked", "user", cred.User, "managedClusterName", clusterName)
+	return admission.Denied(fmt.Sprintf("the credentials of managed cluster %s are revoked", clusterName))
+}
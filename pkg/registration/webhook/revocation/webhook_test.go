@@ -0,0 +1,88 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	hubrevocation "open-cluster-management.io/ocm/pkg/registration/hub/revocation"
+)
+
+func TestRevocationWebhook(t *testing.T) {
+	data, err := json.Marshal(hubrevocation.Revocation{
+		Identities:    hubrevocation.ClusterIdentities("cluster1"),
+		CredentialIDs: []string{"X509SHA256=revoked"},
+		Reason:        hubrevocation.ReasonDenied,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubefake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: hubrevocation.ConfigMapName, Namespace: "open-cluster-management-hub"},
+		Data:       map[string]string{"cluster1": string(data), "cluster2": "invalid"},
+	}), 0)
+	checker, err := hubrevocation.NewChecker("open-cluster-management-hub", kubeInformerFactory.Core().V1().ConfigMaps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	kubeInformerFactory.Start(ctx.Done())
+	kubeInformerFactory.WaitForCacheSync(ctx.Done())
+	webhook := &RevocationWebhook{Namespace: "open-cluster-management-hub", checker: checker}
+
+	cases := []struct {
+		name            string
+		userInfo        authenticationv1.UserInfo
+		expectedAllowed bool
+		expectedMessage string
+	}{
+		{
+			name: "identity is revoked",
+			userInfo: authenticationv1.UserInfo{
+				Username: "system:open-cluster-management:cluster1:agent",
+				Groups:   []string{"system:open-cluster-management:cluster1"},
+			},
+			expectedMessage: "the credentials of managed cluster cluster1 are revoked",
+		},
+		{
+			name: "certificate is revoked",
+			userInfo: authenticationv1.UserInfo{
+				Username: "test",
+				Extra:    map[string]authenticationv1.ExtraValue{hubrevocation.CredentialIDKey: {"X509SHA256=revoked"}},
+			},
+			expectedMessage: "the credentials of managed cluster cluster1 are revoked",
+		},
+		{
+			name: "credential is not revoked",
+			userInfo: authenticationv1.UserInfo{
+				Username: "system:open-cluster-management:cluster3:agent",
+				Groups:   []string{"system:open-cluster-management:cluster3"},
+				Extra:    map[string]authenticationv1.ExtraValue{hubrevocation.CredentialIDKey: {"X509SHA256=valid"}},
+			},
+			expectedAllowed: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp := webhook.Handle(context.TODO(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				UserInfo: c.userInfo,
+			}})
+			if resp.Allowed != c.expectedAllowed {
+				t.Errorf("expected allowed %v, but got %v", c.expectedAllowed, resp.Allowed)
+			}
+			if !c.expectedAllowed && resp.Result.Message != c.expectedMessage {
+				t.Errorf("expected message %q, but got %q", c.expectedMessage, resp.Result.Message)
+			}
+		})
+	}
+}
@@ -6,11 +6,13 @@ import (
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 
 	commonoptions "open-cluster-management.io/ocm/pkg/common/options"
+	"open-cluster-management.io/ocm/pkg/features"
+	"open-cluster-management.io/ocm/pkg/registration/webhook/revocation"
 	internalv1 "open-cluster-management.io/ocm/pkg/registration/webhook/v1"
 	internalv1beta2 "open-cluster-management.io/ocm/pkg/registration/webhook/v1beta2"
 )
 
-func SetupWebhookServer(opts *commonoptions.WebhookOptions) error {
+func (c *Options) SetupWebhookServer(opts *commonoptions.WebhookOptions) error {
 	if err := opts.InstallScheme(
 		clientgoscheme.AddToScheme,
 		clusterv1.Install,
@@ -21,6 +23,9 @@ func SetupWebhookServer(opts *commonoptions.WebhookOptions) error {
 	opts.InstallWebhook(
 		&internalv1.ManagedClusterWebhook{},
 		&internalv1beta2.ManagedClusterSetBindingWebhook{})
+	if features.HubMutableFeatureGate.Enabled(features.ClientCertificateRevocation) {
+		opts.InstallWebhook(&revocation.RevocationWebhook{Namespace: c.HubNamespace})
+	}
 
 	return nil
 }
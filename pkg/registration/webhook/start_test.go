@@ -3,12 +3,16 @@ package webhook
 import (
 	"testing"
 
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+
 	commonoptions "open-cluster-management.io/ocm/pkg/common/options"
+	"open-cluster-management.io/ocm/pkg/features"
 )
 
 func TestSetupWebhookServer(t *testing.T) {
+	utilruntime.Must(features.HubMutableFeatureGate.Add(features.DefaultHubRegistrationFeatureGates))
 	opts := commonoptions.NewWebhookOptions()
-	err := SetupWebhookServer(opts)
+	err := NewOptions().SetupWebhookServer(opts)
 	if err != nil {
 		t.Errorf("SetupWebhookServer() error = %v, wantErr %v", err, nil)
 	}
@@ -16,7 +16,6 @@ import (
 
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 	clusterv1beta2 "open-cluster-management.io/api/cluster/v1beta2"
-	ocmfeature "open-cluster-management.io/api/feature"
 
 	"open-cluster-management.io/ocm/pkg/features"
 )
@@ -445,7 +444,7 @@ func TestDefault(t *testing.T) {
 			},
 		},
 	}
-	runtime.Must(features.HubMutableFeatureGate.Add(ocmfeature.DefaultHubRegistrationFeatureGates))
+	runtime.Must(features.HubMutableFeatureGate.Add(features.DefaultHubRegistrationFeatureGates))
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			w := ManagedClusterWebhook{}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	operatorhelpers "github.com/openshift/library-go/pkg/operator/v1helpers"
 	authenticationv1 "k8s.io/api/authentication/v1"
@@ -105,6 +106,19 @@ func (r *ManagedClusterWebhook) ValidateDelete(_ context.Context, _ *v1.ManagedC
 
 // validateManagedClusterObj validates the fileds of ManagedCluster object
 func (r *ManagedClusterWebhook) validateManagedClusterObj(cluster v1.ManagedCluster) error {
+	// the end time of the maintenance window and the credential revocation time must be in RFC3339 format
+	for _, key := range []string{helpers.MaintenanceUntilAnnotationKey, helpers.RevokeCredentialsIssuedBeforeAnnotationKey} {
+		if value, ok := cluster.Annotations[key]; ok {
+			if _, err := time.Parse(time.RFC3339, value); err != nil {
+				return apierrors.NewBadRequest(fmt.Sprintf("annotation %q is invalid: %v", key, err))
+			}
+		}
+	}
+	if until, ok := helpers.DeclaredMaintenanceUntil(&cluster); ok && until.After(time.Now().Add(helpers.MaxMaintenanceWindow)) {
+		return apierrors.NewBadRequest(fmt.Sprintf("annotation %q is invalid: the maintenance window exceeds the maximum %v",
+			helpers.MaintenanceUntilAnnotationKey, helpers.MaxMaintenanceWindow))
+	}
+
 	errs := []error{}
 	// The cluster name must be the same format of namespace name.
 	if errMsgs := apimachineryvalidation.ValidateNamespaceName(cluster.Name, false); len(errMsgs) > 0 {
@@ -3,6 +3,7 @@ package v1
 import (
 	"context"
 	"testing"
+	"time"
 
 	admissionv1 "k8s.io/api/admission/v1"
 	authorizationv1 "k8s.io/api/authorization/v1"
@@ -15,6 +16,8 @@ import (
 
 	v1 "open-cluster-management.io/api/cluster/v1"
 	"open-cluster-management.io/api/cluster/v1beta2"
+
+	"open-cluster-management.io/ocm/pkg/registration/helpers"
 )
 
 func TestValidateCreate(t *testing.T) {
@@ -36,6 +39,40 @@ func TestValidateCreate(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:          "validate invalid maintenance annotation",
+			expectedError: true,
+			cluster: &v1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "set-1",
+					Annotations: map[string]string{helpers.MaintenanceUntilAnnotationKey: "tomorrow"},
+				},
+			},
+		},
+		{
+			name:          "validate valid maintenance annotation",
+			expectedError: false,
+			cluster: &v1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "set-1",
+					Annotations: map[string]string{
+						helpers.MaintenanceUntilAnnotationKey: time.Now().Add(time.Hour).Format(time.RFC3339),
+					},
+				},
+			},
+		},
+		{
+			name:          "validate maintenance annotation exceeding the maximum window",
+			expectedError: true,
+			cluster: &v1.ManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "set-1",
+					Annotations: map[string]string{
+						helpers.MaintenanceUntilAnnotationKey: time.Now().Add(48 * time.Hour).Format(time.RFC3339),
+					},
+				},
+			},
+		},
 		{
 			name:                   "validate creating an accepted ManagedCluster without permission",
 			expectedError:          true,
@@ -2,10 +2,14 @@ package grpc
 
 import (
 	"context"
+	"time"
 
 	"github.com/openshift/library-go/pkg/controller/controllercmd"
 	"github.com/spf13/pflag"
 	"google.golang.org/grpc"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	kubeinformers "k8s.io/client-go/informers"
 
 	addonce "open-cluster-management.io/sdk-go/pkg/cloudevents/clients/addon/v1alpha1"
 	clusterce "open-cluster-management.io/sdk-go/pkg/cloudevents/clients/cluster"
@@ -21,6 +25,7 @@ import (
 	sdkgrpc "open-cluster-management.io/sdk-go/pkg/server/grpc"
 	grpcauthn "open-cluster-management.io/sdk-go/pkg/server/grpc/authn"
 
+	"open-cluster-management.io/ocm/pkg/registration/hub/revocation"
 	"open-cluster-management.io/ocm/pkg/server/services/addon"
 	"open-cluster-management.io/ocm/pkg/server/services/cluster"
 	"open-cluster-management.io/ocm/pkg/server/services/csr"
@@ -31,8 +36,11 @@ import (
 )
 
 type GRPCServerOptions struct {
-	GRPCServerConfig  string
-	grpcBrokerOptions *cloudeventsgrpc.BrokerOptions
+	GRPCServerConfig string
+	// CredentialRevocation rejects the requests with the revoked managed cluster credentials, it is enabled if the
+	// ClientCertificateRevocation feature gate of the hub registration is enabled.
+	CredentialRevocation bool
+	grpcBrokerOptions    *cloudeventsgrpc.BrokerOptions
 }
 
 func NewGRPCServerOptions() *GRPCServerOptions {
@@ -43,6 +51,8 @@ func NewGRPCServerOptions() *GRPCServerOptions {
 
 func (o *GRPCServerOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.GRPCServerConfig, "server-config", o.GRPCServerConfig, "Location of the server configuration file.")
+	fs.BoolVar(&o.CredentialRevocation, "credential-revocation", o.CredentialRevocation,
+		"Reject the requests with the revoked managed cluster credentials.")
 	o.grpcBrokerOptions.AddFlags(fs)
 }
 
@@ -73,14 +83,34 @@ func (o *GRPCServerOptions) Run(ctx context.Context, controllerContext *controll
 		work.NewWorkService(clients.WorkClient, clients.WorkInformers.Work().V1().ManifestWorks()))
 	grpcEventServer.RegisterService(ctx, sace.TokenRequestDataType, tokenrequest.NewTokenRequestService(clients.KubeClient))
 
+	var tokenAuthenticator grpcauthn.Authenticator = grpcauthn.NewTokenAuthenticator(clients.KubeClient)
+	var mtlsAuthenticator grpcauthn.Authenticator = grpcauthn.NewMtlsAuthenticator()
+	if o.CredentialRevocation {
+		// the revoked credentials are kept in a ConfigMap in the hub namespace
+		revocationInformers := kubeinformers.NewSharedInformerFactoryWithOptions(clients.KubeClient, 30*time.Minute,
+			kubeinformers.WithNamespace(controllerContext.OperatorNamespace),
+			kubeinformers.WithTweakListOptions(func(listOptions *metav1.ListOptions) {
+				listOptions.FieldSelector = fields.OneTermEqualSelector("metadata.name", revocation.ConfigMapName).String()
+			}))
+		revocationChecker, err := revocation.NewChecker(controllerContext.OperatorNamespace,
+			revocationInformers.Core().V1().ConfigMaps())
+		if err != nil {
+			return err
+		}
+		go revocationInformers.Start(ctx.Done())
+
+		tokenAuthenticator = newRevocationAuthenticator(tokenAuthenticator, revocationChecker, tokenCredential)
+		mtlsAuthenticator = newRevocationAuthenticator(mtlsAuthenticator, revocationChecker, certificateCredential)
+	}
+
 	// start clients
 	go clients.Run(ctx)
 
 	// initialize and run grpc server
 	authorizer := grpcauthz.NewSARAuthorizer(clients.KubeClient)
 	return sdkgrpc.NewGRPCServer(serverOptions).
-		WithAuthenticator(grpcauthn.NewTokenAuthenticator(clients.KubeClient)).
-		WithAuthenticator(grpcauthn.NewMtlsAuthenticator()).
+		WithAuthenticator(tokenAuthenticator).
+		WithAuthenticator(mtlsAuthenticator).
 		WithUnaryAuthorizer(authorizer).
 		WithStreamAuthorizer(authorizer).
 		WithRegisterFunc(func(s *grpc.Server) {
@@ -0,0 +1,104 @@
+package grpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+
+	"open-cluster-management.io/sdk-go/pkg/server/grpc/authn"
+
+	"open-cluster-management.io/ocm/pkg/registration/helpers"
+	"open-cluster-management.io/ocm/pkg/registration/hub/revocation"
+)
+
+// credentialFunc fills the credential id and the issue time of the credential of a request.
+type credentialFunc func(ctx context.Context, cred *revocation.Credential)
+
+// revocationAuthenticator rejects the requests whose credentials are revoked after they are authenticated by the
+// delegated authenticator.
+type revocationAuthenticator struct {
+	delegate   authn.Authenticator
+	checker    *revocation.Checker
+	credential credentialFunc
+}
+
+var _ authn.Authenticator = &revocationAuthenticator{}
+
+func newRevocationAuthenticator(
+	delegate authn.Authenticator, checker *revocation.Checker, credential credentialFunc) *revocationAuthenticator {
+	return &revocationAuthenticator{delegate: delegate, checker: checker, credential: credential}
+}
+
+func (a *revocationAuthenticator) Authenticate(ctx context.Context) (context.Context, error) {
+	newCtx, err := a.delegate.Authenticate(ctx)
+	if err != nil {
+		return newCtx, err
+	}
+
+	cred := revocation.Credential{}
+	cred.User, _ = newCtx.Value(authn.ContextUserKey).(string)
+	cred.Groups, _ = newCtx.Value(authn.ContextGroupsKey).([]string)
+	a.credential(ctx, &cred)
+
+	revoked, clusterName, err := a.checker.IsRevoked(cred)
+	if err != nil {
+		return ctx, status.Errorf(codes.Unavailable, "failed to check the credential revocation: %v", err)
+	}
+	if revoked {
+		klog.FromContext(ctx).V(4).Info("Credential is revoked", "user", cred.User, "managedClusterName", clusterName)
+		return ctx, status.Error(codes.Unauthenticated, "credential is revoked")
+	}
+	return newCtx, nil
+}
+
+// certificateCredential reads the credential id and the issue time from the verified client certificate.
+func certificateCredential(ctx context.Context, cred *revocation.Credential) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return
+	}
+	tlsAuth, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsAuth.State.VerifiedChains) == 0 || len(tlsAuth.State.VerifiedChains[0]) == 0 {
+		return
+	}
+	cert := tlsAuth.State.VerifiedChains[0][0]
+	if cert == nil {
+		return
+	}
+	cred.ID = revocation.CertificateCredentialID(cert)
+	cred.IssuedAt = helpers.CertificateIssuedAt(cert.NotBefore)
+}
+
+// tokenCredential reads the issue time from the iat claim of the token. The token is already reviewed by the
+// delegated authenticator, so its payload is decoded without verifying the signature.
+func tokenCredential(ctx context.Context, cred *revocation.Credential) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md["authorization"]) == 0 {
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(md["authorization"][0], "Bearer "), ".")
+	if len(parts) != 3 {
+		return
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return
+	}
+	claims := struct {
+		IssuedAt int64 `json:"iat"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.IssuedAt == 0 {
+		return
+	}
+	cred.IssuedAt = time.Unix(claims.IssuedAt, 0)
+}
@@ -0,0 +1,101 @@
+package grpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+
+	"open-cluster-management.io/sdk-go/pkg/server/grpc/authn"
+
+	"open-cluster-management.io/ocm/pkg/registration/hub/revocation"
+)
+
+type fakeAuthenticator struct {
+	user   string
+	groups []string
+}
+
+func (a *fakeAuthenticator) Authenticate(ctx context.Context) (context.Context, error) {
+	ctx = context.WithValue(ctx, authn.ContextUserKey, a.user)
+	return context.WithValue(ctx, authn.ContextGroupsKey, a.groups), nil
+}
+
+func newTokenContext(issuedAt time.Time) context.Context {
+	payload, _ := json.Marshal(map[string]int64{"iat": issuedAt.Unix()})
+	token := fmt.Sprintf("header.%s.signature", base64.RawURLEncoding.EncodeToString(payload))
+	return metadata.NewIncomingContext(context.TODO(), metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestRevocationAuthenticator(t *testing.T) {
+	revokedAt := time.Now()
+	data, err := json.Marshal(revocation.Revocation{
+		Identities:   revocation.ClusterIdentities("cluster1", "system:serviceaccount:cluster1:test-agent"),
+		IssuedBefore: &metav1.Time{Time: revokedAt},
+		Reason:       revocation.ReasonForcedRotation,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubefake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: revocation.ConfigMapName, Namespace: "open-cluster-management-hub"},
+		Data:       map[string]string{"cluster1": string(data)},
+	}), 0)
+	checker, err := revocation.NewChecker("open-cluster-management-hub", kubeInformerFactory.Core().V1().ConfigMaps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	kubeInformerFactory.Start(ctx.Done())
+	kubeInformerFactory.WaitForCacheSync(ctx.Done())
+
+	cases := []struct {
+		name         string
+		ctx          context.Context
+		user         string
+		expectedCode codes.Code
+	}{
+		{
+			name:         "token issued before the revocation",
+			ctx:          newTokenContext(revokedAt.Add(-time.Hour)),
+			user:         "system:serviceaccount:cluster1:test-agent",
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:         "token issued after the revocation",
+			ctx:          newTokenContext(revokedAt.Add(time.Hour)),
+			user:         "system:serviceaccount:cluster1:test-agent",
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "token of another service account",
+			ctx:          newTokenContext(revokedAt.Add(-time.Hour)),
+			user:         "system:serviceaccount:cluster1:other-agent",
+			expectedCode: codes.OK,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			authenticator := newRevocationAuthenticator(&fakeAuthenticator{user: c.user}, checker, tokenCredential)
+			newCtx, err := authenticator.Authenticate(c.ctx)
+			if code := status.Code(err); code != c.expectedCode {
+				t.Errorf("expected code %v, but got %v", c.expectedCode, err)
+			}
+			if err == nil && newCtx.Value(authn.ContextUserKey) != c.user {
+				t.Errorf("expected the user in the context, but got %v", newCtx.Value(authn.ContextUserKey))
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"open-cluster-management.io/sdk-go/pkg/basecontroller/factory"
 	"open-cluster-management.io/sdk-go/pkg/patcher"
 
+	commonhelpers "open-cluster-management.io/ocm/pkg/common/helpers"
 	"open-cluster-management.io/ocm/pkg/common/queue"
 	"open-cluster-management.io/ocm/pkg/work/helper"
 )
@@ -30,6 +31,9 @@ const (
 	EvictionGracePeriodBound = 100 * 365 * 24 * time.Hour
 
 	unManagedAppliedManifestWork = "UnManagedAppliedManifestWork"
+
+	// the interval to check whether the restored manifestwork is applied before adopting its appliedmanifestwork
+	adoptionCheckInterval = 30 * time.Second
 )
 
 type unmanagedAppliedWorkController struct {
@@ -48,6 +52,10 @@ type unmanagedAppliedWorkController struct {
 //   - the manifestwork of the current appliedmanifestwork is missing on the hub, or
 //   - the appliedmanifestwork hub hash does not match the current hub hash of the work agent.
 //
+// If the manifestwork is restored from a backup of the hub whose hub hash matches the appliedmanifestwork, the
+// appliedmanifestwork is deleted without the grace period once the manifestwork is applied again, and the resources
+// adopted by the appliedmanifestwork of the current hub are kept on the managed cluster.
+//
 // One unmanaged appliedmanifestwork will be evicted from the managed cluster after a grace period (by
 // default, 60 minutes), after one appliedmanifestwork is evicted from the managed cluster, its owned
 // resources will also be evicted from the managed cluster with Kubernetes garbage collection.
@@ -109,7 +117,7 @@ func (m *unmanagedAppliedWorkController) sync(ctx context.Context, controllerCon
 		return m.stopToEvictAppliedManifestWork(ctx, appliedManifestWork)
 	}
 
-	_, err = m.manifestWorkLister.Get(appliedManifestWork.Spec.ManifestWorkName)
+	manifestWork, err := m.manifestWorkLister.Get(appliedManifestWork.Spec.ManifestWorkName)
 	if errors.IsNotFound(err) {
 		// evict the current appliedmanifestwork when its relating manifestwork is missing on the hub
 		return m.evictAppliedManifestWork(ctx, controllerContext, appliedManifestWork)
@@ -120,6 +128,12 @@ func (m *unmanagedAppliedWorkController) sync(ctx context.Context, controllerCon
 
 	// manifestwork exists but hub changed
 	if !strings.HasPrefix(appliedManifestWork.Name, m.hubHash) {
+		// the manifestwork is restored from a backup of the hub of the current appliedmanifestwork, the
+		// appliedmanifestwork is adopted rather than evicted.
+		if restoredFrom, ok := manifestWork.Annotations[commonhelpers.RestoredFromHubAnnotationKey]; ok &&
+			restoredFrom == appliedManifestWork.Spec.HubHash {
+			return m.adoptAppliedManifestWork(ctx, controllerContext, manifestWork, appliedManifestWork)
+		}
 		return m.evictAppliedManifestWork(ctx, controllerContext, appliedManifestWork)
 	}
 
@@ -156,6 +170,35 @@ func (m *unmanagedAppliedWorkController) evictAppliedManifestWork(ctx context.Co
 	return nil
 }
 
+// adoptAppliedManifestWork deletes the appliedmanifestwork of the backed up hub once the restored manifestwork is
+// applied by the appliedmanifestwork of the current hub. The resources are owned by both appliedmanifestworks by then,
+// so they are kept on the managed cluster, and only the resources that are no longer in the manifestwork are deleted.
+func (m *unmanagedAppliedWorkController) adoptAppliedManifestWork(ctx context.Context,
+	controllerContext factory.SyncContext, manifestWork *workapiv1.ManifestWork,
+	appliedManifestWork *workapiv1.AppliedManifestWork) error {
+	logger := klog.FromContext(ctx)
+
+	_, err := m.appliedManifestWorkLister.Get(fmt.Sprintf("%s-%s", m.hubHash, manifestWork.Name))
+	switch {
+	case errors.IsNotFound(err):
+	case err != nil:
+		return err
+	default:
+		applied := meta.FindStatusCondition(manifestWork.Status.Conditions, workapiv1.WorkApplied)
+		if applied != nil && applied.Status == metav1.ConditionTrue && applied.ObservedGeneration == manifestWork.Generation {
+			if err := m.appliedManifestWorkClient.Delete(ctx, appliedManifestWork.Name, metav1.DeleteOptions{}); err != nil {
+				return err
+			}
+			logger.Info("AppliedManifestWork of the backed up hub is adopted", "agentID", m.agentID)
+			return nil
+		}
+	}
+
+	// wait until the manifestwork is applied, and do not evict the appliedmanifestwork meanwhile.
+	controllerContext.Queue().AddAfter(appliedManifestWork.Name, adoptionCheckInterval)
+	return m.stopToEvictAppliedManifestWork(ctx, appliedManifestWork)
+}
+
 func (m *unmanagedAppliedWorkController) stopToEvictAppliedManifestWork(
 	ctx context.Context, appliedManifestWork *workapiv1.AppliedManifestWork) error {
 	if appliedManifestWork.Status.EvictionStartTime == nil {
@@ -14,6 +14,7 @@ import (
 	workapiv1 "open-cluster-management.io/api/work/v1"
 	"open-cluster-management.io/sdk-go/pkg/patcher"
 
+	commonhelpers "open-cluster-management.io/ocm/pkg/common/helpers"
 	testingcommon "open-cluster-management.io/ocm/pkg/common/testing"
 )
 
@@ -110,6 +111,87 @@ func TestSyncUnamanagedAppliedWork(t *testing.T) {
 				testingcommon.AssertActions(t, actions, "patch")
 			},
 		},
+		{
+			name:                    "wait to adopt appliedmanifestwork until the restored manifestwork is applied",
+			appliedManifestWorkName: "hubhash-test",
+			hubHash:                 "hubhash-new",
+			agentID:                 "test-agent",
+			works: []runtime.Object{
+				&workapiv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "test",
+						Namespace:   "test",
+						Annotations: map[string]string{commonhelpers.RestoredFromHubAnnotationKey: "hubhash"},
+					},
+				},
+			},
+			appliedWorks: []runtime.Object{
+				&workapiv1.AppliedManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "hubhash-test",
+					},
+					Spec: workapiv1.AppliedManifestWorkSpec{
+						ManifestWorkName: "test",
+						HubHash:          "hubhash",
+						AgentID:          "test-agent",
+					},
+					Status: workapiv1.AppliedManifestWorkStatus{
+						EvictionStartTime: &metav1.Time{
+							Time: time.Now().Add(-5 * time.Minute),
+						},
+					},
+				},
+			},
+			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "patch")
+			},
+		},
+		{
+			name:                    "adopt appliedmanifestwork once the restored manifestwork is applied",
+			appliedManifestWorkName: "hubhash-test",
+			hubHash:                 "hubhash-new",
+			agentID:                 "test-agent",
+			works: []runtime.Object{
+				&workapiv1.ManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "test",
+						Namespace:   "test",
+						Generation:  1,
+						Annotations: map[string]string{commonhelpers.RestoredFromHubAnnotationKey: "hubhash"},
+					},
+					Status: workapiv1.ManifestWorkStatus{
+						Conditions: []metav1.Condition{
+							{Type: workapiv1.WorkApplied, Status: metav1.ConditionTrue, ObservedGeneration: 1},
+						},
+					},
+				},
+			},
+			appliedWorks: []runtime.Object{
+				&workapiv1.AppliedManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "hubhash-test",
+					},
+					Spec: workapiv1.AppliedManifestWorkSpec{
+						ManifestWorkName: "test",
+						HubHash:          "hubhash",
+						AgentID:          "test-agent",
+					},
+				},
+				&workapiv1.AppliedManifestWork{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "hubhash-new-test",
+					},
+					Spec: workapiv1.AppliedManifestWorkSpec{
+						ManifestWorkName: "test",
+						HubHash:          "hubhash-new",
+						AgentID:          "test-agent",
+					},
+				},
+			},
+			validateAppliedManifestWorkActions: func(t *testing.T, actions []clienttesting.Action) {
+				testingcommon.AssertActions(t, actions, "delete")
+			},
+		},
 		{
 			name:                    "delete appliedmanifestwork after eviction grace period ",
 			appliedManifestWorkName: "hubhash-test",
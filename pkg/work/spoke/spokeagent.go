@@ -14,6 +14,7 @@ import (
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 
+	clusterclientset "open-cluster-management.io/api/client/cluster/clientset/versioned"
 	workclientset "open-cluster-management.io/api/client/work/clientset/versioned"
 	workv1client "open-cluster-management.io/api/client/work/clientset/versioned/typed/work/v1"
 	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
@@ -25,8 +26,10 @@ import (
 	"open-cluster-management.io/sdk-go/pkg/cloudevents/clients/work/store"
 	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/builder"
 
+	commonhelpers "open-cluster-management.io/ocm/pkg/common/helpers"
 	"open-cluster-management.io/ocm/pkg/common/options"
 	"open-cluster-management.io/ocm/pkg/features"
+	"open-cluster-management.io/ocm/pkg/version"
 	"open-cluster-management.io/ocm/pkg/work/helper"
 	"open-cluster-management.io/ocm/pkg/work/spoke/auth"
 	"open-cluster-management.io/ocm/pkg/work/spoke/conditions"
@@ -98,6 +101,19 @@ func (o *WorkAgentConfig) RunWorkloadAgent(ctx context.Context, controllerContex
 	if err != nil {
 		return err
 	}
+	spokeClusterClient, err := clusterclientset.NewForConfig(spokeRestConfig)
+	if err != nil {
+		return err
+	}
+
+	// report the version of the work agent for the hub to check the agent version skew, the agent keeps running
+	// if it fails to report.
+	if agentVersion := version.Get().GitVersion; len(agentVersion) > 0 {
+		if err := commonhelpers.EnsureAgentVersionClaim(ctx, spokeClusterClient.ClusterV1alpha1().ClusterClaims(),
+			commonhelpers.WorkAgentComponent, agentVersion); err != nil {
+			logger.Error(err, "failed to report the version of the work agent")
+		}
+	}
 
 	// Resyncing at a small interval may cause performance issues when the number of AppliedManifestWorks is large.
 	// Since the resync interval for the ManifestWork informer is set to 24 hours, use a different interval, such as